@@ -0,0 +1,296 @@
+package main
+
+import (
+        "archive/tar"
+        "archive/zip"
+        "bufio"
+        "bytes"
+        "compress/gzip"
+        "errors"
+        "fmt"
+        "io"
+        "os"
+        "strings"
+)
+
+// archiveFormat names the archive format of path by its extension: "zip",
+// "tar" or "tar.gz" (also .tgz), or "" for any other file
+func archiveFormat(path string) string {
+        name := strings.ToLower(path)
+        switch {
+        case strings.HasSuffix(name, ".zip"):
+                return "zip"
+        case strings.HasSuffix(name, ".tar"):
+                return "tar"
+        case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+                return "tar.gz"
+        }
+        return ""
+}
+
+// isTextSample tells whether the start of a file is text that can be
+// cleaned: valid UTF-8, or text in the encoding named with -input-encoding
+// or found by its byte order mark or detectEncoding, that decodes without
+// invalid sequences and has no NUL characters. Anything else, such as a
+// PDF or a JPEG image, is not cleaned, even if it happens to have no NUL
+// bytes at its start.
+func isTextSample(sample []byte, options CleaningOptions) bool {
+        encoding := inputEncodingOf(sample, options)
+        if !strings.HasPrefix(encoding, "UTF-8") && !decodable(encoding) {
+                return false
+        }
+        text, invalid := decodeSample(sample, encoding)
+        return invalid == 0 && bytes.IndexByte(text, 0) < 0
+}
+
+// archiveCleaner rebuilds an archive with its text entries cleaned
+type archiveCleaner struct {
+        name    string
+        options CleaningOptions
+        verbose bool
+        check   bool
+        stats   *CleaningStats
+}
+
+// cleanArchive cleans the text entries of the ZIP or TAR archive at
+// inputPath and writes the rebuilt archive to sink. Entries that are not
+// text, directories and links are copied as they are, and so are, in a ZIP
+// archive, text entries that cleaning leaves unchanged. The statistics are
+// those of all text entries together; an entry that cannot be cleaned fails
+// the archive.
+func cleanArchive(inputPath string, sink OutputSink, options CleaningOptions, verbose bool) (*CleaningStats, error) {
+        if options.UndoPatch || options.OnlyLines != nil {
+                return nil, errors.New("-undo-patch and -changed-only cannot be used with archives")
+        }
+        in, err := os.Open(inputPath)
+        if err != nil {
+                return nil, fmt.Errorf("could not read input file: %w", err)
+        }
+        defer in.Close()
+        info, err := in.Stat()
+        if err != nil {
+                return nil, fmt.Errorf("could not read input file: %w", err)
+        }
+
+        _, check := sink.(*discardSink)
+        a := &archiveCleaner{
+                name:    inputPath,
+                options: options,
+                verbose: verbose,
+                check:   check,
+                stats:   &CleaningStats{RemovedCharDetails: make(map[rune]int)},
+        }
+        // Entries are counted by their size in the archive, not unpacked
+        a.options.Progress = nil
+
+        w, err := sink.Open()
+        if err != nil {
+                return nil, err
+        }
+        switch archiveFormat(inputPath) {
+        case "zip":
+                err = a.zip(in, info.Size(), w)
+        case "tar":
+                err = a.tar(in, w)
+        case "tar.gz":
+                err = a.tarGzip(in, w)
+        }
+        if err != nil {
+                sink.Abort()
+                return nil, err
+        }
+        if err := sink.Close(); err != nil {
+                return nil, err
+        }
+        skipProgress(options, info.Size())
+        return a.stats, nil
+}
+
+func (a *archiveCleaner) zip(in io.ReaderAt, size int64, w io.Writer) error {
+        zr, err := zip.NewReader(in, size)
+        if err != nil {
+                return fmt.Errorf("could not read ZIP archive: %w", err)
+        }
+        zw := zip.NewWriter(w)
+        if err := zw.SetComment(zr.Comment); err != nil {
+                return err
+        }
+        for _, f := range zr.File {
+                if err := a.zipEntry(zw, f); err != nil {
+                        return err
+                }
+        }
+        return zw.Close()
+}
+
+func (a *archiveCleaner) zipEntry(zw *zip.Writer, f *zip.File) error {
+        // Encrypted entries and those of compression methods Go cannot read
+        // are copied without looking into them
+        if !f.Mode().IsRegular() || f.Flags&0x1 != 0 {
+                return zw.Copy(f)
+        }
+        rc, err := f.Open()
+        if errors.Is(err, zip.ErrAlgorithm) {
+                return zw.Copy(f)
+        }
+        if err != nil {
+                return fmt.Errorf("%s: %w", f.Name, err)
+        }
+        defer rc.Close()
+
+        tmp, stats, err := a.cleanEntry(f.Name, bufio.NewReaderSize(rc, detectSampleSize))
+        if err != nil {
+                return err
+        }
+        defer os.Remove(tmp)
+        if a.check || stats == nil || !stats.changed() {
+                return zw.Copy(f)
+        }
+
+        header := f.FileHeader
+        header.Extra = withoutZip64Extra(header.Extra)
+        header.CRC32 = 0
+        header.CompressedSize, header.UncompressedSize = 0, 0
+        header.CompressedSize64, header.UncompressedSize64 = 0, 0
+        dst, err := zw.CreateHeader(&header)
+        if err != nil {
+                return fmt.Errorf("%s: %w", f.Name, err)
+        }
+        return copyFileTo(dst, tmp)
+}
+
+// withoutZip64Extra drops the ZIP64 extended information of a ZIP extra
+// field, whose sizes are those of the original entry; the writer adds its
+// own when the cleaned entry needs one
+func withoutZip64Extra(extra []byte) []byte {
+        var kept []byte
+        for len(extra) >= 4 {
+                id := uint16(extra[0]) | uint16(extra[1])<<8
+                size := 4 + (int(extra[2]) | int(extra[3])<<8)
+                if size > len(extra) {
+                        break
+                }
+                if id != 0x0001 {
+                        kept = append(kept, extra[:size]...)
+                }
+                extra = extra[size:]
+        }
+        return kept
+}
+
+func (a *archiveCleaner) tarGzip(r io.Reader, w io.Writer) error {
+        zr, err := gzip.NewReader(r)
+        if err != nil {
+                return fmt.Errorf("could not read gzip stream: %w", err)
+        }
+        zw := gzip.NewWriter(w)
+        zw.Header = zr.Header
+        if err := a.tar(zr, zw); err != nil {
+                return err
+        }
+        return zw.Close()
+}
+
+func (a *archiveCleaner) tar(r io.Reader, w io.Writer) error {
+        tr := tar.NewReader(r)
+        tw := tar.NewWriter(w)
+        for {
+                header, err := tr.Next()
+                if err == io.EOF {
+                        break
+                }
+                if err != nil {
+                        return fmt.Errorf("could not read TAR archive: %w", err)
+                }
+                if err := a.tarEntry(tw, tr, header); err != nil {
+                        return err
+                }
+        }
+        return tw.Close()
+}
+
+func (a *archiveCleaner) tarEntry(tw *tar.Writer, tr *tar.Reader, header *tar.Header) error {
+        reader := bufio.NewReaderSize(tr, detectSampleSize)
+        if header.FileInfo().Mode().IsRegular() {
+                tmp, stats, err := a.cleanEntry(header.Name, reader)
+                if err != nil {
+                        return err
+                }
+                if stats != nil && a.check {
+                        return nil
+                }
+                if stats != nil {
+                        defer os.Remove(tmp)
+                        info, err := os.Stat(tmp)
+                        if err != nil {
+                                return err
+                        }
+                        cleaned := *header
+                        cleaned.Size = info.Size()
+                        if err := tw.WriteHeader(&cleaned); err != nil {
+                                return fmt.Errorf("%s: %w", header.Name, err)
+                        }
+                        return copyFileTo(tw, tmp)
+                }
+        }
+        if err := tw.WriteHeader(header); err != nil {
+                return fmt.Errorf("%s: %w", header.Name, err)
+        }
+        _, err := io.Copy(tw, reader)
+        return err
+}
+
+// cleanEntry cleans a text entry of the archive into a temporary file, or
+// into nothing in check mode, and returns its path and the statistics of
+// the entry. For an entry that is not text it returns nil statistics and
+// leaves the entry unread past its start.
+func (a *archiveCleaner) cleanEntry(name string, reader *bufio.Reader) (string, *CleaningStats, error) {
+        sample, err := reader.Peek(detectSampleSize)
+        if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+                return "", nil, fmt.Errorf("%s: %w", name, err)
+        }
+        if !isTextSample(sample, a.options) {
+                if a.verbose {
+                        fmt.Printf("   %s: not text, copied byte for byte\n", name)
+                }
+                return "", nil, nil
+        }
+
+        var sink OutputSink = &discardSink{}
+        tmp := ""
+        if !a.check {
+                file, err := os.CreateTemp(a.options.TempDir, "cleanfile-entry-*")
+                if err != nil {
+                        return "", nil, fmt.Errorf("could not create temporary file: %w", err)
+                }
+                file.Close()
+                tmp = file.Name()
+                sink = &fileSink{Path: tmp}
+        }
+        stats, err := cleanReader(reader, nil, a.name+"!"+name, sink, a.options, a.verbose)
+        if err != nil {
+                os.Remove(tmp)
+                return "", nil, fmt.Errorf("%s: %w", name, err)
+        }
+        if a.verbose {
+                fmt.Printf("   %s: %s\n", name, cleanOutcome(FileResult{Stats: stats}))
+        }
+        mergeStats(a.stats, stats)
+        a.stats.Locations = append(a.stats.Locations, stats.Locations...)
+        for _, w := range stats.Warnings {
+                w.Message += " (" + name + ")"
+                a.stats.Warnings = append(a.stats.Warnings, w)
+        }
+        return tmp, stats, nil
+}
+
+// copyFileTo copies the content of the file at path to w
+func copyFileTo(w io.Writer, path string) error {
+        f, err := os.Open(path)
+        if err != nil {
+                return err
+        }
+        defer f.Close()
+        _, err = io.Copy(w, f)
+        return err
+}
@@ -0,0 +1,40 @@
+package main
+
+// Masks for testing eight bytes at once: the high bit of each byte, and
+// each byte set to 0x20 and to 0x01
+const (
+        highBits   = 0x8080808080808080
+        spaceBytes = 0x2020202020202020
+        oneBytes   = 0x0101010101010101
+)
+
+// isPlainASCII reports whether s holds only printable ASCII, tabs and line
+// breaks: text that no cleaning rule removes or replaces, so it can be
+// copied as it is. Eight bytes are tested at a time; a word with a control
+// character in it, usually a tab, is looked at byte by byte.
+func isPlainASCII(s string) bool {
+        i := 0
+        for ; i+8 <= len(s); i += 8 {
+                w := uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+                        uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+                // A byte is below 0x20 if subtracting 0x20 borrows into
+                // its high bit, and DEL if adding 1 carries into it
+                if w&highBits == 0 && ((w-spaceBytes)|(w+oneBytes))&highBits == 0 {
+                        continue
+                }
+                if !plainASCIIBytes(s[i : i+8]) {
+                        return false
+                }
+        }
+        return plainASCIIBytes(s[i:])
+}
+
+func plainASCIIBytes(s string) bool {
+        for i := 0; i < len(s); i++ {
+                c := s[i]
+                if c >= 0x7F || c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+                        return false
+                }
+        }
+        return true
+}
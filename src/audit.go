@@ -0,0 +1,147 @@
+package main
+
+import (
+        "bufio"
+        "encoding/json"
+        "fmt"
+        "io"
+        "os"
+        "strings"
+)
+
+// AuditEntry is one invisible character found by audit
+type AuditEntry struct {
+        Path      string `json:"path"`
+        Line      int    `json:"line"`
+        Column    int    `json:"column"`
+        Offset    int64  `json:"offset"` // in bytes from the start of the file
+        Codepoint string `json:"codepoint"`
+        Name      string `json:"name"`
+        Category  string `json:"category"` // Unicode general category
+        Context   string `json:"context"`
+}
+
+// runAudit implements the audit subcommand
+func runAudit(args []string) int {
+        fs := newSubcommandFlags("audit")
+        recursive := fs.Bool("recursive", false, "Audit every file in the given directory trees")
+        include := fs.String("include", "", "Comma-separated glob patterns of files to audit in recursive mode")
+        exclude := fs.String("exclude", "", "Comma-separated glob patterns of files or directories to skip in recursive mode")
+        reportFormat := fs.String("report", "text", "Report format: text or json")
+        colorMode := fs.String("color", "auto", "Colorize the report: auto, always, never (NO_COLOR disables auto)")
+        paths := parseInterspersed(fs, args)
+
+        if len(paths) == 0 {
+                exitWithUsage(fs, "at least one file or directory is required")
+        }
+        if *reportFormat != "text" && *reportFormat != "json" {
+                exitWithUsage(fs, "invalid report format '%s' (valid options: text, json)", *reportFormat)
+        }
+        if err := setupColor(*colorMode); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        batch := BatchOptions{Include: splitPatterns(*include), Exclude: splitPatterns(*exclude)}
+        files, err := expandPaths(paths, *recursive, batch, "audit")
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        entries := []AuditEntry{}
+        for _, path := range files {
+                found, err := auditFile(path)
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+                        return 1
+                }
+                entries = append(entries, found...)
+        }
+
+        if *reportFormat == "json" {
+                encoder := json.NewEncoder(os.Stdout)
+                encoder.SetIndent("", "  ")
+                encoder.SetEscapeHTML(false)
+                if err := encoder.Encode(entries); err != nil {
+                        fmt.Printf("Error: could not write JSON report: %v\n", err)
+                        return 1
+                }
+        } else {
+                printAudit(os.Stdout, entries, len(files))
+        }
+
+        if len(entries) > 0 {
+                return 1
+        }
+        return 0
+}
+
+// auditFile lists every invisible character of a file. Files that do not
+// look like UTF-8 text are skipped.
+func auditFile(path string) ([]AuditEntry, error) {
+        f, err := os.Open(path)
+        if err != nil {
+                return nil, err
+        }
+        defer f.Close()
+
+        reader := bufio.NewReaderSize(f, detectSampleSize)
+        prefix, err := reader.Peek(detectSampleSize)
+        if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+                return nil, err
+        }
+        if encoding, _ := detectEncoding(prefix); !strings.HasPrefix(encoding, "UTF-8") {
+                return nil, nil
+        }
+
+        var entries []AuditEntry
+        var offset int64
+        lines := newLineReader(reader)
+        for lineNum := 1; ; lineNum++ {
+                line, ending, err := lines.readLine()
+                if err == io.EOF {
+                        return entries, nil
+                }
+                if err != nil {
+                        return nil, err
+                }
+
+                if strings.IndexFunc(line, isInvisible) >= 0 {
+                        runes := []rune(line)
+                        byteIndex := 0
+                        for i, r := range runes {
+                                if isInvisible(r) {
+                                        entries = append(entries, AuditEntry{
+                                                Path:      path,
+                                                Line:      lineNum,
+                                                Column:    i + 1,
+                                                Offset:    offset + int64(byteIndex),
+                                                Codepoint: fmt.Sprintf("U+%04X", r),
+                                                Name:      unicodeName(r),
+                                                Category:  generalCategory(r),
+                                                Context:   auditContextAt(runes, i),
+                                        })
+                                }
+                                byteIndex += len(string(r))
+                        }
+                }
+                offset += int64(len(line) + len(ending))
+        }
+}
+
+func printAudit(w io.Writer, entries []AuditEntry, scanned int) {
+        files := make(map[string]bool)
+        for _, e := range entries {
+                files[e.Path] = true
+                fmt.Fprintf(w, "%s:%d:%d (byte %d): %s %s [%s]\n", e.Path, e.Line, e.Column, e.Offset,
+                        colorize(e.Codepoint, ansiBold), e.Name, e.Category)
+                fmt.Fprintf(w, "    | %s\n", e.Context)
+        }
+
+        if len(entries) == 0 {
+                fmt.Fprintln(w, colorize(fmt.Sprintf("No invisible characters found in %d file(s)", scanned), ansiGreen))
+                return
+        }
+        fmt.Fprintf(w, "\nFound %d invisible character(s) in %d of %d file(s)\n", len(entries), len(files), scanned)
+}
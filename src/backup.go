@@ -0,0 +1,47 @@
+package main
+
+import (
+        "fmt"
+        "os"
+        "path/filepath"
+        "strings"
+        "time"
+)
+
+// backupNames are the values of -backup-name: file.bak, file.<time>.bak
+// or file.<n>.bak
+var backupNames = []string{"plain", "timestamp", "numbered"}
+
+// backupTimeLayout is the time in timestamped backup names. It has no
+// colons, which Windows does not allow in file names.
+const backupTimeLayout = "2006-01-02T15-04-05"
+
+// backupPath returns where the backup of inputPath is written: next to it,
+// or with -backup-dir under that directory at its path relative to root
+// (its name alone if root is empty), named as -backup-name says. Numbered
+// backups take the first free number, so they never replace another.
+func backupPath(inputPath, root string, options CleaningOptions) string {
+        path := inputPath
+        if options.BackupDir != "" {
+                rel := filepath.Base(inputPath)
+                if root != "" {
+                        if r, err := filepath.Rel(root, inputPath); err == nil && !strings.HasPrefix(r, "..") {
+                                rel = r
+                        }
+                }
+                path = filepath.Join(options.BackupDir, rel)
+        }
+
+        switch options.BackupName {
+        case "timestamp":
+                return path + "." + time.Now().Format(backupTimeLayout) + ".bak"
+        case "numbered":
+                for n := 1; ; n++ {
+                        candidate := fmt.Sprintf("%s.%d.bak", path, n)
+                        if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+                                return candidate
+                        }
+                }
+        }
+        return path + ".bak"
+}
@@ -0,0 +1,656 @@
+package main
+
+import (
+        "fmt"
+        "io"
+        "os"
+        "path/filepath"
+        "runtime"
+        "sort"
+        "strings"
+        "sync"
+        "sync/atomic"
+        "time"
+)
+
+// BatchOptions controls which files are picked up in recursive mode and
+// where their cleaned content is written
+type BatchOptions struct {
+        Include   []string
+        Exclude   []string
+        InPlace   bool
+        CheckOnly bool
+        Jobs      int
+        FailFast  bool
+
+        // MakeWritable temporarily adds write permission to read-only files
+        // and directories instead of reporting them
+        MakeWritable bool
+
+        // ChangedOnly restricts cleaning to lines changed since the last commit
+        ChangedOnly bool
+
+        // EditorConfig, if set, adjusts the options of each file
+        EditorConfig *editorConfigResolver
+
+        // Stream, if set, receives the result of each file as soon as it is
+        // done
+        Stream *findingStream
+
+        // OutputDir, if set, receives the cleaned files at their path
+        // relative to Root, instead of name_cleaned.ext next to each input
+        Root      string
+        OutputDir string
+
+        // Force allows existing outputs and backups to be overwritten
+        Force bool
+
+        // Deadline, if set, is when no more files are started
+        // (-time-budget), and ScanState names the file that records when
+        // each file was last scanned (-scan-state)
+        Deadline  time.Time
+        ScanState string
+
+        // CacheFile names the file that records which files were found
+        // clean (-cache), loaded into Cache for the run
+        CacheFile string
+        Cache     *cleanCache
+
+        // NoProgress turns off the progress bar shown on a terminal
+        // (-no-progress)
+        NoProgress bool
+}
+
+// failedCount returns the number of results that carry an error
+func failedCount(results []FileResult) int {
+        failed := 0
+        for _, r := range results {
+                if r.Err != nil {
+                        failed++
+                }
+        }
+        return failed
+}
+
+// splitPatterns turns a comma-separated pattern list into a slice
+func splitPatterns(list string) []string {
+        var patterns []string
+        for _, p := range strings.Split(list, ",") {
+                p = strings.TrimSpace(p)
+                if p != "" {
+                        patterns = append(patterns, filepath.ToSlash(p))
+                }
+        }
+        return patterns
+}
+
+// matchGlob reports whether a slash-separated relative path matches pattern.
+// Patterns without a slash are matched against the base name only, so
+// "*.md" matches at any depth. "**" matches zero or more path segments.
+func matchGlob(pattern, relPath string) bool {
+        if !strings.Contains(pattern, "/") {
+                ok, _ := filepath.Match(pattern, filepath.Base(relPath))
+                return ok
+        }
+        return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+func matchSegments(pattern, path []string) bool {
+        for len(pattern) > 0 {
+                if pattern[0] == "**" {
+                        if len(pattern) == 1 {
+                                return true
+                        }
+                        for i := 0; i <= len(path); i++ {
+                                if matchSegments(pattern[1:], path[i:]) {
+                                        return true
+                                }
+                        }
+                        return false
+                }
+                if len(path) == 0 {
+                        return false
+                }
+                ok, err := filepath.Match(pattern[0], path[0])
+                if err != nil || !ok {
+                        return false
+                }
+                pattern = pattern[1:]
+                path = path[1:]
+        }
+        return len(path) == 0
+}
+
+func matchesAny(patterns []string, relPath string) bool {
+        for _, p := range patterns {
+                if matchGlob(p, relPath) {
+                        return true
+                }
+        }
+        return false
+}
+
+// isCleanfileArtifact reports whether a file was produced by a previous run,
+// so re-running on the same tree doesn't clean its own outputs and backups
+func isCleanfileArtifact(path string) bool {
+        if strings.HasSuffix(path, ".bak") || strings.HasSuffix(path, undoPatchSuffix) {
+                return true
+        }
+        base := filepath.Base(path)
+        base = strings.TrimSuffix(base, outputExt(base))
+        return strings.HasSuffix(base, "_cleaned")
+}
+
+// collectFiles walks root and returns the regular files selected by the
+// include and exclude patterns, in lexical order. Hidden directories such
+// as .git, and the output directory if it is inside root, are skipped.
+func collectFiles(root string, batch BatchOptions) ([]string, error) {
+        var files []string
+        outputDir := ""
+        if batch.OutputDir != "" {
+                outputDir, _ = filepath.Abs(batch.OutputDir)
+        }
+
+        err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+                if err != nil {
+                        return err
+                }
+
+                rel, err := filepath.Rel(root, path)
+                if err != nil {
+                        return err
+                }
+                rel = filepath.ToSlash(rel)
+
+                if info.IsDir() {
+                        if rel == "." {
+                                return nil
+                        }
+                        if strings.HasPrefix(info.Name(), ".") || matchesAny(batch.Exclude, rel) {
+                                return filepath.SkipDir
+                        }
+                        if abs, _ := filepath.Abs(path); outputDir != "" && abs == outputDir {
+                                return filepath.SkipDir
+                        }
+                        return nil
+                }
+
+                if !info.Mode().IsRegular() || isCleanfileArtifact(path) {
+                        return nil
+                }
+                if len(batch.Include) > 0 && !matchesAny(batch.Include, rel) {
+                        return nil
+                }
+                if matchesAny(batch.Exclude, rel) {
+                        return nil
+                }
+
+                files = append(files, path)
+                return nil
+        })
+        if err != nil {
+                return nil, fmt.Errorf("could not walk directory: %w", err)
+        }
+
+        return files, nil
+}
+
+// expandPaths lists the files named by the arguments of a read-only
+// command: files as given, and with recursive the selected files below each
+// directory. verb names the command in the error for a directory given
+// without recursive.
+func expandPaths(paths []string, recursive bool, batch BatchOptions, verb string) ([]string, error) {
+        var files []string
+        for _, path := range paths {
+                info, err := os.Stat(path)
+                if err != nil {
+                        return nil, fmt.Errorf("Could not access '%s': %v", path, err)
+                }
+                if !info.IsDir() {
+                        files = append(files, path)
+                        continue
+                }
+                if !recursive {
+                        return nil, fmt.Errorf("'%s' is a directory (use -recursive to %s a directory tree)", path, verb)
+                }
+                found, err := collectFiles(path, batch)
+                if err != nil {
+                        return nil, err
+                }
+                files = append(files, found...)
+        }
+        return files, nil
+}
+
+// runBatch cleans every selected file below root
+func runBatch(root string, batch BatchOptions, options CleaningOptions, backup, verbose bool) ([]FileResult, error) {
+        batch.Root = root
+        files, err := collectFiles(root, batch)
+        if err != nil {
+                return nil, err
+        }
+        files = withoutFiles(files, batch.CacheFile, batch.ScanState)
+        if batch.CacheFile != "" {
+                if batch.Cache, err = loadCleanCache(batch.CacheFile); err != nil {
+                        return nil, err
+                }
+        }
+        if batch.Deadline.IsZero() && batch.ScanState == "" {
+                results, err := cleanFiles(files, batch, options, backup, verbose)
+                if err == nil && batch.Cache != nil {
+                        err = batch.Cache.save(batch.CacheFile)
+                }
+                return results, err
+        }
+
+        state := make(scanState)
+        if batch.ScanState != "" {
+                if state, err = loadScanState(batch.ScanState); err != nil {
+                        return nil, err
+                }
+        }
+        results, err := cleanFiles(prioritize(files, state), batch, options, backup, verbose)
+        if err != nil {
+                return nil, err
+        }
+
+        // Files not started before the deadline have no result
+        finished := results[:0]
+        for _, r := range results {
+                if r.InputPath != "" {
+                        finished = append(finished, r)
+                }
+        }
+        sort.Slice(finished, func(i, j int) bool { return finished[i].InputPath < finished[j].InputPath })
+        if left := len(files) - len(finished); left > 0 {
+                fmt.Fprintf(os.Stderr, "Time budget reached: %d of %d file(s) scanned, %d left for the next run\n", len(finished), len(files), left)
+        }
+
+        if batch.ScanState != "" {
+                state.record(finished, time.Now())
+                if err := state.save(batch.ScanState); err != nil {
+                        return nil, err
+                }
+        }
+        if batch.Cache != nil {
+                if err := batch.Cache.save(batch.CacheFile); err != nil {
+                        return nil, err
+                }
+        }
+        return finished, nil
+}
+
+// withoutFiles removes from files those that are the same as any of
+// skipped, such as the cache or scan state of the run inside the tree
+func withoutFiles(files []string, skipped ...string) []string {
+        var infos []os.FileInfo
+        for _, path := range skipped {
+                if info, err := os.Stat(path); err == nil {
+                        infos = append(infos, info)
+                }
+        }
+        if len(infos) == 0 {
+                return files
+        }
+        kept := files[:0]
+        for _, path := range files {
+                info, err := os.Stat(path)
+                skip := false
+                for _, s := range infos {
+                        skip = skip || err == nil && os.SameFile(info, s)
+                }
+                if !skip {
+                        kept = append(kept, path)
+                }
+        }
+        return kept
+}
+
+// cleanFiles cleans files using batch.Jobs workers. Results are returned in
+// the order of files, regardless of which worker finished first. Files that
+// fail the permission preflight, or fail while being cleaned, are recorded in
+// their result and the others are still cleaned, unless batch.FailFast is
+// set; then the preflight problems or the first error stop the run.
+func cleanFiles(files []string, batch BatchOptions, options CleaningOptions, backup, verbose bool) ([]FileResult, error) {
+        jobs := batch.Jobs
+        if jobs <= 0 {
+                jobs = runtime.NumCPU()
+        }
+        if jobs > len(files) {
+                jobs = len(files)
+        }
+        jobs, options.MaxMemory = memoryPerFile(options.MaxMemory, jobs)
+
+        problems, changes := preflight(files, batch, options, backup)
+        defer restorePermissions(changes)
+        if len(problems) > 0 && batch.FailFast {
+                return nil, preflightError(files, problems)
+        }
+
+        batch.Stream.start(len(files), batch.CheckOnly)
+        var progress *progressMeter
+        if !batch.NoProgress {
+                progress = startProgress(len(files), totalSize(files))
+                options.Progress = progress
+                defer progress.finish()
+        }
+        results := make([]FileResult, len(files))
+        errs := make([]error, len(files))
+        indexes := make(chan int)
+        var failed int32
+        var wg sync.WaitGroup
+
+        for w := 0; w < jobs; w++ {
+                wg.Add(1)
+                go func() {
+                        defer wg.Done()
+                        for i := range indexes {
+                                if err := problems[files[i]]; err != nil {
+                                        errs[i] = err
+                                } else {
+                                        results[i], errs[i] = processBatchFile(files[i], batch, options, backup, verbose)
+                                }
+                                progress.fileDone()
+                                if errs[i] == nil {
+                                        batch.Stream.file(results[i])
+                                        continue
+                                }
+                                if batch.FailFast {
+                                        batch.Stream.file(FileResult{InputPath: files[i], Err: errs[i]})
+                                        atomic.StoreInt32(&failed, 1)
+                                        continue
+                                }
+                                results[i] = FileResult{
+                                        InputPath: files[i],
+                                        Stats:     &CleaningStats{RemovedCharDetails: make(map[rune]int)},
+                                        Err:       errs[i],
+                                }
+                                batch.Stream.file(results[i])
+                        }
+                }()
+        }
+
+        for i := range files {
+                if atomic.LoadInt32(&failed) != 0 {
+                        break
+                }
+                if !batch.Deadline.IsZero() && time.Now().After(batch.Deadline) {
+                        break
+                }
+                indexes <- i
+        }
+        close(indexes)
+        wg.Wait()
+
+        if batch.FailFast {
+                for _, err := range errs {
+                        if err != nil {
+                                return nil, err
+                        }
+                }
+        }
+
+        return results, nil
+}
+
+// processBatchFile cleans a single file found by collectFiles
+func processBatchFile(inputPath string, batch BatchOptions, options CleaningOptions, backup, verbose bool) (FileResult, error) {
+        if verbose {
+                fmt.Printf("Processing %s\n", inputPath)
+        }
+        options, err := batch.EditorConfig.options(inputPath, options)
+        if err != nil {
+                return FileResult{}, fmt.Errorf("%s: %w", inputPath, err)
+        }
+        if batch.ChangedOnly {
+                options.OnlyLines, err = changedLines(inputPath)
+                if err != nil {
+                        return FileResult{}, fmt.Errorf("%s: %w", inputPath, err)
+                }
+        }
+        var cacheKey string
+        if batch.Cache != nil {
+                var clean bool
+                if cacheKey, clean = batch.Cache.lookup(inputPath, options); clean {
+                        skipProgress(options, totalSize([]string{inputPath}))
+                        result := FileResult{InputPath: inputPath, Stats: &CleaningStats{RemovedCharDetails: make(map[rune]int)}, Cached: true}
+                        if batch.InPlace {
+                                result.OutputPath = inputPath
+                        }
+                        return result, nil
+                }
+        }
+        // The backup is made once cleaning has changed something, so an
+        // unchanged file gets none
+        var makeBackup func()
+        if backup {
+                makeBackup = func() { createBackup(inputPath, batch.Root, options, verbose) }
+        }
+
+        var outputPath string
+        var stats *CleaningStats
+        if batch.CheckOnly {
+                stats, err = cleanFile(inputPath, &discardSink{}, options, verbose)
+        } else if batch.InPlace {
+                outputPath = inputPath
+                stats, err = cleanFileInPlace(inputPath, options, verbose, makeBackup)
+        } else {
+                outputPath = batchOutputPath(inputPath, batch)
+                if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+                        return FileResult{}, fmt.Errorf("%s: could not create output directory: %w", inputPath, err)
+                }
+                sink := &fileSink{Path: outputPath, Mode: options.OutputMode, SourcePerm: inputPerm(inputPath)}
+                source, _ := os.Stat(inputPath)
+                stats, err = cleanToNewFile(inputPath, sink, options, verbose)
+                if err == nil && !stats.OutputSkipped {
+                        if makeBackup != nil {
+                                makeBackup()
+                        }
+                        preserveAttributes(outputPath, source, options, stats)
+                        if options.UndoPatch {
+                                if err = writeUndoPatch(outputPath, outputPath, inputPath, options.MaxMemory); err != nil {
+                                        err = fmt.Errorf("could not write undo patch: %w", err)
+                                }
+                        }
+                        if err == nil && options.Verify {
+                                err = verifyOutput(outputPath, options)
+                        }
+                }
+        }
+        if err != nil {
+                return FileResult{}, fmt.Errorf("%s: %w", inputPath, err)
+        }
+        if batch.Cache != nil {
+                batch.Cache.record(inputPath, cacheKey, options, stats)
+        }
+
+        return FileResult{
+                InputPath:  inputPath,
+                OutputPath: outputPath,
+                Stats:      stats,
+        }, nil
+}
+
+// batchOutputPath returns where a file of a batch run is written: under
+// batch.OutputDir at its path relative to the root, or next to it as
+// name_cleaned.ext
+func batchOutputPath(inputPath string, batch BatchOptions) string {
+        if batch.OutputDir == "" {
+                return defaultOutputPath(inputPath)
+        }
+        rel, err := filepath.Rel(batch.Root, inputPath)
+        if err != nil {
+                rel = filepath.Base(inputPath)
+        }
+        return filepath.Join(batch.OutputDir, rel)
+}
+
+func printBatchResults(w io.Writer, root string, results []FileResult, showDetails bool, targetOS string, checkOnly bool) {
+        total := &CleaningStats{
+                RemovedCharDetails: make(map[rune]int),
+        }
+        changed, failing, cached, unwritten := 0, 0, 0, 0
+        for _, r := range results {
+                if r.Cached {
+                        cached++
+                }
+                if r.Err == nil && r.Stats.OutputSkipped {
+                        unwritten++
+                }
+        }
+
+        fmt.Fprintln(w, "\n"+strings.Repeat("=", 70))
+        fmt.Fprintln(w, colorize("BATCH CLEANING REPORT", ansiBold))
+        fmt.Fprintln(w, strings.Repeat("=", 70))
+
+        fmt.Fprintf(w, "\n%s\n", colorize("Files:", ansiBold, ansiCyan))
+        fmt.Fprintf(w, "   Directory: %s\n", root)
+        fmt.Fprintf(w, "   Processed: %d file(s)\n", len(results))
+        if cached > 0 {
+                fmt.Fprintf(w, "   Skipped:   %d file(s) found clean by an earlier run (-cache)\n", cached)
+        }
+        if unwritten > 0 {
+                fmt.Fprintf(w, "   Unchanged: %d file(s) not written, identical to the input\n", unwritten)
+        }
+        fmt.Fprintln(w)
+
+        for _, r := range results {
+                s := r.Stats
+                if r.Err != nil {
+                        fmt.Fprintf(w, "   %-40s  %s\n", r.InputPath, colorize("FAILED", ansiBold, ansiRed))
+                        continue
+                }
+                if r.Cached {
+                        fmt.Fprintf(w, "   %-40s  clean (cached)\n", r.InputPath)
+                        continue
+                }
+                note := ""
+                if s.OutputSkipped {
+                        note = "  (not written)"
+                }
+                fmt.Fprintf(w, "   %-40s  %6d line(s)  %6d removed  %6d converted%s\n",
+                        r.InputPath, s.LinesProcessed, s.RemovedChars, s.LineEndingsConverted, note)
+
+                mergeStats(total, s)
+                if s.changed() {
+                        changed++
+                }
+                if s.failsCheck() {
+                        failing++
+                }
+        }
+
+        printStatistics(w, total, showDetails, targetOS)
+
+        var warned []FileResult
+        for _, r := range results {
+                if len(r.Stats.Warnings) > 0 {
+                        warned = append(warned, r)
+                }
+        }
+        if len(warned) > 0 {
+                fmt.Fprintf(w, "\n%s\n", colorize("Warnings:", ansiBold, ansiYellow))
+                for _, r := range warned {
+                        for _, warning := range r.Stats.Warnings {
+                                fmt.Fprintf(w, "   %s: %s\n", r.InputPath, formatWarning(warning))
+                        }
+                }
+        }
+
+        if showDetails {
+                header := false
+                for _, r := range results {
+                        if r.Err != nil {
+                                continue
+                        }
+                        for _, word := range r.Stats.WordsAffected {
+                                if !header {
+                                        fmt.Fprintf(w, "\n%s\n", colorize("Removals Inside Words:", ansiBold, ansiCyan))
+                                        header = true
+                                }
+                                fmt.Fprintf(w, "   %s:%d:%d: %s -> %s\n", r.InputPath, word.Line, word.Column, word.Before, word.After)
+                        }
+                }
+
+                // Checksums of the input and of the cleaned content, in
+                // that order; files skipped by -cache were not read
+                header = false
+                for _, r := range results {
+                        if r.Err != nil || r.Stats.InputHash == "" {
+                                continue
+                        }
+                        if !header {
+                                fmt.Fprintf(w, "\n%s\n", colorize("SHA-256 (input, cleaned):", ansiBold, ansiCyan))
+                                header = true
+                        }
+                        fmt.Fprintf(w, "   %s  %s  %s\n", r.Stats.InputHash, r.Stats.OutputHash, r.InputPath)
+                }
+        }
+
+        failed := failedCount(results)
+        if failed > 0 {
+                fmt.Fprintf(w, "\n%s\n", colorize("Failed Files:", ansiBold, ansiRed))
+                for _, r := range results {
+                        if r.Err != nil {
+                                fmt.Fprintf(w, "   %v\n", r.Err)
+                        }
+                }
+        }
+
+        fmt.Fprintln(w, "\n"+strings.Repeat("=", 70))
+        if failed > 0 {
+                fmt.Fprintln(w, colorize(fmt.Sprintf("%d of %d file(s) failed!", failed, len(results)), ansiBold, ansiRed))
+        }
+        if len(results) == 0 {
+                fmt.Fprintln(w, colorize("No matching files found!", ansiYellow))
+        } else if checkOnly && failing > changed {
+                fmt.Fprintln(w, colorize(fmt.Sprintf("Check failed - %d of %d file(s) need cleaning or have flagged words!", failing, len(results)), ansiBold, ansiRed))
+        } else if checkOnly && changed > 0 {
+                fmt.Fprintln(w, colorize(fmt.Sprintf("Check failed - %d of %d file(s) need cleaning!", changed, len(results)), ansiBold, ansiRed))
+        } else if checkOnly {
+                fmt.Fprintln(w, colorize(fmt.Sprintf("Check passed - all %d file(s) are clean!", len(results)-failed), ansiBold, ansiGreen))
+        } else {
+                fmt.Fprintln(w, colorize(fmt.Sprintf("%d of %d file(s) cleaned successfully!", changed, len(results)), ansiBold, ansiGreen))
+        }
+        fmt.Fprintln(w, strings.Repeat("=", 70))
+}
+
+// findDuplicates groups the input paths of files whose cleaned content has
+// the same hash. Only groups with more than one file are returned, ordered
+// by their first path.
+func findDuplicates(results []FileResult) [][]string {
+        byHash := make(map[string][]string)
+        for _, r := range results {
+                if r.Stats.OutputHash == "" {
+                        continue
+                }
+                byHash[r.Stats.OutputHash] = append(byHash[r.Stats.OutputHash], r.InputPath)
+        }
+
+        var groups [][]string
+        for _, paths := range byHash {
+                if len(paths) > 1 {
+                        sort.Strings(paths)
+                        groups = append(groups, paths)
+                }
+        }
+        sort.Slice(groups, func(i, j int) bool {
+                return groups[i][0] < groups[j][0]
+        })
+        return groups
+}
+
+func printDuplicates(w io.Writer, groups [][]string) {
+        fmt.Fprintf(w, "\n%s\n", colorize("Duplicate Content:", ansiBold, ansiCyan))
+        if len(groups) == 0 {
+                fmt.Fprintf(w, "   No files with identical cleaned content found\n")
+        }
+
+        for i, paths := range groups {
+                fmt.Fprintf(w, "   Group %d (%d files):\n", i+1, len(paths))
+                for _, path := range paths {
+                        fmt.Fprintf(w, "      %s\n", path)
+                }
+        }
+        fmt.Fprintln(w, strings.Repeat("=", 70))
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestIsCleanfileArtifact(t *testing.T) {
+        tests := []struct {
+                path string
+                want bool
+        }{
+                {"notes.txt", false},
+                {"dir/notes_cleaned.txt", true},
+                {"notes_cleaned", true},
+                {"notes.txt.bak", true},
+                {"notes.txt" + undoPatchSuffix, true},
+                {"export_cleaned.zip", true},
+                {"t/pkg_cleaned.tar.gz", true},
+                {"t/pkg_cleaned.TGZ", true},
+                {"t/pkg.tar.gz", false},
+                {"t/pkg_cleaned.tar.gz.d/readme.txt", false},
+        }
+        for _, tt := range tests {
+                if got := isCleanfileArtifact(tt.path); got != tt.want {
+                        t.Errorf("isCleanfileArtifact(%q) = %v, want %v", tt.path, got, tt.want)
+                }
+        }
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+        "bufio"
+        "encoding/json"
+        "fmt"
+        "io"
+        "os"
+        "strings"
+        "unicode/utf8"
+)
+
+// BidiFinding is a bidirectional control character found by scan-bidi
+type BidiFinding struct {
+        Path      string `json:"path"`
+        Line      int    `json:"line"`
+        Column    int    `json:"column"`
+        Codepoint string `json:"codepoint"`
+        Name      string `json:"name"`
+        // Unterminated is set for an embedding, override or isolate that is
+        // still open at the end of its line, which is what makes code display
+        // differently from how it is compiled
+        Unterminated bool   `json:"unterminated"`
+        Context      string `json:"context"`
+        char         rune
+}
+
+// bidiAbbreviations name the controls in the context shown with a finding
+var bidiAbbreviations = map[rune]string{
+        '\u202A': "LRE", '\u202B': "RLE", '\u202C': "PDF", '\u202D': "LRO", '\u202E': "RLO",
+        '\u2066': "LRI", '\u2067': "RLI", '\u2068': "FSI", '\u2069': "PDI",
+}
+
+// Context longer than this is shortened around the first control
+const maxBidiContext = 120
+
+// runScanBidi implements the scan-bidi subcommand
+func runScanBidi(args []string) int {
+        fs := newSubcommandFlags("scan-bidi")
+        recursive := fs.Bool("recursive", false, "Scan every file in the given directory trees")
+        include := fs.String("include", "", "Comma-separated glob patterns of files to scan in recursive mode")
+        exclude := fs.String("exclude", "", "Comma-separated glob patterns of files or directories to skip in recursive mode")
+        reportFormat := fs.String("report", "text", "Report format: text, json or sarif")
+        failOn := fs.String("fail-on", "any", "Exit with status 1 on: any (any control), unterminated (only unterminated sequences) or none")
+        colorMode := fs.String("color", "auto", "Colorize the report: auto, always, never (NO_COLOR disables auto)")
+        paths := parseInterspersed(fs, args)
+
+        if len(paths) == 0 {
+                exitWithUsage(fs, "at least one file or directory is required")
+        }
+        if *reportFormat != "text" && *reportFormat != "json" && *reportFormat != "sarif" {
+                exitWithUsage(fs, "invalid report format '%s' (valid options: text, json, sarif)", *reportFormat)
+        }
+        if *failOn != "any" && *failOn != "unterminated" && *failOn != "none" {
+                exitWithUsage(fs, "invalid -fail-on value '%s' (valid options: any, unterminated, none)", *failOn)
+        }
+        if err := setupColor(*colorMode); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        batch := BatchOptions{Include: splitPatterns(*include), Exclude: splitPatterns(*exclude)}
+        files, err := expandPaths(paths, *recursive, batch, "scan")
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        findings := []BidiFinding{}
+        for _, path := range files {
+                found, err := scanBidiFile(path)
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+                        return 1
+                }
+                findings = append(findings, found...)
+        }
+
+        switch *reportFormat {
+        case "json":
+                encoder := json.NewEncoder(os.Stdout)
+                encoder.SetIndent("", "  ")
+                encoder.SetEscapeHTML(false)
+                if err := encoder.Encode(findings); err != nil {
+                        fmt.Printf("Error: could not write JSON report: %v\n", err)
+                        return 1
+                }
+        case "sarif":
+                if err := writeSARIFReport(os.Stdout, bidiResults(findings)); err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        return 1
+                }
+        default:
+                printBidiFindings(os.Stdout, findings, len(files))
+        }
+
+        for _, f := range findings {
+                if *failOn == "any" || *failOn == "unterminated" && f.Unterminated {
+                        return 1
+                }
+        }
+        return 0
+}
+
+// scanBidiFile lists the bidirectional controls of a file. Files that do not
+// look like UTF-8 text are skipped.
+func scanBidiFile(path string) ([]BidiFinding, error) {
+        f, err := os.Open(path)
+        if err != nil {
+                return nil, err
+        }
+        defer f.Close()
+
+        reader := bufio.NewReaderSize(f, detectSampleSize)
+        prefix, err := reader.Peek(detectSampleSize)
+        if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+                return nil, err
+        }
+        encoding, _ := detectEncoding(prefix)
+        if !strings.HasPrefix(encoding, "UTF-8") && !decodable(encoding) {
+                return nil, nil
+        }
+        reader, _ = decodingReader(reader, encoding)
+
+        var findings []BidiFinding
+        lines := newLineReader(reader)
+        for lineNum := 1; ; lineNum++ {
+                line, _, err := lines.readLine()
+                if err == io.EOF {
+                        return findings, nil
+                }
+                if err != nil {
+                        return nil, err
+                }
+                if strings.IndexFunc(line, isBidiControl) >= 0 {
+                        findings = append(findings, scanBidiLine(path, line, lineNum)...)
+                }
+        }
+}
+
+// scanBidiLine finds the controls of one line and marks those left open at
+// its end: embeddings and overrides are closed by PDF, isolates by PDI, and
+// the end of a line closes everything, as in the Unicode bidirectional
+// algorithm
+func scanBidiLine(path, line string, lineNum int) []BidiFinding {
+        var findings []BidiFinding
+        var open []int // indexes into findings, innermost last
+        context := bidiContext(line)
+
+        column := 0
+        for _, r := range line {
+                column++
+                if !isBidiControl(r) {
+                        continue
+                }
+                findings = append(findings, BidiFinding{
+                        Path:      path,
+                        Line:      lineNum,
+                        Column:    column,
+                        Codepoint: fmt.Sprintf("U+%04X", r),
+                        Name:      describeChar(r),
+                        Context:   context,
+                        char:      r,
+                })
+
+                switch r {
+                case '\u202C': // PDF closes the innermost embedding or override
+                        if n := len(open); n > 0 && !isBidiIsolate(findings[open[n-1]].char) {
+                                open = open[:n-1]
+                        }
+                case '\u2069': // PDI closes the innermost isolate and anything opened inside it
+                        for n := len(open) - 1; n >= 0; n-- {
+                                if isBidiIsolate(findings[open[n]].char) {
+                                        open = open[:n]
+                                        break
+                                }
+                        }
+                default:
+                        open = append(open, len(findings)-1)
+                }
+        }
+
+        for _, i := range open {
+                findings[i].Unterminated = true
+        }
+        return findings
+}
+
+// isBidiIsolate reports whether r opens an isolate (LRI, RLI or FSI)
+func isBidiIsolate(r rune) bool {
+        return r >= '\u2066' && r <= '\u2068'
+}
+
+// bidiContext shows a line with its controls made visible as <RLO> etc.,
+// shortened to about maxBidiContext characters around the first control
+func bidiContext(line string) string {
+        var b strings.Builder
+        first := -1
+        for _, r := range line {
+                if name, ok := bidiAbbreviations[r]; ok {
+                        if first < 0 {
+                                first = b.Len()
+                        }
+                        b.WriteString("<" + name + ">")
+                        continue
+                }
+                b.WriteRune(r)
+        }
+
+        context := b.String()
+        if len(context) <= maxBidiContext {
+                return context
+        }
+        start := max(first-maxBidiContext/4, 0)
+        for start > 0 && !utf8.RuneStart(context[start]) {
+                start--
+        }
+        end := min(start+maxBidiContext, len(context))
+        for end < len(context) && !utf8.RuneStart(context[end]) {
+                end++
+        }
+        shortened := context[start:end]
+        if start > 0 {
+                shortened = "..." + shortened
+        }
+        if end < len(context) {
+                shortened += "..."
+        }
+        return shortened
+}
+
+// bidiResults converts findings to results for writeSARIFReport
+func bidiResults(findings []BidiFinding) []FileResult {
+        var results []FileResult
+        for _, f := range findings {
+                if len(results) == 0 || results[len(results)-1].InputPath != f.Path {
+                        results = append(results, FileResult{InputPath: f.Path, Stats: &CleaningStats{}})
+                }
+                stats := results[len(results)-1].Stats
+                stats.Locations = append(stats.Locations, CharLocation{Line: f.Line, Column: f.Column, Char: f.char})
+        }
+        return results
+}
+
+func printBidiFindings(w io.Writer, findings []BidiFinding, scanned int) {
+        files := make(map[string]bool)
+        unterminated := 0
+        for i, f := range findings {
+                files[f.Path] = true
+                status := ""
+                if f.Unterminated {
+                        unterminated++
+                        status = ", " + colorize("unterminated", ansiBold, ansiRed)
+                }
+                fmt.Fprintf(w, "%s:%d:%d: %s %s (%s)%s\n", f.Path, f.Line, f.Column, f.Codepoint, f.Name, bidiAbbreviations[f.char], status)
+
+                // The context follows the last finding of each line
+                if i+1 == len(findings) || findings[i+1].Path != f.Path || findings[i+1].Line != f.Line {
+                        fmt.Fprintf(w, "    | %s\n", f.Context)
+                }
+        }
+
+        if len(findings) == 0 {
+                fmt.Fprintln(w, colorize(fmt.Sprintf("No bidirectional control characters found in %d file(s)", scanned), ansiGreen))
+                return
+        }
+        fmt.Fprintf(w, "\nFound %d bidirectional control character(s) in %d of %d file(s), %d unterminated\n",
+                len(findings), len(files), scanned, unterminated)
+}
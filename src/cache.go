@@ -0,0 +1,162 @@
+package main
+
+import (
+        "crypto/sha256"
+        "encoding/hex"
+        "encoding/json"
+        "fmt"
+        "io"
+        "os"
+        "path/filepath"
+        "sync"
+        "time"
+)
+
+// cleanCache remembers which files were found clean, in the JSON file named
+// with -cache, so that later runs can skip them as long as neither they nor
+// the options have changed. A verdict is keyed by a hash of the content of
+// the file and of the options it was cleaned with, so a file that was
+// touched but not changed, or copied elsewhere, is still known to be clean.
+// Paths record the size and modification time of each file with its key,
+// so that a file whose size and time are unchanged is not even read.
+type cleanCache struct {
+        Paths map[string]cacheEntry `json:"paths"` // by absolute path
+        Clean map[string]bool       `json:"clean"` // keys of the contents found clean
+
+        mu sync.Mutex
+}
+
+type cacheEntry struct {
+        Size    int64     `json:"size"`
+        ModTime time.Time `json:"mod_time"`
+        Options string    `json:"options"` // hash of the options
+        Key     string    `json:"key"`
+}
+
+// loadCleanCache reads a cache; a missing file is an empty cache
+func loadCleanCache(path string) (*cleanCache, error) {
+        cache := &cleanCache{Paths: make(map[string]cacheEntry), Clean: make(map[string]bool)}
+        data, err := os.ReadFile(path)
+        if os.IsNotExist(err) {
+                return cache, nil
+        }
+        if err != nil {
+                return nil, fmt.Errorf("could not read cache: %w", err)
+        }
+        if err := json.Unmarshal(data, cache); err != nil {
+                return nil, fmt.Errorf("could not read cache %s: %w", path, err)
+        }
+        if cache.Paths == nil {
+                cache.Paths = make(map[string]cacheEntry)
+        }
+        if cache.Clean == nil {
+                cache.Clean = make(map[string]bool)
+        }
+        return cache, nil
+}
+
+// optionsHash identifies the options a file is cleaned with, and the version
+// of cleanfile, leaving out those that do not change what cleaning finds
+func optionsHash(options CleaningOptions) string {
+        options.Trace, options.Progress = nil, nil
+        options.MaxMemory = 0
+        options.TraceFrom, options.TraceTo, options.TraceOutput = 0, 0, nil
+        options.RecordLocations, options.RecordWords, options.Quarantine = false, false, false
+        options.ProvenanceText = ""
+        options.TempDir, options.OutputMode = "", 0
+        options.BackupDir, options.BackupName = "", ""
+        options.PreserveTimes, options.PreserveOwner = false, false
+        options.UndoPatch, options.Verify = false, false
+        sum := sha256.Sum256([]byte(version + "\n" + fmt.Sprintf("%#v", options)))
+        return hex.EncodeToString(sum[:])
+}
+
+// lookup reports whether path is known to be clean with options. It also
+// returns the key of the file, to record a verdict under once it has been
+// cleaned, or "" if the file could not be read.
+func (c *cleanCache) lookup(path string, options CleaningOptions) (string, bool) {
+        abs, err := filepath.Abs(path)
+        if err != nil {
+                return "", false
+        }
+        info, err := os.Stat(path)
+        if err != nil {
+                return "", false
+        }
+        hash := optionsHash(options)
+
+        c.mu.Lock()
+        entry, ok := c.Paths[abs]
+        if ok && entry.Options == hash && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) && c.Clean[entry.Key] {
+                c.mu.Unlock()
+                return entry.Key, true
+        }
+        c.mu.Unlock()
+
+        f, err := os.Open(path)
+        if err != nil {
+                return "", false
+        }
+        defer f.Close()
+        h := sha256.New()
+        io.WriteString(h, hash+"\n")
+        if _, err := io.Copy(h, f); err != nil {
+                return "", false
+        }
+        key := hex.EncodeToString(h.Sum(nil))
+
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        if !c.Clean[key] {
+                return key, false
+        }
+        c.Paths[abs] = cacheEntry{Size: info.Size(), ModTime: info.ModTime(), Options: hash, Key: key}
+        return key, true
+}
+
+// record notes the verdict of a file cleaned with options, whose content
+// had the given key. Only a file in which cleaning found nothing to do and
+// nothing to warn about is clean; a file cleaned in place is not recorded
+// until a later run finds it clean.
+func (c *cleanCache) record(path, key string, options CleaningOptions, stats *CleaningStats) {
+        abs, err := filepath.Abs(path)
+        if err != nil || key == "" {
+                return
+        }
+        clean := !stats.changed() && !stats.flagged() && len(stats.Warnings) == 0
+        info, err := os.Stat(path)
+
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        if !clean || err != nil {
+                delete(c.Paths, abs)
+                return
+        }
+        c.Clean[key] = true
+        c.Paths[abs] = cacheEntry{Size: info.Size(), ModTime: info.ModTime(), Options: optionsHash(options), Key: key}
+}
+
+// save writes the cache, without the paths that no longer exist and the
+// verdicts no path refers to, so that it does not grow without bound
+func (c *cleanCache) save(path string) error {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        clean := make(map[string]bool)
+        for abs, entry := range c.Paths {
+                if _, err := os.Stat(abs); err != nil {
+                        delete(c.Paths, abs)
+                        continue
+                }
+                clean[entry.Key] = true
+        }
+        c.Clean = clean
+
+        data, err := json.MarshalIndent(c, "", "  ")
+        if err != nil {
+                return fmt.Errorf("could not write cache: %w", err)
+        }
+        if err := replaceFile(path, append(data, '\n')); err != nil {
+                return fmt.Errorf("could not write cache: %w", err)
+        }
+        return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+        "regexp"
+        "strings"
+        "unicode/utf8"
+)
+
+var (
+        // chatPrefixPattern matches the timestamp and author that start a
+        // message in a chat export: "[31.12.20, 21:41:05] Alice: " (WhatsApp
+        // on iOS, Slack and Teams copies) or "12/31/20, 9:41 PM - Alice: "
+        // (WhatsApp on Android). System messages have no author.
+        chatPrefixPattern = regexp.MustCompile(`^(?:\[[^\]\n]*\d[^\]\n]*\]|\d{1,4}[./-]\d{1,2}[./-]\d{1,4},? \d{1,2}:\d{2}(?::\d{2})?(?:[ \x{00A0}\x{202F}]?[AaPp]\.?[Mm]\.?)? -)[ \t]*(?:[^:\n]{1,64}?: )?`)
+        // replyMarkerPattern matches the quoted-reply markers at the start
+        // of a message: >, &gt; (escaped by Slack), › or », possibly nested
+        replyMarkerPattern = regexp.MustCompile(`^[ \t]*(?:(?:&gt;|[>›»])[ \t]*)+`)
+)
+
+// leadingMarks returns the BOM and direction marks at the start of s, which
+// WhatsApp puts in front of some messages
+func leadingMarks(s string) string {
+        end := 0
+        for end < len(s) {
+                r, size := utf8.DecodeRuneInString(s[end:])
+                if r != '\uFEFF' && r != '\u200E' && r != '\u200F' {
+                        break
+                }
+                end += size
+        }
+        return s[:end]
+}
+
+// chatPrefix returns the timestamp and author prefix of a chat message, or
+// "" for a line that continues a message
+func chatPrefix(line string) string {
+        return chatPrefixPattern.FindString(line)
+}
+
+// normalizeReplyMarker writes the quoted-reply markers at the start of a
+// message as Markdown does, "> " for each level ("&gt;&gt; text" becomes
+// ">> text"). It returns the length of the markers in characters before
+// and after, both 0 if nothing changed.
+func normalizeReplyMarker(s string) (string, int, int) {
+        marker := replyMarkerPattern.FindString(s)
+        if marker == "" || strings.TrimSpace(s[len(marker):]) == "" {
+                // A marker without text is left alone
+                return s, 0, 0
+        }
+        depth := strings.Count(marker, "&gt;") + strings.Count(marker, ">") + strings.Count(marker, "›") + strings.Count(marker, "»")
+        normalized := strings.Repeat(">", depth) + " "
+        if normalized == marker {
+                return s, 0, 0
+        }
+        return normalized + s[len(marker):], utf8.RuneCountInString(marker), len(normalized)
+}
+
+// isReactionLine reports whether a line only lists reactions, such as
+// "👍 3 😂 1" or ":+1: 2 :tada:", as left behind when chat messages are
+// copied with their reactions
+func isReactionLine(s string) bool {
+        runes := []rune(s)
+        reactions := 0
+        for i := 0; i < len(runes); i++ {
+                r := runes[i]
+                switch {
+                case r == ' ' || r == '\t' || r == ',' || r == '(' || r == ')' || r >= '0' && r <= '9':
+                        continue
+                case (r == 'x' || r == '×') && i+1 < len(runes) && runes[i+1] >= '0' && runes[i+1] <= '9':
+                        continue
+                case r == ':':
+                        end := strings.IndexRune(string(runes[i+1:]), ':')
+                        if end < 0 {
+                                return false
+                        }
+                        name := string(runes[i+1:])[:end]
+                        if _, ok := shortcodeEmoji[name]; !ok {
+                                return false
+                        }
+                        i += utf8.RuneCountInString(name) + 1
+                        reactions++
+                        continue
+                }
+                n := emojiLength(runes, i)
+                if n == 0 {
+                        return false
+                }
+                i += n - 1
+                reactions++
+        }
+        return reactions > 0
+}
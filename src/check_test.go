@@ -0,0 +1,45 @@
+package main
+
+import (
+        "errors"
+        "os"
+        "os/exec"
+        "path/filepath"
+        "strings"
+        "testing"
+)
+
+// A file with only words flagged by -preset fails the check in both the
+// report and the exit code, for a single file and in recursive mode
+func TestCheckFlaggedOnly(t *testing.T) {
+        if args := os.Getenv("CLEANFILE_TEST_CHECK"); args != "" {
+                runClean("check", strings.Split(args, "\n"))
+                os.Exit(0)
+        }
+
+        dir := t.TempDir()
+        if err := os.WriteFile(filepath.Join(dir, "mixed.txt"), []byte("login to p\u0430ypal now\n"), 0644); err != nil {
+                t.Fatal(err)
+        }
+        tests := []struct {
+                args []string
+                want string
+        }{
+                {[]string{"-preset", "utr39", "mixed.txt"}, "Check failed - file has flagged words!"},
+                {[]string{"-preset", "utr39", "-recursive", "-no-progress", "."}, "Check failed - 1 of 1 file(s) need cleaning or have flagged words!"},
+        }
+        for _, tt := range tests {
+                cmd := exec.Command(os.Args[0], "-test.run=^TestCheckFlaggedOnly$")
+                cmd.Dir = dir
+                cmd.Env = append(os.Environ(), "CLEANFILE_TEST_CHECK="+strings.Join(tt.args, "\n"), "NO_COLOR=1")
+                out, err := cmd.Output()
+
+                var exitErr *exec.ExitError
+                if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+                        t.Errorf("check %v: exit error %v, want exit status 1", tt.args, err)
+                }
+                if !strings.Contains(string(out), tt.want) {
+                        t.Errorf("check %v: report lacks %q:\n%s", tt.args, tt.want, out)
+                }
+        }
+}
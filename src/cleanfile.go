@@ -1,466 +1,583 @@
 package main
 
 import (
-        "bufio"
-        "flag"
         "fmt"
         "io"
         "os"
         "path/filepath"
-        "regexp"
-        "runtime"
+        "runtime/debug"
+        "sort"
         "strings"
-        "unicode"
+        "time"
+        "unicode/utf8"
 )
 
-// CleaningOptions defines what types of characters to remove
-type CleaningOptions struct {
-        RemoveNonASCII      bool
-        RemoveControlChars  bool
-        RemoveZeroWidth     bool
-        RemoveBOM           bool
-        NormalizeWhitespace bool
-        PreserveNewlines    bool
-        TargetOS            string
-        StripFormat         string
-}
-
-// CleaningStats holds statistics about the cleaning process
-type CleaningStats struct {
-        TotalChars           int
-        RemovedChars         int
-        NonASCIIRemoved      int
-        ControlCharsRemoved  int
-        ZeroWidthRemoved     int
-        LinesProcessed       int
-        LinesWithIssues      int
-        LineEndingsConverted int
-        RemovedCharDetails   map[rune]int
-        MarkdownStripped     bool
-        HTMLStripped         bool
-        HTMLEntitiesDecoded  int
-        FormatDetected       string
-}
-
-// Common zero-width and invisible Unicode characters
-var zeroWidthChars = []rune{
-        '\u200B', '\u200C', '\u200D', '\u200E', '\u200F', '\uFEFF',
-        '\u202A', '\u202B', '\u202C', '\u202D', '\u202E', '\u2060',
-        '\u2061', '\u2062', '\u2063', '\u2064', '\u206A', '\u206B',
-        '\u206C', '\u206D', '\u206E', '\u206F',
-}
-
-// HTML entity mappings
-var htmlEntities = map[string]string{
-        "&amp;":    "&",
-        "&lt;":     "<",
-        "&gt;":     ">",
-        "&quot;":   "\"",
-        "&apos;":   "'",
-        "&nbsp;":   " ",
-        "&copy;":   "\u00A9",
-        "&reg;":    "\u00AE",
-        "&trade;":  "\u2122",
-        "&euro;":   "\u20AC",
-        "&pound;":  "\u00A3",
-        "&yen;":    "\u00A5",
-        "&cent;":   "\u00A2",
-        "&sect;":   "\u00A7",
-        "&para;":   "\u00B6",
-        "&middot;": "\u00B7",
-        "&bull;":   "\u2022",
-        "&hellip;": "\u2026",
-        "&ndash;":  "\u2013",
-        "&mdash;":  "\u2014",
-        "&lsquo;":  "\u2018",
-        "&rsquo;":  "\u2019",
-        "&ldquo;":  "\u201C",
-        "&rdquo;":  "\u201D",
-        "&times;":  "\u00D7",
-        "&divide;": "\u00F7",
-        "&deg;":    "\u00B0",
-        "&plusmn;": "\u00B1",
-        "&frac14;": "\u00BC",
-        "&frac12;": "\u00BD",
-        "&frac34;": "\u00BE",
-}
+func main() {
+        if len(os.Args) > 1 {
+                switch os.Args[1] {
+                case "clean", "check", "git-hook":
+                        runClean(os.Args[1], os.Args[2:])
+                        return
+                case "detect":
+                        os.Exit(runDetect(os.Args[2:]))
+                case "patch":
+                        os.Exit(runPatch(os.Args[2:]))
+                case "scan-bidi":
+                        os.Exit(runScanBidi(os.Args[2:]))
+                case "audit":
+                        os.Exit(runAudit(os.Args[2:]))
+                case "confusables":
+                        os.Exit(runConfusables(os.Args[2:]))
+                case "equal":
+                        os.Exit(runEqual(os.Args[2:]))
+                case "daemon":
+                        os.Exit(runDaemon(os.Args[2:]))
+                case "serve":
+                        os.Exit(runServe(os.Args[2:]))
+                case "undo":
+                        os.Exit(runUndo(os.Args[2:]))
+                case "trends":
+                        os.Exit(runTrends(os.Args[2:]))
+                case "report":
+                        os.Exit(runReport(os.Args[2:]))
+                case "config":
+                        os.Exit(runConfig(os.Args[2:]))
+                case "help":
+                        printUsage(os.Stdout)
+                        return
+                }
+        }
 
-// Character descriptions for better output
-var charDescriptions = map[rune]string{
-        '\u200B': "Zero Width Space",
-        '\u200C': "Zero Width Non-Joiner",
-        '\u200D': "Zero Width Joiner",
-        '\u200E': "Left-to-Right Mark",
-        '\u200F': "Right-to-Left Mark",
-        '\uFEFF': "BOM/Zero Width No-Break Space",
-        '\u202A': "Left-to-Right Embedding",
-        '\u202B': "Right-to-Left Embedding",
-        '\u202C': "Pop Directional Formatting",
-        '\u202D': "Left-to-Right Override",
-        '\u202E': "Right-to-Left Override",
-        '\u2060': "Word Joiner",
-        '\u0000': "NULL character",
-        '\u0001': "Start of Heading",
-        '\u0002': "Start of Text",
-        '\u0003': "End of Text",
-        '\u0004': "End of Transmission",
-        '\u0005': "Enquiry",
-        '\u0006': "Acknowledge",
-        '\u0007': "Bell",
-        '\u0008': "Backspace",
-        '\u000B': "Vertical Tab",
-        '\u000C': "Form Feed",
-        '\u000E': "Shift Out",
-        '\u000F': "Shift In",
-        '\r':     "Carriage Return (CR)",
-        '\n':     "Line Feed (LF)",
+        // Without a subcommand, the flat flag set of earlier versions is
+        // accepted, with -check selecting check mode
+        runClean("", os.Args[1:])
 }
 
-func main() {
-        inputFile := flag.String("input", "", "Input file path (required)")
-        outputFile := flag.String("output", "", "Output file path (defaults to input_cleaned.ext)")
-        removeNonASCII := flag.Bool("ascii", true, "Remove non-ASCII characters")
-        removeControl := flag.Bool("control", true, "Remove control characters (except newlines/tabs)")
-        removeZeroWidth := flag.Bool("zerowidth", true, "Remove zero-width characters")
-        removeBOM := flag.Bool("bom", true, "Remove Byte Order Mark (BOM)")
-        normalizeWS := flag.Bool("normalize", false, "Normalize whitespace")
-        preserveNL := flag.Bool("preserve-newlines", true, "Preserve newlines when normalizing")
-        backup := flag.Bool("backup", true, "Create backup of original file")
-        verbose := flag.Bool("verbose", false, "Verbose output")
-        showDetails := flag.Bool("details", false, "Show detailed list of removed characters")
-        targetOS := flag.String("os", "", "Target OS for line endings (windows, unix, mac, auto). Default: auto")
-        stripFormat := flag.String("strip", "", "Strip formatting: 'markdown' or 'html'")
-
-        flag.Parse()
-
-        if *inputFile == "" {
-                fmt.Println("Error: Input file is required")
-                fmt.Println("\nUsage:")
-                flag.PrintDefaults()
-                os.Exit(1)
+// runClean implements the clean, check and git-hook subcommands, and the
+// legacy invocation without a subcommand (mode ""). Each mode only accepts
+// the flags that make sense for it; the others keep their defaults.
+func runClean(mode string, args []string) {
+        start := time.Now()
+        gitHook := mode == "git-hook"
+
+        f := newCleanFlags(mode)
+        fs, unused := f.fs, f.unused
+
+        positional := parseInterspersed(fs, args)
+        if len(positional) > 0 {
+                if gitHook || len(positional) > 1 || *f.inputFile != "" {
+                        fmt.Printf("Error: Unexpected argument '%s'\n", positional[len(positional)-1])
+                        os.Exit(1)
+                }
+                fs.Set("input", positional[0])
         }
-
-        if _, err := os.Stat(*inputFile); os.IsNotExist(err) {
-                fmt.Printf("Error: Input file '%s' does not exist\n", *inputFile)
-                os.Exit(1)
+        if mode == "check" {
+                *f.check = true
         }
+        explicit := explicitFlags(fs)
 
-        *stripFormat = strings.ToLower(strings.TrimSpace(*stripFormat))
-        if *stripFormat != "" && *stripFormat != "markdown" && *stripFormat != "html" {
-                fmt.Printf("Error: Invalid strip format '%s'. Valid options: markdown, html\n", *stripFormat)
+        cfg, err := loadConfig(configPaths(*f.configFile))
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
                 os.Exit(1)
         }
-
-        if *outputFile == "" {
-                ext := filepath.Ext(*inputFile)
-                base := strings.TrimSuffix(*inputFile, ext)
-                if ext == "" {
-                        *outputFile = base + "_cleaned"
-                } else {
-                        *outputFile = base + "_cleaned" + ext
-                }
+        if err := applyConfig(fs, unused, cfg, *f.profile); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                os.Exit(1)
         }
 
-        absInput, err := filepath.Abs(*inputFile)
-        if err != nil {
-                fmt.Printf("Error: Could not resolve input file path: %v\n", err)
-                os.Exit(1)
+        // Options set on the command line or in a config file take precedence
+        // over .editorconfig, which takes precedence over -mode
+        var editorConfig *editorConfigResolver
+        if *f.useEditorConfig {
+                editorConfig = newEditorConfigResolver(explicitFlags(fs))
         }
-        absOutput, err := filepath.Abs(*outputFile)
-        if err != nil {
-                fmt.Printf("Error: Could not resolve output file path: %v\n", err)
+        if err := applyPreset(fs, *f.preset); err != nil {
+                fmt.Printf("Error: %v\n", err)
                 os.Exit(1)
         }
-        if absInput == absOutput {
-                fmt.Println("Error: Output file cannot be the same as input file")
+        if err := applyCleaningMode(fs, *f.cleaningMode); err != nil {
+                fmt.Printf("Error: %v\n", err)
                 os.Exit(1)
         }
 
-        normalizedOS := normalizeTargetOS(*targetOS)
-        if normalizedOS == "" {
-                fmt.Printf("Error: Invalid target OS '%s'. Valid options: windows, unix, mac, auto\n", *targetOS)
+        if err := setupColor(*f.colorMode); err != nil {
+                fmt.Printf("Error: %v\n", err)
                 os.Exit(1)
         }
 
-        options := CleaningOptions{
-                RemoveNonASCII:      *removeNonASCII,
-                RemoveControlChars:  *removeControl,
-                RemoveZeroWidth:     *removeZeroWidth,
-                RemoveBOM:           *removeBOM,
-                NormalizeWhitespace: *normalizeWS,
-                PreserveNewlines:    *preserveNL,
-                TargetOS:            normalizedOS,
-                StripFormat:         *stripFormat,
-        }
+        if gitHook {
+                if explicit["input"] || explicit["output"] || explicit["recursive"] || explicit["in-place"] {
+                        fmt.Println("Error: -input, -output, -recursive and -in-place cannot be used with git-hook")
+                        os.Exit(1)
+                }
+                // The staged content is the backup
+                *f.backup = false
+                if *f.check {
+                        *f.showDetails = true
+                }
+        } else {
+                if *f.inputFile == "" {
+                        fmt.Println("Error: Input file is required")
+                        fmt.Println()
+                        fs.Usage()
+                        os.Exit(1)
+                }
+
+                var inputInfo os.FileInfo
+                if *f.inputFile == "-" {
+                        if *f.recursive || *f.inPlace || *f.changedOnly || *f.undoPatch {
+                                fmt.Println("Error: -recursive, -in-place, -changed-only and -undo-patch cannot be used when reading standard input")
+                                os.Exit(1)
+                        }
+                        *f.backup = false
+                        inputInfo, err = os.Stdin.Stat()
+                } else {
+                        inputInfo, err = os.Stat(*f.inputFile)
+                }
+                if os.IsNotExist(err) {
+                        fmt.Printf("Error: Input file '%s' does not exist\n", *f.inputFile)
+                        os.Exit(1)
+                }
+                if err != nil {
+                        fmt.Printf("Error: Could not access input file: %v\n", err)
+                        os.Exit(1)
+                }
 
-        if *backup {
-                backupPath := *inputFile + ".bak"
-                if err := copyFile(*inputFile, backupPath); err != nil {
-                        fmt.Printf("Warning: Could not create backup: %v\n", err)
-                } else if *verbose {
-                        fmt.Printf("Backup created: %s\n", backupPath)
+                if *f.check {
+                        *f.backup = false
+                        *f.showDetails = true
+                }
+
+                if *f.recursive {
+                        if *f.cache != "" && !*f.check && !*f.inPlace {
+                                fmt.Println("Error: -cache requires check mode or -in-place, where a clean file is left as it is")
+                                os.Exit(1)
+                        }
+                        if !inputInfo.IsDir() {
+                                fmt.Printf("Error: Input '%s' must be a directory when -recursive is set\n", *f.inputFile)
+                                os.Exit(1)
+                        }
+                        if *f.outputDir != "" {
+                                absInput, errInput := filepath.Abs(*f.inputFile)
+                                absOutput, errOutput := filepath.Abs(*f.outputDir)
+                                if errInput != nil || errOutput != nil || absInput == absOutput {
+                                        fmt.Println("Error: -output-dir cannot be the input directory")
+                                        os.Exit(1)
+                                }
+                        }
+                } else {
+                        if inputInfo.IsDir() {
+                                fmt.Printf("Error: Input '%s' is a directory (use -recursive to clean a directory tree)\n", *f.inputFile)
+                                os.Exit(1)
+                        }
+                        if explicit["include"] || explicit["exclude"] || explicit["duplicates"] || explicit["jobs"] || explicit["fail-fast"] || explicit["make-writable"] || explicit["time-budget"] || explicit["scan-state"] || explicit["cache"] {
+                                fmt.Println("Error: -include, -exclude, -jobs, -duplicates, -fail-fast, -make-writable, -time-budget, -scan-state and -cache require -recursive")
+                                os.Exit(1)
+                        }
                 }
         }
 
-        stats, err := cleanFile(*inputFile, *outputFile, options, *verbose)
+        options, err := f.options()
         if err != nil {
                 fmt.Printf("Error: %v\n", err)
                 os.Exit(1)
         }
-
-        printResults(*inputFile, *outputFile, stats, *showDetails, normalizedOS)
-}
-
-func normalizeTargetOS(targetOS string) string {
-        targetOS = strings.ToLower(strings.TrimSpace(targetOS))
-
-        if targetOS == "" || targetOS == "auto" {
-                switch runtime.GOOS {
-                case "windows":
-                        return "windows"
-                case "darwin":
-                        return "unix"
-                default:
-                        return "unix"
-                }
+        // Standard error, since the cleaned text may go to standard output
+        for _, warning := range f.warnings() {
+                fmt.Fprintf(os.Stderr, "%s %s\n", colorize("Warning:", ansiYellow), warning)
+        }
+        if *f.reportFile != "" && strings.ToLower(*f.colorMode) != "always" {
+                useColor = false
+        }
+        // The garbage collector works harder as the heap nears the limit,
+        // instead of letting it grow to twice the live data
+        if options.MaxMemory > 0 {
+                debug.SetMemoryLimit(options.MaxMemory + memoryReserve)
         }
+        normalizedOS := options.TargetOS
 
-        switch targetOS {
-        case "windows", "win", "dos":
-                return "windows"
-        case "unix", "linux":
-                return "unix"
-        case "mac", "macos", "darwin":
-                return "unix"
-        case "mac9", "macos9", "classic":
-                return "mac9"
-        default:
-                return ""
+        stream, err := openFindingStream(*f.stream)
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                os.Exit(1)
         }
-}
 
-func getLineEnding(targetOS string) string {
-        switch targetOS {
-        case "windows":
-                return "\r\n"
-        case "unix":
-                return "\n"
-        case "mac9":
-                return "\r"
-        default:
-                return "\n"
+        // Created before anything is cleaned, so that nothing is removed
+        // without a record
+        var quarantine *os.File
+        if *f.quarantine != "" {
+                quarantine, err = os.Create(*f.quarantine)
+                if err != nil {
+                        fmt.Printf("Error: Could not create quarantine file: %v\n", err)
+                        os.Exit(1)
+                }
         }
-}
 
-func detectFileFormat(content string) string {
-        if len(content) == 0 {
-                return "unknown"
+        reportOut := os.Stdout
+        var results []FileResult
+        batch := BatchOptions{
+                Include:      splitPatterns(*f.include),
+                Exclude:      splitPatterns(*f.exclude),
+                InPlace:      *f.inPlace,
+                CheckOnly:    *f.check,
+                Jobs:         *f.jobs,
+                FailFast:     *f.failFast,
+                MakeWritable: *f.makeWritable,
+                ChangedOnly:  *f.changedOnly,
+                EditorConfig: editorConfig,
+                Stream:       stream,
+                OutputDir:    *f.outputDir,
+                Force:        *f.force,
+                ScanState:    *f.scanState,
+                CacheFile:    *f.cache,
+                NoProgress:   *f.noProgress || *f.verbose,
+        }
+        if *f.timeBudget > 0 {
+                batch.Deadline = start.Add(*f.timeBudget)
         }
+        if gitHook {
+                results, err = runGitHook(batch, options, *f.check, *f.verbose)
+                if err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
+                }
+        } else if *f.recursive {
+                results, err = runBatch(*f.inputFile, batch, options, *f.backup, *f.verbose)
+                if err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
+                }
+        } else {
+                var sink OutputSink
+                switch {
+                case *f.check:
+                        sink = &discardSink{}
+                case *f.inPlace:
+                        sink = &fileSink{Path: *f.inputFile}
+                case *f.outputFile == "" && *f.inputFile == "-":
+                        sink = &stdoutSink{}
+                case *f.outputFile == "":
+                        sink = &fileSink{Path: defaultOutputPath(*f.inputFile)}
+                default:
+                        sink, err = newOutputSink(*f.outputFile)
+                        if err != nil {
+                                fmt.Printf("Error: %v\n", err)
+                                os.Exit(1)
+                        }
+                }
+                if file, ok := sink.(*fileSink); ok {
+                        file.Mode = options.OutputMode
+                        file.SourcePerm = inputPerm(*f.inputFile)
+                } else if options.UndoPatch || options.Verify {
+                        fmt.Println("Error: -undo-patch and -verify need the output to be a local file")
+                        os.Exit(1)
+                }
+                if _, ok := sink.(*s3Sink); ok && (options.MaxMemory > 0 || options.MaxSize > 0 && options.Oversize == "chunk") {
+                        fmt.Println("Error: s3:// output is held in memory until it is uploaded and cannot be used with -max-memory or -oversize chunk")
+                        os.Exit(1)
+                }
 
-        lines := strings.Split(content, "\n")
-        markdownScore := 0
-        htmlScore := 0
-        totalNonEmptyLines := 0
+                if file, ok := sink.(*fileSink); ok && !*f.inPlace && *f.inputFile != "-" {
+                        absInput, err := filepath.Abs(*f.inputFile)
+                        if err != nil {
+                                fmt.Printf("Error: Could not resolve input file path: %v\n", err)
+                                os.Exit(1)
+                        }
+                        absOutput, err := filepath.Abs(file.Path)
+                        if err != nil {
+                                fmt.Printf("Error: Could not resolve output file path: %v\n", err)
+                                os.Exit(1)
+                        }
+                        if absInput == absOutput {
+                                fmt.Println("Error: Output file cannot be the same as input file")
+                                os.Exit(1)
+                        }
+                }
+                if _, ok := sink.(*stdoutSink); ok {
+                        reportOut = os.Stderr
+                }
 
-        htmlTagPattern := regexp.MustCompile(`<[a-zA-Z][^>]*>`)
-        doctypePattern := regexp.MustCompile(`(?i)<!DOCTYPE\s+html`)
-        htmlEntityPattern := regexp.MustCompile(`&[a-zA-Z]+;|&#\d+;|&#x[0-9a-fA-F]+;`)
-        markdownHeaderPattern := regexp.MustCompile(`^#{1,6}\s+.+`)
-        markdownListPattern := regexp.MustCompile(`^[\s]*[-*+]\s+.+`)
-        markdownOrderedListPattern := regexp.MustCompile(`^[\s]*\d+\.\s+.+`)
-        markdownCodePattern := regexp.MustCompile("^```")
-        markdownLinkPattern := regexp.MustCompile(`\[.+?\]\(.+?\)`)
-        markdownBoldPattern := regexp.MustCompile(`\*\*.+?\*\*|__.+?__`)
-        markdownItalicPattern := regexp.MustCompile(`\*.+?\*|_.+?_`)
-        inlineCodePattern := regexp.MustCompile("`[^`]+`")
+                if *f.inputFile != "-" {
+                        options, err = editorConfig.options(*f.inputFile, options)
+                        if err != nil {
+                                fmt.Printf("Error: %v\n", err)
+                                os.Exit(1)
+                        }
+                }
+                normalizedOS = options.TargetOS
+                if *f.changedOnly {
+                        options.OnlyLines, err = changedLines(*f.inputFile)
+                        if err != nil {
+                                fmt.Printf("Error: %v\n", err)
+                                os.Exit(1)
+                        }
+                }
 
-        inCodeBlock := false
+                if !*f.force {
+                        var err error
+                        if file, ok := sink.(*fileSink); ok && !*f.inPlace {
+                                err = refuseOverwrite(file.Path, "output file")
+                        }
+                        if err == nil && *f.backup && options.BackupName == "plain" {
+                                err = refuseOverwrite(backupPath(*f.inputFile, "", options), "backup")
+                        }
+                        if file, ok := sink.(*fileSink); ok && err == nil && options.UndoPatch {
+                                err = refuseOverwrite(file.Path+undoPatchSuffix, "undo patch")
+                        }
+                        if err != nil {
+                                fmt.Printf("Error: %v\n", err)
+                                os.Exit(1)
+                        }
+                }
+                // The backup is made once cleaning has changed something,
+                // so an unchanged file gets none
+                var backup func()
+                if *f.backup {
+                        backup = func() { createBackup(*f.inputFile, "", options, *f.verbose) }
+                }
 
-        for _, line := range lines {
-                trimmed := strings.TrimSpace(line)
-                if trimmed == "" {
-                        continue
+                inputName := *f.inputFile
+                if inputName == "-" {
+                        inputName = "(stdin)"
+                }
+                stream.start(1, *f.check)
+                var progress *progressMeter
+                if !batch.NoProgress {
+                        progress = startProgress(1, totalSize([]string{*f.inputFile}))
+                        options.Progress = progress
                 }
+                var stats *CleaningStats
+                if *f.inPlace {
+                        stats, err = cleanFileInPlace(*f.inputFile, options, *f.verbose, backup)
+                } else {
+                        source, _ := os.Stat(*f.inputFile)
+                        file, isFile := sink.(*fileSink)
+                        if isFile && *f.inputFile != "-" && !*f.check {
+                                stats, err = cleanToNewFile(*f.inputFile, file, options, *f.verbose)
+                        } else {
+                                stats, err = cleanFile(*f.inputFile, sink, options, *f.verbose)
+                        }
+                        if backup != nil && err == nil && !stats.OutputSkipped {
+                                backup()
+                        }
+                        if isFile && err == nil && !stats.OutputSkipped {
+                                preserveAttributes(file.Path, source, options, stats)
+                                if options.UndoPatch {
+                                        if err = writeUndoPatch(file.Path, file.Path, *f.inputFile, options.MaxMemory); err != nil {
+                                                err = fmt.Errorf("could not write undo patch: %w", err)
+                                        }
+                                }
+                                if err == nil && options.Verify {
+                                        err = verifyOutput(file.Path, options)
+                                }
+                        }
+                }
+                progress.finish()
+                if err != nil {
+                        stream.file(FileResult{InputPath: inputName, Err: err})
+                        stream.Close()
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
+                }
+                results = []FileResult{{InputPath: inputName, OutputPath: sink.String(), Stats: stats}}
+                stream.file(results[0])
+        }
 
-                totalNonEmptyLines++
+        stream.end(results)
+        if err := stream.Close(); err != nil {
+                fmt.Fprintf(os.Stderr, "%s Could not write stream: %v\n", colorize("Warning:", ansiYellow), err)
+        }
+
+        var groups [][]string
+        if *f.duplicates {
+                groups = findDuplicates(results)
+        }
 
-                if markdownCodePattern.MatchString(trimmed) {
-                        inCodeBlock = !inCodeBlock
+        if *f.reportFile != "" {
+                out, err := os.Create(*f.reportFile)
+                if err != nil {
+                        fmt.Printf("Error: Could not create report file: %v\n", err)
+                        os.Exit(1)
                 }
+                defer out.Close()
+                reportOut = out
+        }
 
-                if doctypePattern.MatchString(trimmed) {
-                        htmlScore += 15
+        switch {
+        case f.template != nil:
+                if err := writeTemplateReport(reportOut, f.template, results, normalizedOS, *f.check, groups); err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
                 }
-                if htmlTagPattern.MatchString(trimmed) {
-                        htmlScore += 3
+        case *f.reportFormat == "json":
+                if err := writeJSONReport(reportOut, results, normalizedOS, *f.check, groups); err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
                 }
-                if htmlEntityPattern.MatchString(trimmed) {
-                        htmlScore += 2
+        case *f.reportFormat == "csv":
+                if err := writeCSVReport(reportOut, results); err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
                 }
-
-                if markdownHeaderPattern.MatchString(trimmed) {
-                        markdownScore += 4
+        case *f.reportFormat == "sarif":
+                if err := writeSARIFReport(reportOut, results); err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
                 }
-                if markdownListPattern.MatchString(trimmed) {
-                        markdownScore += 3
+        case *f.reportFormat == "lint":
+                if err := writeLintReport(reportOut, results); err != nil {
+                        fmt.Printf("Error: could not write lint report: %v\n", err)
+                        os.Exit(1)
                 }
-                if markdownOrderedListPattern.MatchString(trimmed) {
-                        markdownScore += 3
+        case *f.reportFormat == "rdjson":
+                if err := writeRDJSONReport(reportOut, results); err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
                 }
-                if markdownCodePattern.MatchString(trimmed) {
-                        markdownScore += 4
+        case *f.reportFormat == "rdjsonl":
+                if err := writeRDJSONLReport(reportOut, results); err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
                 }
-                if !inCodeBlock {
-                        if markdownLinkPattern.MatchString(trimmed) {
-                                markdownScore += 3
-                        }
-                        if markdownBoldPattern.MatchString(trimmed) {
-                                markdownScore += 2
+        default:
+                if gitHook {
+                        printBatchResults(reportOut, "(staged files)", results, *f.showDetails, normalizedOS, *f.check)
+                } else if *f.recursive {
+                        printBatchResults(reportOut, *f.inputFile, results, *f.showDetails, normalizedOS, *f.check)
+                        if *f.duplicates {
+                                printDuplicates(reportOut, groups)
                         }
-                        if markdownItalicPattern.MatchString(trimmed) {
-                                markdownScore += 1
+                } else {
+                        output := results[0].OutputPath
+                        if *f.inPlace {
+                                output += " (in place)"
                         }
-                        if inlineCodePattern.MatchString(trimmed) {
-                                markdownScore += 1
+                        if results[0].Stats.OutputSkipped {
+                                output += " (not written, identical to the input)"
                         }
+                        printResults(reportOut, results[0].InputPath, output, results[0].Stats, *f.showDetails, normalizedOS)
                 }
         }
 
-        if totalNonEmptyLines == 0 {
-                return "unknown"
+        recordHistory(*f.historyFile, results)
+        if quarantine != nil {
+                err := writeQuarantine(quarantine, results)
+                if closeErr := quarantine.Close(); err == nil && closeErr != nil {
+                        err = fmt.Errorf("could not write quarantine file: %w", closeErr)
+                }
+                if err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        os.Exit(1)
+                }
         }
 
-        threshold := totalNonEmptyLines / 7
-
-        if htmlScore > markdownScore && htmlScore >= threshold {
-                return "html"
-        } else if markdownScore > htmlScore && markdownScore >= threshold {
-                return "markdown"
+        if failedCount(results) > 0 {
+                os.Exit(1)
         }
 
-        return "unknown"
+        if *f.check {
+                for _, r := range results {
+                        if r.Stats.failsCheck() {
+                                os.Exit(1)
+                        }
+                }
+        }
 }
 
-func stripMarkdown(text string) string {
-        codeBlockPattern := regexp.MustCompile("(?s)```[a-zA-Z]*\n(.*?)```")
-        text = codeBlockPattern.ReplaceAllString(text, "$1")
-
-        inlineCodePattern := regexp.MustCompile("`([^`]+)`")
-        text = inlineCodePattern.ReplaceAllString(text, "$1")
-
-        headerPattern := regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
-        text = headerPattern.ReplaceAllString(text, "$1")
-
-        boldPattern := regexp.MustCompile(`(\*\*|__)(.*?)\1`)
-        text = boldPattern.ReplaceAllString(text, "$2")
-
-        italicPattern := regexp.MustCompile(`(\*|_)(.*?)\1`)
-        text = italicPattern.ReplaceAllString(text, "$2")
-
-        strikePattern := regexp.MustCompile(`~~(.*?)~~`)
-        text = strikePattern.ReplaceAllString(text, "$1")
-
-        imagePattern := regexp.MustCompile(`!\[([^\]]*)\]\([^\)]+\)`)
-        text = imagePattern.ReplaceAllString(text, "$1")
-
-        linkPattern := regexp.MustCompile(`\[([^\]]+)\]\([^\)]+\)`)
-        text = linkPattern.ReplaceAllString(text, "$1")
-
-        refLinkPattern := regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
-        text = refLinkPattern.ReplaceAllString(text, "$1")
-
-        linkDefPattern := regexp.MustCompile(`(?m)^\[.+?\]:\s+.+$`)
-        text = linkDefPattern.ReplaceAllString(text, "")
-
-        hrPattern := regexp.MustCompile(`(?m)^[\s]*[\-\*_]{3,}[\s]*$`)
-        text = hrPattern.ReplaceAllString(text, "")
-
-        blockquotePattern := regexp.MustCompile(`(?m)^>\s*(.*)$`)
-        text = blockquotePattern.ReplaceAllString(text, "$1")
-
-        listPattern := regexp.MustCompile(`(?m)^[\s]*[-*+]\s+(.+)$`)
-        text = listPattern.ReplaceAllString(text, "$1")
-
-        orderedListPattern := regexp.MustCompile(`(?m)^[\s]*\d+\.\s+(.+)$`)
-        text = orderedListPattern.ReplaceAllString(text, "$1")
-
-        taskListPattern := regexp.MustCompile(`(?m)^[\s]*-\s*\[[xX\s]\]\s+(.+)$`)
-        text = taskListPattern.ReplaceAllString(text, "$1")
-
-        text = strings.ReplaceAll(text, "|", " ")
-
-        htmlCommentPattern := regexp.MustCompile(`<!--.*?-->`)
-        text = htmlCommentPattern.ReplaceAllString(text, "")
-
-        multipleNewlinesPattern := regexp.MustCompile(`\n{3,}`)
-        text = multipleNewlinesPattern.ReplaceAllString(text, "\n\n")
-
-        return text
+// recordHistory appends the run to the history store when -history is set
+func recordHistory(storePath string, results []FileResult) {
+        if storePath == "" {
+                return
+        }
+        if err := appendHistory(storePath, results, time.Now()); err != nil {
+                fmt.Printf("%s Could not record history: %v\n", colorize("Warning:", ansiYellow), err)
+        }
 }
 
-func stripHTML(text string) (string, int) {
-        entitiesDecoded := 0
-
-        commentPattern := regexp.MustCompile(`<!--[\s\S]*?-->`)
-        text = commentPattern.ReplaceAllString(text, "")
-
-        scriptPattern := regexp.MustCompile(`(?is)<script[^>]*>[\s\S]*?</script>`)
-        text = scriptPattern.ReplaceAllString(text, "")
-        stylePattern := regexp.MustCompile(`(?is)<style[^>]*>[\s\S]*?</style>`)
-        text = stylePattern.ReplaceAllString(text, "")
-
-        tagPattern := regexp.MustCompile(`<[^>]+>`)
-        text = tagPattern.ReplaceAllString(text, "")
+// defaultOutputPath derives the output path used when -output is not given
+func defaultOutputPath(inputPath string) string {
+        ext := outputExt(inputPath)
+        base := strings.TrimSuffix(inputPath, ext)
+        if ext == "" {
+                return base + "_cleaned"
+        }
+        return base + "_cleaned" + ext
+}
 
-        for entity, char := range htmlEntities {
-                if strings.Contains(text, entity) {
-                        count := strings.Count(text, entity)
-                        entitiesDecoded += count
-                        text = strings.ReplaceAll(text, entity, char)
-                }
+// outputExt returns the extension that _cleaned goes in front of: that of
+// path, or .tar.gz as a whole for a gzipped TAR archive
+func outputExt(path string) string {
+        if archiveFormat(path) == "tar.gz" && strings.HasSuffix(strings.ToLower(path), ".tar.gz") {
+                return path[len(path)-len(".tar.gz"):]
         }
+        return filepath.Ext(path)
+}
 
-        numericEntityPattern := regexp.MustCompile(`&#(\d+);`)
-        text = numericEntityPattern.ReplaceAllStringFunc(text, func(match string) string {
-                var code int
-                n, err := fmt.Sscanf(match, "&#%d;", &code)
-                if err == nil && n == 1 && code > 0 && code <= 0x10FFFF {
-                        entitiesDecoded++
-                        return string(rune(code))
-                }
-                return match
-        })
+// refuseOverwrite returns an error if the output file or backup at path
+// already exists, which only -force allows to be replaced
+func refuseOverwrite(path, what string) error {
+        if _, err := os.Lstat(path); err == nil {
+                return fmt.Errorf("%s '%s' already exists (use -force to overwrite it)", what, path)
+        }
+        return nil
+}
 
-        hexEntityPattern := regexp.MustCompile(`(?i)&#x([0-9a-fA-F]+);`)
-        text = hexEntityPattern.ReplaceAllStringFunc(text, func(match string) string {
-                var code int
-                n, err := fmt.Sscanf(strings.ToLower(match), "&#x%x;", &code)
-                if err == nil && n == 1 && code > 0 && code <= 0x10FFFF {
-                        entitiesDecoded++
-                        return string(rune(code))
-                }
-                return match
-        })
+// createBackup copies inputPath to its backup (see backupPath), with the
+// permissions of the input unless -output-mode is set
+func createBackup(inputPath, root string, options CleaningOptions, verbose bool) {
+        backupPath := backupPath(inputPath, root, options)
+        err := os.MkdirAll(filepath.Dir(backupPath), 0755)
+        if err == nil {
+                err = copyFile(inputPath, backupPath, options.OutputMode)
+        }
+        if err != nil {
+                fmt.Printf("%s Could not create backup: %v\n", colorize("Warning:", ansiYellow), err)
+        } else if verbose {
+                fmt.Printf("Backup created: %s\n", backupPath)
+        }
+}
 
-        multipleSpacesPattern := regexp.MustCompile(`[ \t]+`)
-        text = multipleSpacesPattern.ReplaceAllString(text, " ")
+func printResults(w io.Writer, inputPath, outputPath string, stats *CleaningStats, showDetails bool, targetOS string) {
+        fmt.Fprintln(w, "\n"+strings.Repeat("=", 70))
+        fmt.Fprintln(w, colorize("FILE CLEANING REPORT", ansiBold))
+        fmt.Fprintln(w, strings.Repeat("=", 70))
 
-        multipleNewlinesPattern := regexp.MustCompile(`\n{3,}`)
-        text = multipleNewlinesPattern.ReplaceAllString(text, "\n\n")
+        fmt.Fprintf(w, "\n%s\n", colorize("Files:", ansiBold, ansiCyan))
+        fmt.Fprintf(w, "   Input:  %s\n", inputPath)
+        if outputPath == "" {
+                fmt.Fprintf(w, "   Output: (check only, nothing written)\n")
+        } else {
+                fmt.Fprintf(w, "   Output: %s\n", outputPath)
+        }
+        if stats.InputHash != "" {
+                fmt.Fprintf(w, "   Input SHA-256:  %s\n", stats.InputHash)
+        }
+        if outputPath != "" && stats.OutputHash != "" {
+                fmt.Fprintf(w, "   Output SHA-256: %s\n", stats.OutputHash)
+        }
 
-        return text, entitiesDecoded
+        printStatistics(w, stats, showDetails, targetOS)
+
+        fmt.Fprintln(w, "\n"+strings.Repeat("=", 70))
+        if outputPath == "" && stats.changed() {
+                fmt.Fprintln(w, colorize("Check failed - file needs cleaning!", ansiBold, ansiRed))
+        } else if outputPath == "" && stats.flagged() {
+                fmt.Fprintln(w, colorize("Check failed - file has flagged words!", ansiBold, ansiRed))
+        } else if outputPath == "" {
+                fmt.Fprintln(w, colorize("Check passed - file is clean!", ansiBold, ansiGreen))
+        } else if stats.changed() {
+                fmt.Fprintln(w, colorize("File cleaned successfully!", ansiBold, ansiGreen))
+        } else {
+                fmt.Fprintln(w, colorize("File processed - no changes needed!", ansiGreen))
+        }
+        fmt.Fprintln(w, strings.Repeat("=", 70))
 }
 
-func printResults(inputPath, outputPath string, stats *CleaningStats, showDetails bool, targetOS string) {
-        fmt.Println("\n" + strings.Repeat("=", 70))
-        fmt.Println("FILE CLEANING REPORT")
-        fmt.Println(strings.Repeat("=", 70))
-
-        fmt.Printf("\nFiles:\n")
-        fmt.Printf("   Input:  %s\n", inputPath)
-        fmt.Printf("   Output: %s\n", outputPath)
-
-        fmt.Printf("\nConfiguration:\n")
+// printStatistics prints the configuration, statistics and character
+// breakdown sections shared by the single-file and batch reports
+func printStatistics(w io.Writer, stats *CleaningStats, showDetails bool, targetOS string) {
+        fmt.Fprintf(w, "\n%s\n", colorize("Configuration:", ansiBold, ansiCyan))
         osName := targetOS
         lineEnding := "LF (\\n)"
         if targetOS == "windows" {
@@ -473,305 +590,407 @@ func printResults(inputPath, outputPath string, stats *CleaningStats, showDetail
                 osName = "Unix/Linux/macOS"
                 lineEnding = "LF (\\n)"
         }
-        fmt.Printf("   Target OS:              %s\n", osName)
-        fmt.Printf("   Line ending format:     %s\n", lineEnding)
+        fmt.Fprintf(w, "   Target OS:              %s\n", osName)
+        fmt.Fprintf(w, "   Line ending format:     %s\n", lineEnding)
 
+        if stats.InputEncoding != "" {
+                encoding := stats.InputEncoding
+                if stats.BOMPresent {
+                        encoding += " with BOM"
+                }
+                switch {
+                case stats.DecodedFrom != "":
+                        encoding += " (converted to UTF-8)"
+                case stats.validUTF8():
+                        encoding += " (valid)"
+                default:
+                        encoding += fmt.Sprintf(" (%d invalid sequence(s))", stats.InvalidUTF8Sequences)
+                }
+                fmt.Fprintf(w, "   Input encoding:         %s\n", encoding)
+        }
         if stats.FormatDetected != "" {
-                fmt.Printf("   Detected format:        %s\n", stats.FormatDetected)
+                fmt.Fprintf(w, "   Detected format:        %s\n", stats.FormatDetected)
         }
         if stats.MarkdownStripped {
-                fmt.Printf("   Markdown stripped:      Yes\n")
+                fmt.Fprintf(w, "   Markdown stripped:      Yes\n")
         }
         if stats.HTMLStripped {
-                fmt.Printf("   HTML stripped:          Yes\n")
+                fmt.Fprintf(w, "   HTML stripped:          Yes\n")
                 if stats.HTMLEntitiesDecoded > 0 {
-                        fmt.Printf("   HTML entities decoded:  %d\n", stats.HTMLEntitiesDecoded)
+                        fmt.Fprintf(w, "   HTML entities decoded:  %d\n", stats.HTMLEntitiesDecoded)
                 }
         }
+        if stats.SubtitlesStripped {
+                fmt.Fprintf(w, "   Subtitles flattened:    Yes\n")
+        }
 
-        fmt.Printf("\nProcessing Statistics:\n")
-        fmt.Printf("   Lines processed:        %d\n", stats.LinesProcessed)
+        if len(stats.StrippedConstructs) > 0 {
+                fmt.Fprintf(w, "\n%s\n", colorize("Stripped Constructs:", ansiBold, ansiCyan))
+                names := make([]string, 0, len(stats.StrippedConstructs))
+                for name := range stats.StrippedConstructs {
+                        names = append(names, name)
+                }
+                sort.Slice(names, func(i, j int) bool {
+                        ci, cj := stats.StrippedConstructs[names[i]], stats.StrippedConstructs[names[j]]
+                        if ci != cj {
+                                return ci > cj
+                        }
+                        return names[i] < names[j]
+                })
+                for _, name := range names {
+                        fmt.Fprintf(w, "   %-24s%d\n", name+":", stats.StrippedConstructs[name])
+                }
+        }
+
+        fmt.Fprintf(w, "\n%s\n", colorize("Processing Statistics:", ansiBold, ansiCyan))
+        fmt.Fprintf(w, "   Lines processed:        %d\n", stats.LinesProcessed)
         if stats.LinesWithIssues > 0 {
-                fmt.Printf("   Lines with issues:      %d\n", stats.LinesWithIssues)
+                fmt.Fprintf(w, "   Lines with issues:      %d\n", stats.LinesWithIssues)
+        }
+        if len(stats.OriginalLineEndings) > 0 {
+                fmt.Fprintf(w, "   Original line endings:  %s\n", formatCounts(stats.OriginalLineEndings))
         }
         if stats.LineEndingsConverted > 0 {
-                fmt.Printf("   Line endings converted: %d\n", stats.LineEndingsConverted)
+                fmt.Fprintf(w, "   Line endings converted: %d (%s)\n", stats.LineEndingsConverted, formatCounts(stats.LineEndingConversions))
+        }
+        if stats.MojibakeRepaired > 0 {
+                fmt.Fprintf(w, "   Mojibake repaired:      %d character(s)\n", stats.MojibakeRepaired)
+        }
+        if stats.UnicodeNormalized > 0 {
+                fmt.Fprintf(w, "   Unicode normalized:     %d sequence(s)\n", stats.UnicodeNormalized)
+        }
+        if stats.Transliterated > 0 {
+                fmt.Fprintf(w, "   Transliterated:         %d character(s)\n", stats.Transliterated)
+        }
+        if stats.TrailingWhitespaceTrimmed > 0 {
+                fmt.Fprintf(w, "   Trailing chars trimmed: %d\n", stats.TrailingWhitespaceTrimmed)
+        }
+        if stats.FinalNewlineAdded {
+                fmt.Fprintf(w, "   Final newline added:    Yes\n")
+        }
+        if stats.BOMAdded {
+                fmt.Fprintf(w, "   BOM added:              Yes\n")
+        }
+        if stats.ProvenanceAdded {
+                fmt.Fprintf(w, "   Provenance comment:     Yes\n")
+        }
+        if stats.QuotesNormalized > 0 {
+                fmt.Fprintf(w, "   Quotes normalized:      %d\n", stats.QuotesNormalized)
+        }
+        if stats.PunctuationConverted > 0 {
+                fmt.Fprintf(w, "   Punctuation converted:  %d\n", stats.PunctuationConverted)
+        }
+        if stats.StrayQuotesRemoved > 0 {
+                fmt.Fprintf(w, "   Stray quotes removed:   %d\n", stats.StrayQuotesRemoved)
+        }
+        if stats.FormulasEscaped > 0 {
+                fmt.Fprintf(w, "   CSV formulas escaped:   %d\n", stats.FormulasEscaped)
+        }
+        if stats.NewlinesEscaped > 0 {
+                fmt.Fprintf(w, "   TSV newlines escaped:   %d\n", stats.NewlinesEscaped)
+        }
+        if stats.ReplyMarkersNormalized > 0 {
+                fmt.Fprintf(w, "   Reply markers fixed:    %d\n", stats.ReplyMarkersNormalized)
+        }
+        if stats.ReactionLinesDropped > 0 {
+                fmt.Fprintf(w, "   Reaction lines dropped: %d\n", stats.ReactionLinesDropped)
+        }
+        if stats.EmojiConverted > 0 {
+                fmt.Fprintf(w, "   Emoji converted:        %d\n", stats.EmojiConverted)
+        }
+        if stats.SoftHyphensConverted > 0 {
+                fmt.Fprintf(w, "   Soft hyphens converted: %d\n", stats.SoftHyphensConverted)
+        }
+        if stats.SpacesConverted > 0 {
+                fmt.Fprintf(w, "   Spaces converted:       %d\n", stats.SpacesConverted)
+        }
+        if stats.DashesConverted > 0 {
+                fmt.Fprintf(w, "   Dashes converted:       %d\n", stats.DashesConverted)
+        }
+        if stats.EscapesDecoded > 0 {
+                fmt.Fprintf(w, "   Escapes decoded:        %d\n", stats.EscapesDecoded)
+        }
+        if stats.InvalidUTF8Sequences > 0 {
+                fmt.Fprintf(w, "   Invalid UTF-8:          %d sequence(s) %s\n", stats.InvalidUTF8Sequences, stats.InvalidUTF8Action)
+        }
+        if stats.ReplacementCharsAdded > 0 {
+                fmt.Fprintf(w, "   U+FFFD introduced:      %d\n", stats.ReplacementCharsAdded)
+        }
+        fmt.Fprintf(w, "   Original characters:    %d\n", stats.OriginalChars)
+        if stats.TotalChars != stats.OriginalChars {
+                if stats.MarkdownStripped || stats.HTMLStripped || stats.SubtitlesStripped {
+                        fmt.Fprintf(w, "   After stripping:        %d\n", stats.TotalChars)
+                } else {
+                        fmt.Fprintf(w, "   After normalizing:      %d\n", stats.TotalChars)
+                }
+        }
+        fmt.Fprintf(w, "   After cleaning:         %d\n", stats.OutputChars)
+        if stats.OriginalBytes > 0 || stats.OutputBytes > 0 {
+                fmt.Fprintf(w, "   Size:                   %d -> %d bytes (%+d)\n", stats.OriginalBytes, stats.OutputBytes, stats.sizeDelta())
         }
-        fmt.Printf("   Total characters:       %d\n", stats.TotalChars)
 
-        fmt.Printf("\nCharacter Removal Summary:\n")
+        fmt.Fprintf(w, "\n%s\n", colorize("Character Removal Summary:", ansiBold, ansiCyan))
         if stats.RemovedChars == 0 {
-                fmt.Printf("   No invalid characters found - file is clean!\n")
+                fmt.Fprintf(w, "   %s\n", colorize("No invalid characters found - file is clean!", ansiGreen))
+                if stats.AllowedKept > 0 {
+                        fmt.Fprintf(w, "   Kept (allowed):       %d characters\n", stats.AllowedKept)
+                }
         } else {
-                fmt.Printf("   Total removed:        %d characters\n", stats.RemovedChars)
+                fmt.Fprintf(w, "   Total removed:        %s characters (%d bytes)\n", colorize(fmt.Sprint(stats.RemovedChars), ansiBold, ansiYellow), stats.removedBytes())
+                if stats.Replacement != "" {
+                        r, _ := utf8.DecodeRuneInString(stats.Replacement)
+                        fmt.Fprintf(w, "   Replaced with:        '%s' (U+%04X)\n", stats.Replacement, r)
+                }
                 if stats.ZeroWidthRemoved > 0 {
-                        fmt.Printf("   Zero-width chars:     %d\n", stats.ZeroWidthRemoved)
+                        fmt.Fprintf(w, "   Zero-width chars:     %d\n", stats.ZeroWidthRemoved)
                 }
                 if stats.ControlCharsRemoved > 0 {
-                        fmt.Printf("   Control chars:        %d\n", stats.ControlCharsRemoved)
+                        fmt.Fprintf(w, "   Control chars:        %d\n", stats.ControlCharsRemoved)
+                }
+                if stats.RestrictedRemoved > 0 {
+                        fmt.Fprintf(w, "   Restricted chars:     %d\n", stats.RestrictedRemoved)
                 }
                 if stats.NonASCIIRemoved > 0 {
-                        fmt.Printf("   Non-ASCII chars:      %d\n", stats.NonASCIIRemoved)
+                        fmt.Fprintf(w, "   Non-ASCII chars:      %d\n", stats.NonASCIIRemoved)
+                }
+                if stats.EmojiRemoved > 0 {
+                        fmt.Fprintf(w, "   Emoji:                %d (%d characters)\n", stats.EmojiRemoved, stats.EmojiCharsRemoved)
+                }
+                if stats.ModifiersRemoved > 0 {
+                        fmt.Fprintf(w, "   Modifiers/joiners:    %d\n", stats.ModifiersRemoved)
+                }
+                if stats.SoftHyphensRemoved > 0 {
+                        fmt.Fprintf(w, "   Soft hyphens:         %d\n", stats.SoftHyphensRemoved)
+                }
+                if stats.AllowedKept > 0 {
+                        fmt.Fprintf(w, "   Kept (allowed):       %d characters\n", stats.AllowedKept)
                 }
 
                 if stats.TotalChars > 0 {
                         percentage := float64(stats.RemovedChars) / float64(stats.TotalChars) * 100
-                        fmt.Printf("\n   Removal rate: %.2f%% of total characters\n", percentage)
+                        fmt.Fprintf(w, "\n   Removal rate: %.2f%% of characters cleaned\n", percentage)
                 }
         }
 
-        if showDetails && len(stats.RemovedCharDetails) > 0 {
-                fmt.Printf("\nDetailed Character Breakdown:\n")
-                fmt.Println(strings.Repeat("-", 70))
-
-                for char, count := range stats.RemovedCharDetails {
-                        desc := charDescriptions[char]
-                        if desc == "" {
-                                if unicode.IsPrint(char) {
-                                        desc = fmt.Sprintf("Character '%c'", char)
-                                } else if unicode.IsControl(char) {
-                                        desc = fmt.Sprintf("Control character (U+%04X)", char)
-                                } else {
-                                        desc = fmt.Sprintf("Non-printable (U+%04X)", char)
-                                }
-                        }
-
-                        fmt.Printf("   U+%04X  %-40s  %d occurrence(s)\n", char, desc, count)
+        if len(stats.Warnings) > 0 {
+                fmt.Fprintf(w, "\n%s\n", colorize("Warnings:", ansiBold, ansiYellow))
+                for _, warning := range stats.Warnings {
+                        fmt.Fprintf(w, "   %s\n", formatWarning(warning))
                 }
-                fmt.Println(strings.Repeat("-", 70))
         }
 
-        fmt.Println("\n" + strings.Repeat("=", 70))
-        if stats.RemovedChars > 0 || stats.LineEndingsConverted > 0 || stats.MarkdownStripped || stats.HTMLStripped {
-                fmt.Println("File cleaned successfully!")
-        } else {
-                fmt.Println("File processed - no changes needed!")
-        }
-        fmt.Println(strings.Repeat("=", 70))
-}
-
-func cleanFile(inputPath, outputPath string, options CleaningOptions, verbose bool) (*CleaningStats, error) {
-        contentBytes, err := os.ReadFile(inputPath)
-        if err != nil {
-                return nil, fmt.Errorf("could not read input file: %w", err)
-        }
-
-        content := string(contentBytes)
-
-        stats := &CleaningStats{
-                RemovedCharDetails: make(map[rune]int),
-        }
-
-        if options.StripFormat != "" {
-                detectedFormat := detectFileFormat(content)
-                stats.FormatDetected = detectedFormat
-
-                if verbose {
-                        fmt.Printf("Detected format: %s\n", detectedFormat)
-                }
-
-                if options.StripFormat == "markdown" {
-                        if detectedFormat != "markdown" {
-                                return nil, fmt.Errorf("file does not appear to be Markdown (detected: %s)", detectedFormat)
-                        }
-                        if verbose {
-                                fmt.Println("Stripping Markdown formatting...")
-                        }
-                        content = stripMarkdown(content)
-                        stats.MarkdownStripped = true
-                } else if options.StripFormat == "html" {
-                        if detectedFormat != "html" {
-                                return nil, fmt.Errorf("file does not appear to be HTML (detected: %s)", detectedFormat)
-                        }
-                        if verbose {
-                                fmt.Println("Stripping HTML tags and decoding entities...")
+        if len(stats.EscapedCells) > 0 {
+                fmt.Fprintf(w, "\n%s\n", colorize("Escaped CSV Cells:", ansiBold, ansiCyan))
+                for i, cell := range stats.EscapedCells {
+                        if i == maxListedCells && !showDetails {
+                                fmt.Fprintf(w, "   ... and %d more (use -details to list all)\n", stats.FormulasEscaped-i)
+                                break
                         }
-                        var entitiesDecoded int
-                        content, entitiesDecoded = stripHTML(content)
-                        stats.HTMLStripped = true
-                        stats.HTMLEntitiesDecoded = entitiesDecoded
+                        fmt.Fprintf(w, "   line %d, field %d: %s\n", cell.Line, cell.Field, truncateCell(cell.Value))
                 }
         }
 
-        outFile, err := os.Create(outputPath)
-        if err != nil {
-                return nil, fmt.Errorf("could not create output file: %w", err)
-        }
-        defer outFile.Close()
-
-        writer := bufio.NewWriter(outFile)
-        defer writer.Flush()
-
-        lineNum := 0
-        targetLineEnding := getLineEnding(options.TargetOS)
-        lines := strings.Split(content, "\n")
-
-        for i, line := range lines {
-                lineNum++
-                stats.LinesProcessed++
-
-                if i < len(lines)-1 {
-                        line += "\n"
-                } else if len(contentBytes) > 0 && contentBytes[len(contentBytes)-1] == '\n' {
-                        line += "\n"
-                }
-
-                cleanedLine, lineStats := cleanString(line, options)
-
-                stats.TotalChars += lineStats.TotalChars
-                stats.RemovedChars += lineStats.RemovedChars
-                stats.NonASCIIRemoved += lineStats.NonASCIIRemoved
-                stats.ControlCharsRemoved += lineStats.ControlCharsRemoved
-                stats.ZeroWidthRemoved += lineStats.ZeroWidthRemoved
-
-                for char, count := range lineStats.RemovedCharDetails {
-                        stats.RemovedCharDetails[char] += count
+        if len(stats.MalformedRows) > 0 {
+                fmt.Fprintf(w, "\n%s\n", colorize("Malformed TSV Rows:", ansiBold, ansiYellow))
+                for i, row := range stats.MalformedRows {
+                        if i == maxListedCells && !showDetails {
+                                fmt.Fprintf(w, "   ... and %d more (use -details to list all)\n", len(stats.MalformedRows)-i)
+                                break
+                        }
+                        fmt.Fprintf(w, "   line %d: %d field(s), expected %d\n", row.Line, row.Fields, row.Expected)
                 }
+        }
 
-                if lineStats.RemovedChars > 0 {
-                        stats.LinesWithIssues++
-                }
+        if showDetails && len(stats.RemovedCharDetails) > 0 {
+                fmt.Fprintf(w, "\n%s\n", colorize("Detailed Character Breakdown:", ansiBold, ansiCyan))
+                fmt.Fprintln(w, strings.Repeat("-", 70))
 
-                cleanedLine, converted := normalizeLineEndings(cleanedLine, targetLineEnding)
-                if converted {
-                        stats.LineEndingsConverted++
+                for char, count := range stats.RemovedCharDetails {
+                        fmt.Fprintf(w, "   U+%04X  %-40s  %d occurrence(s)\n", char, describeChar(char), count)
                 }
+                fmt.Fprintln(w, strings.Repeat("-", 70))
+        }
 
-                if verbose && lineStats.RemovedChars > 0 {
-                        fmt.Printf("Line %d: Removed %d invalid character(s) ", lineNum, lineStats.RemovedChars)
-                        fmt.Printf("[ZW:%d, Ctrl:%d, Non-ASCII:%d]\n",
-                                lineStats.ZeroWidthRemoved,
-                                lineStats.ControlCharsRemoved,
-                                lineStats.NonASCIIRemoved)
+        if showDetails && len(stats.WordsAffected) > 0 {
+                fmt.Fprintf(w, "\n%s\n", colorize("Removals Inside Words:", ansiBold, ansiCyan))
+                for _, word := range stats.WordsAffected {
+                        fmt.Fprintf(w, "   line %d, column %d: %s -> %s\n", word.Line, word.Column, word.Before, word.After)
                 }
+        }
+}
 
-                if _, err := writer.WriteString(cleanedLine); err != nil {
-                        return nil, fmt.Errorf("error writing to output: %w", err)
+// cleanFile cleans inputPath into sink. With a discardSink it runs the full
+// pipeline and only collects statistics (-check).
+//
+// The input is streamed line by line, so memory use does not grow with the
+// file size. Format stripping needs the whole document and therefore falls
+// back to reading the file into memory, which -max-size can limit.
+func cleanFile(inputPath string, sink OutputSink, options CleaningOptions, verbose bool) (*CleaningStats, error) {
+        if archiveFormat(inputPath) != "" {
+                return cleanArchive(inputPath, sink, options, verbose)
+        }
+        inFile := os.Stdin
+        if inputPath != "-" {
+                var err error
+                inFile, err = os.Open(inputPath)
+                if err != nil {
+                        return nil, fmt.Errorf("could not read input file: %w", err)
                 }
+                defer inFile.Close()
         }
-
-        if err := writer.Flush(); err != nil {
-                return nil, fmt.Errorf("error flushing output: %w", err)
+        info, _ := inFile.Stat()
+
+        // Holes read as NUL characters, which -control removes. With
+        // -control=false they are kept, and a file output leaves runs of
+        // zero bytes as holes again (sparseWriter); any other output gets
+        // them written out in full
+        sparse := info != nil && isSparse(info)
+        file, toFile := sink.(*fileSink)
+        if toFile && sparse {
+                file.Sparse = true
         }
-
-        return stats, nil
+        stats, err := cleanReader(inFile, info, inputPath, sink, options, verbose)
+        _, check := sink.(*discardSink)
+        if err == nil && sparse && !toFile && !check && !options.RemoveControlChars {
+                stats.warn(warnSparse, 0, "sparse input (%d bytes, %d allocated) is written out in full to %s",
+                        info.Size(), allocatedSize(info), sink)
+        }
+        return stats, err
 }
 
-func normalizeLineEndings(line, targetEnding string) (string, bool) {
-        originalLine := line
-        converted := false
+// In-place cleaning is retried this many times if another process modifies
+// the file while it is being cleaned
+const (
+        inPlaceAttempts   = 3
+        inPlaceRetryDelay = 200 * time.Millisecond
+)
 
-        if strings.Contains(line, "\r\n") {
-                if targetEnding != "\r\n" {
-                        line = strings.ReplaceAll(line, "\r\n", targetEnding)
-                        converted = true
+// cleanFileInPlace cleans path into a temporary file in the same directory
+// and renames it over the original, so readers never observe a partially
+// written file and a failed run leaves the original untouched. If the
+// file's size or modification time changes while it is being cleaned, the
+// result is discarded and cleaning retried, so a concurrent writer's update
+// is not lost. A symlink is followed: the file it points to is cleaned and
+// replaced, and the link is left as it is. backup, if not nil, is called
+// just before the file is replaced, so a file left as it was gets none.
+func cleanFileInPlace(path string, options CleaningOptions, verbose bool, backup func()) (*CleaningStats, error) {
+        target, err := filepath.EvalSymlinks(path)
+        if err != nil {
+                return nil, fmt.Errorf("could not resolve input file: %w", err)
+        }
+        for attempt := 1; ; attempt++ {
+                stats, modified, err := cleanFileInPlaceOnce(target, options, verbose, backup)
+                if err != nil {
+                        return nil, err
                 }
-        } else if strings.Contains(line, "\n") {
-                if targetEnding != "\n" {
-                        line = strings.ReplaceAll(line, "\n", targetEnding)
-                        converted = true
+                if !modified {
+                        if attempt > 1 {
+                                stats.warn(warnRetried, 0, "file was modified by another process during cleaning; succeeded on attempt %d", attempt)
+                        }
+                        return stats, nil
                 }
-        } else if strings.Contains(line, "\r") {
-                if targetEnding != "\r" {
-                        line = strings.ReplaceAll(line, "\r", targetEnding)
-                        converted = true
+                if attempt == inPlaceAttempts {
+                        return nil, fmt.Errorf("file is being modified by another process (changed during %d cleaning attempts); left unchanged", attempt)
                 }
+                if verbose {
+                        fmt.Printf("%s changed while being cleaned, retrying\n", path)
+                }
+                time.Sleep(time.Duration(attempt) * inPlaceRetryDelay)
         }
-
-        return line, converted && (line != originalLine)
 }
 
-func cleanString(s string, options CleaningOptions) (string, *CleaningStats) {
-        stats := &CleaningStats{
-                RemovedCharDetails: make(map[rune]int),
+// cleanFileInPlaceOnce makes a single attempt at cleaning path in place. It
+// reports modified, and leaves the file alone, if path changed in between.
+func cleanFileInPlaceOnce(path string, options CleaningOptions, verbose bool, backup func()) (*CleaningStats, bool, error) {
+        before, err := os.Stat(path)
+        if err != nil {
+                return nil, false, fmt.Errorf("could not stat input file: %w", err)
         }
-        var result strings.Builder
-        result.Grow(len(s))
 
-        if len(s) == 0 {
-                return s, stats
+        tmpPath, err := createTempFor(path, options.TempDir)
+        if err != nil {
+                return nil, false, fmt.Errorf("could not create temporary file: %w", err)
         }
 
-        runes := []rune(s)
-
-        startIdx := 0
-        if options.RemoveBOM && len(runes) > 0 && runes[0] == '\uFEFF' {
-                startIdx = 1
-                stats.RemovedChars++
-                stats.ZeroWidthRemoved++
-                stats.TotalChars++
-                stats.RemovedCharDetails['\uFEFF']++
+        stats, err := cleanFile(path, &fileSink{Path: tmpPath}, options, verbose)
+        if err != nil {
+                os.Remove(tmpPath)
+                return nil, false, err
         }
-
-        for i := startIdx; i < len(runes); i++ {
-                r := runes[i]
-                stats.TotalChars++
-                shouldKeep := true
-
-                if options.RemoveZeroWidth && isZeroWidth(r) {
-                        stats.RemovedChars++
-                        stats.ZeroWidthRemoved++
-                        stats.RemovedCharDetails[r]++
-                        shouldKeep = false
-                        continue
-                }
-
-                if options.RemoveNonASCII && r > 127 {
-                        if r == '\n' || r == '\r' {
-                                result.WriteRune(r)
-                                continue
+        // A file that cleaning leaves as it was is not rewritten, so that its
+        // modification time does not change; only -output-mode still applies
+        same, err := sameContent(path, tmpPath)
+        if err != nil {
+                os.Remove(tmpPath)
+                return nil, false, fmt.Errorf("could not compare output with input: %w", err)
+        }
+        if same {
+                os.Remove(tmpPath)
+                stats.OutputSkipped = true
+                if options.OutputMode != 0 && before.Mode().Perm() != options.OutputMode {
+                        if err := os.Chmod(path, options.OutputMode); err != nil {
+                                return nil, false, fmt.Errorf("could not set permissions: %w", err)
                         }
-                        stats.RemovedChars++
-                        stats.NonASCIIRemoved++
-                        stats.RemovedCharDetails[r]++
-                        shouldKeep = false
-                        continue
                 }
-
-                if options.RemoveControlChars && unicode.IsControl(r) {
-                        if r == '\n' || r == '\r' || r == '\t' {
-                                result.WriteRune(r)
-                                continue
-                        }
-                        stats.RemovedChars++
-                        stats.ControlCharsRemoved++
-                        stats.RemovedCharDetails[r]++
-                        shouldKeep = false
-                        continue
+                return stats, false, nil
+        }
+        if options.TempDir != "" && !sameDevice(options.TempDir, filepath.Dir(path)) {
+                // A rename across filesystems is a copy, which would leave a
+                // partly written file behind if interrupted
+                tmpPath, err = stageNextTo(path, tmpPath)
+                if err != nil {
+                        return nil, false, fmt.Errorf("could not copy temporary file: %w", err)
                 }
+        }
 
-                if options.NormalizeWhitespace && unicode.IsSpace(r) {
-                        if (r == '\n' || r == '\r') && options.PreserveNewlines {
-                                result.WriteRune(r)
-                                continue
-                        }
-                        if (r == '\n' || r == '\r') && !options.PreserveNewlines {
-                                continue
-                        }
-                        if r != ' ' {
-                                result.WriteRune(' ')
-                                continue
-                        }
+        // The owner goes before the permissions, which changing it can reset
+        preserveAttributes(tmpPath, before, options, stats)
+        perm := before.Mode().Perm()
+        if options.OutputMode != 0 {
+                perm = options.OutputMode
+        }
+        if err := os.Chmod(tmpPath, perm); err != nil {
+                os.Remove(tmpPath)
+                return nil, false, fmt.Errorf("could not set permissions on temporary file: %w", err)
+        }
+        preserveMetadata(path, tmpPath, stats)
+        if options.Verify {
+                if err := verifyOutput(tmpPath, options); err != nil {
+                        os.Remove(tmpPath)
+                        return nil, false, fmt.Errorf("%w (the file was left unchanged)", err)
                 }
+        }
 
-                if shouldKeep {
-                        result.WriteRune(r)
+        after, err := os.Stat(path)
+        if err != nil {
+                os.Remove(tmpPath)
+                return nil, false, fmt.Errorf("could not stat input file: %w", err)
+        }
+        if after.Size() != before.Size() || !after.ModTime().Equal(before.ModTime()) {
+                os.Remove(tmpPath)
+                return nil, true, nil
+        }
+        if backup != nil {
+                backup()
+        }
+        if options.UndoPatch {
+                if err := writeUndoPatch(path, tmpPath, path, options.MaxMemory); err != nil {
+                        os.Remove(tmpPath)
+                        return nil, false, fmt.Errorf("could not write undo patch: %w", err)
                 }
         }
 
-        return result.String(), stats
-}
-
-func isZeroWidth(r rune) bool {
-        for _, zw := range zeroWidthChars {
-                if r == zw {
-                        return true
-                }
+        if err := os.Rename(tmpPath, path); err != nil {
+                os.Remove(tmpPath)
+                return nil, false, fmt.Errorf("could not replace input file: %w", err)
         }
-        return false
+
+        return stats, false, nil
 }
 
-func copyFile(src, dst string) error {
+// copyFile copies src to dst, which gets mode or, if 0, the permissions of
+// src (see createFile)
+func copyFile(src, dst string, mode os.FileMode) error {
         sourceInfo, err := os.Stat(src)
         if err != nil {
                 if os.IsNotExist(err) {
@@ -790,16 +1009,27 @@ func copyFile(src, dst string) error {
         }
         defer sourceFile.Close()
 
-        destFile, err := os.Create(dst)
+        perm := sourceInfo.Mode().Perm()
+        destFile, err := createFile(dst, mode, &perm)
         if err != nil {
                 return fmt.Errorf("could not create destination file: %w", err)
         }
         defer destFile.Close()
 
-        bytesWritten, err := io.Copy(destFile, sourceFile)
+        // Keep the holes of sparse files rather than allocating them
+        var dest io.Writer = destFile
+        sparse := &sparseWriter{file: destFile}
+        if isSparse(sourceInfo) {
+                dest = sparse
+        }
+
+        bytesWritten, err := io.Copy(dest, sourceFile)
         if err != nil {
                 return fmt.Errorf("error copying file: %w", err)
         }
+        if err := sparse.finish(); err != nil {
+                return fmt.Errorf("error copying file: %w", err)
+        }
 
         if bytesWritten != sourceInfo.Size() {
                 return fmt.Errorf("incomplete copy: wrote %d bytes, expected %d", bytesWritten, sourceInfo.Size())
@@ -810,3 +1040,4 @@ func copyFile(src, dst string) error {
         }
 
         return nil
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+        "crypto/sha256"
+        "encoding/hex"
+        "errors"
+        "flag"
+        "fmt"
+        "os"
+        "sort"
+        "strings"
+        "text/template"
+        "time"
+)
+
+// cleanFlags holds the flags of the clean, check and git-hook subcommands
+type cleanFlags struct {
+        mode   string
+        fs     *flag.FlagSet
+        unused *flag.FlagSet // flags the mode does not accept, left at their defaults
+
+        inputFile            *string
+        outputFile           *string
+        outputDir            *string
+        removeNonASCII       *bool
+        removeControl        *bool
+        removeZeroWidth      *bool
+        removeBOM            *bool
+        ensureBOM            *bool
+        normalizeQuotes      *bool
+        smartPunct           *bool
+        fixQuotes            *bool
+        fixMojibake          *bool
+        csvSafe              *bool
+        tsv                  *bool
+        tsvNewline           *string
+        keyValue             *bool
+        chat                 *bool
+        dropReactions        *bool
+        poMode               *bool
+        decodeEscapes        *bool
+        csvDelimiter         *string
+        removeEmoji          *bool
+        stripModifiers       *bool
+        spaces               *bool
+        softHyphens          *string
+        dashes               *string
+        invalidUTF8          *string
+        inputEncoding        *string
+        emojiMode            *string
+        keepLetters          *bool
+        allowRanges          *string
+        replacement          *string
+        normalizeWS          *bool
+        normalizeUnicodeForm *string
+        preserveNL           *bool
+        backup               *bool
+        force                *bool
+        backupDir            *string
+        backupName           *string
+        verbose              *bool
+        showDetails          *bool
+        traceLines           *string
+        targetOS             *string
+        stripFormat          *string
+        stripKeep            *string
+        stripExtract         *string
+        recursive            *bool
+        include              *string
+        exclude              *string
+        inPlace              *bool
+        check                *bool
+        strict               *bool
+        warnLineLength       *int
+        maxSize              *string
+        oversize             *string
+        maxMemory            *string
+        changedOnly          *bool
+        makeWritable         *bool
+        failFast             *bool
+        jobs                 *int
+        timeBudget           *time.Duration
+        scanState            *string
+        duplicates           *bool
+        historyFile          *string
+        reportFormat         *string
+        reportFile           *string
+        stream               *string
+        noProgress           *bool
+        reportTemplate       *string
+        template             *template.Template // parsed from -report-template by options
+        provenance           *string
+        outputMode           *string
+        preserveTimes        *bool
+        preserveOwner        *bool
+        undoPatch            *bool
+        quarantine           *string
+        verify               *bool
+        cache                *string
+        tempDir              *string
+        colorMode            *string
+        cleaningMode         *string
+        preset               *string
+        transliterate        *bool
+        configFile           *string
+        profile              *string
+        trimTrailing         *bool
+        finalNewline         *bool
+        preserveLines        *bool
+        useEditorConfig      *bool
+}
+
+// newCleanFlags defines the flags of a clean mode: "clean", "check",
+// "git-hook", "equal", "serve" (the options of a request) or "" for the
+// legacy invocation. Flags that make no sense for the mode are defined on
+// a separate set, so they keep their defaults and config files can still
+// name them.
+func newCleanFlags(mode string) *cleanFlags {
+        gitHook := mode == "git-hook"
+        legacy := mode == ""
+        // equal and serve clean in memory, writing no files or reports
+        equal := mode == "equal" || mode == "serve"
+
+        fs := newSubcommandFlags(mode)
+        f := &cleanFlags{mode: mode, fs: fs, unused: flag.NewFlagSet("", flag.ContinueOnError)}
+        only := func(available bool) *flag.FlagSet {
+                if available {
+                        return fs
+                }
+                return f.unused
+        }
+
+        f.inputFile = only(!gitHook && !equal).String("input", "", "Input file or directory (may also be given as an argument)")
+        f.outputFile = only(mode == "clean" || legacy).String("output", "", "Output file path or URI: -, file://, http(s)://, s3://bucket/key (defaults to input_cleaned.ext)")
+        f.outputDir = only(mode == "clean" || legacy).String("output-dir", "", "With -recursive, write the cleaned files under this directory, mirroring the input tree, instead of next to their inputs as name_cleaned.ext")
+        f.removeNonASCII = fs.Bool("ascii", true, "Remove non-ASCII characters")
+        f.removeControl = fs.Bool("control", true, "Remove control characters (except newlines/tabs)")
+        f.removeZeroWidth = fs.Bool("zerowidth", true, "Remove zero-width characters")
+        f.removeBOM = fs.Bool("bom", true, "Remove Byte Order Mark (BOM)")
+        f.ensureBOM = fs.Bool("ensure-bom", false, "Make sure the output starts with a UTF-8 BOM, as Excel and some PowerShell tools expect")
+        fs.BoolVar(f.ensureBOM, "add-bom", false, "Same as -ensure-bom")
+        f.normalizeQuotes = fs.Bool("normalize-quotes", false, "Replace typographic quotes and apostrophes with ASCII quotes")
+        f.smartPunct = fs.Bool("smart-punct", false, "Replace curly quotes, dashes, ellipses, primes and guillemets with ASCII equivalents (— to --, … to ..., « to <<)")
+        f.fixQuotes = fs.Bool("fix-quotes", false, "With -normalize-quotes or -smart-punct, remove closing quotes that close nothing (unbalanced quotes are reported either way)")
+        f.csvSafe = fs.Bool("csv-safe", false, "Treat the input as CSV and escape fields that spreadsheets would run as formulas (=, +, -, @)")
+        f.tsv = fs.Bool("tsv", false, "Treat the input as TSV: keep every tab, escape line breaks inside fields and report rows with the wrong number of fields")
+        f.tsvNewline = fs.String("tsv-newline", `\n`, "What line breaks inside TSV fields are replaced with")
+        f.keyValue = fs.Bool("key-value", false, "Treat the input as a .properties, .ini or .env file: clean only values and comments, never keys or section headers")
+        f.chat = fs.Bool("chat", false, "Treat the input as a chat export: keep the [timestamp] author: prefix of each message and clean only the message text")
+        f.dropReactions = fs.Bool("drop-reactions", false, "With -chat, drop lines that only list reactions, such as \"👍 3\"")
+        f.poMode = fs.Bool("po", false, "Treat the input as a gettext .po or .pot file: clean only translations (msgstr), keeping msgid, comments and flags byte for byte")
+        f.decodeEscapes = fs.Bool("decode-escapes", false, "With -key-value, decode \\uXXXX escapes in values before cleaning")
+        f.csvDelimiter = fs.String("csv-delimiter", "", "Field delimiter for -csv-safe: a single character or 'tab' (default: tab for .tsv and .tab files, comma otherwise)")
+        f.removeEmoji = fs.Bool("remove-emoji", false, "Remove emoji, including flags, keycaps, skin tones and ZWJ sequences as a whole, but nothing else outside ASCII")
+        f.stripModifiers = fs.Bool("strip-modifiers", false, "Remove variation selectors (U+FE00-FE0F, U+E0100-E01EF), skin tone modifiers and zero width joiners that join nothing")
+        f.spaces = fs.Bool("spaces", false, "Replace Unicode spaces (no-break, thin, en, em, ideographic and other Zs characters) with an ASCII space instead of removing them")
+        f.softHyphens = fs.String("soft-hyphens", "remove", "What to do with soft hyphens (U+00AD): remove, hyphen (replace with -) or keep (leave them to -ascii)")
+        f.dashes = fs.String("dashes", "", "What to do with dashes, hyphens and minus signs, whatever -ascii says: keep, hyphen (replace with -) or double-hyphen (em dashes with --)")
+        f.emojiMode = fs.String("emoji", "", "What to do with emoji: remove, shortcode (convert to :smile: shortcodes) or unicode (convert shortcodes to emoji)")
+        f.keepLetters = fs.Bool("keep-letters", false, "With -ascii, keep letters of any script (and their accents), removing only symbols, emoji and invisible characters")
+        f.allowRanges = fs.String("allow-ranges", "", "Comma-separated code point ranges to keep even when their category is removed, e.g. U+00C0-U+00FF,U+2013 (or @file)")
+        f.replacement = fs.String("replacement", "", "Character written in place of each removed character, e.g. ? or U+FFFD (default: delete)")
+        f.normalizeWS = fs.Bool("normalize", false, "Normalize whitespace")
+        f.normalizeUnicodeForm = fs.String("normalize-unicode", "", "Convert to a Unicode normalization form before cleaning: nfc, nfd, nfkc or nfkd")
+        f.transliterate = fs.Bool("transliterate", false, "Reduce accented letters and compatibility characters to ASCII (é to e, ß to ss, ﬁ to fi) instead of removing them")
+        f.fixMojibake = fs.Bool("fix-mojibake", false, "Repair double-encoded UTF-8 (Ã© back to é), as found in database exports, before cleaning")
+        f.preserveNL = fs.Bool("preserve-newlines", true, "Preserve newlines when normalizing")
+        f.backup = only(mode == "clean" || legacy).Bool("backup", true, "Create backup of original file")
+        f.backupDir = only(mode == "clean" || legacy).String("backup-dir", "", "Write backups under this directory, mirroring the input tree, instead of next to each file")
+        f.backupName = only(mode == "clean" || legacy).String("backup-name", "plain", "How backups are named: plain (file.bak), timestamp (file.2024-06-01T12-00-00.bak) or numbered (file.1.bak, file.2.bak, ...)")
+        f.force = only(mode == "clean" || legacy).Bool("force", false, "Overwrite existing output files and backups instead of refusing to")
+        f.verbose = fs.Bool("verbose", false, "Verbose output")
+        f.showDetails = fs.Bool("details", false, "Show detailed list of removed characters")
+        f.traceLines = only(!equal).String("trace", "", "Write every character of these lines (e.g. 12 or 12-15) and what each option did with it to standard error")
+        f.targetOS = fs.String("os", "", "Target OS for line endings (windows, unix, mac, auto). Default: auto")
+        f.stripFormat = fs.String("strip", "", "Strip formatting: 'markdown', 'html', 'subtitles' (SRT or WebVTT to a plain transcript) or 'auto' (whichever is detected)")
+        f.stripKeep = fs.String("strip-keep", "", "Comma-separated attributes to keep as [name: value] annotations when stripping: alt, title, aria-label")
+        f.stripExtract = fs.String("strip-extract", "", "Comma-separated HTML metadata to extract into a header when stripping: title, description, json-ld")
+        f.recursive = only(!gitHook && !equal).Bool("recursive", false, "Clean all files in the input directory tree")
+        f.include = only(!equal).String("include", "", "Comma-separated glob patterns of files to clean in recursive mode (e.g. \"*.md,*.txt\")")
+        f.exclude = only(!equal).String("exclude", "", "Comma-separated glob patterns of files or directories to skip in recursive mode (e.g. \"vendor/**\")")
+        f.outputMode = only(mode == "clean" || legacy).String("output-mode", "", "Octal permissions of written files and backups, e.g. 0644 (default: those of the input)")
+        f.preserveTimes = only(mode == "clean" || legacy).Bool("preserve-times", false, "Give written files the modification and access times of the input")
+        f.preserveOwner = only(mode == "clean" || legacy).Bool("preserve-owner", false, "Give written files the owner and group of the input (needs the privileges to change them)")
+        f.undoPatch = only(mode == "clean" || legacy).Bool("undo-patch", false, "Write a patch next to each written file (name"+undoPatchSuffix+") that 'cleanfile undo' uses to restore the input exactly")
+        f.quarantine = only(mode == "clean" || legacy).String("quarantine", "", "Record every removed character with its offset, line, column and context in this JSON file")
+        f.verify = only(mode == "clean" || legacy).Bool("verify", false, "Clean every written file once more and fail if that would still change it")
+        f.tempDir = only(mode == "clean" || legacy).String("tmpdir", "", "Directory for the temporary files of -in-place (default: the directory of each file; on another filesystem they are copied back before the rename)")
+        f.inPlace = only(mode == "clean" || legacy).Bool("in-place", false, "Overwrite the input file atomically instead of writing a separate output file")
+        f.check = only(legacy || gitHook).Bool("check", false, "Only report what would be removed; write nothing and exit 1 if the file needs cleaning")
+        f.strict = fs.Bool("strict", false, "Fail instead of passing through invalid UTF-8, unknown entities or ambiguous encodings")
+        f.inputEncoding = fs.String("input-encoding", "auto", "Encoding of the input: auto (detected), utf-8, utf-16le, utf-16be, utf-32le, utf-32be, windows-1252, iso-8859-1, iso-8859-15, windows-1251, koi8-r or shift_jis")
+        f.invalidUTF8 = fs.String("invalid-utf8", "replace", "What to do with bytes that are not valid UTF-8: replace (with U+FFFD), remove, error (fail the file) or keep (write them out unchanged)")
+        f.warnLineLength = fs.Int("warn-line-length", 10000, "Warn about lines wider than this many columns, with CJK characters and emoji counting as two (0 = off)")
+        f.maxSize = fs.String("max-size", "", "Largest file to clean, e.g. 100M or 2G; larger files are refused or streamed as -oversize says (default: no limit)")
+        f.oversize = fs.String("oversize", "refuse", "What to do with files larger than -max-size: refuse (fail them) or chunk (stream them in pieces, never reading them into memory as a whole)")
+        f.maxMemory = only(!equal).String("max-memory", "", "Memory to stay within, e.g. 64M for a small container: long lines are cleaned in smaller pieces, -jobs is lowered and files that would have to be held in memory as a whole are refused (default: no limit)")
+        f.changedOnly = only(!equal).Bool("changed-only", false, "Only clean lines added or modified since the last git commit")
+        f.makeWritable = only(mode != "check" && !equal).Bool("make-writable", false, "In recursive mode, temporarily make read-only files and directories writable and restore them afterwards")
+        f.failFast = only(!equal).Bool("fail-fast", false, "In recursive mode, stop at the first file that cannot be cleaned")
+        f.jobs = only(!equal).Int("jobs", 1, "Number of files to clean concurrently in recursive mode (0 = one per CPU)")
+        f.timeBudget = only(!gitHook && !equal).Duration("time-budget", 0, "In recursive mode, start no more files after this long (e.g. 10m), taking new and changed files first")
+        f.scanState = only(!gitHook && !equal).String("scan-state", "", "In recursive mode, record when each file was scanned in this file, so runs with -time-budget take up where earlier ones stopped")
+        f.cache = only(!gitHook && !equal).String("cache", "", "In recursive check or -in-place runs, record the files found clean in this file and skip them in later runs while they and the options are unchanged")
+        f.duplicates = only(!gitHook && !equal).Bool("duplicates", false, "In recursive mode, report groups of files whose cleaned content is identical")
+        f.historyFile = only(!equal).String("history", "", "Append a summary of this run to the given history store (see 'cleanfile trends')")
+        f.reportFormat = only(!equal).String("report", "text", "Report format: text, json, csv (one row per file and removed character), sarif, lint (one file:line:column line per removed character), or rdjson or rdjsonl (reviewdog diagnostics with suggested fixes)")
+        f.reportTemplate = only(!equal).String("report-template", "", "Write the report with this Go text/template, executed over the data of the JSON report (see Report Templates)")
+        f.reportFile = only(!equal).String("report-file", "", "Write the report to this file instead of stdout")
+        f.stream = only(!equal).String("stream", "", "Write findings as NDJSON to this file (or fd:N) while the run is in progress, one event per removed character and per finished file")
+        f.noProgress = only(!equal).Bool("no-progress", false, "Do not show a progress bar on standard error, which is otherwise shown on a terminal when a run takes more than a second")
+        f.colorMode = fs.String("color", "auto", "Colorize the report: auto, always, never (NO_COLOR disables auto)")
+        f.cleaningMode = fs.String("mode", "", "Apply a bundle of options: conservative (only invisible characters and the BOM), standard (the defaults) or aggressive (transliteration, normalization, whitespace and punctuation)")
+        f.preset = fs.String("preset", "", "Apply a security profile: utr39 (UTR #39 General Security Profile: remove restricted characters, report mixed-script and confusable words)")
+        f.configFile = fs.String("config", "", "Config file to use instead of the nearest "+projectConfigName)
+        f.profile = fs.String("profile", "", "Apply a named profile from the config file")
+        f.trimTrailing = fs.Bool("trim-trailing", false, "Remove trailing spaces and tabs from every line")
+        f.finalNewline = fs.Bool("final-newline", false, "Make sure the output ends with a line ending")
+        f.preserveLines = fs.Bool("preserve-lines", false, "Guarantee the output has as many lines as the input, so line-number-based annotations stay valid")
+        f.provenance = only(mode == "clean" || legacy).String("provenance", "", "Add a comment with the tool version, time and options hash to cleaned Markdown, HTML and source files: prepend or append")
+        f.useEditorConfig = fs.Bool("editorconfig", true, "Derive line endings, BOM, trailing whitespace and final newline from .editorconfig")
+        return f
+}
+
+// lookup is the optionLookup of the flags of the mode
+func (f *cleanFlags) lookup(name string) (value, def string, isBool, ok bool) {
+        fl := f.fs.Lookup(name)
+        if fl == nil {
+                return "", "", false, false
+        }
+        b, isBool := fl.Value.(interface{ IsBoolFlag() bool })
+        return fl.Value.String(), fl.DefValue, isBool && b.IsBoolFlag(), true
+}
+
+// warnings lists the flags that are set but have no effect
+func (f *cleanFlags) warnings() []string {
+        return conflictWarnings(f.lookup)
+}
+
+// options checks the flags for contradictions and invalid values and
+// returns the cleaning options they select. It does not look at the input,
+// so it can check a config file on its own.
+func (f *cleanFlags) options() (CleaningOptions, error) {
+        if err := checkConflicts(f.lookup); err != nil {
+                return CleaningOptions{}, err
+        }
+        if *f.jobs < 0 {
+                return CleaningOptions{}, errors.New("-jobs must not be negative")
+        }
+        if *f.timeBudget < 0 {
+                return CleaningOptions{}, errors.New("-time-budget must not be negative")
+        }
+
+        // The engine checks the flags it shares with the command; the
+        // profile, preset and mode are already applied to them
+        values := make(map[string]string)
+        for name := range explicitFlags(f.fs) {
+                if _, ok := optionDefaults[name]; ok && name != "profile" {
+                        values[name] = f.fs.Lookup(name).Value.String()
+                }
+        }
+        rangesFile, fromFile := strings.CutPrefix(*f.allowRanges, "@")
+        if fromFile {
+                delete(values, "allow-ranges")
+        }
+        options, err := newCleaningOptions(values)
+        if err != nil {
+                return CleaningOptions{}, err
+        }
+        if fromFile {
+                if options.AllowRanges, err = readAllowRanges(rangesFile); err != nil {
+                        return CleaningOptions{}, err
+                }
+        }
+
+        *f.reportFormat = strings.ToLower(strings.TrimSpace(*f.reportFormat))
+        if !containsString(reportFormats, *f.reportFormat) {
+                return CleaningOptions{}, fmt.Errorf("invalid report format '%s'. Valid options: %s", *f.reportFormat, strings.Join(reportFormats, ", "))
+        }
+        if f.template, err = loadReportTemplate(*f.reportTemplate); err != nil {
+                return CleaningOptions{}, err
+        }
+
+        traceFrom, traceTo, err := parseTraceLines(*f.traceLines)
+        if err != nil {
+                return CleaningOptions{}, err
+        }
+        if traceFrom > 0 && f.mode == "git-hook" {
+                return CleaningOptions{}, errors.New("-trace cannot be used with git-hook")
+        }
+
+        *f.provenance = strings.ToLower(strings.TrimSpace(*f.provenance))
+        if *f.provenance != "" && *f.provenance != "prepend" && *f.provenance != "append" {
+                return CleaningOptions{}, fmt.Errorf("invalid provenance position '%s'. Valid options: prepend, append", *f.provenance)
+        }
+        outputMode, err := parseOutputMode(*f.outputMode)
+        if err != nil {
+                return CleaningOptions{}, err
+        }
+        maxMemory, err := parseMaxMemory(*f.maxMemory)
+        if err != nil {
+                return CleaningOptions{}, err
+        }
+        *f.backupName = strings.ToLower(strings.TrimSpace(*f.backupName))
+        if !containsString(backupNames, *f.backupName) {
+                return CleaningOptions{}, fmt.Errorf("invalid backup name '%s'. Valid options: %s", *f.backupName, strings.Join(backupNames, ", "))
+        }
+        if err := checkTempDir(*f.tempDir); err != nil {
+                return CleaningOptions{}, err
+        }
+
+        provenanceText := ""
+        if *f.provenance != "" {
+                provenanceText = f.provenanceText()
+        }
+
+        options.MaxMemory = maxMemory
+        options.RecordLocations = locationReports[*f.reportFormat] || *f.verbose || *f.stream != "" || *f.quarantine != ""
+        options.Quarantine = *f.quarantine != ""
+        options.Verify = *f.verify
+        options.RecordWords = *f.showDetails
+        options.TraceFrom, options.TraceTo = traceFrom, traceTo
+        options.TraceOutput = os.Stderr
+        options.Provenance = *f.provenance
+        options.ProvenanceText = provenanceText
+        options.OutputMode = outputMode
+        options.PreserveTimes = *f.preserveTimes
+        options.PreserveOwner = *f.preserveOwner
+        options.UndoPatch = *f.undoPatch
+        options.TempDir = *f.tempDir
+        options.BackupDir = *f.backupDir
+        options.BackupName = *f.backupName
+        return options, nil
+}
+
+// readAllowRanges reads the ranges of "-allow-ranges @file" from a file,
+// one or more per line, with # starting a comment
+func readAllowRanges(path string) ([]charRange, error) {
+        data, err := os.ReadFile(path)
+        if err != nil {
+                return nil, fmt.Errorf("could not read allowed ranges: %w", err)
+        }
+        var items []string
+        for _, line := range strings.Split(string(data), "\n") {
+                line, _, _ = strings.Cut(line, "#")
+                items = append(items, strings.Split(line, ",")...)
+        }
+        return parseCharRanges(items, path+": ")
+}
+
+// provenanceText returns the text of the provenance comment for a run:
+// the version, the time and a hash of the engine options (optionDefaults),
+// so that two files cleaned the same way carry the same hash whatever the
+// run's file selection, output and report flags
+func (f *cleanFlags) provenanceText() string {
+        var set []string
+        f.fs.VisitAll(func(fl *flag.Flag) {
+                // A profile is already applied to the flags it sets
+                if _, ok := optionDefaults[fl.Name]; !ok || fl.Name == "profile" {
+                        return
+                }
+                if fl.Value.String() != fl.DefValue {
+                        set = append(set, fl.Name+"="+fl.Value.String())
+                }
+        })
+        sort.Strings(set)
+        sum := sha256.Sum256([]byte(strings.Join(set, "\n")))
+        return fmt.Sprintf("cleaned by cleanfile %s at %s (options %s)", version,
+                time.Now().UTC().Format(time.RFC3339), hex.EncodeToString(sum[:6]))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+        "flag"
+        "fmt"
+        "io"
+        "os"
+)
+
+// version is the version of cleanfile, set at build time with
+// -ldflags "-X main.version=1.4.0"
+var version = "dev"
+
+// subcommands lists the subcommands with a one-line description, in the
+// order they are shown by "cleanfile help"
+var subcommands = []struct {
+        Name, Args, Summary string
+}{
+        {"clean", "[flags] <file|directory>", "Remove invisible and unwanted characters and write the cleaned file"},
+        {"check", "[flags] <file|directory>", "Report what clean would change without writing anything; exit 1 if anything would"},
+        {"detect", "[flags] <file|directory>...", "Show the format, encoding, line endings and invisible characters of files"},
+        {"audit", "[flags] <file|directory>...", "List every invisible character with its offset, position, context and Unicode name; exit 1 if any"},
+        {"confusables", "[flags] <file|directory>...", "Index the confusable skeletons of the words of a corpus and list words that look the same; exit 1 if any"},
+        {"scan-bidi", "[flags] <file|directory>...", "Find bidirectional control characters (Trojan Source) in source files; exit 1 if any"},
+        {"patch", "[flags] [<diff>]", "Clean only the lines a unified diff adds and write the rewritten diff"},
+        {"report", "diff <old.json> <new.json>", "Compare two JSON reports"},
+        {"config", "validate [flags]", "Check config files and profiles for unknown keys, invalid values and contradictory options"},
+        {"equal", "[flags] <a> <b>", "Clean two files in memory and report whether they are then identical; exit 1 if not"},
+        {"daemon", "-queue <dir> -output <dir> -errors <dir> [flags]", "Watch a queue directory, clean arriving files by the profile of their subdirectory and move them to an output or error directory"},
+        {"serve", "[-addr :8080] [flags]", "Answer cleaning requests over HTTP and gRPC: POST text or a file to /clean and get the cleaned text and its report as JSON"},
+        {"undo", "[flags] <patch|directory>...", "Restore files from the undo patches that clean -undo-patch wrote"},
+        {"trends", "-history <file> [flags]", "Show hygiene trends from a history store"},
+        {"git-hook", "[flags]", "Clean or check the files staged for commit (for use as a pre-commit hook)"},
+}
+
+// printUsage prints the list of subcommands
+func printUsage(w io.Writer) {
+        fmt.Fprintln(w, "Usage: cleanfile <command> [flags] [arguments]")
+        fmt.Fprintln(w, "\nCommands:")
+        for _, c := range subcommands {
+                fmt.Fprintf(w, "   %-12s %s\n", c.Name, c.Summary)
+        }
+        fmt.Fprintln(w, "\nRun 'cleanfile <command> -h' for the flags of a command.")
+        fmt.Fprintln(w, "Without a command, the flags of 'clean' and -check are accepted as in earlier versions.")
+}
+
+// newSubcommandFlags creates the flag set of a subcommand, with a usage
+// message that names the subcommand and its arguments. The empty name is
+// the legacy invocation without a subcommand.
+func newSubcommandFlags(name string) *flag.FlagSet {
+        fs := flag.NewFlagSet("cleanfile "+name, flag.ExitOnError)
+        fs.Usage = func() {
+                w := fs.Output()
+                if name == "" {
+                        printUsage(w)
+                        fmt.Fprintln(w, "\nFlags:")
+                        fs.PrintDefaults()
+                        return
+                }
+                for _, c := range subcommands {
+                        if c.Name == name {
+                                fmt.Fprintf(w, "Usage: cleanfile %s %s\n\n%s\n", c.Name, c.Args, c.Summary)
+                        }
+                }
+                fmt.Fprintln(w, "\nFlags:")
+                fs.PrintDefaults()
+        }
+        return fs
+}
+
+// parseInterspersed parses args with fs, allowing flags to follow
+// positional arguments (as in "cleanfile clean notes.txt -in-place"), and
+// returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+        var positional []string
+        for {
+                fs.Parse(args)
+                if fs.NArg() == 0 {
+                        return positional
+                }
+                positional = append(positional, fs.Arg(0))
+                args = fs.Args()[1:]
+        }
+}
+
+// exitWithUsage reports a usage error of a subcommand
+func exitWithUsage(fs *flag.FlagSet, format string, args ...interface{}) {
+        fmt.Fprintf(os.Stderr, "Error: "+format+"\n\n", args...)
+        fs.Usage()
+        os.Exit(2)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+        "fmt"
+        "os"
+        "strings"
+)
+
+// ANSI escape sequences used by the report
+const (
+        ansiReset  = "\033[0m"
+        ansiBold   = "\033[1m"
+        ansiRed    = "\033[31m"
+        ansiGreen  = "\033[32m"
+        ansiYellow = "\033[33m"
+        ansiCyan   = "\033[36m"
+)
+
+// useColor is set once at startup by setupColor
+var useColor bool
+
+// setupColor decides whether output is colorized. "auto" enables color only
+// when stdout is a terminal and neither NO_COLOR nor TERM=dumb is set;
+// "always" overrides NO_COLOR, as recommended by https://no-color.org.
+func setupColor(mode string) error {
+        switch strings.ToLower(strings.TrimSpace(mode)) {
+        case "always":
+                useColor = true
+        case "never":
+                useColor = false
+        case "", "auto":
+                useColor = os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(os.Stdout)
+        default:
+                return fmt.Errorf("invalid color mode '%s'. Valid options: auto, always, never", mode)
+        }
+        return nil
+}
+
+func isTerminal(f *os.File) bool {
+        info, err := f.Stat()
+        if err != nil {
+                return false
+        }
+        return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps text in the given ANSI codes when color output is enabled
+func colorize(text string, codes ...string) string {
+        if !useColor || len(codes) == 0 {
+                return text
+        }
+        return strings.Join(codes, "") + text + ansiReset
+}
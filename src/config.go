@@ -0,0 +1,490 @@
+package main
+
+import (
+        "flag"
+        "fmt"
+        "os"
+        "path/filepath"
+        "sort"
+        "strconv"
+        "strings"
+)
+
+// projectConfigName is looked up in the working directory and its parents
+const projectConfigName = ".cleanfile.yaml"
+
+// Config holds default flag values and named profiles of flag values read
+// from config files. Keys are flag names without the leading dash.
+type Config struct {
+        Defaults map[string]string
+        Profiles map[string]map[string]string
+}
+
+func newConfig() *Config {
+        return &Config{
+                Defaults: make(map[string]string),
+                Profiles: make(map[string]map[string]string),
+        }
+}
+
+// merge overlays other on top of c; profiles with the same name are merged
+// key by key
+func (c *Config) merge(other *Config) {
+        for k, v := range other.Defaults {
+                c.Defaults[k] = v
+        }
+        for name, values := range other.Profiles {
+                if c.Profiles[name] == nil {
+                        c.Profiles[name] = make(map[string]string)
+                }
+                for k, v := range values {
+                        c.Profiles[name][k] = v
+                }
+        }
+}
+
+func (c *Config) profileNames() []string {
+        names := make([]string, 0, len(c.Profiles))
+        for name := range c.Profiles {
+                names = append(names, name)
+        }
+        sort.Strings(names)
+        return names
+}
+
+// userConfigPath returns ~/.config/cleanfile/config.yaml, honoring
+// XDG_CONFIG_HOME
+func userConfigPath() string {
+        if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+                return filepath.Join(dir, "cleanfile", "config.yaml")
+        }
+        home, err := os.UserHomeDir()
+        if err != nil {
+                return ""
+        }
+        return filepath.Join(home, ".config", "cleanfile", "config.yaml")
+}
+
+// findProjectConfig looks for .cleanfile.yaml in dir and its parents
+func findProjectConfig(dir string) string {
+        for {
+                path := filepath.Join(dir, projectConfigName)
+                if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+                        return path
+                }
+                parent := filepath.Dir(dir)
+                if parent == dir {
+                        return ""
+                }
+                dir = parent
+        }
+}
+
+// configPaths lists the config files that apply, lowest precedence first:
+// the user config, then either the explicit -config file or the nearest
+// project config
+func configPaths(explicit string) []string {
+        var paths []string
+        if user := userConfigPath(); user != "" {
+                if _, err := os.Stat(user); err == nil {
+                        paths = append(paths, user)
+                }
+        }
+
+        if explicit != "" {
+                return append(paths, explicit)
+        }
+        if cwd, err := os.Getwd(); err == nil {
+                if project := findProjectConfig(cwd); project != "" {
+                        paths = append(paths, project)
+                }
+        }
+        return paths
+}
+
+// applyCleaningMode sets the options of the mode selected with -mode that
+// were not set on the command line or by a config file
+func applyCleaningMode(fs *flag.FlagSet, mode string) error {
+        return applyBundle(fs, "mode", mode, cleaningModes, cleaningModeNames)
+}
+
+// applyPreset sets the options of the preset selected with -preset in the
+// same way; it takes precedence over -mode
+func applyPreset(fs *flag.FlagSet, preset string) error {
+        return applyBundle(fs, "preset", preset, securityPresets, securityProfiles)
+}
+
+// applyBundle sets the options of the bundle that the flag name selects
+// that were not set on the command line or by a config file
+func applyBundle(fs *flag.FlagSet, name, value string, bundles map[string]map[string]string, names []string) error {
+        value = strings.ToLower(strings.TrimSpace(value))
+        if value == "" {
+                return nil
+        }
+        values, ok := bundles[value]
+        if !ok {
+                return fmt.Errorf("invalid %s '%s'. Valid options: %s", name, value, strings.Join(names, ", "))
+        }
+        fs.Set(name, value)
+
+        explicit := explicitFlags(fs)
+        for _, key := range sortedKeys(values) {
+                if explicit[key] {
+                        continue
+                }
+                if err := fs.Set(key, values[key]); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// loadConfig reads and merges the given config files on top of the
+// built-in profiles
+func loadConfig(paths []string) (*Config, error) {
+        cfg := newConfig()
+        cfg.merge(&Config{Profiles: builtinProfiles})
+        for _, path := range paths {
+                data, err := os.ReadFile(path)
+                if err != nil {
+                        return nil, fmt.Errorf("could not read config file: %w", err)
+                }
+                fileCfg, err := parseConfig(data)
+                if err != nil {
+                        return nil, fmt.Errorf("%s: %w", path, err)
+                }
+                cfg.merge(fileCfg)
+        }
+        return cfg, nil
+}
+
+// values returns the config defaults overlaid with the values of profile,
+// if one is given
+func (c *Config) values(profile string) (map[string]string, error) {
+        values := make(map[string]string)
+        for k, v := range c.Defaults {
+                values[k] = v
+        }
+        if profile != "" {
+                profileValues, ok := c.Profiles[profile]
+                if !ok {
+                        available := "none"
+                        if names := c.profileNames(); len(names) > 0 {
+                                available = strings.Join(names, ", ")
+                        }
+                        return nil, fmt.Errorf("unknown profile '%s' (available: %s)", profile, available)
+                }
+                for k, v := range profileValues {
+                        values[k] = v
+                }
+        }
+        return values, nil
+}
+
+// applyConfig sets every flag named in the config defaults and the selected
+// profile, unless it was given explicitly on the command line. Keys naming a
+// flag in other, which holds the flags the current subcommand does not
+// accept, are skipped so one config file can serve every subcommand.
+func applyConfig(fs, other *flag.FlagSet, cfg *Config, profile string) error {
+        values, err := cfg.values(profile)
+        if err != nil {
+                return err
+        }
+
+        explicit := explicitFlags(fs)
+        for _, key := range sortedKeys(values) {
+                if explicit[key] && key != "config" && key != "profile" {
+                        continue
+                }
+                if err := setConfigValue(fs, other, key, values[key]); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// setConfigValue sets the flag named by a config key, or does nothing if
+// the flag is in other
+func setConfigValue(fs, other *flag.FlagSet, key, value string) error {
+        if key == "config" || key == "profile" {
+                return fmt.Errorf("unknown config key '%s'", key)
+        }
+        if fs.Lookup(key) == nil {
+                if other != nil && other.Lookup(key) != nil {
+                        return nil
+                }
+                return fmt.Errorf("unknown config key '%s'%s", key, suggestFlag(fs, key))
+        }
+        if err := fs.Set(key, value); err != nil {
+                return fmt.Errorf("invalid value for config key '%s': %v", key, err)
+        }
+        return nil
+}
+
+// suggestFlag returns " (did you mean 'name'?)" if key is a flag name
+// written with underscores, capitals or a leading dash
+func suggestFlag(fs *flag.FlagSet, key string) string {
+        name := strings.ToLower(strings.ReplaceAll(strings.TrimLeft(key, "-"), "_", "-"))
+        if name != key && fs.Lookup(name) != nil && name != "config" && name != "profile" {
+                return fmt.Sprintf(" (did you mean '%s'?)", name)
+        }
+        return ""
+}
+
+// explicitFlags returns the names of the flags set on the command line
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+        explicit := make(map[string]bool)
+        fs.Visit(func(f *flag.Flag) {
+                explicit[f.Name] = true
+        })
+        return explicit
+}
+
+// parseConfig parses a config file. The format is the YAML subset needed for
+// flat settings: nested mappings, plain, single- or double-quoted scalars,
+// and block ("- item") or flow ("[a, b]") lists, which are joined with commas
+// to match list-valued flags such as -include.
+func parseConfig(data []byte) (*Config, error) {
+        lines, err := yamlLines(string(data))
+        if err != nil {
+                return nil, err
+        }
+        root, next, err := parseYAMLBlock(lines, 0, 0)
+        if err != nil {
+                return nil, err
+        }
+        if next < len(lines) {
+                return nil, fmt.Errorf("line %d: unexpected indentation", lines[next].num)
+        }
+
+        cfg := newConfig()
+        top, ok := root.(map[string]interface{})
+        if !ok {
+                if root == nil {
+                        return cfg, nil
+                }
+                return nil, fmt.Errorf("expected a mapping at the top level")
+        }
+
+        for key, value := range top {
+                switch key {
+                case "defaults":
+                        values, err := flagValues(key, value)
+                        if err != nil {
+                                return nil, err
+                        }
+                        cfg.Defaults = values
+                case "profiles":
+                        profiles, ok := value.(map[string]interface{})
+                        if !ok {
+                                return nil, fmt.Errorf("'profiles' must be a mapping of profile names")
+                        }
+                        for name, profileValue := range profiles {
+                                values, err := flagValues("profiles."+name, profileValue)
+                                if err != nil {
+                                        return nil, err
+                                }
+                                cfg.Profiles[name] = values
+                        }
+                default:
+                        return nil, fmt.Errorf("unknown top-level key '%s' (expected defaults or profiles)", key)
+                }
+        }
+        return cfg, nil
+}
+
+// flagValues converts a parsed mapping into flag name/value strings
+func flagValues(section string, value interface{}) (map[string]string, error) {
+        values := make(map[string]string)
+        if value == nil {
+                return values, nil
+        }
+        m, ok := value.(map[string]interface{})
+        if !ok {
+                return nil, fmt.Errorf("'%s' must be a mapping of option names", section)
+        }
+        for k, v := range m {
+                switch v := v.(type) {
+                case nil:
+                        values[k] = ""
+                case string:
+                        values[k] = v
+                case []string:
+                        values[k] = strings.Join(v, ",")
+                default:
+                        return nil, fmt.Errorf("'%s.%s' must be a value or a list", section, k)
+                }
+        }
+        return values, nil
+}
+
+type yamlLine struct {
+        num    int
+        indent int
+        text   string
+}
+
+// yamlLines drops blank lines and comments and measures indentation
+func yamlLines(data string) ([]yamlLine, error) {
+        var lines []yamlLine
+        for i, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+                text := strings.TrimRight(stripYAMLComment(raw), " \t")
+                trimmed := strings.TrimLeft(text, " ")
+                if trimmed == "" {
+                        continue
+                }
+                if strings.HasPrefix(trimmed, "\t") {
+                        return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+                }
+                lines = append(lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+        }
+        return lines, nil
+}
+
+// stripYAMLComment removes a trailing "# comment" outside of quotes
+func stripYAMLComment(line string) string {
+        var quote rune
+        for i, r := range line {
+                switch {
+                case quote != 0:
+                        if r == quote {
+                                quote = 0
+                        }
+                case r == '"' || r == '\'':
+                        quote = r
+                case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+                        return line[:i]
+                }
+        }
+        return line
+}
+
+// parseYAMLBlock parses the mapping or list starting at lines[start] whose
+// entries are indented by exactly indent spaces
+func parseYAMLBlock(lines []yamlLine, start, indent int) (interface{}, int, error) {
+        if start >= len(lines) {
+                return nil, start, nil
+        }
+
+        if strings.HasPrefix(lines[start].text, "- ") || lines[start].text == "-" {
+                var items []string
+                i := start
+                for ; i < len(lines) && lines[i].indent == indent; i++ {
+                        line := lines[i]
+                        if !strings.HasPrefix(line.text, "-") {
+                                return nil, i, fmt.Errorf("line %d: expected a list item", line.num)
+                        }
+                        item, err := parseYAMLScalar(strings.TrimSpace(strings.TrimPrefix(line.text, "-")))
+                        if err != nil {
+                                return nil, i, fmt.Errorf("line %d: %v", line.num, err)
+                        }
+                        items = append(items, item)
+                }
+                return items, i, nil
+        }
+
+        m := make(map[string]interface{})
+        i := start
+        for i < len(lines) && lines[i].indent == indent {
+                line := lines[i]
+                colon := strings.Index(line.text, ":")
+                if colon <= 0 || (colon+1 < len(line.text) && line.text[colon+1] != ' ') {
+                        return nil, i, fmt.Errorf("line %d: expected 'key: value'", line.num)
+                }
+                key, err := parseYAMLScalar(strings.TrimSpace(line.text[:colon]))
+                if err != nil {
+                        return nil, i, fmt.Errorf("line %d: %v", line.num, err)
+                }
+                if _, dup := m[key]; dup {
+                        return nil, i, fmt.Errorf("line %d: duplicate key '%s'", line.num, key)
+                }
+                rest := strings.TrimSpace(line.text[colon+1:])
+                i++
+
+                switch {
+                case rest != "" && strings.HasPrefix(rest, "["):
+                        list, err := parseYAMLFlowList(rest)
+                        if err != nil {
+                                return nil, i, fmt.Errorf("line %d: %v", line.num, err)
+                        }
+                        m[key] = list
+                case rest != "":
+                        value, err := parseYAMLScalar(rest)
+                        if err != nil {
+                                return nil, i, fmt.Errorf("line %d: %v", line.num, err)
+                        }
+                        m[key] = value
+                case i < len(lines) && lines[i].indent > indent:
+                        value, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+                        if err != nil {
+                                return nil, next, err
+                        }
+                        m[key] = value
+                        i = next
+                default:
+                        m[key] = nil
+                }
+        }
+        if i < len(lines) && lines[i].indent > indent {
+                return nil, i, fmt.Errorf("line %d: unexpected indentation", lines[i].num)
+        }
+        return m, i, nil
+}
+
+func parseYAMLFlowList(text string) ([]string, error) {
+        if !strings.HasSuffix(text, "]") {
+                return nil, fmt.Errorf("unterminated list")
+        }
+        inner := strings.TrimSpace(text[1 : len(text)-1])
+        if inner == "" {
+                return []string{}, nil
+        }
+
+        var items []string
+        var current strings.Builder
+        var quote rune
+        for _, r := range inner {
+                switch {
+                case quote != 0:
+                        if r == quote {
+                                quote = 0
+                        }
+                        current.WriteRune(r)
+                case r == '"' || r == '\'':
+                        quote = r
+                        current.WriteRune(r)
+                case r == ',':
+                        item, err := parseYAMLScalar(strings.TrimSpace(current.String()))
+                        if err != nil {
+                                return nil, err
+                        }
+                        items = append(items, item)
+                        current.Reset()
+                default:
+                        current.WriteRune(r)
+                }
+        }
+        item, err := parseYAMLScalar(strings.TrimSpace(current.String()))
+        if err != nil {
+                return nil, err
+        }
+        return append(items, item), nil
+}
+
+func parseYAMLScalar(text string) (string, error) {
+        switch {
+        case len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"':
+                value, err := strconv.Unquote(text)
+                if err != nil {
+                        return "", fmt.Errorf("invalid quoted string %s", text)
+                }
+                return value, nil
+        case len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'':
+                return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+        case text == "~" || text == "null":
+                return "", nil
+        case strings.HasPrefix(text, "{") || strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*") || strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+                return "", fmt.Errorf("unsupported YAML syntax '%s'", text)
+        }
+        return text, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+        "flag"
+        "fmt"
+        "os"
+        "path/filepath"
+        "strings"
+)
+
+// runConfig implements the config subcommand
+func runConfig(args []string) int {
+        if len(args) == 0 || args[0] != "validate" {
+                fmt.Println("Usage: cleanfile config validate [-config file] [-profile name]")
+                return 1
+        }
+
+        fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+        configFile := fs.String("config", "", "Config file to check instead of the nearest "+projectConfigName)
+        profile := fs.String("profile", "", "Only check this profile (default: the defaults and every profile)")
+        colorMode := fs.String("color", "auto", "Colorize the output: auto, always, never (NO_COLOR disables auto)")
+        fs.Parse(args[1:])
+        if fs.NArg() > 0 {
+                fmt.Println("Usage: cleanfile config validate [-config file] [-profile name]")
+                return 1
+        }
+        if err := setupColor(*colorMode); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        paths := configPaths(*configFile)
+        cfg, err := loadConfig(paths)
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        // The defaults alone, then each profile on top of them
+        scopes := append([]string{""}, cfg.profileNames()...)
+        if *profile != "" {
+                scopes = []string{*profile}
+        }
+
+        fmt.Println("Config files:")
+        if len(paths) == 0 {
+                fmt.Println("   (none, only the built-in profiles)")
+        }
+        for _, path := range paths {
+                fmt.Printf("   %s\n", path)
+        }
+        fmt.Println()
+
+        // A problem of the defaults is reported once, not again for every
+        // profile that inherits it
+        inherited := make(map[string]bool)
+        problems := 0
+        for _, scope := range scopes {
+                name := "defaults"
+                if scope != "" {
+                        name = fmt.Sprintf("profile '%s'", scope)
+                }
+                var found []string
+                checked, warnings := checkConfigScope(cfg, scope)
+                for _, problem := range checked {
+                        if !inherited[problem] {
+                                found = append(found, problem)
+                        }
+                        if scope == "" {
+                                inherited[problem] = true
+                        }
+                }
+                for _, warning := range warnings {
+                        if !inherited[warning] {
+                                fmt.Printf("%s: %s %s\n", name, colorize("warning:", ansiYellow), warning)
+                        }
+                        if scope == "" {
+                                inherited[warning] = true
+                        }
+                }
+                if len(found) == 0 {
+                        fmt.Printf("%s: %s\n", name, colorize("OK", ansiGreen))
+                        continue
+                }
+                for _, problem := range found {
+                        fmt.Printf("%s: %s\n", name, colorize(problem, ansiRed))
+                }
+                problems += len(found)
+        }
+
+        if problems > 0 {
+                fmt.Printf("\n%d problem(s) found\n", problems)
+                return 1
+        }
+        return 0
+}
+
+// checkConfigScope returns the problems clean would run into with the
+// config defaults and the given profile (none if empty): unknown keys,
+// invalid values, contradictory options and referenced files that do not
+// exist, and separately the options that would have no effect. Unlike a
+// run, it goes on after an unknown key or invalid value, which leaves its
+// flag at the default.
+func checkConfigScope(cfg *Config, profile string) (problems, warnings []string) {
+        values, err := cfg.values(profile)
+        if err != nil {
+                return []string{err.Error()}, nil
+        }
+
+        f := newCleanFlags("")
+        for _, key := range sortedKeys(values) {
+                if err := setConfigValue(f.fs, nil, key, values[key]); err != nil {
+                        problems = append(problems, err.Error())
+                }
+        }
+
+        if err := applyPreset(f.fs, *f.preset); err != nil {
+                problems = append(problems, err.Error())
+        }
+        if err := applyCleaningMode(f.fs, *f.cleaningMode); err != nil {
+                problems = append(problems, err.Error())
+        }
+        if _, err := f.options(); err != nil {
+                problems = append(problems, err.Error())
+        }
+        files := []struct{ key, path string }{{"history", *f.historyFile}, {"report-file", *f.reportFile}, {"stream", *f.stream}}
+        for _, file := range files {
+                if file.path == "" || file.key == "stream" && strings.HasPrefix(file.path, "fd:") {
+                        continue
+                }
+                if info, err := os.Stat(filepath.Dir(file.path)); err != nil || !info.IsDir() {
+                        problems = append(problems, fmt.Sprintf("directory of %s '%s' does not exist", file.key, file.path))
+                }
+        }
+        return problems, f.warnings()
+}
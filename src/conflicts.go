@@ -0,0 +1,205 @@
+package main
+
+import (
+        "fmt"
+        "strings"
+)
+
+// A term names a flag and matches when the flag is set to anything but its
+// default ("strip"), or to one of the listed values ("report=sarif|lint",
+// "preserve-newlines=false"). Flags a mode does not accept never match.
+
+// flagConflicts lists the combinations of options that cannot be used
+// together, because one would undo or contradict the other
+var flagConflicts = []struct {
+        terms  []string
+        reason string
+}{
+        {[]string{"check", "output"}, "check mode writes nothing"},
+        {[]string{"check", "in-place"}, "check mode writes nothing"},
+        {[]string{"in-place", "output"}, ""},
+        {[]string{"recursive", "output"}, "each file is written next to its input, or under -output-dir"},
+        {[]string{"output-dir", "output"}, ""},
+        {[]string{"output-dir", "in-place"}, ""},
+        {[]string{"output-dir", "check"}, "check mode writes nothing"},
+        {[]string{"mode=aggressive", "tsv"}, "it could add, remove or move tabs and line breaks"},
+        {[]string{"mode=aggressive", "report=sarif|lint|rdjson|rdjsonl"}, "columns would count the transformed text"},
+        {[]string{"changed-only", "strip"}, "stripping changes the line structure"},
+        {[]string{"csv-safe", "strip"}, ""},
+        {[]string{"csv-safe", "changed-only"}, ""},
+        {[]string{"tsv", "strip"}, "it could add, remove or move tabs and line breaks"},
+        {[]string{"tsv", "changed-only"}, "it could add, remove or move tabs and line breaks"},
+        {[]string{"tsv", "preserve-lines"}, "it could add, remove or move tabs and line breaks"},
+        {[]string{"tsv", "normalize"}, "it could add, remove or move tabs and line breaks"},
+        {[]string{"tsv", "trim-trailing"}, "it could add, remove or move tabs and line breaks"},
+        {[]string{"key-value", "strip"}, ""},
+        {[]string{"key-value", "csv-safe"}, ""},
+        {[]string{"key-value", "tsv"}, ""},
+        {[]string{"key-value", "normalize", "preserve-newlines=false"}, "values would run into the next key"},
+        {[]string{"po", "key-value"}, ""},
+        {[]string{"po", "strip"}, ""},
+        {[]string{"po", "csv-safe"}, ""},
+        {[]string{"po", "tsv"}, ""},
+        {[]string{"po", "normalize", "preserve-newlines=false"}, "translations would run into the next entry"},
+        {[]string{"chat", "key-value"}, ""},
+        {[]string{"chat", "po"}, ""},
+        {[]string{"chat", "strip"}, ""},
+        {[]string{"chat", "csv-safe"}, ""},
+        {[]string{"chat", "tsv"}, ""},
+        {[]string{"chat", "normalize", "preserve-newlines=false"}, "messages would run into each other"},
+        {[]string{"drop-reactions", "preserve-lines"}, "dropped lines change the line count"},
+        {[]string{"preserve-lines", "strip"}, ""},
+        {[]string{"preserve-lines", "normalize", "preserve-newlines=false"}, ""},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "strip"}, "locations would point into the stripped text, not the file"},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "emoji=unicode"}, "columns would count the converted text"},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "normalize-unicode"}, "columns would count the normalized text"},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "decode-escapes"}, "columns would count the decoded text"},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "smart-punct"}, "columns would count the converted text"},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "fix-mojibake"}, "columns would count the repaired text"},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "transliterate"}, "columns would count the transliterated text"},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "invalid-utf8=remove"}, "columns would count the remaining text"},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "csv-safe"}, ""},
+        {[]string{"report=sarif|lint|rdjson|rdjsonl", "tsv"}, ""},
+        {[]string{"report-template", "report=json|csv|sarif|lint|rdjson|rdjsonl"}, "the template writes the report"},
+        {[]string{"remove-emoji", "emoji=shortcode|unicode"}, "-remove-emoji is short for -emoji remove"},
+        {[]string{"emoji=unicode", "ascii=true"}, "the emoji would be removed again as non-ASCII; add -ascii=false"},
+        {[]string{"trace", "recursive"}, "the trace covers the lines of a single file"},
+        {[]string{"provenance", "preserve-lines"}, "the comment adds a line"},
+        {[]string{"provenance", "check"}, "check mode writes nothing"},
+        {[]string{"output-mode", "check"}, "check mode writes nothing"},
+        {[]string{"preserve-times", "check"}, "check mode writes nothing"},
+        {[]string{"preserve-owner", "check"}, "check mode writes nothing"},
+        {[]string{"undo-patch", "check"}, "check mode writes nothing"},
+        {[]string{"quarantine", "check"}, "check mode removes nothing"},
+        {[]string{"verify", "check"}, "check mode writes nothing"},
+        {[]string{"cache", "changed-only"}, "which lines are cleaned changes with every commit"},
+        {[]string{"cache", "duplicates"}, "files skipped as clean are not read"},
+        {[]string{"strict", "invalid-utf8=remove|keep"}, "-strict fails on invalid UTF-8"},
+}
+
+// flagRequirements lists options that mean nothing on their own: each is
+// rejected, or with warn only reported, unless one of the terms in needs
+// matches
+var flagRequirements = []struct {
+        flag  string
+        needs []string
+        warn  bool
+}{
+        {"drop-reactions", []string{"chat"}, false},
+        {"decode-escapes", []string{"key-value"}, false},
+        {"fix-quotes", []string{"normalize-quotes", "smart-punct"}, false},
+        {"strip-keep", []string{"strip"}, false},
+        {"strip-extract", []string{"strip=html|auto"}, false},
+        {"keep-letters", []string{"ascii=true"}, true},
+        {"tsv-newline", []string{"tsv"}, true},
+        {"csv-delimiter", []string{"csv-safe", "tsv"}, true},
+        {"preserve-newlines", []string{"normalize"}, true},
+        {"tmpdir", []string{"in-place"}, true},
+        {"output-dir", []string{"recursive"}, false},
+        {"backup-dir", []string{"backup=true"}, true},
+        {"backup-name", []string{"backup=true"}, true},
+}
+
+// optionLookup returns the value of an option, its default and whether it
+// is a boolean, or ok false for an option that is not accepted, as for the
+// flags of a mode or the options of newCleaningOptions
+type optionLookup func(name string) (value, def string, isBool, ok bool)
+
+// checkConflicts returns an error naming the first two (or three) options
+// that cannot be used together, or an option that requires another one
+func checkConflicts(lookup optionLookup) error {
+        for _, conflict := range flagConflicts {
+                var set []string
+                for _, term := range conflict.terms {
+                        if !lookup.matches(term) {
+                                break
+                        }
+                        set = append(set, lookup.describe(term))
+                }
+                if len(set) < len(conflict.terms) {
+                        continue
+                }
+                message := fmt.Sprintf("%s cannot be used with %s", set[0], strings.Join(set[1:], " and "))
+                if conflict.reason != "" {
+                        message += " (" + conflict.reason + ")"
+                }
+                return fmt.Errorf("%s", message)
+        }
+        for _, req := range flagRequirements {
+                if !req.warn && lookup.matches(req.flag) && !lookup.matchesAny(req.needs) {
+                        return fmt.Errorf("%s requires %s", lookup.describe(req.flag), describeNeeds(req.needs))
+                }
+        }
+        return nil
+}
+
+// conflictWarnings lists the options that are set but have no effect
+func conflictWarnings(lookup optionLookup) []string {
+        var warnings []string
+        for _, req := range flagRequirements {
+                if req.warn && lookup.matches(req.flag) && !lookup.matchesAny(req.needs) {
+                        warnings = append(warnings, fmt.Sprintf("%s has no effect without %s", lookup.describe(req.flag), describeNeeds(req.needs)))
+                }
+        }
+        return warnings
+}
+
+// matches reports whether a term matches the current option values
+func (lookup optionLookup) matches(term string) bool {
+        name, values, hasValues := strings.Cut(term, "=")
+        value, def, _, ok := lookup(name)
+        if !ok {
+                return false
+        }
+        value = strings.TrimSpace(value)
+        if !hasValues {
+                return value != def
+        }
+        for _, v := range strings.Split(values, "|") {
+                if strings.EqualFold(value, v) {
+                        return true
+                }
+        }
+        return false
+}
+
+func (lookup optionLookup) matchesAny(terms []string) bool {
+        for _, term := range terms {
+                if lookup.matches(term) {
+                        return true
+                }
+        }
+        return false
+}
+
+// describe writes the option of a term as it was set: -chat,
+// -preserve-newlines=false or -report sarif
+func (lookup optionLookup) describe(term string) string {
+        name, _, _ := strings.Cut(term, "=")
+        value, _, isBool, _ := lookup(name)
+        if isBool {
+                if value == "true" {
+                        return "-" + name
+                }
+                return "-" + name + "=" + value
+        }
+        return fmt.Sprintf("-%s %s", name, value)
+}
+
+// describeNeeds writes the terms an option requires, such as "-csv-safe or
+// -tsv" or "-strip html or auto"
+func describeNeeds(needs []string) string {
+        parts := make([]string, len(needs))
+        for i, term := range needs {
+                name, values, hasValues := strings.Cut(term, "=")
+                switch {
+                case !hasValues:
+                        parts[i] = "-" + name
+                case values == "true":
+                        parts[i] = "-" + name
+                default:
+                        parts[i] = "-" + name + " " + strings.ReplaceAll(values, "|", " or ")
+                }
+        }
+        return strings.Join(parts, " or ")
+}
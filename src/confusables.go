@@ -0,0 +1,278 @@
+package main
+
+import (
+        "bufio"
+        "encoding/json"
+        "fmt"
+        "io"
+        "os"
+        "sort"
+        "strings"
+)
+
+// WordLocation is where a word of the confusables index occurs
+type WordLocation struct {
+        Path   string `json:"path"`
+        Line   int    `json:"line"`
+        Column int    `json:"column"`
+}
+
+// IndexedWord is one spelling of a skeleton in the confusables index
+type IndexedWord struct {
+        Word        string         `json:"word"`
+        Codepoints  []string       `json:"codepoints"` // of the characters outside ASCII
+        Scripts     []string       `json:"scripts"`
+        Occurrences int            `json:"occurrences"`
+        Locations   []WordLocation `json:"locations"` // the first maxWordLocations
+        order       int
+}
+
+// SkeletonEntry is a skeleton of the confusables index with the words that
+// reduce to it; two or more words make a confusable group
+type SkeletonEntry struct {
+        Skeleton string         `json:"skeleton"`
+        Words    []*IndexedWord `json:"words"`
+}
+
+// ConfusablesReport is the JSON report of the confusables command
+type ConfusablesReport struct {
+        Files     int              `json:"files"`
+        Words     int              `json:"words"` // distinct words indexed
+        Truncated bool             `json:"truncated,omitempty"`
+        Groups    int              `json:"groups"`
+        Entries   []*SkeletonEntry `json:"entries"`
+}
+
+// Locations kept per word, and distinct words indexed per run
+const (
+        maxWordLocations = 10
+        maxIndexedWords  = 1000000
+)
+
+// confusablesIndex maps the skeletons of the words of a corpus to their
+// spellings
+type confusablesIndex struct {
+        entries   map[string]*SkeletonEntry
+        words     map[string]*IndexedWord
+        truncated bool
+}
+
+func newConfusablesIndex() *confusablesIndex {
+        return &confusablesIndex{entries: make(map[string]*SkeletonEntry), words: make(map[string]*IndexedWord)}
+}
+
+// isIdentifierChar reports whether r belongs to a word of the index: a
+// letter, digit or mark, or the underscore of identifiers such as user_name
+func isIdentifierChar(r rune) bool {
+        return r == '_' || isWordChar(r)
+}
+
+// add records an occurrence of a word. Its skeleton is taken after NFKC,
+// so that fullwidth and mathematical letters meet their Latin letters.
+func (x *confusablesIndex) add(word string, loc WordLocation) {
+        if w, ok := x.words[word]; ok {
+                w.Occurrences++
+                if len(w.Locations) < maxWordLocations {
+                        w.Locations = append(w.Locations, loc)
+                }
+                return
+        }
+        if len(x.words) >= maxIndexedWords {
+                x.truncated = true
+                return
+        }
+
+        w := &IndexedWord{
+                Word:        word,
+                Codepoints:  []string{},
+                Scripts:     wordScripts([]rune(word)),
+                Occurrences: 1,
+                Locations:   []WordLocation{loc},
+                order:       len(x.words),
+        }
+        if w.Scripts == nil {
+                w.Scripts = []string{}
+        }
+        for _, r := range word {
+                if r >= 0x80 {
+                        w.Codepoints = append(w.Codepoints, fmt.Sprintf("U+%04X", r))
+                }
+        }
+        x.words[word] = w
+
+        normalized, _ := normalizeUnicode(word, "nfkc")
+        key := skeleton(normalized, true)
+        entry, ok := x.entries[key]
+        if !ok {
+                entry = &SkeletonEntry{Skeleton: key}
+                x.entries[key] = entry
+        }
+        entry.Words = append(entry.Words, w)
+}
+
+// indexFile adds the words of a file to the index. Files that do not look
+// like UTF-8 text are skipped.
+func (x *confusablesIndex) indexFile(path string) error {
+        f, err := os.Open(path)
+        if err != nil {
+                return err
+        }
+        defer f.Close()
+
+        reader := bufio.NewReaderSize(f, detectSampleSize)
+        prefix, err := reader.Peek(detectSampleSize)
+        if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+                return err
+        }
+        if encoding, _ := detectEncoding(prefix); !strings.HasPrefix(encoding, "UTF-8") {
+                return nil
+        }
+
+        lines := newLineReader(reader)
+        for lineNum := 1; ; lineNum++ {
+                line, _, err := lines.readLine()
+                if err == io.EOF {
+                        return nil
+                }
+                if err != nil {
+                        return err
+                }
+
+                runes := []rune(line)
+                for start := 0; start < len(runes); {
+                        if !isIdentifierChar(runes[start]) {
+                                start++
+                                continue
+                        }
+                        end := start + 1
+                        for end < len(runes) && isIdentifierChar(runes[end]) {
+                                end++
+                        }
+                        if end-start > 1 {
+                                x.add(string(runes[start:end]), WordLocation{Path: path, Line: lineNum, Column: start + 1})
+                        }
+                        start = end
+                }
+        }
+}
+
+// groups returns the skeletons that two or more words reduce to, sorted by
+// skeleton, with their words in the order they were first seen. Unless
+// asciiToo is set, groups of ASCII words only, such as "list" and "Iist",
+// are left out, since code is full of them.
+func (x *confusablesIndex) groups(all, asciiToo bool) []*SkeletonEntry {
+        entries := []*SkeletonEntry{}
+        for _, entry := range x.entries {
+                if !all && !isConfusableGroup(entry.Words, asciiToo) {
+                        continue
+                }
+                sort.Slice(entry.Words, func(i, j int) bool { return entry.Words[i].order < entry.Words[j].order })
+                entries = append(entries, entry)
+        }
+        sort.Slice(entries, func(i, j int) bool { return entries[i].Skeleton < entries[j].Skeleton })
+        return entries
+}
+
+func isConfusableGroup(words []*IndexedWord, asciiToo bool) bool {
+        if len(words) < 2 {
+                return false
+        }
+        for _, w := range words {
+                if !isASCII(w.Word) {
+                        return true
+                }
+        }
+        return asciiToo
+}
+
+// runConfusables implements the confusables subcommand
+func runConfusables(args []string) int {
+        fs := newSubcommandFlags("confusables")
+        recursive := fs.Bool("recursive", false, "Index every file in the given directory trees")
+        include := fs.String("include", "", "Comma-separated glob patterns of files to index in recursive mode")
+        exclude := fs.String("exclude", "", "Comma-separated glob patterns of files or directories to skip in recursive mode")
+        reportFormat := fs.String("report", "text", "Report format: text or json")
+        all := fs.Bool("all", false, "Export every skeleton of the index, not only those of confusable words")
+        asciiToo := fs.Bool("ascii", false, "Also report groups of ASCII words only, such as 'list' and 'Iist'")
+        colorMode := fs.String("color", "auto", "Colorize the report: auto, always, never (NO_COLOR disables auto)")
+        paths := parseInterspersed(fs, args)
+
+        if len(paths) == 0 {
+                exitWithUsage(fs, "at least one file or directory is required")
+        }
+        if *reportFormat != "text" && *reportFormat != "json" {
+                exitWithUsage(fs, "invalid report format '%s' (valid options: text, json)", *reportFormat)
+        }
+        if err := setupColor(*colorMode); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        batch := BatchOptions{Include: splitPatterns(*include), Exclude: splitPatterns(*exclude)}
+        files, err := expandPaths(paths, *recursive, batch, "index")
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        index := newConfusablesIndex()
+        for _, path := range files {
+                if err := index.indexFile(path); err != nil {
+                        fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+                        return 1
+                }
+        }
+
+        report := ConfusablesReport{
+                Files:     len(files),
+                Words:     len(index.words),
+                Truncated: index.truncated,
+                Entries:   index.groups(*all, *asciiToo),
+        }
+        for _, entry := range report.Entries {
+                if isConfusableGroup(entry.Words, *asciiToo) {
+                        report.Groups++
+                }
+        }
+
+        if *reportFormat == "json" {
+                encoder := json.NewEncoder(os.Stdout)
+                encoder.SetIndent("", "  ")
+                encoder.SetEscapeHTML(false)
+                if err := encoder.Encode(report); err != nil {
+                        fmt.Printf("Error: could not write JSON report: %v\n", err)
+                        return 1
+                }
+        } else {
+                printConfusables(os.Stdout, report)
+        }
+
+        if report.Groups > 0 {
+                return 1
+        }
+        return 0
+}
+
+func printConfusables(w io.Writer, report ConfusablesReport) {
+        for _, entry := range report.Entries {
+                fmt.Fprintf(w, "%s:\n", colorize(entry.Skeleton, ansiBold))
+                for _, word := range entry.Words {
+                        first := word.Locations[0]
+                        fmt.Fprintf(w, "   %-20s %-16s %d occurrence(s), first at %s:%d:%d", word.Word,
+                                strings.Join(word.Scripts, "+"), word.Occurrences, first.Path, first.Line, first.Column)
+                        if len(word.Codepoints) > 0 {
+                                fmt.Fprintf(w, " (%s)", strings.Join(word.Codepoints, " "))
+                        }
+                        fmt.Fprintln(w)
+                }
+        }
+
+        if report.Truncated {
+                fmt.Fprintf(w, "\nOnly the first %d distinct words were indexed\n", maxIndexedWords)
+        }
+        if report.Groups == 0 {
+                fmt.Fprintln(w, colorize(fmt.Sprintf("No confusable words found among %d distinct word(s) in %d file(s)", report.Words, report.Files), ansiGreen))
+                return
+        }
+        fmt.Fprintf(w, "\nFound %d group(s) of confusable words among %d distinct word(s) in %d file(s)\n", report.Groups, report.Words, report.Files)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+        "path/filepath"
+        "strconv"
+        "strings"
+        "unicode/utf8"
+)
+
+// typographicQuotes maps curly and low-9 quotation marks to their ASCII
+// equivalents
+var typographicQuotes = map[rune]rune{
+        '‘': '\'', // left single quotation mark
+        '’': '\'', // right single quotation mark (also used as apostrophe)
+        '‚': '\'', // single low-9 quotation mark
+        '‛': '\'', // single high-reversed-9 quotation mark
+        '“': '"',  // left double quotation mark
+        '”': '"',  // right double quotation mark
+        '„': '"',  // double low-9 quotation mark
+        '‟': '"',  // double high-reversed-9 quotation mark
+}
+
+// normalizeQuotes replaces typographic quotation marks with ASCII quotes
+// and returns the number of replacements
+func normalizeQuotes(s string) (string, int) {
+        count := 0
+        normalized := strings.Map(func(r rune) rune {
+                if ascii, ok := typographicQuotes[r]; ok {
+                        count++
+                        return ascii
+                }
+                return r
+        }, s)
+        return normalized, count
+}
+
+// CSVCell identifies a CSV field changed by -csv-safe: the input line it
+// starts on, its 1-based position in the record and its original value
+type CSVCell struct {
+        Line  int    `json:"line"`
+        Field int    `json:"field"`
+        Value string `json:"value"`
+}
+
+// csvFormulaPrefixes are the characters that make Excel, LibreOffice and
+// Google Sheets treat a cell as a formula
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// isCSVFormula reports whether a field value would be evaluated as a
+// formula. Zero-width characters in front of the trigger are skipped, as
+// cleaning removes them; plain numbers such as -5 are left alone.
+func isCSVFormula(value string) bool {
+        trimmed := strings.TrimLeftFunc(value, isZeroWidth)
+        if trimmed == "" || !strings.ContainsRune(csvFormulaPrefixes, rune(trimmed[0])) {
+                return false
+        }
+        _, err := strconv.ParseFloat(strings.TrimSpace(trimmed), 64)
+        return err != nil
+}
+
+// makeCSVSafe rewrites CSV content field by field: fields that would be
+// evaluated as formulas are prefixed with a single quote, and with
+// normalizeQuotes typographic quotes are replaced, with any resulting "
+// escaped so the file's structure is kept. Escaped cells are recorded for
+// the report.
+func makeCSVSafe(content string, delimiter rune, normalize bool, stats *CleaningStats) string {
+        return rewriteCSV(content, delimiter, func(f csvField) string {
+                value := f.Value
+                if normalize {
+                        var count int
+                        value, count = normalizeQuotes(value)
+                        stats.QuotesNormalized += count
+                }
+                if isCSVFormula(value) {
+                        value = "'" + value
+                        stats.FormulasEscaped++
+                        if len(stats.EscapedCells) < maxLocations {
+                                stats.EscapedCells = append(stats.EscapedCells, CSVCell{Line: f.Line, Field: f.Index, Value: f.Value})
+                        }
+                }
+                return value
+        })
+}
+
+// csvField is a field as seen by the callback of rewriteCSV
+type csvField struct {
+        Raw    string // the field as written, including any quotes
+        Value  string
+        Quoted bool
+        Line   int  // input line the field starts on
+        Index  int  // 1-based position in its record
+        Last   bool // the field ends its record
+}
+
+// rewriteCSV calls rewrite for every field of content and writes back the
+// value it returns. Fields whose value is unchanged are copied byte for
+// byte, including their original quoting; a changed field is quoted if it
+// was quoted before or needs to be.
+func rewriteCSV(content string, delimiter rune, rewrite func(f csvField) string) string {
+        var out strings.Builder
+        out.Grow(len(content))
+
+        line, index := 1, 1
+        for pos := 0; pos <= len(content); {
+                raw, value, quoted := nextCSVField(content[pos:], delimiter)
+                pos += len(raw)
+
+                // Find the delimiter or record separator that ends the field
+                r, size := utf8.DecodeRuneInString(content[pos:])
+                if r == '\r' && strings.HasPrefix(content[pos:], "\r\n") {
+                        size = 2
+                }
+
+                field := csvField{Raw: raw, Value: value, Quoted: quoted, Line: line, Index: index, Last: pos == len(content) || r != delimiter}
+                changed := rewrite(field)
+                switch {
+                case changed == value:
+                        out.WriteString(raw)
+                case quoted || csvNeedsQuotes(changed, delimiter):
+                        out.WriteString(`"` + strings.ReplaceAll(changed, `"`, `""`) + `"`)
+                default:
+                        out.WriteString(changed)
+                }
+                line += strings.Count(raw, "\n")
+
+                if pos == len(content) {
+                        break
+                }
+                out.WriteString(content[pos : pos+size])
+                pos += size
+                if r == delimiter {
+                        index++
+                } else {
+                        line++
+                        index = 1
+                }
+        }
+        return out.String()
+}
+
+// csvNeedsQuotes reports whether a value must be quoted to be read back as
+// one field. Tab-separated files only quote what would otherwise split.
+func csvNeedsQuotes(value string, delimiter rune) bool {
+        if delimiter == '\t' {
+                return strings.ContainsAny(value, "\t\r\n") || strings.HasPrefix(value, `"`)
+        }
+        return strings.ContainsAny(value, "\"\r\n"+string(delimiter))
+}
+
+// nextCSVField splits off the field at the start of s, returning its raw
+// text (without the delimiter or line ending that ends it) and its value.
+// A field with text after its closing quote is malformed; its value is its
+// raw text, so it is only changed if that text needs escaping.
+func nextCSVField(s string, delimiter rune) (raw, value string, quoted bool) {
+        if !strings.HasPrefix(s, `"`) {
+                end := strings.IndexFunc(s, func(r rune) bool {
+                        return r == delimiter || r == '\n' || r == '\r'
+                })
+                if end < 0 {
+                        end = len(s)
+                }
+                return s[:end], s[:end], false
+        }
+
+        var b strings.Builder
+        i := 1
+        for i < len(s) {
+                quote := strings.IndexByte(s[i:], '"')
+                if quote < 0 {
+                        // Unterminated: the rest of the content is the field
+                        b.WriteString(s[i:])
+                        return s, b.String(), true
+                }
+                b.WriteString(s[i : i+quote])
+                i += quote + 1
+                if strings.HasPrefix(s[i:], `"`) {
+                        b.WriteByte('"')
+                        i++
+                        continue
+                }
+                break
+        }
+
+        end := i
+        if next, _ := utf8.DecodeRuneInString(s[end:]); end < len(s) && next != delimiter && next != '\n' && next != '\r' {
+                rest := strings.IndexFunc(s[end:], func(r rune) bool {
+                        return r == delimiter || r == '\n' || r == '\r'
+                })
+                if rest < 0 {
+                        rest = len(s) - end
+                }
+                return s[:end+rest], s[:end+rest], false
+        }
+        return s[:end], b.String(), true
+}
+
+// Without -details the report lists only this many escaped cells
+const maxListedCells = 20
+
+// truncateCell shortens a cell value for the report, showing line breaks
+// and tabs as escapes
+func truncateCell(value string) string {
+        value = strconv.Quote(value)
+        return truncateWidth(value, 60, "...")
+}
+
+// csvDelimiterFor picks the delimiter from the file extension: tab for
+// .tsv and .tab files, comma otherwise
+func csvDelimiterFor(path string) rune {
+        switch strings.ToLower(filepath.Ext(path)) {
+        case ".tsv", ".tab":
+                return '\t'
+        }
+        return ','
+}
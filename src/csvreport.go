@@ -0,0 +1,38 @@
+package main
+
+import (
+        "encoding/csv"
+        "fmt"
+        "io"
+        "strconv"
+)
+
+// csvReportHeader names the columns of -report csv
+var csvReportHeader = []string{"file", "codepoint", "name", "count", "category"}
+
+// writeCSVReport writes one row per removed character of each file, with
+// how often it was removed, for loading into a spreadsheet. Files that
+// could not be cleaned have no rows. Cells that a spreadsheet would
+// evaluate as formulas, such as a file named =cmd.txt, are escaped as
+// -csv-safe does.
+func writeCSVReport(w io.Writer, results []FileResult) error {
+        out := csv.NewWriter(w)
+        out.Write(csvReportHeader)
+        for _, r := range results {
+                if r.Err != nil {
+                        continue
+                }
+                file := r.InputPath
+                if isCSVFormula(file) {
+                        file = "'" + file
+                }
+                for _, finding := range newFindings(r.Stats) {
+                        out.Write([]string{file, finding.Codepoint, finding.Name, strconv.Itoa(finding.Count), finding.Category})
+                }
+        }
+        out.Flush()
+        if err := out.Error(); err != nil {
+                return fmt.Errorf("could not write CSV report: %w", err)
+        }
+        return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+        "errors"
+        "strings"
+        "testing"
+)
+
+func TestWriteCSVReport(t *testing.T) {
+        results := []FileResult{
+                {InputPath: "=cmd.txt", Stats: &CleaningStats{RemovedCharDetails: map[rune]int{0x200B: 2, 0xE9: 3}}},
+                {InputPath: "broken.txt", Err: errors.New("could not read")},
+        }
+        var out strings.Builder
+        if err := writeCSVReport(&out, results); err != nil {
+                t.Fatal(err)
+        }
+        lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+        want := []string{
+                "file,codepoint,name,count,category",
+                "'=cmd.txt,U+00E9," + describeChar(0xE9) + ",3," + charCategory(0xE9),
+                "'=cmd.txt,U+200B," + describeChar(0x200B) + ",2," + charCategory(0x200B),
+        }
+        if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+                t.Errorf("CSV report:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+        }
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+        "fmt"
+        "os"
+        "os/signal"
+        "path/filepath"
+        "sort"
+        "strings"
+        "syscall"
+        "time"
+)
+
+// sidecarSuffix is appended to the name of a result or failure for its
+// JSON report
+const sidecarSuffix = ".report.json"
+
+// intakeDaemon cleans the files that arrive in a queue directory. A file
+// in a subdirectory of the queue is cleaned with the config profile of
+// that name, one at the top with the config defaults and -profile. Results
+// go to the output directory and failures to the error directory, at the
+// same relative path and each with a JSON report next to it.
+type intakeDaemon struct {
+        Queue, Output, Errors string
+        Config, Profile       string
+        Settle                time.Duration
+        Verbose               bool
+
+        // Metrics, if set, counts the files processed (-metrics-addr)
+        Metrics *serviceMetrics
+
+        // options caches the cleaning options of each profile
+        options map[string]profileOptions
+
+        // done records the files that were cleaned but could not be removed
+        // from the queue, with their size and time then, so that they are
+        // not cleaned again until they change
+        done map[string]queuedFile
+}
+
+type queuedFile struct {
+        size    int64
+        modTime time.Time
+}
+
+type profileOptions struct {
+        options CleaningOptions
+        err     error
+}
+
+// runDaemon implements the daemon subcommand
+func runDaemon(args []string) int {
+        fs := newSubcommandFlags("daemon")
+        queue := fs.String("queue", "", "Directory to watch for files to clean (required)")
+        output := fs.String("output", "", "Directory the cleaned files are moved to (required)")
+        errorsDir := fs.String("errors", "", "Directory files that could not be cleaned are moved to (required)")
+        configFile := fs.String("config", "", "Config file to use instead of the nearest .cleanfile.yaml")
+        profile := fs.String("profile", "", "Profile for the files at the top of the queue (those in a subdirectory use the profile named after it)")
+        interval := fs.Duration("interval", 2*time.Second, "How often to look for new files")
+        settle := fs.Duration("settle", 2*time.Second, "How long a file must be left unmodified before it is picked up")
+        once := fs.Bool("once", false, "Process the files in the queue and exit instead of watching it")
+        verbose := fs.Bool("verbose", false, "Verbose output")
+        metricsAddr := fs.String("metrics-addr", "", "Serve Prometheus metrics at /metrics on this address, e.g. :9090 (default: off)")
+        if extra := parseInterspersed(fs, args); len(extra) > 0 {
+                exitWithUsage(fs, "unexpected argument '%s'", extra[0])
+        }
+
+        for _, dir := range []struct{ flag, path string }{{"queue", *queue}, {"output", *output}, {"errors", *errorsDir}} {
+                if dir.path == "" {
+                        exitWithUsage(fs, "-%s is required", dir.flag)
+                }
+                if info, err := os.Stat(dir.path); err != nil || !info.IsDir() {
+                        fmt.Printf("Error: -%s '%s' is not a directory\n", dir.flag, dir.path)
+                        return 1
+                }
+        }
+        if *interval <= 0 || *settle < 0 {
+                exitWithUsage(fs, "-interval must be positive and -settle must not be negative")
+        }
+        if err := disjointDirs(*queue, *output, *errorsDir); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        d := &intakeDaemon{
+                Queue:   *queue,
+                Output:  *output,
+                Errors:  *errorsDir,
+                Config:  *configFile,
+                Profile: *profile,
+                Settle:  *settle,
+                Verbose: *verbose,
+                options: make(map[string]profileOptions),
+                done:    make(map[string]queuedFile),
+        }
+        if _, err := d.optionsFor(""); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+        if *metricsAddr != "" {
+                d.Metrics = newServiceMetrics()
+                if err := serveMetrics(*metricsAddr, d.Metrics); err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        return 1
+                }
+        }
+
+        if *once {
+                d.Settle = 0
+                d.poll()
+                return 0
+        }
+
+        stop := make(chan os.Signal, 1)
+        signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+        ticker := time.NewTicker(*interval)
+        defer ticker.Stop()
+        fmt.Printf("Watching %s (results to %s, failures to %s)\n", d.Queue, d.Output, d.Errors)
+        for {
+                d.poll()
+                select {
+                case <-stop:
+                        fmt.Println("Stopped")
+                        return 0
+                case <-ticker.C:
+                }
+        }
+}
+
+// disjointDirs makes sure that none of the directories is inside another,
+// which would make the daemon pick up its own results
+func disjointDirs(dirs ...string) error {
+        abs := make([]string, len(dirs))
+        for i, dir := range dirs {
+                path, err := filepath.Abs(dir)
+                if err != nil {
+                        return err
+                }
+                abs[i] = path
+        }
+        for i := range abs {
+                for j := range abs {
+                        if i == j {
+                                continue
+                        }
+                        if rel, err := filepath.Rel(abs[i], abs[j]); err == nil && !strings.HasPrefix(rel, "..") {
+                                return fmt.Errorf("'%s' and '%s' must be separate directories", dirs[i], dirs[j])
+                        }
+                }
+        }
+        return nil
+}
+
+// optionsFor returns the cleaning options of a queue subdirectory ("" for
+// the top of the queue), built as for "cleanfile clean" from the config
+// files and the profile. .editorconfig is not consulted.
+func (d *intakeDaemon) optionsFor(subdir string) (CleaningOptions, error) {
+        if cached, ok := d.options[subdir]; ok {
+                return cached.options, cached.err
+        }
+
+        profile := d.Profile
+        if subdir != "" {
+                profile = subdir
+        }
+        options, err := func() (CleaningOptions, error) {
+                f := newCleanFlags("clean")
+                cfg, err := loadConfig(configPaths(d.Config))
+                if err != nil {
+                        return CleaningOptions{}, err
+                }
+                if err := applyConfig(f.fs, f.unused, cfg, profile); err != nil {
+                        return CleaningOptions{}, err
+                }
+                if err := applyPreset(f.fs, *f.preset); err != nil {
+                        return CleaningOptions{}, err
+                }
+                if err := applyCleaningMode(f.fs, *f.cleaningMode); err != nil {
+                        return CleaningOptions{}, err
+                }
+                return f.options()
+        }()
+        d.options[subdir] = profileOptions{options, err}
+        return options, err
+}
+
+// poll processes the files that have settled in the queue, in lexical
+// order. Hidden files and directories are skipped, so a producer can write
+// a file under a name starting with a dot and rename it when it is done.
+func (d *intakeDaemon) poll() {
+        var files []string
+        cutoff := time.Now().Add(-d.Settle)
+        filepath.Walk(d.Queue, func(path string, info os.FileInfo, err error) error {
+                if err != nil {
+                        return nil
+                }
+                hidden := path != d.Queue && strings.HasPrefix(info.Name(), ".")
+                if info.IsDir() {
+                        if hidden {
+                                return filepath.SkipDir
+                        }
+                        return nil
+                }
+                if done, ok := d.done[path]; ok && done == (queuedFile{info.Size(), info.ModTime()}) {
+                        return nil
+                }
+                if !hidden && info.Mode().IsRegular() && !info.ModTime().After(cutoff) {
+                        files = append(files, path)
+                }
+                return nil
+        })
+        sort.Strings(files)
+
+        // A file removed since, or replaced by a new one, is no longer done
+        for path, done := range d.done {
+                if info, err := os.Stat(path); err != nil || done != (queuedFile{info.Size(), info.ModTime()}) {
+                        delete(d.done, path)
+                }
+        }
+
+        for _, path := range files {
+                d.process(path)
+        }
+}
+
+// process cleans one file of the queue and moves it out of the queue
+func (d *intakeDaemon) process(path string) {
+        rel, err := filepath.Rel(d.Queue, path)
+        if err != nil {
+                return
+        }
+        subdir := ""
+        if parts := strings.SplitN(filepath.ToSlash(rel), "/", 2); len(parts) == 2 {
+                subdir = parts[0]
+        }
+
+        result := FileResult{InputPath: path}
+        start := time.Now()
+        before, statErr := os.Stat(path)
+        options, err := d.optionsFor(subdir)
+        if err == nil {
+                result.OutputPath, result.Stats, err = d.clean(path, filepath.Join(d.Output, rel), options)
+        }
+        if err != nil {
+                result.Err = err
+                d.Metrics.observe(result, time.Since(start))
+                d.fail(path, rel, result, options)
+                return
+        }
+        d.Metrics.observe(result, time.Since(start))
+
+        if err := os.Remove(path); err != nil {
+                if statErr == nil {
+                        d.done[path] = queuedFile{before.Size(), before.ModTime()}
+                }
+                fmt.Printf("%s %s was cleaned but could not be removed from the queue, and is left there until it changes: %v\n",
+                        colorize("Warning:", ansiYellow), path, err)
+        }
+        if err := writeSidecar(result.OutputPath, result, options); err != nil {
+                fmt.Printf("%s %v\n", colorize("Warning:", ansiYellow), err)
+        }
+        fmt.Printf("Cleaned %s -> %s (%d character(s) removed)\n", path, result.OutputPath, result.Stats.RemovedChars)
+}
+
+// clean writes the cleaned file to a temporary file in the output
+// directory, which then takes a free name at target, so that the output
+// directory never shows a partial file
+func (d *intakeDaemon) clean(path, target string, options CleaningOptions) (string, *CleaningStats, error) {
+        if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+                return "", nil, err
+        }
+        tmpPath, err := createTempFor(target, "")
+        if err != nil {
+                return "", nil, fmt.Errorf("could not create temporary file: %w", err)
+        }
+        sink := &fileSink{Path: tmpPath, Mode: options.OutputMode, SourcePerm: inputPerm(path)}
+        source, _ := os.Stat(path)
+        stats, err := cleanFile(path, sink, options, d.Verbose)
+        if err != nil {
+                os.Remove(tmpPath)
+                return "", nil, err
+        }
+        preserveAttributes(tmpPath, source, options, stats)
+
+        target = freePath(target)
+        if err := os.Rename(tmpPath, target); err != nil {
+                os.Remove(tmpPath)
+                return "", nil, fmt.Errorf("could not move the result to the output directory: %w", err)
+        }
+        return target, stats, nil
+}
+
+// fail moves a file that could not be cleaned to the error directory, with
+// a report that gives the error
+func (d *intakeDaemon) fail(path, rel string, result FileResult, options CleaningOptions) {
+        target := filepath.Join(d.Errors, rel)
+        err := os.MkdirAll(filepath.Dir(target), 0755)
+        if err == nil {
+                target = freePath(target)
+                err = moveFile(path, target)
+        }
+        if err != nil {
+                fmt.Printf("Error: %s could not be cleaned (%v) nor moved to %s: %v\n", path, result.Err, d.Errors, err)
+                return
+        }
+        if err := writeSidecar(target, result, options); err != nil {
+                fmt.Printf("%s %v\n", colorize("Warning:", ansiYellow), err)
+        }
+        fmt.Printf("Failed %s -> %s: %v\n", path, target, result.Err)
+}
+
+// moveFile renames src to dst, or copies and removes it if they are on
+// different filesystems
+func moveFile(src, dst string) error {
+        if err := os.Rename(src, dst); err == nil {
+                return nil
+        }
+        if err := copyFile(src, dst, 0); err != nil {
+                os.Remove(dst)
+                return err
+        }
+        return os.Remove(src)
+}
+
+// freePath returns path, or if it is taken, path with -1, -2 and so on
+// before its extension, so that an earlier file of the same name is never
+// overwritten
+func freePath(path string) string {
+        ext := filepath.Ext(path)
+        base := strings.TrimSuffix(path, ext)
+        candidate := path
+        for n := 1; ; n++ {
+                if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+                        return candidate
+                }
+                candidate = fmt.Sprintf("%s-%d%s", base, n, ext)
+        }
+}
+
+// writeSidecar writes the JSON report of one file next to it
+func writeSidecar(path string, result FileResult, options CleaningOptions) error {
+        f, err := os.Create(path + sidecarSuffix)
+        if err != nil {
+                return fmt.Errorf("could not write report: %w", err)
+        }
+        if err := writeJSONReport(f, []FileResult{result}, options.TargetOS, false, nil); err != nil {
+                f.Close()
+                return err
+        }
+        if err := f.Close(); err != nil {
+                return fmt.Errorf("could not write report: %w", err)
+        }
+        return nil
+}
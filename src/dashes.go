@@ -0,0 +1,35 @@
+package main
+
+// isDash reports whether -dashes applies to a character: the Unicode
+// hyphens and dashes, and the minus sign
+func isDash(r rune) bool {
+        switch r {
+        case '‐', // hyphen
+                '‑', // non-breaking hyphen
+                '‒', // figure dash
+                '–', // en dash
+                '—', // em dash
+                '―', // horizontal bar
+                '−', // minus sign
+                '﹘', // small em dash
+                '﹣', // small hyphen-minus
+                '－': // fullwidth hyphen-minus
+                return true
+        }
+        return false
+}
+
+// validDashes reports whether mode is a valid -dashes value; empty leaves
+// dashes to -ascii and -smart-punct
+func validDashes(mode string) bool {
+        return mode == "" || mode == "keep" || mode == "hyphen" || mode == "double-hyphen"
+}
+
+// dashReplacement returns the ASCII written for a dash: a hyphen, or with
+// double-hyphen two for the long dashes, as typewriters wrote an em dash
+func dashReplacement(r rune, mode string) string {
+        if mode == "double-hyphen" && (r == '—' || r == '―' || r == '﹘') {
+                return "--"
+        }
+        return "-"
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+        "bytes"
+        "encoding/json"
+        "fmt"
+        "io"
+        "os"
+        "strings"
+)
+
+// Detection describes a file as found, without changing it
+type Detection struct {
+        Path        string         `json:"path"`
+        Format      string         `json:"format,omitempty"`
+        Encoding    string         `json:"encoding,omitempty"`
+        BOM         bool           `json:"bom"`
+        LineEndings map[string]int `json:"line_endings,omitempty"`
+        Lines       int            `json:"lines"`
+        Chars       int            `json:"chars"`
+        Removable   map[string]int `json:"removable,omitempty"`
+        Error       string         `json:"error,omitempty"`
+}
+
+// runDetect implements the detect subcommand
+func runDetect(args []string) int {
+        fs := newSubcommandFlags("detect")
+        recursive := fs.Bool("recursive", false, "Detect every file in the given directory trees")
+        include := fs.String("include", "", "Comma-separated glob patterns of files to detect in recursive mode")
+        exclude := fs.String("exclude", "", "Comma-separated glob patterns of files or directories to skip in recursive mode")
+        reportFormat := fs.String("report", "text", "Report format: text or json")
+        colorMode := fs.String("color", "auto", "Colorize the report: auto, always, never (NO_COLOR disables auto)")
+        paths := parseInterspersed(fs, args)
+
+        if len(paths) == 0 {
+                exitWithUsage(fs, "at least one file or directory is required")
+        }
+        if *reportFormat != "text" && *reportFormat != "json" {
+                exitWithUsage(fs, "invalid report format '%s' (valid options: text, json)", *reportFormat)
+        }
+        if err := setupColor(*colorMode); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        batch := BatchOptions{Include: splitPatterns(*include), Exclude: splitPatterns(*exclude)}
+        files, err := expandPaths(paths, *recursive, batch, "detect")
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        status := 0
+        detections := make([]Detection, 0, len(files))
+        for _, path := range files {
+                d := detectFile(path)
+                if d.Error != "" {
+                        status = 1
+                }
+                detections = append(detections, d)
+        }
+
+        if *reportFormat == "json" {
+                encoder := json.NewEncoder(os.Stdout)
+                encoder.SetIndent("", "  ")
+                encoder.SetEscapeHTML(false)
+                if err := encoder.Encode(detections); err != nil {
+                        fmt.Printf("Error: could not write JSON report: %v\n", err)
+                        return 1
+                }
+                return status
+        }
+
+        for i, d := range detections {
+                if i > 0 {
+                        fmt.Println()
+                }
+                printDetection(os.Stdout, d)
+        }
+        return status
+}
+
+// detectFile inspects a file by running the cleaning pipeline with all
+// removals enabled but discarding the output
+func detectFile(path string) Detection {
+        d := Detection{Path: path}
+
+        f, err := os.Open(path)
+        if err != nil {
+                d.Error = err.Error()
+                return d
+        }
+        sample := make([]byte, detectSampleSize)
+        n, err := io.ReadFull(f, sample)
+        f.Close()
+        if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+                d.Error = err.Error()
+                return d
+        }
+        sample = sample[:n]
+
+        d.Encoding, d.BOM = detectEncoding(sample)
+        if !strings.HasPrefix(d.Encoding, "UTF-8") && !decodable(d.Encoding) {
+                // The cleaner cannot read anything else; it would be misreported
+                return d
+        }
+        if decodable(d.Encoding) {
+                decoded, _ := io.ReadAll(newInputDecoder(bytes.NewReader(sample), d.Encoding))
+                sample = decoded
+        }
+
+        options := CleaningOptions{
+                RemoveNonASCII:     true,
+                RemoveControlChars: true,
+                RemoveZeroWidth:    true,
+                RemoveBOM:          true,
+                TargetOS:           "unix",
+        }
+        stats, err := cleanFile(path, &discardSink{}, options, false)
+        if err != nil {
+                d.Error = err.Error()
+                return d
+        }
+
+        d.Format = detectFileFormat(string(sample))
+        d.LineEndings = stats.OriginalLineEndings
+        d.Lines = stats.LinesProcessed
+        d.Chars = stats.OriginalChars
+        for _, w := range stats.Warnings {
+                if w.Kind == warnInvalidUTF8 {
+                        d.Encoding = "UTF-8 (with invalid sequences)"
+                }
+        }
+
+        for r, count := range stats.RemovedCharDetails {
+                if r == '\uFEFF' && d.BOM {
+                        count--
+                }
+                if count > 0 {
+                        if d.Removable == nil {
+                                d.Removable = make(map[string]int)
+                        }
+                        d.Removable[charCategory(r)] += count
+                }
+        }
+        return d
+}
+
+func printDetection(w io.Writer, d Detection) {
+        fmt.Fprintln(w, colorize(d.Path, ansiBold))
+        if d.Error != "" {
+                fmt.Fprintf(w, "   %s %s\n", colorize("Error:", ansiRed), d.Error)
+                return
+        }
+
+        encoding := d.Encoding
+        if d.BOM {
+                encoding += " with BOM"
+        }
+        fmt.Fprintf(w, "   Encoding:      %s\n", encoding)
+        if d.Format == "" {
+                return
+        }
+        fmt.Fprintf(w, "   Format:        %s\n", d.Format)
+
+        endings := "none"
+        if len(d.LineEndings) > 0 {
+                endings = formatCounts(d.LineEndings)
+                if len(d.LineEndings) > 1 {
+                        endings += colorize(" (mixed)", ansiYellow)
+                }
+        }
+        fmt.Fprintf(w, "   Line endings:  %s\n", endings)
+        fmt.Fprintf(w, "   Size:          %d line(s), %d character(s)\n", d.Lines, d.Chars)
+
+        if len(d.Removable) == 0 {
+                fmt.Fprintf(w, "   Removable:     %s\n", colorize("none", ansiGreen))
+        } else {
+                fmt.Fprintf(w, "   Removable:     %s\n", colorize(formatCounts(d.Removable), ansiYellow))
+        }
+}
@@ -0,0 +1,77 @@
+package main
+
+// maxDiffLinesCompared limits the lines between the common start and end of
+// two texts that are aligned line by line; longer differences are given as
+// a single block of removed and added lines
+const maxDiffLinesCompared = 2000
+
+// diffOp is a line of an edit script that turns a into b: a line of both
+// (' '), a line only of a ('-') or a line only of b ('+'). A and B are the
+// indexes of the line in a and in b, as far as it is in them.
+type diffOp struct {
+        Kind byte
+        A, B int
+}
+
+// diffLines returns an edit script from a to b. The common start and end
+// are kept; what lies between is aligned by its longest common subsequence
+// if it is short enough.
+func diffLines(a, b []string) []diffOp {
+        var ops []diffOp
+        start := 0
+        for start < len(a) && start < len(b) && a[start] == b[start] {
+                ops = append(ops, diffOp{' ', start, start})
+                start++
+        }
+        endA, endB := len(a), len(b)
+        for endA > start && endB > start && a[endA-1] == b[endB-1] {
+                endA--
+                endB--
+        }
+        midA, midB := a[start:endA], b[start:endB]
+
+        if len(midA) <= maxDiffLinesCompared && len(midB) <= maxDiffLinesCompared {
+                // lcs[i][j] is the length of the longest common
+                // subsequence of midA[i:] and midB[j:]
+                lcs := make([][]int, len(midA)+1)
+                for i := range lcs {
+                        lcs[i] = make([]int, len(midB)+1)
+                }
+                for i := len(midA) - 1; i >= 0; i-- {
+                        for j := len(midB) - 1; j >= 0; j-- {
+                                if midA[i] == midB[j] {
+                                        lcs[i][j] = lcs[i+1][j+1] + 1
+                                } else {
+                                        lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+                                }
+                        }
+                }
+                i, j := 0, 0
+                for i < len(midA) || j < len(midB) {
+                        switch {
+                        case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+                                ops = append(ops, diffOp{' ', start + i, start + j})
+                                i++
+                                j++
+                        case j == len(midB) || i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]:
+                                ops = append(ops, diffOp{'-', start + i, start + j})
+                                i++
+                        default:
+                                ops = append(ops, diffOp{'+', start + i, start + j})
+                                j++
+                        }
+                }
+        } else {
+                for i := range midA {
+                        ops = append(ops, diffOp{'-', start + i, start})
+                }
+                for j := range midB {
+                        ops = append(ops, diffOp{'+', endA, start + j})
+                }
+        }
+
+        for k := 0; endA+k < len(a); k++ {
+                ops = append(ops, diffOp{' ', endA + k, endB + k})
+        }
+        return ops
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+        "bufio"
+        "fmt"
+        "os"
+        "path/filepath"
+        "strconv"
+        "strings"
+        "sync"
+)
+
+const editorConfigName = ".editorconfig"
+
+// editorConfigSection is one [glob] section of an .editorconfig file
+type editorConfigSection struct {
+        Glob       string
+        Properties map[string]string
+}
+
+// editorConfigFile is a parsed .editorconfig file
+type editorConfigFile struct {
+        Dir      string
+        Root     bool
+        Sections []editorConfigSection
+}
+
+// editorConfigResolver derives per-file cleaning options from the
+// .editorconfig files above each input file. Options whose flags were set
+// on the command line or in a cleanfile config are left alone. Parsed files
+// are cached, so a recursive run reads each .editorconfig only once.
+type editorConfigResolver struct {
+        overridden map[string]bool
+
+        mu    sync.Mutex
+        cache map[string]*editorConfigFile
+}
+
+func newEditorConfigResolver(overridden map[string]bool) *editorConfigResolver {
+        return &editorConfigResolver{
+                overridden: overridden,
+                cache:      make(map[string]*editorConfigFile),
+        }
+}
+
+// options returns base adjusted by the .editorconfig properties that apply
+// to path. A nil resolver returns base unchanged.
+func (r *editorConfigResolver) options(path string, base CleaningOptions) (CleaningOptions, error) {
+        if r == nil {
+                return base, nil
+        }
+        props, err := r.properties(path)
+        if err != nil {
+                return base, err
+        }
+
+        options := base
+        if !r.overridden["os"] {
+                switch props["end_of_line"] {
+                case "lf":
+                        options.TargetOS = "unix"
+                case "crlf":
+                        options.TargetOS = "windows"
+                case "cr":
+                        options.TargetOS = "mac9"
+                }
+        }
+        if !r.overridden["bom"] {
+                switch props["charset"] {
+                case "utf-8":
+                        options.RemoveBOM = true
+                case "utf-8-bom":
+                        options.RemoveBOM = false
+                }
+        }
+        if value, ok := editorConfigBool(props["trim_trailing_whitespace"]); ok && !r.overridden["trim-trailing"] {
+                options.TrimTrailingWhitespace = value
+        }
+        if value, ok := editorConfigBool(props["insert_final_newline"]); ok && !r.overridden["final-newline"] {
+                options.InsertFinalNewline = value
+        }
+        return options, nil
+}
+
+// properties collects the properties that apply to path, letting files
+// closer to it override those further up, and stopping at root = true
+func (r *editorConfigResolver) properties(path string) (map[string]string, error) {
+        absPath, err := filepath.Abs(path)
+        if err != nil {
+                return nil, fmt.Errorf("could not resolve path: %w", err)
+        }
+
+        var files []*editorConfigFile
+        dir := filepath.Dir(absPath)
+        for {
+                file, err := r.load(dir)
+                if err != nil {
+                        return nil, err
+                }
+                if file != nil {
+                        files = append(files, file)
+                        if file.Root {
+                                break
+                        }
+                }
+                parent := filepath.Dir(dir)
+                if parent == dir {
+                        break
+                }
+                dir = parent
+        }
+
+        props := make(map[string]string)
+        for i := len(files) - 1; i >= 0; i-- {
+                file := files[i]
+                rel, err := filepath.Rel(file.Dir, absPath)
+                if err != nil {
+                        continue
+                }
+                rel = filepath.ToSlash(rel)
+                for _, section := range file.Sections {
+                        if !matchEditorConfigGlob(section.Glob, rel) {
+                                continue
+                        }
+                        for key, value := range section.Properties {
+                                if value == "unset" {
+                                        delete(props, key)
+                                } else {
+                                        props[key] = value
+                                }
+                        }
+                }
+        }
+        return props, nil
+}
+
+// load returns the parsed .editorconfig of dir, or nil if there is none
+func (r *editorConfigResolver) load(dir string) (*editorConfigFile, error) {
+        r.mu.Lock()
+        defer r.mu.Unlock()
+
+        if file, ok := r.cache[dir]; ok {
+                return file, nil
+        }
+        file, err := readEditorConfig(dir)
+        if err != nil {
+                return nil, err
+        }
+        r.cache[dir] = file
+        return file, nil
+}
+
+func readEditorConfig(dir string) (*editorConfigFile, error) {
+        path := filepath.Join(dir, editorConfigName)
+        f, err := os.Open(path)
+        if os.IsNotExist(err) {
+                return nil, nil
+        }
+        if err != nil {
+                return nil, fmt.Errorf("could not read %s: %w", path, err)
+        }
+        defer f.Close()
+
+        file := &editorConfigFile{Dir: dir}
+        var section *editorConfigSection
+
+        scanner := bufio.NewScanner(f)
+        for scanner.Scan() {
+                line := strings.TrimSpace(scanner.Text())
+                if line == "" || line[0] == '#' || line[0] == ';' {
+                        continue
+                }
+
+                if line[0] == '[' && line[len(line)-1] == ']' {
+                        file.Sections = append(file.Sections, editorConfigSection{
+                                Glob:       line[1 : len(line)-1],
+                                Properties: make(map[string]string),
+                        })
+                        section = &file.Sections[len(file.Sections)-1]
+                        continue
+                }
+
+                // Malformed lines are ignored, as editors do
+                key, value, ok := strings.Cut(line, "=")
+                if !ok {
+                        continue
+                }
+                key = strings.ToLower(strings.TrimSpace(key))
+                value = strings.ToLower(strings.TrimSpace(value))
+
+                if section == nil {
+                        if key == "root" {
+                                file.Root = value == "true"
+                        }
+                        continue
+                }
+                section.Properties[key] = value
+        }
+        if err := scanner.Err(); err != nil {
+                return nil, fmt.Errorf("could not read %s: %w", path, err)
+        }
+        return file, nil
+}
+
+func editorConfigBool(value string) (bool, bool) {
+        switch value {
+        case "true":
+                return true, true
+        case "false":
+                return false, true
+        }
+        return false, false
+}
+
+// matchEditorConfigGlob matches a section glob against a path relative to
+// the .editorconfig directory. Globs without a slash match the base name at
+// any depth; {a,b} and {1..3} alternatives are expanded before matching.
+func matchEditorConfigGlob(glob, relPath string) bool {
+        for _, pattern := range expandBraces(glob) {
+                pattern = strings.TrimPrefix(pattern, "/")
+                if matchGlob(pattern, relPath) {
+                        return true
+                }
+        }
+        return false
+}
+
+// expandBraces expands the first {…} group of pattern and recurses on the
+// results. Numeric ranges {1..3} become 1, 2 and 3.
+func expandBraces(pattern string) []string {
+        start := strings.IndexByte(pattern, '{')
+        if start < 0 {
+                return []string{pattern}
+        }
+
+        depth := 0
+        end := -1
+        for i := start; i < len(pattern); i++ {
+                switch pattern[i] {
+                case '{':
+                        depth++
+                case '}':
+                        depth--
+                }
+                if depth == 0 {
+                        end = i
+                        break
+                }
+        }
+        if end < 0 {
+                return []string{pattern}
+        }
+
+        prefix, body, suffix := pattern[:start], pattern[start+1:end], pattern[end+1:]
+
+        var alternatives []string
+        if lo, hi, ok := strings.Cut(body, ".."); ok {
+                from, errFrom := strconv.Atoi(lo)
+                to, errTo := strconv.Atoi(hi)
+                if errFrom == nil && errTo == nil && from <= to {
+                        for n := from; n <= to; n++ {
+                                alternatives = append(alternatives, strconv.Itoa(n))
+                        }
+                }
+        }
+        if alternatives == nil {
+                alternatives = splitBraceBody(body)
+        }
+
+        var expanded []string
+        for _, alt := range alternatives {
+                expanded = append(expanded, expandBraces(prefix+alt+suffix)...)
+        }
+        return expanded
+}
+
+// splitBraceBody splits the inside of a {…} group on top-level commas
+func splitBraceBody(body string) []string {
+        var parts []string
+        depth, last := 0, 0
+        for i := 0; i < len(body); i++ {
+                switch body[i] {
+                case '{':
+                        depth++
+                case '}':
+                        depth--
+                case ',':
+                        if depth == 0 {
+                                parts = append(parts, body[last:i])
+                                last = i + 1
+                        }
+                }
+        }
+        return append(parts, body[last:])
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+        "strings"
+        "unicode"
+)
+
+const (
+        zeroWidthJoiner    = '\u200D'
+        emojiVariation     = '\uFE0F' // VS16, requests emoji presentation
+        combiningKeycap    = '\u20E3'
+        regionalIndicatorA = '\U0001F1E6'
+        regionalIndicatorZ = '\U0001F1FF'
+)
+
+// emojiLength returns the number of characters of the emoji that starts at
+// runes[i], or 0 if none does. An emoji is taken as a whole: a ZWJ
+// sequence (family, profession), a flag (two regional indicators or a
+// black flag with tags), a keycap (1, #, * followed by U+20E3) and any
+// skin tone modifier or variation selector that belongs to it. Pictographs
+// that display as text by default, such as © or ™, only count as emoji
+// when followed by U+FE0F.
+func emojiLength(runes []rune, i int) int {
+        r := runes[i]
+        next := func(j int) rune {
+                if j < len(runes) {
+                        return runes[j]
+                }
+                return 0
+        }
+
+        switch {
+        case r >= regionalIndicatorA && r <= regionalIndicatorZ:
+                if n := next(i + 1); n >= regionalIndicatorA && n <= regionalIndicatorZ {
+                        return 2
+                }
+                return 1
+        case r >= '0' && r <= '9' || r == '#' || r == '*':
+                j := i + 1
+                if next(j) == emojiVariation {
+                        j++
+                }
+                if next(j) == combiningKeycap {
+                        return j + 1 - i
+                }
+                return 0
+        case !isEmojiBase(r, next(i+1)):
+                return 0
+        }
+
+        j := i + 1
+        for j < len(runes) {
+                switch n := runes[j]; {
+                case n == emojiVariation || isEmojiModifier(n) || isEmojiTag(n):
+                        j++
+                case n == zeroWidthJoiner && unicode.Is(extendedPictographic, next(j+1)):
+                        j += 2
+                default:
+                        return j - i
+                }
+        }
+        return j - i
+}
+
+// isEmojiBase reports whether r starts an emoji: it displays as one by
+// default or is a pictograph followed by U+FE0F
+func isEmojiBase(r, next rune) bool {
+        if r < 0x80 {
+                return false
+        }
+        return unicode.Is(emojiPresentation, r) || next == emojiVariation && unicode.Is(extendedPictographic, r)
+}
+
+// isEmojiModifier reports whether r is one of the five skin tone modifiers
+func isEmojiModifier(r rune) bool {
+        return r >= '\U0001F3FB' && r <= '\U0001F3FF'
+}
+
+// isEmojiTag reports whether r is a tag character, used by subdivision
+// flags such as England or Scotland
+func isEmojiTag(r rune) bool {
+        return r >= '\U000E0020' && r <= '\U000E007F'
+}
+
+// emojiShortcode returns the shortcode of an emoji found by emojiLength.
+// Variation selectors are ignored, and an emoji with a skin tone falls
+// back to the shortcode of the emoji without it.
+func emojiShortcode(emoji []rune) (string, bool) {
+        key := strings.ReplaceAll(string(emoji), string(emojiVariation), "")
+        if name, ok := emojiShortcodes[key]; ok {
+                return name, true
+        }
+        name, ok := emojiShortcodes[strings.Map(func(r rune) rune {
+                if isEmojiModifier(r) {
+                        return -1
+                }
+                return r
+        }, key)]
+        return name, ok
+}
+
+// expandShortcodes replaces :shortcode: with the emoji it stands for and
+// returns the number of shortcodes replaced. A shortcode must not be
+// directly preceded or followed by a letter or digit, so times such as
+// 10:100:20 are left alone.
+func expandShortcodes(s string) (string, int) {
+        if strings.Count(s, ":") < 2 {
+                return s, 0
+        }
+        var b strings.Builder
+        count := 0
+        written := 0
+        for start := strings.IndexByte(s, ':'); start >= 0; {
+                end := strings.IndexByte(s[start+1:], ':')
+                if end < 0 {
+                        break
+                }
+                end += start + 1
+                emoji, ok := shortcodeEmoji[s[start+1:end]]
+                if !ok || isWordByte(s, start-1) || isWordByte(s, end+1) {
+                        // The closing colon may open the next shortcode
+                        start = end
+                        continue
+                }
+                b.WriteString(s[written:start])
+                b.WriteString(emoji)
+                written = end + 1
+                count++
+                start = strings.IndexByte(s[written:], ':')
+                if start >= 0 {
+                        start += written
+                }
+        }
+        if count == 0 {
+                return s, 0
+        }
+        b.WriteString(s[written:])
+        return b.String(), count
+}
+
+// isWordByte reports whether s[i] exists and is an ASCII letter or digit
+func isWordByte(s string, i int) bool {
+        if i < 0 || i >= len(s) {
+                return false
+        }
+        c := s[i]
+        return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// isVariationSelector reports whether r is one of the variation selectors
+// VS1-VS16 (U+FE00-FE0F) or VS17-VS256 (U+E0100-E01EF)
+func isVariationSelector(r rune) bool {
+        return r >= '\uFE00' && r <= '\uFE0F' || r >= '\U000E0100' && r <= '\U000E01EF'
+}
+
+// isStrayJoiner reports whether the zero width joiner at runes[i] joins
+// nothing: it needs an emoji or a letter of a joining script (such as
+// Arabic or Devanagari) on both sides, not counting the variation
+// selectors and skin tones that -strip-modifiers removes anyway. Han, kana
+// and Hangul never join.
+func isStrayJoiner(runes []rune, i int) bool {
+        joinable := func(r rune) bool {
+                if unicode.Is(emojiPresentation, r) || unicode.Is(extendedPictographic, r) {
+                        return true
+                }
+                return r > 127 && (unicode.IsLetter(r) || unicode.IsMark(r)) &&
+                        !unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+        }
+        prev, next := i-1, i+1
+        for prev >= 0 && (isVariationSelector(runes[prev]) || isEmojiModifier(runes[prev])) {
+                prev--
+        }
+        for next < len(runes) && (isVariationSelector(runes[next]) || isEmojiModifier(runes[next])) {
+                next++
+        }
+        return prev < 0 || next >= len(runes) || !joinable(runes[prev]) || !joinable(runes[next])
+}
@@ -0,0 +1,3794 @@
+// Code generated by tools/emojicodes from gemoji's db/emoji.json. DO NOT EDIT.
+
+package main
+
+// emojiShortcodes maps an emoji, without U+FE0F, to its first alias
+var emojiShortcodes = map[string]string{
+        "\U0001f600":                 "grinning",
+        "\U0001f603":                 "smiley",
+        "\U0001f604":                 "smile",
+        "\U0001f601":                 "grin",
+        "\U0001f606":                 "laughing",
+        "\U0001f605":                 "sweat_smile",
+        "\U0001f923":                 "rofl",
+        "\U0001f602":                 "joy",
+        "\U0001f642":                 "slightly_smiling_face",
+        "\U0001f643":                 "upside_down_face",
+        "\U0001fae0":                 "melting_face",
+        "\U0001f609":                 "wink",
+        "\U0001f60a":                 "blush",
+        "\U0001f607":                 "innocent",
+        "\U0001f970":                 "smiling_face_with_three_hearts",
+        "\U0001f60d":                 "heart_eyes",
+        "\U0001f929":                 "star_struck",
+        "\U0001f618":                 "kissing_heart",
+        "\U0001f617":                 "kissing",
+        "\u263a":                     "relaxed",
+        "\U0001f61a":                 "kissing_closed_eyes",
+        "\U0001f619":                 "kissing_smiling_eyes",
+        "\U0001f972":                 "smiling_face_with_tear",
+        "\U0001f60b":                 "yum",
+        "\U0001f61b":                 "stuck_out_tongue",
+        "\U0001f61c":                 "stuck_out_tongue_winking_eye",
+        "\U0001f92a":                 "zany_face",
+        "\U0001f61d":                 "stuck_out_tongue_closed_eyes",
+        "\U0001f911":                 "money_mouth_face",
+        "\U0001f917":                 "hugs",
+        "\U0001f92d":                 "hand_over_mouth",
+        "\U0001fae2":                 "face_with_open_eyes_and_hand_over_mouth",
+        "\U0001fae3":                 "face_with_peeking_eye",
+        "\U0001f92b":                 "shushing_face",
+        "\U0001f914":                 "thinking",
+        "\U0001fae1":                 "saluting_face",
+        "\U0001f910":                 "zipper_mouth_face",
+        "\U0001f928":                 "raised_eyebrow",
+        "\U0001f610":                 "neutral_face",
+        "\U0001f611":                 "expressionless",
+        "\U0001f636":                 "no_mouth",
+        "\U0001fae5":                 "dotted_line_face",
+        "\U0001f636\u200d\U0001f32b": "face_in_clouds",
+        "\U0001f60f":                 "smirk",
+        "\U0001f612":                 "unamused",
+        "\U0001f644":                 "roll_eyes",
+        "\U0001f62c":                 "grimacing",
+        "\U0001f62e\u200d\U0001f4a8": "face_exhaling",
+        "\U0001f925":                 "lying_face",
+        "\U0001fae8":                 "shaking_face",
+        "\U0001f60c":                 "relieved",
+        "\U0001f614":                 "pensive",
+        "\U0001f62a":                 "sleepy",
+        "\U0001f924":                 "drooling_face",
+        "\U0001f634":                 "sleeping",
+        "\U0001f637":                 "mask",
+        "\U0001f912":                 "face_with_thermometer",
+        "\U0001f915":                 "face_with_head_bandage",
+        "\U0001f922":                 "nauseated_face",
+        "\U0001f92e":                 "vomiting_face",
+        "\U0001f927":                 "sneezing_face",
+        "\U0001f975":                 "hot_face",
+        "\U0001f976":                 "cold_face",
+        "\U0001f974":                 "woozy_face",
+        "\U0001f635":                 "dizzy_face",
+        "\U0001f635\u200d\U0001f4ab": "face_with_spiral_eyes",
+        "\U0001f92f":                 "exploding_head",
+        "\U0001f920":                 "cowboy_hat_face",
+        "\U0001f973":                 "partying_face",
+        "\U0001f978":                 "disguised_face",
+        "\U0001f60e":                 "sunglasses",
+        "\U0001f913":                 "nerd_face",
+        "\U0001f9d0":                 "monocle_face",
+        "\U0001f615":                 "confused",
+        "\U0001fae4":                 "face_with_diagonal_mouth",
+        "\U0001f61f":                 "worried",
+        "\U0001f641":                 "slightly_frowning_face",
+        "\u2639":                     "frowning_face",
+        "\U0001f62e":                 "open_mouth",
+        "\U0001f62f":                 "hushed",
+        "\U0001f632":                 "astonished",
+        "\U0001f633":                 "flushed",
+        "\U0001f97a":                 "pleading_face",
+        "\U0001f979":                 "face_holding_back_tears",
+        "\U0001f626":                 "frowning",
+        "\U0001f627":                 "anguished",
+        "\U0001f628":                 "fearful",
+        "\U0001f630":                 "cold_sweat",
+        "\U0001f625":                 "disappointed_relieved",
+        "\U0001f622":                 "cry",
+        "\U0001f62d":                 "sob",
+        "\U0001f631":                 "scream",
+        "\U0001f616":                 "confounded",
+        "\U0001f623":                 "persevere",
+        "\U0001f61e":                 "disappointed",
+        "\U0001f613":                 "sweat",
+        "\U0001f629":                 "weary",
+        "\U0001f62b":                 "tired_face",
+        "\U0001f971":                 "yawning_face",
+        "\U0001f624":                 "triumph",
+        "\U0001f621":                 "rage",
+        "\U0001f620":                 "angry",
+        "\U0001f92c":                 "cursing_face",
+        "\U0001f608":                 "smiling_imp",
+        "\U0001f47f":                 "imp",
+        "\U0001f480":                 "skull",
+        "\u2620":                     "skull_and_crossbones",
+        "\U0001f4a9":                 "hankey",
+        "\U0001f921":                 "clown_face",
+        "\U0001f479":                 "japanese_ogre",
+        "\U0001f47a":                 "japanese_goblin",
+        "\U0001f47b":                 "ghost",
+        "\U0001f47d":                 "alien",
+        "\U0001f47e":                 "space_invader",
+        "\U0001f916":                 "robot",
+        "\U0001f63a":                 "smiley_cat",
+        "\U0001f638":                 "smile_cat",
+        "\U0001f639":                 "joy_cat",
+        "\U0001f63b":                 "heart_eyes_cat",
+        "\U0001f63c":                 "smirk_cat",
+        "\U0001f63d":                 "kissing_cat",
+        "\U0001f640":                 "scream_cat",
+        "\U0001f63f":                 "crying_cat_face",
+        "\U0001f63e":                 "pouting_cat",
+        "\U0001f648":                 "see_no_evil",
+        "\U0001f649":                 "hear_no_evil",
+        "\U0001f64a":                 "speak_no_evil",
+        "\U0001f48c":                 "love_letter",
+        "\U0001f498":                 "cupid",
+        "\U0001f49d":                 "gift_heart",
+        "\U0001f496":                 "sparkling_heart",
+        "\U0001f497":                 "heartpulse",
+        "\U0001f493":                 "heartbeat",
+        "\U0001f49e":                 "revolving_hearts",
+        "\U0001f495":                 "two_hearts",
+        "\U0001f49f":                 "heart_decoration",
+        "\u2763":                     "heavy_heart_exclamation",
+        "\U0001f494":                 "broken_heart",
+        "\u2764\u200d\U0001f525":     "heart_on_fire",
+        "\u2764\u200d\U0001fa79":     "mending_heart",
+        "\u2764":                     "heart",
+        "\U0001fa77":                 "pink_heart",
+        "\U0001f9e1":                 "orange_heart",
+        "\U0001f49b":                 "yellow_heart",
+        "\U0001f49a":                 "green_heart",
+        "\U0001f499":                 "blue_heart",
+        "\U0001fa75":                 "light_blue_heart",
+        "\U0001f49c":                 "purple_heart",
+        "\U0001f90e":                 "brown_heart",
+        "\U0001f5a4":                 "black_heart",
+        "\U0001fa76":                 "grey_heart",
+        "\U0001f90d":                 "white_heart",
+        "\U0001f48b":                 "kiss",
+        "\U0001f4af":                 "100",
+        "\U0001f4a2":                 "anger",
+        "\U0001f4a5":                 "boom",
+        "\U0001f4ab":                 "dizzy",
+        "\U0001f4a6":                 "sweat_drops",
+        "\U0001f4a8":                 "dash",
+        "\U0001f573":                 "hole",
+        "\U0001f4ac":                 "speech_balloon",
+        "\U0001f441\u200d\U0001f5e8": "eye_speech_bubble",
+        "\U0001f5e8":                 "left_speech_bubble",
+        "\U0001f5ef":                 "right_anger_bubble",
+        "\U0001f4ad":                 "thought_balloon",
+        "\U0001f4a4":                 "zzz",
+        "\U0001f44b":                 "wave",
+        "\U0001f91a":                 "raised_back_of_hand",
+        "\U0001f590":                 "raised_hand_with_fingers_splayed",
+        "\u270b":                     "hand",
+        "\U0001f596":                 "vulcan_salute",
+        "\U0001faf1":                 "rightwards_hand",
+        "\U0001faf2":                 "leftwards_hand",
+        "\U0001faf3":                 "palm_down_hand",
+        "\U0001faf4":                 "palm_up_hand",
+        "\U0001faf7":                 "leftwards_pushing_hand",
+        "\U0001faf8":                 "rightwards_pushing_hand",
+        "\U0001f44c":                 "ok_hand",
+        "\U0001f90c":                 "pinched_fingers",
+        "\U0001f90f":                 "pinching_hand",
+        "\u270c":                     "v",
+        "\U0001f91e":                 "crossed_fingers",
+        "\U0001faf0":                 "hand_with_index_finger_and_thumb_crossed",
+        "\U0001f91f":                 "love_you_gesture",
+        "\U0001f918":                 "metal",
+        "\U0001f919":                 "call_me_hand",
+        "\U0001f448":                 "point_left",
+        "\U0001f449":                 "point_right",
+        "\U0001f446":                 "point_up_2",
+        "\U0001f595":                 "middle_finger",
+        "\U0001f447":                 "point_down",
+        "\u261d":                     "point_up",
+        "\U0001faf5":                 "index_pointing_at_the_viewer",
+        "\U0001f44d":                 "+1",
+        "\U0001f44e":                 "-1",
+        "\u270a":                     "fist_raised",
+        "\U0001f44a":                 "fist_oncoming",
+        "\U0001f91b":                 "fist_left",
+        "\U0001f91c":                 "fist_right",
+        "\U0001f44f":                 "clap",
+        "\U0001f64c":                 "raised_hands",
+        "\U0001faf6":                 "heart_hands",
+        "\U0001f450":                 "open_hands",
+        "\U0001f932":                 "palms_up_together",
+        "\U0001f91d":                 "handshake",
+        "\U0001f64f":                 "pray",
+        "\u270d":                     "writing_hand",
+        "\U0001f485":                 "nail_care",
+        "\U0001f933":                 "selfie",
+        "\U0001f4aa":                 "muscle",
+        "\U0001f9be":                 "mechanical_arm",
+        "\U0001f9bf":                 "mechanical_leg",
+        "\U0001f9b5":                 "leg",
+        "\U0001f9b6":                 "foot",
+        "\U0001f442":                 "ear",
+        "\U0001f9bb":                 "ear_with_hearing_aid",
+        "\U0001f443":                 "nose",
+        "\U0001f9e0":                 "brain",
+        "\U0001fac0":                 "anatomical_heart",
+        "\U0001fac1":                 "lungs",
+        "\U0001f9b7":                 "tooth",
+        "\U0001f9b4":                 "bone",
+        "\U0001f440":                 "eyes",
+        "\U0001f441":                 "eye",
+        "\U0001f445":                 "tongue",
+        "\U0001f444":                 "lips",
+        "\U0001fae6":                 "biting_lip",
+        "\U0001f476":                 "baby",
+        "\U0001f9d2":                 "child",
+        "\U0001f466":                 "boy",
+        "\U0001f467":                 "girl",
+        "\U0001f9d1":                 "adult",
+        "\U0001f471":                 "blond_haired_person",
+        "\U0001f468":                 "man",
+        "\U0001f9d4":                 "bearded_person",
+        "\U0001f9d4\u200d\u2642":     "man_beard",
+        "\U0001f9d4\u200d\u2640":     "woman_beard",
+        "\U0001f468\u200d\U0001f9b0": "red_haired_man",
+        "\U0001f468\u200d\U0001f9b1": "curly_haired_man",
+        "\U0001f468\u200d\U0001f9b3": "white_haired_man",
+        "\U0001f468\u200d\U0001f9b2": "bald_man",
+        "\U0001f469":                 "woman",
+        "\U0001f469\u200d\U0001f9b0": "red_haired_woman",
+        "\U0001f9d1\u200d\U0001f9b0": "person_red_hair",
+        "\U0001f469\u200d\U0001f9b1": "curly_haired_woman",
+        "\U0001f9d1\u200d\U0001f9b1": "person_curly_hair",
+        "\U0001f469\u200d\U0001f9b3": "white_haired_woman",
+        "\U0001f9d1\u200d\U0001f9b3": "person_white_hair",
+        "\U0001f469\u200d\U0001f9b2": "bald_woman",
+        "\U0001f9d1\u200d\U0001f9b2": "person_bald",
+        "\U0001f471\u200d\u2640":     "blond_haired_woman",
+        "\U0001f471\u200d\u2642":     "blond_haired_man",
+        "\U0001f9d3":                 "older_adult",
+        "\U0001f474":                 "older_man",
+        "\U0001f475":                 "older_woman",
+        "\U0001f64d":                 "frowning_person",
+        "\U0001f64d\u200d\u2642":     "frowning_man",
+        "\U0001f64d\u200d\u2640":     "frowning_woman",
+        "\U0001f64e":                 "pouting_face",
+        "\U0001f64e\u200d\u2642":     "pouting_man",
+        "\U0001f64e\u200d\u2640":     "pouting_woman",
+        "\U0001f645":                 "no_good",
+        "\U0001f645\u200d\u2642":     "no_good_man",
+        "\U0001f645\u200d\u2640":     "no_good_woman",
+        "\U0001f646":                 "ok_person",
+        "\U0001f646\u200d\u2642":     "ok_man",
+        "\U0001f646\u200d\u2640":     "ok_woman",
+        "\U0001f481":                 "tipping_hand_person",
+        "\U0001f481\u200d\u2642":     "tipping_hand_man",
+        "\U0001f481\u200d\u2640":     "tipping_hand_woman",
+        "\U0001f64b":                 "raising_hand",
+        "\U0001f64b\u200d\u2642":     "raising_hand_man",
+        "\U0001f64b\u200d\u2640":     "raising_hand_woman",
+        "\U0001f9cf":                 "deaf_person",
+        "\U0001f9cf\u200d\u2642":     "deaf_man",
+        "\U0001f9cf\u200d\u2640":     "deaf_woman",
+        "\U0001f647":                 "bow",
+        "\U0001f647\u200d\u2642":     "bowing_man",
+        "\U0001f647\u200d\u2640":     "bowing_woman",
+        "\U0001f926":                 "facepalm",
+        "\U0001f926\u200d\u2642":     "man_facepalming",
+        "\U0001f926\u200d\u2640":     "woman_facepalming",
+        "\U0001f937":                 "shrug",
+        "\U0001f937\u200d\u2642":     "man_shrugging",
+        "\U0001f937\u200d\u2640":     "woman_shrugging",
+        "\U0001f9d1\u200d\u2695":     "health_worker",
+        "\U0001f468\u200d\u2695":     "man_health_worker",
+        "\U0001f469\u200d\u2695":     "woman_health_worker",
+        "\U0001f9d1\u200d\U0001f393": "student",
+        "\U0001f468\u200d\U0001f393": "man_student",
+        "\U0001f469\u200d\U0001f393": "woman_student",
+        "\U0001f9d1\u200d\U0001f3eb": "teacher",
+        "\U0001f468\u200d\U0001f3eb": "man_teacher",
+        "\U0001f469\u200d\U0001f3eb": "woman_teacher",
+        "\U0001f9d1\u200d\u2696":     "judge",
+        "\U0001f468\u200d\u2696":     "man_judge",
+        "\U0001f469\u200d\u2696":     "woman_judge",
+        "\U0001f9d1\u200d\U0001f33e": "farmer",
+        "\U0001f468\u200d\U0001f33e": "man_farmer",
+        "\U0001f469\u200d\U0001f33e": "woman_farmer",
+        "\U0001f9d1\u200d\U0001f373": "cook",
+        "\U0001f468\u200d\U0001f373": "man_cook",
+        "\U0001f469\u200d\U0001f373": "woman_cook",
+        "\U0001f9d1\u200d\U0001f527": "mechanic",
+        "\U0001f468\u200d\U0001f527": "man_mechanic",
+        "\U0001f469\u200d\U0001f527": "woman_mechanic",
+        "\U0001f9d1\u200d\U0001f3ed": "factory_worker",
+        "\U0001f468\u200d\U0001f3ed": "man_factory_worker",
+        "\U0001f469\u200d\U0001f3ed": "woman_factory_worker",
+        "\U0001f9d1\u200d\U0001f4bc": "office_worker",
+        "\U0001f468\u200d\U0001f4bc": "man_office_worker",
+        "\U0001f469\u200d\U0001f4bc": "woman_office_worker",
+        "\U0001f9d1\u200d\U0001f52c": "scientist",
+        "\U0001f468\u200d\U0001f52c": "man_scientist",
+        "\U0001f469\u200d\U0001f52c": "woman_scientist",
+        "\U0001f9d1\u200d\U0001f4bb": "technologist",
+        "\U0001f468\u200d\U0001f4bb": "man_technologist",
+        "\U0001f469\u200d\U0001f4bb": "woman_technologist",
+        "\U0001f9d1\u200d\U0001f3a4": "singer",
+        "\U0001f468\u200d\U0001f3a4": "man_singer",
+        "\U0001f469\u200d\U0001f3a4": "woman_singer",
+        "\U0001f9d1\u200d\U0001f3a8": "artist",
+        "\U0001f468\u200d\U0001f3a8": "man_artist",
+        "\U0001f469\u200d\U0001f3a8": "woman_artist",
+        "\U0001f9d1\u200d\u2708":     "pilot",
+        "\U0001f468\u200d\u2708":     "man_pilot",
+        "\U0001f469\u200d\u2708":     "woman_pilot",
+        "\U0001f9d1\u200d\U0001f680": "astronaut",
+        "\U0001f468\u200d\U0001f680": "man_astronaut",
+        "\U0001f469\u200d\U0001f680": "woman_astronaut",
+        "\U0001f9d1\u200d\U0001f692": "firefighter",
+        "\U0001f468\u200d\U0001f692": "man_firefighter",
+        "\U0001f469\u200d\U0001f692": "woman_firefighter",
+        "\U0001f46e":                 "police_officer",
+        "\U0001f46e\u200d\u2642":     "policeman",
+        "\U0001f46e\u200d\u2640":     "policewoman",
+        "\U0001f575":                 "detective",
+        "\U0001f575\u200d\u2642":     "male_detective",
+        "\U0001f575\u200d\u2640":     "female_detective",
+        "\U0001f482":                 "guard",
+        "\U0001f482\u200d\u2642":     "guardsman",
+        "\U0001f482\u200d\u2640":     "guardswoman",
+        "\U0001f977":                 "ninja",
+        "\U0001f477":                 "construction_worker",
+        "\U0001f477\u200d\u2642":     "construction_worker_man",
+        "\U0001f477\u200d\u2640":     "construction_worker_woman",
+        "\U0001fac5":                 "person_with_crown",
+        "\U0001f934":                 "prince",
+        "\U0001f478":                 "princess",
+        "\U0001f473":                 "person_with_turban",
+        "\U0001f473\u200d\u2642":     "man_with_turban",
+        "\U0001f473\u200d\u2640":     "woman_with_turban",
+        "\U0001f472":                 "man_with_gua_pi_mao",
+        "\U0001f9d5":                 "woman_with_headscarf",
+        "\U0001f935":                 "person_in_tuxedo",
+        "\U0001f935\u200d\u2642":     "man_in_tuxedo",
+        "\U0001f935\u200d\u2640":     "woman_in_tuxedo",
+        "\U0001f470":                 "person_with_veil",
+        "\U0001f470\u200d\u2642":     "man_with_veil",
+        "\U0001f470\u200d\u2640":     "woman_with_veil",
+        "\U0001f930":                 "pregnant_woman",
+        "\U0001fac3":                 "pregnant_man",
+        "\U0001fac4":                 "pregnant_person",
+        "\U0001f931":                 "breast_feeding",
+        "\U0001f469\u200d\U0001f37c": "woman_feeding_baby",
+        "\U0001f468\u200d\U0001f37c": "man_feeding_baby",
+        "\U0001f9d1\u200d\U0001f37c": "person_feeding_baby",
+        "\U0001f47c":                 "angel",
+        "\U0001f385":                 "santa",
+        "\U0001f936":                 "mrs_claus",
+        "\U0001f9d1\u200d\U0001f384": "mx_claus",
+        "\U0001f9b8":                 "superhero",
+        "\U0001f9b8\u200d\u2642":     "superhero_man",
+        "\U0001f9b8\u200d\u2640":     "superhero_woman",
+        "\U0001f9b9":                 "supervillain",
+        "\U0001f9b9\u200d\u2642":     "supervillain_man",
+        "\U0001f9b9\u200d\u2640":     "supervillain_woman",
+        "\U0001f9d9":                 "mage",
+        "\U0001f9d9\u200d\u2642":     "mage_man",
+        "\U0001f9d9\u200d\u2640":     "mage_woman",
+        "\U0001f9da":                 "fairy",
+        "\U0001f9da\u200d\u2642":     "fairy_man",
+        "\U0001f9da\u200d\u2640":     "fairy_woman",
+        "\U0001f9db":                 "vampire",
+        "\U0001f9db\u200d\u2642":     "vampire_man",
+        "\U0001f9db\u200d\u2640":     "vampire_woman",
+        "\U0001f9dc":                 "merperson",
+        "\U0001f9dc\u200d\u2642":     "merman",
+        "\U0001f9dc\u200d\u2640":     "mermaid",
+        "\U0001f9dd":                 "elf",
+        "\U0001f9dd\u200d\u2642":     "elf_man",
+        "\U0001f9dd\u200d\u2640":     "elf_woman",
+        "\U0001f9de":                 "genie",
+        "\U0001f9de\u200d\u2642":     "genie_man",
+        "\U0001f9de\u200d\u2640":     "genie_woman",
+        "\U0001f9df":                 "zombie",
+        "\U0001f9df\u200d\u2642":     "zombie_man",
+        "\U0001f9df\u200d\u2640":     "zombie_woman",
+        "\U0001f9cc":                 "troll",
+        "\U0001f486":                 "massage",
+        "\U0001f486\u200d\u2642":     "massage_man",
+        "\U0001f486\u200d\u2640":     "massage_woman",
+        "\U0001f487":                 "haircut",
+        "\U0001f487\u200d\u2642":     "haircut_man",
+        "\U0001f487\u200d\u2640":     "haircut_woman",
+        "\U0001f6b6":                 "walking",
+        "\U0001f6b6\u200d\u2642":     "walking_man",
+        "\U0001f6b6\u200d\u2640":     "walking_woman",
+        "\U0001f9cd":                 "standing_person",
+        "\U0001f9cd\u200d\u2642":     "standing_man",
+        "\U0001f9cd\u200d\u2640":     "standing_woman",
+        "\U0001f9ce":                 "kneeling_person",
+        "\U0001f9ce\u200d\u2642":     "kneeling_man",
+        "\U0001f9ce\u200d\u2640":     "kneeling_woman",
+        "\U0001f9d1\u200d\U0001f9af": "person_with_probing_cane",
+        "\U0001f468\u200d\U0001f9af": "man_with_probing_cane",
+        "\U0001f469\u200d\U0001f9af": "woman_with_probing_cane",
+        "\U0001f9d1\u200d\U0001f9bc": "person_in_motorized_wheelchair",
+        "\U0001f468\u200d\U0001f9bc": "man_in_motorized_wheelchair",
+        "\U0001f469\u200d\U0001f9bc": "woman_in_motorized_wheelchair",
+        "\U0001f9d1\u200d\U0001f9bd": "person_in_manual_wheelchair",
+        "\U0001f468\u200d\U0001f9bd": "man_in_manual_wheelchair",
+        "\U0001f469\u200d\U0001f9bd": "woman_in_manual_wheelchair",
+        "\U0001f3c3":                 "runner",
+        "\U0001f3c3\u200d\u2642":     "running_man",
+        "\U0001f3c3\u200d\u2640":     "running_woman",
+        "\U0001f483":                 "woman_dancing",
+        "\U0001f57a":                 "man_dancing",
+        "\U0001f574":                 "business_suit_levitating",
+        "\U0001f46f":                 "dancers",
+        "\U0001f46f\u200d\u2642":     "dancing_men",
+        "\U0001f46f\u200d\u2640":     "dancing_women",
+        "\U0001f9d6":                 "sauna_person",
+        "\U0001f9d6\u200d\u2642":     "sauna_man",
+        "\U0001f9d6\u200d\u2640":     "sauna_woman",
+        "\U0001f9d7":                 "climbing",
+        "\U0001f9d7\u200d\u2642":     "climbing_man",
+        "\U0001f9d7\u200d\u2640":     "climbing_woman",
+        "\U0001f93a":                 "person_fencing",
+        "\U0001f3c7":                 "horse_racing",
+        "\u26f7":                     "skier",
+        "\U0001f3c2":                 "snowboarder",
+        "\U0001f3cc":                 "golfing",
+        "\U0001f3cc\u200d\u2642":     "golfing_man",
+        "\U0001f3cc\u200d\u2640":     "golfing_woman",
+        "\U0001f3c4":                 "surfer",
+        "\U0001f3c4\u200d\u2642":     "surfing_man",
+        "\U0001f3c4\u200d\u2640":     "surfing_woman",
+        "\U0001f6a3":                 "rowboat",
+        "\U0001f6a3\u200d\u2642":     "rowing_man",
+        "\U0001f6a3\u200d\u2640":     "rowing_woman",
+        "\U0001f3ca":                 "swimmer",
+        "\U0001f3ca\u200d\u2642":     "swimming_man",
+        "\U0001f3ca\u200d\u2640":     "swimming_woman",
+        "\u26f9":                     "bouncing_ball_person",
+        "\u26f9\u200d\u2642":         "bouncing_ball_man",
+        "\u26f9\u200d\u2640":         "bouncing_ball_woman",
+        "\U0001f3cb":                 "weight_lifting",
+        "\U0001f3cb\u200d\u2642":     "weight_lifting_man",
+        "\U0001f3cb\u200d\u2640":     "weight_lifting_woman",
+        "\U0001f6b4":                 "bicyclist",
+        "\U0001f6b4\u200d\u2642":     "biking_man",
+        "\U0001f6b4\u200d\u2640":     "biking_woman",
+        "\U0001f6b5":                 "mountain_bicyclist",
+        "\U0001f6b5\u200d\u2642":     "mountain_biking_man",
+        "\U0001f6b5\u200d\u2640":     "mountain_biking_woman",
+        "\U0001f938":                 "cartwheeling",
+        "\U0001f938\u200d\u2642":     "man_cartwheeling",
+        "\U0001f938\u200d\u2640":     "woman_cartwheeling",
+        "\U0001f93c":                 "wrestling",
+        "\U0001f93c\u200d\u2642":     "men_wrestling",
+        "\U0001f93c\u200d\u2640":     "women_wrestling",
+        "\U0001f93d":                 "water_polo",
+        "\U0001f93d\u200d\u2642":     "man_playing_water_polo",
+        "\U0001f93d\u200d\u2640":     "woman_playing_water_polo",
+        "\U0001f93e":                 "handball_person",
+        "\U0001f93e\u200d\u2642":     "man_playing_handball",
+        "\U0001f93e\u200d\u2640":     "woman_playing_handball",
+        "\U0001f939":                 "juggling_person",
+        "\U0001f939\u200d\u2642":     "man_juggling",
+        "\U0001f939\u200d\u2640":     "woman_juggling",
+        "\U0001f9d8":                 "lotus_position",
+        "\U0001f9d8\u200d\u2642":     "lotus_position_man",
+        "\U0001f9d8\u200d\u2640":     "lotus_position_woman",
+        "\U0001f6c0":                 "bath",
+        "\U0001f6cc":                 "sleeping_bed",
+        "\U0001f9d1\u200d\U0001f91d\u200d\U0001f9d1": "people_holding_hands",
+        "\U0001f46d": "two_women_holding_hands",
+        "\U0001f46b": "couple",
+        "\U0001f46c": "two_men_holding_hands",
+        "\U0001f48f": "couplekiss",
+        "\U0001f469\u200d\u2764\u200d\U0001f48b\u200d\U0001f468": "couplekiss_man_woman",
+        "\U0001f468\u200d\u2764\u200d\U0001f48b\u200d\U0001f468": "couplekiss_man_man",
+        "\U0001f469\u200d\u2764\u200d\U0001f48b\u200d\U0001f469": "couplekiss_woman_woman",
+        "\U0001f491":                                                 "couple_with_heart",
+        "\U0001f469\u200d\u2764\u200d\U0001f468":                     "couple_with_heart_woman_man",
+        "\U0001f468\u200d\u2764\u200d\U0001f468":                     "couple_with_heart_man_man",
+        "\U0001f469\u200d\u2764\u200d\U0001f469":                     "couple_with_heart_woman_woman",
+        "\U0001f46a":                                                 "family",
+        "\U0001f468\u200d\U0001f469\u200d\U0001f466":                 "family_man_woman_boy",
+        "\U0001f468\u200d\U0001f469\u200d\U0001f467":                 "family_man_woman_girl",
+        "\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466": "family_man_woman_girl_boy",
+        "\U0001f468\u200d\U0001f469\u200d\U0001f466\u200d\U0001f466": "family_man_woman_boy_boy",
+        "\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f467": "family_man_woman_girl_girl",
+        "\U0001f468\u200d\U0001f468\u200d\U0001f466":                 "family_man_man_boy",
+        "\U0001f468\u200d\U0001f468\u200d\U0001f467":                 "family_man_man_girl",
+        "\U0001f468\u200d\U0001f468\u200d\U0001f467\u200d\U0001f466": "family_man_man_girl_boy",
+        "\U0001f468\u200d\U0001f468\u200d\U0001f466\u200d\U0001f466": "family_man_man_boy_boy",
+        "\U0001f468\u200d\U0001f468\u200d\U0001f467\u200d\U0001f467": "family_man_man_girl_girl",
+        "\U0001f469\u200d\U0001f469\u200d\U0001f466":                 "family_woman_woman_boy",
+        "\U0001f469\u200d\U0001f469\u200d\U0001f467":                 "family_woman_woman_girl",
+        "\U0001f469\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466": "family_woman_woman_girl_boy",
+        "\U0001f469\u200d\U0001f469\u200d\U0001f466\u200d\U0001f466": "family_woman_woman_boy_boy",
+        "\U0001f469\u200d\U0001f469\u200d\U0001f467\u200d\U0001f467": "family_woman_woman_girl_girl",
+        "\U0001f468\u200d\U0001f466":                                 "family_man_boy",
+        "\U0001f468\u200d\U0001f466\u200d\U0001f466":                 "family_man_boy_boy",
+        "\U0001f468\u200d\U0001f467":                                 "family_man_girl",
+        "\U0001f468\u200d\U0001f467\u200d\U0001f466":                 "family_man_girl_boy",
+        "\U0001f468\u200d\U0001f467\u200d\U0001f467":                 "family_man_girl_girl",
+        "\U0001f469\u200d\U0001f466":                                 "family_woman_boy",
+        "\U0001f469\u200d\U0001f466\u200d\U0001f466":                 "family_woman_boy_boy",
+        "\U0001f469\u200d\U0001f467":                                 "family_woman_girl",
+        "\U0001f469\u200d\U0001f467\u200d\U0001f466":                 "family_woman_girl_boy",
+        "\U0001f469\u200d\U0001f467\u200d\U0001f467":                 "family_woman_girl_girl",
+        "\U0001f5e3":                 "speaking_head",
+        "\U0001f464":                 "bust_in_silhouette",
+        "\U0001f465":                 "busts_in_silhouette",
+        "\U0001fac2":                 "people_hugging",
+        "\U0001f463":                 "footprints",
+        "\U0001f435":                 "monkey_face",
+        "\U0001f412":                 "monkey",
+        "\U0001f98d":                 "gorilla",
+        "\U0001f9a7":                 "orangutan",
+        "\U0001f436":                 "dog",
+        "\U0001f415":                 "dog2",
+        "\U0001f9ae":                 "guide_dog",
+        "\U0001f415\u200d\U0001f9ba": "service_dog",
+        "\U0001f429":                 "poodle",
+        "\U0001f43a":                 "wolf",
+        "\U0001f98a":                 "fox_face",
+        "\U0001f99d":                 "raccoon",
+        "\U0001f431":                 "cat",
+        "\U0001f408":                 "cat2",
+        "\U0001f408\u200d\u2b1b":     "black_cat",
+        "\U0001f981":                 "lion",
+        "\U0001f42f":                 "tiger",
+        "\U0001f405":                 "tiger2",
+        "\U0001f406":                 "leopard",
+        "\U0001f434":                 "horse",
+        "\U0001face":                 "moose",
+        "\U0001facf":                 "donkey",
+        "\U0001f40e":                 "racehorse",
+        "\U0001f984":                 "unicorn",
+        "\U0001f993":                 "zebra",
+        "\U0001f98c":                 "deer",
+        "\U0001f9ac":                 "bison",
+        "\U0001f42e":                 "cow",
+        "\U0001f402":                 "ox",
+        "\U0001f403":                 "water_buffalo",
+        "\U0001f404":                 "cow2",
+        "\U0001f437":                 "pig",
+        "\U0001f416":                 "pig2",
+        "\U0001f417":                 "boar",
+        "\U0001f43d":                 "pig_nose",
+        "\U0001f40f":                 "ram",
+        "\U0001f411":                 "sheep",
+        "\U0001f410":                 "goat",
+        "\U0001f42a":                 "dromedary_camel",
+        "\U0001f42b":                 "camel",
+        "\U0001f999":                 "llama",
+        "\U0001f992":                 "giraffe",
+        "\U0001f418":                 "elephant",
+        "\U0001f9a3":                 "mammoth",
+        "\U0001f98f":                 "rhinoceros",
+        "\U0001f99b":                 "hippopotamus",
+        "\U0001f42d":                 "mouse",
+        "\U0001f401":                 "mouse2",
+        "\U0001f400":                 "rat",
+        "\U0001f439":                 "hamster",
+        "\U0001f430":                 "rabbit",
+        "\U0001f407":                 "rabbit2",
+        "\U0001f43f":                 "chipmunk",
+        "\U0001f9ab":                 "beaver",
+        "\U0001f994":                 "hedgehog",
+        "\U0001f987":                 "bat",
+        "\U0001f43b":                 "bear",
+        "\U0001f43b\u200d\u2744":     "polar_bear",
+        "\U0001f428":                 "koala",
+        "\U0001f43c":                 "panda_face",
+        "\U0001f9a5":                 "sloth",
+        "\U0001f9a6":                 "otter",
+        "\U0001f9a8":                 "skunk",
+        "\U0001f998":                 "kangaroo",
+        "\U0001f9a1":                 "badger",
+        "\U0001f43e":                 "feet",
+        "\U0001f983":                 "turkey",
+        "\U0001f414":                 "chicken",
+        "\U0001f413":                 "rooster",
+        "\U0001f423":                 "hatching_chick",
+        "\U0001f424":                 "baby_chick",
+        "\U0001f425":                 "hatched_chick",
+        "\U0001f426":                 "bird",
+        "\U0001f427":                 "penguin",
+        "\U0001f54a":                 "dove",
+        "\U0001f985":                 "eagle",
+        "\U0001f986":                 "duck",
+        "\U0001f9a2":                 "swan",
+        "\U0001f989":                 "owl",
+        "\U0001f9a4":                 "dodo",
+        "\U0001fab6":                 "feather",
+        "\U0001f9a9":                 "flamingo",
+        "\U0001f99a":                 "peacock",
+        "\U0001f99c":                 "parrot",
+        "\U0001fabd":                 "wing",
+        "\U0001f426\u200d\u2b1b":     "black_bird",
+        "\U0001fabf":                 "goose",
+        "\U0001f438":                 "frog",
+        "\U0001f40a":                 "crocodile",
+        "\U0001f422":                 "turtle",
+        "\U0001f98e":                 "lizard",
+        "\U0001f40d":                 "snake",
+        "\U0001f432":                 "dragon_face",
+        "\U0001f409":                 "dragon",
+        "\U0001f995":                 "sauropod",
+        "\U0001f996":                 "t-rex",
+        "\U0001f433":                 "whale",
+        "\U0001f40b":                 "whale2",
+        "\U0001f42c":                 "dolphin",
+        "\U0001f9ad":                 "seal",
+        "\U0001f41f":                 "fish",
+        "\U0001f420":                 "tropical_fish",
+        "\U0001f421":                 "blowfish",
+        "\U0001f988":                 "shark",
+        "\U0001f419":                 "octopus",
+        "\U0001f41a":                 "shell",
+        "\U0001fab8":                 "coral",
+        "\U0001fabc":                 "jellyfish",
+        "\U0001f40c":                 "snail",
+        "\U0001f98b":                 "butterfly",
+        "\U0001f41b":                 "bug",
+        "\U0001f41c":                 "ant",
+        "\U0001f41d":                 "bee",
+        "\U0001fab2":                 "beetle",
+        "\U0001f41e":                 "lady_beetle",
+        "\U0001f997":                 "cricket",
+        "\U0001fab3":                 "cockroach",
+        "\U0001f577":                 "spider",
+        "\U0001f578":                 "spider_web",
+        "\U0001f982":                 "scorpion",
+        "\U0001f99f":                 "mosquito",
+        "\U0001fab0":                 "fly",
+        "\U0001fab1":                 "worm",
+        "\U0001f9a0":                 "microbe",
+        "\U0001f490":                 "bouquet",
+        "\U0001f338":                 "cherry_blossom",
+        "\U0001f4ae":                 "white_flower",
+        "\U0001fab7":                 "lotus",
+        "\U0001f3f5":                 "rosette",
+        "\U0001f339":                 "rose",
+        "\U0001f940":                 "wilted_flower",
+        "\U0001f33a":                 "hibiscus",
+        "\U0001f33b":                 "sunflower",
+        "\U0001f33c":                 "blossom",
+        "\U0001f337":                 "tulip",
+        "\U0001fabb":                 "hyacinth",
+        "\U0001f331":                 "seedling",
+        "\U0001fab4":                 "potted_plant",
+        "\U0001f332":                 "evergreen_tree",
+        "\U0001f333":                 "deciduous_tree",
+        "\U0001f334":                 "palm_tree",
+        "\U0001f335":                 "cactus",
+        "\U0001f33e":                 "ear_of_rice",
+        "\U0001f33f":                 "herb",
+        "\u2618":                     "shamrock",
+        "\U0001f340":                 "four_leaf_clover",
+        "\U0001f341":                 "maple_leaf",
+        "\U0001f342":                 "fallen_leaf",
+        "\U0001f343":                 "leaves",
+        "\U0001fab9":                 "empty_nest",
+        "\U0001faba":                 "nest_with_eggs",
+        "\U0001f344":                 "mushroom",
+        "\U0001f347":                 "grapes",
+        "\U0001f348":                 "melon",
+        "\U0001f349":                 "watermelon",
+        "\U0001f34a":                 "tangerine",
+        "\U0001f34b":                 "lemon",
+        "\U0001f34c":                 "banana",
+        "\U0001f34d":                 "pineapple",
+        "\U0001f96d":                 "mango",
+        "\U0001f34e":                 "apple",
+        "\U0001f34f":                 "green_apple",
+        "\U0001f350":                 "pear",
+        "\U0001f351":                 "peach",
+        "\U0001f352":                 "cherries",
+        "\U0001f353":                 "strawberry",
+        "\U0001fad0":                 "blueberries",
+        "\U0001f95d":                 "kiwi_fruit",
+        "\U0001f345":                 "tomato",
+        "\U0001fad2":                 "olive",
+        "\U0001f965":                 "coconut",
+        "\U0001f951":                 "avocado",
+        "\U0001f346":                 "eggplant",
+        "\U0001f954":                 "potato",
+        "\U0001f955":                 "carrot",
+        "\U0001f33d":                 "corn",
+        "\U0001f336":                 "hot_pepper",
+        "\U0001fad1":                 "bell_pepper",
+        "\U0001f952":                 "cucumber",
+        "\U0001f96c":                 "leafy_green",
+        "\U0001f966":                 "broccoli",
+        "\U0001f9c4":                 "garlic",
+        "\U0001f9c5":                 "onion",
+        "\U0001f95c":                 "peanuts",
+        "\U0001fad8":                 "beans",
+        "\U0001f330":                 "chestnut",
+        "\U0001fada":                 "ginger_root",
+        "\U0001fadb":                 "pea_pod",
+        "\U0001f35e":                 "bread",
+        "\U0001f950":                 "croissant",
+        "\U0001f956":                 "baguette_bread",
+        "\U0001fad3":                 "flatbread",
+        "\U0001f968":                 "pretzel",
+        "\U0001f96f":                 "bagel",
+        "\U0001f95e":                 "pancakes",
+        "\U0001f9c7":                 "waffle",
+        "\U0001f9c0":                 "cheese",
+        "\U0001f356":                 "meat_on_bone",
+        "\U0001f357":                 "poultry_leg",
+        "\U0001f969":                 "cut_of_meat",
+        "\U0001f953":                 "bacon",
+        "\U0001f354":                 "hamburger",
+        "\U0001f35f":                 "fries",
+        "\U0001f355":                 "pizza",
+        "\U0001f32d":                 "hotdog",
+        "\U0001f96a":                 "sandwich",
+        "\U0001f32e":                 "taco",
+        "\U0001f32f":                 "burrito",
+        "\U0001fad4":                 "tamale",
+        "\U0001f959":                 "stuffed_flatbread",
+        "\U0001f9c6":                 "falafel",
+        "\U0001f95a":                 "egg",
+        "\U0001f373":                 "fried_egg",
+        "\U0001f958":                 "shallow_pan_of_food",
+        "\U0001f372":                 "stew",
+        "\U0001fad5":                 "fondue",
+        "\U0001f963":                 "bowl_with_spoon",
+        "\U0001f957":                 "green_salad",
+        "\U0001f37f":                 "popcorn",
+        "\U0001f9c8":                 "butter",
+        "\U0001f9c2":                 "salt",
+        "\U0001f96b":                 "canned_food",
+        "\U0001f371":                 "bento",
+        "\U0001f358":                 "rice_cracker",
+        "\U0001f359":                 "rice_ball",
+        "\U0001f35a":                 "rice",
+        "\U0001f35b":                 "curry",
+        "\U0001f35c":                 "ramen",
+        "\U0001f35d":                 "spaghetti",
+        "\U0001f360":                 "sweet_potato",
+        "\U0001f362":                 "oden",
+        "\U0001f363":                 "sushi",
+        "\U0001f364":                 "fried_shrimp",
+        "\U0001f365":                 "fish_cake",
+        "\U0001f96e":                 "moon_cake",
+        "\U0001f361":                 "dango",
+        "\U0001f95f":                 "dumpling",
+        "\U0001f960":                 "fortune_cookie",
+        "\U0001f961":                 "takeout_box",
+        "\U0001f980":                 "crab",
+        "\U0001f99e":                 "lobster",
+        "\U0001f990":                 "shrimp",
+        "\U0001f991":                 "squid",
+        "\U0001f9aa":                 "oyster",
+        "\U0001f366":                 "icecream",
+        "\U0001f367":                 "shaved_ice",
+        "\U0001f368":                 "ice_cream",
+        "\U0001f369":                 "doughnut",
+        "\U0001f36a":                 "cookie",
+        "\U0001f382":                 "birthday",
+        "\U0001f370":                 "cake",
+        "\U0001f9c1":                 "cupcake",
+        "\U0001f967":                 "pie",
+        "\U0001f36b":                 "chocolate_bar",
+        "\U0001f36c":                 "candy",
+        "\U0001f36d":                 "lollipop",
+        "\U0001f36e":                 "custard",
+        "\U0001f36f":                 "honey_pot",
+        "\U0001f37c":                 "baby_bottle",
+        "\U0001f95b":                 "milk_glass",
+        "\u2615":                     "coffee",
+        "\U0001fad6":                 "teapot",
+        "\U0001f375":                 "tea",
+        "\U0001f376":                 "sake",
+        "\U0001f37e":                 "champagne",
+        "\U0001f377":                 "wine_glass",
+        "\U0001f378":                 "cocktail",
+        "\U0001f379":                 "tropical_drink",
+        "\U0001f37a":                 "beer",
+        "\U0001f37b":                 "beers",
+        "\U0001f942":                 "clinking_glasses",
+        "\U0001f943":                 "tumbler_glass",
+        "\U0001fad7":                 "pouring_liquid",
+        "\U0001f964":                 "cup_with_straw",
+        "\U0001f9cb":                 "bubble_tea",
+        "\U0001f9c3":                 "beverage_box",
+        "\U0001f9c9":                 "mate",
+        "\U0001f9ca":                 "ice_cube",
+        "\U0001f962":                 "chopsticks",
+        "\U0001f37d":                 "plate_with_cutlery",
+        "\U0001f374":                 "fork_and_knife",
+        "\U0001f944":                 "spoon",
+        "\U0001f52a":                 "hocho",
+        "\U0001fad9":                 "jar",
+        "\U0001f3fa":                 "amphora",
+        "\U0001f30d":                 "earth_africa",
+        "\U0001f30e":                 "earth_americas",
+        "\U0001f30f":                 "earth_asia",
+        "\U0001f310":                 "globe_with_meridians",
+        "\U0001f5fa":                 "world_map",
+        "\U0001f5fe":                 "japan",
+        "\U0001f9ed":                 "compass",
+        "\U0001f3d4":                 "mountain_snow",
+        "\u26f0":                     "mountain",
+        "\U0001f30b":                 "volcano",
+        "\U0001f5fb":                 "mount_fuji",
+        "\U0001f3d5":                 "camping",
+        "\U0001f3d6":                 "beach_umbrella",
+        "\U0001f3dc":                 "desert",
+        "\U0001f3dd":                 "desert_island",
+        "\U0001f3de":                 "national_park",
+        "\U0001f3df":                 "stadium",
+        "\U0001f3db":                 "classical_building",
+        "\U0001f3d7":                 "building_construction",
+        "\U0001f9f1":                 "bricks",
+        "\U0001faa8":                 "rock",
+        "\U0001fab5":                 "wood",
+        "\U0001f6d6":                 "hut",
+        "\U0001f3d8":                 "houses",
+        "\U0001f3da":                 "derelict_house",
+        "\U0001f3e0":                 "house",
+        "\U0001f3e1":                 "house_with_garden",
+        "\U0001f3e2":                 "office",
+        "\U0001f3e3":                 "post_office",
+        "\U0001f3e4":                 "european_post_office",
+        "\U0001f3e5":                 "hospital",
+        "\U0001f3e6":                 "bank",
+        "\U0001f3e8":                 "hotel",
+        "\U0001f3e9":                 "love_hotel",
+        "\U0001f3ea":                 "convenience_store",
+        "\U0001f3eb":                 "school",
+        "\U0001f3ec":                 "department_store",
+        "\U0001f3ed":                 "factory",
+        "\U0001f3ef":                 "japanese_castle",
+        "\U0001f3f0":                 "european_castle",
+        "\U0001f492":                 "wedding",
+        "\U0001f5fc":                 "tokyo_tower",
+        "\U0001f5fd":                 "statue_of_liberty",
+        "\u26ea":                     "church",
+        "\U0001f54c":                 "mosque",
+        "\U0001f6d5":                 "hindu_temple",
+        "\U0001f54d":                 "synagogue",
+        "\u26e9":                     "shinto_shrine",
+        "\U0001f54b":                 "kaaba",
+        "\u26f2":                     "fountain",
+        "\u26fa":                     "tent",
+        "\U0001f301":                 "foggy",
+        "\U0001f303":                 "night_with_stars",
+        "\U0001f3d9":                 "cityscape",
+        "\U0001f304":                 "sunrise_over_mountains",
+        "\U0001f305":                 "sunrise",
+        "\U0001f306":                 "city_sunset",
+        "\U0001f307":                 "city_sunrise",
+        "\U0001f309":                 "bridge_at_night",
+        "\u2668":                     "hotsprings",
+        "\U0001f3a0":                 "carousel_horse",
+        "\U0001f6dd":                 "playground_slide",
+        "\U0001f3a1":                 "ferris_wheel",
+        "\U0001f3a2":                 "roller_coaster",
+        "\U0001f488":                 "barber",
+        "\U0001f3aa":                 "circus_tent",
+        "\U0001f682":                 "steam_locomotive",
+        "\U0001f683":                 "railway_car",
+        "\U0001f684":                 "bullettrain_side",
+        "\U0001f685":                 "bullettrain_front",
+        "\U0001f686":                 "train2",
+        "\U0001f687":                 "metro",
+        "\U0001f688":                 "light_rail",
+        "\U0001f689":                 "station",
+        "\U0001f68a":                 "tram",
+        "\U0001f69d":                 "monorail",
+        "\U0001f69e":                 "mountain_railway",
+        "\U0001f68b":                 "train",
+        "\U0001f68c":                 "bus",
+        "\U0001f68d":                 "oncoming_bus",
+        "\U0001f68e":                 "trolleybus",
+        "\U0001f690":                 "minibus",
+        "\U0001f691":                 "ambulance",
+        "\U0001f692":                 "fire_engine",
+        "\U0001f693":                 "police_car",
+        "\U0001f694":                 "oncoming_police_car",
+        "\U0001f695":                 "taxi",
+        "\U0001f696":                 "oncoming_taxi",
+        "\U0001f697":                 "car",
+        "\U0001f698":                 "oncoming_automobile",
+        "\U0001f699":                 "blue_car",
+        "\U0001f6fb":                 "pickup_truck",
+        "\U0001f69a":                 "truck",
+        "\U0001f69b":                 "articulated_lorry",
+        "\U0001f69c":                 "tractor",
+        "\U0001f3ce":                 "racing_car",
+        "\U0001f3cd":                 "motorcycle",
+        "\U0001f6f5":                 "motor_scooter",
+        "\U0001f9bd":                 "manual_wheelchair",
+        "\U0001f9bc":                 "motorized_wheelchair",
+        "\U0001f6fa":                 "auto_rickshaw",
+        "\U0001f6b2":                 "bike",
+        "\U0001f6f4":                 "kick_scooter",
+        "\U0001f6f9":                 "skateboard",
+        "\U0001f6fc":                 "roller_skate",
+        "\U0001f68f":                 "busstop",
+        "\U0001f6e3":                 "motorway",
+        "\U0001f6e4":                 "railway_track",
+        "\U0001f6e2":                 "oil_drum",
+        "\u26fd":                     "fuelpump",
+        "\U0001f6de":                 "wheel",
+        "\U0001f6a8":                 "rotating_light",
+        "\U0001f6a5":                 "traffic_light",
+        "\U0001f6a6":                 "vertical_traffic_light",
+        "\U0001f6d1":                 "stop_sign",
+        "\U0001f6a7":                 "construction",
+        "\u2693":                     "anchor",
+        "\U0001f6df":                 "ring_buoy",
+        "\u26f5":                     "boat",
+        "\U0001f6f6":                 "canoe",
+        "\U0001f6a4":                 "speedboat",
+        "\U0001f6f3":                 "passenger_ship",
+        "\u26f4":                     "ferry",
+        "\U0001f6e5":                 "motor_boat",
+        "\U0001f6a2":                 "ship",
+        "\u2708":                     "airplane",
+        "\U0001f6e9":                 "small_airplane",
+        "\U0001f6eb":                 "flight_departure",
+        "\U0001f6ec":                 "flight_arrival",
+        "\U0001fa82":                 "parachute",
+        "\U0001f4ba":                 "seat",
+        "\U0001f681":                 "helicopter",
+        "\U0001f69f":                 "suspension_railway",
+        "\U0001f6a0":                 "mountain_cableway",
+        "\U0001f6a1":                 "aerial_tramway",
+        "\U0001f6f0":                 "artificial_satellite",
+        "\U0001f680":                 "rocket",
+        "\U0001f6f8":                 "flying_saucer",
+        "\U0001f6ce":                 "bellhop_bell",
+        "\U0001f9f3":                 "luggage",
+        "\u231b":                     "hourglass",
+        "\u23f3":                     "hourglass_flowing_sand",
+        "\u231a":                     "watch",
+        "\u23f0":                     "alarm_clock",
+        "\u23f1":                     "stopwatch",
+        "\u23f2":                     "timer_clock",
+        "\U0001f570":                 "mantelpiece_clock",
+        "\U0001f55b":                 "clock12",
+        "\U0001f567":                 "clock1230",
+        "\U0001f550":                 "clock1",
+        "\U0001f55c":                 "clock130",
+        "\U0001f551":                 "clock2",
+        "\U0001f55d":                 "clock230",
+        "\U0001f552":                 "clock3",
+        "\U0001f55e":                 "clock330",
+        "\U0001f553":                 "clock4",
+        "\U0001f55f":                 "clock430",
+        "\U0001f554":                 "clock5",
+        "\U0001f560":                 "clock530",
+        "\U0001f555":                 "clock6",
+        "\U0001f561":                 "clock630",
+        "\U0001f556":                 "clock7",
+        "\U0001f562":                 "clock730",
+        "\U0001f557":                 "clock8",
+        "\U0001f563":                 "clock830",
+        "\U0001f558":                 "clock9",
+        "\U0001f564":                 "clock930",
+        "\U0001f559":                 "clock10",
+        "\U0001f565":                 "clock1030",
+        "\U0001f55a":                 "clock11",
+        "\U0001f566":                 "clock1130",
+        "\U0001f311":                 "new_moon",
+        "\U0001f312":                 "waxing_crescent_moon",
+        "\U0001f313":                 "first_quarter_moon",
+        "\U0001f314":                 "moon",
+        "\U0001f315":                 "full_moon",
+        "\U0001f316":                 "waning_gibbous_moon",
+        "\U0001f317":                 "last_quarter_moon",
+        "\U0001f318":                 "waning_crescent_moon",
+        "\U0001f319":                 "crescent_moon",
+        "\U0001f31a":                 "new_moon_with_face",
+        "\U0001f31b":                 "first_quarter_moon_with_face",
+        "\U0001f31c":                 "last_quarter_moon_with_face",
+        "\U0001f321":                 "thermometer",
+        "\u2600":                     "sunny",
+        "\U0001f31d":                 "full_moon_with_face",
+        "\U0001f31e":                 "sun_with_face",
+        "\U0001fa90":                 "ringed_planet",
+        "\u2b50":                     "star",
+        "\U0001f31f":                 "star2",
+        "\U0001f320":                 "stars",
+        "\U0001f30c":                 "milky_way",
+        "\u2601":                     "cloud",
+        "\u26c5":                     "partly_sunny",
+        "\u26c8":                     "cloud_with_lightning_and_rain",
+        "\U0001f324":                 "sun_behind_small_cloud",
+        "\U0001f325":                 "sun_behind_large_cloud",
+        "\U0001f326":                 "sun_behind_rain_cloud",
+        "\U0001f327":                 "cloud_with_rain",
+        "\U0001f328":                 "cloud_with_snow",
+        "\U0001f329":                 "cloud_with_lightning",
+        "\U0001f32a":                 "tornado",
+        "\U0001f32b":                 "fog",
+        "\U0001f32c":                 "wind_face",
+        "\U0001f300":                 "cyclone",
+        "\U0001f308":                 "rainbow",
+        "\U0001f302":                 "closed_umbrella",
+        "\u2602":                     "open_umbrella",
+        "\u2614":                     "umbrella",
+        "\u26f1":                     "parasol_on_ground",
+        "\u26a1":                     "zap",
+        "\u2744":                     "snowflake",
+        "\u2603":                     "snowman_with_snow",
+        "\u26c4":                     "snowman",
+        "\u2604":                     "comet",
+        "\U0001f525":                 "fire",
+        "\U0001f4a7":                 "droplet",
+        "\U0001f30a":                 "ocean",
+        "\U0001f383":                 "jack_o_lantern",
+        "\U0001f384":                 "christmas_tree",
+        "\U0001f386":                 "fireworks",
+        "\U0001f387":                 "sparkler",
+        "\U0001f9e8":                 "firecracker",
+        "\u2728":                     "sparkles",
+        "\U0001f388":                 "balloon",
+        "\U0001f389":                 "tada",
+        "\U0001f38a":                 "confetti_ball",
+        "\U0001f38b":                 "tanabata_tree",
+        "\U0001f38d":                 "bamboo",
+        "\U0001f38e":                 "dolls",
+        "\U0001f38f":                 "flags",
+        "\U0001f390":                 "wind_chime",
+        "\U0001f391":                 "rice_scene",
+        "\U0001f9e7":                 "red_envelope",
+        "\U0001f380":                 "ribbon",
+        "\U0001f381":                 "gift",
+        "\U0001f397":                 "reminder_ribbon",
+        "\U0001f39f":                 "tickets",
+        "\U0001f3ab":                 "ticket",
+        "\U0001f396":                 "medal_military",
+        "\U0001f3c6":                 "trophy",
+        "\U0001f3c5":                 "medal_sports",
+        "\U0001f947":                 "1st_place_medal",
+        "\U0001f948":                 "2nd_place_medal",
+        "\U0001f949":                 "3rd_place_medal",
+        "\u26bd":                     "soccer",
+        "\u26be":                     "baseball",
+        "\U0001f94e":                 "softball",
+        "\U0001f3c0":                 "basketball",
+        "\U0001f3d0":                 "volleyball",
+        "\U0001f3c8":                 "football",
+        "\U0001f3c9":                 "rugby_football",
+        "\U0001f3be":                 "tennis",
+        "\U0001f94f":                 "flying_disc",
+        "\U0001f3b3":                 "bowling",
+        "\U0001f3cf":                 "cricket_game",
+        "\U0001f3d1":                 "field_hockey",
+        "\U0001f3d2":                 "ice_hockey",
+        "\U0001f94d":                 "lacrosse",
+        "\U0001f3d3":                 "ping_pong",
+        "\U0001f3f8":                 "badminton",
+        "\U0001f94a":                 "boxing_glove",
+        "\U0001f94b":                 "martial_arts_uniform",
+        "\U0001f945":                 "goal_net",
+        "\u26f3":                     "golf",
+        "\u26f8":                     "ice_skate",
+        "\U0001f3a3":                 "fishing_pole_and_fish",
+        "\U0001f93f":                 "diving_mask",
+        "\U0001f3bd":                 "running_shirt_with_sash",
+        "\U0001f3bf":                 "ski",
+        "\U0001f6f7":                 "sled",
+        "\U0001f94c":                 "curling_stone",
+        "\U0001f3af":                 "dart",
+        "\U0001fa80":                 "yo_yo",
+        "\U0001fa81":                 "kite",
+        "\U0001f52b":                 "gun",
+        "\U0001f3b1":                 "8ball",
+        "\U0001f52e":                 "crystal_ball",
+        "\U0001fa84":                 "magic_wand",
+        "\U0001f3ae":                 "video_game",
+        "\U0001f579":                 "joystick",
+        "\U0001f3b0":                 "slot_machine",
+        "\U0001f3b2":                 "game_die",
+        "\U0001f9e9":                 "jigsaw",
+        "\U0001f9f8":                 "teddy_bear",
+        "\U0001fa85":                 "pinata",
+        "\U0001faa9":                 "mirror_ball",
+        "\U0001fa86":                 "nesting_dolls",
+        "\u2660":                     "spades",
+        "\u2665":                     "hearts",
+        "\u2666":                     "diamonds",
+        "\u2663":                     "clubs",
+        "\u265f":                     "chess_pawn",
+        "\U0001f0cf":                 "black_joker",
+        "\U0001f004":                 "mahjong",
+        "\U0001f3b4":                 "flower_playing_cards",
+        "\U0001f3ad":                 "performing_arts",
+        "\U0001f5bc":                 "framed_picture",
+        "\U0001f3a8":                 "art",
+        "\U0001f9f5":                 "thread",
+        "\U0001faa1":                 "sewing_needle",
+        "\U0001f9f6":                 "yarn",
+        "\U0001faa2":                 "knot",
+        "\U0001f453":                 "eyeglasses",
+        "\U0001f576":                 "dark_sunglasses",
+        "\U0001f97d":                 "goggles",
+        "\U0001f97c":                 "lab_coat",
+        "\U0001f9ba":                 "safety_vest",
+        "\U0001f454":                 "necktie",
+        "\U0001f455":                 "shirt",
+        "\U0001f456":                 "jeans",
+        "\U0001f9e3":                 "scarf",
+        "\U0001f9e4":                 "gloves",
+        "\U0001f9e5":                 "coat",
+        "\U0001f9e6":                 "socks",
+        "\U0001f457":                 "dress",
+        "\U0001f458":                 "kimono",
+        "\U0001f97b":                 "sari",
+        "\U0001fa71":                 "one_piece_swimsuit",
+        "\U0001fa72":                 "swim_brief",
+        "\U0001fa73":                 "shorts",
+        "\U0001f459":                 "bikini",
+        "\U0001f45a":                 "womans_clothes",
+        "\U0001faad":                 "folding_hand_fan",
+        "\U0001f45b":                 "purse",
+        "\U0001f45c":                 "handbag",
+        "\U0001f45d":                 "pouch",
+        "\U0001f6cd":                 "shopping",
+        "\U0001f392":                 "school_satchel",
+        "\U0001fa74":                 "thong_sandal",
+        "\U0001f45e":                 "mans_shoe",
+        "\U0001f45f":                 "athletic_shoe",
+        "\U0001f97e":                 "hiking_boot",
+        "\U0001f97f":                 "flat_shoe",
+        "\U0001f460":                 "high_heel",
+        "\U0001f461":                 "sandal",
+        "\U0001fa70":                 "ballet_shoes",
+        "\U0001f462":                 "boot",
+        "\U0001faae":                 "hair_pick",
+        "\U0001f451":                 "crown",
+        "\U0001f452":                 "womans_hat",
+        "\U0001f3a9":                 "tophat",
+        "\U0001f393":                 "mortar_board",
+        "\U0001f9e2":                 "billed_cap",
+        "\U0001fa96":                 "military_helmet",
+        "\u26d1":                     "rescue_worker_helmet",
+        "\U0001f4ff":                 "prayer_beads",
+        "\U0001f484":                 "lipstick",
+        "\U0001f48d":                 "ring",
+        "\U0001f48e":                 "gem",
+        "\U0001f507":                 "mute",
+        "\U0001f508":                 "speaker",
+        "\U0001f509":                 "sound",
+        "\U0001f50a":                 "loud_sound",
+        "\U0001f4e2":                 "loudspeaker",
+        "\U0001f4e3":                 "mega",
+        "\U0001f4ef":                 "postal_horn",
+        "\U0001f514":                 "bell",
+        "\U0001f515":                 "no_bell",
+        "\U0001f3bc":                 "musical_score",
+        "\U0001f3b5":                 "musical_note",
+        "\U0001f3b6":                 "notes",
+        "\U0001f399":                 "studio_microphone",
+        "\U0001f39a":                 "level_slider",
+        "\U0001f39b":                 "control_knobs",
+        "\U0001f3a4":                 "microphone",
+        "\U0001f3a7":                 "headphones",
+        "\U0001f4fb":                 "radio",
+        "\U0001f3b7":                 "saxophone",
+        "\U0001fa97":                 "accordion",
+        "\U0001f3b8":                 "guitar",
+        "\U0001f3b9":                 "musical_keyboard",
+        "\U0001f3ba":                 "trumpet",
+        "\U0001f3bb":                 "violin",
+        "\U0001fa95":                 "banjo",
+        "\U0001f941":                 "drum",
+        "\U0001fa98":                 "long_drum",
+        "\U0001fa87":                 "maracas",
+        "\U0001fa88":                 "flute",
+        "\U0001f4f1":                 "iphone",
+        "\U0001f4f2":                 "calling",
+        "\u260e":                     "phone",
+        "\U0001f4de":                 "telephone_receiver",
+        "\U0001f4df":                 "pager",
+        "\U0001f4e0":                 "fax",
+        "\U0001f50b":                 "battery",
+        "\U0001faab":                 "low_battery",
+        "\U0001f50c":                 "electric_plug",
+        "\U0001f4bb":                 "computer",
+        "\U0001f5a5":                 "desktop_computer",
+        "\U0001f5a8":                 "printer",
+        "\u2328":                     "keyboard",
+        "\U0001f5b1":                 "computer_mouse",
+        "\U0001f5b2":                 "trackball",
+        "\U0001f4bd":                 "minidisc",
+        "\U0001f4be":                 "floppy_disk",
+        "\U0001f4bf":                 "cd",
+        "\U0001f4c0":                 "dvd",
+        "\U0001f9ee":                 "abacus",
+        "\U0001f3a5":                 "movie_camera",
+        "\U0001f39e":                 "film_strip",
+        "\U0001f4fd":                 "film_projector",
+        "\U0001f3ac":                 "clapper",
+        "\U0001f4fa":                 "tv",
+        "\U0001f4f7":                 "camera",
+        "\U0001f4f8":                 "camera_flash",
+        "\U0001f4f9":                 "video_camera",
+        "\U0001f4fc":                 "vhs",
+        "\U0001f50d":                 "mag",
+        "\U0001f50e":                 "mag_right",
+        "\U0001f56f":                 "candle",
+        "\U0001f4a1":                 "bulb",
+        "\U0001f526":                 "flashlight",
+        "\U0001f3ee":                 "izakaya_lantern",
+        "\U0001fa94":                 "diya_lamp",
+        "\U0001f4d4":                 "notebook_with_decorative_cover",
+        "\U0001f4d5":                 "closed_book",
+        "\U0001f4d6":                 "book",
+        "\U0001f4d7":                 "green_book",
+        "\U0001f4d8":                 "blue_book",
+        "\U0001f4d9":                 "orange_book",
+        "\U0001f4da":                 "books",
+        "\U0001f4d3":                 "notebook",
+        "\U0001f4d2":                 "ledger",
+        "\U0001f4c3":                 "page_with_curl",
+        "\U0001f4dc":                 "scroll",
+        "\U0001f4c4":                 "page_facing_up",
+        "\U0001f4f0":                 "newspaper",
+        "\U0001f5de":                 "newspaper_roll",
+        "\U0001f4d1":                 "bookmark_tabs",
+        "\U0001f516":                 "bookmark",
+        "\U0001f3f7":                 "label",
+        "\U0001f4b0":                 "moneybag",
+        "\U0001fa99":                 "coin",
+        "\U0001f4b4":                 "yen",
+        "\U0001f4b5":                 "dollar",
+        "\U0001f4b6":                 "euro",
+        "\U0001f4b7":                 "pound",
+        "\U0001f4b8":                 "money_with_wings",
+        "\U0001f4b3":                 "credit_card",
+        "\U0001f9fe":                 "receipt",
+        "\U0001f4b9":                 "chart",
+        "\u2709":                     "envelope",
+        "\U0001f4e7":                 "email",
+        "\U0001f4e8":                 "incoming_envelope",
+        "\U0001f4e9":                 "envelope_with_arrow",
+        "\U0001f4e4":                 "outbox_tray",
+        "\U0001f4e5":                 "inbox_tray",
+        "\U0001f4e6":                 "package",
+        "\U0001f4eb":                 "mailbox",
+        "\U0001f4ea":                 "mailbox_closed",
+        "\U0001f4ec":                 "mailbox_with_mail",
+        "\U0001f4ed":                 "mailbox_with_no_mail",
+        "\U0001f4ee":                 "postbox",
+        "\U0001f5f3":                 "ballot_box",
+        "\u270f":                     "pencil2",
+        "\u2712":                     "black_nib",
+        "\U0001f58b":                 "fountain_pen",
+        "\U0001f58a":                 "pen",
+        "\U0001f58c":                 "paintbrush",
+        "\U0001f58d":                 "crayon",
+        "\U0001f4dd":                 "memo",
+        "\U0001f4bc":                 "briefcase",
+        "\U0001f4c1":                 "file_folder",
+        "\U0001f4c2":                 "open_file_folder",
+        "\U0001f5c2":                 "card_index_dividers",
+        "\U0001f4c5":                 "date",
+        "\U0001f4c6":                 "calendar",
+        "\U0001f5d2":                 "spiral_notepad",
+        "\U0001f5d3":                 "spiral_calendar",
+        "\U0001f4c7":                 "card_index",
+        "\U0001f4c8":                 "chart_with_upwards_trend",
+        "\U0001f4c9":                 "chart_with_downwards_trend",
+        "\U0001f4ca":                 "bar_chart",
+        "\U0001f4cb":                 "clipboard",
+        "\U0001f4cc":                 "pushpin",
+        "\U0001f4cd":                 "round_pushpin",
+        "\U0001f4ce":                 "paperclip",
+        "\U0001f587":                 "paperclips",
+        "\U0001f4cf":                 "straight_ruler",
+        "\U0001f4d0":                 "triangular_ruler",
+        "\u2702":                     "scissors",
+        "\U0001f5c3":                 "card_file_box",
+        "\U0001f5c4":                 "file_cabinet",
+        "\U0001f5d1":                 "wastebasket",
+        "\U0001f512":                 "lock",
+        "\U0001f513":                 "unlock",
+        "\U0001f50f":                 "lock_with_ink_pen",
+        "\U0001f510":                 "closed_lock_with_key",
+        "\U0001f511":                 "key",
+        "\U0001f5dd":                 "old_key",
+        "\U0001f528":                 "hammer",
+        "\U0001fa93":                 "axe",
+        "\u26cf":                     "pick",
+        "\u2692":                     "hammer_and_pick",
+        "\U0001f6e0":                 "hammer_and_wrench",
+        "\U0001f5e1":                 "dagger",
+        "\u2694":                     "crossed_swords",
+        "\U0001f4a3":                 "bomb",
+        "\U0001fa83":                 "boomerang",
+        "\U0001f3f9":                 "bow_and_arrow",
+        "\U0001f6e1":                 "shield",
+        "\U0001fa9a":                 "carpentry_saw",
+        "\U0001f527":                 "wrench",
+        "\U0001fa9b":                 "screwdriver",
+        "\U0001f529":                 "nut_and_bolt",
+        "\u2699":                     "gear",
+        "\U0001f5dc":                 "clamp",
+        "\u2696":                     "balance_scale",
+        "\U0001f9af":                 "probing_cane",
+        "\U0001f517":                 "link",
+        "\u26d3":                     "chains",
+        "\U0001fa9d":                 "hook",
+        "\U0001f9f0":                 "toolbox",
+        "\U0001f9f2":                 "magnet",
+        "\U0001fa9c":                 "ladder",
+        "\u2697":                     "alembic",
+        "\U0001f9ea":                 "test_tube",
+        "\U0001f9eb":                 "petri_dish",
+        "\U0001f9ec":                 "dna",
+        "\U0001f52c":                 "microscope",
+        "\U0001f52d":                 "telescope",
+        "\U0001f4e1":                 "satellite",
+        "\U0001f489":                 "syringe",
+        "\U0001fa78":                 "drop_of_blood",
+        "\U0001f48a":                 "pill",
+        "\U0001fa79":                 "adhesive_bandage",
+        "\U0001fa7c":                 "crutch",
+        "\U0001fa7a":                 "stethoscope",
+        "\U0001fa7b":                 "x_ray",
+        "\U0001f6aa":                 "door",
+        "\U0001f6d7":                 "elevator",
+        "\U0001fa9e":                 "mirror",
+        "\U0001fa9f":                 "window",
+        "\U0001f6cf":                 "bed",
+        "\U0001f6cb":                 "couch_and_lamp",
+        "\U0001fa91":                 "chair",
+        "\U0001f6bd":                 "toilet",
+        "\U0001faa0":                 "plunger",
+        "\U0001f6bf":                 "shower",
+        "\U0001f6c1":                 "bathtub",
+        "\U0001faa4":                 "mouse_trap",
+        "\U0001fa92":                 "razor",
+        "\U0001f9f4":                 "lotion_bottle",
+        "\U0001f9f7":                 "safety_pin",
+        "\U0001f9f9":                 "broom",
+        "\U0001f9fa":                 "basket",
+        "\U0001f9fb":                 "roll_of_paper",
+        "\U0001faa3":                 "bucket",
+        "\U0001f9fc":                 "soap",
+        "\U0001fae7":                 "bubbles",
+        "\U0001faa5":                 "toothbrush",
+        "\U0001f9fd":                 "sponge",
+        "\U0001f9ef":                 "fire_extinguisher",
+        "\U0001f6d2":                 "shopping_cart",
+        "\U0001f6ac":                 "smoking",
+        "\u26b0":                     "coffin",
+        "\U0001faa6":                 "headstone",
+        "\u26b1":                     "funeral_urn",
+        "\U0001f9ff":                 "nazar_amulet",
+        "\U0001faac":                 "hamsa",
+        "\U0001f5ff":                 "moyai",
+        "\U0001faa7":                 "placard",
+        "\U0001faaa":                 "identification_card",
+        "\U0001f3e7":                 "atm",
+        "\U0001f6ae":                 "put_litter_in_its_place",
+        "\U0001f6b0":                 "potable_water",
+        "\u267f":                     "wheelchair",
+        "\U0001f6b9":                 "mens",
+        "\U0001f6ba":                 "womens",
+        "\U0001f6bb":                 "restroom",
+        "\U0001f6bc":                 "baby_symbol",
+        "\U0001f6be":                 "wc",
+        "\U0001f6c2":                 "passport_control",
+        "\U0001f6c3":                 "customs",
+        "\U0001f6c4":                 "baggage_claim",
+        "\U0001f6c5":                 "left_luggage",
+        "\u26a0":                     "warning",
+        "\U0001f6b8":                 "children_crossing",
+        "\u26d4":                     "no_entry",
+        "\U0001f6ab":                 "no_entry_sign",
+        "\U0001f6b3":                 "no_bicycles",
+        "\U0001f6ad":                 "no_smoking",
+        "\U0001f6af":                 "do_not_litter",
+        "\U0001f6b1":                 "non-potable_water",
+        "\U0001f6b7":                 "no_pedestrians",
+        "\U0001f4f5":                 "no_mobile_phones",
+        "\U0001f51e":                 "underage",
+        "\u2622":                     "radioactive",
+        "\u2623":                     "biohazard",
+        "\u2b06":                     "arrow_up",
+        "\u2197":                     "arrow_upper_right",
+        "\u27a1":                     "arrow_right",
+        "\u2198":                     "arrow_lower_right",
+        "\u2b07":                     "arrow_down",
+        "\u2199":                     "arrow_lower_left",
+        "\u2b05":                     "arrow_left",
+        "\u2196":                     "arrow_upper_left",
+        "\u2195":                     "arrow_up_down",
+        "\u2194":                     "left_right_arrow",
+        "\u21a9":                     "leftwards_arrow_with_hook",
+        "\u21aa":                     "arrow_right_hook",
+        "\u2934":                     "arrow_heading_up",
+        "\u2935":                     "arrow_heading_down",
+        "\U0001f503":                 "arrows_clockwise",
+        "\U0001f504":                 "arrows_counterclockwise",
+        "\U0001f519":                 "back",
+        "\U0001f51a":                 "end",
+        "\U0001f51b":                 "on",
+        "\U0001f51c":                 "soon",
+        "\U0001f51d":                 "top",
+        "\U0001f6d0":                 "place_of_worship",
+        "\u269b":                     "atom_symbol",
+        "\U0001f549":                 "om",
+        "\u2721":                     "star_of_david",
+        "\u2638":                     "wheel_of_dharma",
+        "\u262f":                     "yin_yang",
+        "\u271d":                     "latin_cross",
+        "\u2626":                     "orthodox_cross",
+        "\u262a":                     "star_and_crescent",
+        "\u262e":                     "peace_symbol",
+        "\U0001f54e":                 "menorah",
+        "\U0001f52f":                 "six_pointed_star",
+        "\U0001faaf":                 "khanda",
+        "\u2648":                     "aries",
+        "\u2649":                     "taurus",
+        "\u264a":                     "gemini",
+        "\u264b":                     "cancer",
+        "\u264c":                     "leo",
+        "\u264d":                     "virgo",
+        "\u264e":                     "libra",
+        "\u264f":                     "scorpius",
+        "\u2650":                     "sagittarius",
+        "\u2651":                     "capricorn",
+        "\u2652":                     "aquarius",
+        "\u2653":                     "pisces",
+        "\u26ce":                     "ophiuchus",
+        "\U0001f500":                 "twisted_rightwards_arrows",
+        "\U0001f501":                 "repeat",
+        "\U0001f502":                 "repeat_one",
+        "\u25b6":                     "arrow_forward",
+        "\u23e9":                     "fast_forward",
+        "\u23ed":                     "next_track_button",
+        "\u23ef":                     "play_or_pause_button",
+        "\u25c0":                     "arrow_backward",
+        "\u23ea":                     "rewind",
+        "\u23ee":                     "previous_track_button",
+        "\U0001f53c":                 "arrow_up_small",
+        "\u23eb":                     "arrow_double_up",
+        "\U0001f53d":                 "arrow_down_small",
+        "\u23ec":                     "arrow_double_down",
+        "\u23f8":                     "pause_button",
+        "\u23f9":                     "stop_button",
+        "\u23fa":                     "record_button",
+        "\u23cf":                     "eject_button",
+        "\U0001f3a6":                 "cinema",
+        "\U0001f505":                 "low_brightness",
+        "\U0001f506":                 "high_brightness",
+        "\U0001f4f6":                 "signal_strength",
+        "\U0001f6dc":                 "wireless",
+        "\U0001f4f3":                 "vibration_mode",
+        "\U0001f4f4":                 "mobile_phone_off",
+        "\u2640":                     "female_sign",
+        "\u2642":                     "male_sign",
+        "\u26a7":                     "transgender_symbol",
+        "\u2716":                     "heavy_multiplication_x",
+        "\u2795":                     "heavy_plus_sign",
+        "\u2796":                     "heavy_minus_sign",
+        "\u2797":                     "heavy_division_sign",
+        "\U0001f7f0":                 "heavy_equals_sign",
+        "\u267e":                     "infinity",
+        "\u203c":                     "bangbang",
+        "\u2049":                     "interrobang",
+        "\u2753":                     "question",
+        "\u2754":                     "grey_question",
+        "\u2755":                     "grey_exclamation",
+        "\u2757":                     "exclamation",
+        "\u3030":                     "wavy_dash",
+        "\U0001f4b1":                 "currency_exchange",
+        "\U0001f4b2":                 "heavy_dollar_sign",
+        "\u2695":                     "medical_symbol",
+        "\u267b":                     "recycle",
+        "\u269c":                     "fleur_de_lis",
+        "\U0001f531":                 "trident",
+        "\U0001f4db":                 "name_badge",
+        "\U0001f530":                 "beginner",
+        "\u2b55":                     "o",
+        "\u2705":                     "white_check_mark",
+        "\u2611":                     "ballot_box_with_check",
+        "\u2714":                     "heavy_check_mark",
+        "\u274c":                     "x",
+        "\u274e":                     "negative_squared_cross_mark",
+        "\u27b0":                     "curly_loop",
+        "\u27bf":                     "loop",
+        "\u303d":                     "part_alternation_mark",
+        "\u2733":                     "eight_spoked_asterisk",
+        "\u2734":                     "eight_pointed_black_star",
+        "\u2747":                     "sparkle",
+        "\u00a9":                     "copyright",
+        "\u00ae":                     "registered",
+        "\u2122":                     "tm",
+        "#\u20e3":                    "hash",
+        "*\u20e3":                    "asterisk",
+        "0\u20e3":                    "zero",
+        "1\u20e3":                    "one",
+        "2\u20e3":                    "two",
+        "3\u20e3":                    "three",
+        "4\u20e3":                    "four",
+        "5\u20e3":                    "five",
+        "6\u20e3":                    "six",
+        "7\u20e3":                    "seven",
+        "8\u20e3":                    "eight",
+        "9\u20e3":                    "nine",
+        "\U0001f51f":                 "keycap_ten",
+        "\U0001f520":                 "capital_abcd",
+        "\U0001f521":                 "abcd",
+        "\U0001f522":                 "1234",
+        "\U0001f523":                 "symbols",
+        "\U0001f524":                 "abc",
+        "\U0001f170":                 "a",
+        "\U0001f18e":                 "ab",
+        "\U0001f171":                 "b",
+        "\U0001f191":                 "cl",
+        "\U0001f192":                 "cool",
+        "\U0001f193":                 "free",
+        "\u2139":                     "information_source",
+        "\U0001f194":                 "id",
+        "\u24c2":                     "m",
+        "\U0001f195":                 "new",
+        "\U0001f196":                 "ng",
+        "\U0001f17e":                 "o2",
+        "\U0001f197":                 "ok",
+        "\U0001f17f":                 "parking",
+        "\U0001f198":                 "sos",
+        "\U0001f199":                 "up",
+        "\U0001f19a":                 "vs",
+        "\U0001f201":                 "koko",
+        "\U0001f202":                 "sa",
+        "\U0001f237":                 "u6708",
+        "\U0001f236":                 "u6709",
+        "\U0001f22f":                 "u6307",
+        "\U0001f250":                 "ideograph_advantage",
+        "\U0001f239":                 "u5272",
+        "\U0001f21a":                 "u7121",
+        "\U0001f232":                 "u7981",
+        "\U0001f251":                 "accept",
+        "\U0001f238":                 "u7533",
+        "\U0001f234":                 "u5408",
+        "\U0001f233":                 "u7a7a",
+        "\u3297":                     "congratulations",
+        "\u3299":                     "secret",
+        "\U0001f23a":                 "u55b6",
+        "\U0001f235":                 "u6e80",
+        "\U0001f534":                 "red_circle",
+        "\U0001f7e0":                 "orange_circle",
+        "\U0001f7e1":                 "yellow_circle",
+        "\U0001f7e2":                 "green_circle",
+        "\U0001f535":                 "large_blue_circle",
+        "\U0001f7e3":                 "purple_circle",
+        "\U0001f7e4":                 "brown_circle",
+        "\u26ab":                     "black_circle",
+        "\u26aa":                     "white_circle",
+        "\U0001f7e5":                 "red_square",
+        "\U0001f7e7":                 "orange_square",
+        "\U0001f7e8":                 "yellow_square",
+        "\U0001f7e9":                 "green_square",
+        "\U0001f7e6":                 "blue_square",
+        "\U0001f7ea":                 "purple_square",
+        "\U0001f7eb":                 "brown_square",
+        "\u2b1b":                     "black_large_square",
+        "\u2b1c":                     "white_large_square",
+        "\u25fc":                     "black_medium_square",
+        "\u25fb":                     "white_medium_square",
+        "\u25fe":                     "black_medium_small_square",
+        "\u25fd":                     "white_medium_small_square",
+        "\u25aa":                     "black_small_square",
+        "\u25ab":                     "white_small_square",
+        "\U0001f536":                 "large_orange_diamond",
+        "\U0001f537":                 "large_blue_diamond",
+        "\U0001f538":                 "small_orange_diamond",
+        "\U0001f539":                 "small_blue_diamond",
+        "\U0001f53a":                 "small_red_triangle",
+        "\U0001f53b":                 "small_red_triangle_down",
+        "\U0001f4a0":                 "diamond_shape_with_a_dot_inside",
+        "\U0001f518":                 "radio_button",
+        "\U0001f533":                 "white_square_button",
+        "\U0001f532":                 "black_square_button",
+        "\U0001f3c1":                 "checkered_flag",
+        "\U0001f6a9":                 "triangular_flag_on_post",
+        "\U0001f38c":                 "crossed_flags",
+        "\U0001f3f4":                 "black_flag",
+        "\U0001f3f3":                 "white_flag",
+        "\U0001f3f3\u200d\U0001f308": "rainbow_flag",
+        "\U0001f3f3\u200d\u26a7":     "transgender_flag",
+        "\U0001f3f4\u200d\u2620":     "pirate_flag",
+        "\U0001f1e6\U0001f1e8":       "ascension_island",
+        "\U0001f1e6\U0001f1e9":       "andorra",
+        "\U0001f1e6\U0001f1ea":       "united_arab_emirates",
+        "\U0001f1e6\U0001f1eb":       "afghanistan",
+        "\U0001f1e6\U0001f1ec":       "antigua_barbuda",
+        "\U0001f1e6\U0001f1ee":       "anguilla",
+        "\U0001f1e6\U0001f1f1":       "albania",
+        "\U0001f1e6\U0001f1f2":       "armenia",
+        "\U0001f1e6\U0001f1f4":       "angola",
+        "\U0001f1e6\U0001f1f6":       "antarctica",
+        "\U0001f1e6\U0001f1f7":       "argentina",
+        "\U0001f1e6\U0001f1f8":       "american_samoa",
+        "\U0001f1e6\U0001f1f9":       "austria",
+        "\U0001f1e6\U0001f1fa":       "australia",
+        "\U0001f1e6\U0001f1fc":       "aruba",
+        "\U0001f1e6\U0001f1fd":       "aland_islands",
+        "\U0001f1e6\U0001f1ff":       "azerbaijan",
+        "\U0001f1e7\U0001f1e6":       "bosnia_herzegovina",
+        "\U0001f1e7\U0001f1e7":       "barbados",
+        "\U0001f1e7\U0001f1e9":       "bangladesh",
+        "\U0001f1e7\U0001f1ea":       "belgium",
+        "\U0001f1e7\U0001f1eb":       "burkina_faso",
+        "\U0001f1e7\U0001f1ec":       "bulgaria",
+        "\U0001f1e7\U0001f1ed":       "bahrain",
+        "\U0001f1e7\U0001f1ee":       "burundi",
+        "\U0001f1e7\U0001f1ef":       "benin",
+        "\U0001f1e7\U0001f1f1":       "st_barthelemy",
+        "\U0001f1e7\U0001f1f2":       "bermuda",
+        "\U0001f1e7\U0001f1f3":       "brunei",
+        "\U0001f1e7\U0001f1f4":       "bolivia",
+        "\U0001f1e7\U0001f1f6":       "caribbean_netherlands",
+        "\U0001f1e7\U0001f1f7":       "brazil",
+        "\U0001f1e7\U0001f1f8":       "bahamas",
+        "\U0001f1e7\U0001f1f9":       "bhutan",
+        "\U0001f1e7\U0001f1fb":       "bouvet_island",
+        "\U0001f1e7\U0001f1fc":       "botswana",
+        "\U0001f1e7\U0001f1fe":       "belarus",
+        "\U0001f1e7\U0001f1ff":       "belize",
+        "\U0001f1e8\U0001f1e6":       "canada",
+        "\U0001f1e8\U0001f1e8":       "cocos_islands",
+        "\U0001f1e8\U0001f1e9":       "congo_kinshasa",
+        "\U0001f1e8\U0001f1eb":       "central_african_republic",
+        "\U0001f1e8\U0001f1ec":       "congo_brazzaville",
+        "\U0001f1e8\U0001f1ed":       "switzerland",
+        "\U0001f1e8\U0001f1ee":       "cote_divoire",
+        "\U0001f1e8\U0001f1f0":       "cook_islands",
+        "\U0001f1e8\U0001f1f1":       "chile",
+        "\U0001f1e8\U0001f1f2":       "cameroon",
+        "\U0001f1e8\U0001f1f3":       "cn",
+        "\U0001f1e8\U0001f1f4":       "colombia",
+        "\U0001f1e8\U0001f1f5":       "clipperton_island",
+        "\U0001f1e8\U0001f1f7":       "costa_rica",
+        "\U0001f1e8\U0001f1fa":       "cuba",
+        "\U0001f1e8\U0001f1fb":       "cape_verde",
+        "\U0001f1e8\U0001f1fc":       "curacao",
+        "\U0001f1e8\U0001f1fd":       "christmas_island",
+        "\U0001f1e8\U0001f1fe":       "cyprus",
+        "\U0001f1e8\U0001f1ff":       "czech_republic",
+        "\U0001f1e9\U0001f1ea":       "de",
+        "\U0001f1e9\U0001f1ec":       "diego_garcia",
+        "\U0001f1e9\U0001f1ef":       "djibouti",
+        "\U0001f1e9\U0001f1f0":       "denmark",
+        "\U0001f1e9\U0001f1f2":       "dominica",
+        "\U0001f1e9\U0001f1f4":       "dominican_republic",
+        "\U0001f1e9\U0001f1ff":       "algeria",
+        "\U0001f1ea\U0001f1e6":       "ceuta_melilla",
+        "\U0001f1ea\U0001f1e8":       "ecuador",
+        "\U0001f1ea\U0001f1ea":       "estonia",
+        "\U0001f1ea\U0001f1ec":       "egypt",
+        "\U0001f1ea\U0001f1ed":       "western_sahara",
+        "\U0001f1ea\U0001f1f7":       "eritrea",
+        "\U0001f1ea\U0001f1f8":       "es",
+        "\U0001f1ea\U0001f1f9":       "ethiopia",
+        "\U0001f1ea\U0001f1fa":       "eu",
+        "\U0001f1eb\U0001f1ee":       "finland",
+        "\U0001f1eb\U0001f1ef":       "fiji",
+        "\U0001f1eb\U0001f1f0":       "falkland_islands",
+        "\U0001f1eb\U0001f1f2":       "micronesia",
+        "\U0001f1eb\U0001f1f4":       "faroe_islands",
+        "\U0001f1eb\U0001f1f7":       "fr",
+        "\U0001f1ec\U0001f1e6":       "gabon",
+        "\U0001f1ec\U0001f1e7":       "gb",
+        "\U0001f1ec\U0001f1e9":       "grenada",
+        "\U0001f1ec\U0001f1ea":       "georgia",
+        "\U0001f1ec\U0001f1eb":       "french_guiana",
+        "\U0001f1ec\U0001f1ec":       "guernsey",
+        "\U0001f1ec\U0001f1ed":       "ghana",
+        "\U0001f1ec\U0001f1ee":       "gibraltar",
+        "\U0001f1ec\U0001f1f1":       "greenland",
+        "\U0001f1ec\U0001f1f2":       "gambia",
+        "\U0001f1ec\U0001f1f3":       "guinea",
+        "\U0001f1ec\U0001f1f5":       "guadeloupe",
+        "\U0001f1ec\U0001f1f6":       "equatorial_guinea",
+        "\U0001f1ec\U0001f1f7":       "greece",
+        "\U0001f1ec\U0001f1f8":       "south_georgia_south_sandwich_islands",
+        "\U0001f1ec\U0001f1f9":       "guatemala",
+        "\U0001f1ec\U0001f1fa":       "guam",
+        "\U0001f1ec\U0001f1fc":       "guinea_bissau",
+        "\U0001f1ec\U0001f1fe":       "guyana",
+        "\U0001f1ed\U0001f1f0":       "hong_kong",
+        "\U0001f1ed\U0001f1f2":       "heard_mcdonald_islands",
+        "\U0001f1ed\U0001f1f3":       "honduras",
+        "\U0001f1ed\U0001f1f7":       "croatia",
+        "\U0001f1ed\U0001f1f9":       "haiti",
+        "\U0001f1ed\U0001f1fa":       "hungary",
+        "\U0001f1ee\U0001f1e8":       "canary_islands",
+        "\U0001f1ee\U0001f1e9":       "indonesia",
+        "\U0001f1ee\U0001f1ea":       "ireland",
+        "\U0001f1ee\U0001f1f1":       "israel",
+        "\U0001f1ee\U0001f1f2":       "isle_of_man",
+        "\U0001f1ee\U0001f1f3":       "india",
+        "\U0001f1ee\U0001f1f4":       "british_indian_ocean_territory",
+        "\U0001f1ee\U0001f1f6":       "iraq",
+        "\U0001f1ee\U0001f1f7":       "iran",
+        "\U0001f1ee\U0001f1f8":       "iceland",
+        "\U0001f1ee\U0001f1f9":       "it",
+        "\U0001f1ef\U0001f1ea":       "jersey",
+        "\U0001f1ef\U0001f1f2":       "jamaica",
+        "\U0001f1ef\U0001f1f4":       "jordan",
+        "\U0001f1ef\U0001f1f5":       "jp",
+        "\U0001f1f0\U0001f1ea":       "kenya",
+        "\U0001f1f0\U0001f1ec":       "kyrgyzstan",
+        "\U0001f1f0\U0001f1ed":       "cambodia",
+        "\U0001f1f0\U0001f1ee":       "kiribati",
+        "\U0001f1f0\U0001f1f2":       "comoros",
+        "\U0001f1f0\U0001f1f3":       "st_kitts_nevis",
+        "\U0001f1f0\U0001f1f5":       "north_korea",
+        "\U0001f1f0\U0001f1f7":       "kr",
+        "\U0001f1f0\U0001f1fc":       "kuwait",
+        "\U0001f1f0\U0001f1fe":       "cayman_islands",
+        "\U0001f1f0\U0001f1ff":       "kazakhstan",
+        "\U0001f1f1\U0001f1e6":       "laos",
+        "\U0001f1f1\U0001f1e7":       "lebanon",
+        "\U0001f1f1\U0001f1e8":       "st_lucia",
+        "\U0001f1f1\U0001f1ee":       "liechtenstein",
+        "\U0001f1f1\U0001f1f0":       "sri_lanka",
+        "\U0001f1f1\U0001f1f7":       "liberia",
+        "\U0001f1f1\U0001f1f8":       "lesotho",
+        "\U0001f1f1\U0001f1f9":       "lithuania",
+        "\U0001f1f1\U0001f1fa":       "luxembourg",
+        "\U0001f1f1\U0001f1fb":       "latvia",
+        "\U0001f1f1\U0001f1fe":       "libya",
+        "\U0001f1f2\U0001f1e6":       "morocco",
+        "\U0001f1f2\U0001f1e8":       "monaco",
+        "\U0001f1f2\U0001f1e9":       "moldova",
+        "\U0001f1f2\U0001f1ea":       "montenegro",
+        "\U0001f1f2\U0001f1eb":       "st_martin",
+        "\U0001f1f2\U0001f1ec":       "madagascar",
+        "\U0001f1f2\U0001f1ed":       "marshall_islands",
+        "\U0001f1f2\U0001f1f0":       "macedonia",
+        "\U0001f1f2\U0001f1f1":       "mali",
+        "\U0001f1f2\U0001f1f2":       "myanmar",
+        "\U0001f1f2\U0001f1f3":       "mongolia",
+        "\U0001f1f2\U0001f1f4":       "macau",
+        "\U0001f1f2\U0001f1f5":       "northern_mariana_islands",
+        "\U0001f1f2\U0001f1f6":       "martinique",
+        "\U0001f1f2\U0001f1f7":       "mauritania",
+        "\U0001f1f2\U0001f1f8":       "montserrat",
+        "\U0001f1f2\U0001f1f9":       "malta",
+        "\U0001f1f2\U0001f1fa":       "mauritius",
+        "\U0001f1f2\U0001f1fb":       "maldives",
+        "\U0001f1f2\U0001f1fc":       "malawi",
+        "\U0001f1f2\U0001f1fd":       "mexico",
+        "\U0001f1f2\U0001f1fe":       "malaysia",
+        "\U0001f1f2\U0001f1ff":       "mozambique",
+        "\U0001f1f3\U0001f1e6":       "namibia",
+        "\U0001f1f3\U0001f1e8":       "new_caledonia",
+        "\U0001f1f3\U0001f1ea":       "niger",
+        "\U0001f1f3\U0001f1eb":       "norfolk_island",
+        "\U0001f1f3\U0001f1ec":       "nigeria",
+        "\U0001f1f3\U0001f1ee":       "nicaragua",
+        "\U0001f1f3\U0001f1f1":       "netherlands",
+        "\U0001f1f3\U0001f1f4":       "norway",
+        "\U0001f1f3\U0001f1f5":       "nepal",
+        "\U0001f1f3\U0001f1f7":       "nauru",
+        "\U0001f1f3\U0001f1fa":       "niue",
+        "\U0001f1f3\U0001f1ff":       "new_zealand",
+        "\U0001f1f4\U0001f1f2":       "oman",
+        "\U0001f1f5\U0001f1e6":       "panama",
+        "\U0001f1f5\U0001f1ea":       "peru",
+        "\U0001f1f5\U0001f1eb":       "french_polynesia",
+        "\U0001f1f5\U0001f1ec":       "papua_new_guinea",
+        "\U0001f1f5\U0001f1ed":       "philippines",
+        "\U0001f1f5\U0001f1f0":       "pakistan",
+        "\U0001f1f5\U0001f1f1":       "poland",
+        "\U0001f1f5\U0001f1f2":       "st_pierre_miquelon",
+        "\U0001f1f5\U0001f1f3":       "pitcairn_islands",
+        "\U0001f1f5\U0001f1f7":       "puerto_rico",
+        "\U0001f1f5\U0001f1f8":       "palestinian_territories",
+        "\U0001f1f5\U0001f1f9":       "portugal",
+        "\U0001f1f5\U0001f1fc":       "palau",
+        "\U0001f1f5\U0001f1fe":       "paraguay",
+        "\U0001f1f6\U0001f1e6":       "qatar",
+        "\U0001f1f7\U0001f1ea":       "reunion",
+        "\U0001f1f7\U0001f1f4":       "romania",
+        "\U0001f1f7\U0001f1f8":       "serbia",
+        "\U0001f1f7\U0001f1fa":       "ru",
+        "\U0001f1f7\U0001f1fc":       "rwanda",
+        "\U0001f1f8\U0001f1e6":       "saudi_arabia",
+        "\U0001f1f8\U0001f1e7":       "solomon_islands",
+        "\U0001f1f8\U0001f1e8":       "seychelles",
+        "\U0001f1f8\U0001f1e9":       "sudan",
+        "\U0001f1f8\U0001f1ea":       "sweden",
+        "\U0001f1f8\U0001f1ec":       "singapore",
+        "\U0001f1f8\U0001f1ed":       "st_helena",
+        "\U0001f1f8\U0001f1ee":       "slovenia",
+        "\U0001f1f8\U0001f1ef":       "svalbard_jan_mayen",
+        "\U0001f1f8\U0001f1f0":       "slovakia",
+        "\U0001f1f8\U0001f1f1":       "sierra_leone",
+        "\U0001f1f8\U0001f1f2":       "san_marino",
+        "\U0001f1f8\U0001f1f3":       "senegal",
+        "\U0001f1f8\U0001f1f4":       "somalia",
+        "\U0001f1f8\U0001f1f7":       "suriname",
+        "\U0001f1f8\U0001f1f8":       "south_sudan",
+        "\U0001f1f8\U0001f1f9":       "sao_tome_principe",
+        "\U0001f1f8\U0001f1fb":       "el_salvador",
+        "\U0001f1f8\U0001f1fd":       "sint_maarten",
+        "\U0001f1f8\U0001f1fe":       "syria",
+        "\U0001f1f8\U0001f1ff":       "swaziland",
+        "\U0001f1f9\U0001f1e6":       "tristan_da_cunha",
+        "\U0001f1f9\U0001f1e8":       "turks_caicos_islands",
+        "\U0001f1f9\U0001f1e9":       "chad",
+        "\U0001f1f9\U0001f1eb":       "french_southern_territories",
+        "\U0001f1f9\U0001f1ec":       "togo",
+        "\U0001f1f9\U0001f1ed":       "thailand",
+        "\U0001f1f9\U0001f1ef":       "tajikistan",
+        "\U0001f1f9\U0001f1f0":       "tokelau",
+        "\U0001f1f9\U0001f1f1":       "timor_leste",
+        "\U0001f1f9\U0001f1f2":       "turkmenistan",
+        "\U0001f1f9\U0001f1f3":       "tunisia",
+        "\U0001f1f9\U0001f1f4":       "tonga",
+        "\U0001f1f9\U0001f1f7":       "tr",
+        "\U0001f1f9\U0001f1f9":       "trinidad_tobago",
+        "\U0001f1f9\U0001f1fb":       "tuvalu",
+        "\U0001f1f9\U0001f1fc":       "taiwan",
+        "\U0001f1f9\U0001f1ff":       "tanzania",
+        "\U0001f1fa\U0001f1e6":       "ukraine",
+        "\U0001f1fa\U0001f1ec":       "uganda",
+        "\U0001f1fa\U0001f1f2":       "us_outlying_islands",
+        "\U0001f1fa\U0001f1f3":       "united_nations",
+        "\U0001f1fa\U0001f1f8":       "us",
+        "\U0001f1fa\U0001f1fe":       "uruguay",
+        "\U0001f1fa\U0001f1ff":       "uzbekistan",
+        "\U0001f1fb\U0001f1e6":       "vatican_city",
+        "\U0001f1fb\U0001f1e8":       "st_vincent_grenadines",
+        "\U0001f1fb\U0001f1ea":       "venezuela",
+        "\U0001f1fb\U0001f1ec":       "british_virgin_islands",
+        "\U0001f1fb\U0001f1ee":       "us_virgin_islands",
+        "\U0001f1fb\U0001f1f3":       "vietnam",
+        "\U0001f1fb\U0001f1fa":       "vanuatu",
+        "\U0001f1fc\U0001f1eb":       "wallis_futuna",
+        "\U0001f1fc\U0001f1f8":       "samoa",
+        "\U0001f1fd\U0001f1f0":       "kosovo",
+        "\U0001f1fe\U0001f1ea":       "yemen",
+        "\U0001f1fe\U0001f1f9":       "mayotte",
+        "\U0001f1ff\U0001f1e6":       "south_africa",
+        "\U0001f1ff\U0001f1f2":       "zambia",
+        "\U0001f1ff\U0001f1fc":       "zimbabwe",
+        "\U0001f3f4\U000e0067\U000e0062\U000e0065\U000e006e\U000e0067\U000e007f": "england",
+        "\U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f": "scotland",
+        "\U0001f3f4\U000e0067\U000e0062\U000e0077\U000e006c\U000e0073\U000e007f": "wales",
+}
+
+// shortcodeEmoji maps every alias to its emoji
+var shortcodeEmoji = map[string]string{
+        "grinning":                       "\U0001f600",
+        "smiley":                         "\U0001f603",
+        "smile":                          "\U0001f604",
+        "grin":                           "\U0001f601",
+        "laughing":                       "\U0001f606",
+        "satisfied":                      "\U0001f606",
+        "sweat_smile":                    "\U0001f605",
+        "rofl":                           "\U0001f923",
+        "joy":                            "\U0001f602",
+        "slightly_smiling_face":          "\U0001f642",
+        "upside_down_face":               "\U0001f643",
+        "melting_face":                   "\U0001fae0",
+        "wink":                           "\U0001f609",
+        "blush":                          "\U0001f60a",
+        "innocent":                       "\U0001f607",
+        "smiling_face_with_three_hearts": "\U0001f970",
+        "heart_eyes":                     "\U0001f60d",
+        "star_struck":                    "\U0001f929",
+        "kissing_heart":                  "\U0001f618",
+        "kissing":                        "\U0001f617",
+        "relaxed":                        "\u263a\ufe0f",
+        "kissing_closed_eyes":            "\U0001f61a",
+        "kissing_smiling_eyes":           "\U0001f619",
+        "smiling_face_with_tear":         "\U0001f972",
+        "yum":                            "\U0001f60b",
+        "stuck_out_tongue":               "\U0001f61b",
+        "stuck_out_tongue_winking_eye":   "\U0001f61c",
+        "zany_face":                      "\U0001f92a",
+        "stuck_out_tongue_closed_eyes":   "\U0001f61d",
+        "money_mouth_face":               "\U0001f911",
+        "hugs":                           "\U0001f917",
+        "hand_over_mouth":                "\U0001f92d",
+        "face_with_open_eyes_and_hand_over_mouth": "\U0001fae2",
+        "face_with_peeking_eye":                   "\U0001fae3",
+        "shushing_face":                           "\U0001f92b",
+        "thinking":                                "\U0001f914",
+        "saluting_face":                           "\U0001fae1",
+        "zipper_mouth_face":                       "\U0001f910",
+        "raised_eyebrow":                          "\U0001f928",
+        "neutral_face":                            "\U0001f610",
+        "expressionless":                          "\U0001f611",
+        "no_mouth":                                "\U0001f636",
+        "dotted_line_face":                        "\U0001fae5",
+        "face_in_clouds":                          "\U0001f636\u200d\U0001f32b\ufe0f",
+        "smirk":                                   "\U0001f60f",
+        "unamused":                                "\U0001f612",
+        "roll_eyes":                               "\U0001f644",
+        "grimacing":                               "\U0001f62c",
+        "face_exhaling":                           "\U0001f62e\u200d\U0001f4a8",
+        "lying_face":                              "\U0001f925",
+        "shaking_face":                            "\U0001fae8",
+        "relieved":                                "\U0001f60c",
+        "pensive":                                 "\U0001f614",
+        "sleepy":                                  "\U0001f62a",
+        "drooling_face":                           "\U0001f924",
+        "sleeping":                                "\U0001f634",
+        "mask":                                    "\U0001f637",
+        "face_with_thermometer":                   "\U0001f912",
+        "face_with_head_bandage":                  "\U0001f915",
+        "nauseated_face":                          "\U0001f922",
+        "vomiting_face":                           "\U0001f92e",
+        "sneezing_face":                           "\U0001f927",
+        "hot_face":                                "\U0001f975",
+        "cold_face":                               "\U0001f976",
+        "woozy_face":                              "\U0001f974",
+        "dizzy_face":                              "\U0001f635",
+        "face_with_spiral_eyes":                   "\U0001f635\u200d\U0001f4ab",
+        "exploding_head":                          "\U0001f92f",
+        "cowboy_hat_face":                         "\U0001f920",
+        "partying_face":                           "\U0001f973",
+        "disguised_face":                          "\U0001f978",
+        "sunglasses":                              "\U0001f60e",
+        "nerd_face":                               "\U0001f913",
+        "monocle_face":                            "\U0001f9d0",
+        "confused":                                "\U0001f615",
+        "face_with_diagonal_mouth":                "\U0001fae4",
+        "worried":                                 "\U0001f61f",
+        "slightly_frowning_face":                  "\U0001f641",
+        "frowning_face":                           "\u2639\ufe0f",
+        "open_mouth":                              "\U0001f62e",
+        "hushed":                                  "\U0001f62f",
+        "astonished":                              "\U0001f632",
+        "flushed":                                 "\U0001f633",
+        "pleading_face":                           "\U0001f97a",
+        "face_holding_back_tears":                 "\U0001f979",
+        "frowning":                                "\U0001f626",
+        "anguished":                               "\U0001f627",
+        "fearful":                                 "\U0001f628",
+        "cold_sweat":                              "\U0001f630",
+        "disappointed_relieved":                   "\U0001f625",
+        "cry":                                     "\U0001f622",
+        "sob":                                     "\U0001f62d",
+        "scream":                                  "\U0001f631",
+        "confounded":                              "\U0001f616",
+        "persevere":                               "\U0001f623",
+        "disappointed":                            "\U0001f61e",
+        "sweat":                                   "\U0001f613",
+        "weary":                                   "\U0001f629",
+        "tired_face":                              "\U0001f62b",
+        "yawning_face":                            "\U0001f971",
+        "triumph":                                 "\U0001f624",
+        "rage":                                    "\U0001f621",
+        "pout":                                    "\U0001f621",
+        "angry":                                   "\U0001f620",
+        "cursing_face":                            "\U0001f92c",
+        "smiling_imp":                             "\U0001f608",
+        "imp":                                     "\U0001f47f",
+        "skull":                                   "\U0001f480",
+        "skull_and_crossbones":                    "\u2620\ufe0f",
+        "hankey":                                  "\U0001f4a9",
+        "poop":                                    "\U0001f4a9",
+        "shit":                                    "\U0001f4a9",
+        "clown_face":                              "\U0001f921",
+        "japanese_ogre":                           "\U0001f479",
+        "japanese_goblin":                         "\U0001f47a",
+        "ghost":                                   "\U0001f47b",
+        "alien":                                   "\U0001f47d",
+        "space_invader":                           "\U0001f47e",
+        "robot":                                   "\U0001f916",
+        "smiley_cat":                              "\U0001f63a",
+        "smile_cat":                               "\U0001f638",
+        "joy_cat":                                 "\U0001f639",
+        "heart_eyes_cat":                          "\U0001f63b",
+        "smirk_cat":                               "\U0001f63c",
+        "kissing_cat":                             "\U0001f63d",
+        "scream_cat":                              "\U0001f640",
+        "crying_cat_face":                         "\U0001f63f",
+        "pouting_cat":                             "\U0001f63e",
+        "see_no_evil":                             "\U0001f648",
+        "hear_no_evil":                            "\U0001f649",
+        "speak_no_evil":                           "\U0001f64a",
+        "love_letter":                             "\U0001f48c",
+        "cupid":                                   "\U0001f498",
+        "gift_heart":                              "\U0001f49d",
+        "sparkling_heart":                         "\U0001f496",
+        "heartpulse":                              "\U0001f497",
+        "heartbeat":                               "\U0001f493",
+        "revolving_hearts":                        "\U0001f49e",
+        "two_hearts":                              "\U0001f495",
+        "heart_decoration":                        "\U0001f49f",
+        "heavy_heart_exclamation":                 "\u2763\ufe0f",
+        "broken_heart":                            "\U0001f494",
+        "heart_on_fire":                           "\u2764\ufe0f\u200d\U0001f525",
+        "mending_heart":                           "\u2764\ufe0f\u200d\U0001fa79",
+        "heart":                                   "\u2764\ufe0f",
+        "pink_heart":                              "\U0001fa77",
+        "orange_heart":                            "\U0001f9e1",
+        "yellow_heart":                            "\U0001f49b",
+        "green_heart":                             "\U0001f49a",
+        "blue_heart":                              "\U0001f499",
+        "light_blue_heart":                        "\U0001fa75",
+        "purple_heart":                            "\U0001f49c",
+        "brown_heart":                             "\U0001f90e",
+        "black_heart":                             "\U0001f5a4",
+        "grey_heart":                              "\U0001fa76",
+        "white_heart":                             "\U0001f90d",
+        "kiss":                                    "\U0001f48b",
+        "100":                                     "\U0001f4af",
+        "anger":                                   "\U0001f4a2",
+        "boom":                                    "\U0001f4a5",
+        "collision":                               "\U0001f4a5",
+        "dizzy":                                   "\U0001f4ab",
+        "sweat_drops":                             "\U0001f4a6",
+        "dash":                                    "\U0001f4a8",
+        "hole":                                    "\U0001f573\ufe0f",
+        "speech_balloon":                          "\U0001f4ac",
+        "eye_speech_bubble":                       "\U0001f441\ufe0f\u200d\U0001f5e8\ufe0f",
+        "left_speech_bubble":                      "\U0001f5e8\ufe0f",
+        "right_anger_bubble":                      "\U0001f5ef\ufe0f",
+        "thought_balloon":                         "\U0001f4ad",
+        "zzz":                                     "\U0001f4a4",
+        "wave":                                    "\U0001f44b",
+        "raised_back_of_hand":                     "\U0001f91a",
+        "raised_hand_with_fingers_splayed":        "\U0001f590\ufe0f",
+        "hand":                                    "\u270b",
+        "raised_hand":                             "\u270b",
+        "vulcan_salute":                           "\U0001f596",
+        "rightwards_hand":                         "\U0001faf1",
+        "leftwards_hand":                          "\U0001faf2",
+        "palm_down_hand":                          "\U0001faf3",
+        "palm_up_hand":                            "\U0001faf4",
+        "leftwards_pushing_hand":                  "\U0001faf7",
+        "rightwards_pushing_hand":                 "\U0001faf8",
+        "ok_hand":                                 "\U0001f44c",
+        "pinched_fingers":                         "\U0001f90c",
+        "pinching_hand":                           "\U0001f90f",
+        "v":                                       "\u270c\ufe0f",
+        "crossed_fingers":                         "\U0001f91e",
+        "hand_with_index_finger_and_thumb_crossed": "\U0001faf0",
+        "love_you_gesture":                         "\U0001f91f",
+        "metal":                                    "\U0001f918",
+        "call_me_hand":                             "\U0001f919",
+        "point_left":                               "\U0001f448",
+        "point_right":                              "\U0001f449",
+        "point_up_2":                               "\U0001f446",
+        "middle_finger":                            "\U0001f595",
+        "fu":                                       "\U0001f595",
+        "point_down":                               "\U0001f447",
+        "point_up":                                 "\u261d\ufe0f",
+        "index_pointing_at_the_viewer":             "\U0001faf5",
+        "+1":                                       "\U0001f44d",
+        "thumbsup":                                 "\U0001f44d",
+        "-1":                                       "\U0001f44e",
+        "thumbsdown":                               "\U0001f44e",
+        "fist_raised":                              "\u270a",
+        "fist":                                     "\u270a",
+        "fist_oncoming":                            "\U0001f44a",
+        "facepunch":                                "\U0001f44a",
+        "punch":                                    "\U0001f44a",
+        "fist_left":                                "\U0001f91b",
+        "fist_right":                               "\U0001f91c",
+        "clap":                                     "\U0001f44f",
+        "raised_hands":                             "\U0001f64c",
+        "heart_hands":                              "\U0001faf6",
+        "open_hands":                               "\U0001f450",
+        "palms_up_together":                        "\U0001f932",
+        "handshake":                                "\U0001f91d",
+        "pray":                                     "\U0001f64f",
+        "writing_hand":                             "\u270d\ufe0f",
+        "nail_care":                                "\U0001f485",
+        "selfie":                                   "\U0001f933",
+        "muscle":                                   "\U0001f4aa",
+        "mechanical_arm":                           "\U0001f9be",
+        "mechanical_leg":                           "\U0001f9bf",
+        "leg":                                      "\U0001f9b5",
+        "foot":                                     "\U0001f9b6",
+        "ear":                                      "\U0001f442",
+        "ear_with_hearing_aid":                     "\U0001f9bb",
+        "nose":                                     "\U0001f443",
+        "brain":                                    "\U0001f9e0",
+        "anatomical_heart":                         "\U0001fac0",
+        "lungs":                                    "\U0001fac1",
+        "tooth":                                    "\U0001f9b7",
+        "bone":                                     "\U0001f9b4",
+        "eyes":                                     "\U0001f440",
+        "eye":                                      "\U0001f441\ufe0f",
+        "tongue":                                   "\U0001f445",
+        "lips":                                     "\U0001f444",
+        "biting_lip":                               "\U0001fae6",
+        "baby":                                     "\U0001f476",
+        "child":                                    "\U0001f9d2",
+        "boy":                                      "\U0001f466",
+        "girl":                                     "\U0001f467",
+        "adult":                                    "\U0001f9d1",
+        "blond_haired_person":                      "\U0001f471",
+        "man":                                      "\U0001f468",
+        "bearded_person":                           "\U0001f9d4",
+        "man_beard":                                "\U0001f9d4\u200d\u2642\ufe0f",
+        "woman_beard":                              "\U0001f9d4\u200d\u2640\ufe0f",
+        "red_haired_man":                           "\U0001f468\u200d\U0001f9b0",
+        "curly_haired_man":                         "\U0001f468\u200d\U0001f9b1",
+        "white_haired_man":                         "\U0001f468\u200d\U0001f9b3",
+        "bald_man":                                 "\U0001f468\u200d\U0001f9b2",
+        "woman":                                    "\U0001f469",
+        "red_haired_woman":                         "\U0001f469\u200d\U0001f9b0",
+        "person_red_hair":                          "\U0001f9d1\u200d\U0001f9b0",
+        "curly_haired_woman":                       "\U0001f469\u200d\U0001f9b1",
+        "person_curly_hair":                        "\U0001f9d1\u200d\U0001f9b1",
+        "white_haired_woman":                       "\U0001f469\u200d\U0001f9b3",
+        "person_white_hair":                        "\U0001f9d1\u200d\U0001f9b3",
+        "bald_woman":                               "\U0001f469\u200d\U0001f9b2",
+        "person_bald":                              "\U0001f9d1\u200d\U0001f9b2",
+        "blond_haired_woman":                       "\U0001f471\u200d\u2640\ufe0f",
+        "blonde_woman":                             "\U0001f471\u200d\u2640\ufe0f",
+        "blond_haired_man":                         "\U0001f471\u200d\u2642\ufe0f",
+        "older_adult":                              "\U0001f9d3",
+        "older_man":                                "\U0001f474",
+        "older_woman":                              "\U0001f475",
+        "frowning_person":                          "\U0001f64d",
+        "frowning_man":                             "\U0001f64d\u200d\u2642\ufe0f",
+        "frowning_woman":                           "\U0001f64d\u200d\u2640\ufe0f",
+        "pouting_face":                             "\U0001f64e",
+        "pouting_man":                              "\U0001f64e\u200d\u2642\ufe0f",
+        "pouting_woman":                            "\U0001f64e\u200d\u2640\ufe0f",
+        "no_good":                                  "\U0001f645",
+        "no_good_man":                              "\U0001f645\u200d\u2642\ufe0f",
+        "ng_man":                                   "\U0001f645\u200d\u2642\ufe0f",
+        "no_good_woman":                            "\U0001f645\u200d\u2640\ufe0f",
+        "ng_woman":                                 "\U0001f645\u200d\u2640\ufe0f",
+        "ok_person":                                "\U0001f646",
+        "ok_man":                                   "\U0001f646\u200d\u2642\ufe0f",
+        "ok_woman":                                 "\U0001f646\u200d\u2640\ufe0f",
+        "tipping_hand_person":                      "\U0001f481",
+        "information_desk_person":                  "\U0001f481",
+        "tipping_hand_man":                         "\U0001f481\u200d\u2642\ufe0f",
+        "sassy_man":                                "\U0001f481\u200d\u2642\ufe0f",
+        "tipping_hand_woman":                       "\U0001f481\u200d\u2640\ufe0f",
+        "sassy_woman":                              "\U0001f481\u200d\u2640\ufe0f",
+        "raising_hand":                             "\U0001f64b",
+        "raising_hand_man":                         "\U0001f64b\u200d\u2642\ufe0f",
+        "raising_hand_woman":                       "\U0001f64b\u200d\u2640\ufe0f",
+        "deaf_person":                              "\U0001f9cf",
+        "deaf_man":                                 "\U0001f9cf\u200d\u2642\ufe0f",
+        "deaf_woman":                               "\U0001f9cf\u200d\u2640\ufe0f",
+        "bow":                                      "\U0001f647",
+        "bowing_man":                               "\U0001f647\u200d\u2642\ufe0f",
+        "bowing_woman":                             "\U0001f647\u200d\u2640\ufe0f",
+        "facepalm":                                 "\U0001f926",
+        "man_facepalming":                          "\U0001f926\u200d\u2642\ufe0f",
+        "woman_facepalming":                        "\U0001f926\u200d\u2640\ufe0f",
+        "shrug":                                    "\U0001f937",
+        "man_shrugging":                            "\U0001f937\u200d\u2642\ufe0f",
+        "woman_shrugging":                          "\U0001f937\u200d\u2640\ufe0f",
+        "health_worker":                            "\U0001f9d1\u200d\u2695\ufe0f",
+        "man_health_worker":                        "\U0001f468\u200d\u2695\ufe0f",
+        "woman_health_worker":                      "\U0001f469\u200d\u2695\ufe0f",
+        "student":                                  "\U0001f9d1\u200d\U0001f393",
+        "man_student":                              "\U0001f468\u200d\U0001f393",
+        "woman_student":                            "\U0001f469\u200d\U0001f393",
+        "teacher":                                  "\U0001f9d1\u200d\U0001f3eb",
+        "man_teacher":                              "\U0001f468\u200d\U0001f3eb",
+        "woman_teacher":                            "\U0001f469\u200d\U0001f3eb",
+        "judge":                                    "\U0001f9d1\u200d\u2696\ufe0f",
+        "man_judge":                                "\U0001f468\u200d\u2696\ufe0f",
+        "woman_judge":                              "\U0001f469\u200d\u2696\ufe0f",
+        "farmer":                                   "\U0001f9d1\u200d\U0001f33e",
+        "man_farmer":                               "\U0001f468\u200d\U0001f33e",
+        "woman_farmer":                             "\U0001f469\u200d\U0001f33e",
+        "cook":                                     "\U0001f9d1\u200d\U0001f373",
+        "man_cook":                                 "\U0001f468\u200d\U0001f373",
+        "woman_cook":                               "\U0001f469\u200d\U0001f373",
+        "mechanic":                                 "\U0001f9d1\u200d\U0001f527",
+        "man_mechanic":                             "\U0001f468\u200d\U0001f527",
+        "woman_mechanic":                           "\U0001f469\u200d\U0001f527",
+        "factory_worker":                           "\U0001f9d1\u200d\U0001f3ed",
+        "man_factory_worker":                       "\U0001f468\u200d\U0001f3ed",
+        "woman_factory_worker":                     "\U0001f469\u200d\U0001f3ed",
+        "office_worker":                            "\U0001f9d1\u200d\U0001f4bc",
+        "man_office_worker":                        "\U0001f468\u200d\U0001f4bc",
+        "woman_office_worker":                      "\U0001f469\u200d\U0001f4bc",
+        "scientist":                                "\U0001f9d1\u200d\U0001f52c",
+        "man_scientist":                            "\U0001f468\u200d\U0001f52c",
+        "woman_scientist":                          "\U0001f469\u200d\U0001f52c",
+        "technologist":                             "\U0001f9d1\u200d\U0001f4bb",
+        "man_technologist":                         "\U0001f468\u200d\U0001f4bb",
+        "woman_technologist":                       "\U0001f469\u200d\U0001f4bb",
+        "singer":                                   "\U0001f9d1\u200d\U0001f3a4",
+        "man_singer":                               "\U0001f468\u200d\U0001f3a4",
+        "woman_singer":                             "\U0001f469\u200d\U0001f3a4",
+        "artist":                                   "\U0001f9d1\u200d\U0001f3a8",
+        "man_artist":                               "\U0001f468\u200d\U0001f3a8",
+        "woman_artist":                             "\U0001f469\u200d\U0001f3a8",
+        "pilot":                                    "\U0001f9d1\u200d\u2708\ufe0f",
+        "man_pilot":                                "\U0001f468\u200d\u2708\ufe0f",
+        "woman_pilot":                              "\U0001f469\u200d\u2708\ufe0f",
+        "astronaut":                                "\U0001f9d1\u200d\U0001f680",
+        "man_astronaut":                            "\U0001f468\u200d\U0001f680",
+        "woman_astronaut":                          "\U0001f469\u200d\U0001f680",
+        "firefighter":                              "\U0001f9d1\u200d\U0001f692",
+        "man_firefighter":                          "\U0001f468\u200d\U0001f692",
+        "woman_firefighter":                        "\U0001f469\u200d\U0001f692",
+        "police_officer":                           "\U0001f46e",
+        "cop":                                      "\U0001f46e",
+        "policeman":                                "\U0001f46e\u200d\u2642\ufe0f",
+        "policewoman":                              "\U0001f46e\u200d\u2640\ufe0f",
+        "detective":                                "\U0001f575\ufe0f",
+        "male_detective":                           "\U0001f575\ufe0f\u200d\u2642\ufe0f",
+        "female_detective":                         "\U0001f575\ufe0f\u200d\u2640\ufe0f",
+        "guard":                                    "\U0001f482",
+        "guardsman":                                "\U0001f482\u200d\u2642\ufe0f",
+        "guardswoman":                              "\U0001f482\u200d\u2640\ufe0f",
+        "ninja":                                    "\U0001f977",
+        "construction_worker":                      "\U0001f477",
+        "construction_worker_man":                  "\U0001f477\u200d\u2642\ufe0f",
+        "construction_worker_woman":                "\U0001f477\u200d\u2640\ufe0f",
+        "person_with_crown":                        "\U0001fac5",
+        "prince":                                   "\U0001f934",
+        "princess":                                 "\U0001f478",
+        "person_with_turban":                       "\U0001f473",
+        "man_with_turban":                          "\U0001f473\u200d\u2642\ufe0f",
+        "woman_with_turban":                        "\U0001f473\u200d\u2640\ufe0f",
+        "man_with_gua_pi_mao":                      "\U0001f472",
+        "woman_with_headscarf":                     "\U0001f9d5",
+        "person_in_tuxedo":                         "\U0001f935",
+        "man_in_tuxedo":                            "\U0001f935\u200d\u2642\ufe0f",
+        "woman_in_tuxedo":                          "\U0001f935\u200d\u2640\ufe0f",
+        "person_with_veil":                         "\U0001f470",
+        "man_with_veil":                            "\U0001f470\u200d\u2642\ufe0f",
+        "woman_with_veil":                          "\U0001f470\u200d\u2640\ufe0f",
+        "bride_with_veil":                          "\U0001f470\u200d\u2640\ufe0f",
+        "pregnant_woman":                           "\U0001f930",
+        "pregnant_man":                             "\U0001fac3",
+        "pregnant_person":                          "\U0001fac4",
+        "breast_feeding":                           "\U0001f931",
+        "woman_feeding_baby":                       "\U0001f469\u200d\U0001f37c",
+        "man_feeding_baby":                         "\U0001f468\u200d\U0001f37c",
+        "person_feeding_baby":                      "\U0001f9d1\u200d\U0001f37c",
+        "angel":                                    "\U0001f47c",
+        "santa":                                    "\U0001f385",
+        "mrs_claus":                                "\U0001f936",
+        "mx_claus":                                 "\U0001f9d1\u200d\U0001f384",
+        "superhero":                                "\U0001f9b8",
+        "superhero_man":                            "\U0001f9b8\u200d\u2642\ufe0f",
+        "superhero_woman":                          "\U0001f9b8\u200d\u2640\ufe0f",
+        "supervillain":                             "\U0001f9b9",
+        "supervillain_man":                         "\U0001f9b9\u200d\u2642\ufe0f",
+        "supervillain_woman":                       "\U0001f9b9\u200d\u2640\ufe0f",
+        "mage":                                     "\U0001f9d9",
+        "mage_man":                                 "\U0001f9d9\u200d\u2642\ufe0f",
+        "mage_woman":                               "\U0001f9d9\u200d\u2640\ufe0f",
+        "fairy":                                    "\U0001f9da",
+        "fairy_man":                                "\U0001f9da\u200d\u2642\ufe0f",
+        "fairy_woman":                              "\U0001f9da\u200d\u2640\ufe0f",
+        "vampire":                                  "\U0001f9db",
+        "vampire_man":                              "\U0001f9db\u200d\u2642\ufe0f",
+        "vampire_woman":                            "\U0001f9db\u200d\u2640\ufe0f",
+        "merperson":                                "\U0001f9dc",
+        "merman":                                   "\U0001f9dc\u200d\u2642\ufe0f",
+        "mermaid":                                  "\U0001f9dc\u200d\u2640\ufe0f",
+        "elf":                                      "\U0001f9dd",
+        "elf_man":                                  "\U0001f9dd\u200d\u2642\ufe0f",
+        "elf_woman":                                "\U0001f9dd\u200d\u2640\ufe0f",
+        "genie":                                    "\U0001f9de",
+        "genie_man":                                "\U0001f9de\u200d\u2642\ufe0f",
+        "genie_woman":                              "\U0001f9de\u200d\u2640\ufe0f",
+        "zombie":                                   "\U0001f9df",
+        "zombie_man":                               "\U0001f9df\u200d\u2642\ufe0f",
+        "zombie_woman":                             "\U0001f9df\u200d\u2640\ufe0f",
+        "troll":                                    "\U0001f9cc",
+        "massage":                                  "\U0001f486",
+        "massage_man":                              "\U0001f486\u200d\u2642\ufe0f",
+        "massage_woman":                            "\U0001f486\u200d\u2640\ufe0f",
+        "haircut":                                  "\U0001f487",
+        "haircut_man":                              "\U0001f487\u200d\u2642\ufe0f",
+        "haircut_woman":                            "\U0001f487\u200d\u2640\ufe0f",
+        "walking":                                  "\U0001f6b6",
+        "walking_man":                              "\U0001f6b6\u200d\u2642\ufe0f",
+        "walking_woman":                            "\U0001f6b6\u200d\u2640\ufe0f",
+        "standing_person":                          "\U0001f9cd",
+        "standing_man":                             "\U0001f9cd\u200d\u2642\ufe0f",
+        "standing_woman":                           "\U0001f9cd\u200d\u2640\ufe0f",
+        "kneeling_person":                          "\U0001f9ce",
+        "kneeling_man":                             "\U0001f9ce\u200d\u2642\ufe0f",
+        "kneeling_woman":                           "\U0001f9ce\u200d\u2640\ufe0f",
+        "person_with_probing_cane":                 "\U0001f9d1\u200d\U0001f9af",
+        "man_with_probing_cane":                    "\U0001f468\u200d\U0001f9af",
+        "woman_with_probing_cane":                  "\U0001f469\u200d\U0001f9af",
+        "person_in_motorized_wheelchair":           "\U0001f9d1\u200d\U0001f9bc",
+        "man_in_motorized_wheelchair":              "\U0001f468\u200d\U0001f9bc",
+        "woman_in_motorized_wheelchair":            "\U0001f469\u200d\U0001f9bc",
+        "person_in_manual_wheelchair":              "\U0001f9d1\u200d\U0001f9bd",
+        "man_in_manual_wheelchair":                 "\U0001f468\u200d\U0001f9bd",
+        "woman_in_manual_wheelchair":               "\U0001f469\u200d\U0001f9bd",
+        "runner":                                   "\U0001f3c3",
+        "running":                                  "\U0001f3c3",
+        "running_man":                              "\U0001f3c3\u200d\u2642\ufe0f",
+        "running_woman":                            "\U0001f3c3\u200d\u2640\ufe0f",
+        "woman_dancing":                            "\U0001f483",
+        "dancer":                                   "\U0001f483",
+        "man_dancing":                              "\U0001f57a",
+        "business_suit_levitating":                 "\U0001f574\ufe0f",
+        "dancers":                                  "\U0001f46f",
+        "dancing_men":                              "\U0001f46f\u200d\u2642\ufe0f",
+        "dancing_women":                            "\U0001f46f\u200d\u2640\ufe0f",
+        "sauna_person":                             "\U0001f9d6",
+        "sauna_man":                                "\U0001f9d6\u200d\u2642\ufe0f",
+        "sauna_woman":                              "\U0001f9d6\u200d\u2640\ufe0f",
+        "climbing":                                 "\U0001f9d7",
+        "climbing_man":                             "\U0001f9d7\u200d\u2642\ufe0f",
+        "climbing_woman":                           "\U0001f9d7\u200d\u2640\ufe0f",
+        "person_fencing":                           "\U0001f93a",
+        "horse_racing":                             "\U0001f3c7",
+        "skier":                                    "\u26f7\ufe0f",
+        "snowboarder":                              "\U0001f3c2",
+        "golfing":                                  "\U0001f3cc\ufe0f",
+        "golfing_man":                              "\U0001f3cc\ufe0f\u200d\u2642\ufe0f",
+        "golfing_woman":                            "\U0001f3cc\ufe0f\u200d\u2640\ufe0f",
+        "surfer":                                   "\U0001f3c4",
+        "surfing_man":                              "\U0001f3c4\u200d\u2642\ufe0f",
+        "surfing_woman":                            "\U0001f3c4\u200d\u2640\ufe0f",
+        "rowboat":                                  "\U0001f6a3",
+        "rowing_man":                               "\U0001f6a3\u200d\u2642\ufe0f",
+        "rowing_woman":                             "\U0001f6a3\u200d\u2640\ufe0f",
+        "swimmer":                                  "\U0001f3ca",
+        "swimming_man":                             "\U0001f3ca\u200d\u2642\ufe0f",
+        "swimming_woman":                           "\U0001f3ca\u200d\u2640\ufe0f",
+        "bouncing_ball_person":                     "\u26f9\ufe0f",
+        "bouncing_ball_man":                        "\u26f9\ufe0f\u200d\u2642\ufe0f",
+        "basketball_man":                           "\u26f9\ufe0f\u200d\u2642\ufe0f",
+        "bouncing_ball_woman":                      "\u26f9\ufe0f\u200d\u2640\ufe0f",
+        "basketball_woman":                         "\u26f9\ufe0f\u200d\u2640\ufe0f",
+        "weight_lifting":                           "\U0001f3cb\ufe0f",
+        "weight_lifting_man":                       "\U0001f3cb\ufe0f\u200d\u2642\ufe0f",
+        "weight_lifting_woman":                     "\U0001f3cb\ufe0f\u200d\u2640\ufe0f",
+        "bicyclist":                                "\U0001f6b4",
+        "biking_man":                               "\U0001f6b4\u200d\u2642\ufe0f",
+        "biking_woman":                             "\U0001f6b4\u200d\u2640\ufe0f",
+        "mountain_bicyclist":                       "\U0001f6b5",
+        "mountain_biking_man":                      "\U0001f6b5\u200d\u2642\ufe0f",
+        "mountain_biking_woman":                    "\U0001f6b5\u200d\u2640\ufe0f",
+        "cartwheeling":                             "\U0001f938",
+        "man_cartwheeling":                         "\U0001f938\u200d\u2642\ufe0f",
+        "woman_cartwheeling":                       "\U0001f938\u200d\u2640\ufe0f",
+        "wrestling":                                "\U0001f93c",
+        "men_wrestling":                            "\U0001f93c\u200d\u2642\ufe0f",
+        "women_wrestling":                          "\U0001f93c\u200d\u2640\ufe0f",
+        "water_polo":                               "\U0001f93d",
+        "man_playing_water_polo":                   "\U0001f93d\u200d\u2642\ufe0f",
+        "woman_playing_water_polo":                 "\U0001f93d\u200d\u2640\ufe0f",
+        "handball_person":                          "\U0001f93e",
+        "man_playing_handball":                     "\U0001f93e\u200d\u2642\ufe0f",
+        "woman_playing_handball":                   "\U0001f93e\u200d\u2640\ufe0f",
+        "juggling_person":                          "\U0001f939",
+        "man_juggling":                             "\U0001f939\u200d\u2642\ufe0f",
+        "woman_juggling":                           "\U0001f939\u200d\u2640\ufe0f",
+        "lotus_position":                           "\U0001f9d8",
+        "lotus_position_man":                       "\U0001f9d8\u200d\u2642\ufe0f",
+        "lotus_position_woman":                     "\U0001f9d8\u200d\u2640\ufe0f",
+        "bath":                                     "\U0001f6c0",
+        "sleeping_bed":                             "\U0001f6cc",
+        "people_holding_hands":                     "\U0001f9d1\u200d\U0001f91d\u200d\U0001f9d1",
+        "two_women_holding_hands":                  "\U0001f46d",
+        "couple":                                   "\U0001f46b",
+        "two_men_holding_hands":                    "\U0001f46c",
+        "couplekiss":                               "\U0001f48f",
+        "couplekiss_man_woman":                     "\U0001f469\u200d\u2764\ufe0f\u200d\U0001f48b\u200d\U0001f468",
+        "couplekiss_man_man":                       "\U0001f468\u200d\u2764\ufe0f\u200d\U0001f48b\u200d\U0001f468",
+        "couplekiss_woman_woman":                   "\U0001f469\u200d\u2764\ufe0f\u200d\U0001f48b\u200d\U0001f469",
+        "couple_with_heart":                        "\U0001f491",
+        "couple_with_heart_woman_man":              "\U0001f469\u200d\u2764\ufe0f\u200d\U0001f468",
+        "couple_with_heart_man_man":                "\U0001f468\u200d\u2764\ufe0f\u200d\U0001f468",
+        "couple_with_heart_woman_woman":            "\U0001f469\u200d\u2764\ufe0f\u200d\U0001f469",
+        "family":                                   "\U0001f46a",
+        "family_man_woman_boy":                     "\U0001f468\u200d\U0001f469\u200d\U0001f466",
+        "family_man_woman_girl":                    "\U0001f468\u200d\U0001f469\u200d\U0001f467",
+        "family_man_woman_girl_boy":                "\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466",
+        "family_man_woman_boy_boy":                 "\U0001f468\u200d\U0001f469\u200d\U0001f466\u200d\U0001f466",
+        "family_man_woman_girl_girl":               "\U0001f468\u200d\U0001f469\u200d\U0001f467\u200d\U0001f467",
+        "family_man_man_boy":                       "\U0001f468\u200d\U0001f468\u200d\U0001f466",
+        "family_man_man_girl":                      "\U0001f468\u200d\U0001f468\u200d\U0001f467",
+        "family_man_man_girl_boy":                  "\U0001f468\u200d\U0001f468\u200d\U0001f467\u200d\U0001f466",
+        "family_man_man_boy_boy":                   "\U0001f468\u200d\U0001f468\u200d\U0001f466\u200d\U0001f466",
+        "family_man_man_girl_girl":                 "\U0001f468\u200d\U0001f468\u200d\U0001f467\u200d\U0001f467",
+        "family_woman_woman_boy":                   "\U0001f469\u200d\U0001f469\u200d\U0001f466",
+        "family_woman_woman_girl":                  "\U0001f469\u200d\U0001f469\u200d\U0001f467",
+        "family_woman_woman_girl_boy":              "\U0001f469\u200d\U0001f469\u200d\U0001f467\u200d\U0001f466",
+        "family_woman_woman_boy_boy":               "\U0001f469\u200d\U0001f469\u200d\U0001f466\u200d\U0001f466",
+        "family_woman_woman_girl_girl":             "\U0001f469\u200d\U0001f469\u200d\U0001f467\u200d\U0001f467",
+        "family_man_boy":                           "\U0001f468\u200d\U0001f466",
+        "family_man_boy_boy":                       "\U0001f468\u200d\U0001f466\u200d\U0001f466",
+        "family_man_girl":                          "\U0001f468\u200d\U0001f467",
+        "family_man_girl_boy":                      "\U0001f468\u200d\U0001f467\u200d\U0001f466",
+        "family_man_girl_girl":                     "\U0001f468\u200d\U0001f467\u200d\U0001f467",
+        "family_woman_boy":                         "\U0001f469\u200d\U0001f466",
+        "family_woman_boy_boy":                     "\U0001f469\u200d\U0001f466\u200d\U0001f466",
+        "family_woman_girl":                        "\U0001f469\u200d\U0001f467",
+        "family_woman_girl_boy":                    "\U0001f469\u200d\U0001f467\u200d\U0001f466",
+        "family_woman_girl_girl":                   "\U0001f469\u200d\U0001f467\u200d\U0001f467",
+        "speaking_head":                            "\U0001f5e3\ufe0f",
+        "bust_in_silhouette":                       "\U0001f464",
+        "busts_in_silhouette":                      "\U0001f465",
+        "people_hugging":                           "\U0001fac2",
+        "footprints":                               "\U0001f463",
+        "monkey_face":                              "\U0001f435",
+        "monkey":                                   "\U0001f412",
+        "gorilla":                                  "\U0001f98d",
+        "orangutan":                                "\U0001f9a7",
+        "dog":                                      "\U0001f436",
+        "dog2":                                     "\U0001f415",
+        "guide_dog":                                "\U0001f9ae",
+        "service_dog":                              "\U0001f415\u200d\U0001f9ba",
+        "poodle":                                   "\U0001f429",
+        "wolf":                                     "\U0001f43a",
+        "fox_face":                                 "\U0001f98a",
+        "raccoon":                                  "\U0001f99d",
+        "cat":                                      "\U0001f431",
+        "cat2":                                     "\U0001f408",
+        "black_cat":                                "\U0001f408\u200d\u2b1b",
+        "lion":                                     "\U0001f981",
+        "tiger":                                    "\U0001f42f",
+        "tiger2":                                   "\U0001f405",
+        "leopard":                                  "\U0001f406",
+        "horse":                                    "\U0001f434",
+        "moose":                                    "\U0001face",
+        "donkey":                                   "\U0001facf",
+        "racehorse":                                "\U0001f40e",
+        "unicorn":                                  "\U0001f984",
+        "zebra":                                    "\U0001f993",
+        "deer":                                     "\U0001f98c",
+        "bison":                                    "\U0001f9ac",
+        "cow":                                      "\U0001f42e",
+        "ox":                                       "\U0001f402",
+        "water_buffalo":                            "\U0001f403",
+        "cow2":                                     "\U0001f404",
+        "pig":                                      "\U0001f437",
+        "pig2":                                     "\U0001f416",
+        "boar":                                     "\U0001f417",
+        "pig_nose":                                 "\U0001f43d",
+        "ram":                                      "\U0001f40f",
+        "sheep":                                    "\U0001f411",
+        "goat":                                     "\U0001f410",
+        "dromedary_camel":                          "\U0001f42a",
+        "camel":                                    "\U0001f42b",
+        "llama":                                    "\U0001f999",
+        "giraffe":                                  "\U0001f992",
+        "elephant":                                 "\U0001f418",
+        "mammoth":                                  "\U0001f9a3",
+        "rhinoceros":                               "\U0001f98f",
+        "hippopotamus":                             "\U0001f99b",
+        "mouse":                                    "\U0001f42d",
+        "mouse2":                                   "\U0001f401",
+        "rat":                                      "\U0001f400",
+        "hamster":                                  "\U0001f439",
+        "rabbit":                                   "\U0001f430",
+        "rabbit2":                                  "\U0001f407",
+        "chipmunk":                                 "\U0001f43f\ufe0f",
+        "beaver":                                   "\U0001f9ab",
+        "hedgehog":                                 "\U0001f994",
+        "bat":                                      "\U0001f987",
+        "bear":                                     "\U0001f43b",
+        "polar_bear":                               "\U0001f43b\u200d\u2744\ufe0f",
+        "koala":                                    "\U0001f428",
+        "panda_face":                               "\U0001f43c",
+        "sloth":                                    "\U0001f9a5",
+        "otter":                                    "\U0001f9a6",
+        "skunk":                                    "\U0001f9a8",
+        "kangaroo":                                 "\U0001f998",
+        "badger":                                   "\U0001f9a1",
+        "feet":                                     "\U0001f43e",
+        "paw_prints":                               "\U0001f43e",
+        "turkey":                                   "\U0001f983",
+        "chicken":                                  "\U0001f414",
+        "rooster":                                  "\U0001f413",
+        "hatching_chick":                           "\U0001f423",
+        "baby_chick":                               "\U0001f424",
+        "hatched_chick":                            "\U0001f425",
+        "bird":                                     "\U0001f426",
+        "penguin":                                  "\U0001f427",
+        "dove":                                     "\U0001f54a\ufe0f",
+        "eagle":                                    "\U0001f985",
+        "duck":                                     "\U0001f986",
+        "swan":                                     "\U0001f9a2",
+        "owl":                                      "\U0001f989",
+        "dodo":                                     "\U0001f9a4",
+        "feather":                                  "\U0001fab6",
+        "flamingo":                                 "\U0001f9a9",
+        "peacock":                                  "\U0001f99a",
+        "parrot":                                   "\U0001f99c",
+        "wing":                                     "\U0001fabd",
+        "black_bird":                               "\U0001f426\u200d\u2b1b",
+        "goose":                                    "\U0001fabf",
+        "frog":                                     "\U0001f438",
+        "crocodile":                                "\U0001f40a",
+        "turtle":                                   "\U0001f422",
+        "lizard":                                   "\U0001f98e",
+        "snake":                                    "\U0001f40d",
+        "dragon_face":                              "\U0001f432",
+        "dragon":                                   "\U0001f409",
+        "sauropod":                                 "\U0001f995",
+        "t-rex":                                    "\U0001f996",
+        "whale":                                    "\U0001f433",
+        "whale2":                                   "\U0001f40b",
+        "dolphin":                                  "\U0001f42c",
+        "flipper":                                  "\U0001f42c",
+        "seal":                                     "\U0001f9ad",
+        "fish":                                     "\U0001f41f",
+        "tropical_fish":                            "\U0001f420",
+        "blowfish":                                 "\U0001f421",
+        "shark":                                    "\U0001f988",
+        "octopus":                                  "\U0001f419",
+        "shell":                                    "\U0001f41a",
+        "coral":                                    "\U0001fab8",
+        "jellyfish":                                "\U0001fabc",
+        "snail":                                    "\U0001f40c",
+        "butterfly":                                "\U0001f98b",
+        "bug":                                      "\U0001f41b",
+        "ant":                                      "\U0001f41c",
+        "bee":                                      "\U0001f41d",
+        "honeybee":                                 "\U0001f41d",
+        "beetle":                                   "\U0001fab2",
+        "lady_beetle":                              "\U0001f41e",
+        "cricket":                                  "\U0001f997",
+        "cockroach":                                "\U0001fab3",
+        "spider":                                   "\U0001f577\ufe0f",
+        "spider_web":                               "\U0001f578\ufe0f",
+        "scorpion":                                 "\U0001f982",
+        "mosquito":                                 "\U0001f99f",
+        "fly":                                      "\U0001fab0",
+        "worm":                                     "\U0001fab1",
+        "microbe":                                  "\U0001f9a0",
+        "bouquet":                                  "\U0001f490",
+        "cherry_blossom":                           "\U0001f338",
+        "white_flower":                             "\U0001f4ae",
+        "lotus":                                    "\U0001fab7",
+        "rosette":                                  "\U0001f3f5\ufe0f",
+        "rose":                                     "\U0001f339",
+        "wilted_flower":                            "\U0001f940",
+        "hibiscus":                                 "\U0001f33a",
+        "sunflower":                                "\U0001f33b",
+        "blossom":                                  "\U0001f33c",
+        "tulip":                                    "\U0001f337",
+        "hyacinth":                                 "\U0001fabb",
+        "seedling":                                 "\U0001f331",
+        "potted_plant":                             "\U0001fab4",
+        "evergreen_tree":                           "\U0001f332",
+        "deciduous_tree":                           "\U0001f333",
+        "palm_tree":                                "\U0001f334",
+        "cactus":                                   "\U0001f335",
+        "ear_of_rice":                              "\U0001f33e",
+        "herb":                                     "\U0001f33f",
+        "shamrock":                                 "\u2618\ufe0f",
+        "four_leaf_clover":                         "\U0001f340",
+        "maple_leaf":                               "\U0001f341",
+        "fallen_leaf":                              "\U0001f342",
+        "leaves":                                   "\U0001f343",
+        "empty_nest":                               "\U0001fab9",
+        "nest_with_eggs":                           "\U0001faba",
+        "mushroom":                                 "\U0001f344",
+        "grapes":                                   "\U0001f347",
+        "melon":                                    "\U0001f348",
+        "watermelon":                               "\U0001f349",
+        "tangerine":                                "\U0001f34a",
+        "orange":                                   "\U0001f34a",
+        "mandarin":                                 "\U0001f34a",
+        "lemon":                                    "\U0001f34b",
+        "banana":                                   "\U0001f34c",
+        "pineapple":                                "\U0001f34d",
+        "mango":                                    "\U0001f96d",
+        "apple":                                    "\U0001f34e",
+        "green_apple":                              "\U0001f34f",
+        "pear":                                     "\U0001f350",
+        "peach":                                    "\U0001f351",
+        "cherries":                                 "\U0001f352",
+        "strawberry":                               "\U0001f353",
+        "blueberries":                              "\U0001fad0",
+        "kiwi_fruit":                               "\U0001f95d",
+        "tomato":                                   "\U0001f345",
+        "olive":                                    "\U0001fad2",
+        "coconut":                                  "\U0001f965",
+        "avocado":                                  "\U0001f951",
+        "eggplant":                                 "\U0001f346",
+        "potato":                                   "\U0001f954",
+        "carrot":                                   "\U0001f955",
+        "corn":                                     "\U0001f33d",
+        "hot_pepper":                               "\U0001f336\ufe0f",
+        "bell_pepper":                              "\U0001fad1",
+        "cucumber":                                 "\U0001f952",
+        "leafy_green":                              "\U0001f96c",
+        "broccoli":                                 "\U0001f966",
+        "garlic":                                   "\U0001f9c4",
+        "onion":                                    "\U0001f9c5",
+        "peanuts":                                  "\U0001f95c",
+        "beans":                                    "\U0001fad8",
+        "chestnut":                                 "\U0001f330",
+        "ginger_root":                              "\U0001fada",
+        "pea_pod":                                  "\U0001fadb",
+        "bread":                                    "\U0001f35e",
+        "croissant":                                "\U0001f950",
+        "baguette_bread":                           "\U0001f956",
+        "flatbread":                                "\U0001fad3",
+        "pretzel":                                  "\U0001f968",
+        "bagel":                                    "\U0001f96f",
+        "pancakes":                                 "\U0001f95e",
+        "waffle":                                   "\U0001f9c7",
+        "cheese":                                   "\U0001f9c0",
+        "meat_on_bone":                             "\U0001f356",
+        "poultry_leg":                              "\U0001f357",
+        "cut_of_meat":                              "\U0001f969",
+        "bacon":                                    "\U0001f953",
+        "hamburger":                                "\U0001f354",
+        "fries":                                    "\U0001f35f",
+        "pizza":                                    "\U0001f355",
+        "hotdog":                                   "\U0001f32d",
+        "sandwich":                                 "\U0001f96a",
+        "taco":                                     "\U0001f32e",
+        "burrito":                                  "\U0001f32f",
+        "tamale":                                   "\U0001fad4",
+        "stuffed_flatbread":                        "\U0001f959",
+        "falafel":                                  "\U0001f9c6",
+        "egg":                                      "\U0001f95a",
+        "fried_egg":                                "\U0001f373",
+        "shallow_pan_of_food":                      "\U0001f958",
+        "stew":                                     "\U0001f372",
+        "fondue":                                   "\U0001fad5",
+        "bowl_with_spoon":                          "\U0001f963",
+        "green_salad":                              "\U0001f957",
+        "popcorn":                                  "\U0001f37f",
+        "butter":                                   "\U0001f9c8",
+        "salt":                                     "\U0001f9c2",
+        "canned_food":                              "\U0001f96b",
+        "bento":                                    "\U0001f371",
+        "rice_cracker":                             "\U0001f358",
+        "rice_ball":                                "\U0001f359",
+        "rice":                                     "\U0001f35a",
+        "curry":                                    "\U0001f35b",
+        "ramen":                                    "\U0001f35c",
+        "spaghetti":                                "\U0001f35d",
+        "sweet_potato":                             "\U0001f360",
+        "oden":                                     "\U0001f362",
+        "sushi":                                    "\U0001f363",
+        "fried_shrimp":                             "\U0001f364",
+        "fish_cake":                                "\U0001f365",
+        "moon_cake":                                "\U0001f96e",
+        "dango":                                    "\U0001f361",
+        "dumpling":                                 "\U0001f95f",
+        "fortune_cookie":                           "\U0001f960",
+        "takeout_box":                              "\U0001f961",
+        "crab":                                     "\U0001f980",
+        "lobster":                                  "\U0001f99e",
+        "shrimp":                                   "\U0001f990",
+        "squid":                                    "\U0001f991",
+        "oyster":                                   "\U0001f9aa",
+        "icecream":                                 "\U0001f366",
+        "shaved_ice":                               "\U0001f367",
+        "ice_cream":                                "\U0001f368",
+        "doughnut":                                 "\U0001f369",
+        "cookie":                                   "\U0001f36a",
+        "birthday":                                 "\U0001f382",
+        "cake":                                     "\U0001f370",
+        "cupcake":                                  "\U0001f9c1",
+        "pie":                                      "\U0001f967",
+        "chocolate_bar":                            "\U0001f36b",
+        "candy":                                    "\U0001f36c",
+        "lollipop":                                 "\U0001f36d",
+        "custard":                                  "\U0001f36e",
+        "honey_pot":                                "\U0001f36f",
+        "baby_bottle":                              "\U0001f37c",
+        "milk_glass":                               "\U0001f95b",
+        "coffee":                                   "\u2615",
+        "teapot":                                   "\U0001fad6",
+        "tea":                                      "\U0001f375",
+        "sake":                                     "\U0001f376",
+        "champagne":                                "\U0001f37e",
+        "wine_glass":                               "\U0001f377",
+        "cocktail":                                 "\U0001f378",
+        "tropical_drink":                           "\U0001f379",
+        "beer":                                     "\U0001f37a",
+        "beers":                                    "\U0001f37b",
+        "clinking_glasses":                         "\U0001f942",
+        "tumbler_glass":                            "\U0001f943",
+        "pouring_liquid":                           "\U0001fad7",
+        "cup_with_straw":                           "\U0001f964",
+        "bubble_tea":                               "\U0001f9cb",
+        "beverage_box":                             "\U0001f9c3",
+        "mate":                                     "\U0001f9c9",
+        "ice_cube":                                 "\U0001f9ca",
+        "chopsticks":                               "\U0001f962",
+        "plate_with_cutlery":                       "\U0001f37d\ufe0f",
+        "fork_and_knife":                           "\U0001f374",
+        "spoon":                                    "\U0001f944",
+        "hocho":                                    "\U0001f52a",
+        "knife":                                    "\U0001f52a",
+        "jar":                                      "\U0001fad9",
+        "amphora":                                  "\U0001f3fa",
+        "earth_africa":                             "\U0001f30d",
+        "earth_americas":                           "\U0001f30e",
+        "earth_asia":                               "\U0001f30f",
+        "globe_with_meridians":                     "\U0001f310",
+        "world_map":                                "\U0001f5fa\ufe0f",
+        "japan":                                    "\U0001f5fe",
+        "compass":                                  "\U0001f9ed",
+        "mountain_snow":                            "\U0001f3d4\ufe0f",
+        "mountain":                                 "\u26f0\ufe0f",
+        "volcano":                                  "\U0001f30b",
+        "mount_fuji":                               "\U0001f5fb",
+        "camping":                                  "\U0001f3d5\ufe0f",
+        "beach_umbrella":                           "\U0001f3d6\ufe0f",
+        "desert":                                   "\U0001f3dc\ufe0f",
+        "desert_island":                            "\U0001f3dd\ufe0f",
+        "national_park":                            "\U0001f3de\ufe0f",
+        "stadium":                                  "\U0001f3df\ufe0f",
+        "classical_building":                       "\U0001f3db\ufe0f",
+        "building_construction":                    "\U0001f3d7\ufe0f",
+        "bricks":                                   "\U0001f9f1",
+        "rock":                                     "\U0001faa8",
+        "wood":                                     "\U0001fab5",
+        "hut":                                      "\U0001f6d6",
+        "houses":                                   "\U0001f3d8\ufe0f",
+        "derelict_house":                           "\U0001f3da\ufe0f",
+        "house":                                    "\U0001f3e0",
+        "house_with_garden":                        "\U0001f3e1",
+        "office":                                   "\U0001f3e2",
+        "post_office":                              "\U0001f3e3",
+        "european_post_office":                     "\U0001f3e4",
+        "hospital":                                 "\U0001f3e5",
+        "bank":                                     "\U0001f3e6",
+        "hotel":                                    "\U0001f3e8",
+        "love_hotel":                               "\U0001f3e9",
+        "convenience_store":                        "\U0001f3ea",
+        "school":                                   "\U0001f3eb",
+        "department_store":                         "\U0001f3ec",
+        "factory":                                  "\U0001f3ed",
+        "japanese_castle":                          "\U0001f3ef",
+        "european_castle":                          "\U0001f3f0",
+        "wedding":                                  "\U0001f492",
+        "tokyo_tower":                              "\U0001f5fc",
+        "statue_of_liberty":                        "\U0001f5fd",
+        "church":                                   "\u26ea",
+        "mosque":                                   "\U0001f54c",
+        "hindu_temple":                             "\U0001f6d5",
+        "synagogue":                                "\U0001f54d",
+        "shinto_shrine":                            "\u26e9\ufe0f",
+        "kaaba":                                    "\U0001f54b",
+        "fountain":                                 "\u26f2",
+        "tent":                                     "\u26fa",
+        "foggy":                                    "\U0001f301",
+        "night_with_stars":                         "\U0001f303",
+        "cityscape":                                "\U0001f3d9\ufe0f",
+        "sunrise_over_mountains":                   "\U0001f304",
+        "sunrise":                                  "\U0001f305",
+        "city_sunset":                              "\U0001f306",
+        "city_sunrise":                             "\U0001f307",
+        "bridge_at_night":                          "\U0001f309",
+        "hotsprings":                               "\u2668\ufe0f",
+        "carousel_horse":                           "\U0001f3a0",
+        "playground_slide":                         "\U0001f6dd",
+        "ferris_wheel":                             "\U0001f3a1",
+        "roller_coaster":                           "\U0001f3a2",
+        "barber":                                   "\U0001f488",
+        "circus_tent":                              "\U0001f3aa",
+        "steam_locomotive":                         "\U0001f682",
+        "railway_car":                              "\U0001f683",
+        "bullettrain_side":                         "\U0001f684",
+        "bullettrain_front":                        "\U0001f685",
+        "train2":                                   "\U0001f686",
+        "metro":                                    "\U0001f687",
+        "light_rail":                               "\U0001f688",
+        "station":                                  "\U0001f689",
+        "tram":                                     "\U0001f68a",
+        "monorail":                                 "\U0001f69d",
+        "mountain_railway":                         "\U0001f69e",
+        "train":                                    "\U0001f68b",
+        "bus":                                      "\U0001f68c",
+        "oncoming_bus":                             "\U0001f68d",
+        "trolleybus":                               "\U0001f68e",
+        "minibus":                                  "\U0001f690",
+        "ambulance":                                "\U0001f691",
+        "fire_engine":                              "\U0001f692",
+        "police_car":                               "\U0001f693",
+        "oncoming_police_car":                      "\U0001f694",
+        "taxi":                                     "\U0001f695",
+        "oncoming_taxi":                            "\U0001f696",
+        "car":                                      "\U0001f697",
+        "red_car":                                  "\U0001f697",
+        "oncoming_automobile":                      "\U0001f698",
+        "blue_car":                                 "\U0001f699",
+        "pickup_truck":                             "\U0001f6fb",
+        "truck":                                    "\U0001f69a",
+        "articulated_lorry":                        "\U0001f69b",
+        "tractor":                                  "\U0001f69c",
+        "racing_car":                               "\U0001f3ce\ufe0f",
+        "motorcycle":                               "\U0001f3cd\ufe0f",
+        "motor_scooter":                            "\U0001f6f5",
+        "manual_wheelchair":                        "\U0001f9bd",
+        "motorized_wheelchair":                     "\U0001f9bc",
+        "auto_rickshaw":                            "\U0001f6fa",
+        "bike":                                     "\U0001f6b2",
+        "kick_scooter":                             "\U0001f6f4",
+        "skateboard":                               "\U0001f6f9",
+        "roller_skate":                             "\U0001f6fc",
+        "busstop":                                  "\U0001f68f",
+        "motorway":                                 "\U0001f6e3\ufe0f",
+        "railway_track":                            "\U0001f6e4\ufe0f",
+        "oil_drum":                                 "\U0001f6e2\ufe0f",
+        "fuelpump":                                 "\u26fd",
+        "wheel":                                    "\U0001f6de",
+        "rotating_light":                           "\U0001f6a8",
+        "traffic_light":                            "\U0001f6a5",
+        "vertical_traffic_light":                   "\U0001f6a6",
+        "stop_sign":                                "\U0001f6d1",
+        "construction":                             "\U0001f6a7",
+        "anchor":                                   "\u2693",
+        "ring_buoy":                                "\U0001f6df",
+        "boat":                                     "\u26f5",
+        "sailboat":                                 "\u26f5",
+        "canoe":                                    "\U0001f6f6",
+        "speedboat":                                "\U0001f6a4",
+        "passenger_ship":                           "\U0001f6f3\ufe0f",
+        "ferry":                                    "\u26f4\ufe0f",
+        "motor_boat":                               "\U0001f6e5\ufe0f",
+        "ship":                                     "\U0001f6a2",
+        "airplane":                                 "\u2708\ufe0f",
+        "small_airplane":                           "\U0001f6e9\ufe0f",
+        "flight_departure":                         "\U0001f6eb",
+        "flight_arrival":                           "\U0001f6ec",
+        "parachute":                                "\U0001fa82",
+        "seat":                                     "\U0001f4ba",
+        "helicopter":                               "\U0001f681",
+        "suspension_railway":                       "\U0001f69f",
+        "mountain_cableway":                        "\U0001f6a0",
+        "aerial_tramway":                           "\U0001f6a1",
+        "artificial_satellite":                     "\U0001f6f0\ufe0f",
+        "rocket":                                   "\U0001f680",
+        "flying_saucer":                            "\U0001f6f8",
+        "bellhop_bell":                             "\U0001f6ce\ufe0f",
+        "luggage":                                  "\U0001f9f3",
+        "hourglass":                                "\u231b",
+        "hourglass_flowing_sand":                   "\u23f3",
+        "watch":                                    "\u231a",
+        "alarm_clock":                              "\u23f0",
+        "stopwatch":                                "\u23f1\ufe0f",
+        "timer_clock":                              "\u23f2\ufe0f",
+        "mantelpiece_clock":                        "\U0001f570\ufe0f",
+        "clock12":                                  "\U0001f55b",
+        "clock1230":                                "\U0001f567",
+        "clock1":                                   "\U0001f550",
+        "clock130":                                 "\U0001f55c",
+        "clock2":                                   "\U0001f551",
+        "clock230":                                 "\U0001f55d",
+        "clock3":                                   "\U0001f552",
+        "clock330":                                 "\U0001f55e",
+        "clock4":                                   "\U0001f553",
+        "clock430":                                 "\U0001f55f",
+        "clock5":                                   "\U0001f554",
+        "clock530":                                 "\U0001f560",
+        "clock6":                                   "\U0001f555",
+        "clock630":                                 "\U0001f561",
+        "clock7":                                   "\U0001f556",
+        "clock730":                                 "\U0001f562",
+        "clock8":                                   "\U0001f557",
+        "clock830":                                 "\U0001f563",
+        "clock9":                                   "\U0001f558",
+        "clock930":                                 "\U0001f564",
+        "clock10":                                  "\U0001f559",
+        "clock1030":                                "\U0001f565",
+        "clock11":                                  "\U0001f55a",
+        "clock1130":                                "\U0001f566",
+        "new_moon":                                 "\U0001f311",
+        "waxing_crescent_moon":                     "\U0001f312",
+        "first_quarter_moon":                       "\U0001f313",
+        "moon":                                     "\U0001f314",
+        "waxing_gibbous_moon":                      "\U0001f314",
+        "full_moon":                                "\U0001f315",
+        "waning_gibbous_moon":                      "\U0001f316",
+        "last_quarter_moon":                        "\U0001f317",
+        "waning_crescent_moon":                     "\U0001f318",
+        "crescent_moon":                            "\U0001f319",
+        "new_moon_with_face":                       "\U0001f31a",
+        "first_quarter_moon_with_face":             "\U0001f31b",
+        "last_quarter_moon_with_face":              "\U0001f31c",
+        "thermometer":                              "\U0001f321\ufe0f",
+        "sunny":                                    "\u2600\ufe0f",
+        "full_moon_with_face":                      "\U0001f31d",
+        "sun_with_face":                            "\U0001f31e",
+        "ringed_planet":                            "\U0001fa90",
+        "star":                                     "\u2b50",
+        "star2":                                    "\U0001f31f",
+        "stars":                                    "\U0001f320",
+        "milky_way":                                "\U0001f30c",
+        "cloud":                                    "\u2601\ufe0f",
+        "partly_sunny":                             "\u26c5",
+        "cloud_with_lightning_and_rain":            "\u26c8\ufe0f",
+        "sun_behind_small_cloud":                   "\U0001f324\ufe0f",
+        "sun_behind_large_cloud":                   "\U0001f325\ufe0f",
+        "sun_behind_rain_cloud":                    "\U0001f326\ufe0f",
+        "cloud_with_rain":                          "\U0001f327\ufe0f",
+        "cloud_with_snow":                          "\U0001f328\ufe0f",
+        "cloud_with_lightning":                     "\U0001f329\ufe0f",
+        "tornado":                                  "\U0001f32a\ufe0f",
+        "fog":                                      "\U0001f32b\ufe0f",
+        "wind_face":                                "\U0001f32c\ufe0f",
+        "cyclone":                                  "\U0001f300",
+        "rainbow":                                  "\U0001f308",
+        "closed_umbrella":                          "\U0001f302",
+        "open_umbrella":                            "\u2602\ufe0f",
+        "umbrella":                                 "\u2614",
+        "parasol_on_ground":                        "\u26f1\ufe0f",
+        "zap":                                      "\u26a1",
+        "snowflake":                                "\u2744\ufe0f",
+        "snowman_with_snow":                        "\u2603\ufe0f",
+        "snowman":                                  "\u26c4",
+        "comet":                                    "\u2604\ufe0f",
+        "fire":                                     "\U0001f525",
+        "droplet":                                  "\U0001f4a7",
+        "ocean":                                    "\U0001f30a",
+        "jack_o_lantern":                           "\U0001f383",
+        "christmas_tree":                           "\U0001f384",
+        "fireworks":                                "\U0001f386",
+        "sparkler":                                 "\U0001f387",
+        "firecracker":                              "\U0001f9e8",
+        "sparkles":                                 "\u2728",
+        "balloon":                                  "\U0001f388",
+        "tada":                                     "\U0001f389",
+        "confetti_ball":                            "\U0001f38a",
+        "tanabata_tree":                            "\U0001f38b",
+        "bamboo":                                   "\U0001f38d",
+        "dolls":                                    "\U0001f38e",
+        "flags":                                    "\U0001f38f",
+        "wind_chime":                               "\U0001f390",
+        "rice_scene":                               "\U0001f391",
+        "red_envelope":                             "\U0001f9e7",
+        "ribbon":                                   "\U0001f380",
+        "gift":                                     "\U0001f381",
+        "reminder_ribbon":                          "\U0001f397\ufe0f",
+        "tickets":                                  "\U0001f39f\ufe0f",
+        "ticket":                                   "\U0001f3ab",
+        "medal_military":                           "\U0001f396\ufe0f",
+        "trophy":                                   "\U0001f3c6",
+        "medal_sports":                             "\U0001f3c5",
+        "1st_place_medal":                          "\U0001f947",
+        "2nd_place_medal":                          "\U0001f948",
+        "3rd_place_medal":                          "\U0001f949",
+        "soccer":                                   "\u26bd",
+        "baseball":                                 "\u26be",
+        "softball":                                 "\U0001f94e",
+        "basketball":                               "\U0001f3c0",
+        "volleyball":                               "\U0001f3d0",
+        "football":                                 "\U0001f3c8",
+        "rugby_football":                           "\U0001f3c9",
+        "tennis":                                   "\U0001f3be",
+        "flying_disc":                              "\U0001f94f",
+        "bowling":                                  "\U0001f3b3",
+        "cricket_game":                             "\U0001f3cf",
+        "field_hockey":                             "\U0001f3d1",
+        "ice_hockey":                               "\U0001f3d2",
+        "lacrosse":                                 "\U0001f94d",
+        "ping_pong":                                "\U0001f3d3",
+        "badminton":                                "\U0001f3f8",
+        "boxing_glove":                             "\U0001f94a",
+        "martial_arts_uniform":                     "\U0001f94b",
+        "goal_net":                                 "\U0001f945",
+        "golf":                                     "\u26f3",
+        "ice_skate":                                "\u26f8\ufe0f",
+        "fishing_pole_and_fish":                    "\U0001f3a3",
+        "diving_mask":                              "\U0001f93f",
+        "running_shirt_with_sash":                  "\U0001f3bd",
+        "ski":                                      "\U0001f3bf",
+        "sled":                                     "\U0001f6f7",
+        "curling_stone":                            "\U0001f94c",
+        "dart":                                     "\U0001f3af",
+        "yo_yo":                                    "\U0001fa80",
+        "kite":                                     "\U0001fa81",
+        "gun":                                      "\U0001f52b",
+        "8ball":                                    "\U0001f3b1",
+        "crystal_ball":                             "\U0001f52e",
+        "magic_wand":                               "\U0001fa84",
+        "video_game":                               "\U0001f3ae",
+        "joystick":                                 "\U0001f579\ufe0f",
+        "slot_machine":                             "\U0001f3b0",
+        "game_die":                                 "\U0001f3b2",
+        "jigsaw":                                   "\U0001f9e9",
+        "teddy_bear":                               "\U0001f9f8",
+        "pinata":                                   "\U0001fa85",
+        "mirror_ball":                              "\U0001faa9",
+        "nesting_dolls":                            "\U0001fa86",
+        "spades":                                   "\u2660\ufe0f",
+        "hearts":                                   "\u2665\ufe0f",
+        "diamonds":                                 "\u2666\ufe0f",
+        "clubs":                                    "\u2663\ufe0f",
+        "chess_pawn":                               "\u265f\ufe0f",
+        "black_joker":                              "\U0001f0cf",
+        "mahjong":                                  "\U0001f004",
+        "flower_playing_cards":                     "\U0001f3b4",
+        "performing_arts":                          "\U0001f3ad",
+        "framed_picture":                           "\U0001f5bc\ufe0f",
+        "art":                                      "\U0001f3a8",
+        "thread":                                   "\U0001f9f5",
+        "sewing_needle":                            "\U0001faa1",
+        "yarn":                                     "\U0001f9f6",
+        "knot":                                     "\U0001faa2",
+        "eyeglasses":                               "\U0001f453",
+        "dark_sunglasses":                          "\U0001f576\ufe0f",
+        "goggles":                                  "\U0001f97d",
+        "lab_coat":                                 "\U0001f97c",
+        "safety_vest":                              "\U0001f9ba",
+        "necktie":                                  "\U0001f454",
+        "shirt":                                    "\U0001f455",
+        "tshirt":                                   "\U0001f455",
+        "jeans":                                    "\U0001f456",
+        "scarf":                                    "\U0001f9e3",
+        "gloves":                                   "\U0001f9e4",
+        "coat":                                     "\U0001f9e5",
+        "socks":                                    "\U0001f9e6",
+        "dress":                                    "\U0001f457",
+        "kimono":                                   "\U0001f458",
+        "sari":                                     "\U0001f97b",
+        "one_piece_swimsuit":                       "\U0001fa71",
+        "swim_brief":                               "\U0001fa72",
+        "shorts":                                   "\U0001fa73",
+        "bikini":                                   "\U0001f459",
+        "womans_clothes":                           "\U0001f45a",
+        "folding_hand_fan":                         "\U0001faad",
+        "purse":                                    "\U0001f45b",
+        "handbag":                                  "\U0001f45c",
+        "pouch":                                    "\U0001f45d",
+        "shopping":                                 "\U0001f6cd\ufe0f",
+        "school_satchel":                           "\U0001f392",
+        "thong_sandal":                             "\U0001fa74",
+        "mans_shoe":                                "\U0001f45e",
+        "shoe":                                     "\U0001f45e",
+        "athletic_shoe":                            "\U0001f45f",
+        "hiking_boot":                              "\U0001f97e",
+        "flat_shoe":                                "\U0001f97f",
+        "high_heel":                                "\U0001f460",
+        "sandal":                                   "\U0001f461",
+        "ballet_shoes":                             "\U0001fa70",
+        "boot":                                     "\U0001f462",
+        "hair_pick":                                "\U0001faae",
+        "crown":                                    "\U0001f451",
+        "womans_hat":                               "\U0001f452",
+        "tophat":                                   "\U0001f3a9",
+        "mortar_board":                             "\U0001f393",
+        "billed_cap":                               "\U0001f9e2",
+        "military_helmet":                          "\U0001fa96",
+        "rescue_worker_helmet":                     "\u26d1\ufe0f",
+        "prayer_beads":                             "\U0001f4ff",
+        "lipstick":                                 "\U0001f484",
+        "ring":                                     "\U0001f48d",
+        "gem":                                      "\U0001f48e",
+        "mute":                                     "\U0001f507",
+        "speaker":                                  "\U0001f508",
+        "sound":                                    "\U0001f509",
+        "loud_sound":                               "\U0001f50a",
+        "loudspeaker":                              "\U0001f4e2",
+        "mega":                                     "\U0001f4e3",
+        "postal_horn":                              "\U0001f4ef",
+        "bell":                                     "\U0001f514",
+        "no_bell":                                  "\U0001f515",
+        "musical_score":                            "\U0001f3bc",
+        "musical_note":                             "\U0001f3b5",
+        "notes":                                    "\U0001f3b6",
+        "studio_microphone":                        "\U0001f399\ufe0f",
+        "level_slider":                             "\U0001f39a\ufe0f",
+        "control_knobs":                            "\U0001f39b\ufe0f",
+        "microphone":                               "\U0001f3a4",
+        "headphones":                               "\U0001f3a7",
+        "radio":                                    "\U0001f4fb",
+        "saxophone":                                "\U0001f3b7",
+        "accordion":                                "\U0001fa97",
+        "guitar":                                   "\U0001f3b8",
+        "musical_keyboard":                         "\U0001f3b9",
+        "trumpet":                                  "\U0001f3ba",
+        "violin":                                   "\U0001f3bb",
+        "banjo":                                    "\U0001fa95",
+        "drum":                                     "\U0001f941",
+        "long_drum":                                "\U0001fa98",
+        "maracas":                                  "\U0001fa87",
+        "flute":                                    "\U0001fa88",
+        "iphone":                                   "\U0001f4f1",
+        "calling":                                  "\U0001f4f2",
+        "phone":                                    "\u260e\ufe0f",
+        "telephone":                                "\u260e\ufe0f",
+        "telephone_receiver":                       "\U0001f4de",
+        "pager":                                    "\U0001f4df",
+        "fax":                                      "\U0001f4e0",
+        "battery":                                  "\U0001f50b",
+        "low_battery":                              "\U0001faab",
+        "electric_plug":                            "\U0001f50c",
+        "computer":                                 "\U0001f4bb",
+        "desktop_computer":                         "\U0001f5a5\ufe0f",
+        "printer":                                  "\U0001f5a8\ufe0f",
+        "keyboard":                                 "\u2328\ufe0f",
+        "computer_mouse":                           "\U0001f5b1\ufe0f",
+        "trackball":                                "\U0001f5b2\ufe0f",
+        "minidisc":                                 "\U0001f4bd",
+        "floppy_disk":                              "\U0001f4be",
+        "cd":                                       "\U0001f4bf",
+        "dvd":                                      "\U0001f4c0",
+        "abacus":                                   "\U0001f9ee",
+        "movie_camera":                             "\U0001f3a5",
+        "film_strip":                               "\U0001f39e\ufe0f",
+        "film_projector":                           "\U0001f4fd\ufe0f",
+        "clapper":                                  "\U0001f3ac",
+        "tv":                                       "\U0001f4fa",
+        "camera":                                   "\U0001f4f7",
+        "camera_flash":                             "\U0001f4f8",
+        "video_camera":                             "\U0001f4f9",
+        "vhs":                                      "\U0001f4fc",
+        "mag":                                      "\U0001f50d",
+        "mag_right":                                "\U0001f50e",
+        "candle":                                   "\U0001f56f\ufe0f",
+        "bulb":                                     "\U0001f4a1",
+        "flashlight":                               "\U0001f526",
+        "izakaya_lantern":                          "\U0001f3ee",
+        "lantern":                                  "\U0001f3ee",
+        "diya_lamp":                                "\U0001fa94",
+        "notebook_with_decorative_cover":           "\U0001f4d4",
+        "closed_book":                              "\U0001f4d5",
+        "book":                                     "\U0001f4d6",
+        "open_book":                                "\U0001f4d6",
+        "green_book":                               "\U0001f4d7",
+        "blue_book":                                "\U0001f4d8",
+        "orange_book":                              "\U0001f4d9",
+        "books":                                    "\U0001f4da",
+        "notebook":                                 "\U0001f4d3",
+        "ledger":                                   "\U0001f4d2",
+        "page_with_curl":                           "\U0001f4c3",
+        "scroll":                                   "\U0001f4dc",
+        "page_facing_up":                           "\U0001f4c4",
+        "newspaper":                                "\U0001f4f0",
+        "newspaper_roll":                           "\U0001f5de\ufe0f",
+        "bookmark_tabs":                            "\U0001f4d1",
+        "bookmark":                                 "\U0001f516",
+        "label":                                    "\U0001f3f7\ufe0f",
+        "moneybag":                                 "\U0001f4b0",
+        "coin":                                     "\U0001fa99",
+        "yen":                                      "\U0001f4b4",
+        "dollar":                                   "\U0001f4b5",
+        "euro":                                     "\U0001f4b6",
+        "pound":                                    "\U0001f4b7",
+        "money_with_wings":                         "\U0001f4b8",
+        "credit_card":                              "\U0001f4b3",
+        "receipt":                                  "\U0001f9fe",
+        "chart":                                    "\U0001f4b9",
+        "envelope":                                 "\u2709\ufe0f",
+        "email":                                    "\U0001f4e7",
+        "e-mail":                                   "\U0001f4e7",
+        "incoming_envelope":                        "\U0001f4e8",
+        "envelope_with_arrow":                      "\U0001f4e9",
+        "outbox_tray":                              "\U0001f4e4",
+        "inbox_tray":                               "\U0001f4e5",
+        "package":                                  "\U0001f4e6",
+        "mailbox":                                  "\U0001f4eb",
+        "mailbox_closed":                           "\U0001f4ea",
+        "mailbox_with_mail":                        "\U0001f4ec",
+        "mailbox_with_no_mail":                     "\U0001f4ed",
+        "postbox":                                  "\U0001f4ee",
+        "ballot_box":                               "\U0001f5f3\ufe0f",
+        "pencil2":                                  "\u270f\ufe0f",
+        "black_nib":                                "\u2712\ufe0f",
+        "fountain_pen":                             "\U0001f58b\ufe0f",
+        "pen":                                      "\U0001f58a\ufe0f",
+        "paintbrush":                               "\U0001f58c\ufe0f",
+        "crayon":                                   "\U0001f58d\ufe0f",
+        "memo":                                     "\U0001f4dd",
+        "pencil":                                   "\U0001f4dd",
+        "briefcase":                                "\U0001f4bc",
+        "file_folder":                              "\U0001f4c1",
+        "open_file_folder":                         "\U0001f4c2",
+        "card_index_dividers":                      "\U0001f5c2\ufe0f",
+        "date":                                     "\U0001f4c5",
+        "calendar":                                 "\U0001f4c6",
+        "spiral_notepad":                           "\U0001f5d2\ufe0f",
+        "spiral_calendar":                          "\U0001f5d3\ufe0f",
+        "card_index":                               "\U0001f4c7",
+        "chart_with_upwards_trend":                 "\U0001f4c8",
+        "chart_with_downwards_trend":               "\U0001f4c9",
+        "bar_chart":                                "\U0001f4ca",
+        "clipboard":                                "\U0001f4cb",
+        "pushpin":                                  "\U0001f4cc",
+        "round_pushpin":                            "\U0001f4cd",
+        "paperclip":                                "\U0001f4ce",
+        "paperclips":                               "\U0001f587\ufe0f",
+        "straight_ruler":                           "\U0001f4cf",
+        "triangular_ruler":                         "\U0001f4d0",
+        "scissors":                                 "\u2702\ufe0f",
+        "card_file_box":                            "\U0001f5c3\ufe0f",
+        "file_cabinet":                             "\U0001f5c4\ufe0f",
+        "wastebasket":                              "\U0001f5d1\ufe0f",
+        "lock":                                     "\U0001f512",
+        "unlock":                                   "\U0001f513",
+        "lock_with_ink_pen":                        "\U0001f50f",
+        "closed_lock_with_key":                     "\U0001f510",
+        "key":                                      "\U0001f511",
+        "old_key":                                  "\U0001f5dd\ufe0f",
+        "hammer":                                   "\U0001f528",
+        "axe":                                      "\U0001fa93",
+        "pick":                                     "\u26cf\ufe0f",
+        "hammer_and_pick":                          "\u2692\ufe0f",
+        "hammer_and_wrench":                        "\U0001f6e0\ufe0f",
+        "dagger":                                   "\U0001f5e1\ufe0f",
+        "crossed_swords":                           "\u2694\ufe0f",
+        "bomb":                                     "\U0001f4a3",
+        "boomerang":                                "\U0001fa83",
+        "bow_and_arrow":                            "\U0001f3f9",
+        "shield":                                   "\U0001f6e1\ufe0f",
+        "carpentry_saw":                            "\U0001fa9a",
+        "wrench":                                   "\U0001f527",
+        "screwdriver":                              "\U0001fa9b",
+        "nut_and_bolt":                             "\U0001f529",
+        "gear":                                     "\u2699\ufe0f",
+        "clamp":                                    "\U0001f5dc\ufe0f",
+        "balance_scale":                            "\u2696\ufe0f",
+        "probing_cane":                             "\U0001f9af",
+        "link":                                     "\U0001f517",
+        "chains":                                   "\u26d3\ufe0f",
+        "hook":                                     "\U0001fa9d",
+        "toolbox":                                  "\U0001f9f0",
+        "magnet":                                   "\U0001f9f2",
+        "ladder":                                   "\U0001fa9c",
+        "alembic":                                  "\u2697\ufe0f",
+        "test_tube":                                "\U0001f9ea",
+        "petri_dish":                               "\U0001f9eb",
+        "dna":                                      "\U0001f9ec",
+        "microscope":                               "\U0001f52c",
+        "telescope":                                "\U0001f52d",
+        "satellite":                                "\U0001f4e1",
+        "syringe":                                  "\U0001f489",
+        "drop_of_blood":                            "\U0001fa78",
+        "pill":                                     "\U0001f48a",
+        "adhesive_bandage":                         "\U0001fa79",
+        "crutch":                                   "\U0001fa7c",
+        "stethoscope":                              "\U0001fa7a",
+        "x_ray":                                    "\U0001fa7b",
+        "door":                                     "\U0001f6aa",
+        "elevator":                                 "\U0001f6d7",
+        "mirror":                                   "\U0001fa9e",
+        "window":                                   "\U0001fa9f",
+        "bed":                                      "\U0001f6cf\ufe0f",
+        "couch_and_lamp":                           "\U0001f6cb\ufe0f",
+        "chair":                                    "\U0001fa91",
+        "toilet":                                   "\U0001f6bd",
+        "plunger":                                  "\U0001faa0",
+        "shower":                                   "\U0001f6bf",
+        "bathtub":                                  "\U0001f6c1",
+        "mouse_trap":                               "\U0001faa4",
+        "razor":                                    "\U0001fa92",
+        "lotion_bottle":                            "\U0001f9f4",
+        "safety_pin":                               "\U0001f9f7",
+        "broom":                                    "\U0001f9f9",
+        "basket":                                   "\U0001f9fa",
+        "roll_of_paper":                            "\U0001f9fb",
+        "bucket":                                   "\U0001faa3",
+        "soap":                                     "\U0001f9fc",
+        "bubbles":                                  "\U0001fae7",
+        "toothbrush":                               "\U0001faa5",
+        "sponge":                                   "\U0001f9fd",
+        "fire_extinguisher":                        "\U0001f9ef",
+        "shopping_cart":                            "\U0001f6d2",
+        "smoking":                                  "\U0001f6ac",
+        "coffin":                                   "\u26b0\ufe0f",
+        "headstone":                                "\U0001faa6",
+        "funeral_urn":                              "\u26b1\ufe0f",
+        "nazar_amulet":                             "\U0001f9ff",
+        "hamsa":                                    "\U0001faac",
+        "moyai":                                    "\U0001f5ff",
+        "placard":                                  "\U0001faa7",
+        "identification_card":                      "\U0001faaa",
+        "atm":                                      "\U0001f3e7",
+        "put_litter_in_its_place":                  "\U0001f6ae",
+        "potable_water":                            "\U0001f6b0",
+        "wheelchair":                               "\u267f",
+        "mens":                                     "\U0001f6b9",
+        "womens":                                   "\U0001f6ba",
+        "restroom":                                 "\U0001f6bb",
+        "baby_symbol":                              "\U0001f6bc",
+        "wc":                                       "\U0001f6be",
+        "passport_control":                         "\U0001f6c2",
+        "customs":                                  "\U0001f6c3",
+        "baggage_claim":                            "\U0001f6c4",
+        "left_luggage":                             "\U0001f6c5",
+        "warning":                                  "\u26a0\ufe0f",
+        "children_crossing":                        "\U0001f6b8",
+        "no_entry":                                 "\u26d4",
+        "no_entry_sign":                            "\U0001f6ab",
+        "no_bicycles":                              "\U0001f6b3",
+        "no_smoking":                               "\U0001f6ad",
+        "do_not_litter":                            "\U0001f6af",
+        "non-potable_water":                        "\U0001f6b1",
+        "no_pedestrians":                           "\U0001f6b7",
+        "no_mobile_phones":                         "\U0001f4f5",
+        "underage":                                 "\U0001f51e",
+        "radioactive":                              "\u2622\ufe0f",
+        "biohazard":                                "\u2623\ufe0f",
+        "arrow_up":                                 "\u2b06\ufe0f",
+        "arrow_upper_right":                        "\u2197\ufe0f",
+        "arrow_right":                              "\u27a1\ufe0f",
+        "arrow_lower_right":                        "\u2198\ufe0f",
+        "arrow_down":                               "\u2b07\ufe0f",
+        "arrow_lower_left":                         "\u2199\ufe0f",
+        "arrow_left":                               "\u2b05\ufe0f",
+        "arrow_upper_left":                         "\u2196\ufe0f",
+        "arrow_up_down":                            "\u2195\ufe0f",
+        "left_right_arrow":                         "\u2194\ufe0f",
+        "leftwards_arrow_with_hook":                "\u21a9\ufe0f",
+        "arrow_right_hook":                         "\u21aa\ufe0f",
+        "arrow_heading_up":                         "\u2934\ufe0f",
+        "arrow_heading_down":                       "\u2935\ufe0f",
+        "arrows_clockwise":                         "\U0001f503",
+        "arrows_counterclockwise":                  "\U0001f504",
+        "back":                                     "\U0001f519",
+        "end":                                      "\U0001f51a",
+        "on":                                       "\U0001f51b",
+        "soon":                                     "\U0001f51c",
+        "top":                                      "\U0001f51d",
+        "place_of_worship":                         "\U0001f6d0",
+        "atom_symbol":                              "\u269b\ufe0f",
+        "om":                                       "\U0001f549\ufe0f",
+        "star_of_david":                            "\u2721\ufe0f",
+        "wheel_of_dharma":                          "\u2638\ufe0f",
+        "yin_yang":                                 "\u262f\ufe0f",
+        "latin_cross":                              "\u271d\ufe0f",
+        "orthodox_cross":                           "\u2626\ufe0f",
+        "star_and_crescent":                        "\u262a\ufe0f",
+        "peace_symbol":                             "\u262e\ufe0f",
+        "menorah":                                  "\U0001f54e",
+        "six_pointed_star":                         "\U0001f52f",
+        "khanda":                                   "\U0001faaf",
+        "aries":                                    "\u2648",
+        "taurus":                                   "\u2649",
+        "gemini":                                   "\u264a",
+        "cancer":                                   "\u264b",
+        "leo":                                      "\u264c",
+        "virgo":                                    "\u264d",
+        "libra":                                    "\u264e",
+        "scorpius":                                 "\u264f",
+        "sagittarius":                              "\u2650",
+        "capricorn":                                "\u2651",
+        "aquarius":                                 "\u2652",
+        "pisces":                                   "\u2653",
+        "ophiuchus":                                "\u26ce",
+        "twisted_rightwards_arrows":                "\U0001f500",
+        "repeat":                                   "\U0001f501",
+        "repeat_one":                               "\U0001f502",
+        "arrow_forward":                            "\u25b6\ufe0f",
+        "fast_forward":                             "\u23e9",
+        "next_track_button":                        "\u23ed\ufe0f",
+        "play_or_pause_button":                     "\u23ef\ufe0f",
+        "arrow_backward":                           "\u25c0\ufe0f",
+        "rewind":                                   "\u23ea",
+        "previous_track_button":                    "\u23ee\ufe0f",
+        "arrow_up_small":                           "\U0001f53c",
+        "arrow_double_up":                          "\u23eb",
+        "arrow_down_small":                         "\U0001f53d",
+        "arrow_double_down":                        "\u23ec",
+        "pause_button":                             "\u23f8\ufe0f",
+        "stop_button":                              "\u23f9\ufe0f",
+        "record_button":                            "\u23fa\ufe0f",
+        "eject_button":                             "\u23cf\ufe0f",
+        "cinema":                                   "\U0001f3a6",
+        "low_brightness":                           "\U0001f505",
+        "high_brightness":                          "\U0001f506",
+        "signal_strength":                          "\U0001f4f6",
+        "wireless":                                 "\U0001f6dc",
+        "vibration_mode":                           "\U0001f4f3",
+        "mobile_phone_off":                         "\U0001f4f4",
+        "female_sign":                              "\u2640\ufe0f",
+        "male_sign":                                "\u2642\ufe0f",
+        "transgender_symbol":                       "\u26a7\ufe0f",
+        "heavy_multiplication_x":                   "\u2716\ufe0f",
+        "heavy_plus_sign":                          "\u2795",
+        "heavy_minus_sign":                         "\u2796",
+        "heavy_division_sign":                      "\u2797",
+        "heavy_equals_sign":                        "\U0001f7f0",
+        "infinity":                                 "\u267e\ufe0f",
+        "bangbang":                                 "\u203c\ufe0f",
+        "interrobang":                              "\u2049\ufe0f",
+        "question":                                 "\u2753",
+        "grey_question":                            "\u2754",
+        "grey_exclamation":                         "\u2755",
+        "exclamation":                              "\u2757",
+        "heavy_exclamation_mark":                   "\u2757",
+        "wavy_dash":                                "\u3030\ufe0f",
+        "currency_exchange":                        "\U0001f4b1",
+        "heavy_dollar_sign":                        "\U0001f4b2",
+        "medical_symbol":                           "\u2695\ufe0f",
+        "recycle":                                  "\u267b\ufe0f",
+        "fleur_de_lis":                             "\u269c\ufe0f",
+        "trident":                                  "\U0001f531",
+        "name_badge":                               "\U0001f4db",
+        "beginner":                                 "\U0001f530",
+        "o":                                        "\u2b55",
+        "white_check_mark":                         "\u2705",
+        "ballot_box_with_check":                    "\u2611\ufe0f",
+        "heavy_check_mark":                         "\u2714\ufe0f",
+        "x":                                        "\u274c",
+        "negative_squared_cross_mark":              "\u274e",
+        "curly_loop":                               "\u27b0",
+        "loop":                                     "\u27bf",
+        "part_alternation_mark":                    "\u303d\ufe0f",
+        "eight_spoked_asterisk":                    "\u2733\ufe0f",
+        "eight_pointed_black_star":                 "\u2734\ufe0f",
+        "sparkle":                                  "\u2747\ufe0f",
+        "copyright":                                "\u00a9\ufe0f",
+        "registered":                               "\u00ae\ufe0f",
+        "tm":                                       "\u2122\ufe0f",
+        "hash":                                     "#\ufe0f\u20e3",
+        "asterisk":                                 "*\ufe0f\u20e3",
+        "zero":                                     "0\ufe0f\u20e3",
+        "one":                                      "1\ufe0f\u20e3",
+        "two":                                      "2\ufe0f\u20e3",
+        "three":                                    "3\ufe0f\u20e3",
+        "four":                                     "4\ufe0f\u20e3",
+        "five":                                     "5\ufe0f\u20e3",
+        "six":                                      "6\ufe0f\u20e3",
+        "seven":                                    "7\ufe0f\u20e3",
+        "eight":                                    "8\ufe0f\u20e3",
+        "nine":                                     "9\ufe0f\u20e3",
+        "keycap_ten":                               "\U0001f51f",
+        "capital_abcd":                             "\U0001f520",
+        "abcd":                                     "\U0001f521",
+        "1234":                                     "\U0001f522",
+        "symbols":                                  "\U0001f523",
+        "abc":                                      "\U0001f524",
+        "a":                                        "\U0001f170\ufe0f",
+        "ab":                                       "\U0001f18e",
+        "b":                                        "\U0001f171\ufe0f",
+        "cl":                                       "\U0001f191",
+        "cool":                                     "\U0001f192",
+        "free":                                     "\U0001f193",
+        "information_source":                       "\u2139\ufe0f",
+        "id":                                       "\U0001f194",
+        "m":                                        "\u24c2\ufe0f",
+        "new":                                      "\U0001f195",
+        "ng":                                       "\U0001f196",
+        "o2":                                       "\U0001f17e\ufe0f",
+        "ok":                                       "\U0001f197",
+        "parking":                                  "\U0001f17f\ufe0f",
+        "sos":                                      "\U0001f198",
+        "up":                                       "\U0001f199",
+        "vs":                                       "\U0001f19a",
+        "koko":                                     "\U0001f201",
+        "sa":                                       "\U0001f202\ufe0f",
+        "u6708":                                    "\U0001f237\ufe0f",
+        "u6709":                                    "\U0001f236",
+        "u6307":                                    "\U0001f22f",
+        "ideograph_advantage":                      "\U0001f250",
+        "u5272":                                    "\U0001f239",
+        "u7121":                                    "\U0001f21a",
+        "u7981":                                    "\U0001f232",
+        "accept":                                   "\U0001f251",
+        "u7533":                                    "\U0001f238",
+        "u5408":                                    "\U0001f234",
+        "u7a7a":                                    "\U0001f233",
+        "congratulations":                          "\u3297\ufe0f",
+        "secret":                                   "\u3299\ufe0f",
+        "u55b6":                                    "\U0001f23a",
+        "u6e80":                                    "\U0001f235",
+        "red_circle":                               "\U0001f534",
+        "orange_circle":                            "\U0001f7e0",
+        "yellow_circle":                            "\U0001f7e1",
+        "green_circle":                             "\U0001f7e2",
+        "large_blue_circle":                        "\U0001f535",
+        "purple_circle":                            "\U0001f7e3",
+        "brown_circle":                             "\U0001f7e4",
+        "black_circle":                             "\u26ab",
+        "white_circle":                             "\u26aa",
+        "red_square":                               "\U0001f7e5",
+        "orange_square":                            "\U0001f7e7",
+        "yellow_square":                            "\U0001f7e8",
+        "green_square":                             "\U0001f7e9",
+        "blue_square":                              "\U0001f7e6",
+        "purple_square":                            "\U0001f7ea",
+        "brown_square":                             "\U0001f7eb",
+        "black_large_square":                       "\u2b1b",
+        "white_large_square":                       "\u2b1c",
+        "black_medium_square":                      "\u25fc\ufe0f",
+        "white_medium_square":                      "\u25fb\ufe0f",
+        "black_medium_small_square":                "\u25fe",
+        "white_medium_small_square":                "\u25fd",
+        "black_small_square":                       "\u25aa\ufe0f",
+        "white_small_square":                       "\u25ab\ufe0f",
+        "large_orange_diamond":                     "\U0001f536",
+        "large_blue_diamond":                       "\U0001f537",
+        "small_orange_diamond":                     "\U0001f538",
+        "small_blue_diamond":                       "\U0001f539",
+        "small_red_triangle":                       "\U0001f53a",
+        "small_red_triangle_down":                  "\U0001f53b",
+        "diamond_shape_with_a_dot_inside":          "\U0001f4a0",
+        "radio_button":                             "\U0001f518",
+        "white_square_button":                      "\U0001f533",
+        "black_square_button":                      "\U0001f532",
+        "checkered_flag":                           "\U0001f3c1",
+        "triangular_flag_on_post":                  "\U0001f6a9",
+        "crossed_flags":                            "\U0001f38c",
+        "black_flag":                               "\U0001f3f4",
+        "white_flag":                               "\U0001f3f3\ufe0f",
+        "rainbow_flag":                             "\U0001f3f3\ufe0f\u200d\U0001f308",
+        "transgender_flag":                         "\U0001f3f3\ufe0f\u200d\u26a7\ufe0f",
+        "pirate_flag":                              "\U0001f3f4\u200d\u2620\ufe0f",
+        "ascension_island":                         "\U0001f1e6\U0001f1e8",
+        "andorra":                                  "\U0001f1e6\U0001f1e9",
+        "united_arab_emirates":                     "\U0001f1e6\U0001f1ea",
+        "afghanistan":                              "\U0001f1e6\U0001f1eb",
+        "antigua_barbuda":                          "\U0001f1e6\U0001f1ec",
+        "anguilla":                                 "\U0001f1e6\U0001f1ee",
+        "albania":                                  "\U0001f1e6\U0001f1f1",
+        "armenia":                                  "\U0001f1e6\U0001f1f2",
+        "angola":                                   "\U0001f1e6\U0001f1f4",
+        "antarctica":                               "\U0001f1e6\U0001f1f6",
+        "argentina":                                "\U0001f1e6\U0001f1f7",
+        "american_samoa":                           "\U0001f1e6\U0001f1f8",
+        "austria":                                  "\U0001f1e6\U0001f1f9",
+        "australia":                                "\U0001f1e6\U0001f1fa",
+        "aruba":                                    "\U0001f1e6\U0001f1fc",
+        "aland_islands":                            "\U0001f1e6\U0001f1fd",
+        "azerbaijan":                               "\U0001f1e6\U0001f1ff",
+        "bosnia_herzegovina":                       "\U0001f1e7\U0001f1e6",
+        "barbados":                                 "\U0001f1e7\U0001f1e7",
+        "bangladesh":                               "\U0001f1e7\U0001f1e9",
+        "belgium":                                  "\U0001f1e7\U0001f1ea",
+        "burkina_faso":                             "\U0001f1e7\U0001f1eb",
+        "bulgaria":                                 "\U0001f1e7\U0001f1ec",
+        "bahrain":                                  "\U0001f1e7\U0001f1ed",
+        "burundi":                                  "\U0001f1e7\U0001f1ee",
+        "benin":                                    "\U0001f1e7\U0001f1ef",
+        "st_barthelemy":                            "\U0001f1e7\U0001f1f1",
+        "bermuda":                                  "\U0001f1e7\U0001f1f2",
+        "brunei":                                   "\U0001f1e7\U0001f1f3",
+        "bolivia":                                  "\U0001f1e7\U0001f1f4",
+        "caribbean_netherlands":                    "\U0001f1e7\U0001f1f6",
+        "brazil":                                   "\U0001f1e7\U0001f1f7",
+        "bahamas":                                  "\U0001f1e7\U0001f1f8",
+        "bhutan":                                   "\U0001f1e7\U0001f1f9",
+        "bouvet_island":                            "\U0001f1e7\U0001f1fb",
+        "botswana":                                 "\U0001f1e7\U0001f1fc",
+        "belarus":                                  "\U0001f1e7\U0001f1fe",
+        "belize":                                   "\U0001f1e7\U0001f1ff",
+        "canada":                                   "\U0001f1e8\U0001f1e6",
+        "cocos_islands":                            "\U0001f1e8\U0001f1e8",
+        "congo_kinshasa":                           "\U0001f1e8\U0001f1e9",
+        "central_african_republic":                 "\U0001f1e8\U0001f1eb",
+        "congo_brazzaville":                        "\U0001f1e8\U0001f1ec",
+        "switzerland":                              "\U0001f1e8\U0001f1ed",
+        "cote_divoire":                             "\U0001f1e8\U0001f1ee",
+        "cook_islands":                             "\U0001f1e8\U0001f1f0",
+        "chile":                                    "\U0001f1e8\U0001f1f1",
+        "cameroon":                                 "\U0001f1e8\U0001f1f2",
+        "cn":                                       "\U0001f1e8\U0001f1f3",
+        "colombia":                                 "\U0001f1e8\U0001f1f4",
+        "clipperton_island":                        "\U0001f1e8\U0001f1f5",
+        "costa_rica":                               "\U0001f1e8\U0001f1f7",
+        "cuba":                                     "\U0001f1e8\U0001f1fa",
+        "cape_verde":                               "\U0001f1e8\U0001f1fb",
+        "curacao":                                  "\U0001f1e8\U0001f1fc",
+        "christmas_island":                         "\U0001f1e8\U0001f1fd",
+        "cyprus":                                   "\U0001f1e8\U0001f1fe",
+        "czech_republic":                           "\U0001f1e8\U0001f1ff",
+        "de":                                       "\U0001f1e9\U0001f1ea",
+        "diego_garcia":                             "\U0001f1e9\U0001f1ec",
+        "djibouti":                                 "\U0001f1e9\U0001f1ef",
+        "denmark":                                  "\U0001f1e9\U0001f1f0",
+        "dominica":                                 "\U0001f1e9\U0001f1f2",
+        "dominican_republic":                       "\U0001f1e9\U0001f1f4",
+        "algeria":                                  "\U0001f1e9\U0001f1ff",
+        "ceuta_melilla":                            "\U0001f1ea\U0001f1e6",
+        "ecuador":                                  "\U0001f1ea\U0001f1e8",
+        "estonia":                                  "\U0001f1ea\U0001f1ea",
+        "egypt":                                    "\U0001f1ea\U0001f1ec",
+        "western_sahara":                           "\U0001f1ea\U0001f1ed",
+        "eritrea":                                  "\U0001f1ea\U0001f1f7",
+        "es":                                       "\U0001f1ea\U0001f1f8",
+        "ethiopia":                                 "\U0001f1ea\U0001f1f9",
+        "eu":                                       "\U0001f1ea\U0001f1fa",
+        "european_union":                           "\U0001f1ea\U0001f1fa",
+        "finland":                                  "\U0001f1eb\U0001f1ee",
+        "fiji":                                     "\U0001f1eb\U0001f1ef",
+        "falkland_islands":                         "\U0001f1eb\U0001f1f0",
+        "micronesia":                               "\U0001f1eb\U0001f1f2",
+        "faroe_islands":                            "\U0001f1eb\U0001f1f4",
+        "fr":                                       "\U0001f1eb\U0001f1f7",
+        "gabon":                                    "\U0001f1ec\U0001f1e6",
+        "gb":                                       "\U0001f1ec\U0001f1e7",
+        "uk":                                       "\U0001f1ec\U0001f1e7",
+        "grenada":                                  "\U0001f1ec\U0001f1e9",
+        "georgia":                                  "\U0001f1ec\U0001f1ea",
+        "french_guiana":                            "\U0001f1ec\U0001f1eb",
+        "guernsey":                                 "\U0001f1ec\U0001f1ec",
+        "ghana":                                    "\U0001f1ec\U0001f1ed",
+        "gibraltar":                                "\U0001f1ec\U0001f1ee",
+        "greenland":                                "\U0001f1ec\U0001f1f1",
+        "gambia":                                   "\U0001f1ec\U0001f1f2",
+        "guinea":                                   "\U0001f1ec\U0001f1f3",
+        "guadeloupe":                               "\U0001f1ec\U0001f1f5",
+        "equatorial_guinea":                        "\U0001f1ec\U0001f1f6",
+        "greece":                                   "\U0001f1ec\U0001f1f7",
+        "south_georgia_south_sandwich_islands":     "\U0001f1ec\U0001f1f8",
+        "guatemala":                                "\U0001f1ec\U0001f1f9",
+        "guam":                                     "\U0001f1ec\U0001f1fa",
+        "guinea_bissau":                            "\U0001f1ec\U0001f1fc",
+        "guyana":                                   "\U0001f1ec\U0001f1fe",
+        "hong_kong":                                "\U0001f1ed\U0001f1f0",
+        "heard_mcdonald_islands":                   "\U0001f1ed\U0001f1f2",
+        "honduras":                                 "\U0001f1ed\U0001f1f3",
+        "croatia":                                  "\U0001f1ed\U0001f1f7",
+        "haiti":                                    "\U0001f1ed\U0001f1f9",
+        "hungary":                                  "\U0001f1ed\U0001f1fa",
+        "canary_islands":                           "\U0001f1ee\U0001f1e8",
+        "indonesia":                                "\U0001f1ee\U0001f1e9",
+        "ireland":                                  "\U0001f1ee\U0001f1ea",
+        "israel":                                   "\U0001f1ee\U0001f1f1",
+        "isle_of_man":                              "\U0001f1ee\U0001f1f2",
+        "india":                                    "\U0001f1ee\U0001f1f3",
+        "british_indian_ocean_territory":           "\U0001f1ee\U0001f1f4",
+        "iraq":                                     "\U0001f1ee\U0001f1f6",
+        "iran":                                     "\U0001f1ee\U0001f1f7",
+        "iceland":                                  "\U0001f1ee\U0001f1f8",
+        "it":                                       "\U0001f1ee\U0001f1f9",
+        "jersey":                                   "\U0001f1ef\U0001f1ea",
+        "jamaica":                                  "\U0001f1ef\U0001f1f2",
+        "jordan":                                   "\U0001f1ef\U0001f1f4",
+        "jp":                                       "\U0001f1ef\U0001f1f5",
+        "kenya":                                    "\U0001f1f0\U0001f1ea",
+        "kyrgyzstan":                               "\U0001f1f0\U0001f1ec",
+        "cambodia":                                 "\U0001f1f0\U0001f1ed",
+        "kiribati":                                 "\U0001f1f0\U0001f1ee",
+        "comoros":                                  "\U0001f1f0\U0001f1f2",
+        "st_kitts_nevis":                           "\U0001f1f0\U0001f1f3",
+        "north_korea":                              "\U0001f1f0\U0001f1f5",
+        "kr":                                       "\U0001f1f0\U0001f1f7",
+        "kuwait":                                   "\U0001f1f0\U0001f1fc",
+        "cayman_islands":                           "\U0001f1f0\U0001f1fe",
+        "kazakhstan":                               "\U0001f1f0\U0001f1ff",
+        "laos":                                     "\U0001f1f1\U0001f1e6",
+        "lebanon":                                  "\U0001f1f1\U0001f1e7",
+        "st_lucia":                                 "\U0001f1f1\U0001f1e8",
+        "liechtenstein":                            "\U0001f1f1\U0001f1ee",
+        "sri_lanka":                                "\U0001f1f1\U0001f1f0",
+        "liberia":                                  "\U0001f1f1\U0001f1f7",
+        "lesotho":                                  "\U0001f1f1\U0001f1f8",
+        "lithuania":                                "\U0001f1f1\U0001f1f9",
+        "luxembourg":                               "\U0001f1f1\U0001f1fa",
+        "latvia":                                   "\U0001f1f1\U0001f1fb",
+        "libya":                                    "\U0001f1f1\U0001f1fe",
+        "morocco":                                  "\U0001f1f2\U0001f1e6",
+        "monaco":                                   "\U0001f1f2\U0001f1e8",
+        "moldova":                                  "\U0001f1f2\U0001f1e9",
+        "montenegro":                               "\U0001f1f2\U0001f1ea",
+        "st_martin":                                "\U0001f1f2\U0001f1eb",
+        "madagascar":                               "\U0001f1f2\U0001f1ec",
+        "marshall_islands":                         "\U0001f1f2\U0001f1ed",
+        "macedonia":                                "\U0001f1f2\U0001f1f0",
+        "mali":                                     "\U0001f1f2\U0001f1f1",
+        "myanmar":                                  "\U0001f1f2\U0001f1f2",
+        "mongolia":                                 "\U0001f1f2\U0001f1f3",
+        "macau":                                    "\U0001f1f2\U0001f1f4",
+        "northern_mariana_islands":                 "\U0001f1f2\U0001f1f5",
+        "martinique":                               "\U0001f1f2\U0001f1f6",
+        "mauritania":                               "\U0001f1f2\U0001f1f7",
+        "montserrat":                               "\U0001f1f2\U0001f1f8",
+        "malta":                                    "\U0001f1f2\U0001f1f9",
+        "mauritius":                                "\U0001f1f2\U0001f1fa",
+        "maldives":                                 "\U0001f1f2\U0001f1fb",
+        "malawi":                                   "\U0001f1f2\U0001f1fc",
+        "mexico":                                   "\U0001f1f2\U0001f1fd",
+        "malaysia":                                 "\U0001f1f2\U0001f1fe",
+        "mozambique":                               "\U0001f1f2\U0001f1ff",
+        "namibia":                                  "\U0001f1f3\U0001f1e6",
+        "new_caledonia":                            "\U0001f1f3\U0001f1e8",
+        "niger":                                    "\U0001f1f3\U0001f1ea",
+        "norfolk_island":                           "\U0001f1f3\U0001f1eb",
+        "nigeria":                                  "\U0001f1f3\U0001f1ec",
+        "nicaragua":                                "\U0001f1f3\U0001f1ee",
+        "netherlands":                              "\U0001f1f3\U0001f1f1",
+        "norway":                                   "\U0001f1f3\U0001f1f4",
+        "nepal":                                    "\U0001f1f3\U0001f1f5",
+        "nauru":                                    "\U0001f1f3\U0001f1f7",
+        "niue":                                     "\U0001f1f3\U0001f1fa",
+        "new_zealand":                              "\U0001f1f3\U0001f1ff",
+        "oman":                                     "\U0001f1f4\U0001f1f2",
+        "panama":                                   "\U0001f1f5\U0001f1e6",
+        "peru":                                     "\U0001f1f5\U0001f1ea",
+        "french_polynesia":                         "\U0001f1f5\U0001f1eb",
+        "papua_new_guinea":                         "\U0001f1f5\U0001f1ec",
+        "philippines":                              "\U0001f1f5\U0001f1ed",
+        "pakistan":                                 "\U0001f1f5\U0001f1f0",
+        "poland":                                   "\U0001f1f5\U0001f1f1",
+        "st_pierre_miquelon":                       "\U0001f1f5\U0001f1f2",
+        "pitcairn_islands":                         "\U0001f1f5\U0001f1f3",
+        "puerto_rico":                              "\U0001f1f5\U0001f1f7",
+        "palestinian_territories":                  "\U0001f1f5\U0001f1f8",
+        "portugal":                                 "\U0001f1f5\U0001f1f9",
+        "palau":                                    "\U0001f1f5\U0001f1fc",
+        "paraguay":                                 "\U0001f1f5\U0001f1fe",
+        "qatar":                                    "\U0001f1f6\U0001f1e6",
+        "reunion":                                  "\U0001f1f7\U0001f1ea",
+        "romania":                                  "\U0001f1f7\U0001f1f4",
+        "serbia":                                   "\U0001f1f7\U0001f1f8",
+        "ru":                                       "\U0001f1f7\U0001f1fa",
+        "rwanda":                                   "\U0001f1f7\U0001f1fc",
+        "saudi_arabia":                             "\U0001f1f8\U0001f1e6",
+        "solomon_islands":                          "\U0001f1f8\U0001f1e7",
+        "seychelles":                               "\U0001f1f8\U0001f1e8",
+        "sudan":                                    "\U0001f1f8\U0001f1e9",
+        "sweden":                                   "\U0001f1f8\U0001f1ea",
+        "singapore":                                "\U0001f1f8\U0001f1ec",
+        "st_helena":                                "\U0001f1f8\U0001f1ed",
+        "slovenia":                                 "\U0001f1f8\U0001f1ee",
+        "svalbard_jan_mayen":                       "\U0001f1f8\U0001f1ef",
+        "slovakia":                                 "\U0001f1f8\U0001f1f0",
+        "sierra_leone":                             "\U0001f1f8\U0001f1f1",
+        "san_marino":                               "\U0001f1f8\U0001f1f2",
+        "senegal":                                  "\U0001f1f8\U0001f1f3",
+        "somalia":                                  "\U0001f1f8\U0001f1f4",
+        "suriname":                                 "\U0001f1f8\U0001f1f7",
+        "south_sudan":                              "\U0001f1f8\U0001f1f8",
+        "sao_tome_principe":                        "\U0001f1f8\U0001f1f9",
+        "el_salvador":                              "\U0001f1f8\U0001f1fb",
+        "sint_maarten":                             "\U0001f1f8\U0001f1fd",
+        "syria":                                    "\U0001f1f8\U0001f1fe",
+        "swaziland":                                "\U0001f1f8\U0001f1ff",
+        "tristan_da_cunha":                         "\U0001f1f9\U0001f1e6",
+        "turks_caicos_islands":                     "\U0001f1f9\U0001f1e8",
+        "chad":                                     "\U0001f1f9\U0001f1e9",
+        "french_southern_territories":              "\U0001f1f9\U0001f1eb",
+        "togo":                                     "\U0001f1f9\U0001f1ec",
+        "thailand":                                 "\U0001f1f9\U0001f1ed",
+        "tajikistan":                               "\U0001f1f9\U0001f1ef",
+        "tokelau":                                  "\U0001f1f9\U0001f1f0",
+        "timor_leste":                              "\U0001f1f9\U0001f1f1",
+        "turkmenistan":                             "\U0001f1f9\U0001f1f2",
+        "tunisia":                                  "\U0001f1f9\U0001f1f3",
+        "tonga":                                    "\U0001f1f9\U0001f1f4",
+        "tr":                                       "\U0001f1f9\U0001f1f7",
+        "trinidad_tobago":                          "\U0001f1f9\U0001f1f9",
+        "tuvalu":                                   "\U0001f1f9\U0001f1fb",
+        "taiwan":                                   "\U0001f1f9\U0001f1fc",
+        "tanzania":                                 "\U0001f1f9\U0001f1ff",
+        "ukraine":                                  "\U0001f1fa\U0001f1e6",
+        "uganda":                                   "\U0001f1fa\U0001f1ec",
+        "us_outlying_islands":                      "\U0001f1fa\U0001f1f2",
+        "united_nations":                           "\U0001f1fa\U0001f1f3",
+        "us":                                       "\U0001f1fa\U0001f1f8",
+        "uruguay":                                  "\U0001f1fa\U0001f1fe",
+        "uzbekistan":                               "\U0001f1fa\U0001f1ff",
+        "vatican_city":                             "\U0001f1fb\U0001f1e6",
+        "st_vincent_grenadines":                    "\U0001f1fb\U0001f1e8",
+        "venezuela":                                "\U0001f1fb\U0001f1ea",
+        "british_virgin_islands":                   "\U0001f1fb\U0001f1ec",
+        "us_virgin_islands":                        "\U0001f1fb\U0001f1ee",
+        "vietnam":                                  "\U0001f1fb\U0001f1f3",
+        "vanuatu":                                  "\U0001f1fb\U0001f1fa",
+        "wallis_futuna":                            "\U0001f1fc\U0001f1eb",
+        "samoa":                                    "\U0001f1fc\U0001f1f8",
+        "kosovo":                                   "\U0001f1fd\U0001f1f0",
+        "yemen":                                    "\U0001f1fe\U0001f1ea",
+        "mayotte":                                  "\U0001f1fe\U0001f1f9",
+        "south_africa":                             "\U0001f1ff\U0001f1e6",
+        "zambia":                                   "\U0001f1ff\U0001f1f2",
+        "zimbabwe":                                 "\U0001f1ff\U0001f1fc",
+        "england":                                  "\U0001f3f4\U000e0067\U000e0062\U000e0065\U000e006e\U000e0067\U000e007f",
+        "scotland":                                 "\U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f",
+        "wales":                                    "\U0001f3f4\U000e0067\U000e0062\U000e0077\U000e006c\U000e0073\U000e007f",
+}
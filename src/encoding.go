@@ -0,0 +1,435 @@
+package main
+
+import (
+        "bufio"
+        "bytes"
+        "encoding/binary"
+        "io"
+        "strings"
+        "unicode/utf16"
+        "unicode/utf8"
+)
+
+// Only this much of a file is used to guess its format
+const detectSampleSize = 64 * 1024
+
+// inputEncodings are the values of -input-encoding other than auto, by
+// their lowercase name and common aliases
+var inputEncodings = map[string]string{
+        "utf-8":        "UTF-8",
+        "utf8":         "UTF-8",
+        "utf-16le":     "UTF-16LE",
+        "utf-16be":     "UTF-16BE",
+        "utf-32le":     "UTF-32LE",
+        "utf-32be":     "UTF-32BE",
+        "windows-1252": "Windows-1252",
+        "cp1252":       "Windows-1252",
+        "iso-8859-1":   "ISO-8859-1",
+        "latin1":       "ISO-8859-1",
+        "iso-8859-15":  "ISO-8859-15",
+        "latin9":       "ISO-8859-15",
+        "windows-1251": "Windows-1251",
+        "cp1251":       "Windows-1251",
+        "koi8-r":       "KOI8-R",
+        "shift_jis":    "Shift_JIS",
+        "shift-jis":    "Shift_JIS",
+        "sjis":         "Shift_JIS",
+        "cp932":        "Shift_JIS",
+}
+
+// detectEncoding names the encoding announced by a byte order mark, or
+// guesses UTF-16 or UTF-32 from NUL bytes when there is none, and a legacy
+// encoding from text that is not valid UTF-8
+func detectEncoding(sample []byte) (string, bool) {
+        switch {
+        case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+                return "UTF-8", true
+        case bytes.HasPrefix(sample, []byte{0x00, 0x00, 0xFE, 0xFF}):
+                return "UTF-32BE", true
+        case bytes.HasPrefix(sample, []byte{0xFF, 0xFE, 0x00, 0x00}):
+                return "UTF-32LE", true
+        case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+                return "UTF-16BE", true
+        case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+                return "UTF-16LE", true
+        }
+
+        if encoding := guessUTF16or32(sample); encoding != "" {
+                return encoding, false
+        }
+        if nul := bytes.Count(sample, []byte{0}); nul > 0 && nul >= len(sample)/4 {
+                return "binary or UTF-16/32 without BOM", false
+        }
+        if encoding := guessLegacyEncoding(sample); encoding != "" {
+                return encoding, false
+        }
+        return "UTF-8", false
+}
+
+// inputEncodingOf returns the encoding in which input starting with sample
+// is read: the one named with -input-encoding, or else the one detected,
+// except that a legacy encoding is not guessed if -invalid-utf8 says what
+// to do with bytes that are not valid UTF-8
+func inputEncodingOf(sample []byte, options CleaningOptions) string {
+        if options.InputEncoding != "" {
+                return options.InputEncoding
+        }
+        encoding, _ := detectEncoding(sample)
+        if options.NoLegacyGuess && isLegacyEncoding(encoding) {
+                return "UTF-8"
+        }
+        return encoding
+}
+
+// isUTF16or32 reports whether an encoding is UTF-16 or UTF-32
+func isUTF16or32(encoding string) bool {
+        return strings.HasPrefix(encoding, "UTF-16") || strings.HasPrefix(encoding, "UTF-32")
+}
+
+// isLegacyEncoding reports whether an encoding is one of the single-byte
+// encodings or Shift_JIS
+func isLegacyEncoding(encoding string) bool {
+        return singleByteTables[encoding] != nil || encoding == "Shift_JIS"
+}
+
+// decodable reports whether input in an encoding is decoded to UTF-8
+// before cleaning
+func decodable(encoding string) bool {
+        return isUTF16or32(encoding) || isLegacyEncoding(encoding)
+}
+
+// guessUTF16or32 recognizes UTF-16 and UTF-32 without a byte order mark by
+// where the NUL bytes fall: text that is mostly ASCII or Latin has a NUL in
+// the high byte of nearly every UTF-16 code unit and few anywhere else, and
+// UTF-32 always has one in the highest byte and, outside emoji and rare
+// scripts, the next. Windows tools that write "Unicode" files, SQL
+// Server exports and PowerShell redirections produce such files. It
+// returns "" if the sample shows no such pattern.
+func guessUTF16or32(sample []byte) string {
+        n := len(sample) &^ 3
+        if n < 16 {
+                return ""
+        }
+        var zeros [4]int
+        for i, b := range sample[:n] {
+                if b == 0 {
+                        zeros[i%4]++
+                }
+        }
+        units := n / 4
+        even, odd := zeros[0]+zeros[2], zeros[1]+zeros[3]
+        switch {
+        case zeros[3] == units && zeros[2] >= units*3/4 && zeros[0] < units/4:
+                return "UTF-32LE"
+        case zeros[0] == units && zeros[1] >= units*3/4 && zeros[3] < units/4:
+                return "UTF-32BE"
+        case odd >= units*2/3 && even*10 < odd:
+                return "UTF-16LE"
+        case even >= units*2/3 && odd*10 < even:
+                return "UTF-16BE"
+        }
+        return ""
+}
+
+// legacyMinHighBytes is the fewest bytes above 0x7F in which a sample that
+// is not valid UTF-8 is taken for a legacy encoding
+const legacyMinHighBytes = 8
+
+// guessLegacyEncoding recognizes text in a legacy encoding, which is not
+// valid UTF-8, in the way of chardet: by which bytes above 0x7F it uses and
+// how they cluster. Only a sample with at least legacyMinHighBytes such
+// bytes and not a single valid multi-byte UTF-8 sequence is considered;
+// anything else is taken as UTF-8 with a few bad bytes, for -invalid-utf8
+// to handle. Shift_JIS, whose pairs can happen to be valid UTF-8, only
+// needs more invalid bytes than valid sequences, but has to parse as
+// such throughout and contain kana; Cyrillic text has its high bytes in
+// runs (whole words), in KOI8-R mostly in the range of its lowercase
+// letters 0xC0-0xDF and in Windows-1251 in 0xE0-0xFF; anything else is
+// taken as Windows-1252, or as ISO-8859-1 without any of the bytes
+// 0x80-0x9F where the two differ. It returns "" for UTF-8.
+func guessLegacyEncoding(sample []byte) string {
+        invalid, multibyte := 0, 0
+        for i := 0; i < len(sample); {
+                r, size := utf8.DecodeRune(sample[i:])
+                switch {
+                case r == utf8.RuneError && size == 1 && !utf8.FullRune(sample[i:]):
+                        // A character cut off at the end of the sample
+                case r == utf8.RuneError && size == 1:
+                        invalid++
+                case size > 1:
+                        multibyte++
+                }
+                i += size
+        }
+        if invalid < legacyMinHighBytes {
+                return ""
+        }
+        // Shift_JIS pairs can form valid UTF-8 by chance ("です" is 82 C5
+        // 82 B7, with C5 82 in it), so it is tried while they are outnumbered
+        if multibyte < invalid && isShiftJIS(sample) {
+                return "Shift_JIS"
+        }
+        // With no valid multi-byte sequence, every byte above 0x7F is invalid
+        if multibyte > 0 {
+                return ""
+        }
+
+        high, runs, lower, upper, c1 := 0, 0, 0, 0, false
+        for i, b := range sample {
+                if b < 0x80 {
+                        continue
+                }
+                high++
+                if i == 0 || sample[i-1] < 0x80 {
+                        runs++
+                }
+                switch {
+                case b >= 0xC0 && b <= 0xDF:
+                        lower++
+                case b >= 0xE0:
+                        upper++
+                case b <= 0x9F:
+                        c1 = true
+                }
+        }
+        switch {
+        case high >= runs*3 && lower >= high*3/5:
+                return "KOI8-R"
+        case high >= runs*3 && upper >= high*3/5:
+                return "Windows-1251"
+        case c1:
+                return "Windows-1252"
+        }
+        return "ISO-8859-1"
+}
+
+// isShiftJIS reports whether a sample parses as Shift_JIS with at least one
+// double-byte character and with kana (lead bytes 0x82 and 0x83) making up
+// a quarter of them, as they do in Japanese prose. Western text can parse
+// by chance when every accented letter is followed by a letter, but then
+// decodes to rare kanji.
+func isShiftJIS(sample []byte) bool {
+        pairs, kana := 0, 0
+        for i := 0; i < len(sample); i++ {
+                b := sample[i]
+                switch {
+                case b < 0x80 || b >= 0xA1 && b <= 0xDF:
+                        // ASCII and halfwidth katakana
+                case i+1 == len(sample):
+                        // A lead byte cut off at the end of the sample
+                case shiftJISTable[uint16(b)<<8|uint16(sample[i+1])] != 0:
+                        pairs++
+                        if b == 0x82 || b == 0x83 {
+                                kana++
+                        }
+                        i++
+                default:
+                        return false
+                }
+        }
+        return pairs > 0 && kana*4 >= pairs
+}
+
+// inputDecoder converts input in another encoding to UTF-8 as it is read,
+// so that such a file is cleaned like any other and written out as UTF-8.
+// A UTF-16 or UTF-32 byte order mark is decoded to U+FEFF, which -bom
+// removes or -ensure-bom keeps. Bytes that stand for no character, such as
+// unpaired surrogates, undefined bytes of a legacy encoding and an
+// incomplete character at the end of the input, are decoded as U+FFFD and
+// counted in Invalid.
+type inputDecoder struct {
+        r       io.Reader
+        buf     []byte
+        in      []byte // bytes not yet decoded
+        out     []byte // decoded bytes not yet returned
+        eof     bool
+        Invalid int
+
+        // UTF-16 and UTF-32
+        unit    int // bytes per code unit, 2 or 4
+        order   binary.ByteOrder
+        pending rune // a high surrogate waiting for its low half
+
+        table *[128]rune // a single-byte encoding; nil for Shift_JIS
+}
+
+func newInputDecoder(r io.Reader, encoding string) *inputDecoder {
+        d := &inputDecoder{r: r, buf: make([]byte, 32*1024)}
+        switch {
+        case strings.HasPrefix(encoding, "UTF-16"):
+                d.unit = 2
+        case strings.HasPrefix(encoding, "UTF-32"):
+                d.unit = 4
+        default:
+                d.table = singleByteTables[encoding]
+        }
+        d.order = binary.LittleEndian
+        if strings.HasSuffix(encoding, "BE") {
+                d.order = binary.BigEndian
+        }
+        return d
+}
+
+func (d *inputDecoder) Read(p []byte) (int, error) {
+        for len(d.out) == 0 {
+                if d.eof {
+                        if d.pending == 0 && len(d.in) == 0 {
+                                return 0, io.EOF
+                        }
+                        // An incomplete character at the end of the input
+                        d.invalid()
+                        d.pending, d.in = 0, nil
+                        continue
+                }
+                n, err := d.r.Read(d.buf)
+                d.in = append(d.in, d.buf[:n]...)
+                var consumed int
+                switch {
+                case d.unit > 0:
+                        consumed = d.decodeUnicode()
+                case d.table != nil:
+                        consumed = d.decodeSingleByte()
+                default:
+                        consumed = d.decodeShiftJIS()
+                }
+                d.in = append(d.in[:0], d.in[consumed:]...)
+                if err == io.EOF {
+                        d.eof = true
+                } else if err != nil {
+                        return 0, err
+                }
+        }
+        n := copy(p, d.out)
+        d.out = d.out[n:]
+        return n, nil
+}
+
+// decodeUnicode converts the complete UTF-16 or UTF-32 code units read so
+// far and returns the number of bytes used
+func (d *inputDecoder) decodeUnicode() int {
+        i := 0
+        for ; i+d.unit <= len(d.in); i += d.unit {
+                var r rune
+                if d.unit == 4 {
+                        r = rune(d.order.Uint32(d.in[i:]))
+                } else {
+                        r = rune(d.order.Uint16(d.in[i:]))
+                        if d.pending != 0 {
+                                high := d.pending
+                                d.pending = 0
+                                if r >= 0xDC00 && r <= 0xDFFF {
+                                        d.out = utf8.AppendRune(d.out, utf16.DecodeRune(high, r))
+                                        continue
+                                }
+                                d.invalid()
+                        }
+                        if r >= 0xD800 && r <= 0xDBFF {
+                                d.pending = r
+                                continue
+                        }
+                }
+                d.emit(r)
+        }
+        return i
+}
+
+// decodeSingleByte converts all bytes read so far
+func (d *inputDecoder) decodeSingleByte() int {
+        for _, b := range d.in {
+                if b < 0x80 {
+                        d.out = append(d.out, b)
+                        continue
+                }
+                d.emit(d.table[b-0x80])
+        }
+        return len(d.in)
+}
+
+// decodeShiftJIS converts the complete characters read so far, leaving a
+// lead byte whose trail byte is still to come
+func (d *inputDecoder) decodeShiftJIS() int {
+        i := 0
+        for ; i < len(d.in); i++ {
+                b := d.in[i]
+                switch {
+                case b < 0x80:
+                        d.out = append(d.out, b)
+                case b >= 0xA1 && b <= 0xDF:
+                        // Halfwidth katakana, U+FF61-U+FF9F
+                        d.emit(rune(b) - 0xA1 + 0xFF61)
+                case (b >= 0x81 && b <= 0x9F || b >= 0xE0 && b <= 0xFC) && i+1 == len(d.in):
+                        return i
+                case b >= 0x81 && b <= 0x9F || b >= 0xE0 && b <= 0xFC:
+                        if r, ok := shiftJISTable[uint16(b)<<8|uint16(d.in[i+1])]; ok {
+                                d.emit(r)
+                                i++
+                                continue
+                        }
+                        d.invalid()
+                default:
+                        d.invalid()
+                }
+        }
+        return i
+}
+
+// emit appends a decoded character, or U+FFFD for one that is not valid
+func (d *inputDecoder) emit(r rune) {
+        if r == utf8.RuneError || !utf8.ValidRune(r) {
+                d.invalid()
+                return
+        }
+        d.out = utf8.AppendRune(d.out, r)
+}
+
+func (d *inputDecoder) invalid() {
+        d.out = utf8.AppendRune(d.out, utf8.RuneError)
+        d.Invalid++
+}
+
+// decodeSample decodes a sample of input in encoding to UTF-8 and returns
+// it with the number of invalid sequences in it. A character cut off at the
+// end of the sample is left out rather than counted as invalid.
+func decodeSample(sample []byte, encoding string) ([]byte, int) {
+        if !decodable(encoding) {
+                end := len(sample)
+                for i := len(sample) - 1; i >= 0 && i >= len(sample)-utf8.UTFMax; i-- {
+                        if utf8.RuneStart(sample[i]) {
+                                if !utf8.FullRune(sample[i:]) {
+                                        end = i
+                                }
+                                break
+                        }
+                }
+                invalid := 0
+                for i := 0; i < end; {
+                        r, size := utf8.DecodeRune(sample[i:end])
+                        if r == utf8.RuneError && size == 1 {
+                                invalid++
+                        }
+                        i += size
+                }
+                return sample[:end], invalid
+        }
+        d := newInputDecoder(nil, encoding)
+        d.in = sample
+        switch {
+        case d.unit > 0:
+                d.decodeUnicode()
+        case d.table != nil:
+                d.decodeSingleByte()
+        default:
+                d.decodeShiftJIS()
+        }
+        return d.out, d.Invalid
+}
+
+// decodingReader returns a reader of the UTF-8 text in reader, decoding it
+// first if it is in another encoding; the decoder is nil otherwise
+func decodingReader(reader *bufio.Reader, encoding string) (*bufio.Reader, *inputDecoder) {
+        if !decodable(encoding) {
+                return reader, nil
+        }
+        decoder := newInputDecoder(reader, encoding)
+        return bufio.NewReaderSize(decoder, detectSampleSize), decoder
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestDetectLegacyEncoding(t *testing.T) {
+        tests := []struct {
+                name, sample, want string
+        }{
+                // "です" is 82 c5 82 b7, and c5 82 is valid UTF-8
+                {"Shift_JIS sentence", "\x82\xb1\x82\xea\x82\xcd\x93\xfa\x96\x7b\x8c\xea\x82\xcc\x83\x65\x83\x4c\x83\x58\x83\x67\x82\xc5\x82\xb7\x81\x42", "Shift_JIS"},
+                {"Shift_JIS prose", "\x8c\xe1\x94\x79\x82\xcd\x94\x4c\x82\xc5\x82\xa0\x82\xe9\x81\x42\x96\xbc\x91\x4f\x82\xcd\x82\xdc\x82\xbe\x96\xb3\x82\xa2\x81\x42\x82\xc7\x82\xb1\x82\xc5\x90\xb6\x82\xea\x82\xbd\x82\xa9\x82\xc6\x82\xf1\x82\xc6\x8c\xa9\x93\x96\x82\xaa\x82\xc2\x82\xa9\x82\xca\x81\x42", "Shift_JIS"},
+                {"Windows-1252", "\x44\x65\x72\x20\x42\xe4\x72\x20\x6c\xe4\x75\x66\x74\x20\xfc\x62\x65\x72\x20\x64\x69\x65\x20\x53\x74\x72\x61\xdf\x65\x2c\x20\x77\xe4\x68\x72\x65\x6e\x64\x20\x64\x69\x65\x20\x4b\xfc\x68\x65\x20\x67\x72\x61\x73\x65\x6e\x2e\x20\x54\x72\xe8\x73\x20\xe9\x6c\xe9\x67\x61\x6e\x74\x20\x96\x20\xab\x6f\x75\x69\xbb\x2e", "Windows-1252"},
+                {"KOI8-R", "\xf0\xd2\xc9\xd7\xc5\xd4\x2c\x20\xcb\xc1\xcb\x20\xc4\xc5\xcc\xc1\x3f\x20\xfc\xd4\xcf\x20\xd0\xd2\xc9\xcd\xc5\xd2\x20\xd4\xc5\xcb\xd3\xd4\xc1\x2e", "KOI8-R"},
+                {"UTF-8 Japanese", "これは日本語のテキストです。", "UTF-8"},
+                {"UTF-8 with a few bad bytes", "caf\xe9 na\xefve – ü", "UTF-8"},
+        }
+        for _, tt := range tests {
+                if got, _ := detectEncoding([]byte(tt.sample)); got != tt.want {
+                        t.Errorf("%s: detectEncoding = %q, want %q", tt.name, got, tt.want)
+                }
+        }
+}
@@ -0,0 +1,1466 @@
+package main
+
+import (
+        "bufio"
+        "bytes"
+        "crypto/sha256"
+        "encoding/hex"
+        "fmt"
+        "io"
+        "io/fs"
+        "runtime"
+        "strconv"
+        "strings"
+        "unicode"
+        "unicode/utf8"
+)
+
+// CleaningOptions defines what types of characters to remove
+type CleaningOptions struct {
+        RemoveNonASCII         bool
+        RemoveControlChars     bool
+        RemoveZeroWidth        bool
+        RemoveBOM              bool
+        EnsureBOM              bool // start the output with a UTF-8 BOM
+        NormalizeQuotes        bool // replace typographic quotes with ASCII quotes
+        SmartPunct             bool // replace dashes, ellipses, primes and guillemets with ASCII
+        FixQuotes              bool // with NormalizeQuotes, remove closing quotes that close nothing
+        CSVSafe                bool // escape CSV fields that spreadsheets would run as formulas
+        CSVDelimiter           rune
+        TSV                    bool   // keep tabs, escape line breaks in fields and check column counts
+        TSVNewline             string // what line breaks inside TSV fields are replaced with
+        KeyValue               bool   // clean only the values and comments of .properties, .ini and .env files
+        PO                     bool   // clean only the translations (msgstr) of gettext catalogs
+        Chat                   bool   // keep the timestamp and author of chat export messages
+        DropReactions          bool   // with Chat, drop lines that only list reactions
+        DecodeEscapes          bool   // with KeyValue, decode \uXXXX escapes in values
+        NormalizeWhitespace    bool
+        NormalizeUnicode       string // nfc, nfd, nfkc or nfkd; applied before all other cleaning
+        FixMojibake            bool   // undo double UTF-8 encoding (Ã© to é) before normalizing
+        Transliterate          bool   // after normalizing, reduce accented letters and compatibility characters to ASCII
+        PreserveNewlines       bool
+        TargetOS               string
+        StripFormat            string
+        StripKeep              map[string]bool // annotations kept by -strip: alt, title, aria-label
+        StripExtract           map[string]bool // metadata moved to a header by -strip html: title, description, json-ld
+        Replacement            string          // written in place of each removed character, if set
+        AllowRanges            []charRange     // characters kept even when their category is removed
+        KeepLetters            bool            // with RemoveNonASCII, keep letters of any script
+        Emoji                  string          // "remove", "shortcode" (:smile:) or "unicode" (:smile: to the emoji)
+        StripModifiers         bool            // remove variation selectors, skin tones and stray joiners
+        SoftHyphens            string          // "remove", "hyphen" (replace with -) or "keep"
+        Spaces                 bool            // convert Unicode spaces (NBSP, em space...) to ASCII spaces
+        Dashes                 string          // "keep", "hyphen" or "double-hyphen"; empty leaves dashes to the other options
+        InvalidUTF8            string          // "replace" (with U+FFFD), "remove", "error" or "keep"
+        InputEncoding          string          // a name from inputEncodings; empty detects it
+        NoLegacyGuess          bool            // -invalid-utf8 was set, so input that is not UTF-8 is not taken for a legacy encoding
+        Strict                 bool
+        WarnLineLength         int
+        MaxSize                int64  // bytes; 0 is no limit
+        Oversize               string // what to do with a larger file: "refuse" or "chunk" (stream it)
+        MaxMemory              int64  // bytes that cleaning may use, per file once divided among jobs; 0 is no limit
+        TrimTrailingWhitespace bool
+        InsertFinalNewline     bool
+
+        // PreserveLines guarantees that every input line yields exactly one
+        // output line, so line numbers stay valid
+        PreserveLines bool
+
+        // OnlyLines, if non-nil, restricts cleaning to these line numbers;
+        // all other lines are copied unchanged
+        OnlyLines map[int]bool
+
+        // RecordLocations keeps the position of every removed character
+        // (up to maxLocations per file) in CleaningStats.Locations
+        RecordLocations bool
+
+        // Quarantine records the offset and context of every removed
+        // character, without the limit of maxLocations (-quarantine)
+        Quarantine bool
+
+        // RecordWords keeps the words that removed characters sat inside
+        // (up to maxLocations per file) in CleaningStats.WordsAffected
+        RecordWords bool
+
+        // TraceFrom and TraceTo, if TraceFrom is set, select the lines for
+        // which every character and what was done with it is written to
+        // TraceOutput, standard error for -trace
+        TraceFrom, TraceTo int
+        TraceOutput        io.Writer
+
+        // Trace, if set, is called by cleanString with the index of every
+        // character and what it did with it
+        Trace func(index int, char rune, action string)
+
+        // Progress, if set, is written everything read from the input, for
+        // the progress bar to count
+        Progress io.Writer
+
+        // Provenance, "prepend" or "append", adds a comment with
+        // ProvenanceText to files whose format has comments
+        Provenance     string
+        ProvenanceText string
+
+        // SecurityProfile, "utr39" or empty, removes the characters the
+        // UTR #39 General Security Profile restricts and reports words that
+        // mix scripts or look like other words of the file (-preset)
+        SecurityProfile string
+
+        // TempDir, if set, holds the temporary files of in-place rewrites
+        // (-tmpdir) instead of the directory of each file
+        TempDir string
+
+        // OutputMode, if not 0, is the permissions of every file written:
+        // outputs, files cleaned in place and backups (-output-mode)
+        OutputMode fs.FileMode
+
+        // BackupDir, if set, receives the backups instead of the directory
+        // of each file (-backup-dir), and BackupName selects how they are
+        // named: plain, timestamp or numbered (-backup-name)
+        BackupDir  string
+        BackupName string
+
+        // PreserveTimes and PreserveOwner give outputs and files cleaned in
+        // place the times and the owner of the input (-preserve-times,
+        // -preserve-owner)
+        PreserveTimes bool
+        PreserveOwner bool
+
+        // UndoPatch writes the patch that restores the input next to every
+        // output and file cleaned in place (-undo-patch)
+        UndoPatch bool
+
+        // Verify cleans every output once more and fails the file if that
+        // would still change it (-verify)
+        Verify bool
+}
+
+// CharLocation is the position of a removed character; Column counts
+// characters (code points) from 1
+type CharLocation struct {
+        Line       int
+        Column     int
+        ByteColumn int // in bytes of UTF-8, for -report rdjson and rdjsonl
+        Char       rune
+
+        // With options.Quarantine, the offset in bytes of the character in
+        // the text as UTF-8, and the text around it as audit shows it
+        Offset  int
+        Context string
+}
+
+// maxLocations limits the number of locations recorded per file
+const maxLocations = 10000
+
+// CleaningStats holds statistics about the cleaning process
+type CleaningStats struct {
+        OriginalChars             int // characters in the input file
+        TotalChars                int // characters passed to cleaning, after any format stripping or normalization
+        OutputChars               int // characters written
+        OriginalBytes             int // size of the input file
+        OutputBytes               int // size of what was written
+        RemovedChars              int
+        NonASCIIRemoved           int
+        ControlCharsRemoved       int
+        ZeroWidthRemoved          int
+        RestrictedRemoved         int // characters restricted by -preset utr39
+        MixedScriptWords          int // words mixing scripts, reported by -preset utr39
+        ConfusableWords           int // words that look like another word of the file
+        EmojiRemoved              int // whole emoji, however many characters each
+        EmojiCharsRemoved         int
+        EmojiConverted            int // emoji converted to shortcodes or back
+        ModifiersRemoved          int // variation selectors, skin tones and stray joiners
+        SoftHyphensRemoved        int
+        SoftHyphensConverted      int // soft hyphens replaced with hyphens
+        SpacesConverted           int // Unicode spaces replaced with ASCII spaces
+        DashesConverted           int // dashes and minus signs replaced by -dashes
+        ReplyMarkersNormalized    int // quoted-reply markers rewritten by -chat
+        ReactionLinesDropped      int
+        LinesProcessed            int
+        LinesWithIssues           int
+        LineEndingsConverted      int
+        LineEndingConversions     map[string]int
+        OriginalLineEndings       map[string]int
+        RemovedCharDetails        map[rune]int
+        MarkdownStripped          bool
+        HTMLStripped              bool
+        SubtitlesStripped         bool
+        HTMLEntitiesDecoded       int
+        UnicodeNormalized         int            // character sequences changed by -normalize-unicode
+        MojibakeRepaired          int            // double-encoded characters repaired by -fix-mojibake
+        Transliterated            int            // characters reduced to ASCII by -transliterate
+        InvalidUTF8Sequences      int            // invalid UTF-8, counted as U+FFFD would replace it
+        InvalidUTF8Action         string         // what -invalid-utf8 did with them
+        DecodedFrom               string         // the encoding of input converted to UTF-8
+        InputEncoding             string         // the encoding of the input, detected or named with -input-encoding
+        BOMPresent                bool           // the input started with a byte order mark
+        ReplacementCharsAdded     int            // U+FFFD written in place of invalid input
+        Replacement               string         // what removed characters were replaced with, if anything
+        AllowedKept               int            // characters kept only because of -allow-ranges
+        StrippedConstructs        map[string]int // e.g. "links", "<div> tags"
+        FormatDetected            string
+        TrailingWhitespaceTrimmed int
+        FinalNewlineAdded         bool
+        BOMAdded                  bool
+        ProvenanceAdded           bool
+        QuotesNormalized          int
+        PunctuationConverted      int       // dashes, ellipses, primes and guillemets replaced by -smart-punct
+        StrayQuotesRemoved        int       // closing quotes without an opening one, removed by -fix-quotes
+        FormulasEscaped           int       // CSV fields prefixed with ' by -csv-safe
+        EscapedCells              []CSVCell // the first maxLocations of them
+        NewlinesEscaped           int       // line breaks inside TSV fields
+        EscapesDecoded            int       // \uXXXX escapes decoded by -decode-escapes
+        MalformedRows             []TSVRow
+        InputHash                 string // SHA-256 of the input as read
+        OutputHash                string // SHA-256 of what was written
+        OutputSkipped             bool   // identical to the input, so not written
+        Warnings                  []Warning
+        Locations                 []CharLocation
+        WordsAffected             []AffectedWord
+
+        removed []int // indexes of the characters cleanString removed, with RecordWords
+}
+
+// validUTF8 reports whether the input was UTF-8 without a single invalid
+// sequence
+func (s *CleaningStats) validUTF8() bool {
+        return s.DecodedFrom == "" && s.InvalidUTF8Sequences == 0
+}
+
+// flagged reports whether -preset found words that cleaning cannot fix,
+// which fail -check as changes do
+func (s *CleaningStats) flagged() bool {
+        return s.MixedScriptWords > 0 || s.ConfusableWords > 0
+}
+
+// failsCheck reports whether the file fails -check: cleaning would change
+// it, or -preset flagged words in it
+func (s *CleaningStats) failsCheck() bool {
+        return s.changed() || s.flagged()
+}
+
+// changed reports whether cleaning altered (or, in check mode, would alter)
+// the file content
+func (s *CleaningStats) changed() bool {
+        return s.RemovedChars > 0 || s.LineEndingsConverted > 0 || s.MarkdownStripped || s.HTMLStripped || s.SubtitlesStripped ||
+                s.TrailingWhitespaceTrimmed > 0 || s.FinalNewlineAdded || s.BOMAdded || s.UnicodeNormalized > 0 || s.Transliterated > 0 ||
+                s.QuotesNormalized > 0 || s.FormulasEscaped > 0 || s.NewlinesEscaped > 0 || s.EscapesDecoded > 0 || s.EmojiConverted > 0 ||
+                s.ReplyMarkersNormalized > 0 || s.ReactionLinesDropped > 0 || s.SoftHyphensConverted > 0 ||
+                s.SpacesConverted > 0 || s.DashesConverted > 0 || s.MojibakeRepaired > 0 || s.PunctuationConverted > 0 || s.StrayQuotesRemoved > 0 ||
+                s.InvalidUTF8Sequences > 0 && s.InvalidUTF8Action != "kept" || s.DecodedFrom != "" || s.ProvenanceAdded
+}
+
+// softHyphen marks where a word may be broken; it is only displayed, as a
+// hyphen, at the end of a line. Text copied from justified PDF and Word
+// documents is full of them.
+const softHyphen = '\u00AD'
+
+// validSoftHyphens reports whether mode is a valid -soft-hyphens value
+func validSoftHyphens(mode string) bool {
+        return mode == "remove" || mode == "hyphen" || mode == "keep"
+}
+
+// Common zero-width and invisible Unicode characters
+var zeroWidthChars = []rune{
+        '\u200B', '\u200C', '\u200D', '\u200E', '\u200F', '\uFEFF',
+        '\u202A', '\u202B', '\u202C', '\u202D', '\u202E', '\u2060',
+        '\u2061', '\u2062', '\u2063', '\u2064', '\u2066', '\u2067',
+        '\u2068', '\u2069', '\u206A', '\u206B',
+        '\u206C', '\u206D', '\u206E', '\u206F',
+}
+
+// HTML entity mappings
+var htmlEntities = map[string]string{
+        "&amp;":    "&",
+        "&lt;":     "<",
+        "&gt;":     ">",
+        "&quot;":   "\"",
+        "&apos;":   "'",
+        "&nbsp;":   " ",
+        "&copy;":   "\u00A9",
+        "&reg;":    "\u00AE",
+        "&trade;":  "\u2122",
+        "&euro;":   "\u20AC",
+        "&pound;":  "\u00A3",
+        "&yen;":    "\u00A5",
+        "&cent;":   "\u00A2",
+        "&sect;":   "\u00A7",
+        "&para;":   "\u00B6",
+        "&middot;": "\u00B7",
+        "&bull;":   "\u2022",
+        "&hellip;": "\u2026",
+        "&ndash;":  "\u2013",
+        "&mdash;":  "\u2014",
+        "&lsquo;":  "\u2018",
+        "&rsquo;":  "\u2019",
+        "&ldquo;":  "\u201C",
+        "&rdquo;":  "\u201D",
+        "&times;":  "\u00D7",
+        "&divide;": "\u00F7",
+        "&deg;":    "\u00B0",
+        "&plusmn;": "\u00B1",
+        "&frac14;": "\u00BC",
+        "&frac12;": "\u00BD",
+        "&frac34;": "\u00BE",
+}
+
+// Character descriptions for better output
+var charDescriptions = map[rune]string{
+        '\u00AD': "Soft Hyphen",
+        '\u200B': "Zero Width Space",
+        '\u200C': "Zero Width Non-Joiner",
+        '\u200D': "Zero Width Joiner",
+        '\u200E': "Left-to-Right Mark",
+        '\u200F': "Right-to-Left Mark",
+        '\uFEFF': "BOM/Zero Width No-Break Space",
+        '\u202A': "Left-to-Right Embedding",
+        '\u202B': "Right-to-Left Embedding",
+        '\u202C': "Pop Directional Formatting",
+        '\u202D': "Left-to-Right Override",
+        '\u202E': "Right-to-Left Override",
+        '\u2066': "Left-to-Right Isolate",
+        '\u2067': "Right-to-Left Isolate",
+        '\u2068': "First Strong Isolate",
+        '\u2069': "Pop Directional Isolate",
+        '\u2060': "Word Joiner",
+        '\u0000': "NULL character",
+        '\u0001': "Start of Heading",
+        '\u0002': "Start of Text",
+        '\u0003': "End of Text",
+        '\u0004': "End of Transmission",
+        '\u0005': "Enquiry",
+        '\u0006': "Acknowledge",
+        '\u0007': "Bell",
+        '\u0008': "Backspace",
+        '\u000B': "Vertical Tab",
+        '\u000C': "Form Feed",
+        '\u000E': "Shift Out",
+        '\u000F': "Shift In",
+        '\r':     "Carriage Return (CR)",
+        '\n':     "Line Feed (LF)",
+}
+
+// describeChar returns a human-readable name for a removed character
+func describeChar(char rune) string {
+        if desc := charDescriptions[char]; desc != "" {
+                return desc
+        }
+        if unicode.IsPrint(char) {
+                return fmt.Sprintf("Character '%c'", char)
+        } else if unicode.IsControl(char) {
+                return fmt.Sprintf("Control character (U+%04X)", char)
+        }
+        return fmt.Sprintf("Non-printable (U+%04X)", char)
+}
+
+// charCategory returns the removal category a character is counted under
+func charCategory(char rune) string {
+        switch {
+        case isZeroWidth(char):
+                return "zero-width"
+        case char == softHyphen:
+                return "soft-hyphen"
+        case char > 127:
+                return "non-ascii"
+        default:
+                return "control"
+        }
+}
+
+// formatFinding formats one removed character grep-style, as
+// "path:line:column: U+200B Zero Width Space (zero-width)"
+func formatFinding(path string, loc CharLocation) string {
+        return fmt.Sprintf("%s:%d:%d: U+%04X %s (%s)", path, loc.Line, loc.Column, loc.Char, describeChar(loc.Char), charCategory(loc.Char))
+}
+
+func normalizeTargetOS(targetOS string) string {
+        targetOS = strings.ToLower(strings.TrimSpace(targetOS))
+
+        if targetOS == "" || targetOS == "auto" {
+                switch runtime.GOOS {
+                case "windows":
+                        return "windows"
+                case "darwin":
+                        return "unix"
+                default:
+                        return "unix"
+                }
+        }
+
+        switch targetOS {
+        case "windows", "win", "dos":
+                return "windows"
+        case "unix", "linux":
+                return "unix"
+        case "mac", "macos", "darwin":
+                return "unix"
+        case "mac9", "macos9", "classic":
+                return "mac9"
+        default:
+                return ""
+        }
+}
+
+func getLineEnding(targetOS string) string {
+        switch targetOS {
+        case "windows":
+                return "\r\n"
+        case "unix":
+                return "\n"
+        case "mac9":
+                return "\r"
+        default:
+                return "\n"
+        }
+}
+
+// OutputSink is a destination for cleaned content. cleanFile calls Open only
+// after the input has been read and validated, then either Close to commit
+// the content or Abort to discard it, so a failed run leaves no partial
+// output behind.
+type OutputSink interface {
+        Open() (io.Writer, error)
+        Close() error
+        Abort()
+        String() string
+}
+
+// discardSink drops the content; it is used by -check
+type discardSink struct{}
+
+func (s *discardSink) Open() (io.Writer, error) { return io.Discard, nil }
+func (s *discardSink) Close() error             { return nil }
+func (s *discardSink) Abort()                   {}
+func (s *discardSink) String() string           { return "" }
+
+// BufferSink keeps the cleaned content in memory, for embedding the cleaner
+// in other Go code
+type BufferSink struct {
+        Buffer bytes.Buffer
+}
+
+func (s *BufferSink) Open() (io.Writer, error) {
+        s.Buffer.Reset()
+        return &s.Buffer, nil
+}
+func (s *BufferSink) Close() error   { return nil }
+func (s *BufferSink) Abort()         { s.Buffer.Reset() }
+func (s *BufferSink) String() string { return "(buffer)" }
+
+// cleanReader is cleanFile for input read from r, with info describing r
+// if it is a file, or nil. inputPath names the input in traces, findings
+// and the provenance comment, and picks the -csv-safe delimiter; nothing
+// is opened by that name, so text in memory is cleaned by the same engine
+// as a file (see tools/wasm).
+func cleanReader(r io.Reader, info fs.FileInfo, inputPath string, sink OutputSink, options CleaningOptions, verbose bool) (*CleaningStats, error) {
+        stats := &CleaningStats{
+                RemovedCharDetails: make(map[rune]int),
+                Replacement:        options.Replacement,
+        }
+        tracer := newLineTracer(inputPath, options)
+
+        // Detection only looks at a buffered prefix, so it works the same
+        // for a pipe, which cannot be rewound, as for a file
+        inputHasher := sha256.New()
+        limiter := &sizeLimiter{r: r}
+        var counted io.Reader = limiter
+        if options.Progress != nil {
+                counted = io.TeeReader(limiter, options.Progress)
+        }
+        input := &byteCounter{r: io.TeeReader(counted, inputHasher)}
+        reader := bufio.NewReaderSize(input, detectSampleSize)
+        prefix, err := reader.Peek(detectSampleSize)
+        if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+                return nil, fmt.Errorf("could not read input file: %w", err)
+        }
+        encoding := inputEncodingOf(prefix, options)
+        if options.Strict && options.InputEncoding == "" && isLegacyEncoding(encoding) {
+                return nil, fmt.Errorf("strict: input is not UTF-8 and looks like %s (name its encoding with -input-encoding)", encoding)
+        }
+        var decoder *inputDecoder
+        if decodable(encoding) {
+                reader, decoder = decodingReader(reader, encoding)
+                prefix, err = reader.Peek(detectSampleSize)
+                if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+                        return nil, fmt.Errorf("could not read input file: %w", err)
+                }
+                stats.DecodedFrom = encoding
+        } else if !strings.HasPrefix(encoding, "UTF-8") && !options.Strict {
+                stats.warn(warnEncoding, 0, "input looks like %s and is cleaned as UTF-8", encoding)
+        }
+        stats.InputEncoding = encoding
+        stats.BOMPresent = bytes.HasPrefix(prefix, []byte{0xEF, 0xBB, 0xBF})
+        encodingChecked := false
+
+        // With -ensure-bom an existing BOM is passed through rather than
+        // removed and added again
+        keptBOM := options.EnsureBOM && bytes.HasPrefix(prefix, []byte{0xEF, 0xBB, 0xBF})
+        if keptBOM {
+                reader.Discard(3)
+                stats.OriginalChars++
+                stats.OutputChars++
+        }
+
+        detectedFormat := ""
+        if options.StripFormat != "" {
+                detectedFormat = detectFileFormat(string(prefix))
+                stats.FormatDetected = detectedFormat
+                if verbose {
+                        fmt.Printf("Detected format: %s\n", detectedFormat)
+                }
+        }
+        if options.StripFormat == "auto" {
+                options.StripFormat = ""
+                if detectedFormat == "markdown" || detectedFormat == "html" || detectedFormat == "subtitles" {
+                        options.StripFormat = detectedFormat
+                }
+        }
+
+        // Stripping and CSV rewriting see the whole document, since
+        // constructs and quoted fields can span lines
+        buffered := options.StripFormat != "" || options.CSVSafe || options.TSV
+        if limit, limitErr := inputLimit(options, buffered); limit > 0 {
+                limiter.err = limitErr
+                if info != nil && info.Mode().IsRegular() && info.Size() > limit {
+                        return nil, limitErr
+                }
+                limiter.max = limit
+        }
+        if buffered {
+                contentBytes, err := io.ReadAll(reader)
+                if err != nil {
+                        return nil, fmt.Errorf("could not read input file: %w", err)
+                }
+                if err := checkContentEncoding(stats, contentBytes, options); err != nil {
+                        return nil, err
+                }
+                encodingChecked = true
+
+                stats.OriginalChars += utf8.RuneCount(contentBytes)
+                content := applyInvalidUTF8(string(contentBytes), options.InvalidUTF8)
+                if options.StripFormat != "" {
+                        content, err = stripContent(content, detectedFormat, stats, options, verbose)
+                        if err != nil {
+                                return nil, err
+                        }
+                }
+                if options.CSVSafe {
+                        delimiter := options.CSVDelimiter
+                        if delimiter == 0 {
+                                delimiter = csvDelimiterFor(inputPath)
+                        }
+                        content = makeCSVSafe(content, delimiter, options.NormalizeQuotes, stats)
+                }
+                if options.TSV {
+                        content, err = prepareTSV(content, options.TSVNewline, stats, options)
+                        if err != nil {
+                                return nil, err
+                        }
+                }
+                reader = bufio.NewReader(strings.NewReader(content))
+        }
+
+        out, err := sink.Open()
+        if err != nil {
+                return nil, err
+        }
+
+        hasher := sha256.New()
+        output := &byteCounter{w: io.MultiWriter(out, hasher)}
+        writer := bufio.NewWriter(output)
+
+        if options.EnsureBOM {
+                if _, err := writer.WriteString("\uFEFF"); err != nil {
+                        sink.Abort()
+                        return nil, fmt.Errorf("error writing to output: %w", err)
+                }
+                if !keptBOM {
+                        stats.BOMAdded = true
+                        stats.OutputChars++
+                }
+        }
+
+        lineNum := 0
+        targetLineEnding := getLineEnding(options.TargetOS)
+        lines := newLineReader(reader)
+        lastEnding := ""
+
+        // With -provenance, prepend holds the comment until it is written
+        // before the first line, or after it if that has to stay first, or
+        // after the front matter that frontMatter is set within
+        provenance, prepend := "", ""
+        frontMatter := false
+        if options.Provenance != "" {
+                var ok bool
+                provenance, ok = provenanceComment(inputPath, options)
+                if !ok {
+                        stats.warn(warnProvenance, 0, "no comment syntax known for this file, provenance comment left out")
+                }
+                if options.Provenance == "prepend" {
+                        prepend = provenance
+                }
+        }
+        writeProvenance := func(lineEnding string) error {
+                comment := lineEnding + provenance + targetLineEnding
+                if _, err := writer.WriteString(comment); err != nil {
+                        sink.Abort()
+                        return fmt.Errorf("error writing to output: %w", err)
+                }
+                stats.OutputChars += utf8.RuneCountInString(comment)
+                stats.ProvenanceAdded = true
+                return nil
+        }
+
+        // Long lines arrive in several chunks: column counts the characters
+        // of the current line before this chunk, width the columns they take
+        // up on screen, carry holds trailing blanks that can only be trimmed
+        // once the end of the line is known
+        continued := false
+        column, width := 0, 0
+        byteColumn := 0
+        carry := ""
+
+        // lineOffset is where the current line starts in the text, and
+        // consumed how much of the text has been read
+        lineOffset, consumed := 0, 0
+        lineHasIssues := false
+
+        // With -key-value, valueLine is set while a line holds a value and
+        // valueContinues when a trailing backslash carries it to the next
+        valueLine := false
+        valueContinues := false
+
+        // With -po, po follows the entries of the catalog. keepWhole is
+        // set when a line is copied unchanged, in all of its chunks.
+        var po poState
+        keepWhole := false
+
+        // With -normalize-quotes, quotes pairs the quotation marks of each
+        // paragraph
+        var quotes quotePairs
+        var security *securityScanner
+        if options.SecurityProfile != "" {
+                security = newSecurityScanner()
+        }
+
+        chunk := chunkSize(options)
+        for {
+                content, ending, more, readErr := lines.readChunk(chunk)
+                if readErr == io.EOF {
+                        break
+                }
+                if readErr != nil {
+                        sink.Abort()
+                        return nil, fmt.Errorf("could not read input file: %w", readErr)
+                }
+                line := content + ending
+                firstChunk := !continued
+
+                if !continued {
+                        lineNum++
+                        stats.LinesProcessed++
+                        column, width, byteColumn = 0, 0, 0
+                        lineOffset = consumed
+                        if lineNum == 1 && keptBOM {
+                                column, byteColumn = 1, len("\uFEFF")
+                                consumed += len("\uFEFF")
+                        }
+                        lineHasIssues = false
+                        if prepend != "" {
+                                hold := false
+                                switch {
+                                case lineNum == 1:
+                                        frontMatter = opensFrontMatter(inputPath, content)
+                                        hold = frontMatter || mustStayFirst(inputPath, content)
+                                case frontMatter:
+                                        // The closing line is part of it
+                                        frontMatter = !closesFrontMatter(content)
+                                        hold = true
+                                }
+                                if !hold {
+                                        if err := writeProvenance(""); err != nil {
+                                                return nil, err
+                                        }
+                                        prepend = ""
+                                }
+                        }
+                        if tracer.startLine(lineNum, options) && lineNum == options.TraceFrom && buffered {
+                                tracer.note("lines are counted in the text left by -strip, -csv-safe or -tsv")
+                        }
+                }
+                continued = more
+                consumed += len(line)
+
+                // The part of the line before the value (a key, a msgid or
+                // a chat prefix) is kept as it is. What comes before that,
+                // a BOM or direction marks, is cleaned on its own.
+                lead, keep := "", ""
+                if column == 0 {
+                        keepWhole = false
+                        if options.KeyValue && !valueContinues || options.PO || options.Chat {
+                                if options.Chat {
+                                        lead = leadingMarks(content)
+                                } else if lineNum == 1 && strings.HasPrefix(content, "\uFEFF") {
+                                        lead = "\uFEFF"
+                                }
+                                rest := content[len(lead):]
+                                switch {
+                                case options.PO:
+                                        var clean bool
+                                        keep, clean = po.split(rest)
+                                        keepWhole = !clean
+                                case options.Chat:
+                                        keep = chatPrefix(rest)
+                                default:
+                                        keep, _, valueLine = splitKeyValue(rest)
+                                        keepWhole = keep == rest
+                                }
+                                if keep == "" {
+                                        lead = ""
+                                }
+                                if keepWhole {
+                                        tracer.note("copied unchanged (%s)", keptPrefixOption(options))
+                                } else if keep != "" {
+                                        tracer.note("%q kept as is (%s)", keep, keptPrefixOption(options))
+                                }
+                        }
+                } else if keepWhole {
+                        keep = content
+                }
+                if options.KeyValue && !more {
+                        valueContinues = valueLine && continuesValue(content)
+                }
+
+                if provenance != "" && firstChunk && !more && provenancePattern.MatchString(strings.TrimPrefix(content, "\uFEFF")) {
+                        stats.OriginalChars += utf8.RuneCountInString(line)
+                        stats.countLineEnding(ending, targetLineEnding, false)
+                        tracer.note("dropped, the provenance comment of an earlier run (-provenance)")
+                        continue
+                }
+
+                if options.OnlyLines != nil && !options.OnlyLines[lineNum] {
+                        if _, err := writer.WriteString(line); err != nil {
+                                sink.Abort()
+                                return nil, fmt.Errorf("error writing to output: %w", err)
+                        }
+                        chars := utf8.RuneCountInString(line)
+                        stats.OriginalChars += chars
+                        stats.OutputChars += chars
+                        stats.countLineEnding(ending, targetLineEnding, false)
+                        lastEnding = ending
+                        column += chars
+                        byteColumn += len(line)
+                        tracer.note("copied unchanged (not changed since the last commit, -changed-only)")
+                        continue
+                }
+
+                if !encodingChecked {
+                        if err := checkLineEncoding(stats, []byte(line), lineNum, column, options); err != nil {
+                                sink.Abort()
+                                return nil, err
+                        }
+                }
+
+                if options.DropReactions && column == 0 && !more && keep == "" && isReactionLine(content) {
+                        stats.OriginalChars += utf8.RuneCountInString(line)
+                        stats.countLineEnding(ending, targetLineEnding, false)
+                        stats.ReactionLinesDropped++
+                        tracer.note("dropped, it only lists reactions (-drop-reactions)")
+                        continue
+                }
+
+                if keep != "" {
+                        line = line[len(lead)+len(keep):]
+                }
+                leadChars := utf8.RuneCountInString(lead)
+                keepChars := utf8.RuneCountInString(keep)
+
+                inputChars := -1
+                if prepared := applyInvalidUTF8(line, options.InvalidUTF8); prepared != line {
+                        if removed := utf8.RuneCountInString(line) - utf8.RuneCountInString(prepared); removed > 0 {
+                                inputChars = utf8.RuneCountInString(line)
+                                tracer.note("%d invalid UTF-8 sequence(s) removed (-invalid-utf8 remove); columns count the remaining text", removed)
+                        }
+                        line = prepared
+                }
+                markerEnd, markerShift := 0, 0
+                if options.Chat && column == 0 && !keepWhole {
+                        normalized, before, after := normalizeReplyMarker(line)
+                        if before > 0 {
+                                inputChars = utf8.RuneCountInString(line)
+                                line = normalized
+                                markerEnd, markerShift = after, before-after
+                                stats.ReplyMarkersNormalized++
+                                tracer.note("reply marker rewritten as %q (-chat)", normalized[:after])
+                        }
+                }
+                if options.DecodeEscapes && valueLine {
+                        decoded, count := decodeUnicodeEscapes(line)
+                        if count > 0 {
+                                inputChars = utf8.RuneCountInString(line)
+                                line = decoded
+                                stats.EscapesDecoded += count
+                                tracer.note("%d escape(s) decoded (-decode-escapes); columns count the decoded text", count)
+                        }
+                }
+                // Plain ASCII has no mojibake or typographic punctuation
+                // to look for
+                plain := isPlainASCII(line)
+                if !plain && options.FixMojibake {
+                        fixed, count := fixMojibake(line)
+                        if count > 0 {
+                                if inputChars < 0 {
+                                        inputChars = utf8.RuneCountInString(line)
+                                }
+                                line = fixed
+                                stats.MojibakeRepaired += count
+                                tracer.note("%d double-encoded character(s) repaired (-fix-mojibake); columns count the repaired text", count)
+                        }
+                } else if !plain {
+                        if _, count, _ := fixMojibakeOnce(line); count > 0 {
+                                stats.warn(warnMojibake, lineNum, "text looks double-encoded (such as Ã© for é); -fix-mojibake repairs it")
+                        }
+                }
+                if options.NormalizeUnicode != "" {
+                        normalized, changed := normalizeUnicode(line, options.NormalizeUnicode)
+                        if changed > 0 {
+                                if inputChars < 0 {
+                                        inputChars = utf8.RuneCountInString(line)
+                                }
+                                line = normalized
+                                stats.UnicodeNormalized += changed
+                                tracer.note("%d sequence(s) converted to %s (-normalize-unicode); columns count the normalized text",
+                                        changed, strings.ToUpper(options.NormalizeUnicode))
+                        }
+                }
+                if options.Transliterate {
+                        transliterated, count := transliterate(line)
+                        if count > 0 {
+                                if inputChars < 0 {
+                                        inputChars = utf8.RuneCountInString(line)
+                                }
+                                line = transliterated
+                                stats.Transliterated += count
+                                tracer.note("%d character(s) reduced to ASCII (-transliterate); columns count the transliterated text", count)
+                        }
+                }
+                if options.NormalizeQuotes && !options.CSVSafe && !options.PO {
+                        // PO strings are delimited by quotes of their own
+                        var removed int
+                        line, removed = quotes.check(line, lineNum, options.FixQuotes, stats)
+                        if removed > 0 {
+                                if inputChars < 0 {
+                                        inputChars = utf8.RuneCountInString(line) + removed
+                                }
+                                stats.StrayQuotesRemoved += removed
+                                tracer.note("%d closing quote(s) without an opening one removed (-fix-quotes)", removed)
+                        }
+                }
+                if options.NormalizeQuotes && !options.CSVSafe {
+                        // One character for one, so columns are unchanged
+                        var count int
+                        line, count = normalizeQuotes(line)
+                        stats.QuotesNormalized += count
+                        if count > 0 && options.SmartPunct {
+                                tracer.note("%d quote(s) replaced with ASCII quotes (-smart-punct)", count)
+                        } else if count > 0 {
+                                tracer.note("%d quote(s) replaced with ASCII quotes (-normalize-quotes)", count)
+                        }
+                }
+                if options.SmartPunct && !plain {
+                        converted, count := convertSmartPunct(line, options.Dashes == "")
+                        if count > 0 {
+                                if inputChars < 0 && len(converted) != len(line) {
+                                        inputChars = utf8.RuneCountInString(line)
+                                }
+                                line = converted
+                                stats.PunctuationConverted += count
+                                tracer.note("%d punctuation mark(s) replaced with ASCII (-smart-punct); columns count the converted text", count)
+                        }
+                }
+                if options.Emoji == "unicode" {
+                        expanded, count := expandShortcodes(line)
+                        if count > 0 {
+                                if inputChars < 0 {
+                                        inputChars = utf8.RuneCountInString(line)
+                                }
+                                line = expanded
+                                stats.EmojiConverted += count
+                                tracer.note("%d shortcode(s) converted to emoji (-emoji unicode); columns count the converted text", count)
+                        }
+                }
+
+                // The lead is cleaned first, so that its characters are
+                // traced in order
+                cleanedLead := ""
+                var leadStats *CleaningStats
+                if lead != "" {
+                        leadOptions := options
+                        leadOptions.Trace = tracer.charsAt(column, 0, 0)
+                        cleanedLead, leadStats = cleanString(lead, leadOptions)
+                }
+
+                chunkOptions := options
+                if column > 0 || keep != "" {
+                        // Only the first character of a line can be a BOM
+                        chunkOptions.RemoveBOM = false
+                }
+                chunkOptions.Trace = tracer.charsAt(column+leadChars+keepChars, markerEnd, markerShift)
+                cleanedLine, lineStats := cleanString(line, chunkOptions)
+                for i := range lineStats.Locations {
+                        loc := &lineStats.Locations[i]
+                        if loc.Column > markerEnd {
+                                loc.Column += markerShift
+                        }
+                        loc.Column += leadChars + keepChars
+                }
+                for i := range lineStats.WordsAffected {
+                        word := &lineStats.WordsAffected[i]
+                        if word.Column > markerEnd {
+                                word.Column += markerShift
+                        }
+                        word.Column += leadChars + keepChars
+                }
+                if lead != "" {
+                        leadStats.Locations = append(leadStats.Locations, lineStats.Locations...)
+                        leadStats.WordsAffected = append(leadStats.WordsAffected, lineStats.WordsAffected...)
+                        mergeStats(leadStats, lineStats)
+                        lineStats = leadStats
+                        if inputChars >= 0 {
+                                inputChars += leadChars
+                        }
+                        cleanedLine = cleanedLead + keep + cleanedLine
+                } else {
+                        cleanedLine = keep + cleanedLine
+                }
+                if options.WarnLineLength > 0 {
+                        width += DisplayWidth(content)
+                }
+                lineWidth := 0
+                if !more {
+                        lineWidth = width
+                }
+                warnLine(stats, lineNum, lineWidth, cleanedLine, options)
+                security.scanLine(stats, lineNum, cleanedLine)
+
+                var runes []rune
+                if options.Quarantine && len(lineStats.Locations) > 0 {
+                        runes = []rune(content)
+                }
+                for _, loc := range lineStats.Locations {
+                        if len(stats.Locations) == maxLocations && !options.Quarantine {
+                                break
+                        }
+                        loc.Line = lineNum
+                        loc.ByteColumn = byteColumn + byteIndex(content, loc.Column-1) + 1
+                        if runes != nil {
+                                loc.Offset = lineOffset + loc.ByteColumn - 1
+                                loc.Context = auditContextAt(runes, loc.Column-1)
+                        }
+                        loc.Column += column
+                        stats.Locations = append(stats.Locations, loc)
+                }
+                for _, word := range lineStats.WordsAffected {
+                        if len(stats.WordsAffected) == maxLocations {
+                                break
+                        }
+                        word.Line = lineNum
+                        word.Column += column
+                        stats.WordsAffected = append(stats.WordsAffected, word)
+                }
+
+                stats.TotalChars += lineStats.TotalChars + keepChars
+                if !buffered {
+                        if inputChars < 0 {
+                                inputChars = lineStats.TotalChars
+                        }
+                        stats.OriginalChars += inputChars + keepChars
+                }
+                stats.RemovedChars += lineStats.RemovedChars
+                stats.NonASCIIRemoved += lineStats.NonASCIIRemoved
+                stats.AllowedKept += lineStats.AllowedKept
+                stats.ControlCharsRemoved += lineStats.ControlCharsRemoved
+                stats.ZeroWidthRemoved += lineStats.ZeroWidthRemoved
+                stats.RestrictedRemoved += lineStats.RestrictedRemoved
+                stats.EmojiRemoved += lineStats.EmojiRemoved
+                stats.EmojiCharsRemoved += lineStats.EmojiCharsRemoved
+                stats.EmojiConverted += lineStats.EmojiConverted
+                stats.ModifiersRemoved += lineStats.ModifiersRemoved
+                stats.SoftHyphensRemoved += lineStats.SoftHyphensRemoved
+                stats.SoftHyphensConverted += lineStats.SoftHyphensConverted
+                stats.SpacesConverted += lineStats.SpacesConverted
+
+                for char, count := range lineStats.RemovedCharDetails {
+                        stats.RemovedCharDetails[char] += count
+                }
+
+                if lineStats.RemovedChars > 0 && !lineHasIssues {
+                        stats.LinesWithIssues++
+                        lineHasIssues = true
+                }
+
+                if options.TrimTrailingWhitespace && !keepWhole {
+                        cleanedLine = carry + cleanedLine
+                        carry = ""
+                        if more {
+                                kept := strings.TrimRight(cleanedLine, " \t")
+                                carry = cleanedLine[len(kept):]
+                                cleanedLine = kept
+                        } else {
+                                var trimmed int
+                                cleanedLine, trimmed = trimTrailingWhitespace(cleanedLine, ending)
+                                stats.TrailingWhitespaceTrimmed += trimmed
+                                if trimmed > 0 {
+                                        tracer.note("%d trailing blank(s) trimmed (-trim-trailing)", trimmed)
+                                }
+                        }
+                }
+
+                cleanedLine, converted := normalizeLineEndings(cleanedLine, ending, targetLineEnding)
+                stats.countLineEnding(ending, targetLineEnding, converted)
+                if converted {
+                        tracer.note("line ending %s converted to %s (-os)", lineEndingName(ending), lineEndingName(targetLineEnding))
+                }
+
+                if options.PreserveLines && countLineBreaks(cleanedLine) != countLineBreaks(ending) {
+                        sink.Abort()
+                        return nil, fmt.Errorf("cleaning line %d would change the number of lines", lineNum)
+                }
+
+                if verbose {
+                        for _, loc := range lineStats.Locations {
+                                loc.Line = lineNum
+                                loc.Column += column
+                                fmt.Println(formatFinding(inputPath, loc))
+                        }
+                }
+
+                if options.InvalidUTF8 == "keep" {
+                        cleanedLine = restoreRawBytes(cleanedLine)
+                }
+                if _, err := writer.WriteString(cleanedLine); err != nil {
+                        sink.Abort()
+                        return nil, fmt.Errorf("error writing to output: %w", err)
+                }
+                stats.OutputChars += utf8.RuneCountInString(cleanedLine)
+                lastEnding = ending
+                column += utf8.RuneCountInString(content)
+                byteColumn += len(content)
+        }
+
+        if options.NormalizeQuotes && !options.CSVSafe && !options.PO {
+                quotes.end(stats)
+        }
+        if decoder != nil && decoder.Invalid > 0 {
+                stats.ReplacementCharsAdded += decoder.Invalid
+                stats.warn(warnEncoding, 0, "%d invalid %s sequence(s) decoded as U+FFFD", decoder.Invalid, encoding)
+        }
+        if options.InvalidUTF8 == "replace" {
+                stats.ReplacementCharsAdded += stats.InvalidUTF8Sequences
+        }
+
+        if options.InsertFinalNewline && lineNum > 0 && lastEnding == "" && (options.OnlyLines == nil || options.OnlyLines[lineNum]) {
+                if _, err := writer.WriteString(targetLineEnding); err != nil {
+                        sink.Abort()
+                        return nil, fmt.Errorf("error writing to output: %w", err)
+                }
+                stats.FinalNewlineAdded = true
+                stats.OutputChars += len(targetLineEnding)
+                lastEnding = targetLineEnding
+        }
+        if options.Provenance == "append" && provenance != "" || prepend != "" {
+                // A file without a line break, or only a first line that
+                // has to stay first
+                lineEnding := ""
+                if lineNum > 0 && lastEnding == "" {
+                        lineEnding = targetLineEnding
+                }
+                if err := writeProvenance(lineEnding); err != nil {
+                        return nil, err
+                }
+        }
+
+        if err := writer.Flush(); err != nil {
+                sink.Abort()
+                return nil, fmt.Errorf("error flushing output: %w", err)
+        }
+        if err := sink.Close(); err != nil {
+                return nil, err
+        }
+
+        stats.OriginalBytes = input.n
+        stats.OutputBytes = output.n
+        stats.InputHash = hex.EncodeToString(inputHasher.Sum(nil))
+        stats.OutputHash = hex.EncodeToString(hasher.Sum(nil))
+        return stats, nil
+}
+
+// normalizeLineEndings replaces the terminator of a single cleaned line
+// with the target ending. The terminator is the one the line reader found,
+// so lines are converted exactly once whatever mix of endings the input has.
+// A terminator removed during cleaning (-normalize without
+// -preserve-newlines) is left removed.
+func normalizeLineEndings(line, ending, targetEnding string) (string, bool) {
+        if ending == "" || ending == targetEnding || !strings.HasSuffix(line, ending) {
+                return line, false
+        }
+        return strings.TrimSuffix(line, ending) + targetEnding, true
+}
+
+// countLineBreaks counts the line terminators in s the way lineReader
+// splits lines, so a CRLF pair counts once
+func countLineBreaks(s string) int {
+        return strings.Count(s, "\n") + strings.Count(s, "\r") - strings.Count(s, "\r\n")
+}
+
+// trimTrailingWhitespace removes spaces and tabs before the line terminator
+// and returns the number of characters removed
+func trimTrailingWhitespace(line, ending string) (string, int) {
+        content, hasEnding := strings.CutSuffix(line, ending)
+        if !hasEnding {
+                content, ending = line, ""
+        }
+        trimmed := strings.TrimRight(content, " \t")
+        return trimmed + ending, len(content) - len(trimmed)
+}
+
+func cleanString(s string, options CleaningOptions) (string, *CleaningStats) {
+        stats := &CleaningStats{
+                RemovedCharDetails: make(map[rune]int),
+        }
+        var result strings.Builder
+        result.Grow(len(s))
+
+        if len(s) == 0 {
+                return s, stats
+        }
+        // Nothing but -normalize changes printable ASCII, tabs or line
+        // breaks, so such text is copied without decoding it
+        if options.Trace == nil && !options.NormalizeWhitespace && isPlainASCII(s) {
+                stats.TotalChars = len(s)
+                return s, stats
+        }
+
+        runes := []rune(s)
+
+        startIdx := 0
+        if options.RemoveBOM && len(runes) > 0 && runes[0] == '\uFEFF' {
+                startIdx = 1
+                stats.RemovedChars++
+                stats.ZeroWidthRemoved++
+                stats.TotalChars++
+                stats.RemovedCharDetails['\uFEFF']++
+                stats.recordLocation(options, 0, '\uFEFF')
+                options.traceRemoved(0, '\uFEFF', "-bom")
+        }
+
+        // A combining mark is kept with the letter it belongs to
+        afterLetter := false
+        for i := startIdx; i < len(runes); i++ {
+                r := runes[i]
+                stats.TotalChars++
+                if options.InvalidUTF8 == "keep" && isRawByte(r) {
+                        // An invalid byte kept by -invalid-utf8 keep
+                        afterLetter = false
+                        result.WriteRune(r)
+                        continue
+                }
+                shouldKeep := true
+                allowed := len(options.AllowRanges) > 0 && options.allowed(r)
+                letter := unicode.IsLetter(r) || afterLetter && unicode.IsMark(r)
+                afterLetter = letter
+                if (options.Emoji == "remove" || options.Emoji == "shortcode") && !allowed {
+                        if n := emojiLength(runes, i); n > 0 {
+                                stats.TotalChars += n - 1
+                                afterLetter = false
+                                if name, ok := emojiShortcode(runes[i : i+n]); ok && options.Emoji == "shortcode" {
+                                        result.WriteString(":" + name + ":")
+                                        stats.EmojiConverted++
+                                        for j, e := range runes[i : i+n] {
+                                                options.trace(i+j, e, "converted to :"+name+": (-emoji shortcode)")
+                                        }
+                                        i += n - 1
+                                        continue
+                                }
+                                // Emoji without a shortcode are removed
+                                stats.RemovedChars += n
+                                stats.EmojiRemoved++
+                                stats.EmojiCharsRemoved += n
+                                for j, e := range runes[i : i+n] {
+                                        stats.RemovedCharDetails[e]++
+                                        stats.recordLocation(options, i+j, e)
+                                        options.traceRemoved(i+j, e, "-emoji "+options.Emoji)
+                                }
+                                result.WriteString(options.Replacement)
+                                i += n - 1
+                                continue
+                        }
+                }
+                if options.StripModifiers && !allowed &&
+                        (isVariationSelector(r) || isEmojiModifier(r) || r == zeroWidthJoiner && isStrayJoiner(runes, i)) {
+                        stats.RemovedChars++
+                        stats.ModifiersRemoved++
+                        stats.RemovedCharDetails[r]++
+                        stats.recordLocation(options, i, r)
+                        options.traceRemoved(i, r, "-strip-modifiers")
+                        result.WriteString(options.Replacement)
+                        continue
+                }
+                if r == softHyphen && !allowed {
+                        switch options.SoftHyphens {
+                        case "remove":
+                                stats.RemovedChars++
+                                stats.SoftHyphensRemoved++
+                                stats.RemovedCharDetails[r]++
+                                stats.recordLocation(options, i, r)
+                                options.traceRemoved(i, r, "-soft-hyphens remove")
+                                result.WriteString(options.Replacement)
+                                continue
+                        case "hyphen":
+                                stats.SoftHyphensConverted++
+                                options.trace(i, r, "replaced with '-' (-soft-hyphens hyphen)")
+                                result.WriteRune('-')
+                                continue
+                        }
+                }
+                if options.Dashes != "" && isDash(r) && !allowed {
+                        if options.Dashes == "keep" {
+                                options.trace(i, r, "kept (-dashes keep)")
+                                result.WriteRune(r)
+                                continue
+                        }
+                        replacement := dashReplacement(r, options.Dashes)
+                        stats.DashesConverted++
+                        options.trace(i, r, fmt.Sprintf("replaced with %q (-dashes %s)", replacement, options.Dashes))
+                        result.WriteString(replacement)
+                        continue
+                }
+                if options.Spaces && r != ' ' && unicode.Is(unicode.Zs, r) && !allowed {
+                        stats.SpacesConverted++
+                        options.trace(i, r, "replaced with a space (-spaces)")
+                        result.WriteRune(' ')
+                        continue
+                }
+                if allowed && (options.RemoveZeroWidth && isZeroWidth(r) || options.RemoveNonASCII && r > 127 ||
+                        options.RemoveControlChars && unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t') {
+                        stats.AllowedKept++
+                }
+
+                if options.RemoveZeroWidth && isZeroWidth(r) && !allowed {
+                        stats.RemovedChars++
+                        stats.ZeroWidthRemoved++
+                        stats.RemovedCharDetails[r]++
+                        stats.recordLocation(options, i, r)
+                        options.traceRemoved(i, r, "-zerowidth")
+                        result.WriteString(options.Replacement)
+                        shouldKeep = false
+                        continue
+                }
+
+                if options.SecurityProfile != "" && isRestricted(r) && !allowed {
+                        stats.RemovedChars++
+                        stats.RestrictedRemoved++
+                        stats.RemovedCharDetails[r]++
+                        stats.recordLocation(options, i, r)
+                        options.traceRemoved(i, r, "-preset "+options.SecurityProfile)
+                        result.WriteString(options.Replacement)
+                        continue
+                }
+
+                if options.RemoveNonASCII && r > 127 && !allowed && !(options.KeepLetters && letter) {
+                        if r == '\n' || r == '\r' {
+                                result.WriteRune(r)
+                                options.trace(i, r, "kept (line break)")
+                                continue
+                        }
+                        stats.RemovedChars++
+                        stats.NonASCIIRemoved++
+                        stats.RemovedCharDetails[r]++
+                        stats.recordLocation(options, i, r)
+                        options.traceRemoved(i, r, "-ascii")
+                        result.WriteString(options.Replacement)
+                        shouldKeep = false
+                        continue
+                }
+
+                if options.RemoveControlChars && unicode.IsControl(r) && !allowed {
+                        if r == '\n' || r == '\r' || r == '\t' {
+                                result.WriteRune(r)
+                                options.trace(i, r, "kept (line break or tab)")
+                                continue
+                        }
+                        stats.RemovedChars++
+                        stats.ControlCharsRemoved++
+                        stats.RemovedCharDetails[r]++
+                        stats.recordLocation(options, i, r)
+                        options.traceRemoved(i, r, "-control")
+                        result.WriteString(options.Replacement)
+                        shouldKeep = false
+                        continue
+                }
+
+                if options.NormalizeWhitespace && unicode.IsSpace(r) {
+                        if (r == '\n' || r == '\r') && options.PreserveNewlines {
+                                result.WriteRune(r)
+                                options.trace(i, r, "kept (-preserve-newlines)")
+                                continue
+                        }
+                        if (r == '\n' || r == '\r') && !options.PreserveNewlines {
+                                options.trace(i, r, "removed (-normalize -preserve-newlines=false)")
+                                continue
+                        }
+                        if r != ' ' {
+                                result.WriteRune(' ')
+                                options.trace(i, r, "replaced with a space (-normalize)")
+                                continue
+                        }
+                }
+
+                if shouldKeep {
+                        result.WriteRune(r)
+                        if options.Trace != nil {
+                                options.trace(i, r, keptReason(r, allowed, letter, options))
+                        }
+                }
+        }
+
+        if len(stats.removed) > 0 {
+                stats.WordsAffected = affectedWords(runes, stats.removed, options.Replacement)
+        }
+        return result.String(), stats
+}
+
+// parseCSVDelimiter accepts a single character or "tab"; an empty value
+// selects the delimiter by file extension
+func parseCSVDelimiter(value string) (rune, error) {
+        if value == "" {
+                return 0, nil
+        }
+        if strings.EqualFold(value, "tab") || value == `\t` {
+                return '\t', nil
+        }
+        r, size := utf8.DecodeRuneInString(value)
+        if size == 0 || size != len(value) || r == '"' || r == '\n' || r == '\r' {
+                return 0, fmt.Errorf("invalid CSV delimiter '%s' (expected a single character or 'tab')", value)
+        }
+        return r, nil
+}
+
+// parseReplacement accepts a single character, written literally or as
+// U+XXXX, or the empty string for deleting removed characters
+func parseReplacement(value string) (string, error) {
+        if hex, ok := strings.CutPrefix(strings.ToUpper(value), "U+"); ok && len(hex) >= 4 {
+                code, err := strconv.ParseUint(hex, 16, 32)
+                if err != nil || !utf8.ValidRune(rune(code)) {
+                        return "", fmt.Errorf("invalid replacement character '%s'", value)
+                }
+                return string(rune(code)), nil
+        }
+        if utf8.RuneCountInString(value) > 1 {
+                return "", fmt.Errorf("the replacement must be a single character, got '%s'", value)
+        }
+        return value, nil
+}
+
+// recordLocation notes the position of a removed character within a line
+func (s *CleaningStats) recordLocation(options CleaningOptions, index int, char rune) {
+        if options.RecordLocations && (len(s.Locations) < maxLocations || options.Quarantine) {
+                s.Locations = append(s.Locations, CharLocation{Column: index + 1, Char: char})
+        }
+        if options.RecordWords {
+                s.removed = append(s.removed, index)
+        }
+}
+
+// byteIndex returns the byte offset of the character at index chars of s
+func byteIndex(s string, chars int) int {
+        for i := range s {
+                if chars == 0 {
+                        return i
+                }
+                chars--
+        }
+        return len(s)
+}
+
+func isZeroWidth(r rune) bool {
+        for _, zw := range zeroWidthChars {
+                if r == zw {
+                        return true
+                }
+        }
+        return false
+}
+
+// mergeStats adds the counters of src into dst
+func mergeStats(dst, src *CleaningStats) {
+        dst.OriginalChars += src.OriginalChars
+        dst.TotalChars += src.TotalChars
+        dst.OutputChars += src.OutputChars
+        dst.OriginalBytes += src.OriginalBytes
+        dst.OutputBytes += src.OutputBytes
+        dst.RemovedChars += src.RemovedChars
+        dst.NonASCIIRemoved += src.NonASCIIRemoved
+        dst.EmojiRemoved += src.EmojiRemoved
+        dst.EmojiCharsRemoved += src.EmojiCharsRemoved
+        dst.EmojiConverted += src.EmojiConverted
+        dst.ModifiersRemoved += src.ModifiersRemoved
+        dst.SoftHyphensRemoved += src.SoftHyphensRemoved
+        dst.SoftHyphensConverted += src.SoftHyphensConverted
+        dst.SpacesConverted += src.SpacesConverted
+        dst.ReplyMarkersNormalized += src.ReplyMarkersNormalized
+        dst.ReactionLinesDropped += src.ReactionLinesDropped
+        dst.ControlCharsRemoved += src.ControlCharsRemoved
+        dst.ZeroWidthRemoved += src.ZeroWidthRemoved
+        dst.RestrictedRemoved += src.RestrictedRemoved
+        dst.MixedScriptWords += src.MixedScriptWords
+        dst.ConfusableWords += src.ConfusableWords
+        dst.LinesProcessed += src.LinesProcessed
+        dst.LinesWithIssues += src.LinesWithIssues
+        dst.LineEndingsConverted += src.LineEndingsConverted
+        dst.LineEndingConversions = mergeCounts(dst.LineEndingConversions, src.LineEndingConversions)
+        dst.OriginalLineEndings = mergeCounts(dst.OriginalLineEndings, src.OriginalLineEndings)
+        dst.StrippedConstructs = mergeCounts(dst.StrippedConstructs, src.StrippedConstructs)
+        dst.HTMLEntitiesDecoded += src.HTMLEntitiesDecoded
+        dst.UnicodeNormalized += src.UnicodeNormalized
+        dst.AllowedKept += src.AllowedKept
+        dst.QuotesNormalized += src.QuotesNormalized
+        dst.PunctuationConverted += src.PunctuationConverted
+        dst.StrayQuotesRemoved += src.StrayQuotesRemoved
+        dst.MojibakeRepaired += src.MojibakeRepaired
+        dst.Transliterated += src.Transliterated
+        dst.InvalidUTF8Sequences += src.InvalidUTF8Sequences
+        dst.ReplacementCharsAdded += src.ReplacementCharsAdded
+        if src.InvalidUTF8Action != "" {
+                dst.InvalidUTF8Action = src.InvalidUTF8Action
+        }
+        dst.DashesConverted += src.DashesConverted
+        dst.FormulasEscaped += src.FormulasEscaped
+        dst.NewlinesEscaped += src.NewlinesEscaped
+        dst.EscapesDecoded += src.EscapesDecoded
+        if src.Replacement != "" {
+                dst.Replacement = src.Replacement
+        }
+        dst.TrailingWhitespaceTrimmed += src.TrailingWhitespaceTrimmed
+        dst.MarkdownStripped = dst.MarkdownStripped || src.MarkdownStripped
+        dst.HTMLStripped = dst.HTMLStripped || src.HTMLStripped
+        dst.SubtitlesStripped = dst.SubtitlesStripped || src.SubtitlesStripped
+
+        for char, count := range src.RemovedCharDetails {
+                dst.RemovedCharDetails[char] += count
+        }
+}
+
+// mergeCounts adds src into dst, allocating dst if needed
+func mergeCounts(dst, src map[string]int) map[string]int {
+        if len(src) == 0 {
+                return dst
+        }
+        if dst == nil {
+                dst = make(map[string]int)
+        }
+        for key, count := range src {
+                dst[key] += count
+        }
+        return dst
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+        "go/parser"
+        "go/token"
+        "os"
+        "strconv"
+        "strings"
+        "testing"
+)
+
+// TestEngineFiles checks that the files of the js/wasm build use neither
+// the file system, processes and the network nor the flags of the command
+func TestEngineFiles(t *testing.T) {
+        data, err := os.ReadFile("../tools/wasm/engine.txt")
+        if err != nil {
+                t.Fatal(err)
+        }
+        denied := []string{"os", "os/exec", "os/signal", "flag", "net", "net/http", "net/url", "syscall", "runtime/debug"}
+        for _, name := range strings.Fields(string(data)) {
+                f, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.ImportsOnly)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                for _, imp := range f.Imports {
+                        path, _ := strconv.Unquote(imp.Path.Value)
+                        if containsString(denied, path) {
+                                t.Errorf("%s imports %s", name, path)
+                        }
+                }
+        }
+}
+
+// TestOptionDefaults checks that the flags of clean have the defaults of
+// the options of the engine
+func TestOptionDefaults(t *testing.T) {
+        f := newCleanFlags("clean")
+        for name, def := range optionDefaults {
+                fl := f.fs.Lookup(name)
+                if fl == nil {
+                        t.Errorf("clean has no flag -%s", name)
+                } else if fl.DefValue != def {
+                        t.Errorf("-%s defaults to %q, the engine to %q", name, fl.DefValue, def)
+                }
+        }
+}
+
+func TestNewCleaningOptions(t *testing.T) {
+        tests := []struct {
+                given map[string]string
+                check func(CleaningOptions) bool
+                err   string
+        }{
+                {nil, func(o CleaningOptions) bool { return o.RemoveNonASCII && o.SoftHyphens == "remove" && !o.NoLegacyGuess }, ""},
+                {map[string]string{"profile": "chat"}, func(o CleaningOptions) bool { return o.Chat && !o.RemoveNonASCII }, ""},
+                {map[string]string{"profile": "chat", "ascii": "true"}, func(o CleaningOptions) bool { return o.Chat && o.RemoveNonASCII }, ""},
+                {map[string]string{"mode": "aggressive"}, func(o CleaningOptions) bool { return o.Transliterate && o.NormalizeUnicode == "nfkc" }, ""},
+                {map[string]string{"add-bom": "1"}, func(o CleaningOptions) bool { return o.EnsureBOM }, ""},
+                {map[string]string{"invalid-utf8": "replace"}, func(o CleaningOptions) bool { return o.NoLegacyGuess }, ""},
+                {map[string]string{"config": "x.yaml"}, nil, "unknown option 'config'"},
+                {map[string]string{"ascii": "maybe"}, nil, "invalid value 'maybe' for option 'ascii'"},
+                {map[string]string{"drop-reactions": "true"}, nil, "-drop-reactions requires -chat"},
+                {map[string]string{"mode": "gentle"}, nil, "invalid mode 'gentle'"},
+        }
+        for _, test := range tests {
+                options, err := newCleaningOptions(test.given)
+                switch {
+                case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+                        t.Errorf("%v: error %v, want %q", test.given, err, test.err)
+                case test.err == "" && err != nil:
+                        t.Errorf("%v: %v", test.given, err)
+                case test.check != nil && !test.check(options):
+                        t.Errorf("%v: unexpected options %+v", test.given, options)
+                }
+        }
+}
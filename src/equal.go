@@ -0,0 +1,144 @@
+package main
+
+import (
+        "bytes"
+        "fmt"
+        "io"
+        "os"
+        "strings"
+)
+
+// runEqual implements the equal subcommand: it cleans two files with the
+// options of clean, and reports whether the results are identical. The
+// exit status is 0 if they are, 1 if they are not and 2 on errors.
+func runEqual(args []string) int {
+        f := newCleanFlags("equal")
+        fs := f.fs
+        maxLines := fs.Int("max-lines", 20, "Number of differing lines to show (0 = all)")
+        paths := parseInterspersed(fs, args)
+        if len(paths) != 2 {
+                exitWithUsage(fs, "two files are required")
+        }
+
+        cfg, err := loadConfig(configPaths(*f.configFile))
+        if err == nil {
+                err = applyConfig(fs, f.unused, cfg, *f.profile)
+        }
+        var editorConfig *editorConfigResolver
+        if *f.useEditorConfig {
+                editorConfig = newEditorConfigResolver(explicitFlags(fs))
+        }
+        if err == nil {
+                err = applyPreset(fs, *f.preset)
+        }
+        if err == nil {
+                err = applyCleaningMode(fs, *f.cleaningMode)
+        }
+        if err == nil {
+                err = setupColor(*f.colorMode)
+        }
+        var options CleaningOptions
+        if err == nil {
+                options, err = f.options()
+        }
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 2
+        }
+
+        var cleaned [2][]byte
+        var stats [2]*CleaningStats
+        for i, path := range paths {
+                fileOptions, err := editorConfig.options(path, options)
+                if err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        return 2
+                }
+                sink := &BufferSink{}
+                stats[i], err = cleanFile(path, sink, fileOptions, false)
+                if err != nil {
+                        fmt.Printf("Error: %s: %v\n", path, err)
+                        return 2
+                }
+                cleaned[i] = sink.Buffer.Bytes()
+        }
+
+        if bytes.Equal(cleaned[0], cleaned[1]) {
+                fmt.Println(colorize(fmt.Sprintf("%s and %s are equal after cleaning", paths[0], paths[1]), ansiGreen))
+                for i, path := range paths {
+                        if stats[i].changed() {
+                                fmt.Printf("   %s: %d character(s) removed, %d line ending(s) converted\n",
+                                        path, stats[i].RemovedChars, stats[i].LineEndingsConverted)
+                        }
+                }
+                return 0
+        }
+
+        fmt.Println(colorize(fmt.Sprintf("%s and %s differ after cleaning", paths[0], paths[1]), ansiRed))
+        printLineDiff(os.Stdout, paths, splitLines(cleaned[0]), splitLines(cleaned[1]), *maxLines)
+        // splitLines drops the line ending after the last line, so a file
+        // that differs only there would show no line at all
+        if ends := [2]bool{endsWithLineBreak(cleaned[0]), endsWithLineBreak(cleaned[1])}; ends[0] != ends[1] {
+                with, without := paths[0], paths[1]
+                if ends[1] {
+                        with, without = without, with
+                }
+                fmt.Printf("%s ends with a line ending after its last line, %s does not\n", with, without)
+        }
+        return 1
+}
+
+// endsWithLineBreak reports whether cleaned content ends with a line
+// ending, of whichever -os
+func endsWithLineBreak(content []byte) bool {
+        return bytes.HasSuffix(content, []byte("\n")) || bytes.HasSuffix(content, []byte("\r"))
+}
+
+// splitLines splits cleaned content into lines without their endings,
+// which cleaning has made the same in both files
+func splitLines(content []byte) []string {
+        text := strings.TrimSuffix(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+        if text == "" {
+                return nil
+        }
+        return strings.Split(text, "\n")
+}
+
+// visibleLine shows the invisible characters that remain in a line as
+// <U+XXXX>, as audit does in its context
+func visibleLine(line string) string {
+        var b strings.Builder
+        for _, r := range line {
+                if isInvisible(r) {
+                        fmt.Fprintf(&b, "<U+%04X>", r)
+                } else {
+                        b.WriteRune(r)
+                }
+        }
+        return b.String()
+}
+
+// printLineDiff lists the lines that differ between a and b, as "-" lines
+// of the first file and "+" lines of the second with their line numbers,
+// at most maxLines of them unless maxLines is 0
+func printLineDiff(w io.Writer, paths []string, a, b []string, maxLines int) {
+        var changes []diffOp
+        for _, op := range diffLines(a, b) {
+                if op.Kind != ' ' {
+                        changes = append(changes, op)
+                }
+        }
+
+        fmt.Fprintf(w, "--- %s\n+++ %s\n", paths[0], paths[1])
+        for n, op := range changes {
+                if maxLines > 0 && n == maxLines {
+                        fmt.Fprintf(w, "... %d more differing line(s)\n", len(changes)-n)
+                        break
+                }
+                if op.Kind == '-' {
+                        fmt.Fprintln(w, colorize(fmt.Sprintf("-%6d | %s", op.A+1, visibleLine(a[op.A])), ansiRed))
+                } else {
+                        fmt.Fprintln(w, colorize(fmt.Sprintf("+%6d | %s", op.B+1, visibleLine(b[op.B])), ansiGreen))
+                }
+        }
+}
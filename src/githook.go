@@ -0,0 +1,195 @@
+package main
+
+import (
+        "bytes"
+        "fmt"
+        "os"
+        "os/exec"
+        "path/filepath"
+        "regexp"
+        "strconv"
+        "strings"
+)
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -[0-9,]+ \+([0-9]+)(?:,([0-9]+))? @@`)
+
+// runGitHook cleans (or with checkOnly, checks) the files staged for the
+// next commit and restages the ones that changed. It is meant to run as a
+// pre-commit hook. Files with unstaged changes are refused in fix mode,
+// since restaging them would also commit changes the user did not stage.
+func runGitHook(batch BatchOptions, options CleaningOptions, checkOnly, verbose bool) ([]FileResult, error) {
+        files, err := stagedFiles(batch)
+        if err != nil {
+                return nil, err
+        }
+
+        if !checkOnly {
+                unstaged, err := gitPaths("diff", "--name-only", "-z")
+                if err != nil {
+                        return nil, err
+                }
+                var partial []string
+                for _, path := range files {
+                        if unstaged[path] {
+                                partial = append(partial, path)
+                        }
+                }
+                if len(partial) > 0 {
+                        return nil, fmt.Errorf("cannot clean partially staged files (stage or stash their remaining changes, or use -check): %s",
+                                strings.Join(partial, ", "))
+                }
+        }
+
+        batch.CheckOnly = checkOnly
+        batch.InPlace = !checkOnly
+        results, err := cleanFiles(files, batch, options, false, verbose)
+        if err != nil {
+                return nil, err
+        }
+
+        if !checkOnly {
+                var changed []string
+                for _, r := range results {
+                        if r.Stats.changed() {
+                                changed = append(changed, r.InputPath)
+                        }
+                }
+                if len(changed) > 0 {
+                        if _, err := git(append([]string{"add", "--"}, changed...)...); err != nil {
+                                return nil, err
+                        }
+                }
+        }
+        return results, nil
+}
+
+// stagedFiles lists the text files added, copied or modified in the index,
+// relative to the current directory and filtered by the include and exclude
+// patterns (which match paths relative to the repository root). Files git
+// considers binary are skipped.
+func stagedFiles(batch BatchOptions) ([]string, error) {
+        cdup, err := git("rev-parse", "--show-cdup")
+        if err != nil {
+                return nil, err
+        }
+        cdup = strings.TrimSpace(cdup)
+
+        out, err := git("diff", "--cached", "--numstat", "--no-renames", "--diff-filter=ACM", "-z")
+        if err != nil {
+                return nil, err
+        }
+
+        var files []string
+        for _, entry := range strings.Split(out, "\x00") {
+                // Each entry is "added<TAB>deleted<TAB>path"; binary files
+                // have "-" for both counts
+                fields := strings.SplitN(entry, "\t", 3)
+                if len(fields) != 3 || fields[0] == "-" {
+                        continue
+                }
+                name := fields[2]
+
+                if isCleanfileArtifact(name) {
+                        continue
+                }
+                if len(batch.Include) > 0 && !matchesAny(batch.Include, name) {
+                        continue
+                }
+                if matchesAny(batch.Exclude, name) {
+                        continue
+                }
+
+                path := filepath.Join(cdup, filepath.FromSlash(name))
+                if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+                        continue
+                }
+                files = append(files, path)
+        }
+        return files, nil
+}
+
+// gitPaths runs a git command that prints NUL-separated repository paths
+// and returns them relative to the current directory
+func gitPaths(args ...string) (map[string]bool, error) {
+        cdup, err := git("rev-parse", "--show-cdup")
+        if err != nil {
+                return nil, err
+        }
+        out, err := git(args...)
+        if err != nil {
+                return nil, err
+        }
+
+        paths := make(map[string]bool)
+        for _, name := range strings.Split(out, "\x00") {
+                if name != "" {
+                        paths[filepath.Join(strings.TrimSpace(cdup), filepath.FromSlash(name))] = true
+                }
+        }
+        return paths, nil
+}
+
+// changedLines returns the line numbers of path that were added or modified
+// since the last commit, according to git. It returns nil (meaning every
+// line) for files git does not track yet and in repositories without
+// commits.
+func changedLines(path string) (map[int]bool, error) {
+        dir, name := filepath.Split(path)
+        if dir == "" {
+                dir = "."
+        }
+
+        if _, err := git("-C", dir, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+                if _, err := git("-C", dir, "rev-parse", "--git-dir"); err != nil {
+                        return nil, err
+                }
+                return nil, nil
+        }
+        tracked, err := git("-C", dir, "ls-files", "--", name)
+        if err != nil {
+                return nil, err
+        }
+        if strings.TrimSpace(tracked) == "" {
+                return nil, nil
+        }
+
+        out, err := git("-C", dir, "diff", "-U0", "--no-color", "--no-ext-diff", "HEAD", "--", name)
+        if err != nil {
+                return nil, err
+        }
+
+        lines := make(map[int]bool)
+        for _, line := range strings.Split(out, "\n") {
+                m := hunkHeaderPattern.FindStringSubmatch(line)
+                if m == nil {
+                        continue
+                }
+                start, _ := strconv.Atoi(m[1])
+                count := 1
+                if m[2] != "" {
+                        count, _ = strconv.Atoi(m[2])
+                }
+                for n := start; n < start+count; n++ {
+                        lines[n] = true
+                }
+        }
+        return lines, nil
+}
+
+func git(args ...string) (string, error) {
+        var stdout, stderr bytes.Buffer
+        cmd := exec.Command("git", args...)
+        cmd.Stdout = &stdout
+        cmd.Stderr = &stderr
+        if err := cmd.Run(); err != nil {
+                command := args[0]
+                if command == "-C" && len(args) > 2 {
+                        command = args[2]
+                }
+                if msg := strings.TrimSpace(stderr.String()); msg != "" {
+                        return "", fmt.Errorf("git %s: %s", command, msg)
+                }
+                return "", fmt.Errorf("git %s: %w", command, err)
+        }
+        return stdout.String(), nil
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+        "encoding/binary"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "net/http"
+        "net/url"
+        "os"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// grpcServicePath is the path prefix of the methods of the Cleaner service
+// of cleanfile.proto
+const grpcServicePath = "/cleanfile.v1.Cleaner/"
+
+// grpcPieceSize is the largest piece of cleaned text in a CleanResponse
+const grpcPieceSize = 64 * 1024
+
+// gRPC status codes
+const (
+        grpcOK                = 0
+        grpcInvalidArgument   = 3
+        grpcResourceExhausted = 8
+        grpcUnimplemented     = 12
+        grpcInternal          = 13
+)
+
+// grpcError is an error with the gRPC status code it ends a call with
+type grpcError struct {
+        code int
+        err  error
+}
+
+func (e *grpcError) Error() string { return e.err.Error() }
+
+// cleanRequest is a decoded CleanRequest
+type cleanRequest struct {
+        Content []byte
+        Options map[string]string
+        Name    string
+}
+
+func decodeCleanRequest(data []byte) (cleanRequest, error) {
+        req := cleanRequest{Options: make(map[string]string)}
+        err := protoFields(data, func(field, wire int, v uint64, b []byte) error {
+                if wire != wireBytes {
+                        return nil
+                }
+                switch field {
+                case 1:
+                        req.Content = b
+                case 2:
+                        var key, value string
+                        err := protoFields(b, func(field, wire int, v uint64, b []byte) error {
+                                switch {
+                                case field == 1 && wire == wireBytes:
+                                        key = string(b)
+                                case field == 2 && wire == wireBytes:
+                                        value = string(b)
+                                }
+                                return nil
+                        })
+                        if err != nil {
+                                return err
+                        }
+                        req.Options[key] = value
+                case 3:
+                        req.Name = string(b)
+                }
+                return nil
+        })
+        if err != nil {
+                return cleanRequest{}, fmt.Errorf("invalid CleanRequest: %w", err)
+        }
+        return req, nil
+}
+
+// values returns the options of a request as those of a /clean request
+func (req cleanRequest) values() url.Values {
+        values := url.Values{}
+        for key, value := range req.Options {
+                values.Set(key, value)
+        }
+        return values
+}
+
+// handleGRPC answers the gRPC calls of the Cleaner service. The requests
+// of a call are received in full, their content going to a temporary file
+// as for /clean, before the text is cleaned; the cleaned text of Clean is
+// sent back as it is written.
+func (s *cleanServer) handleGRPC(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost || r.ProtoMajor != 2 || !isGRPCContentType(r.Header.Get("Content-Type")) {
+                writeServeError(w, http.StatusUnsupportedMediaType, errors.New("expected a gRPC call over HTTP/2"))
+                return
+        }
+        w.Header().Set("Content-Type", "application/grpc")
+        method := strings.TrimPrefix(r.URL.Path, grpcServicePath)
+        if method != "Clean" && method != "Check" && method != "Detect" {
+                endGRPC(w, &grpcError{grpcUnimplemented, fmt.Errorf("unknown method %s", method)})
+                return
+        }
+        r.Body = http.MaxBytesReader(w, r.Body, s.MaxUpload)
+
+        tmp, err := os.CreateTemp("", "cleanfile-serve-*")
+        if err != nil {
+                endGRPC(w, &grpcError{grpcInternal, err})
+                return
+        }
+        defer os.Remove(tmp.Name())
+        defer tmp.Close()
+        req, err := s.receiveGRPC(r.Body, tmp)
+        if err != nil {
+                endGRPC(w, err)
+                return
+        }
+        if req.Name == "" {
+                req.Name = "(request)"
+        }
+
+        switch method {
+        case "Clean":
+                err = s.grpcClean(w, r, tmp.Name(), req)
+        case "Check":
+                err = s.grpcCheck(w, r, tmp.Name(), req)
+        case "Detect":
+                err = grpcDetect(w, tmp.Name(), req)
+        }
+        endGRPC(w, err)
+}
+
+// receiveGRPC reads the CleanRequest messages of a call, writing their
+// content to dst, and returns the first with its content removed
+func (s *cleanServer) receiveGRPC(body io.Reader, dst io.Writer) (cleanRequest, error) {
+        var first cleanRequest
+        for n := 0; ; n++ {
+                data, err := s.readGRPCMessage(body)
+                if err == io.EOF {
+                        return first, nil
+                }
+                if err != nil {
+                        return cleanRequest{}, err
+                }
+                req, err := decodeCleanRequest(data)
+                if err != nil {
+                        return cleanRequest{}, &grpcError{grpcInvalidArgument, err}
+                }
+                if _, err := dst.Write(req.Content); err != nil {
+                        return cleanRequest{}, &grpcError{grpcInternal, err}
+                }
+                if n == 0 {
+                        first = req
+                        first.Content = nil
+                }
+        }
+}
+
+// readGRPCMessage reads the next length-prefixed message of a call, or
+// returns io.EOF at the end of the call
+func (s *cleanServer) readGRPCMessage(body io.Reader) ([]byte, error) {
+        var header [5]byte
+        _, err := io.ReadFull(body, header[:])
+        if err == nil && header[0] != 0 {
+                err = &grpcError{grpcUnimplemented, errors.New("compressed messages are not supported")}
+        }
+        var data []byte
+        if err == nil {
+                length := binary.BigEndian.Uint32(header[1:])
+                if int64(length) > s.MaxUpload {
+                        return nil, &grpcError{grpcResourceExhausted, fmt.Errorf("message is larger than %s", formatSize(s.MaxUpload))}
+                }
+                data = make([]byte, length)
+                _, err = io.ReadFull(body, data)
+        }
+
+        var tooLarge *http.MaxBytesError
+        switch {
+        case err == nil || err == io.EOF:
+                return data, err
+        case errors.As(err, &tooLarge):
+                return nil, &grpcError{grpcResourceExhausted, fmt.Errorf("call is larger than %s", formatSize(s.MaxUpload))}
+        case errors.As(err, new(*grpcError)):
+                return nil, err
+        default:
+                return nil, &grpcError{grpcInvalidArgument, fmt.Errorf("could not read message: %w", err)}
+        }
+}
+
+func (s *cleanServer) grpcClean(w http.ResponseWriter, r *http.Request, path string, req cleanRequest) error {
+        start := time.Now()
+        options, err := s.options(req.values())
+        if err != nil {
+                return &grpcError{grpcInvalidArgument, err}
+        }
+        stats, err := cleanFile(path, &grpcSink{w: w}, options, false)
+        result := FileResult{InputPath: req.Name, OutputPath: "(response)", Stats: stats, Err: err}
+        s.observeGRPC(r, "Clean", result, start)
+        if err != nil {
+                return &grpcError{grpcInvalidArgument, err}
+        }
+
+        var response protoMessage
+        response.embed(2, grpcReport(result, options.TargetOS, false))
+        return writeGRPCMessage(w, response)
+}
+
+func (s *cleanServer) grpcCheck(w http.ResponseWriter, r *http.Request, path string, req cleanRequest) error {
+        start := time.Now()
+        options, err := s.options(req.values())
+        if err != nil {
+                return &grpcError{grpcInvalidArgument, err}
+        }
+        stats, err := cleanFile(path, &discardSink{}, options, false)
+        result := FileResult{InputPath: req.Name, Stats: stats, Err: err}
+        s.observeGRPC(r, "Check", result, start)
+        if err != nil {
+                return &grpcError{grpcInvalidArgument, err}
+        }
+
+        var response protoMessage
+        response.bool(1, stats.changed())
+        response.embed(2, grpcReport(result, options.TargetOS, true))
+        return writeGRPCMessage(w, response)
+}
+
+func grpcDetect(w http.ResponseWriter, path string, req cleanRequest) error {
+        if len(req.Options) > 0 {
+                return &grpcError{grpcInvalidArgument, errors.New("Detect takes no options")}
+        }
+        d := detectFile(path)
+        if d.Error != "" {
+                return &grpcError{grpcInvalidArgument, errors.New(d.Error)}
+        }
+
+        var response protoMessage
+        response.string(1, d.Format)
+        response.string(2, d.Encoding)
+        response.bool(3, d.BOM)
+        response.countMap(4, d.LineEndings)
+        response.int(5, int64(d.Lines))
+        response.int(6, int64(d.Chars))
+        response.countMap(7, d.Removable)
+        return writeGRPCMessage(w, response)
+}
+
+// observeGRPC counts a cleaned text in the metrics and logs it
+func (s *cleanServer) observeGRPC(r *http.Request, method string, result FileResult, start time.Time) {
+        s.Metrics.observe(result, time.Since(start))
+        if s.Verbose {
+                fmt.Printf("%s gRPC %s %s: %v\n", r.RemoteAddr, method, result.InputPath, cleanOutcome(result))
+        }
+}
+
+// grpcReport encodes the Report message of a cleaned text
+func grpcReport(result FileResult, targetOS string, checkOnly bool) protoMessage {
+        file := newJSONReport([]FileResult{result}, targetOS, checkOnly, nil).Files[0]
+        data, _ := json.Marshal(file)
+
+        var report protoMessage
+        report.bool(1, file.Changed)
+        report.int(2, int64(file.Stats.LinesProcessed))
+        report.int(3, int64(file.Stats.OriginalBytes))
+        report.int(4, int64(file.Stats.OutputBytes))
+        report.int(5, int64(file.Stats.RemovedChars))
+        for _, f := range file.Findings {
+                var finding protoMessage
+                finding.string(1, f.Codepoint)
+                finding.string(2, f.Name)
+                finding.string(3, f.Category)
+                finding.int(4, int64(f.Count))
+                report.embed(6, finding)
+        }
+        for _, w := range file.Warnings {
+                var warning protoMessage
+                warning.string(1, w.Kind)
+                warning.string(2, w.Message)
+                warning.int(3, int64(w.Line))
+                warning.int(4, int64(w.Count))
+                report.embed(7, warning)
+        }
+        report.string(8, string(data))
+        return report
+}
+
+// grpcSink sends the cleaned text of a Clean call as CleanResponse messages
+// of grpcPieceSize, so that it never has to be held in memory as a whole
+type grpcSink struct {
+        w       http.ResponseWriter
+        pending []byte
+}
+
+func (s *grpcSink) Open() (io.Writer, error) {
+        s.pending = s.pending[:0]
+        return s, nil
+}
+
+func (s *grpcSink) Write(p []byte) (int, error) {
+        s.pending = append(s.pending, p...)
+        for len(s.pending) >= grpcPieceSize {
+                if err := s.send(s.pending[:grpcPieceSize]); err != nil {
+                        return 0, err
+                }
+                s.pending = append(s.pending[:0], s.pending[grpcPieceSize:]...)
+        }
+        return len(p), nil
+}
+
+func (s *grpcSink) send(piece []byte) error {
+        var response protoMessage
+        response.bytes(1, piece)
+        return writeGRPCMessage(s.w, response)
+}
+
+func (s *grpcSink) Close() error {
+        if len(s.pending) == 0 {
+                return nil
+        }
+        return s.send(s.pending)
+}
+
+func (s *grpcSink) Abort()         { s.pending = nil }
+func (s *grpcSink) String() string { return "(response)" }
+
+// writeGRPCMessage sends a length-prefixed message and flushes it, so the
+// client receives each piece as it is written
+func writeGRPCMessage(w http.ResponseWriter, m protoMessage) error {
+        header := make([]byte, 5, 5+len(m))
+        binary.BigEndian.PutUint32(header[1:], uint32(len(m)))
+        if _, err := w.Write(append(header, m...)); err != nil {
+                return err
+        }
+        return http.NewResponseController(w).Flush()
+}
+
+// endGRPC ends a call with the status of err, in the trailers
+func endGRPC(w http.ResponseWriter, err error) {
+        code := grpcOK
+        if err != nil {
+                code = grpcInternal
+                var e *grpcError
+                if errors.As(err, &e) {
+                        code = e.code
+                }
+                w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcMessage(err.Error()))
+        }
+        w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+}
+
+// grpcMessage percent-encodes an error message for the grpc-message trailer
+func grpcMessage(s string) string {
+        var b strings.Builder
+        for i := 0; i < len(s); i++ {
+                if c := s[i]; c < ' ' || c > '~' || c == '%' {
+                        fmt.Fprintf(&b, "%%%02X", c)
+                } else {
+                        b.WriteByte(c)
+                }
+        }
+        return b.String()
+}
+
+// isGRPCContentType tells whether a request is a gRPC call with protocol
+// buffers messages
+func isGRPCContentType(contentType string) bool {
+        contentType, _, _ = strings.Cut(contentType, ";")
+        return contentType == "application/grpc" || contentType == "application/grpc+proto"
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+        "bufio"
+        "encoding/json"
+        "flag"
+        "fmt"
+        "os"
+        "path/filepath"
+        "sort"
+        "strings"
+        "time"
+)
+
+// HistoryRecord is one line of the history store: the summary of cleaning a
+// single file during a single run. Records of the same run share a RunID.
+type HistoryRecord struct {
+        RunID                string    `json:"run_id"`
+        Time                 time.Time `json:"time"`
+        Path                 string    `json:"path"`
+        LinesProcessed       int       `json:"lines_processed"`
+        LinesWithIssues      int       `json:"lines_with_issues"`
+        TotalChars           int       `json:"total_chars"`
+        RemovedChars         int       `json:"removed_chars"`
+        ZeroWidthRemoved     int       `json:"zero_width_removed"`
+        ControlCharsRemoved  int       `json:"control_chars_removed"`
+        NonASCIIRemoved      int       `json:"non_ascii_removed"`
+        LineEndingsConverted int       `json:"line_endings_converted"`
+}
+
+// runSummary aggregates the history records of one run
+type runSummary struct {
+        RunID           string
+        Time            time.Time
+        Files           int
+        FilesWithIssues int
+        TotalChars      int
+        RemovedChars    int
+}
+
+func (r runSummary) removalRate() float64 {
+        if r.TotalChars == 0 {
+                return 0
+        }
+        return float64(r.RemovedChars) / float64(r.TotalChars) * 100
+}
+
+// appendHistory appends one record per cleaned file to the JSON Lines store
+// at storePath, creating it if necessary
+func appendHistory(storePath string, results []FileResult, now time.Time) error {
+        f, err := os.OpenFile(storePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+        if err != nil {
+                return fmt.Errorf("could not open history store: %w", err)
+        }
+        defer f.Close()
+
+        runID := now.UTC().Format(time.RFC3339Nano)
+        writer := bufio.NewWriter(f)
+        encoder := json.NewEncoder(writer)
+
+        for _, r := range results {
+                if r.Err != nil {
+                        continue
+                }
+                path, err := filepath.Abs(r.InputPath)
+                if err != nil {
+                        path = r.InputPath
+                }
+                s := r.Stats
+                record := HistoryRecord{
+                        RunID:                runID,
+                        Time:                 now,
+                        Path:                 path,
+                        LinesProcessed:       s.LinesProcessed,
+                        LinesWithIssues:      s.LinesWithIssues,
+                        TotalChars:           s.TotalChars,
+                        RemovedChars:         s.RemovedChars,
+                        ZeroWidthRemoved:     s.ZeroWidthRemoved,
+                        ControlCharsRemoved:  s.ControlCharsRemoved,
+                        NonASCIIRemoved:      s.NonASCIIRemoved,
+                        LineEndingsConverted: s.LineEndingsConverted,
+                }
+                if err := encoder.Encode(record); err != nil {
+                        return fmt.Errorf("could not write history record: %w", err)
+                }
+        }
+
+        if err := writer.Flush(); err != nil {
+                return fmt.Errorf("could not write history store: %w", err)
+        }
+        return f.Close()
+}
+
+// readHistory loads all records from the store, skipping malformed lines
+func readHistory(storePath string) ([]HistoryRecord, error) {
+        f, err := os.Open(storePath)
+        if err != nil {
+                return nil, fmt.Errorf("could not open history store: %w", err)
+        }
+        defer f.Close()
+
+        var records []HistoryRecord
+        scanner := bufio.NewScanner(f)
+        scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+        for scanner.Scan() {
+                line := strings.TrimSpace(scanner.Text())
+                if line == "" {
+                        continue
+                }
+                var record HistoryRecord
+                if err := json.Unmarshal([]byte(line), &record); err != nil {
+                        continue
+                }
+                records = append(records, record)
+        }
+        if err := scanner.Err(); err != nil {
+                return nil, fmt.Errorf("could not read history store: %w", err)
+        }
+
+        return records, nil
+}
+
+// summarizeRuns groups records under pathPrefix by run, oldest first
+func summarizeRuns(records []HistoryRecord, pathPrefix string) []runSummary {
+        byRun := make(map[string]*runSummary)
+        for _, record := range records {
+                if pathPrefix != "" && !isBelow(record.Path, pathPrefix) {
+                        continue
+                }
+                run, ok := byRun[record.RunID]
+                if !ok {
+                        run = &runSummary{RunID: record.RunID, Time: record.Time}
+                        byRun[record.RunID] = run
+                }
+                run.Files++
+                run.TotalChars += record.TotalChars
+                run.RemovedChars += record.RemovedChars
+                if record.RemovedChars > 0 {
+                        run.FilesWithIssues++
+                }
+        }
+
+        runs := make([]runSummary, 0, len(byRun))
+        for _, run := range byRun {
+                runs = append(runs, *run)
+        }
+        sort.Slice(runs, func(i, j int) bool {
+                return runs[i].Time.Before(runs[j].Time)
+        })
+        return runs
+}
+
+// isBelow reports whether path is dir itself or lies inside dir
+func isBelow(path, dir string) bool {
+        return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// runTrends implements the "trends" command and returns the exit code
+func runTrends(args []string) int {
+        fs := flag.NewFlagSet("trends", flag.ExitOnError)
+        historyFile := fs.String("history", "", "History store written by -history (required)")
+        pathPrefix := fs.String("path", "", "Only include files below this path")
+        colorMode := fs.String("color", "auto", "Colorize the report: auto, always, never (NO_COLOR disables auto)")
+        fs.Parse(args)
+
+        if err := setupColor(*colorMode); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+        if *historyFile == "" {
+                fmt.Println("Error: -history is required")
+                fmt.Println("\nUsage: cleanfile trends -history FILE [-path DIR]")
+                fs.PrintDefaults()
+                return 1
+        }
+
+        prefix := ""
+        if *pathPrefix != "" {
+                abs, err := filepath.Abs(*pathPrefix)
+                if err != nil {
+                        fmt.Printf("Error: Could not resolve path: %v\n", err)
+                        return 1
+                }
+                prefix = abs
+        }
+
+        records, err := readHistory(*historyFile)
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+        runs := summarizeRuns(records, prefix)
+
+        fmt.Println("\n" + strings.Repeat("=", 70))
+        fmt.Println(colorize("HYGIENE TRENDS", ansiBold))
+        fmt.Println(strings.Repeat("=", 70))
+
+        fmt.Printf("\n%s\n", colorize("History:", ansiBold, ansiCyan))
+        fmt.Printf("   Store:  %s\n", *historyFile)
+        if prefix != "" {
+                fmt.Printf("   Path:   %s\n", prefix)
+        }
+        fmt.Printf("   Runs:   %d\n", len(runs))
+
+        if len(runs) > 0 {
+                fmt.Printf("\n%s\n", colorize("Runs:", ansiBold, ansiCyan))
+                fmt.Printf("   %-19s  %6s  %8s  %10s  %10s  %8s\n", "Time", "Files", "Dirty", "Characters", "Removed", "Rate")
+                fmt.Println(strings.Repeat("-", 70))
+                for _, run := range runs {
+                        fmt.Printf("   %-19s  %6d  %8d  %10d  %10d  %7.2f%%\n",
+                                run.Time.Local().Format("2006-01-02 15:04:05"),
+                                run.Files, run.FilesWithIssues, run.TotalChars, run.RemovedChars, run.removalRate())
+                }
+                fmt.Println(strings.Repeat("-", 70))
+        }
+
+        fmt.Println("\n" + strings.Repeat("=", 70))
+        if len(runs) < 2 {
+                fmt.Println(colorize("Not enough runs recorded to show a trend", ansiYellow))
+        } else {
+                first, last := runs[0], runs[len(runs)-1]
+                change := fmt.Sprintf("removal rate %.2f%% -> %.2f%%, dirty files %d -> %d",
+                        first.removalRate(), last.removalRate(), first.FilesWithIssues, last.FilesWithIssues)
+                switch {
+                case last.removalRate() < first.removalRate():
+                        fmt.Println(colorize("Trend: improving ("+change+")", ansiBold, ansiGreen))
+                case last.removalRate() > first.removalRate():
+                        fmt.Println(colorize("Trend: getting worse ("+change+")", ansiBold, ansiRed))
+                default:
+                        fmt.Println("Trend: unchanged (" + change + ")")
+                }
+        }
+        fmt.Println(strings.Repeat("=", 70))
+
+        return 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+        "strings"
+        "unicode/utf8"
+)
+
+// validInvalidUTF8 reports whether mode is a valid -invalid-utf8 value
+func validInvalidUTF8(mode string) bool {
+        return mode == "replace" || mode == "remove" || mode == "error" || mode == "keep"
+}
+
+// rawByteBase is where -invalid-utf8 keep maps the bytes 0x80-0xFF that are
+// not part of valid UTF-8 while a line is cleaned: the last 128 code points
+// of the Supplementary Private Use Area-B, which text practically never
+// uses. cleanString passes them through and restoreRawBytes turns them back
+// into the bytes they stand for.
+const rawByteBase = 0x10FF00
+
+// isRawByte reports whether a character stands for a kept invalid byte
+func isRawByte(r rune) bool {
+        return r >= rawByteBase+0x80 && r <= rawByteBase+0xFF
+}
+
+// applyInvalidUTF8 prepares text that is not valid UTF-8 for cleaning:
+// remove drops the invalid bytes and keep maps each to a raw byte
+// character. With replace they are left for the conversion to runes, which
+// decodes each as U+FFFD; error has failed before.
+func applyInvalidUTF8(s, mode string) string {
+        if utf8.ValidString(s) {
+                return s
+        }
+        switch mode {
+        case "remove":
+                return strings.ToValidUTF8(s, "")
+        case "keep":
+                var b strings.Builder
+                b.Grow(len(s) + 8)
+                for i := 0; i < len(s); {
+                        r, size := utf8.DecodeRuneInString(s[i:])
+                        if r == utf8.RuneError && size == 1 {
+                                b.WriteRune(rawByteBase + rune(s[i]))
+                        } else {
+                                b.WriteString(s[i : i+size])
+                        }
+                        i += size
+                }
+                return b.String()
+        }
+        return s
+}
+
+// restoreRawBytes writes the bytes that raw byte characters stand for back
+// into cleaned text
+func restoreRawBytes(s string) string {
+        if !strings.ContainsFunc(s, isRawByte) {
+                return s
+        }
+        var b strings.Builder
+        b.Grow(len(s))
+        for _, r := range s {
+                if isRawByte(r) {
+                        b.WriteByte(byte(r - rawByteBase))
+                        continue
+                }
+                b.WriteRune(r)
+        }
+        return b.String()
+}
+
+// invalidUTF8Action describes what was done with invalid UTF-8 in a mode
+func invalidUTF8Action(mode string) string {
+        switch mode {
+        case "remove":
+                return "removed"
+        case "keep":
+                return "kept"
+        }
+        return "replaced with U+FFFD"
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+        "fmt"
+        "strings"
+        "unicode"
+)
+
+// Characters of visible context shown on each side of an audited character
+const auditContext = 20
+
+// invisibleTables are the characters audit lists, besides the controls
+var invisibleTables = []*unicode.RangeTable{
+        unicode.Cf,
+        unicode.Zs,
+        unicode.Zl,
+        unicode.Zp,
+        unicode.Variation_Selector,
+        unicode.Other_Default_Ignorable_Code_Point,
+}
+
+// isInvisible reports whether r is a control, format, space, separator or
+// default-ignorable character, other than tab, line breaks and the ASCII
+// space
+func isInvisible(r rune) bool {
+        switch r {
+        case '\t', '\n', '\r', ' ':
+                return false
+        }
+        return unicode.IsControl(r) || isZeroWidth(r) || unicode.IsOneOf(invisibleTables, r)
+}
+
+// unicodeName returns the Unicode name of an invisible character
+func unicodeName(r rune) string {
+        if name, ok := invisibleNames[r]; ok {
+                return name
+        }
+        return describeChar(r)
+}
+
+// generalCategory returns the two-letter Unicode general category of r
+func generalCategory(r rune) string {
+        for _, category := range []string{"Cc", "Cf", "Zs", "Zl", "Zp", "Mn", "Lo", "Co", "Cs"} {
+                if unicode.Is(unicode.Categories[category], r) {
+                        return category
+                }
+        }
+        return "Cn"
+}
+
+// auditContextAt shows the characters around runes[i], with the audited
+// character as [U+XXXX] and other invisible characters as <U+XXXX>
+func auditContextAt(runes []rune, i int) string {
+        start := max(i-auditContext, 0)
+        end := min(i+auditContext+1, len(runes))
+
+        var b strings.Builder
+        if start > 0 {
+                b.WriteString("...")
+        }
+        for j := start; j < end; j++ {
+                r := runes[j]
+                switch {
+                case j == i:
+                        fmt.Fprintf(&b, "[U+%04X]", r)
+                case isInvisible(r):
+                        fmt.Fprintf(&b, "<U+%04X>", r)
+                case r == '\t':
+                        b.WriteString(`\t`)
+                default:
+                        b.WriteRune(r)
+                }
+        }
+        if end < len(runes) {
+                b.WriteString("...")
+        }
+        return b.String()
+}
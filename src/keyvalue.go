@@ -0,0 +1,145 @@
+package main
+
+import (
+        "strconv"
+        "strings"
+        "unicode"
+        "unicode/utf16"
+)
+
+// splitKeyValue splits a line of a .properties, .ini or .env file into the
+// part that is left exactly as it is and the value, which is cleaned. Blank
+// lines and [section] headers are kept whole; a comment keeps its
+// indentation and its text is cleaned like a value. isValue reports whether
+// a trailing backslash would continue the value on the next line.
+func splitKeyValue(content string) (keep, value string, isValue bool) {
+        trimmed := strings.TrimLeft(content, " \t\f")
+        indent := content[:len(content)-len(trimmed)]
+        switch {
+        case trimmed == "" || strings.HasPrefix(trimmed, "["):
+                return content, "", false
+        case strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "!"):
+                return indent, trimmed, false
+        case strings.HasPrefix(trimmed, "export "):
+                // .env files may export their variables
+                rest := strings.TrimLeft(trimmed[len("export "):], " \t")
+                indent = content[:len(content)-len(rest)]
+                trimmed = rest
+        }
+
+        // The key ends at the first unescaped =, : or blank, as in Java
+        // properties; blanks around the separator belong to it
+        for i := 0; i < len(trimmed); i++ {
+                switch trimmed[i] {
+                case '\\':
+                        i++
+                case '=', ':', ' ', '\t', '\f':
+                        j := i
+                        for j < len(trimmed) && strings.IndexByte(" \t\f", trimmed[j]) >= 0 {
+                                j++
+                        }
+                        if trimmed[i] == '=' || trimmed[i] == ':' {
+                                j = i + 1
+                        } else if j < len(trimmed) && (trimmed[j] == '=' || trimmed[j] == ':') {
+                                j++
+                        }
+                        for j < len(trimmed) && strings.IndexByte(" \t\f", trimmed[j]) >= 0 {
+                                j++
+                        }
+                        return indent + trimmed[:j], trimmed[j:], true
+                }
+        }
+        // A key without a value
+        return content, "", true
+}
+
+// continuesValue reports whether a line ends with an odd number of
+// backslashes, which continues a .properties value on the next line
+func continuesValue(content string) bool {
+        n := len(content) - len(strings.TrimRight(content, `\`))
+        return n%2 == 1
+}
+
+// decodeUnicodeEscapes replaces the \uXXXX escapes of a .properties value,
+// including surrogate pairs, with the characters they stand for and returns
+// the number of escapes decoded. An escaped backslash (\\u0041) is left
+// alone, and so are malformed escapes and those that keepEscaped keeps.
+func decodeUnicodeEscapes(s string) (string, int) {
+        if !strings.Contains(s, `\u`) {
+                return s, 0
+        }
+        var b strings.Builder
+        count := 0
+        for i := 0; i < len(s); i++ {
+                if s[i] != '\\' || i+1 == len(s) {
+                        b.WriteByte(s[i])
+                        continue
+                }
+                if s[i+1] != 'u' {
+                        b.WriteString(s[i : i+2])
+                        i++
+                        continue
+                }
+                r, size := parseUnicodeEscape(s[i:])
+                if size == 0 {
+                        b.WriteByte(s[i])
+                        continue
+                }
+                if utf16.IsSurrogate(r) {
+                        if low, lowSize := parseUnicodeEscape(s[i+size:]); lowSize > 0 {
+                                if pair := utf16.DecodeRune(r, low); pair != unicode.ReplacementChar {
+                                        b.WriteRune(pair)
+                                        count += 2
+                                        i += size + lowSize - 1
+                                        continue
+                                }
+                        }
+                        // A lone surrogate cannot be written as UTF-8
+                        b.WriteString(s[i : i+size])
+                        i += size - 1
+                        continue
+                }
+                if keepEscaped(r) {
+                        b.WriteString(s[i : i+size])
+                        i += size - 1
+                        continue
+                }
+                b.WriteRune(r)
+                count++
+                i += size - 1
+        }
+        return b.String(), count
+}
+
+// keepEscaped reports whether an escape must stay one because its
+// character has a meaning in .properties, .ini or .env syntax, where
+// decoding it would change what the file says: a line terminator would
+// end the value, a backslash start another escape, whitespace be trimmed
+// or separate, and a separator, comment or quote character be parsed
+func keepEscaped(r rune) bool {
+        switch r {
+        case '\t', '\n', '\v', '\f', '\r', ' ', 0x85, 0x2028, 0x2029:
+                return true
+        }
+        return strings.ContainsRune(`\=:#!;"'$`, r)
+}
+
+// parseUnicodeEscape parses a \uXXXX escape at the start of s (Java allows
+// repeated u's) and returns its value and length, or a length of 0
+func parseUnicodeEscape(s string) (rune, int) {
+        if !strings.HasPrefix(s, `\u`) {
+                return 0, 0
+        }
+        i := 1
+        for i < len(s) && s[i] == 'u' {
+                i++
+        }
+        if len(s) < i+4 {
+                return 0, 0
+        }
+        code, err := strconv.ParseUint(s[i:i+4], 16, 16)
+        if err != nil {
+                return 0, 0
+        }
+        return rune(code), i + 4
+}
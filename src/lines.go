@@ -0,0 +1,217 @@
+package main
+
+import (
+        "bufio"
+        "bytes"
+        "fmt"
+        "io"
+        "math"
+        "sort"
+        "strings"
+        "unicode"
+        "unicode/utf8"
+)
+
+// lineReader splits its input into lines terminated by LF, CRLF or a lone
+// CR (Classic Mac OS). A CRLF pair is recognised even when the CR is the
+// last byte of one buffered chunk and the LF the first byte of the next, so
+// it is never mistaken for two separate line endings.
+type lineReader struct {
+        r    *bufio.Reader
+        rest []byte // the part of a line cut off the last chunk, which starts the next
+}
+
+func newLineReader(r *bufio.Reader) *lineReader {
+        return &lineReader{r: r}
+}
+
+// Lines longer than this are cleaned in pieces, so that a multi-hundred-MB
+// single-line file (minified JSON or HTML) is neither held in memory several
+// times over nor truncated. Pieces are cut where no cleaning rule looks
+// across the cut (see safeCut).
+const maxChunkSize = 1 << 20
+
+// readLine returns the next line without its terminator, and the terminator
+// itself ("\n", "\r\n", "\r", or "" for a final unterminated line). At the
+// end of the input it returns io.EOF.
+func (lr *lineReader) readLine() (string, string, error) {
+        content, ending, _, err := lr.readChunk(math.MaxInt)
+        return content, ending, err
+}
+
+// readChunk is like readLine but returns at most limit bytes of a line at a
+// time, never splitting a UTF-8 sequence or anything safeCut keeps
+// together. more is set when the line continues in the next chunk; its
+// terminator comes with the last one.
+func (lr *lineReader) readChunk(limit int) (content, ending string, more bool, err error) {
+        line := lr.rest
+        lr.rest = nil
+        for {
+                if lr.r.Buffered() == 0 {
+                        if _, err := lr.r.Peek(1); err != nil {
+                                if err == io.EOF && len(line) > 0 {
+                                        return string(line), "", false, nil
+                                }
+                                return string(line), "", false, err
+                        }
+                }
+
+                buf, _ := lr.r.Peek(lr.r.Buffered())
+                i := bytes.IndexAny(buf, "\r\n")
+                if room := limit - len(line); (i < 0 || i > room) && len(buf) >= room {
+                        n := runeBoundary(buf[:room])
+                        line = append(line, buf[:n]...)
+                        lr.r.Discard(n)
+                        cut := safeCut(line)
+                        lr.rest = append([]byte(nil), line[cut:]...)
+                        return string(line[:cut]), "", true, nil
+                }
+                if i < 0 {
+                        line = append(line, buf...)
+                        lr.r.Discard(len(buf))
+                        continue
+                }
+
+                line = append(line, buf[:i]...)
+                delim := buf[i]
+                lr.r.Discard(i + 1)
+                if delim == '\n' {
+                        return string(line), "\n", false, nil
+                }
+
+                // The LF of a CRLF pair may only arrive with the next chunk
+                if next, err := lr.r.Peek(1); err == nil && next[0] == '\n' {
+                        lr.r.Discard(1)
+                        return string(line), "\r\n", false, nil
+                }
+                return string(line), "\r", false, nil
+        }
+}
+
+// runeBoundary returns the length of the longest prefix of p that does not
+// end in the middle of a UTF-8 sequence
+func runeBoundary(p []byte) int {
+        for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+                if utf8.RuneStart(p[i]) {
+                        if utf8.FullRune(p[i:]) {
+                                return len(p)
+                        }
+                        return i
+                }
+        }
+        return len(p)
+}
+
+// safeCut returns where a chunk of a line that goes on is cut: as late as
+// possible in its second half, at the best of these places, so that the
+// rules that look at neighbouring characters see the same ones as in the
+// whole line. Best is a blank after ASCII text, which splits no word; then
+// ASCII punctuation other than the : of a shortcode and the \ of an escape;
+// then two characters that do not join, one of them ASCII, and that are
+// not both part of a word; then any two that do not join. Characters join
+// if one is a mark, joiner, variation selector, skin tone, tag, keycap or
+// regional indicator, so emoji sequences and a letter with its marks are
+// never split. Without any of these the chunk is cut at its end.
+func safeCut(p []byte) int {
+        cut, best := len(p), 0
+        for i := len(p); i > len(p)/2; {
+                r1, size := utf8.DecodeLastRune(p[:i])
+                if i < len(p) {
+                        r2, _ := utf8.DecodeRune(p[i:])
+                        if level := cutLevel(r1, r2); level > best {
+                                cut, best = i, level
+                                if best == 4 {
+                                        break
+                                }
+                        }
+                }
+                i -= size
+        }
+        return cut
+}
+
+// cutLevel rates cutting between r1 and r2 for safeCut, from 4 (best) to 0
+// (never)
+func cutLevel(r1, r2 rune) int {
+        blank := func(r rune) bool { return r == ' ' || r == '\t' }
+        word := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' }
+        ascii1, ascii2 := r1 < utf8.RuneSelf, r2 < utf8.RuneSelf
+        switch {
+        case ascii1 && !blank(r1) && blank(r2):
+                return 4
+        case ascii1 && ascii2 && !blank(r2) && unicode.IsPunct(r1) && r1 != ':' && r1 != '\\':
+                return 3
+        case joinsNext(r1) || joinsPrevious(r2):
+                return 0
+        case (ascii1 || ascii2) && !(word(r1) && word(r2)):
+                return 2
+        }
+        return 1
+}
+
+// joinsPrevious reports whether r belongs with the character before it
+func joinsPrevious(r rune) bool {
+        return unicode.IsMark(r) || r == '\u200C' || r == zeroWidthJoiner || r == combiningKeycap ||
+                isVariationSelector(r) || isEmojiModifier(r) || isEmojiTag(r) || isRegionalIndicator(r) ||
+                r >= 0x1160 && r <= 0x11FF || r >= 0xD7B0 && r <= 0xD7FF // Hangul vowels and final consonants
+}
+
+// joinsNext reports whether r belongs with the character after it
+func joinsNext(r rune) bool {
+        return r == '\u200C' || r == zeroWidthJoiner || isRegionalIndicator(r)
+}
+
+// isRegionalIndicator reports whether r is one of the letters that make up
+// flags in pairs
+func isRegionalIndicator(r rune) bool {
+        return r >= regionalIndicatorA && r <= regionalIndicatorZ
+}
+
+// lineEndingName returns the conventional name of a line terminator
+func lineEndingName(ending string) string {
+        switch ending {
+        case "\r\n":
+                return "CRLF"
+        case "\r":
+                return "CR"
+        case "\n":
+                return "LF"
+        }
+        return ""
+}
+
+// countLineEnding records one line terminator found in the input and, if
+// it was rewritten, the conversion that was applied (e.g. "CRLF->LF")
+func (s *CleaningStats) countLineEnding(ending, target string, converted bool) {
+        if ending == "" {
+                return
+        }
+        if s.OriginalLineEndings == nil {
+                s.OriginalLineEndings = make(map[string]int)
+        }
+        s.OriginalLineEndings[lineEndingName(ending)]++
+
+        if !converted {
+                return
+        }
+        if s.LineEndingConversions == nil {
+                s.LineEndingConversions = make(map[string]int)
+        }
+        s.LineEndingConversions[lineEndingName(ending)+"->"+lineEndingName(target)]++
+        s.LineEndingsConverted++
+}
+
+// formatCounts renders a count map as "a: 1, b: 2" in key order
+func formatCounts(counts map[string]int) string {
+        keys := make([]string, 0, len(counts))
+        for key := range counts {
+                keys = append(keys, key)
+        }
+        sort.Strings(keys)
+
+        parts := make([]string, len(keys))
+        for i, key := range keys {
+                parts[i] = fmt.Sprintf("%s: %d", key, counts[key])
+        }
+        return strings.Join(parts, ", ")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+        "bufio"
+        "reflect"
+        "strings"
+        "testing"
+        "unicode/utf8"
+)
+
+// TestLineEndingsAcrossChunks checks that a CRLF pair whose CR is the last
+// byte of one buffered chunk of the input and whose LF is the first of the
+// next counts as one line ending, converted once, for every -os
+func TestLineEndingsAcrossChunks(t *testing.T) {
+        pad := func(n int) string { return strings.Repeat("a", n) }
+        tests := []struct {
+                name    string
+                lines   []string
+                endings []string
+        }{
+                {"CRLF across the first chunk", []string{pad(detectSampleSize - 1), "b"}, []string{"\r\n", "\n"}},
+                {"CRLF across a later chunk", []string{"a", pad(2*detectSampleSize - 3), "b"}, []string{"\n", "\r\n", "\r\n"}},
+                {"CR alone at the end of a chunk", []string{pad(detectSampleSize - 1), "b"}, []string{"\r", "\r\n"}},
+                {"CR at the end of the input", []string{"a", pad(detectSampleSize - 3)}, []string{"\n", "\r"}},
+        }
+        for _, targetOS := range []string{"windows", "unix", "mac9"} {
+                target := getLineEnding(targetOS)
+                for _, test := range tests {
+                        input, want := "", ""
+                        found := make(map[string]int)
+                        for i, line := range test.lines {
+                                input += line + test.endings[i]
+                                want += line + target
+                                found[lineEndingName(test.endings[i])]++
+                        }
+
+                        options, err := newCleaningOptions(map[string]string{"os": targetOS})
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        var sink BufferSink
+                        stats, err := cleanReader(strings.NewReader(input), nil, "(input)", &sink, options, false)
+                        if err != nil {
+                                t.Fatalf("%s, -os %s: %v", test.name, targetOS, err)
+                        }
+                        if got := sink.Buffer.String(); got != want {
+                                t.Errorf("%s, -os %s: %d lines, want %d", test.name, targetOS, countLineBreaks(got), len(test.lines))
+                        }
+                        if !reflect.DeepEqual(stats.OriginalLineEndings, found) {
+                                t.Errorf("%s, -os %s: line endings %v, want %v", test.name, targetOS, stats.OriginalLineEndings, found)
+                        }
+                }
+        }
+}
+
+// TestReadChunkRuneBoundary checks that readChunk never cuts a UTF-8
+// sequence of any length, wherever it falls across the chunk limit or the
+// end of the buffered input
+func TestReadChunkRuneBoundary(t *testing.T) {
+        const limit = 32
+        for _, r := range []string{"é", "€", "😀"} {
+                for before := 1; before < len(r); before++ {
+                        line := strings.Repeat("a", limit-before) + strings.Repeat(r, limit) + "z"
+                        for _, size := range []int{16, limit - before + 1, 4096} {
+                                lines := newLineReader(bufio.NewReaderSize(strings.NewReader(line+"\n"), size))
+                                var got []string
+                                for {
+                                        content, ending, more, err := lines.readChunk(limit)
+                                        if err != nil {
+                                                t.Fatalf("%q, %d before the limit, buffer of %d: %v", r, before, size, err)
+                                        }
+                                        if len(content) > limit || !utf8.ValidString(content) {
+                                                t.Errorf("%q, %d before the limit, buffer of %d: chunk %q", r, before, size, content)
+                                        }
+                                        got = append(got, content)
+                                        if !more {
+                                                if ending != "\n" {
+                                                        t.Errorf("%q, %d before the limit, buffer of %d: ending %q", r, before, size, ending)
+                                                }
+                                                break
+                                        }
+                                }
+                                if strings.Join(got, "") != line {
+                                        t.Errorf("%q, %d before the limit, buffer of %d: chunks %q", r, before, size, got)
+                                }
+                                if len(got) < 2 {
+                                        t.Errorf("%q, %d before the limit, buffer of %d: line not cut", r, before, size)
+                                }
+                        }
+                }
+        }
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+        "fmt"
+        "io"
+)
+
+// writeLintReport writes one line per removed character, so that a single
+// character can be located in a large file, or opened from an editor's
+// quickfix list. Files that failed are reported with their error.
+func writeLintReport(w io.Writer, results []FileResult) error {
+        for _, r := range results {
+                if r.Err != nil {
+                        if _, err := fmt.Fprintf(w, "%s: error: %v\n", r.InputPath, r.Err); err != nil {
+                                return err
+                        }
+                        continue
+                }
+                for _, loc := range r.Stats.Locations {
+                        if _, err := fmt.Fprintln(w, lintFinding(r.InputPath, loc)); err != nil {
+                                return err
+                        }
+                }
+                if more := r.Stats.RemovedChars - len(r.Stats.Locations); more > 0 {
+                        if _, err := fmt.Fprintf(w, "%s: %d more finding(s) not listed\n", r.InputPath, more); err != nil {
+                                return err
+                        }
+                }
+        }
+        return nil
+}
+
+// lintFinding formats a removed character as "path:line:column: level:
+// U+200B Zero Width Space (zero-width)", with the level and rule it has in
+// -report sarif
+func lintFinding(path string, loc CharLocation) string {
+        ruleID := sarifRuleID(loc.Char)
+        return fmt.Sprintf("%s:%d:%d: %s: U+%04X %s (%s)", path, loc.Line, loc.Column, ruleLevel(ruleID), loc.Char, describeChar(loc.Char), ruleID)
+}
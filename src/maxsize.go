@@ -0,0 +1,82 @@
+package main
+
+import (
+        "fmt"
+        "io"
+        "strconv"
+        "strings"
+)
+
+// oversizeActions are the values of -oversize
+var oversizeActions = []string{"refuse", "chunk"}
+
+// parseSize parses the size of -max-size: a number of bytes, optionally
+// followed by K, M or G (powers of 1024, with or without B or iB, as in
+// 512K, 100MB or 2GiB); "" or 0 sets no limit
+func parseSize(s string) (int64, error) {
+        value := strings.ToUpper(strings.TrimSpace(s))
+        if value == "" {
+                return 0, nil
+        }
+        value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+        unit := int64(1)
+        switch {
+        case strings.HasSuffix(value, "K"):
+                unit = 1 << 10
+        case strings.HasSuffix(value, "M"):
+                unit = 1 << 20
+        case strings.HasSuffix(value, "G"):
+                unit = 1 << 30
+        }
+        if unit > 1 {
+                value = value[:len(value)-1]
+        }
+        n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+        if err != nil || n < 0 || n > (1<<62)/unit {
+                return 0, fmt.Errorf("invalid size '%s'. Expected a number of bytes such as 1048576, 512K, 100M or 2G", s)
+        }
+        return n * unit, nil
+}
+
+// formatSize renders a number of bytes for messages, e.g. 1.5 MB
+func formatSize(n int64) string {
+        switch {
+        case n >= 1<<30:
+                return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+        case n >= 1<<20:
+                return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+        case n >= 1<<10:
+                return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+        }
+        return fmt.Sprintf("%d bytes", n)
+}
+
+// oversizeError is the error of a file larger than -max-size that is not
+// cleaned: with -oversize refuse, or when it would have to be read into
+// memory as a whole
+func oversizeError(options CleaningOptions, buffered bool) error {
+        if buffered {
+                return fmt.Errorf("input is larger than -max-size (%s), and -strip, -csv-safe and -tsv need it in memory as a whole",
+                        formatSize(options.MaxSize))
+        }
+        return fmt.Errorf("input is larger than -max-size (%s)", formatSize(options.MaxSize))
+}
+
+// sizeLimiter fails reading once more than max bytes have been read, so
+// that input whose size is not known in advance, such as a pipe, is held to
+// -max-size as well. A max of 0 sets no limit.
+type sizeLimiter struct {
+        r   io.Reader
+        n   int64
+        max int64
+        err error
+}
+
+func (l *sizeLimiter) Read(p []byte) (int, error) {
+        n, err := l.r.Read(p)
+        l.n += int64(n)
+        if l.max > 0 && l.n > l.max {
+                return n, l.err
+        }
+        return n, err
+}
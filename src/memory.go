@@ -0,0 +1,79 @@
+package main
+
+import "fmt"
+
+// Budgets of -max-memory. memoryReserve is kept for the runtime and the
+// character tables, so a limit must be at least minMaxMemory; each file
+// cleaned at a time gets at least minFileMemory of the rest, and -jobs is
+// lowered until they do.
+const (
+        minMaxMemory  = 16 << 20
+        memoryReserve = 8 << 20
+        minFileMemory = 2 << 20
+)
+
+// How many times its size a piece of text takes in memory while it is
+// cleaned: a line chunk as read, as runes and as cleaned; a whole document
+// for -strip, -csv-safe or -tsv as read, decoded and after each rewrite;
+// both files of an undo patch and their lines
+const (
+        chunkCopies    = 16
+        documentCopies = 8
+        patchCopies    = 4
+)
+
+// minChunkSize is the smallest piece a long line is cleaned in, however
+// little memory -max-memory leaves
+const minChunkSize = 4 << 10
+
+// parseMaxMemory parses -max-memory like -max-size and returns the memory
+// that files may use together, what is left after memoryReserve
+func parseMaxMemory(s string) (int64, error) {
+        limit, err := parseSize(s)
+        if err != nil || limit == 0 {
+                return 0, err
+        }
+        if limit < minMaxMemory {
+                return 0, fmt.Errorf("-max-memory must be at least %s", formatSize(minMaxMemory))
+        }
+        return limit - memoryReserve, nil
+}
+
+// memoryPerFile divides the memory files may use among the jobs that clean
+// them, lowering jobs so that each file gets at least minFileMemory
+func memoryPerFile(maxMemory int64, jobs int) (int, int64) {
+        if maxMemory == 0 || jobs == 0 {
+                return jobs, maxMemory
+        }
+        if fit := int(maxMemory / minFileMemory); jobs > fit {
+                jobs = max(fit, 1)
+        }
+        return jobs, maxMemory / int64(jobs)
+}
+
+// chunkSize returns how much of a long line is cleaned at a time
+func chunkSize(options CleaningOptions) int {
+        if options.MaxMemory == 0 {
+                return maxChunkSize
+        }
+        return int(min(max(options.MaxMemory/chunkCopies, minChunkSize), maxChunkSize))
+}
+
+// inputLimit returns how many bytes of input a file may have, or 0 for no
+// limit, and the error of a larger file: -max-size, unless -oversize chunk
+// streams it, and the part of -max-memory that a document held as a whole
+// may take
+func inputLimit(options CleaningOptions, buffered bool) (int64, error) {
+        var limit int64
+        var err error
+        if options.MaxSize > 0 && (buffered || options.Oversize == "refuse") {
+                limit, err = options.MaxSize, oversizeError(options, buffered)
+        }
+        if buffered && options.MaxMemory > 0 {
+                if fit := options.MaxMemory / documentCopies; limit == 0 || fit < limit {
+                        limit = fit
+                        err = fmt.Errorf("input is larger than the %s that -strip, -csv-safe and -tsv can hold in memory within -max-memory", formatSize(fit))
+                }
+        }
+        return limit, err
+}
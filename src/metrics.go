@@ -0,0 +1,132 @@
+package main
+
+import (
+        "errors"
+        "fmt"
+        "io"
+        "net"
+        "net/http"
+        "strconv"
+        "sync"
+        "time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the buckets of the
+// processing duration histogram: the default buckets of the Prometheus
+// client libraries
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// removalClasses are the values of the class label of the removed
+// characters counter, each with the count of a file's characters it takes
+var removalClasses = []struct {
+        name  string
+        count func(s *CleaningStats) int
+}{
+        {"zero_width", func(s *CleaningStats) int { return s.ZeroWidthRemoved }},
+        {"control", func(s *CleaningStats) int { return s.ControlCharsRemoved }},
+        {"non_ascii", func(s *CleaningStats) int { return s.NonASCIIRemoved }},
+        {"emoji", func(s *CleaningStats) int { return s.EmojiCharsRemoved }},
+        {"restricted", func(s *CleaningStats) int { return s.RestrictedRemoved }},
+        {"modifiers", func(s *CleaningStats) int { return s.ModifiersRemoved }},
+        {"soft_hyphens", func(s *CleaningStats) int { return s.SoftHyphensRemoved }},
+}
+
+// serviceMetrics counts what a long-running mode has cleaned since it
+// started, for Prometheus to scrape from /metrics (-metrics-addr). Its
+// methods may be called concurrently, and do nothing on a nil value.
+type serviceMetrics struct {
+        mu       sync.Mutex
+        cleaned  int64
+        failed   int64
+        bytes    int64
+        removed  []int64 // by removalClasses
+        buckets  []int64 // by durationBuckets, not cumulative
+        duration float64 // seconds, summed over all files
+        count    int64
+}
+
+func newServiceMetrics() *serviceMetrics {
+        return &serviceMetrics{removed: make([]int64, len(removalClasses)), buckets: make([]int64, len(durationBuckets))}
+}
+
+// observe counts a file that was cleaned, or failed, in elapsed
+func (m *serviceMetrics) observe(r FileResult, elapsed time.Duration) {
+        if m == nil {
+                return
+        }
+        m.mu.Lock()
+        defer m.mu.Unlock()
+        if r.Err != nil || r.Stats == nil {
+                m.failed++
+        } else {
+                m.cleaned++
+                m.bytes += int64(r.Stats.OriginalBytes)
+                for i, class := range removalClasses {
+                        m.removed[i] += int64(class.count(r.Stats))
+                }
+        }
+        seconds := elapsed.Seconds()
+        for i, bound := range durationBuckets {
+                if seconds <= bound {
+                        m.buckets[i]++
+                        break
+                }
+        }
+        m.duration += seconds
+        m.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *serviceMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+        m.write(w)
+}
+
+func (m *serviceMetrics) write(w io.Writer) {
+        m.mu.Lock()
+        defer m.mu.Unlock()
+
+        fmt.Fprintln(w, "# HELP cleanfile_files_processed_total Files processed, by whether they were cleaned or failed.")
+        fmt.Fprintln(w, "# TYPE cleanfile_files_processed_total counter")
+        fmt.Fprintf(w, "cleanfile_files_processed_total{result=\"cleaned\"} %d\n", m.cleaned)
+        fmt.Fprintf(w, "cleanfile_files_processed_total{result=\"failed\"} %d\n", m.failed)
+
+        fmt.Fprintln(w, "# HELP cleanfile_bytes_processed_total Bytes read from the files that were cleaned.")
+        fmt.Fprintln(w, "# TYPE cleanfile_bytes_processed_total counter")
+        fmt.Fprintf(w, "cleanfile_bytes_processed_total %d\n", m.bytes)
+
+        fmt.Fprintln(w, "# HELP cleanfile_characters_removed_total Characters removed, by class.")
+        fmt.Fprintln(w, "# TYPE cleanfile_characters_removed_total counter")
+        for i, class := range removalClasses {
+                fmt.Fprintf(w, "cleanfile_characters_removed_total{class=%q} %d\n", class.name, m.removed[i])
+        }
+
+        fmt.Fprintln(w, "# HELP cleanfile_processing_duration_seconds Time taken to clean a file.")
+        fmt.Fprintln(w, "# TYPE cleanfile_processing_duration_seconds histogram")
+        var cumulative int64
+        for i, bound := range durationBuckets {
+                cumulative += m.buckets[i]
+                fmt.Fprintf(w, "cleanfile_processing_duration_seconds_bucket{le=\"%s\"} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+        }
+        fmt.Fprintf(w, "cleanfile_processing_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.count)
+        fmt.Fprintf(w, "cleanfile_processing_duration_seconds_sum %s\n", strconv.FormatFloat(m.duration, 'g', -1, 64))
+        fmt.Fprintf(w, "cleanfile_processing_duration_seconds_count %d\n", m.count)
+}
+
+// serveMetrics serves m at /metrics on addr, such as :9090, in the
+// background. Only listening is checked; an error while serving ends the
+// endpoint, not the caller.
+func serveMetrics(addr string, m *serviceMetrics) error {
+        listener, err := net.Listen("tcp", addr)
+        if err != nil {
+                return fmt.Errorf("could not listen for metrics on %s: %w", addr, err)
+        }
+        mux := http.NewServeMux()
+        mux.Handle("/metrics", m)
+        go func() {
+                if err := http.Serve(listener, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+                        fmt.Printf("%s metrics endpoint stopped: %v\n", colorize("Warning:", ansiYellow), err)
+                }
+        }()
+        return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+        "strings"
+        "unicode/utf8"
+)
+
+// windows1252 maps the characters Windows-1252 puts at 0x80-0x9F to their
+// byte. Latin-1 and Windows-1252 agree on 0xA0-0xFF, which are the code
+// points U+00A0-U+00FF.
+var windows1252 = map[rune]byte{
+        '€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+        'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+        '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+        '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// maxMojibakeLayers limits how often text that was encoded more than twice
+// is decoded again
+const maxMojibakeLayers = 3
+
+// legacyByte returns the byte a character was decoded from if UTF-8 was
+// read as Windows-1252 (or Latin-1, whose C1 controls pass through as
+// U+0080-U+009F)
+func legacyByte(r rune) (byte, bool) {
+        if r >= 0x80 && r <= 0xFF {
+                return byte(r), true
+        }
+        b, ok := windows1252[r]
+        return b, ok
+}
+
+// fixMojibake undoes double UTF-8 encoding: UTF-8 that was read as
+// Windows-1252 or Latin-1 and encoded as UTF-8 again, so that é (C3 A9)
+// became Ã©. A sequence is only repaired if the characters make up exactly
+// one valid UTF-8 character, and one that isMojibakeTarget accepts, which
+// text that merely contains Ã or Â rarely does. It returns the repaired text and the number of characters repaired.
+func fixMojibake(s string) (string, int) {
+        repaired := 0
+        for layer := 0; layer < maxMojibakeLayers; layer++ {
+                fixed, count, consumed := fixMojibakeOnce(s)
+                if count == 0 {
+                        break
+                }
+                s = fixed
+                if layer == 0 {
+                        repaired += count
+                } else {
+                        // Text encoded three times: the characters repaired
+                        // before become one
+                        repaired -= consumed - count
+                }
+        }
+        return s, repaired
+}
+
+// fixMojibakeOnce decodes one layer of double encoding and returns the
+// result, the number of sequences decoded and the characters they took up
+func fixMojibakeOnce(s string) (string, int, int) {
+        // Every repaired sequence starts with Â-Å or â
+        if !strings.ContainsFunc(s, func(r rune) bool { return r >= 0xC2 && r <= 0xC5 || r == 0xE2 }) {
+                return s, 0, 0
+        }
+        runes := []rune(s)
+        var b strings.Builder
+        b.Grow(len(s))
+        count, consumed := 0, 0
+        for i := 0; i < len(runes); i++ {
+                if n := mojibakeLength(runes[i:]); n > 0 {
+                        encoded := make([]byte, n)
+                        for j := range encoded {
+                                encoded[j], _ = legacyByte(runes[i+j])
+                        }
+                        b.Write(encoded)
+                        count++
+                        consumed += n
+                        i += n - 1
+                        continue
+                }
+                b.WriteRune(runes[i])
+        }
+        return b.String(), count, consumed
+}
+
+// mojibakeLength returns the number of characters at the start of runes
+// that are the bytes of one double-encoded UTF-8 character, or 0
+func mojibakeLength(runes []rune) int {
+        lead, ok := legacyByte(runes[0])
+        if !ok {
+                return 0
+        }
+        // Only the leads of the characters isMojibakeTarget accepts
+        var n int
+        switch {
+        case lead >= 0xC2 && lead <= 0xC5:
+                n = 2
+        case lead == 0xE2:
+                n = 3
+        default:
+                return 0
+        }
+        if len(runes) < n {
+                return 0
+        }
+        encoded := []byte{lead}
+        for _, r := range runes[1:n] {
+                b, ok := legacyByte(r)
+                if !ok || b < 0x80 || b > 0xBF {
+                        return 0
+                }
+                encoded = append(encoded, b)
+        }
+        if r, size := utf8.DecodeRune(encoded); size != n || !isMojibakeTarget(r) {
+                return 0
+        }
+        return n
+}
+
+// isMojibakeTarget reports whether r is a character that double encoding
+// typically garbles and -fix-mojibake restores: the letters and symbols of
+// Latin-1 Supplement and Latin Extended-A, whose double encoding starts
+// with Â, Ã, Ä or Å, and the quotes, dashes and other characters of General
+// Punctuation, which start with â€. Decoding to anything else, as „Fuß“ to
+// „Fuߓ or CAFÉ’s to CAFɒs, would take valid text for mojibake.
+func isMojibakeTarget(r rune) bool {
+        return r >= 0x80 && r <= 0x17F || r >= 0x2000 && r <= 0x206F
+}
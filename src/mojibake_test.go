@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestFixMojibake(t *testing.T) {
+        tests := []struct {
+                in, want string
+                repaired int
+        }{
+                {"CafÃ©", "Café", 1},
+                {"Ã¼ber StraÃŸe", "über Straße", 2},
+                {"2010â€“2020", "2010–2020", 1},
+                {"â€žZitatâ€œ", "„Zitat“", 2},
+                {"ZaÅ¼Ã³Å‚Ä‡", "Zażółć", 4},
+                {"CafÃƒÂ©", "Café", 1},
+
+                // Valid text whose characters happen to be the bytes of
+                // some UTF-8 character
+                {"„Fuß“", "„Fuß“", 0},
+                {"CAFÉ’s", "CAFÉ’s", 0},
+                {"Ärger über Öl", "Ärger über Öl", 0},
+                {"Ã la carte", "Ã la carte", 0},
+                {"naïve ½ × ¾", "naïve ½ × ¾", 0},
+                {"Ωμέγα", "Ωμέγα", 0},
+        }
+        for _, tt := range tests {
+                got, repaired := fixMojibake(tt.in)
+                if got != tt.want || repaired != tt.repaired {
+                        t.Errorf("fixMojibake(%q) = %q, %d; want %q, %d", tt.in, got, repaired, tt.want, tt.repaired)
+                }
+                // The warning without -fix-mojibake relies on the same check
+                if _, count, _ := fixMojibakeOnce(tt.in); (count > 0) != (tt.repaired > 0) {
+                        t.Errorf("fixMojibakeOnce(%q) found %d sequence(s)", tt.in, count)
+                }
+        }
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+        "sort"
+        "unicode/utf8"
+)
+
+// Unicode normalization forms accepted by -normalize-unicode
+var normalizationForms = []string{"nfc", "nfd", "nfkc", "nfkd"}
+
+// Hangul syllables are composed and decomposed algorithmically
+// (Unicode 3.12)
+const (
+        hangulSBase  = 0xAC00
+        hangulLBase  = 0x1100
+        hangulVBase  = 0x1161
+        hangulTBase  = 0x11A7
+        hangulLCount = 19
+        hangulVCount = 21
+        hangulTCount = 28
+        hangulNCount = hangulVCount * hangulTCount
+        hangulSCount = hangulLCount * hangulNCount
+)
+
+// composesBackward holds the starters that can combine with a preceding
+// character, such as U+0B3E in U+0B47 U+0B3E -> U+0B4B and the Hangul
+// vowel and trailing consonant jamo. Normalization segments cannot start
+// with them.
+var composesBackward = func() map[rune]bool {
+        m := make(map[rune]bool)
+        for pair := range compositions {
+                if combiningClasses[pair[1]] == 0 {
+                        m[pair[1]] = true
+                }
+        }
+        for r := rune(hangulVBase); r < hangulVBase+hangulVCount; r++ {
+                m[r] = true
+        }
+        for r := rune(hangulTBase + 1); r < hangulTBase+hangulTCount; r++ {
+                m[r] = true
+        }
+        return m
+}()
+
+// normalizeUnicode converts s to the given normalization form (UAX #15)
+// and returns the number of segments it changed. A segment is a starter
+// with the combining characters that follow it, e.g. "e" + U+0301.
+func normalizeUnicode(s, form string) (string, int) {
+        if isASCII(s) {
+                return s, 0
+        }
+        compat := form == "nfkc" || form == "nfkd"
+        compose := form == "nfc" || form == "nfkc"
+
+        runes := []rune(s)
+        out := make([]rune, 0, len(runes))
+        changed := 0
+        start := 0
+        for i := 1; i <= len(runes); i++ {
+                if i < len(runes) && !startsSegment(runes[i], compat) {
+                        continue
+                }
+                segment := runes[start:i]
+                normalized := normalizeSegment(segment, compat, compose)
+                if string(normalized) != string(segment) {
+                        changed++
+                }
+                out = append(out, normalized...)
+                start = i
+        }
+        return string(out), changed
+}
+
+func isASCII(s string) bool {
+        for i := 0; i < len(s); i++ {
+                if s[i] >= 0x80 {
+                        return false
+                }
+        }
+        return true
+}
+
+// startsSegment reports whether nothing before r can interact with r or
+// anything after it: r and its decomposition start with a starter that
+// does not combine backwards
+func startsSegment(r rune, compat bool) bool {
+        if combiningClasses[r] != 0 || composesBackward[r] {
+                return false
+        }
+        first := firstDecomposed(r, compat)
+        return combiningClasses[first] == 0 && !composesBackward[first]
+}
+
+// firstDecomposed returns the first character of the full decomposition
+// of r
+func firstDecomposed(r rune, compat bool) rune {
+        if r >= hangulSBase && r < hangulSBase+hangulSCount {
+                return hangulLBase + (r-hangulSBase)/hangulNCount
+        }
+        decomposition, ok := "", false
+        if compat {
+                decomposition, ok = compatDecompositions[r]
+        }
+        if !ok {
+                decomposition, ok = canonicalDecompositions[r]
+        }
+        if !ok {
+                return r
+        }
+        first, _ := utf8.DecodeRuneInString(decomposition)
+        return first
+}
+
+func normalizeSegment(segment []rune, compat, compose bool) []rune {
+        var d []rune
+        for _, r := range segment {
+                d = decompose(d, r, compat)
+        }
+
+        // Canonical ordering: stable sort of each run of non-starters by
+        // combining class
+        for i := 0; i < len(d); {
+                if combiningClasses[d[i]] == 0 {
+                        i++
+                        continue
+                }
+                j := i
+                for j < len(d) && combiningClasses[d[j]] != 0 {
+                        j++
+                }
+                run := d[i:j]
+                sort.SliceStable(run, func(a, b int) bool {
+                        return combiningClasses[run[a]] < combiningClasses[run[b]]
+                })
+                i = j
+        }
+
+        if !compose || len(d) < 2 {
+                return d
+        }
+        return composeRunes(d)
+}
+
+// decompose appends the full decomposition of r to d
+func decompose(d []rune, r rune, compat bool) []rune {
+        if r >= hangulSBase && r < hangulSBase+hangulSCount {
+                index := r - hangulSBase
+                d = append(d, hangulLBase+index/hangulNCount, hangulVBase+(index%hangulNCount)/hangulTCount)
+                if t := index % hangulTCount; t != 0 {
+                        d = append(d, hangulTBase+t)
+                }
+                return d
+        }
+        if compat {
+                if decomposition, ok := compatDecompositions[r]; ok {
+                        return append(d, []rune(decomposition)...)
+                }
+        }
+        if decomposition, ok := canonicalDecompositions[r]; ok {
+                return append(d, []rune(decomposition)...)
+        }
+        return append(d, r)
+}
+
+// composeRunes applies canonical composition to a decomposed, canonically
+// ordered sequence: each character is combined with the last starter unless
+// a character in between blocks it
+func composeRunes(d []rune) []rune {
+        out := d[:1]
+        starter := 0
+        lastClass := int(combiningClasses[d[0]])
+        if lastClass != 0 {
+                // A leading non-starter has no starter to combine with
+                lastClass = 256
+        }
+
+        for _, r := range d[1:] {
+                class := int(combiningClasses[r])
+                if composite, ok := composePair(out[starter], r); ok && (lastClass < class || lastClass == 0) {
+                        out[starter] = composite
+                        continue
+                }
+                if class == 0 {
+                        starter = len(out)
+                }
+                lastClass = class
+                out = append(out, r)
+        }
+        return out
+}
+
+func composePair(a, b rune) (rune, bool) {
+        if a >= hangulLBase && a < hangulLBase+hangulLCount && b >= hangulVBase && b < hangulVBase+hangulVCount {
+                return hangulSBase + ((a-hangulLBase)*hangulVCount+(b-hangulVBase))*hangulTCount, true
+        }
+        if a >= hangulSBase && a < hangulSBase+hangulSCount && (a-hangulSBase)%hangulTCount == 0 &&
+                b > hangulTBase && b < hangulTBase+hangulTCount {
+                return a + (b - hangulTBase), true
+        }
+        composite, ok := compositions[[2]rune{a, b}]
+        return composite, ok
+}
+
+// validNormalizationForm reports whether form is one of normalizationForms
+func validNormalizationForm(form string) bool {
+        for _, f := range normalizationForms {
+                if form == f {
+                        return true
+                }
+        }
+        return false
+}
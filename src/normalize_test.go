@@ -0,0 +1,89 @@
+package main
+
+import (
+        "bufio"
+        "os"
+        "strconv"
+        "strings"
+        "testing"
+        "unicode"
+        "unicode/utf8"
+)
+
+// TestNormalizationConformance runs the conformance tests of
+// testdata/NormalizationTest.txt (see tools/normtables): for each line
+// source;NFC;NFD;NFKC;NFKD, every form of the columns it must hold, and
+// every character not in part 1 must be left as it is by every form
+func TestNormalizationConformance(t *testing.T) {
+        f, err := os.Open("testdata/NormalizationTest.txt")
+        if err != nil {
+                t.Fatal(err)
+        }
+        defer f.Close()
+
+        listed := make(map[rune]bool)
+        part, lines := "", 0
+        scanner := bufio.NewScanner(f)
+        for scanner.Scan() {
+                line, _, _ := strings.Cut(scanner.Text(), "#")
+                if strings.HasPrefix(line, "@") {
+                        part = strings.TrimSpace(line)
+                        continue
+                }
+                fields := strings.Split(line, ";")
+                if len(fields) < 5 {
+                        continue
+                }
+                var c [6]string
+                for i, field := range fields[:5] {
+                        for _, code := range strings.Fields(field) {
+                                r, err := strconv.ParseUint(code, 16, 32)
+                                if err != nil {
+                                        t.Fatalf("%s: %v", line, err)
+                                }
+                                c[i+1] += string(rune(r))
+                        }
+                }
+                if part == "@Part1" {
+                        r, _ := utf8.DecodeRuneInString(c[1])
+                        listed[r] = true
+                }
+                lines++
+
+                for _, check := range []struct {
+                        form   string
+                        want   int
+                        source []int
+                }{
+                        {"nfc", 2, []int{1, 2, 3}},
+                        {"nfc", 4, []int{4, 5}},
+                        {"nfd", 3, []int{1, 2, 3}},
+                        {"nfd", 5, []int{4, 5}},
+                        {"nfkc", 4, []int{1, 2, 3, 4, 5}},
+                        {"nfkd", 5, []int{1, 2, 3, 4, 5}},
+                } {
+                        for _, i := range check.source {
+                                if got, _ := normalizeUnicode(c[i], check.form); got != c[check.want] {
+                                        t.Errorf("%s: %s of c%d is %+q, want c%d %+q", line, check.form, i, got, check.want, c[check.want])
+                                }
+                        }
+                }
+        }
+        if err := scanner.Err(); err != nil {
+                t.Fatal(err)
+        }
+        if len(listed) == 0 {
+                t.Fatalf("no part 1 in %d lines", lines)
+        }
+
+        for r := rune(0); r <= unicode.MaxRune; r++ {
+                if !utf8.ValidRune(r) || listed[r] {
+                        continue
+                }
+                for _, form := range normalizationForms {
+                        if got, _ := normalizeUnicode(string(r), form); got != string(r) {
+                                t.Errorf("%s of U+%04X is %+q, want it unchanged", form, r, got)
+                        }
+                }
+        }
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+        "errors"
+        "fmt"
+        "sort"
+        "strconv"
+        "strings"
+)
+
+// optionDefaults are the options of the cleaning engine, named as the flags
+// of clean, with their defaults. newCleanFlags defines the same flags with
+// the same defaults; the others, such as -output or -report, belong to the
+// command alone.
+var optionDefaults = map[string]string{
+        "ascii": "true", "control": "true", "zerowidth": "true", "bom": "true",
+        "ensure-bom": "false", "add-bom": "false",
+        "normalize-quotes": "false", "smart-punct": "false", "fix-quotes": "false",
+        "csv-safe": "false", "csv-delimiter": "", "tsv": "false", "tsv-newline": `\n`,
+        "key-value": "false", "po": "false", "decode-escapes": "false", "chat": "false", "drop-reactions": "false",
+        "remove-emoji": "false", "emoji": "", "strip-modifiers": "false",
+        "spaces": "false", "soft-hyphens": "remove", "dashes": "",
+        "keep-letters": "false", "allow-ranges": "", "replacement": "",
+        "normalize": "false", "preserve-newlines": "true", "normalize-unicode": "",
+        "transliterate": "false", "fix-mojibake": "false",
+        "os": "", "strip": "", "strip-keep": "", "strip-extract": "",
+        "strict": "false", "input-encoding": "auto", "invalid-utf8": "replace",
+        "warn-line-length": "10000", "max-size": "", "oversize": "refuse",
+        "trim-trailing": "false", "final-newline": "false", "preserve-lines": "false",
+        "profile": "", "preset": "", "mode": "",
+}
+
+// isBoolOption reports whether an option of optionDefaults is a boolean
+func isBoolOption(name string) bool {
+        return optionDefaults[name] == "true" || optionDefaults[name] == "false"
+}
+
+// builtinProfiles can be selected with -profile without a config file; a
+// config file can extend or override them key by key
+var builtinProfiles = map[string]map[string]string{
+        // CSV that opens cleanly in Excel: UTF-8 with BOM, CRLF, straight
+        // quotes, no invisible characters and no formula injection
+        "excel-csv": {
+                "ascii":            "false",
+                "zerowidth":        "true",
+                "control":          "true",
+                "os":               "windows",
+                "ensure-bom":       "true",
+                "normalize-quotes": "true",
+                "csv-safe":         "true",
+        },
+        // Slack, WhatsApp and Teams text exports: messages are cleaned but
+        // keep their timestamps and authors, letters of every language and
+        // their emoji
+        "chat": {
+                "chat":          "true",
+                "ascii":         "false",
+                "zerowidth":     "true",
+                "control":       "true",
+                "trim-trailing": "true",
+        },
+}
+
+var builtinProfileNames = []string{"chat", "excel-csv"}
+
+// cleaningModes are the option bundles selected with -mode. Unlike a
+// profile, a mode is a fixed set of passes: options given on the command
+// line or in a config file (including the selected profile) override it.
+var cleaningModes = map[string]map[string]string{
+        // Only what no reader can see: zero-width, control and format
+        // characters and the BOM. Letters, emoji, punctuation and
+        // whitespace are left alone.
+        "conservative": {
+                "ascii":     "false",
+                "zerowidth": "true",
+                "control":   "true",
+                "bom":       "true",
+        },
+        // The defaults: everything outside ASCII is removed
+        "standard": {},
+        // Text reduced to plain ASCII with as little lost as possible:
+        // compatibility characters and accented letters become their ASCII
+        // letters, curly punctuation and Unicode spaces their ASCII forms,
+        // and runs of blanks and trailing whitespace are cleaned up
+        "aggressive": {
+                "ascii":             "true",
+                "fix-mojibake":      "true",
+                "normalize-unicode": "nfkc",
+                "transliterate":     "true",
+                "smart-punct":       "true",
+                "spaces":            "true",
+                "normalize":         "true",
+                "trim-trailing":     "true",
+        },
+}
+
+var cleaningModeNames = []string{"conservative", "standard", "aggressive"}
+
+// securityPresets are the option bundles selected with -preset, which also
+// turns on the checks of the security profile of the same name
+var securityPresets = map[string]map[string]string{
+        // The General Security Profile of UTR #39 for text: letters of every
+        // recommended script are kept, compatibility characters are
+        // normalized and invisible characters removed
+        "utr39": {
+                "ascii":             "false",
+                "zerowidth":         "true",
+                "control":           "true",
+                "bom":               "true",
+                "normalize-unicode": "nfkc",
+        },
+}
+
+// newCleaningOptions checks options named as the flags of clean, such as
+// "ascii": "false" or "profile": "chat", and returns the cleaning options
+// they select, with the defaults of the options not given. As on the
+// command line, a built-in profile, then -preset and then -mode fill in
+// the options that are not given. The command passes the flags that were
+// set, once its config file is applied; the js/wasm build the options of
+// the page.
+func newCleaningOptions(given map[string]string) (CleaningOptions, error) {
+        values := make(map[string]string)
+        for _, name := range sortedKeys(given) {
+                value := given[name]
+                if _, ok := optionDefaults[name]; !ok {
+                        return CleaningOptions{}, fmt.Errorf("unknown option '%s'", name)
+                }
+                if isBoolOption(name) {
+                        b, err := strconv.ParseBool(value)
+                        if err != nil {
+                                return CleaningOptions{}, fmt.Errorf("invalid value '%s' for option '%s'", value, name)
+                        }
+                        value = strconv.FormatBool(b)
+                }
+                if name == "add-bom" {
+                        name = "ensure-bom"
+                }
+                values[name] = value
+        }
+        if err := applyOptionBundle(values, "profile", builtinProfiles, builtinProfileNames); err != nil {
+                return CleaningOptions{}, err
+        }
+        if err := applyOptionBundle(values, "preset", securityPresets, securityProfiles); err != nil {
+                return CleaningOptions{}, err
+        }
+        if err := applyOptionBundle(values, "mode", cleaningModes, cleaningModeNames); err != nil {
+                return CleaningOptions{}, err
+        }
+
+        get := func(name string) string {
+                if value, ok := values[name]; ok {
+                        return value
+                }
+                return optionDefaults[name]
+        }
+        lookup := func(name string) (string, string, bool, bool) {
+                def, ok := optionDefaults[name]
+                return get(name), def, isBoolOption(name), ok
+        }
+        if err := checkConflicts(lookup); err != nil {
+                return CleaningOptions{}, err
+        }
+        is := func(name string) bool { return get(name) == "true" }
+
+        stripFormat := strings.ToLower(strings.TrimSpace(get("strip")))
+        if stripFormat != "" && stripFormat != "markdown" && stripFormat != "html" && stripFormat != "subtitles" && stripFormat != "auto" {
+                return CleaningOptions{}, fmt.Errorf("invalid strip format '%s'. Valid options: markdown, html, subtitles, auto", stripFormat)
+        }
+
+        normalizeForm := strings.ToLower(strings.TrimSpace(get("normalize-unicode")))
+        if normalizeForm != "" && !validNormalizationForm(normalizeForm) {
+                return CleaningOptions{}, fmt.Errorf("invalid normalization form '%s'. Valid options: %s", normalizeForm, strings.Join(normalizationForms, ", "))
+        }
+
+        replacementChar, err := parseReplacement(get("replacement"))
+        if err != nil {
+                return CleaningOptions{}, err
+        }
+        allowed, err := parseAllowRanges(get("allow-ranges"))
+        if err != nil {
+                return CleaningOptions{}, err
+        }
+
+        keep := make(map[string]bool)
+        for _, name := range optionList(get("strip-keep")) {
+                if name != "alt" && name != "title" && name != "aria-label" {
+                        return CleaningOptions{}, fmt.Errorf("invalid -strip-keep attribute '%s'. Valid options: alt, title, aria-label", name)
+                }
+                keep[name] = true
+        }
+
+        extract := make(map[string]bool)
+        for _, name := range optionList(get("strip-extract")) {
+                if name != "title" && name != "description" && name != "json-ld" {
+                        return CleaningOptions{}, fmt.Errorf("invalid -strip-extract element '%s'. Valid options: title, description, json-ld", name)
+                }
+                extract[name] = true
+        }
+
+        delimiter, err := parseCSVDelimiter(get("csv-delimiter"))
+        if err != nil {
+                return CleaningOptions{}, err
+        }
+        if is("tsv") {
+                breaks := "\t\r\n" + tsvLineBreaks
+                if strings.ContainsAny(replacementChar, breaks) || strings.ContainsAny(get("tsv-newline"), breaks) {
+                        return CleaningOptions{}, errors.New("-replacement and -tsv-newline must not contain tabs or line breaks with -tsv")
+                }
+                if delimiter == 0 {
+                        delimiter = '\t'
+                }
+        }
+
+        emojiMode := get("emoji")
+        if is("remove-emoji") {
+                emojiMode = "remove"
+        }
+        if emojiMode != "" && emojiMode != "remove" && emojiMode != "shortcode" && emojiMode != "unicode" {
+                return CleaningOptions{}, fmt.Errorf("invalid emoji mode '%s'. Valid options: remove, shortcode, unicode", emojiMode)
+        }
+        if !validSoftHyphens(get("soft-hyphens")) {
+                return CleaningOptions{}, fmt.Errorf("invalid soft hyphen mode '%s'. Valid options: remove, hyphen, keep", get("soft-hyphens"))
+        }
+
+        dashes := strings.ToLower(strings.TrimSpace(get("dashes")))
+        if !validDashes(dashes) {
+                return CleaningOptions{}, fmt.Errorf("invalid dash mode '%s'. Valid options: keep, hyphen, double-hyphen", dashes)
+        }
+
+        invalidUTF8 := strings.ToLower(strings.TrimSpace(get("invalid-utf8")))
+        if !validInvalidUTF8(invalidUTF8) {
+                return CleaningOptions{}, fmt.Errorf("invalid -invalid-utf8 mode '%s'. Valid options: replace, remove, error, keep", invalidUTF8)
+        }
+        if is("strict") {
+                invalidUTF8 = "error"
+        }
+        inputEncoding := ""
+        if name := strings.ToLower(strings.TrimSpace(get("input-encoding"))); name != "auto" {
+                var ok bool
+                if inputEncoding, ok = inputEncodings[name]; !ok {
+                        return CleaningOptions{}, fmt.Errorf("invalid input encoding '%s'. Valid options: auto, utf-8, utf-16le, utf-16be, utf-32le, utf-32be, windows-1252, iso-8859-1, iso-8859-15, windows-1251, koi8-r, shift_jis", get("input-encoding"))
+                }
+        }
+
+        warnLineLength, err := strconv.Atoi(get("warn-line-length"))
+        if err != nil {
+                return CleaningOptions{}, fmt.Errorf("invalid value '%s' for option 'warn-line-length'", get("warn-line-length"))
+        }
+        maxSize, err := parseSize(get("max-size"))
+        if err != nil {
+                return CleaningOptions{}, err
+        }
+        oversize := strings.ToLower(strings.TrimSpace(get("oversize")))
+        if !containsString(oversizeActions, oversize) {
+                return CleaningOptions{}, fmt.Errorf("invalid -oversize action '%s'. Valid options: %s", oversize, strings.Join(oversizeActions, ", "))
+        }
+
+        normalizedOS := normalizeTargetOS(get("os"))
+        if normalizedOS == "" {
+                return CleaningOptions{}, fmt.Errorf("invalid target OS '%s'. Valid options: windows, unix, mac, auto", get("os"))
+        }
+
+        _, noLegacyGuess := values["invalid-utf8"]
+        return CleaningOptions{
+                RemoveNonASCII:         is("ascii"),
+                RemoveControlChars:     is("control"),
+                RemoveZeroWidth:        is("zerowidth"),
+                RemoveBOM:              is("bom"),
+                EnsureBOM:              is("ensure-bom"),
+                NormalizeQuotes:        is("normalize-quotes") || is("smart-punct"),
+                SmartPunct:             is("smart-punct"),
+                FixQuotes:              is("fix-quotes"),
+                CSVSafe:                is("csv-safe"),
+                CSVDelimiter:           delimiter,
+                TSV:                    is("tsv"),
+                TSVNewline:             get("tsv-newline"),
+                KeyValue:               is("key-value"),
+                PO:                     is("po"),
+                Chat:                   is("chat"),
+                DropReactions:          is("drop-reactions"),
+                DecodeEscapes:          is("decode-escapes"),
+                NormalizeWhitespace:    is("normalize"),
+                NormalizeUnicode:       normalizeForm,
+                FixMojibake:            is("fix-mojibake"),
+                Transliterate:          is("transliterate"),
+                PreserveNewlines:       is("preserve-newlines"),
+                TargetOS:               normalizedOS,
+                StripFormat:            stripFormat,
+                StripKeep:              keep,
+                StripExtract:           extract,
+                Replacement:            replacementChar,
+                AllowRanges:            allowed,
+                KeepLetters:            is("keep-letters"),
+                Emoji:                  emojiMode,
+                StripModifiers:         is("strip-modifiers"),
+                SoftHyphens:            get("soft-hyphens"),
+                Spaces:                 is("spaces"),
+                Dashes:                 dashes,
+                InvalidUTF8:            invalidUTF8,
+                InputEncoding:          inputEncoding,
+                NoLegacyGuess:          noLegacyGuess,
+                Strict:                 is("strict"),
+                WarnLineLength:         warnLineLength,
+                MaxSize:                maxSize,
+                Oversize:               oversize,
+                TrimTrailingWhitespace: is("trim-trailing"),
+                InsertFinalNewline:     is("final-newline"),
+                PreserveLines:          is("preserve-lines"),
+                SecurityProfile:        get("preset"),
+        }, nil
+}
+
+// applyOptionBundle adds the options of the bundle that option selects to
+// values, except those already given
+func applyOptionBundle(values map[string]string, option string, bundles map[string]map[string]string, names []string) error {
+        value := strings.ToLower(strings.TrimSpace(values[option]))
+        if value == "" {
+                return nil
+        }
+        bundle, ok := bundles[value]
+        if !ok {
+                return fmt.Errorf("invalid %s '%s'. Valid options: %s", option, value, strings.Join(names, ", "))
+        }
+        values[option] = value
+        for key, v := range bundle {
+                if _, given := values[key]; !given {
+                        values[key] = v
+                }
+        }
+        return nil
+}
+
+// optionList splits a comma-separated option value such as that of
+// -strip-keep into its lowercase items
+func optionList(value string) []string {
+        var items []string
+        for _, item := range strings.Split(value, ",") {
+                if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+                        items = append(items, item)
+                }
+        }
+        return items
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+        keys := make([]string, 0, len(m))
+        for k := range m {
+                keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        return keys
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+        "bufio"
+        "fmt"
+        "io"
+        "os"
+        "regexp"
+        "strconv"
+        "strings"
+)
+
+var hunkRangePattern = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// runPatch implements the patch subcommand: it cleans only the lines a
+// unified diff adds and writes the rewritten diff, so that a change can be
+// cleaned without touching the lines around it
+func runPatch(args []string) int {
+        fs := newSubcommandFlags("patch")
+        outputFile := fs.String("output", "", "Write the rewritten diff to this file instead of stdout")
+        check := fs.Bool("check", false, "Only list what would be removed from the added lines (as file:line:column); exit 1 if anything would")
+        removeNonASCII := fs.Bool("ascii", true, "Remove non-ASCII characters")
+        removeControl := fs.Bool("control", true, "Remove control characters (except tabs)")
+        removeZeroWidth := fs.Bool("zerowidth", true, "Remove zero-width characters")
+        removeBOM := fs.Bool("bom", true, "Remove Byte Order Mark (BOM)")
+        trimTrailing := fs.Bool("trim-trailing", false, "Remove trailing spaces and tabs from added lines")
+        normalizeUnicodeForm := fs.String("normalize-unicode", "", "Convert added lines to a Unicode normalization form: nfc, nfd, nfkc or nfkd")
+        replacement := fs.String("replacement", "", "Character written in place of each removed character, e.g. ? or U+FFFD (default: delete)")
+        keepLetters := fs.Bool("keep-letters", false, "With -ascii, keep letters of any script (and their accents)")
+        stripModifiers := fs.Bool("strip-modifiers", false, "Remove variation selectors, skin tone modifiers and zero width joiners that join nothing")
+        spaces := fs.Bool("spaces", false, "Replace Unicode spaces with an ASCII space instead of removing them")
+        softHyphens := fs.String("soft-hyphens", "remove", "What to do with soft hyphens (U+00AD): remove, hyphen or keep")
+        dashes := fs.String("dashes", "", "What to do with dashes, hyphens and minus signs: keep, hyphen or double-hyphen")
+        removeEmoji := fs.Bool("remove-emoji", false, "Remove emoji, taking flags, keycaps and ZWJ sequences as a whole")
+        allowRanges := fs.String("allow-ranges", "", "Comma-separated code point ranges to keep even when their category is removed (or @file)")
+        verbose := fs.Bool("verbose", false, "Print a summary to stderr")
+        paths := parseInterspersed(fs, args)
+
+        if len(paths) > 1 {
+                exitWithUsage(fs, "at most one diff file can be given")
+        }
+        *normalizeUnicodeForm = strings.ToLower(strings.TrimSpace(*normalizeUnicodeForm))
+        if *normalizeUnicodeForm != "" && !validNormalizationForm(*normalizeUnicodeForm) {
+                exitWithUsage(fs, "invalid normalization form '%s' (valid options: %s)", *normalizeUnicodeForm, strings.Join(normalizationForms, ", "))
+        }
+        replacementChar, err := parseReplacement(*replacement)
+        if err != nil {
+                exitWithUsage(fs, "%v", err)
+        }
+        allowed, err := parseAllowRanges(*allowRanges)
+        if err != nil {
+                exitWithUsage(fs, "%v", err)
+        }
+        if !validSoftHyphens(*softHyphens) {
+                exitWithUsage(fs, "invalid soft hyphen mode '%s' (valid options: remove, hyphen, keep)", *softHyphens)
+        }
+        *dashes = strings.ToLower(strings.TrimSpace(*dashes))
+        if !validDashes(*dashes) {
+                exitWithUsage(fs, "invalid dash mode '%s' (valid options: keep, hyphen, double-hyphen)", *dashes)
+        }
+
+        options := CleaningOptions{
+                RemoveNonASCII:         *removeNonASCII,
+                RemoveControlChars:     *removeControl,
+                RemoveZeroWidth:        *removeZeroWidth,
+                RemoveBOM:              *removeBOM,
+                NormalizeUnicode:       *normalizeUnicodeForm,
+                Replacement:            replacementChar,
+                AllowRanges:            allowed,
+                KeepLetters:            *keepLetters,
+                StripModifiers:         *stripModifiers,
+                SoftHyphens:            *softHyphens,
+                Spaces:                 *spaces,
+                Dashes:                 *dashes,
+                TrimTrailingWhitespace: *trimTrailing,
+                RecordLocations:        *check,
+        }
+        if *removeEmoji {
+                options.Emoji = "remove"
+        }
+
+        var in io.Reader = os.Stdin
+        if len(paths) == 1 && paths[0] != "-" {
+                f, err := os.Open(paths[0])
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "Error: could not read diff: %v\n", err)
+                        return 1
+                }
+                defer f.Close()
+                in = f
+        }
+
+        var out io.Writer = io.Discard
+        var sink OutputSink = &discardSink{}
+        if !*check {
+                sink = &stdoutSink{}
+                if *outputFile != "" {
+                        sink = &fileSink{Path: *outputFile}
+                }
+                if out, err = sink.Open(); err != nil {
+                        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+                        return 1
+                }
+        }
+
+        writer := bufio.NewWriter(out)
+        result, err := cleanPatch(in, writer, options)
+        if err == nil {
+                err = writer.Flush()
+        }
+        if err != nil {
+                sink.Abort()
+                fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+                return 1
+        }
+        if err := sink.Close(); err != nil {
+                fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+                return 1
+        }
+
+        if *check {
+                for _, f := range result {
+                        for _, loc := range f.Stats.Locations {
+                                fmt.Println(formatFinding(f.InputPath, loc))
+                        }
+                }
+        }
+
+        changed := 0
+        for _, f := range result {
+                if f.Stats.changed() {
+                        changed++
+                }
+        }
+        if *verbose {
+                total := &CleaningStats{RemovedCharDetails: make(map[rune]int)}
+                for _, f := range result {
+                        mergeStats(total, f.Stats)
+                }
+                fmt.Fprintf(os.Stderr, "%d added line(s) in %d file(s) cleaned, %d character(s) removed, %d file(s) changed\n",
+                        total.LinesProcessed, len(result), total.RemovedChars, changed)
+        }
+        if *check && changed > 0 {
+                return 1
+        }
+        return 0
+}
+
+// cleanPatch copies a unified diff from r to w, cleaning the added lines.
+// Hunk headers stay valid because cleaning never adds or removes lines.
+// The result has one entry per file in the diff with the statistics of its
+// added lines; removed character locations use new-file line numbers.
+func cleanPatch(r io.Reader, w io.Writer, options CleaningOptions) ([]FileResult, error) {
+        reader := bufio.NewReader(r)
+        var results []FileResult
+        var current *CleaningStats
+        oldLeft, newLeft, newLine := 0, 0, 0
+
+        for lineNum := 1; ; lineNum++ {
+                line, err := reader.ReadString('\n')
+                if line == "" && err != nil {
+                        if err == io.EOF {
+                                return results, nil
+                        }
+                        return nil, fmt.Errorf("could not read diff: %w", err)
+                }
+
+                inHunk := oldLeft > 0 || newLeft > 0
+                switch {
+                case !inHunk && strings.HasPrefix(line, "+++ "):
+                        path := strings.TrimRight(strings.TrimPrefix(line, "+++ "), "\r\n")
+                        path, _, _ = strings.Cut(path, "\t")
+                        path = strings.TrimPrefix(path, "b/")
+                        results = append(results, FileResult{InputPath: path, Stats: &CleaningStats{RemovedCharDetails: make(map[rune]int)}})
+                        current = results[len(results)-1].Stats
+
+                case !inHunk && strings.HasPrefix(line, "@@ "):
+                        m := hunkRangePattern.FindStringSubmatch(line)
+                        if m == nil || current == nil {
+                                return nil, fmt.Errorf("line %d: invalid hunk header", lineNum)
+                        }
+                        oldLeft, newLeft = hunkLength(m[1]), hunkLength(m[3])
+                        newLine, _ = strconv.Atoi(m[2])
+
+                case inHunk && strings.HasPrefix(line, "+"):
+                        content, ending := splitLineEnding(line[1:])
+                        line = "+" + cleanPatchLine(current, content, newLine, options) + ending
+                        newLeft--
+                        newLine++
+
+                case inHunk && strings.HasPrefix(line, "-"):
+                        oldLeft--
+
+                case inHunk && (strings.HasPrefix(line, " ") || line == "\n" || line == "\r\n"):
+                        // Some tools drop the space of empty context lines
+                        oldLeft--
+                        newLeft--
+                        newLine++
+
+                case inHunk && !strings.HasPrefix(line, `\`):
+                        return nil, fmt.Errorf("line %d: hunk ends early", lineNum)
+                }
+
+                if _, err := io.WriteString(w, line); err != nil {
+                        return nil, fmt.Errorf("error writing to output: %w", err)
+                }
+        }
+}
+
+// hunkLength parses the optional line count of a hunk range, which is 1
+// when omitted
+func hunkLength(count string) int {
+        if count == "" {
+                return 1
+        }
+        n, _ := strconv.Atoi(count)
+        return n
+}
+
+func splitLineEnding(line string) (string, string) {
+        if content, ok := strings.CutSuffix(line, "\r\n"); ok {
+                return content, "\r\n"
+        }
+        if content, ok := strings.CutSuffix(line, "\n"); ok {
+                return content, "\n"
+        }
+        return line, ""
+}
+
+// cleanPatchLine cleans the content of one added line and adds its
+// statistics to stats
+func cleanPatchLine(stats *CleaningStats, content string, lineNum int, options CleaningOptions) string {
+        if options.NormalizeUnicode != "" {
+                var normalized int
+                content, normalized = normalizeUnicode(content, options.NormalizeUnicode)
+                stats.UnicodeNormalized += normalized
+        }
+
+        cleaned, lineStats := cleanString(content, options)
+        stats.LinesProcessed++
+        stats.TotalChars += lineStats.TotalChars
+        stats.RemovedChars += lineStats.RemovedChars
+        stats.NonASCIIRemoved += lineStats.NonASCIIRemoved
+        stats.AllowedKept += lineStats.AllowedKept
+        stats.ControlCharsRemoved += lineStats.ControlCharsRemoved
+        stats.ZeroWidthRemoved += lineStats.ZeroWidthRemoved
+        stats.EmojiRemoved += lineStats.EmojiRemoved
+        stats.EmojiCharsRemoved += lineStats.EmojiCharsRemoved
+        stats.EmojiConverted += lineStats.EmojiConverted
+        stats.ModifiersRemoved += lineStats.ModifiersRemoved
+        stats.SoftHyphensRemoved += lineStats.SoftHyphensRemoved
+        stats.SoftHyphensConverted += lineStats.SoftHyphensConverted
+        stats.SpacesConverted += lineStats.SpacesConverted
+        stats.DashesConverted += lineStats.DashesConverted
+        for char, count := range lineStats.RemovedCharDetails {
+                stats.RemovedCharDetails[char] += count
+        }
+        if lineStats.RemovedChars > 0 {
+                stats.LinesWithIssues++
+        }
+        for _, loc := range lineStats.Locations {
+                if len(stats.Locations) < maxLocations {
+                        loc.Line = lineNum
+                        stats.Locations = append(stats.Locations, loc)
+                }
+        }
+
+        if options.TrimTrailingWhitespace {
+                var trimmed int
+                cleaned, trimmed = trimTrailingWhitespace(cleaned, "")
+                stats.TrailingWhitespaceTrimmed += trimmed
+        }
+        return cleaned
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+        "fmt"
+        "os"
+        "path/filepath"
+        "reflect"
+        "strconv"
+        "strings"
+        "sync"
+        "time"
+)
+
+// parseOutputMode parses the octal permissions of -output-mode, such as
+// 0644 or 600; "" selects none
+func parseOutputMode(s string) (os.FileMode, error) {
+        s = strings.TrimSpace(s)
+        if s == "" {
+                return 0, nil
+        }
+        mode, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+        if err != nil || mode == 0 || mode > 0777 {
+                return 0, fmt.Errorf("invalid output mode '%s'. Expected octal permissions such as 0644 or 0600", s)
+        }
+        return os.FileMode(mode), nil
+}
+
+// inputPerm returns the permissions of the file a copy is made from, or nil
+// (none to copy) for standard input or a file that cannot be stat'ed
+func inputPerm(path string) *os.FileMode {
+        if path == "" || path == "-" {
+                return nil
+        }
+        info, err := os.Stat(path)
+        if err != nil {
+                return nil
+        }
+        perm := info.Mode().Perm()
+        return &perm
+}
+
+// umask returns the permission bits the umask of the process takes away
+// from new files. Go has no portable way to read it, so it is seen in the
+// permissions of a file created with 0777 in a private temporary
+// directory; if that fails, the common 022 is assumed.
+var umask = sync.OnceValue(func() os.FileMode {
+        dir, err := os.MkdirTemp("", "cleanfile-umask-*")
+        if err != nil {
+                return 0022
+        }
+        defer os.RemoveAll(dir)
+        f, err := os.OpenFile(filepath.Join(dir, "probe"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0777)
+        if err != nil {
+                return 0022
+        }
+        defer f.Close()
+        info, err := f.Stat()
+        if err != nil {
+                return 0022
+        }
+        return 0777 &^ info.Mode().Perm()
+})
+
+// createFile creates or truncates a file for writing. With mode, the file
+// gets exactly those permissions, whatever the umask. Otherwise it gets
+// source, the permissions of the file it is a copy of, less the umask, so
+// that a cleaned script keeps its execute bits and a copy of a private
+// file, such as a backup of a .env file, is never readable by others. A nil
+// source (standard input) gives 0666 less the umask, as os.Create does.
+// The permissions are set even if the file exists, such as a temporary
+// file made by createTempFor, which is always private, but not on a device
+// or pipe such as /dev/null.
+func createFile(path string, mode os.FileMode, source *os.FileMode) (*os.File, error) {
+        perm := mode
+        if perm == 0 {
+                perm = 0666
+                if source != nil {
+                        perm = *source
+                }
+                perm &^= umask()
+        }
+        f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+        if err != nil {
+                return nil, err
+        }
+        info, err := f.Stat()
+        if err != nil {
+                f.Close()
+                return nil, err
+        }
+        if info.Mode().IsRegular() && info.Mode().Perm() != perm {
+                if err := f.Chmod(perm); err != nil {
+                        f.Close()
+                        return nil, fmt.Errorf("could not set permissions: %w", err)
+                }
+        }
+        return f, nil
+}
+
+// fileTimes returns the access and modification times of a file. The
+// access time lives in the platform-specific info.Sys() (Atim on Linux,
+// Atimespec on macOS) and is looked up by name, as in sameDevice; where it
+// is not found, the zero time leaves it unchanged with os.Chtimes.
+func fileTimes(info os.FileInfo) (atime, mtime time.Time) {
+        sys := reflect.ValueOf(info.Sys())
+        if sys.Kind() == reflect.Ptr {
+                sys = sys.Elem()
+        }
+        if sys.Kind() == reflect.Struct {
+                for _, name := range []string{"Atim", "Atimespec"} {
+                        spec := sys.FieldByName(name)
+                        if !spec.IsValid() || spec.Kind() != reflect.Struct {
+                                continue
+                        }
+                        sec, nsec := spec.FieldByName("Sec"), spec.FieldByName("Nsec")
+                        if sec.IsValid() && nsec.IsValid() && sec.CanInt() && nsec.CanInt() {
+                                atime = time.Unix(sec.Int(), nsec.Int())
+                                break
+                        }
+                }
+        }
+        return atime, info.ModTime()
+}
+
+// fileOwner returns the user and group IDs of a file, from the Uid and Gid
+// fields of info.Sys() on Unix; ok is false elsewhere
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+        sys := reflect.ValueOf(info.Sys())
+        if sys.Kind() == reflect.Ptr {
+                sys = sys.Elem()
+        }
+        if sys.Kind() != reflect.Struct {
+                return 0, 0, false
+        }
+        u, g := sys.FieldByName("Uid"), sys.FieldByName("Gid")
+        if !u.IsValid() || !g.IsValid() || !u.CanUint() || !g.CanUint() {
+                return 0, 0, false
+        }
+        return int(u.Uint()), int(g.Uint()), true
+}
+
+// preserveAttributes gives a written file the modification and access
+// times (-preserve-times) and the owner and group (-preserve-owner) of the
+// input, as stat'ed before it was read. Failures, such as changing the
+// owner without the privileges to, are reported as warnings.
+func preserveAttributes(dst string, source os.FileInfo, options CleaningOptions, stats *CleaningStats) {
+        if source == nil {
+                return
+        }
+        if options.PreserveOwner {
+                if uid, gid, ok := fileOwner(source); ok {
+                        if err := os.Lchown(dst, uid, gid); err != nil {
+                                stats.warn(warnLostMetadata, 0, "could not preserve the owner: %v", err)
+                        }
+                }
+        }
+        if options.PreserveTimes {
+                atime, mtime := fileTimes(source)
+                if err := os.Chtimes(dst, atime, mtime); err != nil {
+                        stats.warn(warnLostMetadata, 0, "could not preserve the modification time: %v", err)
+                }
+        }
+}
@@ -0,0 +1,59 @@
+package main
+
+import "strings"
+
+// poState follows the entries of a gettext .po or .pot file line by line,
+// so that only translations (msgstr strings) are cleaned
+type poState struct {
+        inMsgid    bool // continuation strings belong to a msgid or msgid_plural
+        inMsgstr   bool // continuation strings belong to a translation
+        hasContext bool // the entry has a msgctxt
+        emptyMsgid bool // the entry's msgid is "", so far
+        header     bool // the current msgstr is the header entry
+}
+
+// split returns the part of a line that is kept byte for byte and whether
+// the rest is cleaned. Comments (including #, flags and #~ obsolete
+// entries), msgctxt, msgid and msgid_plural are kept whole, and so is the
+// header entry, whose Plural-Forms and Content-Type programs depend on. Of
+// a msgstr line, everything up to the opening quote is kept.
+func (p *poState) split(content string) (keep string, clean bool) {
+        trimmed := strings.TrimLeft(content, " \t")
+        switch {
+        case trimmed == "":
+                // A blank line ends the entry
+                *p = poState{}
+        case strings.HasPrefix(trimmed, "#"):
+        case strings.HasPrefix(trimmed, "msgctxt"):
+                *p = poState{hasContext: true}
+        case strings.HasPrefix(trimmed, "msgid_plural"):
+                p.inMsgid = true
+                p.emptyMsgid = false
+        case strings.HasPrefix(trimmed, "msgid"):
+                rest := strings.TrimSpace(strings.TrimPrefix(trimmed, "msgid"))
+                *p = poState{inMsgid: true, hasContext: p.hasContext, emptyMsgid: !p.hasContext && rest == `""`}
+        case strings.HasPrefix(trimmed, "msgstr"):
+                p.header = p.inMsgid && p.emptyMsgid || p.inMsgstr && p.header
+                p.inMsgid = false
+                p.inMsgstr = true
+                return p.splitString(content)
+        case strings.HasPrefix(trimmed, `"`):
+                if p.inMsgstr {
+                        return p.splitString(content)
+                }
+                if p.inMsgid && strings.TrimSpace(trimmed) != `""` {
+                        p.emptyMsgid = false
+                }
+        }
+        return content, false
+}
+
+// splitString keeps a msgstr line up to its opening quote, or whole if it
+// belongs to the header
+func (p *poState) splitString(content string) (string, bool) {
+        quote := strings.IndexByte(content, '"')
+        if p.header || quote < 0 {
+                return content, false
+        }
+        return content[:quote+1], true
+}
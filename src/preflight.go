@@ -0,0 +1,184 @@
+package main
+
+import (
+        "fmt"
+        "os"
+        "path/filepath"
+        "strings"
+)
+
+// permissionChange is a temporary chmod made by -make-writable
+type permissionChange struct {
+        Path string
+        Mode os.FileMode
+}
+
+// preflight checks, before any file is touched, that every file can be read
+// and that everything the run will write can be written: the file itself
+// for -in-place, its directory for the temporary file that replaces it,
+// _cleaned outputs and backups, and -output-dir and -backup-dir. Unless
+// batch.Force, it also checks that no output, backup or undo patch would
+// replace an existing file. It returns the problems found per file. With
+// batch.MakeWritable, read-only input files and their directories are made
+// writable instead of being reported; the returned changes undo that.
+func preflight(files []string, batch BatchOptions, options CleaningOptions, backup bool) (map[string]error, []permissionChange) {
+        problems := make(map[string]error)
+        var changes []permissionChange
+        dirs := make(map[string]error)
+
+        // Outputs and backups kept apart from the inputs go into directories
+        // created as needed, so the nearest existing one must be writable
+        var treeErr error
+        if !batch.CheckOnly && !batch.InPlace && batch.OutputDir != "" {
+                if err := checkTreeWritable(batch.OutputDir); err != nil {
+                        treeErr = fmt.Errorf("output directory %s is not writable: %w", batch.OutputDir, err)
+                }
+        }
+        if treeErr == nil && !batch.CheckOnly && backup && options.BackupDir != "" {
+                if err := checkTreeWritable(options.BackupDir); err != nil {
+                        treeErr = fmt.Errorf("backup directory %s is not writable: %w", options.BackupDir, err)
+                }
+        }
+
+        for _, path := range files {
+                f, err := os.Open(path)
+                if err != nil {
+                        problems[path] = fmt.Errorf("%s: cannot be read: %w", path, err)
+                        continue
+                }
+                f.Close()
+
+                if batch.CheckOnly {
+                        continue
+                }
+                if treeErr != nil {
+                        problems[path] = fmt.Errorf("%s: %w", path, treeErr)
+                        continue
+                }
+
+                if !batch.Force {
+                        target := path
+                        if !batch.InPlace {
+                                target = batchOutputPath(path, batch)
+                                err = refuseOverwrite(target, "output file")
+                        }
+                        if err == nil && backup && options.BackupName == "plain" {
+                                err = refuseOverwrite(backupPath(path, batch.Root, options), "backup")
+                        }
+                        if err == nil && options.UndoPatch {
+                                err = refuseOverwrite(target+undoPatchSuffix, "undo patch")
+                        }
+                        if err != nil {
+                                problems[path] = fmt.Errorf("%s: %w", path, err)
+                                continue
+                        }
+                }
+
+                if batch.InPlace {
+                        info, err := os.Stat(path)
+                        if err != nil {
+                                problems[path] = fmt.Errorf("%s: %w", path, err)
+                                continue
+                        }
+                        if info.Mode().Perm()&0200 == 0 {
+                                if !batch.MakeWritable {
+                                        problems[path] = fmt.Errorf("%s: file is read-only (use -make-writable to clean it anyway)", path)
+                                        continue
+                                }
+                                change, err := makeWritable(path)
+                                if err != nil {
+                                        problems[path] = fmt.Errorf("%s: could not make file writable: %w", path, err)
+                                        continue
+                                }
+                                changes = append(changes, change)
+                        }
+                }
+
+                // A file cleaned in place is replaced by renaming a temporary
+                // file over it, and _cleaned outputs and backups are written
+                // next to it; only outputs under -output-dir and backups
+                // under -backup-dir need no room there
+                if batch.InPlace || batch.OutputDir == "" || backup && options.BackupDir == "" {
+                        dir := filepath.Dir(path)
+                        err, checked := dirs[dir]
+                        if !checked {
+                                err = checkDirWritable(dir)
+                                if err != nil && batch.MakeWritable {
+                                        if change, chmodErr := makeWritable(dir); chmodErr == nil {
+                                                changes = append(changes, change)
+                                                err = checkDirWritable(dir)
+                                        }
+                                }
+                                dirs[dir] = err
+                        }
+                        if err != nil {
+                                problems[path] = fmt.Errorf("%s: directory is not writable: %w", path, err)
+                        }
+                }
+        }
+        return problems, changes
+}
+
+// checkDirWritable tries to create a file in dir, which is the only reliable
+// test across platforms and for privileged users
+func checkDirWritable(dir string) error {
+        f, err := os.CreateTemp(dir, ".cleanfile-preflight-*")
+        if err != nil {
+                return err
+        }
+        f.Close()
+        return os.Remove(f.Name())
+}
+
+// checkTreeWritable checks that dir, or if it does not exist yet the
+// nearest directory above it that does, can take new files
+func checkTreeWritable(dir string) error {
+        for {
+                info, err := os.Stat(dir)
+                if err == nil {
+                        if !info.IsDir() {
+                                return fmt.Errorf("%s is not a directory", dir)
+                        }
+                        return checkDirWritable(dir)
+                }
+                if !os.IsNotExist(err) {
+                        return err
+                }
+                parent := filepath.Dir(dir)
+                if parent == dir {
+                        return err
+                }
+                dir = parent
+        }
+}
+
+func makeWritable(path string) (permissionChange, error) {
+        info, err := os.Stat(path)
+        if err != nil {
+                return permissionChange{}, err
+        }
+        if err := os.Chmod(path, info.Mode().Perm()|0200); err != nil {
+                return permissionChange{}, err
+        }
+        return permissionChange{Path: path, Mode: info.Mode().Perm()}, nil
+}
+
+// restorePermissions undoes the changes made by preflight
+func restorePermissions(changes []permissionChange) {
+        for _, change := range changes {
+                if err := os.Chmod(change.Path, change.Mode); err != nil {
+                        fmt.Printf("%s Could not restore permissions of %s: %v\n", colorize("Warning:", ansiYellow), change.Path, err)
+                }
+        }
+}
+
+// preflightError lists every problem found by preflight, in file order
+func preflightError(files []string, problems map[string]error) error {
+        var messages []string
+        for _, path := range files {
+                if err := problems[path]; err != nil {
+                        messages = append(messages, err.Error())
+                }
+        }
+        return fmt.Errorf("%d file(s) cannot be processed:\n   %s", len(messages), strings.Join(messages, "\n   "))
+}
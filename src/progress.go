@@ -0,0 +1,157 @@
+package main
+
+import (
+        "fmt"
+        "io"
+        "os"
+        "strings"
+        "sync"
+        "sync/atomic"
+        "time"
+)
+
+// progressDelay is how long a run goes before the progress bar appears, so
+// that quick runs print nothing; progressInterval is how often it is redrawn
+const (
+        progressDelay    = time.Second
+        progressInterval = 200 * time.Millisecond
+        progressBarWidth = 24
+)
+
+// progressMeter shows a progress bar on standard error while files are
+// cleaned: the bytes read of the total, the files finished and an estimate
+// of the time left. Its methods may be called concurrently by the workers
+// of a batch, and do nothing on a nil meter.
+type progressMeter struct {
+        out        io.Writer
+        files      int
+        totalBytes int64
+        start      time.Time
+
+        bytes int64 // read so far, updated atomically
+        done  int64 // files finished, updated atomically
+
+        stop    chan struct{}
+        stopped sync.WaitGroup
+        shown   bool
+}
+
+// startProgress starts a meter for files of totalBytes bytes in all (0 if
+// not known, as for standard input). It returns nil, showing nothing, if
+// standard error is not a terminal.
+func startProgress(files int, totalBytes int64) *progressMeter {
+        if os.Getenv("TERM") == "dumb" || !isTerminal(os.Stderr) {
+                return nil
+        }
+        p := &progressMeter{out: os.Stderr, files: files, totalBytes: totalBytes, start: time.Now(), stop: make(chan struct{})}
+        p.stopped.Add(1)
+        go p.run()
+        return p
+}
+
+// totalSize returns the combined size of files, skipping those that cannot
+// be accessed
+func totalSize(files []string) int64 {
+        var total int64
+        for _, path := range files {
+                if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+                        total += info.Size()
+                }
+        }
+        return total
+}
+
+func (p *progressMeter) run() {
+        defer p.stopped.Done()
+        delay := time.NewTimer(progressDelay)
+        defer delay.Stop()
+        select {
+        case <-p.stop:
+                return
+        case <-delay.C:
+        }
+        ticker := time.NewTicker(progressInterval)
+        defer ticker.Stop()
+        for {
+                p.draw()
+                select {
+                case <-p.stop:
+                        return
+                case <-ticker.C:
+                }
+        }
+}
+
+// draw writes the progress line over the previous one
+func (p *progressMeter) draw() {
+        p.shown = true
+        read := atomic.LoadInt64(&p.bytes)
+        done := atomic.LoadInt64(&p.done)
+        elapsed := time.Since(p.start)
+
+        var line strings.Builder
+        if p.totalBytes > 0 {
+                if read > p.totalBytes {
+                        read = p.totalBytes
+                }
+                fraction := float64(read) / float64(p.totalBytes)
+                filled := int(fraction * progressBarWidth)
+                fmt.Fprintf(&line, "[%s%s] %3.0f%% %s / %s", strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled),
+                        fraction*100, formatSize(read), formatSize(p.totalBytes))
+        } else {
+                fmt.Fprintf(&line, "%s read", formatSize(read))
+        }
+        if p.files > 1 {
+                fmt.Fprintf(&line, ", %d/%d files", done, p.files)
+        }
+        if p.totalBytes > 0 && read > 0 && read < p.totalBytes {
+                left := time.Duration(float64(elapsed) * float64(p.totalBytes-read) / float64(read))
+                fmt.Fprintf(&line, ", ETA %s", left.Round(time.Second))
+        }
+        fmt.Fprintf(p.out, "\r%s\033[K", line.String())
+}
+
+// Write counts what cleaning reads, which it writes to options.Progress,
+// towards the bytes processed
+func (p *progressMeter) Write(b []byte) (int, error) {
+        if p != nil {
+                atomic.AddInt64(&p.bytes, int64(len(b)))
+        }
+        return len(b), nil
+}
+
+// skip counts n bytes as processed without reading them, for files the
+// cache knew to be clean
+func (p *progressMeter) skip(n int64) {
+        if p != nil {
+                atomic.AddInt64(&p.bytes, n)
+        }
+}
+
+// skipProgress counts n bytes as processed by the meter of options, if it
+// has one, for input that is not read
+func skipProgress(options CleaningOptions, n int64) {
+        if p, ok := options.Progress.(*progressMeter); ok {
+                p.skip(n)
+        }
+}
+
+// fileDone counts a finished file, whether it was cleaned or failed
+func (p *progressMeter) fileDone() {
+        if p != nil {
+                atomic.AddInt64(&p.done, 1)
+        }
+}
+
+// finish stops the meter and clears its line, so the report that follows
+// starts on a clean line
+func (p *progressMeter) finish() {
+        if p == nil {
+                return
+        }
+        close(p.stop)
+        p.stopped.Wait()
+        if p.shown {
+                fmt.Fprint(p.out, "\r\033[K")
+        }
+}
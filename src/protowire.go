@@ -0,0 +1,121 @@
+package main
+
+import (
+        "encoding/binary"
+        "errors"
+        "sort"
+)
+
+// Protocol buffers wire types
+const (
+        wireVarint  = 0
+        wireFixed64 = 1
+        wireBytes   = 2
+        wireFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("truncated protocol buffers message")
+
+// protoMessage encodes a protocol buffers message field by field, for the
+// few messages of cleanfile.proto. As in proto3, fields with their default
+// value are left out.
+type protoMessage []byte
+
+func (m *protoMessage) tag(field, wire int) {
+        *m = binary.AppendUvarint(*m, uint64(field)<<3|uint64(wire))
+}
+
+func (m *protoMessage) int(field int, v int64) {
+        if v != 0 {
+                m.tag(field, wireVarint)
+                *m = binary.AppendUvarint(*m, uint64(v))
+        }
+}
+
+func (m *protoMessage) bool(field int, v bool) {
+        if v {
+                m.int(field, 1)
+        }
+}
+
+func (m *protoMessage) bytes(field int, v []byte) {
+        if len(v) > 0 {
+                m.embed(field, v)
+        }
+}
+
+func (m *protoMessage) string(field int, v string) {
+        m.bytes(field, []byte(v))
+}
+
+// embed adds a length-delimited field even when it is empty, as a message
+// field is present whether or not its own fields are set
+func (m *protoMessage) embed(field int, v []byte) {
+        m.tag(field, wireBytes)
+        *m = binary.AppendUvarint(*m, uint64(len(v)))
+        *m = append(*m, v...)
+}
+
+// countMap adds a map<string, int64> field, one entry per key in order
+func (m *protoMessage) countMap(field int, counts map[string]int) {
+        keys := make([]string, 0, len(counts))
+        for key := range counts {
+                keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        for _, key := range keys {
+                var entry protoMessage
+                entry.string(1, key)
+                entry.int(2, int64(counts[key]))
+                m.embed(field, entry)
+        }
+}
+
+// protoFields calls fn with each field of an encoded message: its number,
+// its wire type and its value, in v for varints and in b for
+// length-delimited fields. Fixed-size fields are skipped.
+func protoFields(data []byte, fn func(field, wire int, v uint64, b []byte) error) error {
+        for len(data) > 0 {
+                key, n := binary.Uvarint(data)
+                if n <= 0 {
+                        return errProtoTruncated
+                }
+                data = data[n:]
+                field, wire := int(key>>3), int(key&7)
+                var v uint64
+                var b []byte
+                switch wire {
+                case wireVarint:
+                        v, n = binary.Uvarint(data)
+                        if n <= 0 {
+                                return errProtoTruncated
+                        }
+                        data = data[n:]
+                case wireBytes:
+                        length, n := binary.Uvarint(data)
+                        if n <= 0 || length > uint64(len(data)-n) {
+                                return errProtoTruncated
+                        }
+                        b, data = data[n:n+int(length)], data[n+int(length):]
+                case wireFixed64, wireFixed32:
+                        size := 8
+                        if wire == wireFixed32 {
+                                size = 4
+                        }
+                        if len(data) < size {
+                                return errProtoTruncated
+                        }
+                        data = data[size:]
+                        continue
+                default:
+                        return errors.New("unsupported protocol buffers wire type")
+                }
+                if field == 0 {
+                        return errors.New("invalid protocol buffers field number 0")
+                }
+                if err := fn(field, wire, v, b); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
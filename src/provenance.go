@@ -0,0 +1,79 @@
+package main
+
+import (
+        "path/filepath"
+        "regexp"
+        "strings"
+)
+
+// provenancePattern matches a provenance comment written by an earlier run,
+// which is replaced rather than kept next to the new one
+var provenancePattern = regexp.MustCompile(`^\s*(?:<!--|//|#|--|;|%|/\*) cleaned by cleanfile \S+ at \S+ \(options [0-9a-f]+\)`)
+
+// commentSyntax maps file extensions to the start and end of a line comment
+var commentSyntax = map[string][2]string{
+        ".md": {"<!--", "-->"}, ".markdown": {"<!--", "-->"}, ".html": {"<!--", "-->"}, ".htm": {"<!--", "-->"},
+        ".xhtml": {"<!--", "-->"}, ".xml": {"<!--", "-->"}, ".svg": {"<!--", "-->"}, ".vue": {"<!--", "-->"},
+
+        ".go": {"//"}, ".c": {"//"}, ".h": {"//"}, ".cc": {"//"}, ".cpp": {"//"}, ".hpp": {"//"}, ".cs": {"//"},
+        ".java": {"//"}, ".kt": {"//"}, ".kts": {"//"}, ".scala": {"//"}, ".groovy": {"//"}, ".swift": {"//"},
+        ".rs": {"//"}, ".dart": {"//"}, ".php": {"//"}, ".js": {"//"}, ".mjs": {"//"}, ".cjs": {"//"},
+        ".jsx": {"//"}, ".ts": {"//"}, ".tsx": {"//"},
+
+        ".py": {"#"}, ".rb": {"#"}, ".pl": {"#"}, ".pm": {"#"}, ".r": {"#"}, ".sh": {"#"}, ".bash": {"#"},
+        ".zsh": {"#"}, ".ps1": {"#"}, ".yaml": {"#"}, ".yml": {"#"}, ".toml": {"#"}, ".tf": {"#"},
+        ".properties": {"#"}, ".conf": {"#"}, ".cfg": {"#"}, ".env": {"#"}, ".mk": {"#"},
+        "makefile": {"#"}, "dockerfile": {"#"},
+
+        ".sql": {"--"}, ".lua": {"--"}, ".hs": {"--"},
+        ".ini": {";"},
+        ".tex": {"%"},
+        ".css": {"/*", "*/"}, ".scss": {"/*", "*/"}, ".less": {"/*", "*/"},
+}
+
+// provenanceComment returns the provenance comment for a file, or false if
+// its format has no comment syntax known to cleanfile
+func provenanceComment(path string, options CleaningOptions) (string, bool) {
+        if path == "-" || options.StripFormat != "" || options.CSVSafe || options.TSV {
+                // Stripped text, CSV and TSV have no comments
+                return "", false
+        }
+        name := strings.ToLower(filepath.Base(path))
+        syntax, ok := commentSyntax[filepath.Ext(name)]
+        if !ok {
+                syntax, ok = commentSyntax[name]
+        }
+        if !ok {
+                return "", false
+        }
+        comment := syntax[0] + " " + options.ProvenanceText
+        if syntax[1] != "" {
+                comment += " " + syntax[1]
+        }
+        return comment, true
+}
+
+// mustStayFirst reports whether the first line of a file has to remain the
+// first line: a shebang, an XML declaration or the "---" that starts a YAML
+// document
+func mustStayFirst(path, content string) bool {
+        content = strings.TrimPrefix(content, "\uFEFF")
+        ext := strings.ToLower(filepath.Ext(path))
+        return strings.HasPrefix(content, "#!") || strings.HasPrefix(content, "<?xml") ||
+                (ext == ".yaml" || ext == ".yml") && strings.TrimRight(content, " \t") == "---"
+}
+
+// opensFrontMatter reports whether the first line of a Markdown file opens
+// a block of front matter, "---", which has to stay at the start of the
+// file up to its closing line
+func opensFrontMatter(path, content string) bool {
+        ext := strings.ToLower(filepath.Ext(path))
+        return (ext == ".md" || ext == ".markdown") && strings.TrimRight(strings.TrimPrefix(content, "\uFEFF"), " \t") == "---"
+}
+
+// closesFrontMatter reports whether a line closes a block of front matter:
+// "---", or "..." as YAML also ends a document
+func closesFrontMatter(content string) bool {
+        content = strings.TrimRight(content, " \t")
+        return content == "---" || content == "..."
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+        "encoding/json"
+        "fmt"
+        "io"
+        "time"
+)
+
+// QuarantineReport is the -quarantine file: every character a run removed,
+// with where it was and what surrounded it
+type QuarantineReport struct {
+        Generated time.Time    `json:"generated"`
+        Files     int          `json:"files"`   // files cleaned
+        Removed   int          `json:"removed"` // characters removed
+        Entries   []AuditEntry `json:"characters"`
+}
+
+// writeQuarantine writes the removed characters of the results. Their
+// locations were all recorded, since options.Quarantine lifts the limit of
+// maxLocations.
+func writeQuarantine(w io.Writer, results []FileResult) error {
+        report := QuarantineReport{Generated: time.Now(), Entries: []AuditEntry{}}
+        for _, r := range results {
+                if r.Err != nil {
+                        continue
+                }
+                report.Files++
+                report.Removed += r.Stats.RemovedChars
+                for _, loc := range r.Stats.Locations {
+                        report.Entries = append(report.Entries, AuditEntry{
+                                Path:      r.InputPath,
+                                Line:      loc.Line,
+                                Column:    loc.Column,
+                                Offset:    int64(loc.Offset),
+                                Codepoint: fmt.Sprintf("U+%04X", loc.Char),
+                                Name:      unicodeName(loc.Char),
+                                Category:  generalCategory(loc.Char),
+                                Context:   loc.Context,
+                        })
+                }
+        }
+
+        encoder := json.NewEncoder(w)
+        encoder.SetIndent("", "  ")
+        encoder.SetEscapeHTML(false)
+        if err := encoder.Encode(report); err != nil {
+                return fmt.Errorf("could not write quarantine file: %w", err)
+        }
+        return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import "strings"
+
+// quotePairs pairs the double quotes of a paragraph as -normalize-quotes
+// converts them, so that quotes left unbalanced, often by stripping HTML or
+// by a paste that cut a quotation in half, can be reported. A quotation may
+// span lines; a blank line ends the paragraph.
+type quotePairs struct {
+        open     bool
+        openLine int
+        lowOpen  bool // the open quotation started with „
+}
+
+// check follows the double quotes of a line before their conversion.
+// Curly quotes say which way they face: “ and „ open, ” closes, and “ also
+// closes a quotation opened with „, as in German. A straight quote closes an
+// open quotation and otherwise opens one. With fix, closing quotes that
+// close nothing are removed; the line is returned with the number removed.
+func (q *quotePairs) check(line string, lineNum int, fix bool, stats *CleaningStats) (string, int) {
+        if strings.TrimSpace(line) == "" {
+                q.end(stats)
+                return line, 0
+        }
+
+        var fixed strings.Builder
+        removed := 0
+        for i, r := range line {
+                opens, closes := false, false
+                switch r {
+                case '“':
+                        if q.open && q.lowOpen {
+                                closes = true
+                        } else {
+                                opens = true
+                        }
+                case '„', '‟':
+                        opens = true
+                case '”':
+                        closes = true
+                case '"':
+                        closes = q.open
+                        opens = !q.open
+                }
+
+                switch {
+                case opens && q.open:
+                        stats.warn(warnUnbalancedQuote, q.openLine, "quotation mark is never closed")
+                        q.openLine, q.lowOpen = lineNum, r == '„'
+                case opens:
+                        q.open, q.openLine, q.lowOpen = true, lineNum, r == '„'
+                case closes && q.open:
+                        q.open = false
+                case closes && fix:
+                        if removed == 0 {
+                                fixed.WriteString(line[:i])
+                        }
+                        removed++
+                        continue
+                case closes:
+                        stats.warn(warnUnbalancedQuote, lineNum, "closing quotation mark without an opening one")
+                }
+                if removed > 0 {
+                        fixed.WriteRune(r)
+                }
+        }
+        if removed == 0 {
+                return line, 0
+        }
+        return fixed.String(), removed
+}
+
+// end closes the paragraph, reporting a quotation that is still open
+func (q *quotePairs) end(stats *CleaningStats) {
+        if q.open {
+                stats.warn(warnUnbalancedQuote, q.openLine, "quotation mark is never closed")
+        }
+        *q = quotePairs{}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+        "fmt"
+        "strconv"
+        "strings"
+)
+
+// charRange is an inclusive range of code points
+type charRange struct {
+        Lo, Hi rune
+}
+
+// parseAllowRanges parses the value of -allow-ranges: a comma-separated
+// list of code points and ranges such as "U+00A0-U+00FF,U+2013". The
+// command also reads the list from a file (see readAllowRanges).
+func parseAllowRanges(spec string) ([]charRange, error) {
+        return parseCharRanges(strings.Split(spec, ","), "")
+}
+
+func parseCharRanges(items []string, context string) ([]charRange, error) {
+        var ranges []charRange
+        for _, item := range items {
+                item = strings.TrimSpace(item)
+                if item == "" {
+                        continue
+                }
+                lo, hi, isRange := strings.Cut(item, "-")
+                r := charRange{}
+                var err error
+                if r.Lo, err = parseCodePoint(lo); err == nil {
+                        r.Hi = r.Lo
+                        if isRange {
+                                r.Hi, err = parseCodePoint(hi)
+                        }
+                }
+                if err != nil || r.Hi < r.Lo {
+                        return nil, fmt.Errorf("%sinvalid character range '%s' (expected U+XXXX or U+XXXX-U+YYYY)", context, item)
+                }
+                ranges = append(ranges, r)
+        }
+        return ranges, nil
+}
+
+// parseCodePoint parses "U+00E9" (the U+ is optional)
+func parseCodePoint(s string) (rune, error) {
+        s = strings.TrimSpace(s)
+        if len(s) > 2 && (s[:2] == "U+" || s[:2] == "u+") {
+                s = s[2:]
+        }
+        code, err := strconv.ParseUint(s, 16, 32)
+        if err != nil || code > 0x10FFFF {
+                return 0, fmt.Errorf("invalid code point '%s'", s)
+        }
+        return rune(code), nil
+}
+
+// allowed reports whether r is in one of the ranges kept by -allow-ranges
+func (options CleaningOptions) allowed(r rune) bool {
+        for _, cr := range options.AllowRanges {
+                if r >= cr.Lo && r <= cr.Hi {
+                        return true
+                }
+        }
+        return false
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+        "encoding/json"
+        "fmt"
+        "io"
+        "path/filepath"
+        "unicode/utf8"
+)
+
+// The Reviewdog Diagnostic Format, read by reviewdog and other code review
+// bots: as a single object (rdjson) or one diagnostic per line (rdjsonl).
+// Positions count lines from 1 and columns in bytes of UTF-8, from 1.
+
+type rdPosition struct {
+        Line   int `json:"line"`
+        Column int `json:"column"`
+}
+
+type rdRange struct {
+        Start rdPosition `json:"start"`
+        End   rdPosition `json:"end"`
+}
+
+type rdLocation struct {
+        Path  string  `json:"path"`
+        Range rdRange `json:"range"`
+}
+
+type rdSuggestion struct {
+        Range rdRange `json:"range"`
+        Text  string  `json:"text"`
+}
+
+type rdSource struct {
+        Name string `json:"name"`
+}
+
+type rdCode struct {
+        Value string `json:"value"`
+}
+
+type rdDiagnostic struct {
+        Message     string         `json:"message"`
+        Location    rdLocation     `json:"location"`
+        Severity    string         `json:"severity"`
+        Source      *rdSource      `json:"source,omitempty"`
+        Code        rdCode         `json:"code"`
+        Suggestions []rdSuggestion `json:"suggestions"`
+}
+
+type rdResult struct {
+        Source      rdSource       `json:"source"`
+        Diagnostics []rdDiagnostic `json:"diagnostics"`
+}
+
+// rdSeverities maps the SARIF levels of sarifRules to reviewdog severities
+var rdSeverities = map[string]string{"error": "ERROR", "warning": "WARNING", "note": "INFO"}
+
+// rdDiagnostics converts the locations of removed characters to
+// diagnostics, each with a suggestion that deletes the character, or
+// replaces it with -replacement, so that a review bot can offer the fix
+// inline. With withSource, every diagnostic names cleanfile as its source.
+func rdDiagnostics(results []FileResult, withSource bool) []rdDiagnostic {
+        diagnostics := []rdDiagnostic{}
+        for _, r := range results {
+                if r.Err != nil {
+                        continue
+                }
+                for _, loc := range r.Stats.Locations {
+                        ruleID := sarifRuleID(loc.Char)
+                        span := rdRange{
+                                Start: rdPosition{Line: loc.Line, Column: loc.ByteColumn},
+                                End:   rdPosition{Line: loc.Line, Column: loc.ByteColumn + utf8.RuneLen(loc.Char)},
+                        }
+                        d := rdDiagnostic{
+                                Message:     fmt.Sprintf("U+%04X (%s)", loc.Char, describeChar(loc.Char)),
+                                Location:    rdLocation{Path: filepath.ToSlash(r.InputPath), Range: span},
+                                Severity:    rdSeverities[ruleLevel(ruleID)],
+                                Code:        rdCode{Value: ruleID},
+                                Suggestions: []rdSuggestion{{Range: span, Text: r.Stats.Replacement}},
+                        }
+                        if withSource {
+                                d.Source = &rdSource{Name: "cleanfile"}
+                        }
+                        diagnostics = append(diagnostics, d)
+                }
+        }
+        return diagnostics
+}
+
+// writeRDJSONReport writes the removed characters as a single reviewdog
+// result (rdjson)
+func writeRDJSONReport(w io.Writer, results []FileResult) error {
+        encoder := json.NewEncoder(w)
+        encoder.SetIndent("", "  ")
+        encoder.SetEscapeHTML(false)
+        result := rdResult{Source: rdSource{Name: "cleanfile"}, Diagnostics: rdDiagnostics(results, false)}
+        if err := encoder.Encode(result); err != nil {
+                return fmt.Errorf("could not write rdjson report: %w", err)
+        }
+        return nil
+}
+
+// writeRDJSONLReport writes one reviewdog diagnostic per line (rdjsonl)
+func writeRDJSONLReport(w io.Writer, results []FileResult) error {
+        encoder := json.NewEncoder(w)
+        encoder.SetEscapeHTML(false)
+        for _, d := range rdDiagnostics(results, true) {
+                if err := encoder.Encode(d); err != nil {
+                        return fmt.Errorf("could not write rdjsonl report: %w", err)
+                }
+        }
+        return nil
+}
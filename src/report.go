@@ -0,0 +1,273 @@
+package main
+
+import (
+        "encoding/json"
+        "fmt"
+        "io"
+        "sort"
+        "time"
+)
+
+// reportFormats are the values of -report for clean and check
+var reportFormats = []string{"text", "json", "csv", "sarif", "lint", "rdjson", "rdjsonl"}
+
+// locationReports are the report formats that list every removed character
+// by line and column, and so need CleaningOptions.RecordLocations
+var locationReports = map[string]bool{"sarif": true, "lint": true, "rdjson": true, "rdjsonl": true}
+
+// FileResult holds the outcome of cleaning a single file in batch mode.
+// Err is set if the file could not be cleaned; Stats is then empty, as it
+// is when Cached is set because the cache knew the file to be clean.
+type FileResult struct {
+        InputPath  string
+        OutputPath string
+        Stats      *CleaningStats
+        Err        error
+        Cached     bool
+}
+
+// JSONReport is the machine-readable report written by -report json
+type JSONReport struct {
+        Generated  time.Time    `json:"generated"`
+        TargetOS   string       `json:"target_os"`
+        CheckOnly  bool         `json:"check_only"`
+        Files      []FileReport `json:"files"`
+        Totals     StatsReport  `json:"totals"`
+        Duplicates [][]string   `json:"duplicates,omitempty"`
+}
+
+// FileReport describes the outcome for a single file
+type FileReport struct {
+        Input     string      `json:"input"`
+        Output    string      `json:"output,omitempty"`
+        Changed   bool        `json:"changed"`
+        Stats     StatsReport `json:"stats"`
+        Findings  []Finding   `json:"findings"`
+        Warnings  []Warning   `json:"warnings"`
+        Cells     []CSVCell   `json:"escaped_cells,omitempty"`
+        Rows      []TSVRow    `json:"malformed_rows,omitempty"`
+        Error     string      `json:"error,omitempty"`
+        Cached    bool        `json:"cached,omitempty"`      // skipped, known to be clean (-cache)
+        Unwritten bool        `json:"not_written,omitempty"` // identical to the input, so not written
+}
+
+// StatsReport mirrors CleaningStats with stable JSON field names
+type StatsReport struct {
+        LinesProcessed            int            `json:"lines_processed"`
+        LinesWithIssues           int            `json:"lines_with_issues"`
+        OriginalChars             int            `json:"original_chars"`
+        TotalChars                int            `json:"total_chars"`
+        OutputChars               int            `json:"output_chars"`
+        RemovedChars              int            `json:"removed_chars"`
+        OriginalBytes             int            `json:"original_bytes"`
+        OutputBytes               int            `json:"output_bytes"`
+        RemovedBytes              int            `json:"removed_bytes"`
+        SizeDelta                 int            `json:"size_delta_bytes"`
+        ZeroWidthRemoved          int            `json:"zero_width_removed"`
+        RestrictedRemoved         int            `json:"restricted_removed"`
+        MixedScriptWords          int            `json:"mixed_script_words"`
+        ConfusableWords           int            `json:"confusable_words"`
+        ControlCharsRemoved       int            `json:"control_chars_removed"`
+        NonASCIIRemoved           int            `json:"non_ascii_removed"`
+        EmojiRemoved              int            `json:"emoji_removed"`
+        EmojiCharsRemoved         int            `json:"emoji_chars_removed"`
+        EmojiConverted            int            `json:"emoji_converted"`
+        ModifiersRemoved          int            `json:"modifiers_removed"`
+        SoftHyphensRemoved        int            `json:"soft_hyphens_removed"`
+        SoftHyphensConverted      int            `json:"soft_hyphens_converted"`
+        SpacesConverted           int            `json:"spaces_converted"`
+        DashesConverted           int            `json:"dashes_converted"`
+        ReplyMarkersNormalized    int            `json:"reply_markers_normalized"`
+        ReactionLinesDropped      int            `json:"reaction_lines_dropped"`
+        LineEndingsConverted      int            `json:"line_endings_converted"`
+        LineEndingConversions     map[string]int `json:"line_ending_conversions,omitempty"`
+        OriginalLineEndings       map[string]int `json:"original_line_endings,omitempty"`
+        FormatDetected            string         `json:"format_detected,omitempty"`
+        MarkdownStripped          bool           `json:"markdown_stripped"`
+        HTMLStripped              bool           `json:"html_stripped"`
+        SubtitlesStripped         bool           `json:"subtitles_stripped"`
+        HTMLEntitiesDecoded       int            `json:"html_entities_decoded"`
+        StrippedConstructs        map[string]int `json:"stripped_constructs,omitempty"`
+        UnicodeNormalized         int            `json:"unicode_normalized"`
+        MojibakeRepaired          int            `json:"mojibake_repaired"`
+        Transliterated            int            `json:"transliterated"`
+        InvalidUTF8Sequences      int            `json:"invalid_utf8_sequences"`
+        InvalidUTF8Action         string         `json:"invalid_utf8_action,omitempty"`
+        DecodedFrom               string         `json:"decoded_from,omitempty"`
+        InputEncoding             string         `json:"input_encoding,omitempty"`
+        BOMPresent                bool           `json:"bom_present"`
+        ValidUTF8                 *bool          `json:"valid_utf8,omitempty"`
+        ReplacementCharsAdded     int            `json:"replacement_chars_added"`
+        Replacement               string         `json:"replacement,omitempty"`
+        AllowedKept               int            `json:"allowed_kept"`
+        TrailingWhitespaceTrimmed int            `json:"trailing_whitespace_trimmed"`
+        FinalNewlineAdded         bool           `json:"final_newline_added"`
+        BOMAdded                  bool           `json:"bom_added"`
+        ProvenanceAdded           bool           `json:"provenance_added"`
+        QuotesNormalized          int            `json:"quotes_normalized"`
+        PunctuationConverted      int            `json:"punctuation_converted"`
+        StrayQuotesRemoved        int            `json:"stray_quotes_removed"`
+        FormulasEscaped           int            `json:"formulas_escaped"`
+        NewlinesEscaped           int            `json:"newlines_escaped"`
+        EscapesDecoded            int            `json:"escapes_decoded"`
+        InputHash                 string         `json:"input_sha256,omitempty"`
+        OutputHash                string         `json:"output_sha256,omitempty"`
+}
+
+// Finding is one kind of removed character in a file
+type Finding struct {
+        Codepoint string `json:"codepoint"`
+        Name      string `json:"name"`
+        Category  string `json:"category"`
+        Count     int    `json:"count"`
+}
+
+func newStatsReport(stats *CleaningStats) StatsReport {
+        // Totals have no encoding of their own
+        var validUTF8 *bool
+        if stats.InputEncoding != "" {
+                valid := stats.validUTF8()
+                validUTF8 = &valid
+        }
+        return StatsReport{
+                LinesProcessed:            stats.LinesProcessed,
+                LinesWithIssues:           stats.LinesWithIssues,
+                OriginalChars:             stats.OriginalChars,
+                TotalChars:                stats.TotalChars,
+                OutputChars:               stats.OutputChars,
+                RemovedChars:              stats.RemovedChars,
+                OriginalBytes:             stats.OriginalBytes,
+                OutputBytes:               stats.OutputBytes,
+                RemovedBytes:              stats.removedBytes(),
+                SizeDelta:                 stats.sizeDelta(),
+                ZeroWidthRemoved:          stats.ZeroWidthRemoved,
+                RestrictedRemoved:         stats.RestrictedRemoved,
+                MixedScriptWords:          stats.MixedScriptWords,
+                ConfusableWords:           stats.ConfusableWords,
+                ControlCharsRemoved:       stats.ControlCharsRemoved,
+                NonASCIIRemoved:           stats.NonASCIIRemoved,
+                EmojiRemoved:              stats.EmojiRemoved,
+                EmojiCharsRemoved:         stats.EmojiCharsRemoved,
+                EmojiConverted:            stats.EmojiConverted,
+                ModifiersRemoved:          stats.ModifiersRemoved,
+                SoftHyphensRemoved:        stats.SoftHyphensRemoved,
+                SoftHyphensConverted:      stats.SoftHyphensConverted,
+                SpacesConverted:           stats.SpacesConverted,
+                DashesConverted:           stats.DashesConverted,
+                ReplyMarkersNormalized:    stats.ReplyMarkersNormalized,
+                ReactionLinesDropped:      stats.ReactionLinesDropped,
+                LineEndingsConverted:      stats.LineEndingsConverted,
+                LineEndingConversions:     stats.LineEndingConversions,
+                OriginalLineEndings:       stats.OriginalLineEndings,
+                FormatDetected:            stats.FormatDetected,
+                MarkdownStripped:          stats.MarkdownStripped,
+                HTMLStripped:              stats.HTMLStripped,
+                SubtitlesStripped:         stats.SubtitlesStripped,
+                HTMLEntitiesDecoded:       stats.HTMLEntitiesDecoded,
+                StrippedConstructs:        stats.StrippedConstructs,
+                UnicodeNormalized:         stats.UnicodeNormalized,
+                MojibakeRepaired:          stats.MojibakeRepaired,
+                Transliterated:            stats.Transliterated,
+                InvalidUTF8Sequences:      stats.InvalidUTF8Sequences,
+                InvalidUTF8Action:         stats.InvalidUTF8Action,
+                DecodedFrom:               stats.DecodedFrom,
+                InputEncoding:             stats.InputEncoding,
+                BOMPresent:                stats.BOMPresent,
+                ValidUTF8:                 validUTF8,
+                ReplacementCharsAdded:     stats.ReplacementCharsAdded,
+                Replacement:               stats.Replacement,
+                AllowedKept:               stats.AllowedKept,
+                TrailingWhitespaceTrimmed: stats.TrailingWhitespaceTrimmed,
+                FinalNewlineAdded:         stats.FinalNewlineAdded,
+                BOMAdded:                  stats.BOMAdded,
+                ProvenanceAdded:           stats.ProvenanceAdded,
+                QuotesNormalized:          stats.QuotesNormalized,
+                PunctuationConverted:      stats.PunctuationConverted,
+                StrayQuotesRemoved:        stats.StrayQuotesRemoved,
+                FormulasEscaped:           stats.FormulasEscaped,
+                NewlinesEscaped:           stats.NewlinesEscaped,
+                EscapesDecoded:            stats.EscapesDecoded,
+                InputHash:                 stats.InputHash,
+                OutputHash:                stats.OutputHash,
+        }
+}
+
+// newFindings lists the removed characters of stats ordered by codepoint
+func newFindings(stats *CleaningStats) []Finding {
+        chars := make([]rune, 0, len(stats.RemovedCharDetails))
+        for char := range stats.RemovedCharDetails {
+                chars = append(chars, char)
+        }
+        sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+
+        findings := make([]Finding, 0, len(chars))
+        for _, char := range chars {
+                findings = append(findings, Finding{
+                        Codepoint: fmt.Sprintf("U+%04X", char),
+                        Name:      describeChar(char),
+                        Category:  charCategory(char),
+                        Count:     stats.RemovedCharDetails[char],
+                })
+        }
+        return findings
+}
+
+func writeJSONReport(w io.Writer, results []FileResult, targetOS string, checkOnly bool, duplicates [][]string) error {
+        report := newJSONReport(results, targetOS, checkOnly, duplicates)
+        encoder := json.NewEncoder(w)
+        encoder.SetIndent("", "  ")
+        encoder.SetEscapeHTML(false)
+        if err := encoder.Encode(report); err != nil {
+                return fmt.Errorf("could not write JSON report: %w", err)
+        }
+        return nil
+}
+
+// newJSONReport collects the results of a run, and their totals, for the
+// JSON report and report templates
+func newJSONReport(results []FileResult, targetOS string, checkOnly bool, duplicates [][]string) JSONReport {
+        total := &CleaningStats{
+                RemovedCharDetails: make(map[rune]int),
+        }
+        report := JSONReport{
+                Generated:  time.Now(),
+                TargetOS:   targetOS,
+                CheckOnly:  checkOnly,
+                Files:      make([]FileReport, 0, len(results)),
+                Duplicates: duplicates,
+        }
+
+        for _, r := range results {
+                if r.Err != nil {
+                        report.Files = append(report.Files, FileReport{
+                                Input:    r.InputPath,
+                                Error:    r.Err.Error(),
+                                Findings: []Finding{},
+                                Warnings: []Warning{},
+                        })
+                        continue
+                }
+                report.Files = append(report.Files, FileReport{
+                        Input:     r.InputPath,
+                        Output:    r.OutputPath,
+                        Changed:   r.Stats.changed(),
+                        Stats:     newStatsReport(r.Stats),
+                        Findings:  newFindings(r.Stats),
+                        Warnings:  nonNilWarnings(r.Stats.Warnings),
+                        Cells:     r.Stats.EscapedCells,
+                        Rows:      r.Stats.MalformedRows,
+                        Cached:    r.Cached,
+                        Unwritten: r.Stats.OutputSkipped,
+                })
+                mergeStats(total, r.Stats)
+        }
+        report.Totals = newStatsReport(total)
+        return report
+}
+
+func nonNilWarnings(warnings []Warning) []Warning {
+        if warnings == nil {
+                return []Warning{}
+        }
+        return warnings
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+        "encoding/json"
+        "flag"
+        "fmt"
+        "io"
+        "os"
+        "sort"
+        "strings"
+)
+
+func readJSONReport(path string) (*JSONReport, error) {
+        data, err := os.ReadFile(path)
+        if err != nil {
+                return nil, fmt.Errorf("could not read report: %w", err)
+        }
+        var report JSONReport
+        if err := json.Unmarshal(data, &report); err != nil {
+                return nil, fmt.Errorf("could not parse report %s: %w", path, err)
+        }
+        return &report, nil
+}
+
+// findingChange compares the count of one (file, codepoint) finding between
+// two reports
+type findingChange struct {
+        File     string
+        Finding  Finding
+        OldCount int
+        NewCount int
+}
+
+// reportDiff classifies findings as new (absent or less frequent in the old
+// report), fixed (absent or less frequent in the new report) or persisting
+type reportDiff struct {
+        New        []findingChange
+        Fixed      []findingChange
+        Persisting []findingChange
+}
+
+func diffReports(oldReport, newReport *JSONReport) reportDiff {
+        type key struct{ file, codepoint string }
+        oldFindings := make(map[key]Finding)
+        newFindings := make(map[key]Finding)
+        var keys []key
+
+        // A file that could not be scanned this time has not been fixed
+        failed := make(map[string]bool)
+        for _, f := range newReport.Files {
+                if f.Error != "" {
+                        failed[f.Input] = true
+                }
+        }
+
+        for _, f := range oldReport.Files {
+                if failed[f.Input] {
+                        continue
+                }
+                for _, finding := range f.Findings {
+                        k := key{f.Input, finding.Codepoint}
+                        oldFindings[k] = finding
+                        keys = append(keys, k)
+                }
+        }
+        for _, f := range newReport.Files {
+                for _, finding := range f.Findings {
+                        k := key{f.Input, finding.Codepoint}
+                        newFindings[k] = finding
+                        if _, ok := oldFindings[k]; !ok {
+                                keys = append(keys, k)
+                        }
+                }
+        }
+        sort.Slice(keys, func(i, j int) bool {
+                if keys[i].file != keys[j].file {
+                        return keys[i].file < keys[j].file
+                }
+                return keys[i].codepoint < keys[j].codepoint
+        })
+
+        var diff reportDiff
+        for _, k := range keys {
+                oldFinding := oldFindings[k]
+                newFinding, inNew := newFindings[k]
+                change := findingChange{File: k.file, OldCount: oldFinding.Count, NewCount: newFinding.Count}
+                if inNew {
+                        change.Finding = newFinding
+                } else {
+                        change.Finding = oldFinding
+                }
+
+                switch {
+                case change.NewCount > change.OldCount:
+                        diff.New = append(diff.New, change)
+                case change.NewCount < change.OldCount:
+                        diff.Fixed = append(diff.Fixed, change)
+                default:
+                        diff.Persisting = append(diff.Persisting, change)
+                }
+        }
+        return diff
+}
+
+// runReport implements the "report" command and returns the exit code
+func runReport(args []string) int {
+        if len(args) == 0 || args[0] != "diff" {
+                fmt.Println("Usage: cleanfile report diff [-color mode] old.json new.json")
+                return 1
+        }
+
+        fs := flag.NewFlagSet("report diff", flag.ExitOnError)
+        colorMode := fs.String("color", "auto", "Colorize the report: auto, always, never (NO_COLOR disables auto)")
+        fs.Parse(args[1:])
+
+        if err := setupColor(*colorMode); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+        if fs.NArg() != 2 {
+                fmt.Println("Usage: cleanfile report diff [-color mode] old.json new.json")
+                return 1
+        }
+
+        oldReport, err := readJSONReport(fs.Arg(0))
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+        newReport, err := readJSONReport(fs.Arg(1))
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        diff := diffReports(oldReport, newReport)
+        printReportDiff(os.Stdout, fs.Arg(0), fs.Arg(1), diff)
+
+        if len(diff.New) > 0 {
+                return 1
+        }
+        return 0
+}
+
+func printReportDiff(w io.Writer, oldPath, newPath string, diff reportDiff) {
+        fmt.Fprintln(w, "\n"+strings.Repeat("=", 70))
+        fmt.Fprintln(w, colorize("REPORT COMPARISON", ansiBold))
+        fmt.Fprintln(w, strings.Repeat("=", 70))
+
+        fmt.Fprintf(w, "\n%s\n", colorize("Reports:", ansiBold, ansiCyan))
+        fmt.Fprintf(w, "   Old: %s\n", oldPath)
+        fmt.Fprintf(w, "   New: %s\n", newPath)
+
+        fmt.Fprintf(w, "\n%s\n", colorize("Summary:", ansiBold, ansiCyan))
+        fmt.Fprintf(w, "   New findings:        %d\n", len(diff.New))
+        fmt.Fprintf(w, "   Fixed findings:      %d\n", len(diff.Fixed))
+        fmt.Fprintf(w, "   Persisting findings: %d\n", len(diff.Persisting))
+
+        sections := []struct {
+                title   string
+                changes []findingChange
+                color   string
+        }{
+                {"New Findings:", diff.New, ansiRed},
+                {"Fixed Findings:", diff.Fixed, ansiGreen},
+                {"Persisting Findings:", diff.Persisting, ansiYellow},
+        }
+        for _, section := range sections {
+                if len(section.changes) == 0 {
+                        continue
+                }
+                fmt.Fprintf(w, "\n%s\n", colorize(section.title, ansiBold, section.color))
+                fmt.Fprintln(w, strings.Repeat("-", 70))
+                for _, c := range section.changes {
+                        fmt.Fprintf(w, "   %s  %s  %-30s  %d -> %d\n",
+                                c.File, c.Finding.Codepoint, c.Finding.Name, c.OldCount, c.NewCount)
+                }
+                fmt.Fprintln(w, strings.Repeat("-", 70))
+        }
+
+        fmt.Fprintln(w, "\n"+strings.Repeat("=", 70))
+        if len(diff.New) > 0 {
+                fmt.Fprintln(w, colorize("New issues were introduced!", ansiBold, ansiRed))
+        } else {
+                fmt.Fprintln(w, colorize("No new issues introduced!", ansiBold, ansiGreen))
+        }
+        fmt.Fprintln(w, strings.Repeat("=", 70))
+}
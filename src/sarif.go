@@ -0,0 +1,144 @@
+package main
+
+import (
+        "encoding/json"
+        "fmt"
+        "io"
+        "path/filepath"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// sarifRule describes one kind of finding
+type sarifRule struct {
+        ID               string       `json:"id"`
+        Name             string       `json:"name"`
+        ShortDescription sarifMessage `json:"shortDescription"`
+        DefaultConfig    struct {
+                Level string `json:"level"`
+        } `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+        Text string `json:"text"`
+}
+
+type sarifResult struct {
+        RuleID    string          `json:"ruleId"`
+        Level     string          `json:"level"`
+        Message   sarifMessage    `json:"message"`
+        Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+        PhysicalLocation struct {
+                ArtifactLocation struct {
+                        URI string `json:"uri"`
+                } `json:"artifactLocation"`
+                Region struct {
+                        StartLine   int `json:"startLine"`
+                        StartColumn int `json:"startColumn"`
+                        EndColumn   int `json:"endColumn"`
+                } `json:"region"`
+        } `json:"physicalLocation"`
+}
+
+type sarifLog struct {
+        Schema  string     `json:"$schema"`
+        Version string     `json:"version"`
+        Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+        Tool struct {
+                Driver struct {
+                        Name  string      `json:"name"`
+                        Rules []sarifRule `json:"rules"`
+                } `json:"driver"`
+        } `json:"tool"`
+        ColumnKind string        `json:"columnKind"`
+        Results    []sarifResult `json:"results"`
+}
+
+// sarifRules are the rules findings are reported under, one per category
+// of charCategory. Bidirectional controls get their own rule and the
+// highest level, since they can make source code read differently from
+// how it is compiled ("Trojan Source").
+var sarifRules = []struct {
+        ID, Name, Description, Level string
+}{
+        {"bidi-control", "BidiControlCharacter", "Bidirectional control character that can reorder displayed text", "error"},
+        {"zero-width", "ZeroWidthCharacter", "Invisible zero-width character", "warning"},
+        {"soft-hyphen", "SoftHyphen", "Soft hyphen, only displayed where a word is broken", "warning"},
+        {"control", "ControlCharacter", "Control character", "warning"},
+        {"non-ascii", "NonASCIICharacter", "Non-ASCII character", "note"},
+}
+
+// defaultRuleLevel is the level of findings of a category that sarifRules
+// has no rule for
+const defaultRuleLevel = "warning"
+
+// ruleLevel returns the level findings of a rule are reported at
+func ruleLevel(ruleID string) string {
+        for _, rule := range sarifRules {
+                if rule.ID == ruleID {
+                        return rule.Level
+                }
+        }
+        return defaultRuleLevel
+}
+
+// isBidiControl reports whether r is an embedding, override or isolate
+// control of the Unicode bidirectional algorithm
+func isBidiControl(r rune) bool {
+        return (r >= '\u202A' && r <= '\u202E') || (r >= '\u2066' && r <= '\u2069')
+}
+
+func sarifRuleID(r rune) string {
+        if isBidiControl(r) {
+                return "bidi-control"
+        }
+        return charCategory(r)
+}
+
+// writeSARIFReport writes one SARIF result per removed character, using the
+// locations recorded with CleaningOptions.RecordLocations
+func writeSARIFReport(w io.Writer, results []FileResult) error {
+        run := sarifRun{ColumnKind: "unicodeCodePoints", Results: []sarifResult{}}
+        run.Tool.Driver.Name = "cleanfile"
+
+        for _, rule := range sarifRules {
+                r := sarifRule{ID: rule.ID, Name: rule.Name, ShortDescription: sarifMessage{rule.Description}}
+                r.DefaultConfig.Level = rule.Level
+                run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, r)
+        }
+
+        for _, r := range results {
+                if r.Err != nil {
+                        continue
+                }
+                for _, loc := range r.Stats.Locations {
+                        ruleID := sarifRuleID(loc.Char)
+                        result := sarifResult{
+                                RuleID:  ruleID,
+                                Level:   ruleLevel(ruleID),
+                                Message: sarifMessage{fmt.Sprintf("U+%04X (%s)", loc.Char, describeChar(loc.Char))},
+                        }
+                        var location sarifLocation
+                        location.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(r.InputPath)
+                        location.PhysicalLocation.Region.StartLine = loc.Line
+                        location.PhysicalLocation.Region.StartColumn = loc.Column
+                        location.PhysicalLocation.Region.EndColumn = loc.Column + 1
+                        result.Locations = []sarifLocation{location}
+                        run.Results = append(run.Results, result)
+                }
+        }
+
+        encoder := json.NewEncoder(w)
+        encoder.SetIndent("", "  ")
+        encoder.SetEscapeHTML(false)
+        if err := encoder.Encode(sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}); err != nil {
+                return fmt.Errorf("could not write SARIF report: %w", err)
+        }
+        return nil
+}
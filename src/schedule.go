@@ -0,0 +1,111 @@
+package main
+
+import (
+        "encoding/json"
+        "fmt"
+        "os"
+        "path/filepath"
+        "sort"
+        "time"
+)
+
+// scanState records when each file of a tree was last scanned, in the JSON
+// file named with -scan-state, so that runs limited by -time-budget can
+// take up the files an earlier run did not get to. Keys are absolute paths.
+type scanState map[string]scanEntry
+
+type scanEntry struct {
+        Scanned time.Time `json:"scanned"`
+        ModTime time.Time `json:"mod_time"` // of the file when it was scanned
+        Size    int64     `json:"size"`
+}
+
+// loadScanState reads a scan state; a missing file is an empty state
+func loadScanState(path string) (scanState, error) {
+        state := make(scanState)
+        data, err := os.ReadFile(path)
+        if os.IsNotExist(err) {
+                return state, nil
+        }
+        if err != nil {
+                return nil, fmt.Errorf("could not read scan state: %w", err)
+        }
+        if err := json.Unmarshal(data, &state); err != nil {
+                return nil, fmt.Errorf("could not read scan state %s: %w", path, err)
+        }
+        return state, nil
+}
+
+// save writes the scan state through a temporary file, so that an
+// interrupted run leaves the previous state intact
+func (s scanState) save(path string) error {
+        data, err := json.MarshalIndent(s, "", "  ")
+        if err != nil {
+                return fmt.Errorf("could not write scan state: %w", err)
+        }
+        if err := replaceFile(path, append(data, '\n')); err != nil {
+                return fmt.Errorf("could not write scan state: %w", err)
+        }
+        return nil
+}
+
+// record notes the files of a run as scanned at now, with their size and
+// modification time after the run
+func (s scanState) record(results []FileResult, now time.Time) {
+        for _, r := range results {
+                abs, err := filepath.Abs(r.InputPath)
+                if err != nil {
+                        continue
+                }
+                info, err := os.Stat(r.InputPath)
+                if err != nil {
+                        delete(s, abs)
+                        continue
+                }
+                s[abs] = scanEntry{Scanned: now, ModTime: info.ModTime(), Size: info.Size()}
+        }
+}
+
+// prioritize orders files for a run that may not get through all of them:
+// first the files never scanned or changed since they were, most recently
+// modified first, then the others, those scanned longest ago first
+func prioritize(files []string, state scanState) []string {
+        type candidate struct {
+                path    string
+                pending bool
+                modTime time.Time
+                scanned time.Time
+        }
+        candidates := make([]candidate, len(files))
+        for i, path := range files {
+                c := candidate{path: path, pending: true}
+                info, err := os.Stat(path)
+                if err == nil {
+                        c.modTime = info.ModTime()
+                }
+                if abs, err := filepath.Abs(path); err == nil && info != nil {
+                        if entry, ok := state[abs]; ok && entry.ModTime.Equal(info.ModTime()) && entry.Size == info.Size() {
+                                c.pending = false
+                                c.scanned = entry.Scanned
+                        }
+                }
+                candidates[i] = c
+        }
+        sort.SliceStable(candidates, func(i, j int) bool {
+                a, b := candidates[i], candidates[j]
+                switch {
+                case a.pending != b.pending:
+                        return a.pending
+                case a.pending:
+                        return a.modTime.After(b.modTime)
+                default:
+                        return a.scanned.Before(b.scanned)
+                }
+        })
+
+        ordered := make([]string, len(files))
+        for i, c := range candidates {
+                ordered[i] = c.path
+        }
+        return ordered
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+        "sort"
+        "strings"
+        "unicode"
+)
+
+// securityProfiles are the values of -preset
+var securityProfiles = []string{"utr39"}
+
+// recommendedScripts are the scripts in common modern use (UAX #31, Table
+// 7). The General Security Profile of UTR #39 restricts every other script,
+// historic ones such as Gothic or Runic and limited-use ones such as
+// Cherokee or Tifinagh, since a reader rarely knows what their letters
+// look like.
+var recommendedScripts = map[string]bool{
+        "Common": true, "Inherited": true, "Arabic": true, "Armenian": true, "Bengali": true,
+        "Bopomofo": true, "Cyrillic": true, "Devanagari": true, "Ethiopic": true, "Georgian": true,
+        "Greek": true, "Gujarati": true, "Gurmukhi": true, "Han": true, "Hangul": true,
+        "Hebrew": true, "Hiragana": true, "Kannada": true, "Katakana": true, "Khmer": true,
+        "Lao": true, "Latin": true, "Malayalam": true, "Myanmar": true, "Oriya": true,
+        "Sinhala": true, "Tamil": true, "Telugu": true, "Thaana": true, "Thai": true,
+        "Tibetan": true,
+}
+
+// frequentScripts are looked up first by scriptOf
+var frequentScripts = []string{"Latin", "Common", "Inherited", "Cyrillic", "Greek", "Han", "Hiragana", "Katakana", "Hangul", "Arabic", "Hebrew"}
+
+// scriptOf returns the script of a character by its name in
+// unicode.Scripts, "Common" and "Inherited" for characters shared by
+// scripts and "Unknown" for unassigned code points
+func scriptOf(r rune) string {
+        if r < 0x80 {
+                if unicode.IsLetter(r) {
+                        return "Latin"
+                }
+                return "Common"
+        }
+        for _, name := range frequentScripts {
+                if unicode.Is(unicode.Scripts[name], r) {
+                        return name
+                }
+        }
+        for name, table := range unicode.Scripts {
+                if unicode.Is(table, r) {
+                        return name
+                }
+        }
+        return "Unknown"
+}
+
+// isRestricted reports whether the General Security Profile of UTR #39
+// restricts a letter, mark or digit: one of a script outside
+// recommendedScripts, a deprecated character, or one that is not stable
+// under NFKC, such as the ligature ﬁ or a mathematical bold letter, which
+// -normalize-unicode nfkc replaces first. Symbols, punctuation and spaces
+// are not restricted in running text.
+func isRestricted(r rune) bool {
+        if r < 0x80 || !unicode.In(r, unicode.L, unicode.M, unicode.N) {
+                return false
+        }
+        if unicode.Is(unicode.Deprecated, r) {
+                return true
+        }
+        if _, ok := compatDecompositions[r]; ok {
+                return true
+        }
+        return !recommendedScripts[scriptOf(r)]
+}
+
+// scriptGroups are the combinations of scripts that UTR #39 accepts in a
+// single word at the Highly Restrictive level: Japanese, Chinese with
+// Bopomofo and Korean, each also with Latin
+var scriptGroups = [][]string{
+        {"Latin", "Han", "Hiragana", "Katakana"},
+        {"Latin", "Han", "Bopomofo"},
+        {"Latin", "Han", "Hangul"},
+}
+
+// wordScripts returns the scripts of a word other than Common and
+// Inherited, in the order they first occur
+func wordScripts(word []rune) []string {
+        var scripts []string
+        for _, r := range word {
+                script := scriptOf(r)
+                if script == "Common" || script == "Inherited" || containsString(scripts, script) {
+                        continue
+                }
+                scripts = append(scripts, script)
+        }
+        return scripts
+}
+
+// isMixedScript reports whether a word has letters of more than one script
+// that no scriptGroups entry covers
+func isMixedScript(scripts []string) bool {
+        if len(scripts) < 2 {
+                return false
+        }
+        for _, group := range scriptGroups {
+                covered := 0
+                for _, script := range scripts {
+                        if containsString(group, script) {
+                                covered++
+                        }
+                }
+                if covered == len(scripts) {
+                        return false
+                }
+        }
+        return true
+}
+
+func containsString(list []string, s string) bool {
+        for _, item := range list {
+                if item == s {
+                        return true
+                }
+        }
+        return false
+}
+
+// skeleton returns the UTR #39 skeleton of a word: decomposed (NFD), with
+// every character replaced by its prototype in confusablePrototypes, and
+// decomposed again. Words with the same skeleton look the same. With
+// asciiToo false, only characters outside ASCII are replaced, which yields
+// the Latin text a word imitates.
+func skeleton(word string, asciiToo bool) string {
+        var b strings.Builder
+        for _, r := range word {
+                decomposed, ok := canonicalDecompositions[r]
+                if !ok {
+                        decomposed = string(r)
+                }
+                for _, c := range decomposed {
+                        if prototype, ok := confusablePrototypes[c]; ok && (asciiToo || c >= 0x80) {
+                                b.WriteString(prototype)
+                                continue
+                        }
+                        b.WriteRune(c)
+                }
+        }
+        decomposed, _ := normalizeUnicode(b.String(), "nfd")
+        return decomposed
+}
+
+// maxSkeletons limits the words a file remembers to find confusable pairs
+const maxSkeletons = 100000
+
+// securityScanner checks the words of a file against the General Security
+// Profile of UTR #39: words that mix scripts, and different words that
+// look the same, such as "paypal" and "pаypal" with a Cyrillic а.
+type securityScanner struct {
+        skeletons map[string]seenWord
+}
+
+type seenWord struct {
+        Word string
+        Line int
+}
+
+func newSecurityScanner() *securityScanner {
+        return &securityScanner{skeletons: make(map[string]seenWord)}
+}
+
+// scanLine checks the words of a cleaned line
+func (s *securityScanner) scanLine(stats *CleaningStats, lineNum int, line string) {
+        if s == nil {
+                return
+        }
+        type lineWord struct {
+                runes   []rune
+                scripts []string
+        }
+        var words []lineWord
+        perScript := make(map[string]int)
+        runes := []rune(line)
+        for start := 0; start < len(runes); {
+                if !isWordChar(runes[start]) {
+                        start++
+                        continue
+                }
+                end := start + 1
+                for end < len(runes) && isWordChar(runes[end]) {
+                        end++
+                }
+                word := lineWord{runes: runes[start:end], scripts: wordScripts(runes[start:end])}
+                if len(word.scripts) == 1 {
+                        perScript[word.scripts[0]]++
+                }
+                words = append(words, word)
+                start = end
+        }
+
+        for _, w := range words {
+                if len(w.runes) < 2 {
+                        continue
+                }
+                word := string(w.runes)
+                switch {
+                case isMixedScript(w.scripts):
+                        scripts := append([]string(nil), w.scripts...)
+                        sort.Strings(scripts)
+                        stats.MixedScriptWords++
+                        stats.warn(warnMixedScript, lineNum, "'%s' mixes %s (looks like '%s')",
+                                word, strings.Join(scripts, " and "), skeleton(word, false))
+                        continue
+                case len(w.scripts) == 1 && w.scripts[0] != "Latin" && perScript["Latin"] > perScript[w.scripts[0]]:
+                        // A whole-script confusable: a word among Latin text
+                        // that only consists of lookalikes of Latin letters
+                        if latin := skeleton(word, false); isASCII(latin) {
+                                stats.ConfusableWords++
+                                stats.warn(warnConfusable, lineNum, "'%s' is %s but looks like the Latin '%s'", word, w.scripts[0], latin)
+                                continue
+                        }
+                }
+                s.checkSkeleton(stats, lineNum, word)
+        }
+}
+
+// checkSkeleton reports a word that looks like a different word seen
+// earlier in the file
+func (s *securityScanner) checkSkeleton(stats *CleaningStats, lineNum int, word string) {
+        key := skeleton(word, true)
+        first, ok := s.skeletons[key]
+        switch {
+        case !ok:
+                if len(s.skeletons) < maxSkeletons {
+                        s.skeletons[key] = seenWord{Word: word, Line: lineNum}
+                }
+        case first.Word != word && (!isASCII(word) || !isASCII(first.Word)):
+                stats.ConfusableWords++
+                stats.warn(warnConfusable, lineNum, "'%s' looks the same as '%s' on line %d", word, first.Word, first.Line)
+        }
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "mime"
+        "net"
+        "net/http"
+        "net/url"
+        "os"
+        "os/signal"
+        "strings"
+        "syscall"
+        "time"
+)
+
+// serveDeniedOptions are the cleaning options a request cannot set: they
+// name files on the server or only affect its console
+var serveDeniedOptions = map[string]bool{
+        "config": true, "editorconfig": true, "color": true, "verbose": true, "details": true,
+}
+
+// cleanServer answers cleaning requests over HTTP, with the options of
+// each request on top of those of the server's config file
+type cleanServer struct {
+        Config    *Config
+        Profile   string
+        MaxUpload int64
+        Verbose   bool
+        Metrics   *serviceMetrics
+}
+
+// ServeResponse is the body of a successful /clean request: the cleaned
+// text and the report of the file, as in -report json
+type ServeResponse struct {
+        Content string     `json:"content"`
+        Report  FileReport `json:"report"`
+}
+
+// runServe implements the serve subcommand
+func runServe(args []string) int {
+        fs := newSubcommandFlags("serve")
+        addr := fs.String("addr", ":8080", "Address to listen on")
+        configFile := fs.String("config", "", "Config file to use instead of the nearest "+projectConfigName)
+        profile := fs.String("profile", "", "Profile applied to requests that name none")
+        maxUpload := fs.String("max-upload", "32M", "Largest request body accepted, e.g. 32M or 1G")
+        metrics := fs.Bool("metrics", true, "Serve Prometheus metrics at /metrics")
+        verbose := fs.Bool("verbose", false, "Log every request")
+        if extra := parseInterspersed(fs, args); len(extra) > 0 {
+                exitWithUsage(fs, "unexpected argument '%s'", extra[0])
+        }
+
+        limit, err := parseSize(*maxUpload)
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+        cfg, err := loadConfig(configPaths(*configFile))
+        if err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+        s := &cleanServer{Config: cfg, Profile: *profile, MaxUpload: limit, Verbose: *verbose}
+        // A mistake in the config or the default profile shows now rather
+        // than in the answer to every request
+        if _, err := s.options(url.Values{}); err != nil {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+
+        mux := http.NewServeMux()
+        mux.HandleFunc("/clean", s.handleClean)
+        mux.HandleFunc(grpcServicePath, s.handleGRPC)
+        mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+                fmt.Fprintln(w, "ok")
+        })
+        if *metrics {
+                s.Metrics = newServiceMetrics()
+                mux.Handle("/metrics", s.Metrics)
+        }
+
+        listener, err := net.Listen("tcp", *addr)
+        if err != nil {
+                fmt.Printf("Error: could not listen on %s: %v\n", *addr, err)
+                return 1
+        }
+        // gRPC clients speak HTTP/2 without TLS on the same address
+        protocols := new(http.Protocols)
+        protocols.SetHTTP1(true)
+        protocols.SetUnencryptedHTTP2(true)
+        server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second, Protocols: protocols}
+        stopped := make(chan struct{})
+        go func() {
+                stop := make(chan os.Signal, 1)
+                signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+                <-stop
+                // Requests being answered are finished
+                ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+                defer cancel()
+                server.Shutdown(ctx)
+                close(stopped)
+        }()
+        fmt.Printf("Listening on %s\n", listener.Addr())
+        if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+                fmt.Printf("Error: %v\n", err)
+                return 1
+        }
+        <-stopped
+        fmt.Println("Stopped")
+        return 0
+}
+
+// options builds the cleaning options of a request as "cleanfile equal"
+// would from the flags named by values, the config file and the profile
+// the request names, or the server's
+func (s *cleanServer) options(values url.Values) (CleaningOptions, error) {
+        f := newCleanFlags("serve")
+        last := make(map[string]string)
+        for key, list := range values {
+                last[key] = list[len(list)-1]
+        }
+        for _, key := range sortedKeys(last) {
+                value := last[key]
+                if serveDeniedOptions[key] || f.fs.Lookup(key) == nil {
+                        return CleaningOptions{}, fmt.Errorf("unknown option '%s'", key)
+                }
+                if key == "allow-ranges" && strings.HasPrefix(value, "@") {
+                        return CleaningOptions{}, errors.New("allow-ranges cannot name a file on the server")
+                }
+                if err := f.fs.Set(key, value); err != nil {
+                        return CleaningOptions{}, fmt.Errorf("invalid value '%s' for option '%s': %v", value, key, err)
+                }
+        }
+        if *f.profile == "" {
+                *f.profile = s.Profile
+        }
+        if err := applyConfig(f.fs, f.unused, s.Config, *f.profile); err != nil {
+                return CleaningOptions{}, err
+        }
+        if err := applyPreset(f.fs, *f.preset); err != nil {
+                return CleaningOptions{}, err
+        }
+        if err := applyCleaningMode(f.fs, *f.cleaningMode); err != nil {
+                return CleaningOptions{}, err
+        }
+        return f.options()
+}
+
+// handleClean cleans the text of a POST request. The text is the request
+// body, or the file of a multipart/form-data upload, and the options are
+// given as query parameters or, in an upload, as form fields, named as the
+// flags of clean (ascii=false, strip=html, profile=chat).
+func (s *cleanServer) handleClean(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        if r.Method != http.MethodPost {
+                w.Header().Set("Allow", http.MethodPost)
+                writeServeError(w, http.StatusMethodNotAllowed, errors.New("use POST"))
+                return
+        }
+        r.Body = http.MaxBytesReader(w, r.Body, s.MaxUpload)
+
+        // The text goes to a temporary file, so that it is streamed through
+        // the cleaner like any other file rather than held in memory
+        tmp, err := os.CreateTemp("", "cleanfile-serve-*")
+        if err != nil {
+                writeServeError(w, http.StatusInternalServerError, err)
+                return
+        }
+        defer os.Remove(tmp.Name())
+        defer tmp.Close()
+
+        values := r.URL.Query()
+        name := "(request)"
+        if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+                name, err = readUpload(r, tmp, values)
+        } else {
+                _, err = io.Copy(tmp, r.Body)
+        }
+        if err != nil {
+                status := http.StatusBadRequest
+                var tooLarge *http.MaxBytesError
+                if errors.As(err, &tooLarge) {
+                        status = http.StatusRequestEntityTooLarge
+                        err = fmt.Errorf("request body is larger than %s", formatSize(s.MaxUpload))
+                }
+                writeServeError(w, status, err)
+                return
+        }
+
+        options, err := s.options(values)
+        if err != nil {
+                writeServeError(w, http.StatusBadRequest, err)
+                return
+        }
+        sink := &BufferSink{}
+        stats, err := cleanFile(tmp.Name(), sink, options, false)
+        result := FileResult{InputPath: name, OutputPath: "(response)", Stats: stats, Err: err}
+        s.Metrics.observe(result, time.Since(start))
+        if s.Verbose {
+                fmt.Printf("%s %s %s: %v\n", r.RemoteAddr, r.Method, name, cleanOutcome(result))
+        }
+        if err != nil {
+                writeServeError(w, http.StatusUnprocessableEntity, err)
+                return
+        }
+
+        report := newJSONReport([]FileResult{result}, options.TargetOS, false, nil)
+        w.Header().Set("Content-Type", "application/json")
+        encoder := json.NewEncoder(w)
+        encoder.SetEscapeHTML(false)
+        encoder.Encode(ServeResponse{Content: sink.Buffer.String(), Report: report.Files[0]})
+}
+
+// readUpload copies the first file of a multipart/form-data request to
+// dst, adds the other form fields to values and returns the file name
+func readUpload(r *http.Request, dst io.Writer, values url.Values) (string, error) {
+        reader, err := r.MultipartReader()
+        if err != nil {
+                return "", err
+        }
+        name := ""
+        for {
+                part, err := reader.NextPart()
+                if err == io.EOF {
+                        break
+                }
+                if err != nil {
+                        return "", err
+                }
+                if part.FileName() == "" {
+                        value, err := io.ReadAll(io.LimitReader(part, 4096))
+                        if err != nil {
+                                return "", err
+                        }
+                        values.Set(part.FormName(), string(value))
+                        continue
+                }
+                if name != "" {
+                        return "", errors.New("only one file can be cleaned per request")
+                }
+                name = part.FileName()
+                if _, err := io.Copy(dst, part); err != nil {
+                        return "", err
+                }
+        }
+        if name == "" {
+                return "", errors.New("the upload has no file")
+        }
+        return name, nil
+}
+
+// cleanOutcome describes the result of a request for the log
+func cleanOutcome(r FileResult) string {
+        if r.Err != nil {
+                return "failed: " + r.Err.Error()
+        }
+        return fmt.Sprintf("%d character(s) removed", r.Stats.RemovedChars)
+}
+
+// writeServeError answers a request with an error as {"error": "..."}
+func writeServeError(w http.ResponseWriter, status int, err error) {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(status)
+        json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+        "bytes"
+        "crypto/hmac"
+        "crypto/sha256"
+        "encoding/hex"
+        "errors"
+        "fmt"
+        "io"
+        "net/http"
+        "net/url"
+        "os"
+        "strings"
+        "time"
+)
+
+// newOutputSink selects a sink by URI scheme: "-" or "stdout:" for standard
+// output, http(s):// for an HTTP PUT, s3://bucket/key for Amazon S3 (or an
+// S3-compatible store) and file:// or a plain path for a local file
+func newOutputSink(spec string) (OutputSink, error) {
+        if spec == "-" || spec == "stdout:" {
+                return &stdoutSink{}, nil
+        }
+
+        u, err := url.Parse(spec)
+        if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+                // Plain paths, including Windows drive letters such as C:\out.txt
+                return &fileSink{Path: spec}, nil
+        }
+
+        switch strings.ToLower(u.Scheme) {
+        case "file":
+                return &fileSink{Path: u.Path}, nil
+        case "http", "https":
+                return &httpSink{URL: spec}, nil
+        case "s3":
+                if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+                        return nil, fmt.Errorf("invalid S3 destination '%s' (expected s3://bucket/key)", spec)
+                }
+                return &s3Sink{Bucket: u.Host, Key: strings.TrimPrefix(u.Path, "/")}, nil
+        default:
+                return nil, fmt.Errorf("unsupported output scheme '%s'", u.Scheme)
+        }
+}
+
+// fileSink writes to a local file. With Sparse, long runs of zero bytes
+// are left as holes, as they were in a sparse input. The file gets Mode if
+// set, and otherwise SourcePerm, the permissions of the input (see
+// createFile); a nil SourcePerm copies none.
+type fileSink struct {
+        Path       string
+        Sparse     bool
+        Mode       os.FileMode
+        SourcePerm *os.FileMode
+        file       *os.File
+        sparse     *sparseWriter
+}
+
+func (s *fileSink) Open() (io.Writer, error) {
+        f, err := createFile(s.Path, s.Mode, s.SourcePerm)
+        if err != nil {
+                return nil, fmt.Errorf("could not create output file: %w", err)
+        }
+        s.file = f
+        if s.Sparse {
+                s.sparse = &sparseWriter{file: f}
+                return s.sparse, nil
+        }
+        return f, nil
+}
+
+func (s *fileSink) Close() error {
+        if s.sparse != nil {
+                if err := s.sparse.finish(); err != nil {
+                        s.Abort()
+                        return fmt.Errorf("could not write output file: %w", err)
+                }
+        }
+        if err := s.file.Close(); err != nil {
+                return fmt.Errorf("could not close output file: %w", err)
+        }
+        return nil
+}
+
+// Abort removes a partly written output file, but leaves a device, pipe or
+// symlink that the output was written through
+func (s *fileSink) Abort() {
+        if s.file != nil {
+                s.file.Close()
+                if info, err := os.Lstat(s.Path); err == nil && info.Mode().IsRegular() {
+                        os.Remove(s.Path)
+                }
+        }
+}
+
+func (s *fileSink) String() string {
+        return s.Path
+}
+
+// stdoutSink writes to standard output
+type stdoutSink struct{}
+
+func (s *stdoutSink) Open() (io.Writer, error) { return os.Stdout, nil }
+func (s *stdoutSink) Close() error             { return nil }
+func (s *stdoutSink) Abort()                   {}
+func (s *stdoutSink) String() string           { return "(stdout)" }
+
+// httpSink streams the content with a single HTTP PUT, which starts on
+// Open and is sent chunked as cleaning goes, so that a large file is never
+// held in memory
+type httpSink struct {
+        URL  string
+        pipe *io.PipeWriter
+        done chan struct{}
+        err  error
+}
+
+func (s *httpSink) Open() (io.Writer, error) {
+        body, pipe := io.Pipe()
+        req, err := http.NewRequest(http.MethodPut, s.URL, body)
+        if err != nil {
+                return nil, fmt.Errorf("could not create upload request: %w", err)
+        }
+        req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+        s.pipe = pipe
+        s.done = make(chan struct{})
+        go func() {
+                // The upload lasts as long as cleaning does, so it has no time
+                // limit; a server that fails early stops the writes
+                s.err = doUpload(req, 0)
+                body.CloseWithError(s.err)
+                close(s.done)
+        }()
+        return s, nil
+}
+
+// Write passes p on to the upload; once the upload has failed, it returns
+// the reason rather than the closed pipe
+func (s *httpSink) Write(p []byte) (int, error) {
+        n, err := s.pipe.Write(p)
+        if err != nil {
+                <-s.done
+                if s.err != nil {
+                        err = s.err
+                }
+        }
+        return n, err
+}
+
+func (s *httpSink) Close() error {
+        s.pipe.Close()
+        <-s.done
+        return s.err
+}
+
+func (s *httpSink) Abort() {
+        if s.pipe != nil {
+                s.pipe.CloseWithError(errors.New("cleaning failed"))
+                <-s.done
+        }
+}
+
+func (s *httpSink) String() string { return s.URL }
+
+// s3Sink uploads the content to S3 with a SigV4-signed PUT on Close. S3
+// needs the length of the content up front, so it is held in memory, and
+// runClean refuses an s3:// output with -max-memory or -oversize chunk.
+// Credentials and region come from the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION variables;
+// AWS_ENDPOINT_URL selects an S3-compatible store (path-style addressing).
+type s3Sink struct {
+        Bucket string
+        Key    string
+        buffer bytes.Buffer
+}
+
+func (s *s3Sink) Open() (io.Writer, error) {
+        s.buffer.Reset()
+        return &s.buffer, nil
+}
+
+func (s *s3Sink) Close() error {
+        accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+        secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+        if accessKey == "" || secretKey == "" {
+                return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set for s3:// output")
+        }
+        region := os.Getenv("AWS_REGION")
+        if region == "" {
+                region = "us-east-1"
+        }
+
+        escapedKey := (&url.URL{Path: s.Key}).EscapedPath()
+        endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, region, escapedKey)
+        if custom := os.Getenv("AWS_ENDPOINT_URL"); custom != "" {
+                endpoint = strings.TrimSuffix(custom, "/") + "/" + s.Bucket + "/" + escapedKey
+        }
+
+        body := s.buffer.Bytes()
+        req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
+        if err != nil {
+                return fmt.Errorf("could not create upload request: %w", err)
+        }
+        req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+        if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+                req.Header.Set("X-Amz-Security-Token", token)
+        }
+        signS3Request(req, body, accessKey, secretKey, region, time.Now().UTC())
+
+        return doUpload(req, 5*time.Minute)
+}
+
+func (s *s3Sink) Abort() { s.buffer.Reset() }
+
+func (s *s3Sink) String() string { return "s3://" + s.Bucket + "/" + s.Key }
+
+// doUpload sends req and checks the response; a zero timeout sets no limit
+func doUpload(req *http.Request, timeout time.Duration) error {
+        client := &http.Client{Timeout: timeout}
+        resp, err := client.Do(req)
+        if err != nil {
+                return fmt.Errorf("upload failed: %w", err)
+        }
+        defer resp.Body.Close()
+
+        if resp.StatusCode < 200 || resp.StatusCode > 299 {
+                detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+                return fmt.Errorf("upload failed: %s %s", resp.Status, strings.TrimSpace(string(detail)))
+        }
+        return nil
+}
+
+// signS3Request adds an AWS Signature Version 4 Authorization header
+func signS3Request(req *http.Request, body []byte, accessKey, secretKey, region string, now time.Time) {
+        amzDate := now.Format("20060102T150405Z")
+        day := now.Format("20060102")
+        payloadHash := sha256Hex(body)
+
+        req.Header.Set("X-Amz-Date", amzDate)
+        req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+        signedHeaders := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+        if req.Header.Get("X-Amz-Security-Token") != "" {
+                signedHeaders = append(signedHeaders, "x-amz-security-token")
+        }
+
+        var canonicalHeaders strings.Builder
+        for _, h := range signedHeaders {
+                value := req.Header.Get(h)
+                if h == "host" {
+                        value = req.URL.Host
+                }
+                canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+        }
+
+        canonicalRequest := strings.Join([]string{
+                req.Method,
+                req.URL.EscapedPath(),
+                req.URL.RawQuery,
+                canonicalHeaders.String(),
+                strings.Join(signedHeaders, ";"),
+                payloadHash,
+        }, "\n")
+
+        scope := day + "/" + region + "/s3/aws4_request"
+        stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+        key := hmacSHA256([]byte("AWS4"+secretKey), day)
+        key = hmacSHA256(key, region)
+        key = hmacSHA256(key, "s3")
+        key = hmacSHA256(key, "aws4_request")
+        signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+        req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+                accessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+func sha256Hex(data []byte) string {
+        sum := sha256.Sum256(data)
+        return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+        mac := hmac.New(sha256.New, key)
+        mac.Write([]byte(data))
+        return mac.Sum(nil)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+        "io"
+        "unicode/utf8"
+)
+
+// byteCounter counts the bytes read from or written to the stream it wraps
+type byteCounter struct {
+        r io.Reader
+        w io.Writer
+        n int
+}
+
+func (c *byteCounter) Read(p []byte) (int, error) {
+        n, err := c.r.Read(p)
+        c.n += n
+        return n, err
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+        n, err := c.w.Write(p)
+        c.n += n
+        return n, err
+}
+
+// removedBytes returns the size in UTF-8 of the removed characters. A
+// replacement written instead (-replacement) is not subtracted; the size
+// delta accounts for it.
+func (s *CleaningStats) removedBytes() int {
+        total := 0
+        for char, count := range s.RemovedCharDetails {
+                total += utf8.RuneLen(char) * count
+        }
+        return total
+}
+
+// sizeDelta returns how many bytes the output is larger (or, if negative,
+// smaller) than the input
+func (s *CleaningStats) sizeDelta() int {
+        return s.OutputBytes - s.OriginalBytes
+}
@@ -0,0 +1,53 @@
+package main
+
+import "strings"
+
+// smartPunctuation maps the typographic punctuation that word processors
+// and language models produce, other than quotation marks (see
+// typographicQuotes), to ASCII that reads the same
+var smartPunctuation = map[rune]string{
+        '‐': "-",   // hyphen
+        '‑': "-",   // non-breaking hyphen
+        '‒': "-",   // figure dash
+        '–': "-",   // en dash
+        '—': "--",  // em dash
+        '―': "--",  // horizontal bar
+        '−': "-",   // minus sign
+        '…': "...", // horizontal ellipsis
+        '′': "'",   // prime (feet, minutes)
+        '″': "\"",  // double prime (inches, seconds)
+        '‴': "'''", // triple prime
+        '‵': "'",   // reversed prime
+        '«': "<<",  // left-pointing double angle quotation mark
+        '»': ">>",  // right-pointing double angle quotation mark
+        '‹': "<",   // single left-pointing angle quotation mark
+        '›': ">",   // single right-pointing angle quotation mark
+}
+
+// convertSmartPunct replaces dashes (unless -dashes decides about them),
+// ellipses, primes and guillemets with their ASCII equivalents and returns
+// the number of characters replaced. Curly quotes are left to
+// normalizeQuotes.
+func convertSmartPunct(s string, dashes bool) (string, int) {
+        if !strings.ContainsFunc(s, isSmartPunct) {
+                return s, 0
+        }
+        var b strings.Builder
+        b.Grow(len(s) + 8)
+        count := 0
+        for _, r := range s {
+                if ascii, ok := smartPunctuation[r]; ok && (dashes || !isDash(r)) {
+                        b.WriteString(ascii)
+                        count++
+                        continue
+                }
+                b.WriteRune(r)
+        }
+        return b.String(), count
+}
+
+// isSmartPunct reports whether -smart-punct converts a character
+func isSmartPunct(r rune) bool {
+        _, ok := smartPunctuation[r]
+        return ok
+}
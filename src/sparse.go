@@ -0,0 +1,128 @@
+package main
+
+import (
+        "io"
+        "os"
+        "reflect"
+)
+
+// Runs of zero bytes at least this long are written as holes
+const sparseBlockSize = 4096
+
+// Windows marks sparse files with this attribute
+const fileAttributeSparseFile = 0x200
+
+// isSparse reports whether a file has holes, i.e. occupies less disk space
+// than its size. Reading it yields zero bytes for the holes, so a plain copy
+// allocates them all.
+//
+// The fields needed live in the platform-specific info.Sys(): Blocks (in
+// 512-byte units) on Unix and FileAttributes on Windows. They are looked up
+// by name so that this builds on every platform.
+func isSparse(info os.FileInfo) bool {
+        sys := reflect.ValueOf(info.Sys())
+        if sys.Kind() == reflect.Ptr {
+                sys = sys.Elem()
+        }
+        if sys.Kind() != reflect.Struct {
+                return false
+        }
+        if blocks := sys.FieldByName("Blocks"); blocks.IsValid() && blocks.CanInt() {
+                return blocks.Int()*512 < info.Size()
+        }
+        if attrs := sys.FieldByName("FileAttributes"); attrs.IsValid() && attrs.CanUint() {
+                return attrs.Uint()&fileAttributeSparseFile != 0
+        }
+        return false
+}
+
+// allocatedSize is the disk space used by a file, or its size when the
+// platform does not tell
+func allocatedSize(info os.FileInfo) int64 {
+        sys := reflect.ValueOf(info.Sys())
+        if sys.Kind() == reflect.Ptr {
+                sys = sys.Elem()
+        }
+        if sys.Kind() == reflect.Struct {
+                if blocks := sys.FieldByName("Blocks"); blocks.IsValid() && blocks.CanInt() {
+                        return blocks.Int() * 512
+                }
+        }
+        return info.Size()
+}
+
+// sparseWriter writes to a file, seeking over long runs of zero bytes
+// instead of writing them so that the file system leaves holes there
+type sparseWriter struct {
+        file    *os.File
+        pending int64 // zero bytes skipped but not yet seeked over
+}
+
+func (w *sparseWriter) Write(p []byte) (int, error) {
+        written := 0
+        for len(p) > 0 {
+                zeros := 0
+                for zeros < len(p) && p[zeros] == 0 {
+                        zeros++
+                }
+                // Zeros right after a hole extend it
+                if zeros >= sparseBlockSize || (zeros > 0 && w.pending > 0) {
+                        w.pending += int64(zeros)
+                        written += zeros
+                        p = p[zeros:]
+                        continue
+                }
+
+                end := nextHole(p)
+                if err := w.skip(); err != nil {
+                        return written, err
+                }
+                n, err := w.file.Write(p[:end])
+                written += n
+                if err != nil {
+                        return written, err
+                }
+                p = p[end:]
+        }
+        return written, nil
+}
+
+// nextHole returns the start of the first run of zero bytes long enough to
+// become a hole, or len(p)
+func nextHole(p []byte) int {
+        run := 0
+        for i, b := range p {
+                if b != 0 {
+                        run = 0
+                        continue
+                }
+                run++
+                if run == sparseBlockSize {
+                        return i + 1 - run
+                }
+        }
+        return len(p)
+}
+
+// skip seeks over the pending zero bytes
+func (w *sparseWriter) skip() error {
+        if w.pending == 0 {
+                return nil
+        }
+        _, err := w.file.Seek(w.pending, io.SeekCurrent)
+        w.pending = 0
+        return err
+}
+
+// finish extends the file over a trailing hole, which seeking alone does not
+func (w *sparseWriter) finish() error {
+        if w.pending == 0 {
+                return nil
+        }
+        offset, err := w.file.Seek(w.pending, io.SeekCurrent)
+        if err != nil {
+                return err
+        }
+        w.pending = 0
+        return w.file.Truncate(offset)
+}
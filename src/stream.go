@@ -0,0 +1,169 @@
+package main
+
+import (
+        "encoding/json"
+        "fmt"
+        "io"
+        "os"
+        "strconv"
+        "strings"
+        "sync"
+        "time"
+)
+
+// findingStream writes the events of a run as newline-delimited JSON while
+// it is in progress, for -stream: a start event, one finding per removed
+// character and one file event as soon as each file is done, and an end
+// event. Each event is written with a single unbuffered write, so that a
+// reader following the file never sees half a line.
+type findingStream struct {
+        mu     sync.Mutex
+        out    io.Writer
+        closer io.Closer // the stream file; nil for a file descriptor passed in
+        err    error
+}
+
+// StreamEvent is one line of the -stream output. Fields that do not apply to
+// the kind of event are left out.
+type StreamEvent struct {
+        Event string    `json:"event"`
+        Time  time.Time `json:"time"`
+
+        // start and end
+        Files     int  `json:"files,omitempty"`
+        CheckOnly bool `json:"check_only,omitempty"`
+        Changed   int  `json:"changed,omitempty"`
+        Failed    int  `json:"failed,omitempty"`
+        Removed   int  `json:"removed_chars,omitempty"`
+
+        // finding
+        File      string `json:"file,omitempty"`
+        Line      int    `json:"line,omitempty"`
+        Column    int    `json:"column,omitempty"`
+        Codepoint string `json:"codepoint,omitempty"`
+        Name      string `json:"name,omitempty"`
+        Category  string `json:"category,omitempty"`
+        Rule      string `json:"rule,omitempty"`
+        Level     string `json:"level,omitempty"`
+
+        // file
+        Result *FileReport `json:"result,omitempty"`
+}
+
+// openFindingStream opens the destination of -stream: a file, created or
+// truncated, or an open file descriptor written as fd:N. It returns nil if
+// spec is empty.
+func openFindingStream(spec string) (*findingStream, error) {
+        if spec == "" {
+                return nil, nil
+        }
+        if fd, ok := strings.CutPrefix(spec, "fd:"); ok {
+                n, err := strconv.Atoi(fd)
+                if err != nil || n < 0 {
+                        return nil, fmt.Errorf("invalid -stream file descriptor '%s' (expected fd:N)", spec)
+                }
+                return &findingStream{out: os.NewFile(uintptr(n), spec)}, nil
+        }
+        file, err := os.Create(spec)
+        if err != nil {
+                return nil, fmt.Errorf("could not create stream file: %w", err)
+        }
+        return &findingStream{out: file, closer: file}, nil
+}
+
+// emit writes one event. After the first failed write the stream stops, and
+// Close reports the error.
+func (s *findingStream) emit(event StreamEvent) {
+        if s == nil {
+                return
+        }
+        event.Time = time.Now()
+        line, err := json.Marshal(event)
+        if err != nil {
+                return
+        }
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if s.err == nil {
+                _, s.err = s.out.Write(append(line, '\n'))
+        }
+}
+
+// start announces a run over the given number of files
+func (s *findingStream) start(files int, checkOnly bool) {
+        s.emit(StreamEvent{Event: "start", Files: files, CheckOnly: checkOnly})
+}
+
+// file writes the findings of a finished file, then its result. Findings
+// beyond the locations recorded (maxLocations) appear only in the counts
+// of the result.
+func (s *findingStream) file(r FileResult) {
+        if s == nil {
+                return
+        }
+        if r.Err != nil {
+                s.emit(StreamEvent{Event: "file", File: r.InputPath, Result: &FileReport{
+                        Input:    r.InputPath,
+                        Error:    r.Err.Error(),
+                        Findings: []Finding{},
+                        Warnings: []Warning{},
+                }})
+                return
+        }
+        for _, loc := range r.Stats.Locations {
+                ruleID := sarifRuleID(loc.Char)
+                s.emit(StreamEvent{
+                        Event:     "finding",
+                        File:      r.InputPath,
+                        Line:      loc.Line,
+                        Column:    loc.Column,
+                        Codepoint: fmt.Sprintf("U+%04X", loc.Char),
+                        Name:      describeChar(loc.Char),
+                        Category:  charCategory(loc.Char),
+                        Rule:      ruleID,
+                        Level:     ruleLevel(ruleID),
+                })
+        }
+        s.emit(StreamEvent{Event: "file", File: r.InputPath, Result: &FileReport{
+                Input:     r.InputPath,
+                Output:    r.OutputPath,
+                Changed:   r.Stats.changed(),
+                Stats:     newStatsReport(r.Stats),
+                Findings:  newFindings(r.Stats),
+                Warnings:  nonNilWarnings(r.Stats.Warnings),
+                Unwritten: r.Stats.OutputSkipped,
+        }})
+}
+
+// end closes the run with its totals
+func (s *findingStream) end(results []FileResult) {
+        if s == nil {
+                return
+        }
+        event := StreamEvent{Event: "end", Files: len(results), Failed: failedCount(results)}
+        for _, r := range results {
+                if r.Err == nil {
+                        event.Removed += r.Stats.RemovedChars
+                        if r.Stats.changed() {
+                                event.Changed++
+                        }
+                }
+        }
+        s.emit(event)
+}
+
+// Close closes a stream file (but not a file descriptor passed in) and
+// returns the first error writing the stream
+func (s *findingStream) Close() error {
+        if s == nil {
+                return nil
+        }
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if s.closer != nil {
+                if err := s.closer.Close(); s.err == nil {
+                        s.err = err
+                }
+        }
+        return s.err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+        "bufio"
+        "bytes"
+        "fmt"
+        "regexp"
+        "strconv"
+        "strings"
+        "unicode/utf8"
+)
+
+var entityReferencePattern = regexp.MustCompile(`&#[xX][0-9a-zA-Z]*;|&#[0-9a-zA-Z]*;|&[a-zA-Z][a-zA-Z0-9]*;`)
+
+// checkLineEncoding validates the raw bytes of one input line. In strict
+// mode it rejects input that looks like another Unicode encoding, since
+// cleaning such a file byte-wise would silently mangle it. Invalid UTF-8
+// sequences are counted and fail the file with -invalid-utf8 error (which
+// -strict implies); otherwise they are recorded as warnings. column is the
+// number of characters preceding line when it is only a chunk of a long
+// line.
+func checkLineEncoding(stats *CleaningStats, line []byte, lineNum, column int, options CleaningOptions) error {
+        if options.Strict {
+                if lineNum == 1 && column == 0 {
+                        switch {
+                        case bytes.HasPrefix(line, []byte{0x00, 0x00, 0xFE, 0xFF}),
+                                bytes.HasPrefix(line, []byte{0xFF, 0xFE, 0x00, 0x00}):
+                                return fmt.Errorf("strict: input starts with a UTF-32 byte order mark")
+                        case bytes.HasPrefix(line, []byte{0xFE, 0xFF}),
+                                bytes.HasPrefix(line, []byte{0xFF, 0xFE}):
+                                return fmt.Errorf("strict: input starts with a UTF-16 byte order mark")
+                        }
+                }
+
+                if i := bytes.IndexByte(line, 0); i >= 0 {
+                        return fmt.Errorf("strict: NUL byte at line %d, column %d (binary file or UTF-16/32 without BOM?)",
+                                lineNum, column+1+utf8.RuneCount(line[:i]))
+                }
+        }
+
+        if utf8.Valid(line) {
+                return nil
+        }
+        for i := 0; i < len(line); {
+                r, size := utf8.DecodeRune(line[i:])
+                if r == utf8.RuneError && size <= 1 {
+                        if options.InvalidUTF8 == "error" {
+                                prefix := ""
+                                if options.Strict {
+                                        prefix = "strict: "
+                                }
+                                return fmt.Errorf("%sinvalid UTF-8 byte 0x%02X at line %d, column %d",
+                                        prefix, line[i], lineNum, column+1+utf8.RuneCount(line[:i]))
+                        }
+                        stats.InvalidUTF8Sequences++
+                        stats.InvalidUTF8Action = invalidUTF8Action(options.InvalidUTF8)
+                        stats.warn(warnInvalidUTF8, lineNum, "invalid UTF-8 byte 0x%02X %s", line[i], stats.InvalidUTF8Action)
+                }
+                i += size
+        }
+        return nil
+}
+
+// checkContentEncoding runs checkLineEncoding over a whole document
+func checkContentEncoding(stats *CleaningStats, content []byte, options CleaningOptions) error {
+        lines := newLineReader(bufio.NewReader(bytes.NewReader(content)))
+        for lineNum := 1; ; lineNum++ {
+                line, ending, err := lines.readLine()
+                if err != nil {
+                        return nil
+                }
+                if err := checkLineEncoding(stats, []byte(line+ending), lineNum, 0, options); err != nil {
+                        return err
+                }
+        }
+}
+
+// invalidEntity is an entity reference that stripHTML passes through undecoded
+type invalidEntity struct {
+        Ref  string
+        Line int
+        Col  int
+}
+
+func (e invalidEntity) describe() string {
+        if strings.HasPrefix(e.Ref, "&#") {
+                return "invalid numeric character reference " + e.Ref
+        }
+        return "unknown HTML entity " + e.Ref
+}
+
+func findInvalidEntities(text string) []invalidEntity {
+        var invalid []invalidEntity
+        for _, loc := range entityReferencePattern.FindAllStringIndex(text, -1) {
+                ref := text[loc[0]:loc[1]]
+                if _, ok := htmlEntities[ref]; ok {
+                        continue
+                }
+                if decodeNumericEntity(ref) >= 0 {
+                        continue
+                }
+
+                line, col := positionOf([]byte(text), loc[0])
+                invalid = append(invalid, invalidEntity{Ref: ref, Line: line, Col: col})
+        }
+        return invalid
+}
+
+// checkEntities is run in strict mode before HTML stripping and rejects
+// entity references that stripHTML would pass through undecoded
+func checkEntities(text string) error {
+        if invalid := findInvalidEntities(text); len(invalid) > 0 {
+                e := invalid[0]
+                return fmt.Errorf("strict: %s at line %d, column %d", e.describe(), e.Line, e.Col)
+        }
+        return nil
+}
+
+// decodeNumericEntity returns the code point of a decimal or hexadecimal
+// character reference, or -1 if ref is not a valid one
+func decodeNumericEntity(ref string) rune {
+        if !strings.HasPrefix(ref, "&#") || !strings.HasSuffix(ref, ";") {
+                return -1
+        }
+        digits := ref[2 : len(ref)-1]
+        base := 10
+        if strings.HasPrefix(digits, "x") || strings.HasPrefix(digits, "X") {
+                digits = digits[1:]
+                base = 16
+        }
+
+        code, err := strconv.ParseInt(digits, base, 32)
+        if err != nil || code <= 0 || code > utf8.MaxRune || (code >= 0xD800 && code <= 0xDFFF) {
+                return -1
+        }
+        return rune(code)
+}
+
+// positionOf converts a byte offset into a 1-based line and column (in runes)
+func positionOf(content []byte, offset int) (int, int) {
+        line := 1 + bytes.Count(content[:offset], []byte("\n"))
+        lineStart := bytes.LastIndexByte(content[:offset], '\n') + 1
+        col := 1 + utf8.RuneCount(content[lineStart:offset])
+        return line, col
+}
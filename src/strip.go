@@ -0,0 +1,456 @@
+package main
+
+import (
+        "bytes"
+        "encoding/json"
+        "fmt"
+        "regexp"
+        "strings"
+)
+
+func detectFileFormat(content string) string {
+        if len(content) == 0 {
+                return "unknown"
+        }
+        if isSubtitles(content) {
+                return "subtitles"
+        }
+
+        lines := strings.Split(content, "\n")
+        markdownScore := 0
+        htmlScore := 0
+        totalNonEmptyLines := 0
+
+        htmlTagPattern := regexp.MustCompile(`<[a-zA-Z][^>]*>`)
+        doctypePattern := regexp.MustCompile(`(?i)<!DOCTYPE\s+html`)
+        htmlEntityPattern := regexp.MustCompile(`&[a-zA-Z]+;|&#\d+;|&#x[0-9a-fA-F]+;`)
+        markdownHeaderPattern := regexp.MustCompile(`^#{1,6}\s+.+`)
+        markdownListPattern := regexp.MustCompile(`^[\s]*[-*+]\s+.+`)
+        markdownOrderedListPattern := regexp.MustCompile(`^[\s]*\d+\.\s+.+`)
+        markdownCodePattern := regexp.MustCompile("^```")
+        markdownLinkPattern := regexp.MustCompile(`\[.+?\]\(.+?\)`)
+        markdownBoldPattern := regexp.MustCompile(`\*\*.+?\*\*|__.+?__`)
+        markdownItalicPattern := regexp.MustCompile(`\*.+?\*|_.+?_`)
+        inlineCodePattern := regexp.MustCompile("`[^`]+`")
+
+        inCodeBlock := false
+
+        for _, line := range lines {
+                trimmed := strings.TrimSpace(line)
+                if trimmed == "" {
+                        continue
+                }
+
+                totalNonEmptyLines++
+
+                if markdownCodePattern.MatchString(trimmed) {
+                        inCodeBlock = !inCodeBlock
+                }
+
+                if doctypePattern.MatchString(trimmed) {
+                        htmlScore += 15
+                }
+                if htmlTagPattern.MatchString(trimmed) {
+                        htmlScore += 3
+                }
+                if htmlEntityPattern.MatchString(trimmed) {
+                        htmlScore += 2
+                }
+
+                if markdownHeaderPattern.MatchString(trimmed) {
+                        markdownScore += 4
+                }
+                if markdownListPattern.MatchString(trimmed) {
+                        markdownScore += 3
+                }
+                if markdownOrderedListPattern.MatchString(trimmed) {
+                        markdownScore += 3
+                }
+                if markdownCodePattern.MatchString(trimmed) {
+                        markdownScore += 4
+                }
+                if !inCodeBlock {
+                        if markdownLinkPattern.MatchString(trimmed) {
+                                markdownScore += 3
+                        }
+                        if markdownBoldPattern.MatchString(trimmed) {
+                                markdownScore += 2
+                        }
+                        if markdownItalicPattern.MatchString(trimmed) {
+                                markdownScore += 1
+                        }
+                        if inlineCodePattern.MatchString(trimmed) {
+                                markdownScore += 1
+                        }
+                }
+        }
+
+        if totalNonEmptyLines == 0 {
+                return "unknown"
+        }
+
+        threshold := totalNonEmptyLines / 7
+
+        if htmlScore > markdownScore && htmlScore >= threshold {
+                return "html"
+        } else if markdownScore > htmlScore && markdownScore >= threshold {
+                return "markdown"
+        }
+
+        return "unknown"
+}
+
+// replaceCountingFunc is like replaceCounting but computes each replacement
+// from the submatches of the match
+func replaceCountingFunc(pattern *regexp.Regexp, text string, counts map[string]int, construct string, repl func([]string) string) string {
+        return pattern.ReplaceAllStringFunc(text, func(match string) string {
+                counts[construct]++
+                return repl(pattern.FindStringSubmatch(match))
+        })
+}
+
+// annotation renders an attribute kept by -strip-keep, or nothing if it is
+// empty
+func annotation(name, value string) string {
+        value = strings.Join(strings.Fields(value), " ")
+        if value == "" {
+                return ""
+        }
+        return "[" + name + ": " + value + "]"
+}
+
+// replaceCounting is regexp.ReplaceAllString that also adds the number of
+// matches to counts[construct]
+func replaceCounting(pattern *regexp.Regexp, text, template string, counts map[string]int, construct string) string {
+        matches := pattern.FindAllStringSubmatchIndex(text, -1)
+        if len(matches) == 0 {
+                return text
+        }
+        counts[construct] += len(matches)
+
+        result := make([]byte, 0, len(text))
+        last := 0
+        for _, m := range matches {
+                result = append(result, text[last:m[0]]...)
+                result = pattern.ExpandString(result, template, text, m)
+                last = m[1]
+        }
+        return string(append(result, text[last:]...))
+}
+
+// stripMarkdown removes Markdown syntax, counting each kind of construct
+// removed in counts. Image alt texts and link titles named in keep are
+// kept as annotations.
+func stripMarkdown(text string, keep map[string]bool, counts map[string]int) string {
+        codeBlockPattern := regexp.MustCompile("(?s)```[a-zA-Z]*\n(.*?)```")
+        text = replaceCounting(codeBlockPattern, text, "$1", counts, "code blocks")
+
+        inlineCodePattern := regexp.MustCompile("`([^`]+)`")
+        text = replaceCounting(inlineCodePattern, text, "$1", counts, "inline code spans")
+
+        headerPattern := regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+        text = replaceCounting(headerPattern, text, "$1", counts, "headings")
+
+        // RE2 has no backreferences, so each delimiter needs its own pattern
+        boldPattern := regexp.MustCompile(`\*\*(.*?)\*\*`)
+        text = replaceCounting(boldPattern, text, "$1", counts, "bold spans")
+        boldUnderscorePattern := regexp.MustCompile(`__(.*?)__`)
+        text = replaceCounting(boldUnderscorePattern, text, "$1", counts, "bold spans")
+
+        italicPattern := regexp.MustCompile(`\*(.*?)\*`)
+        text = replaceCounting(italicPattern, text, "$1", counts, "italic spans")
+        italicUnderscorePattern := regexp.MustCompile(`_(.*?)_`)
+        text = replaceCounting(italicUnderscorePattern, text, "$1", counts, "italic spans")
+
+        strikePattern := regexp.MustCompile(`~~(.*?)~~`)
+        text = replaceCounting(strikePattern, text, "$1", counts, "strikethrough spans")
+
+        imagePattern := regexp.MustCompile(`!\[([^\]]*)\]\([^\)"]*?(?:\s+"([^"]*)")?\)`)
+        text = replaceCountingFunc(imagePattern, text, counts, "images", func(m []string) string {
+                alt := m[1]
+                if keep["alt"] {
+                        alt = annotation("alt", m[1])
+                }
+                if keep["title"] && m[2] != "" {
+                        return strings.TrimSpace(alt + " " + annotation("title", m[2]))
+                }
+                return alt
+        })
+
+        linkPattern := regexp.MustCompile(`\[([^\]]+)\]\([^\)"]*?(?:\s+"([^"]*)")?\)`)
+        text = replaceCountingFunc(linkPattern, text, counts, "links", func(m []string) string {
+                if keep["title"] && m[2] != "" {
+                        return m[1] + " " + annotation("title", m[2])
+                }
+                return m[1]
+        })
+
+        refLinkPattern := regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+        text = replaceCounting(refLinkPattern, text, "$1", counts, "reference links")
+
+        linkDefPattern := regexp.MustCompile(`(?m)^\[.+?\]:\s+.+$`)
+        text = replaceCounting(linkDefPattern, text, "", counts, "link definitions")
+
+        hrPattern := regexp.MustCompile(`(?m)^[\s]*[\-\*_]{3,}[\s]*$`)
+        text = replaceCounting(hrPattern, text, "", counts, "horizontal rules")
+
+        blockquotePattern := regexp.MustCompile(`(?m)^>\s*(.*)$`)
+        text = replaceCounting(blockquotePattern, text, "$1", counts, "blockquote lines")
+
+        // Task items are list items too, so they must be matched first
+        taskListPattern := regexp.MustCompile(`(?m)^[\s]*-\s*\[[xX\s]\]\s+(.+)$`)
+        text = replaceCounting(taskListPattern, text, "$1", counts, "task list items")
+
+        listPattern := regexp.MustCompile(`(?m)^[\s]*[-*+]\s+(.+)$`)
+        text = replaceCounting(listPattern, text, "$1", counts, "list items")
+
+        orderedListPattern := regexp.MustCompile(`(?m)^[\s]*\d+\.\s+(.+)$`)
+        text = replaceCounting(orderedListPattern, text, "$1", counts, "numbered list items")
+
+        if n := strings.Count(text, "|"); n > 0 {
+                counts["table separators"] += n
+                text = strings.ReplaceAll(text, "|", " ")
+        }
+
+        htmlCommentPattern := regexp.MustCompile(`<!--.*?-->`)
+        text = replaceCounting(htmlCommentPattern, text, "", counts, "HTML comments")
+
+        multipleNewlinesPattern := regexp.MustCompile(`\n{3,}`)
+        text = multipleNewlinesPattern.ReplaceAllString(text, "\n\n")
+
+        return text
+}
+
+var (
+        tagPattern     = regexp.MustCompile(`<[^>]+>`)
+        tagNamePattern = regexp.MustCompile(`^<\s*([a-zA-Z][a-zA-Z0-9:-]*)`)
+        tagAttrPattern = regexp.MustCompile(`\s([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// htmlAttributes returns the name (lowercased) and value of each attribute
+// of a tag, in order
+func htmlAttributes(tag string) [][2]string {
+        var attrs [][2]string
+        for _, m := range tagAttrPattern.FindAllStringSubmatch(tag, -1) {
+                attrs = append(attrs, [2]string{strings.ToLower(m[1]), m[2] + m[3] + m[4]})
+        }
+        return attrs
+}
+
+// htmlAttribute returns the value of the named attribute of a tag
+func htmlAttribute(tag, name string) string {
+        for _, attr := range htmlAttributes(tag) {
+                if attr[0] == name {
+                        return attr[1]
+                }
+        }
+        return ""
+}
+
+// stripHTML removes tags, comments, scripts and styles and decodes
+// entities. Removed constructs are counted in counts, tags by name. The
+// attributes in options.StripKeep are kept as annotations where their tag
+// was, and the metadata in options.StripExtract is moved to a header.
+func stripHTML(text string, options CleaningOptions, counts map[string]int) (string, int) {
+        entitiesDecoded := 0
+        keep := options.StripKeep
+
+        commentPattern := regexp.MustCompile(`<!--[\s\S]*?-->`)
+        text = replaceCounting(commentPattern, text, "", counts, "comments")
+
+        var header string
+        if len(options.StripExtract) > 0 {
+                header, text = extractHTMLMetadata(text, options.StripExtract)
+        }
+
+        scriptPattern := regexp.MustCompile(`(?is)<script[^>]*>[\s\S]*?</script>`)
+        text = replaceCounting(scriptPattern, text, "", counts, "<script> blocks")
+        stylePattern := regexp.MustCompile(`(?is)<style[^>]*>[\s\S]*?</style>`)
+        text = replaceCounting(stylePattern, text, "", counts, "<style> blocks")
+
+        text = tagPattern.ReplaceAllStringFunc(text, func(tag string) string {
+                switch {
+                case strings.HasPrefix(tag, "</"):
+                        counts["closing tags"]++
+                case strings.HasPrefix(tag, "<!") || strings.HasPrefix(tag, "<?"):
+                        counts["declarations"]++
+                default:
+                        name := "other"
+                        if m := tagNamePattern.FindStringSubmatch(tag); m != nil {
+                                name = strings.ToLower(m[1])
+                        }
+                        counts["<"+name+"> tags"]++
+                        return tagAnnotations(tag, keep)
+                }
+                return ""
+        })
+
+        // The header is added before entities are decoded, since titles and
+        // attribute values contain them too
+        if header != "" {
+                text = header + "\n" + text
+        }
+
+        for entity, char := range htmlEntities {
+                if strings.Contains(text, entity) {
+                        count := strings.Count(text, entity)
+                        entitiesDecoded += count
+                        text = strings.ReplaceAll(text, entity, char)
+                }
+        }
+
+        numericEntityPattern := regexp.MustCompile(`&#(\d+);`)
+        text = numericEntityPattern.ReplaceAllStringFunc(text, func(match string) string {
+                var code int
+                n, err := fmt.Sscanf(match, "&#%d;", &code)
+                if err == nil && n == 1 && code > 0 && code <= 0x10FFFF {
+                        entitiesDecoded++
+                        return string(rune(code))
+                }
+                return match
+        })
+
+        hexEntityPattern := regexp.MustCompile(`(?i)&#x([0-9a-fA-F]+);`)
+        text = hexEntityPattern.ReplaceAllStringFunc(text, func(match string) string {
+                var code int
+                n, err := fmt.Sscanf(strings.ToLower(match), "&#x%x;", &code)
+                if err == nil && n == 1 && code > 0 && code <= 0x10FFFF {
+                        entitiesDecoded++
+                        return string(rune(code))
+                }
+                return match
+        })
+
+        multipleSpacesPattern := regexp.MustCompile(`[ \t]+`)
+        text = multipleSpacesPattern.ReplaceAllString(text, " ")
+
+        if len(keep) > 0 {
+                // Drop the padding of annotations at the start or end of a line
+                annotationEdgePattern := regexp.MustCompile(`(?m)^ (\[(?:alt|title|aria-label): )|(\]) $`)
+                text = annotationEdgePattern.ReplaceAllString(text, "$1$2")
+        }
+
+        multipleNewlinesPattern := regexp.MustCompile(`\n{3,}`)
+        text = multipleNewlinesPattern.ReplaceAllString(text, "\n\n")
+
+        return text, entitiesDecoded
+}
+
+// tagAnnotations renders the attributes of an opening tag named in keep,
+// padded with spaces so they do not run into the surrounding text
+func tagAnnotations(tag string, keep map[string]bool) string {
+        if len(keep) == 0 {
+                return ""
+        }
+        var annotations []string
+        for _, attr := range htmlAttributes(tag) {
+                if !keep[attr[0]] {
+                        continue
+                }
+                if a := annotation(attr[0], attr[1]); a != "" {
+                        annotations = append(annotations, a)
+                }
+        }
+        if len(annotations) == 0 {
+                return ""
+        }
+        return " " + strings.Join(annotations, " ") + " "
+}
+
+var (
+        titleElementPattern  = regexp.MustCompile(`(?is)<title\b[^>]*>(.*?)</title\s*>`)
+        metaTagPattern       = regexp.MustCompile(`(?i)<meta\b[^>]*>`)
+        scriptElementPattern = regexp.MustCompile(`(?is)(<script\b[^>]*>)(.*?)</script\s*>`)
+)
+
+// extractHTMLMetadata removes the title, the meta description and JSON-LD
+// blocks (as selected by extract) from an HTML document and returns them as
+// "Title: ..." lines for the top of the stripped text
+func extractHTMLMetadata(text string, extract map[string]bool) (string, string) {
+        var header strings.Builder
+
+        if extract["title"] {
+                if m := titleElementPattern.FindStringSubmatchIndex(text); m != nil {
+                        title := tagPattern.ReplaceAllString(text[m[2]:m[3]], "")
+                        fmt.Fprintf(&header, "Title: %s\n", strings.Join(strings.Fields(title), " "))
+                        text = text[:m[0]] + text[m[1]:]
+                }
+        }
+
+        if extract["description"] {
+                for _, tag := range metaTagPattern.FindAllString(text, -1) {
+                        if strings.EqualFold(htmlAttribute(tag, "name"), "description") {
+                                description := htmlAttribute(tag, "content")
+                                fmt.Fprintf(&header, "Description: %s\n", strings.Join(strings.Fields(description), " "))
+                                break
+                        }
+                }
+        }
+
+        if extract["json-ld"] {
+                text = scriptElementPattern.ReplaceAllStringFunc(text, func(element string) string {
+                        m := scriptElementPattern.FindStringSubmatch(element)
+                        if !strings.EqualFold(strings.TrimSpace(htmlAttribute(m[1], "type")), "application/ld+json") {
+                                return element
+                        }
+                        var compact bytes.Buffer
+                        if err := json.Compact(&compact, []byte(m[2])); err != nil {
+                                // Keep invalid JSON as it is, on one line
+                                compact.Reset()
+                                compact.WriteString(strings.Join(strings.Fields(m[2]), " "))
+                        }
+                        fmt.Fprintf(&header, "JSON-LD: %s\n", compact.String())
+                        return ""
+                })
+        }
+
+        return header.String(), text
+}
+
+// stripContent strips Markdown or HTML formatting as requested by
+// options.StripFormat, after checking it against the detected format
+func stripContent(content, detectedFormat string, stats *CleaningStats, options CleaningOptions, verbose bool) (string, error) {
+        if options.StripFormat == "markdown" {
+                if detectedFormat != "markdown" {
+                        return "", fmt.Errorf("file does not appear to be Markdown (detected: %s)", detectedFormat)
+                }
+                if verbose {
+                        fmt.Println("Stripping Markdown formatting...")
+                }
+                stats.StrippedConstructs = make(map[string]int)
+                content = stripMarkdown(content, options.StripKeep, stats.StrippedConstructs)
+                stats.MarkdownStripped = true
+        } else if options.StripFormat == "html" {
+                if detectedFormat != "html" {
+                        return "", fmt.Errorf("file does not appear to be HTML (detected: %s)", detectedFormat)
+                }
+                if options.Strict {
+                        if err := checkEntities(content); err != nil {
+                                return "", err
+                        }
+                }
+                for _, e := range findInvalidEntities(content) {
+                        stats.warn(warnUnknownEntity, e.Line, "%s left undecoded", e.describe())
+                }
+                if verbose {
+                        fmt.Println("Stripping HTML tags and decoding entities...")
+                }
+                var entitiesDecoded int
+                stats.StrippedConstructs = make(map[string]int)
+                content, entitiesDecoded = stripHTML(content, options, stats.StrippedConstructs)
+                stats.HTMLStripped = true
+                stats.HTMLEntitiesDecoded = entitiesDecoded
+        } else if options.StripFormat == "subtitles" {
+                if detectedFormat != "subtitles" {
+                        return "", fmt.Errorf("file does not appear to be SubRip or WebVTT subtitles (detected: %s)", detectedFormat)
+                }
+                if verbose {
+                        fmt.Println("Flattening subtitles to a transcript...")
+                }
+                stats.StrippedConstructs = make(map[string]int)
+                content = stripSubtitles(content, stats.StrippedConstructs)
+                stats.SubtitlesStripped = true
+        }
+
+        return content, nil
+}
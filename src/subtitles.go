@@ -0,0 +1,111 @@
+package main
+
+import (
+        "html"
+        "regexp"
+        "strings"
+)
+
+var (
+        // subtitleTimingPattern matches the timing line of a SubRip or WebVTT
+        // cue, e.g. "00:00:01,000 --> 00:00:04,000" (WebVTT may leave out
+        // the hours and adds cue settings after it)
+        subtitleTimingPattern = regexp.MustCompile(`^(?:\d+:)?\d{2}:\d{2}[,.]\d{3}\s+-->\s+(?:\d+:)?\d{2}:\d{2}[,.]\d{3}`)
+        // subtitleTagPattern matches formatting tags (<i>, <font color=...>,
+        // <v Speaker>, <c.yellow>), WebVTT inline timestamps and SubStation
+        // overrides such as {\an8}
+        subtitleTagPattern = regexp.MustCompile(`<[^>\n]*>|\{\\[^}\n]*\}`)
+        // dialogueDashPattern matches the dash that marks a change of
+        // speaker, but not a minus sign
+        dialogueDashPattern = regexp.MustCompile(`^[-–—]\s*(\pL)`)
+        // sentenceEndPattern matches the space after the end of a sentence
+        sentenceEndPattern = regexp.MustCompile(`([.!?…]["'”’)\]]*) +`)
+)
+
+// isSubtitles reports whether content looks like a SubRip (.srt) or
+// WebVTT (.vtt) file: a WEBVTT header, or a cue number followed by a
+// timing line
+func isSubtitles(content string) bool {
+        content = strings.TrimLeft(content, "\uFEFF \t\r\n")
+        if strings.HasPrefix(content, "WEBVTT") {
+                return true
+        }
+        lines := strings.SplitN(content, "\n", 3)
+        if len(lines) < 2 {
+                return false
+        }
+        number := strings.TrimSpace(lines[0])
+        return number != "" && strings.Trim(number, "0123456789") == "" &&
+                subtitleTimingPattern.MatchString(strings.TrimSpace(lines[1]))
+}
+
+// stripSubtitles flattens a SubRip or WebVTT file into a transcript: cue
+// numbers and identifiers, timings, the WebVTT header and NOTE, STYLE and
+// REGION blocks are removed, formatting tags are dropped and entities
+// decoded, and the text of the cues is joined into sentences, one per line.
+// A cue line that repeats the previous one, as in the rolling captions of
+// automatically generated subtitles, is left out.
+func stripSubtitles(text string, counts map[string]int) string {
+        lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+        var fragments []string
+        previous := ""
+
+        for i := 0; i < len(lines); i++ {
+                line := strings.TrimSpace(strings.TrimPrefix(lines[i], "\uFEFF"))
+                if line == "" {
+                        continue
+                }
+
+                // Blocks that are not cues run until the next blank line
+                if i == 0 && strings.HasPrefix(line, "WEBVTT") || isVTTBlock(line) {
+                        if !strings.HasPrefix(line, "WEBVTT") {
+                                counts[strings.ToLower(strings.Fields(line)[0])+" blocks"]++
+                        }
+                        for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+                                i++
+                        }
+                        continue
+                }
+
+                if subtitleTimingPattern.MatchString(line) {
+                        counts["timings"]++
+                        continue
+                }
+                // A cue number or identifier precedes the timing line
+                if i+1 < len(lines) && subtitleTimingPattern.MatchString(strings.TrimSpace(lines[i+1])) {
+                        counts["cue numbers"]++
+                        continue
+                }
+
+                line = replaceCounting(subtitleTagPattern, line, "", counts, "tags")
+                line = html.UnescapeString(line)
+                line = replaceCounting(dialogueDashPattern, line, "$1", counts, "dialogue dashes")
+                line = strings.Join(strings.Fields(line), " ")
+                if line == "" {
+                        continue
+                }
+                if line == previous {
+                        counts["repeated lines"]++
+                        continue
+                }
+                previous = line
+                fragments = append(fragments, line)
+        }
+
+        if len(fragments) == 0 {
+                return ""
+        }
+        transcript := sentenceEndPattern.ReplaceAllString(strings.Join(fragments, " "), "$1\n")
+        return transcript + "\n"
+}
+
+// isVTTBlock reports whether a line starts a WebVTT comment, style sheet or
+// region definition
+func isVTTBlock(line string) bool {
+        for _, keyword := range []string{"NOTE", "STYLE", "REGION"} {
+                if line == keyword || strings.HasPrefix(line, keyword+" ") || strings.HasPrefix(line, keyword+"\t") {
+                        return true
+                }
+        }
+        return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+        "encoding/json"
+        "fmt"
+        "io"
+        "os"
+        "path/filepath"
+        "strings"
+        "text/template"
+)
+
+// templateFuncs are the functions a report template can call besides those
+// of text/template: json quotes a value for a JSON document such as a Slack
+// message, size renders a number of bytes as 1.5 MB, and join and repeat
+// are those of the strings package
+var templateFuncs = template.FuncMap{
+        "json": func(v interface{}) (string, error) {
+                data, err := json.Marshal(v)
+                return string(data), err
+        },
+        "size":   func(n int) string { return formatSize(int64(n)) },
+        "join":   strings.Join,
+        "repeat": strings.Repeat,
+}
+
+// loadReportTemplate parses the text/template of -report-template, or
+// returns nil if path is empty
+func loadReportTemplate(path string) (*template.Template, error) {
+        if path == "" {
+                return nil, nil
+        }
+        data, err := os.ReadFile(path)
+        if err != nil {
+                return nil, fmt.Errorf("could not read report template: %w", err)
+        }
+        tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(data))
+        if err != nil {
+                return nil, fmt.Errorf("invalid report template: %w", err)
+        }
+        return tmpl, nil
+}
+
+// writeTemplateReport executes tmpl over the report of a run, the same
+// data as the JSON report with the Go field names (.Files, .Totals.RemovedChars)
+func writeTemplateReport(w io.Writer, tmpl *template.Template, results []FileResult, targetOS string, checkOnly bool, duplicates [][]string) error {
+        report := newJSONReport(results, targetOS, checkOnly, duplicates)
+        if err := tmpl.Execute(w, report); err != nil {
+                return fmt.Errorf("could not write report: %w", err)
+        }
+        return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+        "fmt"
+        "io"
+        "os"
+        "path/filepath"
+        "reflect"
+)
+
+// checkTempDir checks the directory named with -tmpdir
+func checkTempDir(dir string) error {
+        if dir == "" {
+                return nil
+        }
+        info, err := os.Stat(dir)
+        if err != nil || !info.IsDir() {
+                return fmt.Errorf("-tmpdir '%s' is not a directory", dir)
+        }
+        return nil
+}
+
+// createTempFor creates the temporary file that the cleaned content of path
+// is written to before it replaces path: in dir if set, and otherwise next
+// to path, where the rename that replaces it is atomic
+func createTempFor(path, dir string) (string, error) {
+        if dir == "" {
+                dir = filepath.Dir(path)
+        }
+        tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+        if err != nil {
+                return "", err
+        }
+        tmp.Close()
+        return tmp.Name(), nil
+}
+
+// replaceFile writes data to path through a temporary file next to it, so
+// that an interrupted write leaves the previous content intact
+func replaceFile(path string, data []byte) error {
+        tmpPath, err := createTempFor(path, "")
+        if err != nil {
+                return err
+        }
+        if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+                os.Remove(tmpPath)
+                return err
+        }
+        if err := os.Rename(tmpPath, path); err != nil {
+                os.Remove(tmpPath)
+                return err
+        }
+        return nil
+}
+
+// sameDevice reports whether two paths are on the same filesystem, so that
+// a file can be renamed from one to the other. The device number lives in
+// the platform-specific info.Sys() (Dev on Unix) and is looked up by name,
+// as in isSparse; where the platform does not tell, they are assumed to be.
+func sameDevice(a, b string) bool {
+        device := func(path string) (uint64, bool) {
+                info, err := os.Stat(path)
+                if err != nil {
+                        return 0, false
+                }
+                sys := reflect.ValueOf(info.Sys())
+                if sys.Kind() == reflect.Ptr {
+                        sys = sys.Elem()
+                }
+                if sys.Kind() != reflect.Struct {
+                        return 0, false
+                }
+                dev := sys.FieldByName("Dev")
+                switch {
+                case dev.IsValid() && dev.CanUint():
+                        return dev.Uint(), true
+                case dev.IsValid() && dev.CanInt():
+                        return uint64(dev.Int()), true
+                }
+                return 0, false
+        }
+        devA, okA := device(a)
+        devB, okB := device(b)
+        return !okA || !okB || devA == devB
+}
+
+// stageNextTo copies a temporary file from another filesystem to a new
+// temporary file next to path and removes it, so that path can still be
+// replaced by an atomic rename. It returns the path of the copy.
+func stageNextTo(path, tmpPath string) (string, error) {
+        defer os.Remove(tmpPath)
+        src, err := os.Open(tmpPath)
+        if err != nil {
+                return "", err
+        }
+        defer src.Close()
+
+        dst, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+        if err != nil {
+                return "", err
+        }
+        if _, err := io.Copy(dst, src); err != nil {
+                dst.Close()
+                os.Remove(dst.Name())
+                return "", err
+        }
+        if err := dst.Sync(); err != nil {
+                dst.Close()
+                os.Remove(dst.Name())
+                return "", err
+        }
+        if err := dst.Close(); err != nil {
+                os.Remove(dst.Name())
+                return "", err
+        }
+        return dst.Name(), nil
+}
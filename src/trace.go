@@ -0,0 +1,150 @@
+package main
+
+import (
+        "fmt"
+        "io"
+        "strconv"
+        "strings"
+        "unicode"
+)
+
+// maxTraceLines limits the region -trace covers, since it writes a line
+// for every character
+const maxTraceLines = 1000
+
+// parseTraceLines parses the value of -trace: a line number or a range of
+// lines such as "12-15"
+func parseTraceLines(value string) (int, int, error) {
+        if value == "" {
+                return 0, 0, nil
+        }
+        from, to, isRange := strings.Cut(strings.TrimSpace(value), "-")
+        first, err := strconv.Atoi(strings.TrimSpace(from))
+        last := first
+        if err == nil && isRange {
+                last, err = strconv.Atoi(strings.TrimSpace(to))
+        }
+        if err != nil || first < 1 || last < first {
+                return 0, 0, fmt.Errorf("invalid -trace lines '%s' (expected a line number or a range such as 12-15)", value)
+        }
+        if last-first >= maxTraceLines {
+                return 0, 0, fmt.Errorf("-trace covers at most %d lines", maxTraceLines)
+        }
+        return first, last, nil
+}
+
+// lineTracer writes what cleanReader does with the lines selected by
+// -trace, and with every character on them, to options.TraceOutput
+type lineTracer struct {
+        out  io.Writer
+        path string
+        line int
+        on   bool
+}
+
+// newLineTracer returns a tracer for the lines TraceFrom to TraceTo, or nil
+// if -trace is not set
+func newLineTracer(path string, options CleaningOptions) *lineTracer {
+        if options.TraceFrom == 0 || options.TraceOutput == nil {
+                return nil
+        }
+        if path == "-" {
+                path = "(stdin)"
+        }
+        return &lineTracer{out: options.TraceOutput, path: path}
+}
+
+// startLine selects the line that following events belong to and reports
+// whether it is traced
+func (t *lineTracer) startLine(line int, options CleaningOptions) bool {
+        if t == nil {
+                return false
+        }
+        t.line = line
+        t.on = line >= options.TraceFrom && line <= options.TraceTo
+        return t.on
+}
+
+// note records an event that concerns the current line as a whole
+func (t *lineTracer) note(format string, args ...interface{}) {
+        if t == nil || !t.on {
+                return
+        }
+        fmt.Fprintf(t.out, "trace: %s:%d: %s\n", t.path, t.line, fmt.Sprintf(format, args...))
+}
+
+// char records what was done with the character at column (counted from 1)
+// of the current line
+func (t *lineTracer) char(column int, char rune, action string) {
+        fmt.Fprintf(t.out, "trace: %s:%d:%d: U+%04X %s: %s\n", t.path, t.line, column, char, describeChar(char), action)
+}
+
+// charsAt returns an options.Trace function for text that starts after
+// offset characters of the current line, or nil if the line is not traced.
+// Columns after markerEnd move by markerShift, as for the locations of a
+// line whose reply marker -chat rewrote.
+func (t *lineTracer) charsAt(offset, markerEnd, markerShift int) func(int, rune, string) {
+        if t == nil || !t.on {
+                return nil
+        }
+        return func(index int, char rune, action string) {
+                column := index + 1
+                if column > markerEnd {
+                        column += markerShift
+                }
+                t.char(offset+column, char, action)
+        }
+}
+
+// trace passes what cleanString did with the character at index to
+// options.Trace, if set
+func (options CleaningOptions) trace(index int, char rune, action string) {
+        if options.Trace != nil {
+                options.Trace(index, char, action)
+        }
+}
+
+// traceRemoved traces a removed character, naming the option that removed
+// it and the replacement written instead, if any
+func (options CleaningOptions) traceRemoved(index int, char rune, option string) {
+        if options.Trace == nil {
+                return
+        }
+        action := "removed"
+        if options.Replacement != "" {
+                action = fmt.Sprintf("replaced with %q", options.Replacement)
+        }
+        options.Trace(index, char, action+" ("+option+")")
+}
+
+// keptReason explains why cleanString kept a character, naming the option
+// responsible when another setting would have removed it
+func keptReason(char rune, allowed, letter bool, options CleaningOptions) string {
+        zeroWidth := isZeroWidth(char)
+        control := unicode.IsControl(char) && char != '\n' && char != '\r' && char != '\t'
+        switch {
+        case allowed && (options.RemoveZeroWidth && zeroWidth || options.RemoveNonASCII && char > 127 ||
+                options.RemoveControlChars && control):
+                return "kept (-allow-ranges)"
+        case options.RemoveNonASCII && char > 127 && options.KeepLetters && letter:
+                return "kept (-keep-letters)"
+        case zeroWidth && !options.RemoveZeroWidth:
+                return "kept (-zerowidth=false)"
+        case control && !options.RemoveControlChars:
+                return "kept (-control=false)"
+        case char > 127 && !options.RemoveNonASCII:
+                return "kept (-ascii=false)"
+        }
+        return "kept"
+}
+
+// keptPrefixOption names the option that keeps part of a line as it is
+func keptPrefixOption(options CleaningOptions) string {
+        switch {
+        case options.PO:
+                return "-po"
+        case options.Chat:
+                return "-chat"
+        }
+        return "-key-value"
+}
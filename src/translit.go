@@ -0,0 +1,75 @@
+package main
+
+import (
+        "strings"
+        "unicode"
+)
+
+// latinLetters spells the Latin letters that have no decomposition, so
+// that the Unicode tables cannot reduce them to ASCII, the way they are
+// usually transliterated
+var latinLetters = map[rune]string{
+        'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+        'ø': "o", 'Ø': "O", 'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D",
+        'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "Th", 'ı': "i", 'ħ': "h",
+        'Ħ': "H", 'ŧ': "t", 'Ŧ': "T", 'ŀ': "l", 'Ŀ': "L", 'ĸ': "k",
+}
+
+// transliterate replaces letters and symbols that reduce to ASCII with
+// that ASCII, so that -ascii keeps "cafe" rather than "caf": accented
+// letters lose their accents (é to e), compatibility characters take
+// their plain form (ﬁ to fi, ² to 2, Ａ to A) and the letters of
+// latinLetters are spelled out (ß to ss). Characters of other scripts are
+// left alone. It returns the number of characters replaced.
+func transliterate(s string) (string, int) {
+        if isASCII(s) {
+                return s, 0
+        }
+        var b strings.Builder
+        b.Grow(len(s))
+        count := 0
+        for _, r := range s {
+                if ascii, ok := transliteration(r); ok {
+                        b.WriteString(ascii)
+                        count++
+                        continue
+                }
+                b.WriteRune(r)
+        }
+        return b.String(), count
+}
+
+// transliteration returns the ASCII a character reduces to, if any
+func transliteration(r rune) (string, bool) {
+        if r < 0x80 {
+                return "", false
+        }
+        if ascii, ok := latinLetters[r]; ok {
+                return ascii, true
+        }
+        decomposition, ok := compatDecompositions[r]
+        if !ok {
+                decomposition, ok = canonicalDecompositions[r]
+        }
+        if !ok {
+                return "", false
+        }
+        var b strings.Builder
+        accents := 0
+        for _, d := range decomposition {
+                switch {
+                case unicode.Is(unicode.Mn, d):
+                        // The accent of a decomposed letter
+                        accents++
+                case d < 0x80:
+                        b.WriteRune(d)
+                default:
+                        return "", false
+                }
+        }
+        if accents > 0 && strings.TrimSpace(b.String()) == "" {
+                // A spacing accent such as ¨, which would become a space
+                return "", false
+        }
+        return b.String(), true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+        "fmt"
+        "strings"
+)
+
+// TSVRow is a record of a -tsv file that does not have as many fields as
+// the first record
+type TSVRow struct {
+        Line     int `json:"line"`
+        Fields   int `json:"fields"`
+        Expected int `json:"expected"`
+}
+
+// tsvLineBreaks are the line breaks other than CR and LF, which end a TSV
+// record: loaders that split lines the Unicode way, such as Python's
+// splitlines, would split a record at any of them
+const tsvLineBreaks = "\v\f\u0085\u2028\u2029"
+
+// prepareTSV replaces the line breaks inside the fields of tab-separated
+// content with escape, so every record stays on one line, and checks that
+// all records have as many fields as the first. As in the IANA definition
+// of TSV, records are split at line endings and fields at tabs only, with
+// no quoting, so a quote in a field is just a character. Tabs are never
+// added or removed, and empty fields and \N markers are left as they are.
+func prepareTSV(content, escape string, stats *CleaningStats, options CleaningOptions) (string, error) {
+        type record struct {
+                line, fields int
+        }
+        var records []record
+
+        var b strings.Builder
+        b.Grow(len(content))
+        for line, rest := 1, content; rest != ""; line++ {
+                end := strings.IndexAny(rest, "\r\n")
+                text, ending := rest, ""
+                switch {
+                case end < 0:
+                        rest = ""
+                case strings.HasPrefix(rest[end:], "\r\n"):
+                        text, ending, rest = rest[:end], "\r\n", rest[end+2:]
+                default:
+                        text, ending, rest = rest[:end], rest[end:end+1], rest[end+1:]
+                }
+                records = append(records, record{line, strings.Count(text, "\t") + 1})
+                if strings.ContainsAny(text, tsvLineBreaks) {
+                        for _, r := range text {
+                                if strings.ContainsRune(tsvLineBreaks, r) {
+                                        stats.NewlinesEscaped++
+                                        b.WriteString(escape)
+                                } else {
+                                        b.WriteRune(r)
+                                }
+                        }
+                } else {
+                        b.WriteString(text)
+                }
+                b.WriteString(ending)
+        }
+        content = b.String()
+
+        if len(records) == 0 {
+                return content, nil
+        }
+
+        expected := records[0].fields
+        malformed := 0
+        for _, r := range records[1:] {
+                if r.fields == expected {
+                        continue
+                }
+                if options.Strict {
+                        return "", fmt.Errorf("strict: line %d has %d field(s), expected %d", r.line, r.fields, expected)
+                }
+                malformed++
+                if len(stats.MalformedRows) < maxLocations {
+                        stats.MalformedRows = append(stats.MalformedRows, TSVRow{Line: r.line, Fields: r.fields, Expected: expected})
+                }
+        }
+        if malformed > 0 {
+                stats.warn(warnColumns, 0, "%d row(s) do not have the %d fields of the first row", malformed, expected)
+        }
+        return content, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+        "bytes"
+        "fmt"
+        "io"
+        "os"
+)
+
+// sameContent reports whether the files at a and b hold the same bytes
+func sameContent(a, b string) (bool, error) {
+        fa, err := os.Open(a)
+        if err != nil {
+                return false, err
+        }
+        defer fa.Close()
+        fb, err := os.Open(b)
+        if err != nil {
+                return false, err
+        }
+        defer fb.Close()
+
+        ia, err := fa.Stat()
+        if err != nil {
+                return false, err
+        }
+        ib, err := fb.Stat()
+        if err != nil {
+                return false, err
+        }
+        if ia.Size() != ib.Size() {
+                return false, nil
+        }
+
+        bufA := make([]byte, 64*1024)
+        bufB := make([]byte, 64*1024)
+        for {
+                na, errA := io.ReadFull(fa, bufA)
+                nb, errB := io.ReadFull(fb, bufB)
+                if !bytes.Equal(bufA[:na], bufB[:nb]) {
+                        return false, nil
+                }
+                doneA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+                doneB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+                if errA != nil && !doneA {
+                        return false, errA
+                }
+                if errB != nil && !doneB {
+                        return false, errB
+                }
+                if doneA || doneB {
+                        return doneA && doneB, nil
+                }
+        }
+}
+
+// cleanToNewFile cleans inputPath into the file of sink through a temporary
+// file next to it, which then replaces it. If the cleaned content is the
+// same as the input, nothing is written, so that an unchanged file does not
+// get a new copy (and its build tools a new modification time), and
+// stats.OutputSkipped is set. That is only so while the output file does
+// not exist or already holds the same content: a stale output file from an
+// earlier run is replaced like any other.
+//
+// Only a missing or regular output file is replaced. Anything else, such as
+// /dev/null, a named pipe or a symlink, is written through sink as it is, as
+// is an existing file in a directory that does not take a temporary file.
+func cleanToNewFile(inputPath string, sink *fileSink, options CleaningOptions, verbose bool) (*CleaningStats, error) {
+        info, err := os.Lstat(sink.Path)
+        exists := err == nil
+        if exists && !info.Mode().IsRegular() {
+                return cleanFile(inputPath, sink, options, verbose)
+        }
+        tmpPath, err := createTempFor(sink.Path, "")
+        if err != nil {
+                if exists {
+                        return cleanFile(inputPath, sink, options, verbose)
+                }
+                return nil, fmt.Errorf("could not create temporary file: %w", err)
+        }
+        tmp := *sink
+        tmp.Path = tmpPath
+        stats, err := cleanFile(inputPath, &tmp, options, verbose)
+        if err != nil {
+                os.Remove(tmpPath)
+                return nil, err
+        }
+
+        skip, err := sameContent(inputPath, tmpPath)
+        if err == nil && skip {
+                skip, err = outputUpToDate(sink.Path, tmpPath)
+        }
+        if err != nil {
+                os.Remove(tmpPath)
+                return nil, fmt.Errorf("could not compare output with input: %w", err)
+        }
+        if skip {
+                os.Remove(tmpPath)
+                stats.OutputSkipped = true
+                return stats, nil
+        }
+        if err := os.Rename(tmpPath, sink.Path); err != nil {
+                os.Remove(tmpPath)
+                return nil, fmt.Errorf("could not create output file: %w", err)
+        }
+        return stats, nil
+}
+
+// outputUpToDate reports whether the output file at path can be left as it
+// is for the cleaned content at tmpPath: it does not exist, or it holds the
+// same bytes
+func outputUpToDate(path, tmpPath string) (bool, error) {
+        if _, err := os.Lstat(path); os.IsNotExist(err) {
+                return true, nil
+        }
+        return sameContent(path, tmpPath)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+        "os"
+        "path/filepath"
+        "testing"
+)
+
+// An unchanged file is neither written nor backed up, in place or not; a
+// changed one is both
+func TestUnchangedFileNotWritten(t *testing.T) {
+        options, err := newCleaningOptions(nil)
+        if err != nil {
+                t.Fatal(err)
+        }
+        options.BackupName = "plain"
+
+        for _, inPlace := range []bool{true, false} {
+                dir := t.TempDir()
+                clean := filepath.Join(dir, "clean.txt")
+                dirty := filepath.Join(dir, "dirty.txt")
+                if err := os.WriteFile(clean, []byte("plain text\n"), 0644); err != nil {
+                        t.Fatal(err)
+                }
+                if err := os.WriteFile(dirty, []byte("zero\u200bwidth\n"), 0644); err != nil {
+                        t.Fatal(err)
+                }
+                batch := BatchOptions{InPlace: inPlace, Root: dir, NoProgress: true}
+
+                result, err := processBatchFile(clean, batch, options, true, false)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                if !result.Stats.OutputSkipped {
+                        t.Errorf("in-place %v: unchanged file was written", inPlace)
+                }
+                for _, path := range []string{clean + ".bak", defaultOutputPath(clean)} {
+                        if _, err := os.Lstat(path); !os.IsNotExist(err) {
+                                t.Errorf("in-place %v: %s exists for an unchanged file", inPlace, filepath.Base(path))
+                        }
+                }
+
+                result, err = processBatchFile(dirty, batch, options, true, false)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                if result.Stats.OutputSkipped {
+                        t.Errorf("in-place %v: changed file was not written", inPlace)
+                }
+                backup, err := os.ReadFile(dirty + ".bak")
+                if err != nil {
+                        t.Errorf("in-place %v: no backup of a changed file: %v", inPlace, err)
+                } else if string(backup) != "zero\u200bwidth\n" {
+                        t.Errorf("in-place %v: backup holds %q, want the original", inPlace, backup)
+                }
+        }
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+        "bytes"
+        "fmt"
+        "io"
+        "os"
+        "path/filepath"
+        "strings"
+)
+
+// undoPatchSuffix is appended to the name of a written file for the patch
+// that turns it back into the input it was cleaned from (-undo-patch)
+const undoPatchSuffix = ".undo.patch"
+
+// Unchanged lines shown around each change of an undo patch
+const undoContext = 3
+
+// An undo patch is a unified diff from the cleaned file to the original,
+// so patch(1) can apply it too, after a header that gives the SHA-256 of
+// both. Lines are compared and written byte for byte with their line
+// endings, so that undoing restores the original exactly, whatever its
+// encoding and line endings:
+//
+//      # cleanfile undo patch: undo with cleanfile undo notes.txt.undo.patch
+//      # cleaned-sha256: 9f86d0...
+//      # original-sha256: 60303a...
+//      --- notes.txt
+//      +++ notes.txt
+//      @@ -1,3 +1,3 @@
+//       ...
+
+const (
+        undoCleanedHeader  = "# cleaned-sha256: "
+        undoOriginalHeader = "# original-sha256: "
+        noNewlineMarker    = "\\ No newline at end of file"
+)
+
+// checkPatchMemory returns an error if the files of an undo patch do not
+// fit in maxMemory together, since the patch is computed from both as a
+// whole
+func checkPatchMemory(cleanedPath, originalPath string, maxMemory int64) error {
+        if maxMemory == 0 {
+                return nil
+        }
+        var size int64
+        for _, path := range []string{cleanedPath, originalPath} {
+                if info, err := os.Stat(path); err == nil {
+                        size += info.Size()
+                }
+        }
+        if size*patchCopies > maxMemory {
+                return fmt.Errorf("file is too large for an undo patch within -max-memory (%s for both versions, %s available)",
+                        formatSize(size), formatSize(maxMemory))
+        }
+        return nil
+}
+
+// splitLinesKeepEnds splits content after each \n, keeping the line endings
+func splitLinesKeepEnds(content []byte) []string {
+        lines := strings.SplitAfter(string(content), "\n")
+        if lines[len(lines)-1] == "" {
+                lines = lines[:len(lines)-1]
+        }
+        return lines
+}
+
+// writeUndoPatch writes the undo patch of target, whose content is that of
+// cleanedPath, for turning it back into the content of originalPath. The
+// patch holds the removed text, so it gets the permissions of the original
+// less its execute bits. Both files are read as a whole, which must fit in
+// maxMemory unless it is 0.
+func writeUndoPatch(target, cleanedPath, originalPath string, maxMemory int64) error {
+        if err := checkPatchMemory(cleanedPath, originalPath, maxMemory); err != nil {
+                return err
+        }
+        cleaned, err := os.ReadFile(cleanedPath)
+        if err != nil {
+                return err
+        }
+        original, err := os.ReadFile(originalPath)
+        if err != nil {
+                return err
+        }
+
+        // A patch is never executable, even for a script
+        perm := inputPerm(originalPath)
+        if perm != nil {
+                *perm &^= 0111
+        }
+        f, err := createFile(target+undoPatchSuffix, 0, perm)
+        if err != nil {
+                return err
+        }
+        name := filepath.Base(target)
+        fmt.Fprintf(f, "# cleanfile undo patch: undo with cleanfile undo %s%s\n", name, undoPatchSuffix)
+        fmt.Fprintf(f, "%s%s\n%s%s\n", undoCleanedHeader, sha256Hex(cleaned), undoOriginalHeader, sha256Hex(original))
+        fmt.Fprintf(f, "--- %s\n+++ %s\n", name, name)
+        a, b := splitLinesKeepEnds(cleaned), splitLinesKeepEnds(original)
+        writeHunks(f, diffLines(a, b), a, b)
+        return f.Close()
+}
+
+// writeHunks writes the changes of an edit script from a to b as the hunks
+// of a unified diff, each with up to undoContext unchanged lines around it
+func writeHunks(w io.Writer, ops []diffOp, a, b []string) {
+        var changes []int
+        for k, op := range ops {
+                if op.Kind != ' ' {
+                        changes = append(changes, k)
+                }
+        }
+
+        writeLine := func(prefix byte, line string) {
+                fmt.Fprintf(w, "%c%s", prefix, line)
+                if !strings.HasSuffix(line, "\n") {
+                        fmt.Fprintf(w, "\n%s\n", noNewlineMarker)
+                }
+        }
+        for i := 0; i < len(changes); {
+                // Changes separated by no more than twice the context share
+                // a hunk
+                j := i
+                for j+1 < len(changes) && changes[j+1]-changes[j]-1 <= 2*undoContext {
+                        j++
+                }
+                hunk := ops[max(changes[i]-undoContext, 0):min(changes[j]+undoContext+1, len(ops))]
+                i = j + 1
+
+                countA, countB := 0, 0
+                for _, op := range hunk {
+                        if op.Kind != '+' {
+                                countA++
+                        }
+                        if op.Kind != '-' {
+                                countB++
+                        }
+                }
+                fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(hunk[0].A, countA), hunkRange(hunk[0].B, countB))
+                for _, op := range hunk {
+                        switch op.Kind {
+                        case ' ':
+                                writeLine(' ', a[op.A])
+                        case '-':
+                                writeLine('-', a[op.A])
+                        default:
+                                writeLine('+', b[op.B])
+                        }
+                }
+        }
+}
+
+// hunkRange formats the start and length of a hunk; an empty range starts
+// at the line before it
+func hunkRange(index, count int) string {
+        if count == 0 {
+                return fmt.Sprintf("%d,0", index)
+        }
+        return fmt.Sprintf("%d,%d", index+1, count)
+}
+
+// undoHunk is a hunk of an undo patch: where it starts in the cleaned file
+// and its lines, each with its ' ', '-' or '+'
+type undoHunk struct {
+        Start, Count int
+        Lines        []string
+}
+
+// undoPatch is a parsed undo patch
+type undoPatch struct {
+        Target         string // the file the patch applies to
+        CleanedSHA256  string
+        OriginalSHA256 string
+        Hunks          []undoHunk
+}
+
+// readUndoPatch parses the undo patch at path. Its target is the file named
+// in the patch, next to the patch.
+func readUndoPatch(path string) (*undoPatch, error) {
+        data, err := os.ReadFile(path)
+        if err != nil {
+                return nil, err
+        }
+        lines := splitLinesKeepEnds(data)
+        patch := &undoPatch{}
+        invalid := func(n int, what string) error {
+                return fmt.Errorf("%s:%d: not a cleanfile undo patch (%s)", path, n+1, what)
+        }
+
+        n := 0
+        for ; n < len(lines) && !strings.HasPrefix(lines[n], "--- "); n++ {
+                line := strings.TrimRight(lines[n], "\r\n")
+                if value, ok := strings.CutPrefix(line, undoCleanedHeader); ok {
+                        patch.CleanedSHA256 = value
+                }
+                if value, ok := strings.CutPrefix(line, undoOriginalHeader); ok {
+                        patch.OriginalSHA256 = value
+                }
+        }
+        if patch.CleanedSHA256 == "" || patch.OriginalSHA256 == "" {
+                return nil, invalid(0, "no checksums")
+        }
+        if n+1 >= len(lines) || !strings.HasPrefix(lines[n+1], "+++ ") {
+                return nil, invalid(n, "no file names")
+        }
+        name := strings.TrimSuffix(strings.TrimPrefix(lines[n], "--- "), "\n")
+        if name == "" || name != filepath.Base(name) {
+                return nil, invalid(n, "the file must be named without a directory")
+        }
+        patch.Target = filepath.Join(filepath.Dir(path), name)
+
+        for n += 2; n < len(lines); {
+                var hunk undoHunk
+                var countB int
+                header := strings.TrimSuffix(lines[n], "\n")
+                if _, err := fmt.Sscanf(header, "@@ -%d,%d +%d,%d @@", &hunk.Start, &hunk.Count, new(int), &countB); err != nil {
+                        return nil, invalid(n, "bad hunk header")
+                }
+                n++
+                for remainingA, remainingB := hunk.Count, countB; remainingA > 0 || remainingB > 0; n++ {
+                        if n >= len(lines) || lines[n] == "" || !strings.ContainsRune(" -+", rune(lines[n][0])) {
+                                return nil, invalid(n, "hunk ends early")
+                        }
+                        line := lines[n]
+                        if n+1 < len(lines) && strings.HasPrefix(lines[n+1], noNewlineMarker) {
+                                line = strings.TrimSuffix(line, "\n")
+                                n++
+                        }
+                        if line[0] != '+' {
+                                remainingA--
+                        }
+                        if line[0] != '-' {
+                                remainingB--
+                        }
+                        hunk.Lines = append(hunk.Lines, line)
+                }
+                patch.Hunks = append(patch.Hunks, hunk)
+        }
+        return patch, nil
+}
+
+// apply returns the original content of a cleaned file. Every line of the
+// cleaned file that the patch names must be as the patch gives it.
+func (p *undoPatch) apply(cleaned []byte) ([]byte, error) {
+        lines := splitLinesKeepEnds(cleaned)
+        var out bytes.Buffer
+        pos := 0
+        for i, hunk := range p.Hunks {
+                begin := hunk.Start - 1
+                if hunk.Count == 0 {
+                        begin = hunk.Start
+                }
+                if begin < pos || begin > len(lines) {
+                        return nil, fmt.Errorf("hunk %d is out of place", i+1)
+                }
+                for _, line := range lines[pos:begin] {
+                        out.WriteString(line)
+                }
+                pos = begin
+                for _, line := range hunk.Lines {
+                        text := line[1:]
+                        if line[0] == '+' {
+                                out.WriteString(text)
+                                continue
+                        }
+                        if pos >= len(lines) || lines[pos] != text {
+                                return nil, fmt.Errorf("hunk %d does not match line %d", i+1, pos+1)
+                        }
+                        if line[0] == ' ' {
+                                out.WriteString(text)
+                        }
+                        pos++
+                }
+        }
+        for _, line := range lines[pos:] {
+                out.WriteString(line)
+        }
+        return out.Bytes(), nil
+}
+
+// undoFile restores the target of the undo patch at path and, unless keep
+// is set, removes the patch. The target must still be as cleaned, unless
+// force is set, in which case the hunks must still match.
+func undoFile(path string, force, keep bool) (string, error) {
+        patch, err := readUndoPatch(path)
+        if err != nil {
+                return "", err
+        }
+        cleaned, err := os.ReadFile(patch.Target)
+        if err != nil {
+                return "", err
+        }
+        if sha256Hex(cleaned) != patch.CleanedSHA256 && !force {
+                return "", fmt.Errorf("%s has changed since it was cleaned (use -force to undo the cleaning anyway)", patch.Target)
+        }
+        original, err := patch.apply(cleaned)
+        if err != nil {
+                return "", fmt.Errorf("%s: %w", path, err)
+        }
+        if sha256Hex(original) != patch.OriginalSHA256 && !force {
+                return "", fmt.Errorf("%s: the restored file does not match the checksum of the original", path)
+        }
+
+        // Replaced as by -in-place, keeping the permissions of the target
+        info, err := os.Stat(patch.Target)
+        if err != nil {
+                return "", err
+        }
+        tmpPath, err := createTempFor(patch.Target, "")
+        if err != nil {
+                return "", fmt.Errorf("could not create temporary file: %w", err)
+        }
+        err = os.WriteFile(tmpPath, original, 0600)
+        if err == nil {
+                err = os.Chmod(tmpPath, info.Mode().Perm())
+        }
+        if err == nil {
+                err = os.Rename(tmpPath, patch.Target)
+        }
+        if err != nil {
+                os.Remove(tmpPath)
+                return "", fmt.Errorf("could not restore %s: %w", patch.Target, err)
+        }
+
+        if !keep {
+                if err := os.Remove(path); err != nil {
+                        fmt.Printf("%s %v\n", colorize("Warning:", ansiYellow), err)
+                }
+        }
+        return patch.Target, nil
+}
+
+// findUndoPatches lists the undo patches below dir, skipping hidden
+// directories as collectFiles does
+func findUndoPatches(dir string) ([]string, error) {
+        var patches []string
+        err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+                if err != nil {
+                        return err
+                }
+                if info.IsDir() {
+                        if path != dir && strings.HasPrefix(info.Name(), ".") {
+                                return filepath.SkipDir
+                        }
+                        return nil
+                }
+                if info.Mode().IsRegular() && strings.HasSuffix(path, undoPatchSuffix) {
+                        patches = append(patches, path)
+                }
+                return nil
+        })
+        return patches, err
+}
+
+// runUndo implements the undo subcommand
+func runUndo(args []string) int {
+        fs := newSubcommandFlags("undo")
+        recursive := fs.Bool("recursive", false, "Apply every undo patch in the given directory trees")
+        force := fs.Bool("force", false, "Undo even if the file has changed since it was cleaned, as long as the changed lines still match")
+        keep := fs.Bool("keep", false, "Keep the undo patches instead of removing them once applied")
+        paths := parseInterspersed(fs, args)
+        if len(paths) == 0 {
+                exitWithUsage(fs, "at least one undo patch is required")
+        }
+
+        var patches []string
+        for _, path := range paths {
+                info, err := os.Stat(path)
+                if err != nil {
+                        fmt.Printf("Error: Could not access '%s': %v\n", path, err)
+                        return 1
+                }
+                if !info.IsDir() {
+                        patches = append(patches, path)
+                        continue
+                }
+                if !*recursive {
+                        fmt.Printf("Error: '%s' is a directory (use -recursive to undo a directory tree)\n", path)
+                        return 1
+                }
+                found, err := findUndoPatches(path)
+                if err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        return 1
+                }
+                patches = append(patches, found...)
+        }
+
+        failed := 0
+        for _, path := range patches {
+                target, err := undoFile(path, *force, *keep)
+                if err != nil {
+                        fmt.Printf("Error: %v\n", err)
+                        failed++
+                        continue
+                }
+                fmt.Printf("Restored %s from %s\n", target, path)
+        }
+        if failed > 0 {
+                fmt.Printf("%d of %d undo patch(es) could not be applied\n", failed, len(patches))
+                return 1
+        }
+        return 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+        "fmt"
+        "strings"
+)
+
+// verifyOptions returns the options that -verify cleans an output with:
+// those of the run, without the steps that rewrite a format rather than
+// remove characters (-strip, -provenance, -fix-mojibake), reading it as the
+// UTF-8 it was written as, and keeping the -replacement character
+func verifyOptions(options CleaningOptions) CleaningOptions {
+        options.StripFormat = ""
+        options.Provenance = ""
+        options.FixMojibake = false
+        options.InputEncoding = "utf-8"
+        options.Strict = false
+        options.RecordLocations = true
+        options.RecordWords = false
+        options.Quarantine = false
+        options.TraceFrom = 0
+        options.Progress = nil
+        options.UndoPatch = false
+        options.Verify = false
+        if options.Replacement != "" {
+                r := []rune(options.Replacement)[0]
+                options.AllowRanges = append(append([]charRange(nil), options.AllowRanges...), charRange{r, r})
+        }
+        return options
+}
+
+// verifyOutput cleans the written output at path once more and returns an
+// error if that would still remove characters, convert line endings, trim
+// trailing whitespace or replace invalid UTF-8, the issues the run was to
+// leave none of (-verify)
+func verifyOutput(path string, options CleaningOptions) error {
+        stats, err := cleanFile(path, &discardSink{}, verifyOptions(options), false)
+        if err != nil {
+                return fmt.Errorf("could not verify the output: %w", err)
+        }
+
+        var issues []string
+        if stats.RemovedChars > 0 {
+                first := stats.Locations[0]
+                issues = append(issues, fmt.Sprintf("%d character(s) to remove, the first U+%04X (%s) at line %d, column %d",
+                        stats.RemovedChars, first.Char, describeChar(first.Char), first.Line, first.Column))
+        }
+        if stats.LineEndingsConverted > 0 {
+                issues = append(issues, fmt.Sprintf("%d line ending(s) to convert", stats.LineEndingsConverted))
+        }
+        if stats.TrailingWhitespaceTrimmed > 0 {
+                issues = append(issues, fmt.Sprintf("%d trailing blank(s) to trim", stats.TrailingWhitespaceTrimmed))
+        }
+        if stats.InvalidUTF8Sequences > 0 && stats.InvalidUTF8Action != "kept" {
+                issues = append(issues, fmt.Sprintf("%d invalid UTF-8 sequence(s)", stats.InvalidUTF8Sequences))
+        }
+        if len(issues) > 0 {
+                return fmt.Errorf("verification failed, the output still has %s", strings.Join(issues, "; "))
+        }
+        return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+        "fmt"
+        "unicode"
+)
+
+// Warning records a non-fatal anomaly: something the tool made a judgment
+// call about instead of failing. Repeated occurrences of the same warning
+// are folded into one entry that remembers the first line.
+type Warning struct {
+        Kind    string `json:"kind"`
+        Message string `json:"message"`
+        Line    int    `json:"line,omitempty"`
+        Count   int    `json:"count"`
+}
+
+// Warning kinds
+const (
+        warnLongLine        = "long-line"
+        warnKeptInvisible   = "kept-invisible"
+        warnInvalidUTF8     = "invalid-utf8"
+        warnUnknownEntity   = "unknown-entity"
+        warnRetried         = "retried"
+        warnLostMetadata    = "lost-metadata"
+        warnSparse          = "sparse"
+        warnEncoding        = "encoding"
+        warnColumns         = "columns"
+        warnProvenance      = "provenance"
+        warnUnbalancedQuote = "unbalanced-quote"
+        warnMojibake        = "mojibake"
+        warnMixedScript     = "mixed-script"
+        warnConfusable      = "confusable"
+)
+
+// warn adds an occurrence of a warning to the statistics
+func (s *CleaningStats) warn(kind string, line int, format string, args ...interface{}) {
+        message := fmt.Sprintf(format, args...)
+        for i := range s.Warnings {
+                w := &s.Warnings[i]
+                if w.Kind == kind && w.Message == message {
+                        w.Count++
+                        return
+                }
+        }
+        s.Warnings = append(s.Warnings, Warning{Kind: kind, Message: message, Line: line, Count: 1})
+}
+
+// formatWarning renders a warning as a single report line
+func formatWarning(w Warning) string {
+        text := w.Message
+        if w.Line > 0 {
+                text = fmt.Sprintf("line %d: %s", w.Line, text)
+        }
+        if w.Count > 1 {
+                text += fmt.Sprintf(" (%d occurrences)", w.Count)
+        }
+        return text
+}
+
+// isSuspicious reports whether a character that survived cleaning is
+// invisible or otherwise likely to be unintended
+func isSuspicious(r rune) bool {
+        if r == '\n' || r == '\r' || r == '\t' {
+                return false
+        }
+        return isZeroWidth(r) || unicode.IsControl(r) || unicode.Is(unicode.Cf, r)
+}
+
+// warnLine records the anomalies of a single cleaned line, or of a chunk of
+// a long one. width is the display width of the line (see DisplayWidth),
+// excluding its terminator; it is passed with the last chunk only, and 0
+// otherwise.
+func warnLine(stats *CleaningStats, lineNum, width int, cleaned string, options CleaningOptions) {
+        if options.WarnLineLength > 0 {
+                if width > options.WarnLineLength {
+                        stats.warn(warnLongLine, lineNum, "line wider than %d columns", options.WarnLineLength)
+                }
+        }
+
+        if isPlainASCII(cleaned) {
+                return
+        }
+        for _, r := range cleaned {
+                if isSuspicious(r) && !options.allowed(r) {
+                        stats.warn(warnKeptInvisible, lineNum, "kept invisible character U+%04X (%s)", r, describeChar(r))
+                }
+        }
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+        "strings"
+        "unicode"
+)
+
+// eastAsianWide holds the characters of East Asian Width W (wide) and F
+// (fullwidth) other than emoji, which a terminal or editor with a
+// monospaced font displays in two columns: Hangul, kana, CJK ideographs and
+// punctuation, Yi, Tangut and the fullwidth forms. Ambiguous characters
+// (A), which are wide only in East Asian legacy contexts, count as narrow.
+var eastAsianWide = &unicode.RangeTable{
+        R16: []unicode.Range16{
+                {0x1100, 0x115F, 1}, // Hangul Jamo initial consonants
+                {0x2329, 0x232A, 1}, // angle brackets
+                {0x2E80, 0x303E, 1}, // CJK radicals, Kangxi radicals, CJK symbols and punctuation
+                {0x3041, 0x3247, 1}, // kana, Bopomofo, Hangul compatibility Jamo, Kanbun, enclosed CJK
+                {0x3250, 0x4DBF, 1}, // enclosed CJK, CJK compatibility, CJK extension A
+                {0x4E00, 0xA4CF, 1}, // CJK unified ideographs, Yi
+                {0xA960, 0xA97F, 1}, // Hangul Jamo extended-A
+                {0xAC00, 0xD7A3, 1}, // Hangul syllables
+                {0xF900, 0xFAFF, 1}, // CJK compatibility ideographs
+                {0xFE10, 0xFE19, 1}, // vertical forms
+                {0xFE30, 0xFE6F, 1}, // CJK compatibility forms, small form variants
+                {0xFF00, 0xFF60, 1}, // fullwidth ASCII
+                {0xFFE0, 0xFFE6, 1}, // fullwidth signs
+        },
+        R32: []unicode.Range32{
+                {0x16FE0, 0x16FE4, 1}, // ideographic symbols
+                {0x17000, 0x18CFF, 1}, // Tangut, Khitan
+                {0x1B000, 0x1B2FF, 1}, // kana supplement and extensions, Nushu
+                {0x1F200, 0x1F2FF, 1}, // enclosed ideographic supplement
+                {0x20000, 0x2FFFD, 1}, // CJK extensions B-F, compatibility supplement
+                {0x30000, 0x3FFFD, 1}, // CJK extensions G-H
+        },
+}
+
+// runeWidth returns the number of columns a character takes up in a
+// monospaced font: 0 for combining marks, format and control characters
+// (including zero-width ones), 2 for wide East Asian characters and 1 for
+// everything else. A tab counts as one column, wherever its tab stop is.
+func runeWidth(r rune) int {
+        switch {
+        case r == '\t':
+                return 1
+        case r < 0x20 || r >= 0x7F && r < 0xA0:
+                return 0
+        case r < 0x300:
+                // Latin-1 and the rest of Latin, none of them wide
+                return 1
+        case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || r >= 0x1160 && r <= 0x11FF:
+                // Hangul medial vowels and final consonants join the
+                // initial consonant before them
+                return 0
+        case unicode.Is(eastAsianWide, r) || unicode.Is(emojiPresentation, r):
+                return 2
+        }
+        return 1
+}
+
+// DisplayWidth returns the number of columns text takes up in a monospaced
+// font, so that CJK text is measured the way it is displayed. An emoji
+// takes two columns however many characters it is made of. It is exported
+// with the engine, for code that lays out text the way -warn-line-length
+// measures it (the js/wasm build has it as cleanfile.displayWidth).
+func DisplayWidth(s string) int {
+        if isPlainASCII(s) {
+                return len(s) - strings.Count(s, "\n") - strings.Count(s, "\r")
+        }
+        runes := []rune(s)
+        width := 0
+        for i := 0; i < len(runes); i++ {
+                if n := emojiLength(runes, i); n > 0 {
+                        width += 2
+                        i += n - 1
+                        continue
+                }
+                width += runeWidth(runes[i])
+        }
+        return width
+}
+
+// truncateWidth shortens text to at most width columns, ending it with
+// tail (such as "...") if anything was cut. A wide character or an emoji
+// that would only half fit is left out rather than split, and combining
+// marks stay with the character before them.
+func truncateWidth(s string, width int, tail string) string {
+        if DisplayWidth(s) <= width {
+                return s
+        }
+        width -= DisplayWidth(tail)
+        runes := []rune(s)
+        used, end := 0, 0
+        for end < len(runes) {
+                n, w := 1, runeWidth(runes[end])
+                if e := emojiLength(runes, end); e > 0 {
+                        n, w = e, 2
+                }
+                if used+w > width {
+                        break
+                }
+                used += w
+                end += n
+        }
+        return string(runes[:end]) + tail
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+        "fmt"
+        "strings"
+        "unicode"
+)
+
+// AffectedWord is a word that a removed character sat inside, such as a
+// zero width space in "ca\u200Bfe". -details lists them, so that a
+// reviewer can check that no meaningful content was altered.
+type AffectedWord struct {
+        Line   int
+        Column int    // of the first removed character
+        Before string // the word with its removed characters shown as <U+200B>
+        After  string // the word as written
+}
+
+// isWordChar reports whether a character belongs to a word
+func isWordChar(r rune) bool {
+        return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// affectedWords finds the words that the removed characters of a text sat
+// inside: those with a kept letter or digit on both sides before the next
+// space or punctuation. removed holds the indexes of the removed
+// characters in ascending order; replacement is what was written in their
+// place.
+func affectedWords(runes []rune, removed []int, replacement string) []AffectedWord {
+        isRemoved := make(map[int]bool, len(removed))
+        for _, i := range removed {
+                isRemoved[i] = true
+        }
+        inWord := func(i int) bool { return isRemoved[i] || isWordChar(runes[i]) }
+        keptWordChar := func(i int) bool { return !isRemoved[i] && isWordChar(runes[i]) }
+
+        var words []AffectedWord
+        wordEnd := 0
+        for _, i := range removed {
+                if i < wordEnd {
+                        // Already listed with an earlier removed character
+                        continue
+                }
+                start, end := i, i+1
+                for start > 0 && inWord(start-1) {
+                        start--
+                }
+                for end < len(runes) && inWord(end) {
+                        end++
+                }
+                before, after := false, false
+                for j := start; j < end; j++ {
+                        before = before || j < i && keptWordChar(j)
+                        after = after || j > i && keptWordChar(j)
+                }
+                if !before || !after {
+                        continue
+                }
+
+                var was, is strings.Builder
+                for j := start; j < end; j++ {
+                        if isRemoved[j] {
+                                fmt.Fprintf(&was, "<U+%04X>", runes[j])
+                                is.WriteString(replacement)
+                                continue
+                        }
+                        was.WriteRune(runes[j])
+                        is.WriteRune(runes[j])
+                }
+                words = append(words, AffectedWord{Column: i + 1, Before: was.String(), After: is.String()})
+                wordEnd = end
+        }
+        return words
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+        "errors"
+)
+
+// extendedAttr is an extended attribute, or on Windows an alternate data
+// stream, of a file
+type extendedAttr struct {
+        Name  string
+        Value []byte
+}
+
+// errMetadataUnsupported means there is no way to read extended attributes
+// on this system
+var errMetadataUnsupported = errors.New("extended attributes are not supported on this system")
+
+// preserveMetadata copies the extended attributes of src (xattrs such as
+// quarantine flags and labels, macOS resource forks, Windows alternate data
+// streams) to dst, which replaces src after an in-place clean. Attributes
+// that cannot be copied are recorded as warnings, since they will be lost.
+//
+// Linux and macOS use the xattr system calls (xattr_unix.go), Windows lists
+// streams with "dir /r" (xattr_windows.go). Elsewhere attributes cannot be
+// read at all, which is also a warning, as any the file had are lost.
+func preserveMetadata(src, dst string, stats *CleaningStats) {
+        attrs, err := readExtendedAttrs(src)
+        if errors.Is(err, errMetadataUnsupported) {
+                stats.warn(warnLostMetadata, 0, "extended attributes cannot be copied on this system, any the file had are lost")
+                return
+        }
+        if err != nil {
+                stats.warn(warnLostMetadata, 0, "could not read extended attributes, they may be lost: %v", err)
+                return
+        }
+
+        for _, attr := range attrs {
+                if err := writeExtendedAttr(dst, attr); err != nil {
+                        stats.warn(warnLostMetadata, 0, "extended attribute %s was lost: %v", attr.Name, err)
+                }
+        }
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+        "syscall"
+        "unsafe"
+)
+
+// The syscall package has no xattr wrappers for macOS, so the calls are
+// made directly. Unlike on Linux they take a position, used only for the
+// resource fork, and options; position 0 reads or writes a whole fork.
+
+func listxattr(path string, buf []byte) (int, error) {
+        p, err := syscall.BytePtrFromString(path)
+        if err != nil {
+                return 0, err
+        }
+        n, _, errno := syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)),
+                bufferPointer(buf), uintptr(len(buf)), 0, 0, 0)
+        if errno != 0 {
+                return 0, errno
+        }
+        return int(n), nil
+}
+
+func getxattr(path, name string, buf []byte) (int, error) {
+        p, err := syscall.BytePtrFromString(path)
+        if err != nil {
+                return 0, err
+        }
+        a, err := syscall.BytePtrFromString(name)
+        if err != nil {
+                return 0, err
+        }
+        n, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)),
+                bufferPointer(buf), uintptr(len(buf)), 0, 0)
+        if errno != 0 {
+                return 0, errno
+        }
+        return int(n), nil
+}
+
+func setxattr(path, name string, value []byte) error {
+        p, err := syscall.BytePtrFromString(path)
+        if err != nil {
+                return err
+        }
+        a, err := syscall.BytePtrFromString(name)
+        if err != nil {
+                return err
+        }
+        _, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)),
+                bufferPointer(value), uintptr(len(value)), 0, 0)
+        if errno != 0 {
+                return errno
+        }
+        return nil
+}
+
+// bufferPointer is the address of buf, or 0 for an empty buffer, which
+// asks for the size only
+func bufferPointer(buf []byte) uintptr {
+        if len(buf) == 0 {
+                return 0
+        }
+        return uintptr(unsafe.Pointer(&buf[0]))
+}
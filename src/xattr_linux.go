@@ -0,0 +1,15 @@
+package main
+
+import "syscall"
+
+func listxattr(path string, buf []byte) (int, error) {
+        return syscall.Listxattr(path, buf)
+}
+
+func getxattr(path, name string, buf []byte) (int, error) {
+        return syscall.Getxattr(path, name, buf)
+}
+
+func setxattr(path, name string, value []byte) error {
+        return syscall.Setxattr(path, name, value, 0)
+}
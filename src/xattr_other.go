@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+func readExtendedAttrs(path string) ([]extendedAttr, error) {
+        return nil, errMetadataUnsupported
+}
+
+func writeExtendedAttr(path string, attr extendedAttr) error {
+        return errMetadataUnsupported
+}
@@ -0,0 +1,62 @@
+//go:build linux || darwin
+
+package main
+
+import (
+        "bytes"
+        "errors"
+        "syscall"
+)
+
+// readExtendedAttrs lists the attributes of path and reads each one, the
+// macOS resource fork included. A file system without extended attributes
+// gives none.
+func readExtendedAttrs(path string) ([]extendedAttr, error) {
+        list, err := readXattrBuffer(func(buf []byte) (int, error) { return listxattr(path, buf) })
+        if errors.Is(err, syscall.ENOTSUP) {
+                return nil, nil
+        }
+        if err != nil {
+                return nil, err
+        }
+
+        var attrs []extendedAttr
+        for _, name := range bytes.Split(list, []byte{0}) {
+                if len(name) == 0 {
+                        continue
+                }
+                value, err := readXattrBuffer(func(buf []byte) (int, error) { return getxattr(path, string(name), buf) })
+                if err != nil {
+                        return nil, err
+                }
+                attrs = append(attrs, extendedAttr{Name: string(name), Value: value})
+        }
+        return attrs, nil
+}
+
+func writeExtendedAttr(path string, attr extendedAttr) error {
+        return setxattr(path, attr.Name, attr.Value)
+}
+
+// readXattrBuffer asks read for the size first and then reads into a
+// buffer of that size, again if the attribute grew in between
+func readXattrBuffer(read func(buf []byte) (int, error)) ([]byte, error) {
+        for {
+                size, err := read(nil)
+                if err != nil {
+                        return nil, err
+                }
+                if size == 0 {
+                        return nil, nil
+                }
+                buf := make([]byte, size)
+                n, err := read(buf)
+                if errors.Is(err, syscall.ERANGE) {
+                        continue
+                }
+                if err != nil {
+                        return nil, err
+                }
+                return buf[:n], nil
+        }
+}
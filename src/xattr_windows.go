@@ -0,0 +1,56 @@
+package main
+
+import (
+        "errors"
+        "fmt"
+        "os"
+        "os/exec"
+        "regexp"
+        "strings"
+)
+
+var streamPattern = regexp.MustCompile(`^\s*[0-9.,]+\s+.*?:([^:]+):\$DATA$`)
+
+// readExtendedAttrs lists alternate data streams with "dir /r", which
+// prints them as "<size> name.txt:stream:$DATA", and reads each one
+func readExtendedAttrs(path string) ([]extendedAttr, error) {
+        out, err := runTool("cmd", "/c", "dir", "/r", path)
+        if err != nil {
+                return nil, err
+        }
+
+        var attrs []extendedAttr
+        for _, line := range strings.Split(out, "\n") {
+                m := streamPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+                if m == nil {
+                        continue
+                }
+                data, err := os.ReadFile(path + ":" + m[1])
+                if err != nil {
+                        return nil, err
+                }
+                attrs = append(attrs, extendedAttr{Name: m[1], Value: data})
+        }
+        return attrs, nil
+}
+
+func writeExtendedAttr(path string, attr extendedAttr) error {
+        return os.WriteFile(path+":"+attr.Name, attr.Value, 0644)
+}
+
+// runTool runs an external command; a missing command means the platform
+// offers no way to handle alternate data streams
+func runTool(name string, args ...string) (string, error) {
+        if _, err := exec.LookPath(name); err != nil {
+                return "", errMetadataUnsupported
+        }
+        out, err := exec.Command(name, args...).Output()
+        if err != nil {
+                var exitErr *exec.ExitError
+                if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+                        return "", errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+                }
+                return "", fmt.Errorf("%s: %w", name, err)
+        }
+        return string(out), nil
+}
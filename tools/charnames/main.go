@@ -0,0 +1,58 @@
+// Command charnames generates src/charnames.go, the Unicode names of the
+// invisible characters listed by the audit command, from
+// golang.org/x/text/unicode/runenames.
+//
+// cleanfile itself only depends on the standard library, so the table is
+// generated once and checked in. To regenerate it, run this program in a
+// module that requires golang.org/x/text:
+//
+//      go run ./tools/charnames > src/charnames.go
+package main
+
+import (
+        "bytes"
+        "fmt"
+        "go/format"
+        "os"
+        "strings"
+        "unicode"
+
+        "golang.org/x/text/unicode/runenames"
+)
+
+// invisible are the tables audit draws from; control characters are named
+// by cleanfile itself, since their Unicode name is just "<control>"
+var invisible = []*unicode.RangeTable{
+        unicode.Cf,
+        unicode.Zs,
+        unicode.Zl,
+        unicode.Zp,
+        unicode.Variation_Selector,
+        unicode.Other_Default_Ignorable_Code_Point,
+}
+
+func main() {
+        var buf bytes.Buffer
+        fmt.Fprintf(&buf, "// Code generated by tools/charnames from golang.org/x/text/unicode/runenames (Unicode %s). DO NOT EDIT.\n\n", runenames.UnicodeVersion)
+        fmt.Fprintf(&buf, "package main\n\n")
+        fmt.Fprintf(&buf, "// invisibleNames holds the Unicode names of the format, space,\n// separator, variation selector and default-ignorable characters\n")
+        fmt.Fprintf(&buf, "var invisibleNames = map[rune]string{\n")
+        for r := rune(0); r <= unicode.MaxRune; r++ {
+                if !unicode.IsOneOf(invisible, r) {
+                        continue
+                }
+                name := runenames.Name(r)
+                if name == "" || strings.HasPrefix(name, "<") {
+                        continue
+                }
+                fmt.Fprintf(&buf, "0x%04X: %q,\n", r, name)
+        }
+        fmt.Fprintf(&buf, "}\n")
+
+        src, err := format.Source(buf.Bytes())
+        if err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+        }
+        os.Stdout.Write(src)
+}
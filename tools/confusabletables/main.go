@@ -0,0 +1,98 @@
+// Command confusabletables generates src/confusabletables.go, the
+// prototypes of the confusable characters that -preset utr39 builds word
+// skeletons with, from the confusables.txt of UTR #39. The standard unicode
+// package has no confusables data, so the table is generated once and
+// checked in:
+//
+//      curl -s https://www.unicode.org/Public/security/latest/confusables.txt |
+//              go run ./tools/confusabletables > src/confusabletables.go
+package main
+
+import (
+        "bufio"
+        "bytes"
+        "fmt"
+        "go/format"
+        "os"
+        "regexp"
+        "sort"
+        "strconv"
+        "strings"
+)
+
+var versionPattern = regexp.MustCompile(`^#\s*Version:\s*(\S+)`)
+
+func main() {
+        prototypes := make(map[rune]string)
+        version := "unknown"
+
+        scanner := bufio.NewScanner(os.Stdin)
+        for scanner.Scan() {
+                line := scanner.Text()
+                if m := versionPattern.FindStringSubmatch(line); m != nil && version == "unknown" {
+                        version = m[1]
+                }
+                data, _, _ := strings.Cut(line, "#")
+                fields := strings.Split(data, ";")
+                if len(fields) < 3 {
+                        continue
+                }
+                source := parseCodes(fields[0])
+                if len(source) != 1 {
+                        fmt.Fprintf(os.Stderr, "source of more than one character: %q\n", line)
+                        os.Exit(1)
+                }
+                prototypes[source[0]] = string(parseCodes(fields[1]))
+        }
+        if err := scanner.Err(); err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+        }
+        if len(prototypes) == 0 {
+                fmt.Fprintln(os.Stderr, "no confusables in the input")
+                os.Exit(1)
+        }
+
+        sources := make([]rune, 0, len(prototypes))
+        for r := range prototypes {
+                sources = append(sources, r)
+        }
+        sort.Slice(sources, func(i, j int) bool { return sources[i] < sources[j] })
+
+        var buf bytes.Buffer
+        fmt.Fprintf(&buf, "// Code generated by tools/confusabletables from confusables.txt (UTR #39 version %s). DO NOT EDIT.\n\n", version)
+        fmt.Fprintf(&buf, "package main\n\n")
+        fmt.Fprintf(&buf, "// confusablePrototypes maps each character of confusables.txt to its\n")
+        fmt.Fprintf(&buf, "// prototype, the character or sequence it looks like\n")
+        fmt.Fprintf(&buf, "var confusablePrototypes = map[rune]string{\n")
+        for i, r := range sources {
+                fmt.Fprintf(&buf, "0x%04X: %+q,", r, prototypes[r])
+                if i%4 == 3 {
+                        buf.WriteString("\n")
+                } else {
+                        buf.WriteString(" ")
+                }
+        }
+        fmt.Fprintf(&buf, "}\n")
+
+        src, err := format.Source(buf.Bytes())
+        if err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+        }
+        os.Stdout.Write(src)
+}
+
+// parseCodes parses a field of space-separated hexadecimal code points
+func parseCodes(field string) []rune {
+        var runes []rune
+        for _, s := range strings.Fields(field) {
+                code, err := strconv.ParseUint(s, 16, 32)
+                if err != nil {
+                        fmt.Fprintf(os.Stderr, "invalid code point %q\n", s)
+                        os.Exit(1)
+                }
+                runes = append(runes, rune(code))
+        }
+        return runes
+}
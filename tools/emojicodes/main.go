@@ -0,0 +1,66 @@
+// Command emojicodes generates src/emojicodes.go, the shortcodes (:smile:)
+// used by -emoji shortcode and -emoji unicode, from the emoji database of
+// GitHub's gemoji project, so the names match the ones GitHub, Slack and
+// most Markdown renderers understand:
+//
+//      curl -s https://raw.githubusercontent.com/github/gemoji/master/db/emoji.json |
+//              go run ./tools/emojicodes > src/emojicodes.go
+package main
+
+import (
+        "bytes"
+        "encoding/json"
+        "fmt"
+        "go/format"
+        "os"
+        "strconv"
+        "strings"
+)
+
+type gemoji struct {
+        Emoji   string   `json:"emoji"`
+        Aliases []string `json:"aliases"`
+}
+
+func main() {
+        var emojis []gemoji
+        if err := json.NewDecoder(os.Stdin).Decode(&emojis); err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+        }
+
+        var buf bytes.Buffer
+        fmt.Fprintf(&buf, "// Code generated by tools/emojicodes from gemoji's db/emoji.json. DO NOT EDIT.\n\n")
+        fmt.Fprintf(&buf, "package main\n\n")
+
+        // Emoji are looked up without variation selectors, which text
+        // often leaves out, and written out as the database has them
+        fmt.Fprintf(&buf, "// emojiShortcodes maps an emoji, without U+FE0F, to its first alias\n")
+        fmt.Fprintf(&buf, "var emojiShortcodes = map[string]string{\n")
+        seen := make(map[string]bool)
+        for _, e := range emojis {
+                key := strings.ReplaceAll(e.Emoji, "\uFE0F", "")
+                if len(e.Aliases) == 0 || seen[key] {
+                        continue
+                }
+                seen[key] = true
+                fmt.Fprintf(&buf, "%s: %q,\n", strconv.QuoteToASCII(key), e.Aliases[0])
+        }
+        fmt.Fprintf(&buf, "}\n\n")
+
+        fmt.Fprintf(&buf, "// shortcodeEmoji maps every alias to its emoji\n")
+        fmt.Fprintf(&buf, "var shortcodeEmoji = map[string]string{\n")
+        for _, e := range emojis {
+                for _, alias := range e.Aliases {
+                        fmt.Fprintf(&buf, "%q: %s,\n", alias, strconv.QuoteToASCII(e.Emoji))
+                }
+        }
+        fmt.Fprintf(&buf, "}\n")
+
+        src, err := format.Source(buf.Bytes())
+        if err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+        }
+        os.Stdout.Write(src)
+}
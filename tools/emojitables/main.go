@@ -0,0 +1,123 @@
+// Command emojitables generates src/emojitables.go, the Emoji_Presentation
+// and Extended_Pictographic properties used by -remove-emoji, from the
+// Unicode emoji data file. The standard unicode package has no emoji
+// properties, so the tables are generated once and checked in:
+//
+//      curl -s https://www.unicode.org/Public/UCD/latest/ucd/emoji/emoji-data.txt |
+//              go run ./tools/emojitables > src/emojitables.go
+package main
+
+import (
+        "bufio"
+        "bytes"
+        "fmt"
+        "go/format"
+        "os"
+        "regexp"
+        "strconv"
+        "strings"
+)
+
+// properties maps the properties of emoji-data.txt that are kept to the
+// names of the generated tables
+var properties = map[string]string{
+        "Emoji_Presentation":    "emojiPresentation",
+        "Extended_Pictographic": "extendedPictographic",
+}
+
+var versionPattern = regexp.MustCompile(`Emoji Version (\d+\.\d+)`)
+
+type runeRange struct{ lo, hi rune }
+
+func main() {
+        ranges := make(map[string][]runeRange)
+        version := "unknown"
+
+        scanner := bufio.NewScanner(os.Stdin)
+        for scanner.Scan() {
+                line := scanner.Text()
+                if m := versionPattern.FindStringSubmatch(line); m != nil && version == "unknown" {
+                        version = m[1]
+                }
+                data, _, _ := strings.Cut(line, "#")
+                codes, property, ok := strings.Cut(data, ";")
+                if !ok {
+                        continue
+                }
+                name, ok := properties[strings.TrimSpace(property)]
+                if !ok {
+                        continue
+                }
+                lo, hi, _ := strings.Cut(strings.TrimSpace(codes), "..")
+                if hi == "" {
+                        hi = lo
+                }
+                ranges[name] = appendRange(ranges[name], parseCode(lo), parseCode(hi))
+        }
+        if err := scanner.Err(); err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+        }
+
+        var buf bytes.Buffer
+        fmt.Fprintf(&buf, "// Code generated by tools/emojitables from emoji-data.txt (Emoji %s). DO NOT EDIT.\n\n", version)
+        fmt.Fprintf(&buf, "package main\n\nimport \"unicode\"\n\n")
+        writeTable(&buf, "emojiPresentation", "characters displayed as emoji by default", ranges["emojiPresentation"])
+        writeTable(&buf, "extendedPictographic", "pictographs, which display as emoji when followed by U+FE0F", ranges["extendedPictographic"])
+
+        src, err := format.Source(buf.Bytes())
+        if err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+        }
+        os.Stdout.Write(src)
+}
+
+func parseCode(s string) rune {
+        code, err := strconv.ParseUint(s, 16, 32)
+        if err != nil {
+                fmt.Fprintf(os.Stderr, "invalid code point %q\n", s)
+                os.Exit(1)
+        }
+        return rune(code)
+}
+
+// appendRange adds lo..hi, merging it with the previous range if adjacent
+func appendRange(ranges []runeRange, lo, hi rune) []runeRange {
+        if n := len(ranges); n > 0 && ranges[n-1].hi+1 == lo {
+                ranges[n-1].hi = hi
+                return ranges
+        }
+        return append(ranges, runeRange{lo, hi})
+}
+
+func writeTable(buf *bytes.Buffer, name, doc string, ranges []runeRange) {
+        var r16, r32 []runeRange
+        latin := 0
+        for _, r := range ranges {
+                switch {
+                case r.hi <= 0xFFFF:
+                        r16 = append(r16, r)
+                        if r.hi <= 0xFF {
+                                latin++
+                        }
+                case r.lo > 0xFFFF:
+                        r32 = append(r32, r)
+                default:
+                        r16 = append(r16, runeRange{r.lo, 0xFFFF})
+                        r32 = append(r32, runeRange{0x10000, r.hi})
+                }
+        }
+
+        fmt.Fprintf(buf, "// %s holds the %s\n", name, doc)
+        fmt.Fprintf(buf, "var %s = &unicode.RangeTable{\n", name)
+        fmt.Fprintf(buf, "R16: []unicode.Range16{\n")
+        for _, r := range r16 {
+                fmt.Fprintf(buf, "{0x%04X, 0x%04X, 1},\n", r.lo, r.hi)
+        }
+        fmt.Fprintf(buf, "},\nR32: []unicode.Range32{\n")
+        for _, r := range r32 {
+                fmt.Fprintf(buf, "{0x%X, 0x%X, 1},\n", r.lo, r.hi)
+        }
+        fmt.Fprintf(buf, "},\nLatinOffset: %d,\n}\n\n", latin)
+}
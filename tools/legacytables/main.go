@@ -0,0 +1,102 @@
+// Command legacytables generates src/legacytables.go, the decoding tables
+// of the legacy encodings -input-encoding reads, from
+// golang.org/x/text/encoding.
+//
+// cleanfile itself only depends on the standard library, so the tables are
+// generated once and checked in. To regenerate them, run this program in a
+// module that requires golang.org/x/text:
+//
+//      go run ./tools/legacytables > src/legacytables.go
+package main
+
+import (
+        "bytes"
+        "fmt"
+        "go/format"
+        "os"
+        "unicode/utf8"
+
+        "golang.org/x/text/encoding"
+        "golang.org/x/text/encoding/charmap"
+        "golang.org/x/text/encoding/japanese"
+)
+
+// singleByte are the single-byte encodings, by the name cleanfile reports
+var singleByte = []struct {
+        name     string
+        encoding encoding.Encoding
+}{
+        {"Windows-1252", charmap.Windows1252},
+        {"ISO-8859-1", charmap.ISO8859_1},
+        {"ISO-8859-15", charmap.ISO8859_15},
+        {"Windows-1251", charmap.Windows1251},
+        {"KOI8-R", charmap.KOI8R},
+}
+
+func main() {
+        var buf bytes.Buffer
+        fmt.Fprintf(&buf, "// Code generated by tools/legacytables from golang.org/x/text/encoding. DO NOT EDIT.\n\n")
+        fmt.Fprintf(&buf, "package main\n\n")
+
+        fmt.Fprintf(&buf, "// singleByteTables maps the bytes 0x80-0xFF of each single-byte encoding to\n// the characters they stand for; utf8.RuneError marks bytes left undefined\n")
+        fmt.Fprintf(&buf, "var singleByteTables = map[string]*[128]rune{\n")
+        for _, e := range singleByte {
+                fmt.Fprintf(&buf, "%q: {\n", e.name)
+                decoder := e.encoding.NewDecoder()
+                for b := 0x80; b <= 0xFF; b++ {
+                        r := decode(decoder, []byte{byte(b)})
+                        fmt.Fprintf(&buf, "0x%04X,", r)
+                        lineBreak(&buf, b, 8)
+                }
+                fmt.Fprintf(&buf, "},\n")
+        }
+        fmt.Fprintf(&buf, "}\n\n")
+
+        fmt.Fprintf(&buf, "// shiftJISTable maps the lead and trail byte of each double-byte Shift_JIS\n// (Windows-31J) character to the character\n")
+        fmt.Fprintf(&buf, "var shiftJISTable = map[uint16]rune{\n")
+        decoder := japanese.ShiftJIS.NewDecoder()
+        i := 0
+        for lead := 0x81; lead <= 0xFC; lead++ {
+                if lead >= 0xA0 && lead <= 0xDF {
+                        continue
+                }
+                for trail := 0x40; trail <= 0xFC; trail++ {
+                        if trail == 0x7F {
+                                continue
+                        }
+                        r := decode(decoder, []byte{byte(lead), byte(trail)})
+                        if r == utf8.RuneError {
+                                continue
+                        }
+                        fmt.Fprintf(&buf, "0x%02X%02X: 0x%04X,", lead, trail, r)
+                        lineBreak(&buf, i, 6)
+                        i++
+                }
+        }
+        fmt.Fprintf(&buf, "}\n")
+
+        src, err := format.Source(buf.Bytes())
+        if err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+        }
+        os.Stdout.Write(src)
+}
+
+// decode returns the single character encoded by b, or utf8.RuneError
+func decode(decoder *encoding.Decoder, b []byte) rune {
+        decoded, err := decoder.Bytes(b)
+        if err != nil || utf8.RuneCount(decoded) != 1 {
+                return utf8.RuneError
+        }
+        r, _ := utf8.DecodeRune(decoded)
+        return r
+}
+
+func lineBreak(buf *bytes.Buffer, i, perLine int) {
+        if i%perLine == perLine-1 {
+                buf.WriteString("\n")
+        } else {
+                buf.WriteString(" ")
+        }
+}
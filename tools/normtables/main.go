@@ -0,0 +1,234 @@
+// Command normtables generates src/normtables.go, the Unicode normalization
+// data used by -normalize-unicode, from golang.org/x/text/unicode/norm.
+//
+// cleanfile itself only depends on the standard library, so the tables are
+// generated once and checked in. To regenerate them, run this program in a
+// module that requires golang.org/x/text:
+//
+//      go run ./tools/normtables > src/normtables.go
+//
+// With -test it writes the conformance tests of the tables instead, in the
+// format of the NormalizationTest.txt of the Unicode Character Database,
+// with the expected forms computed by x/text (which passes that file):
+//
+//      go run ./tools/normtables -test > src/testdata/NormalizationTest.txt
+//
+// The NormalizationTest.txt of the same Unicode version can be put in its
+// place; TestNormalizationConformance reads either.
+package main
+
+import (
+        "bufio"
+        "bytes"
+        "flag"
+        "fmt"
+        "go/format"
+        "io"
+        "os"
+        "strings"
+        "sort"
+        "unicode"
+        "unicode/utf8"
+
+        "golang.org/x/text/unicode/norm"
+)
+
+const (
+        hangulBase  = 0xAC00
+        hangulCount = 11172
+)
+
+func isHangulSyllable(r rune) bool {
+        return r >= hangulBase && r < hangulBase+hangulCount
+}
+
+func main() {
+        test := flag.Bool("test", false, "write the conformance tests instead of the tables")
+        flag.Parse()
+        if *test {
+                w := bufio.NewWriter(os.Stdout)
+                writeConformance(w)
+                if err := w.Flush(); err != nil {
+                        fmt.Fprintln(os.Stderr, err)
+                        os.Exit(1)
+                }
+                return
+        }
+
+        classes := make(map[rune]uint8)
+        canonical := make(map[rune]string)
+        compat := make(map[rune]string)
+        compositions := make(map[[2]rune]rune)
+
+        for r := rune(0); r <= unicode.MaxRune; r++ {
+                if !utf8.ValidRune(r) || isHangulSyllable(r) {
+                        continue
+                }
+                s := string(r)
+                if ccc := norm.NFD.PropertiesString(s).CCC(); ccc != 0 {
+                        classes[r] = ccc
+                }
+                nfd := norm.NFD.String(s)
+                if nfd != s {
+                        canonical[r] = nfd
+                }
+                if nfkd := norm.NFKD.String(s); nfkd != nfd {
+                        compat[r] = nfkd
+                }
+
+                // Primary composites are the characters that survive NFC
+                // although they have a canonical decomposition
+                if nfd != s && norm.NFC.String(s) == s {
+                        pair, ok := compositionPair(r, []rune(nfd))
+                        if !ok {
+                                fmt.Fprintf(os.Stderr, "no composition pair for U+%04X\n", r)
+                                os.Exit(1)
+                        }
+                        compositions[pair] = r
+                }
+        }
+
+        var buf bytes.Buffer
+        fmt.Fprintf(&buf, "// Code generated by tools/normtables from golang.org/x/text/unicode/norm (Unicode %s). DO NOT EDIT.\n\n", norm.Version)
+        fmt.Fprintf(&buf, "package main\n\n")
+        fmt.Fprintf(&buf, "// combiningClasses holds the non-zero canonical combining classes\n")
+        fmt.Fprintf(&buf, "var combiningClasses = map[rune]uint8{\n")
+        for i, r := range sortedKeys(classes) {
+                fmt.Fprintf(&buf, "0x%04X: %d,", r, classes[r])
+                lineBreak(&buf, i, 8)
+        }
+        fmt.Fprintf(&buf, "}\n\n")
+
+        writeDecompositions(&buf, "canonicalDecompositions", "holds the full canonical decomposition (NFD) of every\n// character that has one, except the algorithmic Hangul syllables", canonical)
+        writeDecompositions(&buf, "compatDecompositions", "holds the full compatibility decomposition (NFKD) of the\n// characters where it differs from the canonical one", compat)
+
+        pairs := make([][2]rune, 0, len(compositions))
+        for pair := range compositions {
+                pairs = append(pairs, pair)
+        }
+        sort.Slice(pairs, func(i, j int) bool {
+                if pairs[i][0] != pairs[j][0] {
+                        return pairs[i][0] < pairs[j][0]
+                }
+                return pairs[i][1] < pairs[j][1]
+        })
+        fmt.Fprintf(&buf, "// compositions maps the canonical decomposition pairs of the primary\n// composites to the composite (composition exclusions are left out)\n")
+        fmt.Fprintf(&buf, "var compositions = map[[2]rune]rune{\n")
+        for i, pair := range pairs {
+                fmt.Fprintf(&buf, "{0x%04X, 0x%04X}: 0x%04X,", pair[0], pair[1], compositions[pair])
+                lineBreak(&buf, i, 3)
+        }
+        fmt.Fprintf(&buf, "}\n")
+
+        src, err := format.Source(buf.Bytes())
+        if err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+        }
+        os.Stdout.Write(src)
+}
+
+// compositionPair finds the two characters that compose to r: its full
+// decomposition split into a prefix and a suffix that each normalize to a
+// single character
+func compositionPair(r rune, nfd []rune) ([2]rune, bool) {
+        for k := len(nfd) - 1; k > 0; k-- {
+                first := []rune(norm.NFC.String(string(nfd[:k])))
+                second := []rune(norm.NFC.String(string(nfd[k:])))
+                if len(first) == 1 && len(second) == 1 && norm.NFC.String(string(first)+string(second)) == string(r) {
+                        return [2]rune{first[0], second[0]}, true
+                }
+        }
+        return [2]rune{}, false
+}
+
+func writeDecompositions(buf *bytes.Buffer, name, doc string, m map[rune]string) {
+        fmt.Fprintf(buf, "// %s %s\n", name, doc)
+        fmt.Fprintf(buf, "var %s = map[rune]string{\n", name)
+        for i, r := range sortedKeys(m) {
+                fmt.Fprintf(buf, "0x%04X: %+q,", r, m[r])
+                lineBreak(buf, i, 4)
+        }
+        fmt.Fprintf(buf, "}\n\n")
+}
+
+func lineBreak(buf *bytes.Buffer, i, perLine int) {
+        if i%perLine == perLine-1 {
+                buf.WriteString("\n")
+        } else {
+                buf.WriteString(" ")
+        }
+}
+
+func sortedKeys[V any](m map[rune]V) []rune {
+        keys := make([]rune, 0, len(m))
+        for r := range m {
+                keys = append(keys, r)
+        }
+        sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+        return keys
+}
+
+// writeConformance writes the lines "source;NFC;NFD;NFKC;NFKD;" of
+// NormalizationTest.txt for specific cases (part 0), every character that
+// some form changes or that is not a starter (part 1, which the other
+// characters must not be changed by any form), each non-starter reordered
+// among others (part 2) and each composition pair, adjacent and with a
+// non-starter in between (part 3)
+func writeConformance(w io.Writer) {
+        fmt.Fprintf(w, "# Generated by tools/normtables -test from golang.org/x/text/unicode/norm (Unicode %s).\n", norm.Version)
+        fmt.Fprintf(w, "# Format: source;NFC;NFD;NFKC;NFKD; as in the NormalizationTest.txt of the UCD\n")
+
+        fmt.Fprintf(w, "@Part0 # Specific cases\n")
+        for _, s := range []string{
+                "\u1100\u1161\u11A8", "\u1100\u1161\u11A8\u11A8", "\uAC00\u11A8", "\uAC01\u11A8", "\u1100\u0301\u1161",
+                "\u0B47\u0300\u0B3E", "\u0B47\u0B3E", "\u0041\u0300\u0301", "\u0041\u0301\u0300", "\u0045\u0304\u0300",
+                "\u0061\u0328\u0301", "\u0061\u0301\u0328", "\u0301\u0061", "\u0344", "\u0F73\u0F71", "\u1E0A\u0323",
+                "\u00C5\u0301", "\u212B", "\uFB01\u0301", "\u1E9B\u0323", "\U0001D15E\U0001D165",
+        } {
+                writeCase(w, s)
+        }
+
+        fmt.Fprintf(w, "@Part1 # Character by character test\n")
+        for r := rune(0); r <= unicode.MaxRune; r++ {
+                if !utf8.ValidRune(r) {
+                        continue
+                }
+                s := string(r)
+                if norm.NFD.PropertiesString(s).CCC() != 0 || norm.NFKD.String(s) != s || norm.NFC.String(s) != s {
+                        writeCase(w, s)
+                }
+        }
+
+        fmt.Fprintf(w, "@Part2 # Canonical order test\n")
+        for r := rune(0); r <= unicode.MaxRune; r++ {
+                if utf8.ValidRune(r) && norm.NFD.PropertiesString(string(r)).CCC() != 0 {
+                        writeCase(w, "a"+string(r)+"\u0315\u0300\u05AE\u0300b")
+                        writeCase(w, "a\u0315\u0300\u05AE"+string(r)+"\u0300b")
+                }
+        }
+
+        fmt.Fprintf(w, "@Part3 # Composition test\n")
+        for r := rune(0); r <= unicode.MaxRune; r++ {
+                s := string(r)
+                if !utf8.ValidRune(r) || isHangulSyllable(r) || norm.NFC.String(s) != s || norm.NFD.String(s) == s {
+                        continue
+                }
+                pair, _ := compositionPair(r, []rune(norm.NFD.String(s)))
+                writeCase(w, string(pair[0])+string(pair[1]))
+                writeCase(w, string(pair[0])+"\u0334"+string(pair[1]))
+                writeCase(w, string(pair[0])+"\u0327"+string(pair[1]))
+        }
+}
+
+// writeCase writes the line of s and its four normalization forms
+func writeCase(w io.Writer, s string) {
+        for _, form := range []string{s, norm.NFC.String(s), norm.NFD.String(s), norm.NFKC.String(s), norm.NFKD.String(s)} {
+                codes := make([]string, 0, len(form))
+                for _, r := range form {
+                        codes = append(codes, fmt.Sprintf("%04X", r))
+                }
+                fmt.Fprintf(w, "%s;", strings.Join(codes, " "))
+        }
+        fmt.Fprintln(w)
+}
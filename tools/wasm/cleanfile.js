@@ -0,0 +1,37 @@
+// cleanfile.js loads cleanfile.wasm, built as described in wasm_js.go, in a
+// page that has loaded wasm_exec.js from the Go distribution
+// ($(go env GOROOT)/lib/wasm/wasm_exec.js):
+//
+//   const cleaner = await loadCleanfile("cleanfile.wasm");
+//   const {content, report} = cleaner.cleanString(text, {ascii: false});
+//   const {report} = cleaner.stats(text, {profile: "chat"});
+//   const columns = cleaner.displayWidth(line);
+//
+// Unlike the functions of the wasm module itself, these throw an Error for
+// invalid options and text that cannot be cleaned.
+async function loadCleanfile(url) {
+  const go = new Go();
+  const {instance} = await WebAssembly.instantiateStreaming(fetch(url), go.importObject);
+  // Runs until the module waits for calls, by which time it has defined
+  // globalThis.cleanfile
+  go.run(instance);
+
+  const throwing = (fn) => (text, options) => {
+    const answer = fn(text, options || {});
+    if (answer.error) {
+      throw new Error(answer.error);
+    }
+    return answer;
+  };
+  return {
+    cleanString: throwing(globalThis.cleanfile.cleanString),
+    stats: throwing(globalThis.cleanfile.stats),
+    displayWidth: (text) => {
+      const answer = globalThis.cleanfile.displayWidth(text);
+      if (answer.error) {
+        throw new Error(answer.error);
+      }
+      return answer;
+    },
+  };
+}
@@ -0,0 +1,92 @@
+//go:build js && wasm
+
+// The js/wasm entry point of cleanfile, for cleaning text in a browser with
+// the same engine as the command. It is built with the files of the engine
+// alone, listed in engine.txt, which use neither the file system nor the
+// flags of the command, and added to them by an overlay, since every file
+// in src is compiled by the native build:
+//
+//      cd src && GOOS=js GOARCH=wasm go build -overlay ../tools/wasm/overlay.json -o cleanfile.wasm $(cat ../tools/wasm/engine.txt) wasm_js.go
+//
+// Run under wasm_exec.js from the Go distribution (see cleanfile.js), it
+// defines a global cleanfile object with three functions:
+//
+//      cleanfile.cleanString(text, options) -> {content, report}
+//      cleanfile.stats(text, options)       -> {report}
+//      cleanfile.displayWidth(text)         -> columns
+//
+// options are named as the flags of clean ({ascii: false, strip: "html",
+// profile: "chat"}) and taken by newCleaningOptions, with the built-in
+// profiles but no config file, and report is the -report json report of
+// the text. Invalid options and text that cannot be cleaned give {error}
+// instead. displayWidth measures text as -warn-line-length does.
+package main
+
+import (
+        "encoding/json"
+        "strings"
+        "syscall/js"
+)
+
+func main() {
+        js.Global().Set("cleanfile", map[string]interface{}{
+                "cleanString": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+                        return jsClean(args, false)
+                }),
+                "stats": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+                        return jsClean(args, true)
+                }),
+                "displayWidth": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+                        if len(args) == 0 || args[0].Type() != js.TypeString {
+                                return jsError("the text must be a string")
+                        }
+                        return DisplayWidth(args[0].String())
+                }),
+        })
+        // The functions are called for as long as the page is open
+        select {}
+}
+
+// jsClean cleans the text of args[0] with the options of args[1], or only
+// reports what would change if checkOnly
+func jsClean(args []js.Value, checkOnly bool) interface{} {
+        if len(args) == 0 || args[0].Type() != js.TypeString {
+                return jsError("the text must be a string")
+        }
+        given := make(map[string]string)
+        if len(args) > 1 && args[1].Type() == js.TypeObject {
+                keys := js.Global().Get("Object").Call("keys", args[1])
+                for i := 0; i < keys.Length(); i++ {
+                        key := keys.Index(i).String()
+                        given[key] = js.Global().Call("String", args[1].Get(key)).String()
+                }
+        }
+        options, err := newCleaningOptions(given)
+        if err != nil {
+                return jsError(err.Error())
+        }
+
+        var sink OutputSink = &BufferSink{}
+        if checkOnly {
+                sink = &discardSink{}
+        }
+        stats, err := cleanReader(strings.NewReader(args[0].String()), nil, "(input)", sink, options, false)
+        if err != nil {
+                return jsError(err.Error())
+        }
+        result := FileResult{InputPath: "(input)", Stats: stats}
+        data, err := json.Marshal(newJSONReport([]FileResult{result}, options.TargetOS, checkOnly, nil).Files[0])
+        if err != nil {
+                return jsError(err.Error())
+        }
+
+        answer := map[string]interface{}{"report": js.Global().Get("JSON").Call("parse", string(data))}
+        if buffer, ok := sink.(*BufferSink); ok {
+                answer["content"] = buffer.Buffer.String()
+        }
+        return answer
+}
+
+func jsError(message string) interface{} {
+        return map[string]interface{}{"error": message}
+}